@@ -0,0 +1,120 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadDefaults(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "development")
+	t.Setenv("SHUTDOWN_TIMEOUT_SECONDS", "45")
+	t.Setenv("CACHE_TTL_SECONDS", "2m")
+	t.Setenv("API_V1_SUNSET", "2027-06-30")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("expected development defaults to load, got %v", err)
+	}
+	if cfg.ShutdownTimeout != 45*time.Second {
+		t.Errorf("expected a bare number to count seconds, got %v", cfg.ShutdownTimeout)
+	}
+	if cfg.CacheTTL != 2*time.Minute {
+		t.Errorf("expected a duration string to be parsed, got %v", cfg.CacheTTL)
+	}
+	if !cfg.APIV1Sunset.Equal(time.Date(2027, 6, 30, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the v1 sunset date to be parsed, got %v", cfg.APIV1Sunset)
+	}
+	if cfg.NotificationEscalation != 30*time.Minute {
+		t.Errorf("expected the default escalation of 30m, got %v", cfg.NotificationEscalation)
+	}
+}
+
+func TestLoadDatabasePoolDefaults(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "development")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("expected development defaults to load, got %v", err)
+	}
+	if cfg.DBMaxOpenConns != 10 || cfg.DBMaxIdleConns != 5 || cfg.DBLockTimeout != 0 {
+		t.Errorf("expected a small development pool, got open=%d idle=%d lock=%v", cfg.DBMaxOpenConns, cfg.DBMaxIdleConns, cfg.DBLockTimeout)
+	}
+
+	t.Setenv("ENVIRONMENT", "staging")
+	t.Setenv("DB_STATEMENT_TIMEOUT_SECONDS", "1m")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("expected staging defaults to load, got %v", err)
+	}
+	if cfg.DBMaxOpenConns != 25 || cfg.DBMaxIdleConns != 25 || cfg.DBLockTimeout != 30*time.Second {
+		t.Errorf("expected the deployed pool, got open=%d idle=%d lock=%v", cfg.DBMaxOpenConns, cfg.DBMaxIdleConns, cfg.DBLockTimeout)
+	}
+	if cfg.DBStatementTimeout != time.Minute {
+		t.Errorf("expected a statement timeout of 1m, got %v", cfg.DBStatementTimeout)
+	}
+
+	t.Setenv("DB_MAX_IDLE_CONNS", "40")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "DB_MAX_IDLE_CONNS") {
+		t.Errorf("expected more idle than open connections to be rejected, got %v", err)
+	}
+}
+
+func TestLoadListsEveryProblem(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "production")
+	t.Setenv("DATABASE_URL", "")
+	t.Setenv("REDIS_URL", "http://cache:6379")
+	t.Setenv("JWT_SECRET", defaultJWTSecret)
+	t.Setenv("REFRESH_SECRET", "short")
+	t.Setenv("SMTP_PORT", "twenty-five")
+	t.Setenv("SHUTDOWN_TIMEOUT_SECONDS", "-5")
+	t.Setenv("API_V1_SUNSET", "next June")
+	t.Setenv("BACKUP_STORAGE", "s3")
+	t.Setenv("BACKUP_S3_REGION", "eu-west-1")
+	t.Setenv("STORAGE_DRIVER", "minio")
+	t.Setenv("SEARCH_URL", "https://search:9200")
+	t.Setenv("SEARCH_INDEX_PREFIX", "RTIMS")
+	t.Setenv("MQTT_URL", "tcp://broker:1883")
+	t.Setenv("GRPC_PORT", "9090")
+	t.Setenv("GRPC_CERT_FILE", "/etc/rtims/grpc.crt")
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://app.example.com,https://app.example.com/login")
+	t.Setenv("DEV_SEED_ENABLED", "true")
+
+	_, err := Load()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+
+	expected := []string{"DATABASE_URL: must be set", "REDIS_URL: scheme", "JWT_SECRET: must be changed", "REFRESH_SECRET: must be at least", "SMTP_PORT", "SHUTDOWN_TIMEOUT_SECONDS", "API_V1_SUNSET", "BACKUP_S3_BUCKET: must be set", "STORAGE_ENDPOINT: must be set", "SEARCH_INDEX_PREFIX", "MQTT_URL: scheme", "GRPC_CLIENT_CA_FILE: must be set", "CORS_ALLOWED_ORIGINS", "DEV_SEED_ENABLED"}
+	for _, want := range expected {
+		found := false
+		for _, problem := range validationErr.Problems {
+			if strings.HasPrefix(problem, want) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected a problem starting %q in %v", want, validationErr.Problems)
+		}
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := &Config{
+		DatabaseURL:  "postgres://rtims:hunter2@db:5432/rtims",
+		JWTSecret:    "a-very-long-secret-that-must-not-be-logged",
+		SMTPPassword: "",
+		Port:         "8080",
+	}
+	dump := cfg.Redacted()
+
+	if strings.Contains(dump, "hunter2") || strings.Contains(dump, "must-not-be-logged") {
+		t.Errorf("expected secrets to be redacted, got:\n%s", dump)
+	}
+	for _, want := range []string{"Port=8080", "JWTSecret=[redacted]", "SMTPPassword=\n", "DatabaseURL=postgres://rtims:xxxxx@db:5432/rtims"} {
+		if !strings.Contains(dump, want) {
+			t.Errorf("expected %q in:\n%s", want, dump)
+		}
+	}
+}
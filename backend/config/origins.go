@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ParseOrigin checks an allowed origin, such as https://app.example.com or
+// http://localhost:3000, and returns it lowercased. A host starting with
+// "*." allows every subdomain of the rest, such as https://*.example.com
+// for https://shop.example.com, but not the bare domain.
+func ParseOrigin(value string) (string, error) {
+	origin := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(value), "/"))
+	parsed, err := url.Parse(origin)
+	if err != nil || parsed.Host == "" {
+		return "", fmt.Errorf("%q is not an origin such as https://app.example.com", value)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return "", fmt.Errorf("%q: scheme must be http or https", value)
+	}
+	if parsed.Path != "" || parsed.RawQuery != "" || parsed.Fragment != "" || parsed.User != nil {
+		return "", fmt.Errorf("%q: an origin has no path, query or user", value)
+	}
+
+	host := parsed.Hostname()
+	if strings.HasPrefix(host, "*.") {
+		host = host[2:]
+	}
+	// Wildcards stand for a whole label, and a domain is needed after one
+	if host == "" || strings.Contains(host, "*") || (host != parsed.Hostname() && !strings.Contains(host, ".")) {
+		return "", fmt.Errorf("%q: only a leading *. may match subdomains, such as https://*.example.com", value)
+	}
+	return origin, nil
+}
+
+// ParseOrigins checks a comma-separated list of allowed origins, returning
+// them lowercased.
+func ParseOrigins(value string) ([]string, error) {
+	origins := []string{}
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		origin, err := ParseOrigin(part)
+		if err != nil {
+			return nil, err
+		}
+		origins = append(origins, origin)
+	}
+	return origins, nil
+}
+
+// MatchOrigin reports whether the Origin header of a request is one of
+// allowed, as returned by ParseOrigin.
+func MatchOrigin(origin string, allowed []string) bool {
+	origin = strings.ToLower(origin)
+	scheme, host, ok := strings.Cut(origin, "://")
+	if !ok || host == "" {
+		return false
+	}
+
+	for _, pattern := range allowed {
+		if pattern == origin {
+			return true
+		}
+		patternScheme, patternHost, _ := strings.Cut(pattern, "://")
+		suffix, wildcard := strings.CutPrefix(patternHost, "*")
+		if wildcard && patternScheme == scheme && strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+			// The subdomain is one or more labels, not part of one
+			if sub := strings.TrimSuffix(host, suffix); !strings.ContainsAny(sub, ":/") {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseOrigin(t *testing.T) {
+	for value, want := range map[string]string{
+		"https://App.Example.com/":   "https://app.example.com",
+		"http://localhost:3000":      "http://localhost:3000",
+		" https://*.example.com ":    "https://*.example.com",
+		"https://*.example.com:8443": "https://*.example.com:8443",
+	} {
+		if got, err := ParseOrigin(value); err != nil || got != want {
+			t.Errorf("ParseOrigin(%q) = %q, %v; want %q", value, got, err, want)
+		}
+	}
+
+	for _, value := range []string{
+		"*", "example.com", "ftp://example.com", "https://example.com/app", "https://example.com?x=1",
+		"https://user@example.com", "https://*", "https://*.com", "https://app.*.example.com", "https://*example.com",
+	} {
+		if _, err := ParseOrigin(value); err == nil {
+			t.Errorf("ParseOrigin(%q): expected an error", value)
+		}
+	}
+}
+
+func TestParseOrigins(t *testing.T) {
+	origins, err := ParseOrigins("https://a.example.com, ,https://*.example.org")
+	if err != nil || !reflect.DeepEqual(origins, []string{"https://a.example.com", "https://*.example.org"}) {
+		t.Errorf("unexpected origins %v, %v", origins, err)
+	}
+	if _, err := ParseOrigins("https://a.example.com,example.org"); err == nil {
+		t.Error("expected an invalid origin to be rejected")
+	}
+}
+
+func TestMatchOrigin(t *testing.T) {
+	allowed := []string{"http://localhost:3000", "https://*.example.com", "https://*.example.org:8443"}
+	for origin, want := range map[string]bool{
+		"http://localhost:3000":            true,
+		"http://LOCALHOST:3000":            true,
+		"http://localhost:3001":            false,
+		"https://shop.example.com":         true,
+		"https://eu.shop.example.com":      true,
+		"https://example.com":              false,
+		"http://shop.example.com":          false,
+		"https://shop.example.com:8080":    false,
+		"https://shopexample.com":          false,
+		"https://shop.example.com.evil.io": false,
+		"https://shop.example.org:8443":    true,
+		"https://shop.example.org":         false,
+		"null":                             false,
+		"":                                 false,
+	} {
+		if got := MatchOrigin(origin, allowed); got != want {
+			t.Errorf("MatchOrigin(%q) = %v, want %v", origin, got, want)
+		}
+	}
+}
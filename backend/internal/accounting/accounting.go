@@ -0,0 +1,273 @@
+// Package accounting exports stock movements and price changes as journal
+// entries for QuickBooks or Xero. Every export covers the period since the
+// previous one and locks it, so exported movements cannot change.
+package accounting
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"rtims-backend/internal/models"
+	"rtims-backend/internal/scheduler"
+)
+
+const (
+	// SettingAutoExport is the format the accounting_export job exports
+	// the previous month in on the 1st of every month, or "off".
+	SettingAutoExport = "accounting_auto_export"
+	// SettingAccounts holds a JSON object of the ledger accounts journal
+	// lines are posted to, by role; see Accounts.
+	SettingAccounts = "accounting_accounts"
+)
+
+// monthlySchedule is when the accounting_export job runs unless it is off.
+const monthlySchedule = "0 2 1 * *"
+
+// AutoExportOptions are the values of SettingAutoExport.
+var AutoExportOptions = []string{
+	scheduler.ScheduleOff,
+	string(models.AccountingQuickBooksCSV),
+	string(models.AccountingQuickBooksIIF),
+	string(models.AccountingXeroCSV),
+}
+
+// Schedule returns the default schedule of the accounting_export job for
+// the given settings: monthly, unless automatic exports are off.
+func Schedule(settings map[string]interface{}) string {
+	format, _ := settings[SettingAutoExport].(string)
+	if format == "" || format == scheduler.ScheduleOff {
+		return scheduler.ScheduleOff
+	}
+	return monthlySchedule
+}
+
+// Accounts are the ledger accounts journal lines are posted to: account
+// names for QuickBooks, account codes for Xero. Every posting moves value
+// between Inventory and the account of its kind.
+type Accounts struct {
+	Inventory string `json:"inventory"`
+	// Received takes purchases until the supplier's bill is entered.
+	Received    string `json:"received"`
+	CostOfSales string `json:"cost_of_sales"`
+	// Adjustments takes adjustments, damaged stock and revaluations.
+	Adjustments string `json:"adjustments"`
+}
+
+// DefaultAccounts are the accounts used for those settings leave out.
+var DefaultAccounts = Accounts{
+	Inventory:   "Inventory Asset",
+	Received:    "Goods Received Not Invoiced",
+	CostOfSales: "Cost of Goods Sold",
+	Adjustments: "Inventory Adjustments",
+}
+
+// DefaultAccountsSetting is the default value of SettingAccounts.
+func DefaultAccountsSetting() string {
+	encoded, _ := json.Marshal(DefaultAccounts)
+	return string(encoded)
+}
+
+// ParseAccounts parses the SettingAccounts value. Accounts it leaves out
+// are the defaults.
+func ParseAccounts(value string) (Accounts, error) {
+	accounts := DefaultAccounts
+	if strings.TrimSpace(value) == "" {
+		return accounts, nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&accounts); err != nil {
+		return Accounts{}, errors.New("must be an object with inventory, received, cost_of_sales and adjustments accounts")
+	}
+	for name, account := range map[string]string{
+		"inventory":     accounts.Inventory,
+		"received":      accounts.Received,
+		"cost_of_sales": accounts.CostOfSales,
+		"adjustments":   accounts.Adjustments,
+	} {
+		if strings.TrimSpace(account) == "" {
+			return Accounts{}, fmt.Errorf("%s must not be empty", name)
+		}
+	}
+	return accounts, nil
+}
+
+// contra returns the account postings of kind move value to and from
+// Inventory, and "" for kinds that do not change the value of stock.
+func (a Accounts) contra(kind string) string {
+	switch kind {
+	case string(models.ReasonPurchase):
+		return a.Received
+	case string(models.ReasonSale), string(models.ReasonReturn):
+		return a.CostOfSales
+	case string(models.ReasonAdjustment), string(models.ReasonDamage), models.PostingRevaluation:
+		return a.Adjustments
+	}
+	return ""
+}
+
+// kinds describes each kind of posting in journal numbers and memos.
+var kinds = map[string]struct{ code, label string }{
+	string(models.ReasonPurchase):   {"PUR", "purchases"},
+	string(models.ReasonSale):       {"SAL", "sales"},
+	string(models.ReasonReturn):     {"RET", "returns"},
+	string(models.ReasonAdjustment): {"ADJ", "adjustments"},
+	string(models.ReasonDamage):     {"DAM", "damaged stock"},
+	models.PostingRevaluation:       {"REV", "revaluation"},
+}
+
+// Line is a debit or a credit of a journal entry.
+type Line struct {
+	Account string
+	Debit   float64
+	Credit  float64
+}
+
+// Journal is a balanced journal entry.
+type Journal struct {
+	// Number is unique across exports, as each day is only exported once.
+	Number string
+	Date   time.Time
+	Memo   string
+	Lines  []Line
+}
+
+// Journals turns postings into one journal entry each. Postings of kinds
+// that do not change the value of stock, such as transfers, and those that
+// round to nothing are left out.
+func Journals(postings []models.AccountingPosting, accounts Accounts) []Journal {
+	journals := []Journal{}
+	for _, posting := range postings {
+		contra := accounts.contra(posting.Kind)
+		value := math.Round(posting.Value*100) / 100
+		if contra == "" || value == 0 {
+			continue
+		}
+
+		kind := kinds[posting.Kind]
+		noun := "movement"
+		if posting.Kind == models.PostingRevaluation {
+			noun = "price change"
+		}
+		if posting.Count != 1 {
+			noun += "s"
+		}
+		journal := Journal{
+			Number: fmt.Sprintf("RT-%s-%s", posting.Date.Format("20060102"), kind.code),
+			Date:   posting.Date,
+			Memo:   fmt.Sprintf("RTIMS %s, %d %s", kind.label, posting.Count, noun),
+		}
+		if value > 0 {
+			journal.Lines = []Line{{Account: accounts.Inventory, Debit: value}, {Account: contra, Credit: value}}
+		} else {
+			journal.Lines = []Line{{Account: contra, Debit: -value}, {Account: accounts.Inventory, Credit: -value}}
+		}
+		journals = append(journals, journal)
+	}
+	return journals
+}
+
+// Debits totals the debits of journals.
+func Debits(journals []Journal) float64 {
+	total := 0.0
+	for _, journal := range journals {
+		for _, line := range journal.Lines {
+			total += line.Debit
+		}
+	}
+	return math.Round(total*100) / 100
+}
+
+// Extension returns the file extension of format.
+func Extension(format models.AccountingFormat) string {
+	if format == models.AccountingQuickBooksIIF {
+		return "iif"
+	}
+	return "csv"
+}
+
+// Render writes journals in format.
+func Render(format models.AccountingFormat, journals []Journal) ([]byte, error) {
+	switch format {
+	case models.AccountingQuickBooksCSV:
+		return renderQuickBooksCSV(journals)
+	case models.AccountingQuickBooksIIF:
+		return renderIIF(journals), nil
+	case models.AccountingXeroCSV:
+		return renderXeroCSV(journals)
+	}
+	return nil, fmt.Errorf("unknown accounting format %q", format)
+}
+
+func amount(value float64) string {
+	if value == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%.2f", value)
+}
+
+// renderQuickBooksCSV writes the journal entry import of QuickBooks Online.
+func renderQuickBooksCSV(journals []Journal) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"Journal No", "Journal Date", "Account", "Debits", "Credits", "Description"})
+	for _, journal := range journals {
+		for _, line := range journal.Lines {
+			w.Write([]string{journal.Number, journal.Date.Format("01/02/2006"), line.Account,
+				amount(line.Debit), amount(line.Credit), journal.Memo})
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// iifField keeps a value from breaking the tab-separated IIF layout.
+var iifField = strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+
+// renderIIF writes general journal transactions for QuickBooks Desktop.
+// The first line of each is its TRNS line, the rest SPL lines; debits are
+// positive and credits negative.
+func renderIIF(journals []Journal) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("!TRNS\tTRNSTYPE\tDATE\tACCNT\tAMOUNT\tDOCNUM\tMEMO\n")
+	buf.WriteString("!SPL\tTRNSTYPE\tDATE\tACCNT\tAMOUNT\tDOCNUM\tMEMO\n")
+	buf.WriteString("!ENDTRNS\n")
+	for _, journal := range journals {
+		for i, line := range journal.Lines {
+			kind := "SPL"
+			if i == 0 {
+				kind = "TRNS"
+			}
+			fmt.Fprintf(&buf, "%s\tGENERAL JOURNAL\t%s\t%s\t%.2f\t%s\t%s\n", kind, journal.Date.Format("01/02/2006"),
+				iifField.Replace(line.Account), line.Debit-line.Credit, journal.Number, iifField.Replace(journal.Memo))
+		}
+		buf.WriteString("ENDTRNS\n")
+	}
+	return buf.Bytes()
+}
+
+// xeroTaxRate is the tax rate of every line of a Xero journal, as moving
+// stock between ledger accounts is not a taxable supply.
+const xeroTaxRate = "Tax Exempt"
+
+// renderXeroCSV writes the manual journal import of Xero. Debits are
+// positive amounts and credits negative.
+func renderXeroCSV(journals []Journal) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"*Narration", "*Date", "Description", "*AccountCode", "*TaxRate", "*Amount"})
+	for _, journal := range journals {
+		for _, line := range journal.Lines {
+			w.Write([]string{journal.Number + " " + journal.Memo, journal.Date.Format("02/01/2006"), journal.Memo,
+				line.Account, xeroTaxRate, fmt.Sprintf("%.2f", line.Debit-line.Credit)})
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
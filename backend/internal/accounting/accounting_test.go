@@ -0,0 +1,117 @@
+package accounting
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"rtims-backend/internal/models"
+	"rtims-backend/internal/scheduler"
+)
+
+func TestParseAccounts(t *testing.T) {
+	accounts, err := ParseAccounts(`{"inventory": "1400", "cost_of_sales": "5000"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accounts.Inventory != "1400" || accounts.CostOfSales != "5000" || accounts.Received != DefaultAccounts.Received {
+		t.Errorf("expected the accounts left out to be the defaults, got %+v", accounts)
+	}
+
+	if accounts, err := ParseAccounts(DefaultAccountsSetting()); err != nil || accounts != DefaultAccounts {
+		t.Errorf("expected the default setting to parse as the defaults, got %+v, %v", accounts, err)
+	}
+	for _, value := range []string{`{"stock": "1400"}`, `{"inventory": " "}`, `["1400"]`} {
+		if _, err := ParseAccounts(value); err == nil {
+			t.Errorf("expected %s to be rejected", value)
+		}
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	if got := Schedule(map[string]interface{}{}); got != scheduler.ScheduleOff {
+		t.Errorf("expected the job off by default, got %q", got)
+	}
+	if got := Schedule(map[string]interface{}{SettingAutoExport: "xero_csv"}); got != monthlySchedule {
+		t.Errorf("expected the job monthly, got %q", got)
+	}
+}
+
+func TestPeriodEnd(t *testing.T) {
+	through := time.Date(2026, 9, 30, 15, 4, 0, 0, time.FixedZone("WIB", 7*60*60))
+	if got, want := PeriodEnd(through), time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+var day = time.Date(2026, 9, 30, 0, 0, 0, 0, time.UTC)
+
+func testJournals() []Journal {
+	return Journals([]models.AccountingPosting{
+		{Date: day, Kind: "purchase", Value: 120.5, Count: 2},
+		{Date: day, Kind: "sale", Value: -45, Count: 3},
+		{Date: day, Kind: "transfer", Value: 10, Count: 1},
+		{Date: day, Kind: "adjustment", Value: 0.001, Count: 1},
+		{Date: day, Kind: models.PostingRevaluation, Value: -12.345, Count: 1},
+	}, DefaultAccounts)
+}
+
+func TestJournals(t *testing.T) {
+	journals := testJournals()
+	if len(journals) != 3 {
+		t.Fatalf("expected transfers and postings worth nothing left out, got %+v", journals)
+	}
+
+	purchase := journals[0]
+	if purchase.Number != "RT-20260930-PUR" || purchase.Memo != "RTIMS purchases, 2 movements" {
+		t.Errorf("unexpected purchase journal %+v", purchase)
+	}
+	if purchase.Lines[0] != (Line{Account: "Inventory Asset", Debit: 120.5}) || purchase.Lines[1] != (Line{Account: "Goods Received Not Invoiced", Credit: 120.5}) {
+		t.Errorf("expected purchases to debit inventory, got %+v", purchase.Lines)
+	}
+	sale := journals[1]
+	if sale.Lines[0] != (Line{Account: "Cost of Goods Sold", Debit: 45}) || sale.Lines[1] != (Line{Account: "Inventory Asset", Credit: 45}) {
+		t.Errorf("expected sales to credit inventory, got %+v", sale.Lines)
+	}
+	if revaluation := journals[2]; revaluation.Lines[0].Debit != 12.35 || revaluation.Memo != "RTIMS revaluation, 1 price change" {
+		t.Errorf("unexpected revaluation journal %+v", revaluation)
+	}
+
+	if got := Debits(journals); got != 177.85 {
+		t.Errorf("expected debits of 177.85, got %v", got)
+	}
+}
+
+func TestRender(t *testing.T) {
+	journals := testJournals()[:1]
+	tests := []struct {
+		format models.AccountingFormat
+		want   string
+	}{
+		{models.AccountingQuickBooksCSV, "Journal No,Journal Date,Account,Debits,Credits,Description\n" +
+			"RT-20260930-PUR,09/30/2026,Inventory Asset,120.50,,\"RTIMS purchases, 2 movements\"\n" +
+			"RT-20260930-PUR,09/30/2026,Goods Received Not Invoiced,,120.50,\"RTIMS purchases, 2 movements\"\n"},
+		{models.AccountingQuickBooksIIF, "!TRNS\tTRNSTYPE\tDATE\tACCNT\tAMOUNT\tDOCNUM\tMEMO\n" +
+			"!SPL\tTRNSTYPE\tDATE\tACCNT\tAMOUNT\tDOCNUM\tMEMO\n" +
+			"!ENDTRNS\n" +
+			"TRNS\tGENERAL JOURNAL\t09/30/2026\tInventory Asset\t120.50\tRT-20260930-PUR\tRTIMS purchases, 2 movements\n" +
+			"SPL\tGENERAL JOURNAL\t09/30/2026\tGoods Received Not Invoiced\t-120.50\tRT-20260930-PUR\tRTIMS purchases, 2 movements\n" +
+			"ENDTRNS\n"},
+		{models.AccountingXeroCSV, "*Narration,*Date,Description,*AccountCode,*TaxRate,*Amount\n" +
+			"\"RT-20260930-PUR RTIMS purchases, 2 movements\",30/09/2026,\"RTIMS purchases, 2 movements\",Inventory Asset,Tax Exempt,120.50\n" +
+			"\"RT-20260930-PUR RTIMS purchases, 2 movements\",30/09/2026,\"RTIMS purchases, 2 movements\",Goods Received Not Invoiced,Tax Exempt,-120.50\n"},
+	}
+	for _, tt := range tests {
+		content, err := Render(tt.format, journals)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != tt.want {
+			t.Errorf("%s: expected\n%s\ngot\n%s", tt.format, tt.want, content)
+		}
+	}
+
+	if _, err := Render("sage", journals); err == nil || !strings.Contains(err.Error(), "unknown") {
+		t.Errorf("expected an unknown format to be rejected, got %v", err)
+	}
+}
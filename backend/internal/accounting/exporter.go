@@ -0,0 +1,128 @@
+package accounting
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/models"
+	"rtims-backend/internal/scheduler"
+
+	"github.com/google/uuid"
+)
+
+// ErrPeriodNotOver is returned when exporting through a day that has not
+// ended yet, as movements could still be added to it.
+var ErrPeriodNotOver = errors.New("the period has not ended")
+
+// Exporter exports the journals of organizations.
+type Exporter struct {
+	accountingService   *database.AccountingService
+	settingsService     *database.SettingsService
+	organizationService *database.OrganizationService
+}
+
+func NewExporter(db *sql.DB) *Exporter {
+	return &Exporter{
+		accountingService:   database.NewAccountingService(db),
+		settingsService:     database.NewSettingsService(db),
+		organizationService: database.NewOrganizationService(db),
+	}
+}
+
+// PeriodEnd returns the end of the period of an export through day: the
+// midnight, in UTC, that ends it.
+func PeriodEnd(through time.Time) time.Time {
+	return time.Date(through.Year(), through.Month(), through.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+}
+
+// Export exports the journal of an organization from its last export
+// through the given day, which must have ended, and locks the period.
+// createdBy is nil for the scheduled exports.
+func (e *Exporter) Export(ctx context.Context, organizationID uuid.UUID, format models.AccountingFormat, through time.Time,
+	createdBy *uuid.UUID) (*models.AccountingExport, error) {
+	periodEnd := PeriodEnd(through)
+	if periodEnd.After(time.Now()) {
+		return nil, ErrPeriodNotOver
+	}
+
+	settings, err := e.settingsService.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	value, _ := settings[SettingAccounts].(string)
+	accounts, err := ParseAccounts(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s setting: %w", SettingAccounts, err)
+	}
+
+	export := &models.AccountingExport{
+		OrganizationID: organizationID,
+		Format:         format,
+		PeriodEnd:      periodEnd,
+		Filename:       fmt.Sprintf("rtims-journal-%s.%s", through.Format("2006-01-02"), Extension(format)),
+		CreatedBy:      createdBy,
+	}
+	err = e.accountingService.CreateAccountingExport(ctx, export, func(export *models.AccountingExport, postings []models.AccountingPosting) error {
+		journals := Journals(postings, accounts)
+		content, err := Render(export.Format, journals)
+		if err != nil {
+			return err
+		}
+		export.Content = content
+		export.Journals = len(journals)
+		export.Debits = Debits(journals)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+// ExportMonthly exports the previous month of every active organization in
+// the format of SettingAutoExport. It is the accounting_export job.
+// Organizations already exported through the month are skipped.
+func (e *Exporter) ExportMonthly() error {
+	settings, err := e.settingsService.GetSettings()
+	if err != nil {
+		return err
+	}
+	format, _ := settings[SettingAutoExport].(string)
+	if Schedule(settings) == scheduler.ScheduleOff {
+		return nil // Turned off since the job was scheduled
+	}
+
+	ctx := context.Background()
+	organizations, err := e.organizationService.GetOrganizations(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	through := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, -1)
+	failed := 0
+	for _, organization := range organizations {
+		if !organization.IsActive {
+			continue
+		}
+		export, err := e.Export(ctx, organization.ID, models.AccountingFormat(format), through, nil)
+		if errors.Is(err, database.ErrPeriodExported) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Accounting export of %s failed: %v", organization.Name, err)
+			failed++
+			continue
+		}
+		log.Printf("Exported %d journals of %s through %s", export.Journals, organization.Name, through.Format("2006-01-02"))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d accounting exports failed", failed, len(organizations))
+	}
+	return nil
+}
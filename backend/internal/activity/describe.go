@@ -0,0 +1,89 @@
+// Package activity says the entries of an organization's activity feed in
+// sentences, such as "Ana received 50× SKU-123" or "Budi created category
+// Tools", in the reader's language.
+package activity
+
+import (
+	"fmt"
+	"strings"
+
+	"rtims-backend/internal/i18n"
+	"rtims-backend/internal/models"
+)
+
+// Describe returns the sentence for entry in language.
+func Describe(entry models.FeedEntry, language string) string {
+	user := entry.UserName
+	if user == "" {
+		user = i18n.T(language, "Someone")
+	}
+	t := func(message string, args ...interface{}) string {
+		return fmt.Sprintf(i18n.T(language, message), append([]interface{}{user}, args...)...)
+	}
+
+	switch entry.EntityType {
+	case models.FeedStockMovement:
+		return describeMovement(entry, t)
+	case models.FeedProduct:
+		switch entry.Action {
+		case models.ActionCreate:
+			return t("%s created product %s", entry.Name)
+		case models.ActionDelete:
+			return t("%s deleted product %s", entry.Name)
+		}
+		switch {
+		case entry.NewName != "":
+			return t("%s renamed product %s to %s", entry.Name, entry.NewName)
+		case entry.Category != "":
+			return t("%s moved %s to category %s", entry.Name, entry.Category)
+		case len(entry.ChangedFields) > 0:
+			fields := strings.ReplaceAll(strings.Join(entry.ChangedFields, ", "), "_", " ")
+			return t("%s changed the %s of %s", fields, entry.Name)
+		}
+		return t("%s updated product %s", entry.Name)
+	case models.FeedCategory:
+		switch entry.Action {
+		case models.ActionCreate:
+			return t("%s created category %s", entry.Name)
+		case models.ActionDelete:
+			return t("%s deleted category %s", entry.Name)
+		}
+		if entry.NewName != "" {
+			return t("%s renamed category %s to %s", entry.Name, entry.NewName)
+		}
+		return t("%s updated category %s", entry.Name)
+	}
+	return t("%s changed %s", entry.Name)
+}
+
+// describeMovement says what a stock movement did, by its reason and
+// direction.
+func describeMovement(entry models.FeedEntry, t func(string, ...interface{}) string) string {
+	quantity := entry.Change
+	if quantity < 0 {
+		quantity = -quantity
+	}
+
+	switch {
+	case entry.Reason == models.ReasonPurchase && entry.Change > 0:
+		return t("%s received %d× %s", quantity, entry.SKU)
+	case entry.Reason == models.ReasonSale && entry.Change < 0:
+		return t("%s sold %d× %s", quantity, entry.SKU)
+	case entry.Reason == models.ReasonReturn && entry.Change > 0:
+		return t("%s took back %d× %s as returned", quantity, entry.SKU)
+	case entry.Reason == models.ReasonDamage && entry.Change < 0:
+		return t("%s wrote off %d× %s as damaged", quantity, entry.SKU)
+	case entry.Reason == models.ReasonTransfer && entry.Change > 0:
+		return t("%s transferred in %d× %s", quantity, entry.SKU)
+	case entry.Reason == models.ReasonTransfer && entry.Change < 0:
+		return t("%s transferred out %d× %s", quantity, entry.SKU)
+	}
+	return t("%s adjusted the stock of %s by %+d", entry.SKU, entry.Change)
+}
+
+// DescribeAll sets the Message of each entry in language.
+func DescribeAll(entries []models.FeedEntry, language string) {
+	for i := range entries {
+		entries[i].Message = Describe(entries[i], language)
+	}
+}
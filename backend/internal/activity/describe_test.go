@@ -0,0 +1,46 @@
+package activity
+
+import (
+	"testing"
+
+	"rtims-backend/internal/models"
+)
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		entry models.FeedEntry
+		want  string
+	}{
+		{models.FeedEntry{EntityType: models.FeedStockMovement, UserName: "Ana", SKU: "SKU-123", Change: 50, Reason: models.ReasonPurchase},
+			"Ana received 50× SKU-123"},
+		{models.FeedEntry{EntityType: models.FeedStockMovement, UserName: "Ana", SKU: "SKU-123", Change: -2, Reason: models.ReasonSale},
+			"Ana sold 2× SKU-123"},
+		{models.FeedEntry{EntityType: models.FeedStockMovement, UserName: "Ana", SKU: "SKU-123", Change: -4, Reason: models.ReasonTransfer},
+			"Ana transferred out 4× SKU-123"},
+		{models.FeedEntry{EntityType: models.FeedStockMovement, UserName: "Ana", SKU: "SKU-123", Change: -3, Reason: models.ReasonAdjustment},
+			"Ana adjusted the stock of SKU-123 by -3"},
+		{models.FeedEntry{EntityType: models.FeedCategory, Action: models.ActionCreate, UserName: "Budi", Name: "Tools"},
+			"Budi created category Tools"},
+		{models.FeedEntry{EntityType: models.FeedCategory, Action: models.ActionUpdate, UserName: "Budi", Name: "Tools", NewName: "Power Tools"},
+			"Budi renamed category Tools to Power Tools"},
+		{models.FeedEntry{EntityType: models.FeedProduct, Action: models.ActionUpdate, UserName: "Budi", Name: "Drill", Category: "Tools"},
+			"Budi moved Drill to category Tools"},
+		{models.FeedEntry{EntityType: models.FeedProduct, Action: models.ActionUpdate, UserName: "Budi", Name: "Drill",
+			ChangedFields: []string{"minimum_threshold", "price"}},
+			"Budi changed the minimum threshold, price of Drill"},
+		// Entries of deleted users still read as a sentence
+		{models.FeedEntry{EntityType: models.FeedProduct, Action: models.ActionDelete, Name: "Drill"},
+			"Someone deleted product Drill"},
+	}
+
+	for _, tt := range tests {
+		if got := Describe(tt.entry, "en"); got != tt.want {
+			t.Errorf("Describe() = %q, want %q", got, tt.want)
+		}
+	}
+
+	entry := models.FeedEntry{EntityType: models.FeedCategory, Action: models.ActionCreate, UserName: "Budi", Name: "Tools"}
+	if got := Describe(entry, "id"); got != "Budi membuat kategori Tools" {
+		t.Errorf("expected the Indonesian sentence, got %q", got)
+	}
+}
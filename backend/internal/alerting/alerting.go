@@ -0,0 +1,257 @@
+// Package alerting forwards critical inventory events, such as a flagged
+// product running out or a failed sync or backup, to the on-call tools of
+// operations: Prometheus Alertmanager, PagerDuty and Opsgenie. The sinks
+// alerts go to and the routes choosing them are configured in settings.
+package alerting
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Settings keys that configure alerting.
+const (
+	// SettingSinks holds a JSON object of the sinks alerts can go to, by a
+	// name of lowercase letters, digits, hyphens and underscores, e.g.
+	// {"on-call": {"type": "pagerduty", "options": {"routing_key": "…"}}}.
+	SettingSinks = "alert_sinks"
+	// SettingRoutes holds a JSON object of the routes choosing the sinks of
+	// an alert, by name, e.g. {"stock-outs": {"events": ["stock_out"],
+	// "skus": ["WID-1"], "sinks": ["on-call"]}}. An alert goes to the sinks
+	// of every route it matches.
+	SettingRoutes = "alert_routes"
+)
+
+// Events alerts are raised for.
+const (
+	// EventStockOut is a product's stock reaching zero.
+	EventStockOut = "stock_out"
+	// EventSyncFailed is a sync with a store, trading partner, supplier or
+	// spreadsheet failing.
+	EventSyncFailed = "sync_failed"
+	// EventBackupFailed is a backup failing.
+	EventBackupFailed = "backup_failed"
+
+	// eventTest is the alert sent to check a sink, which no route matches.
+	eventTest = "test"
+)
+
+// Severities a route gives its alerts.
+const (
+	SeverityCritical = "critical"
+	SeverityError    = "error"
+	SeverityWarning  = "warning"
+)
+
+// ErrUnknownSink is returned for a sink that is not configured.
+var ErrUnknownSink = errors.New("alert sink is not configured")
+
+var name = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,99}$`)
+
+// Alert is one critical event. Alerts with the same Key are the same
+// problem raised again, which the sinks fold into the alert already open.
+type Alert struct {
+	Event   string
+	Summary string
+	// Source is what the alert is about: a product's SKU, a sync job or
+	// store, or the backups.
+	Source  string
+	Key     string
+	Details map[string]string
+	Time    time.Time
+}
+
+// StockOut is the alert of a product whose stock reached zero.
+func StockOut(event models.StockUpdatedEvent) Alert {
+	return Alert{
+		Event:   EventStockOut,
+		Summary: fmt.Sprintf("%s (%s) is out of stock", event.Name, event.SKU),
+		Source:  event.SKU,
+		Key:     "rtims/" + EventStockOut + "/" + event.ProductID.String(),
+		Details: map[string]string{
+			"product_id":      event.ProductID.String(),
+			"name":            event.Name,
+			"sku":             event.SKU,
+			"organization_id": event.OrganizationID.String(),
+		},
+		Time: time.Now(),
+	}
+}
+
+// SyncFailed is the alert of a failed sync, the source naming the job or
+// store that failed.
+func SyncFailed(source string, err error) Alert {
+	return Alert{
+		Event:   EventSyncFailed,
+		Summary: fmt.Sprintf("Sync %s failed: %v", source, err),
+		Source:  source,
+		Key:     "rtims/" + EventSyncFailed + "/" + source,
+		Details: map[string]string{"error": err.Error()},
+		Time:    time.Now(),
+	}
+}
+
+// BackupFailed is the alert of a failed backup. Every failure folds into
+// one alert, which stays open until somebody resolves it.
+func BackupFailed(id uuid.UUID, reason string) Alert {
+	return Alert{
+		Event:   EventBackupFailed,
+		Summary: fmt.Sprintf("Backup %s failed: %s", id, reason),
+		Source:  "backup",
+		Key:     "rtims/" + EventBackupFailed,
+		Details: map[string]string{"backup_id": id.String(), "error": reason},
+		Time:    time.Now(),
+	}
+}
+
+// SinkProfile is the profile of one sink: its type, one of alertmanager,
+// pagerduty or opsgenie, and the options of the type.
+type SinkProfile struct {
+	Name    string            `json:"-"`
+	Type    string            `json:"type"`
+	Options map[string]string `json:"options"`
+}
+
+// ParseSinks parses the SettingSinks value, checking that every sink's
+// type accepts its options.
+func ParseSinks(value string) (map[string]SinkProfile, error) {
+	sinks := map[string]SinkProfile{}
+	if strings.TrimSpace(value) == "" {
+		return sinks, nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&sinks); err != nil {
+		return nil, errors.New("must be an object mapping sink names to sink profiles")
+	}
+
+	names := make([]string, 0, len(sinks))
+	for sinkName := range sinks {
+		names = append(names, sinkName)
+	}
+	sort.Strings(names)
+
+	for _, sinkName := range names {
+		profile := sinks[sinkName]
+		profile.Name = sinkName
+		if !name.MatchString(sinkName) {
+			return nil, fmt.Errorf("%q is not a sink name of lowercase letters, digits, hyphens and underscores", sinkName)
+		}
+		if _, err := NewSink(profile); err != nil {
+			return nil, fmt.Errorf("%s: %w", sinkName, err)
+		}
+		sinks[sinkName] = profile
+	}
+	return sinks, nil
+}
+
+// Route sends the alerts of Events to Sinks, with Severity, critical unless
+// set. Stock outs match only the products whose SKU is in SKUs, when it is
+// set, so a route can page for the products flagged as critical alone.
+type Route struct {
+	Name     string   `json:"-"`
+	Events   []string `json:"events"`
+	SKUs     []string `json:"skus,omitempty"`
+	Sinks    []string `json:"sinks"`
+	Severity string   `json:"severity,omitempty"`
+}
+
+// ParseRoutes parses the SettingRoutes value. Sinks are checked when an
+// alert is sent, so routes and sinks can be changed in either order.
+func ParseRoutes(value string) (map[string]Route, error) {
+	routes := map[string]Route{}
+	if strings.TrimSpace(value) == "" {
+		return routes, nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&routes); err != nil {
+		return nil, errors.New("must be an object mapping route names to routes")
+	}
+
+	names := make([]string, 0, len(routes))
+	for routeName := range routes {
+		names = append(names, routeName)
+	}
+	sort.Strings(names)
+
+	for _, routeName := range names {
+		route := routes[routeName]
+		route.Name = routeName
+		if route.Severity == "" {
+			route.Severity = SeverityCritical
+		}
+		if !name.MatchString(routeName) {
+			return nil, fmt.Errorf("%q is not a route name of lowercase letters, digits, hyphens and underscores", routeName)
+		}
+		if err := route.check(); err != nil {
+			return nil, fmt.Errorf("%s: %w", routeName, err)
+		}
+		routes[routeName] = route
+	}
+	return routes, nil
+}
+
+func (r Route) check() error {
+	if len(r.Events) == 0 {
+		return errors.New("needs the events it routes")
+	}
+	for _, event := range r.Events {
+		switch event {
+		case EventStockOut, EventSyncFailed, EventBackupFailed:
+		default:
+			return fmt.Errorf("event %q must be stock_out, sync_failed or backup_failed", event)
+		}
+	}
+	if len(r.SKUs) > 0 && !r.routes(EventStockOut) {
+		return errors.New("skus only apply to stock_out")
+	}
+	if len(r.Sinks) == 0 {
+		return errors.New("needs the sinks it sends to")
+	}
+	for _, sink := range r.Sinks {
+		if !name.MatchString(sink) {
+			return fmt.Errorf("%q is not a sink name", sink)
+		}
+	}
+	switch r.Severity {
+	case SeverityCritical, SeverityError, SeverityWarning:
+	default:
+		return errors.New("severity must be critical, error or warning")
+	}
+	return nil
+}
+
+func (r Route) routes(event string) bool {
+	for _, e := range r.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches reports whether the route sends alert.
+func (r Route) Matches(alert Alert) bool {
+	if !r.routes(alert.Event) {
+		return false
+	}
+	if alert.Event != EventStockOut || len(r.SKUs) == 0 {
+		return true
+	}
+	for _, sku := range r.SKUs {
+		if sku == alert.Source {
+			return true
+		}
+	}
+	return false
+}
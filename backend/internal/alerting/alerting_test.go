@@ -0,0 +1,171 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestParseSinks(t *testing.T) {
+	sinks, err := ParseSinks(`{"on-call": {"type": "pagerduty", "options": {"routing_key": "R0UT1NGK3Y"}},
+		"am": {"type": "alertmanager", "options": {"url": "http://alertmanager:9093/"}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sinks["on-call"].Name != "on-call" || sinks["am"].Type != "alertmanager" {
+		t.Errorf("unexpected sinks %+v", sinks)
+	}
+
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"not an object", `["a"]`, "must be an object"},
+		{"name", `{"On Call": {"type": "pagerduty", "options": {"routing_key": "k"}}}`, "is not a sink name"},
+		{"type", `{"a": {"type": "slack", "options": {}}}`, "type must be alertmanager, pagerduty or opsgenie"},
+		{"alertmanager url", `{"a": {"type": "alertmanager", "options": {"url": "alertmanager:9093"}}}`, "url must be an http or https URL"},
+		{"alertmanager auth", `{"a": {"type": "alertmanager", "options": {"url": "http://am", "username": "rtims"}}}`, "set together"},
+		{"routing key", `{"a": {"type": "pagerduty", "options": {}}}`, "needs the routing_key"},
+		{"api key", `{"a": {"type": "opsgenie", "options": {"region": "eu"}}}`, "needs the api_key"},
+		{"region", `{"a": {"type": "opsgenie", "options": {"api_key": "k", "region": "apac"}}}`, "region must be us or eu"},
+	}
+	for _, tt := range tests {
+		if _, err := ParseSinks(tt.value); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestParseRoutes(t *testing.T) {
+	routes, err := ParseRoutes(`{"flagged": {"events": ["stock_out"], "skus": ["WID-1"], "sinks": ["on-call"]}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if route := routes["flagged"]; route.Name != "flagged" || route.Severity != SeverityCritical {
+		t.Errorf("unexpected route %+v", route)
+	}
+
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"no events", `{"a": {"sinks": ["s"]}}`, "needs the events"},
+		{"event", `{"a": {"events": ["low_stock"], "sinks": ["s"]}}`, "must be stock_out, sync_failed or backup_failed"},
+		{"skus", `{"a": {"events": ["backup_failed"], "skus": ["WID-1"], "sinks": ["s"]}}`, "skus only apply to stock_out"},
+		{"no sinks", `{"a": {"events": ["sync_failed"]}}`, "needs the sinks"},
+		{"severity", `{"a": {"events": ["sync_failed"], "sinks": ["s"], "severity": "info"}}`, "severity must be"},
+		{"unknown field", `{"a": {"events": ["sync_failed"], "sinks": ["s"], "receiver": "s"}}`, "must be an object"},
+	}
+	for _, tt := range tests {
+		if _, err := ParseRoutes(tt.value); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestRouteMatches(t *testing.T) {
+	flagged := Route{Events: []string{EventStockOut, EventBackupFailed}, SKUs: []string{"WID-1"}}
+	everything := Route{Events: []string{EventStockOut}}
+
+	widget := StockOut(models.StockUpdatedEvent{ProductID: uuid.New(), Name: "Widget", SKU: "WID-1"})
+	gadget := StockOut(models.StockUpdatedEvent{ProductID: uuid.New(), Name: "Gadget", SKU: "GAD-1"})
+	backup := BackupFailed(uuid.New(), "pg_dump exited with status 1")
+
+	tests := []struct {
+		route Route
+		alert Alert
+		want  bool
+	}{
+		{flagged, widget, true},
+		{flagged, gadget, false},
+		{flagged, backup, true},
+		{everything, gadget, true},
+		{everything, SyncFailed("edi_846", errors.New("connection refused")), false},
+	}
+	for _, tt := range tests {
+		if got := tt.route.Matches(tt.alert); got != tt.want {
+			t.Errorf("route %v matching %s alert of %s = %v, want %v", tt.route.Events, tt.alert.Event, tt.alert.Source, got, tt.want)
+		}
+	}
+}
+
+func TestSinks(t *testing.T) {
+	var path, auth string
+	var body map[string]interface{}
+	status := http.StatusAccepted
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.Path, r.Header.Get("Authorization")
+		raw, _ := io.ReadAll(r.Body)
+		body = nil
+		if strings.HasPrefix(string(raw), "[") {
+			var alerts []map[string]interface{}
+			json.Unmarshal(raw, &alerts)
+			body = alerts[0]
+		} else {
+			json.Unmarshal(raw, &body)
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, `{"status": "success"}`)
+	}))
+	defer server.Close()
+
+	productID := uuid.New()
+	alert := StockOut(models.StockUpdatedEvent{ProductID: productID, Name: "Widget", SKU: "WID-1"})
+	send := func(sinkType string, options map[string]string) error {
+		sink, err := NewSink(SinkProfile{Type: sinkType, Options: options})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sink.Send(context.Background(), alert, SeverityError)
+	}
+
+	if err := send("pagerduty", map[string]string{"routing_key": "R0UT1NGK3Y", "url": server.URL + "/v2/enqueue"}); err != nil {
+		t.Fatal(err)
+	}
+	payload, _ := body["payload"].(map[string]interface{})
+	if path != "/v2/enqueue" || body["routing_key"] != "R0UT1NGK3Y" || body["dedup_key"] != "rtims/stock_out/"+productID.String() ||
+		payload["severity"] != "error" || payload["summary"] != "Widget (WID-1) is out of stock" {
+		t.Errorf("unexpected PagerDuty event %s %v", path, body)
+	}
+
+	if err := send("opsgenie", map[string]string{"api_key": "k3y", "url": server.URL}); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/v2/alerts" || auth != "GenieKey k3y" || body["alias"] != alert.Key || body["priority"] != "P2" || body["entity"] != "WID-1" {
+		t.Errorf("unexpected Opsgenie alert %s %s %v", path, auth, body)
+	}
+
+	if err := send("alertmanager", map[string]string{"url": server.URL + "/", "username": "rtims", "password": "s3cret"}); err != nil {
+		t.Fatal(err)
+	}
+	labels, _ := body["labels"].(map[string]interface{})
+	if path != "/api/v2/alerts" || !strings.HasPrefix(auth, "Basic ") || labels["alertname"] != "RTIMSStockOut" || labels["severity"] != "error" {
+		t.Errorf("unexpected Alertmanager alert %s %s %v", path, auth, body)
+	}
+
+	status = http.StatusBadRequest
+	var apiErr *APIError
+	if err := send("pagerduty", map[string]string{"routing_key": "wrong", "url": server.URL}); !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadRequest {
+		t.Errorf("expected a 400 from the sink, got %v", err)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := truncate("Gudang Surabaya", 6); got != "Gudan…" {
+		t.Errorf("unexpected %q", got)
+	}
+	if got := truncate("Gudang", 6); got != "Gudang" {
+		t.Errorf("unexpected %q", got)
+	}
+}
@@ -0,0 +1,124 @@
+package alerting
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"rtims-backend/internal/database"
+)
+
+// sendTimeout bounds sending one alert to all its sinks.
+const sendTimeout = 30 * time.Second
+
+// rank orders the severities, so a sink two routes send an alert to gets
+// it with the more severe.
+var rank = map[string]int{SeverityWarning: 1, SeverityError: 2, SeverityCritical: 3}
+
+// Router sends alerts to the sinks the routes in settings choose.
+type Router struct {
+	settingsService *database.SettingsService
+}
+
+func NewRouter(db *sql.DB) *Router {
+	return &Router{settingsService: database.NewSettingsService(db)}
+}
+
+// config returns the configured sinks and routes.
+func (r *Router) config() (map[string]SinkProfile, map[string]Route, error) {
+	settings, err := r.settingsService.GetSettings()
+	if err != nil {
+		return nil, nil, err
+	}
+	value, _ := settings[SettingSinks].(string)
+	sinks, err := ParseSinks(value)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid %s setting: %w", SettingSinks, err)
+	}
+	value, _ = settings[SettingRoutes].(string)
+	routes, err := ParseRoutes(value)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid %s setting: %w", SettingRoutes, err)
+	}
+	return sinks, routes, nil
+}
+
+// Fire sends alert to the sinks of every route it matches, once each. A
+// sink that is not configured or fails is logged without stopping the
+// others.
+func (r *Router) Fire(alert Alert) {
+	sinks, routes, err := r.config()
+	if err != nil {
+		log.Printf("Failed to route %s alert %s: %v", alert.Event, alert.Key, err)
+		return
+	}
+
+	severities := map[string]string{}
+	for _, route := range routes {
+		if !route.Matches(alert) {
+			continue
+		}
+		for _, sink := range route.Sinks {
+			if rank[route.Severity] > rank[severities[sink]] {
+				severities[sink] = route.Severity
+			}
+		}
+	}
+	names := make([]string, 0, len(severities))
+	for name := range severities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	for _, name := range names {
+		profile, ok := sinks[name]
+		if !ok {
+			log.Printf("Failed to send %s alert %s to sink %s: %v", alert.Event, alert.Key, name, ErrUnknownSink)
+			continue
+		}
+		// Profiles were checked when parsed, so this cannot fail
+		sink, _ := NewSink(profile)
+		if err := sink.Send(ctx, alert, severities[name]); err != nil {
+			log.Printf("Failed to send %s alert %s to sink %s: %v", alert.Event, alert.Key, name, err)
+		}
+	}
+}
+
+// Test raises a warning in the sink called name, whatever the routes, to
+// check that it is set up.
+func (r *Router) Test(ctx context.Context, name string) error {
+	sinks, _, err := r.config()
+	if err != nil {
+		return err
+	}
+	profile, ok := sinks[name]
+	if !ok {
+		return ErrUnknownSink
+	}
+	sink, _ := NewSink(profile)
+	return sink.Send(ctx, Alert{
+		Event:   eventTest,
+		Summary: "Test alert from RTIMS",
+		Source:  "rtims",
+		Key:     "rtims/" + eventTest + "/" + name,
+		Details: map[string]string{"sink": name},
+		Time:    time.Now(),
+	}, SeverityWarning)
+}
+
+// Watch returns run, raising a sync_failed alert for source whenever it
+// fails.
+func (r *Router) Watch(source string, run func() error) func() error {
+	return func() error {
+		err := run()
+		if err != nil {
+			r.Fire(SyncFailed(source, err))
+		}
+		return err
+	}
+}
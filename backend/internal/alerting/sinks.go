@@ -0,0 +1,243 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+	defaultOpsgenieURL  = "https://api.opsgenie.com"
+	opsgenieEUURL       = "https://api.eu.opsgenie.com"
+
+	// maxErrorBody is how much of an error response is kept in its error.
+	maxErrorBody = 512
+	// Longest summary PagerDuty and message Opsgenie accept.
+	maxPagerDutySummary = 1024
+	maxOpsgenieMessage  = 130
+)
+
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+// Sink is an on-call tool alerts are raised in.
+type Sink interface {
+	// Send raises alert with severity, or adds to the open alert with its
+	// Key.
+	Send(ctx context.Context, alert Alert, severity string) error
+}
+
+// APIError is a response of a sink outside 2xx.
+type APIError struct {
+	Status int
+	Body   string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("alert sink returned status %d: %s", e.Status, e.Body)
+}
+
+// NewSink returns the sink of a profile, checking its options:
+//
+//   - alertmanager needs the url of Alertmanager and takes a username and
+//     password for basic auth.
+//   - pagerduty needs the routing_key of an Events API v2 integration.
+//   - opsgenie needs the api_key of an API integration, and takes region
+//     eu for accounts in the EU.
+//
+// pagerduty and opsgenie take a url for a proxy in place of the service.
+func NewSink(profile SinkProfile) (Sink, error) {
+	options := profile.Options
+	switch profile.Type {
+	case "alertmanager":
+		if err := checkURL(options["url"], true); err != nil {
+			return nil, err
+		}
+		if (options["username"] == "") != (options["password"] == "") {
+			return nil, errors.New("username and password must be set together")
+		}
+		return &alertmanagerSink{url: strings.TrimRight(options["url"], "/"), username: options["username"], password: options["password"]}, nil
+	case "pagerduty":
+		if options["routing_key"] == "" {
+			return nil, errors.New("needs the routing_key of an Events API v2 integration")
+		}
+		if err := checkURL(options["url"], false); err != nil {
+			return nil, err
+		}
+		sink := &pagerDutySink{url: defaultPagerDutyURL, routingKey: options["routing_key"]}
+		if options["url"] != "" {
+			sink.url = options["url"]
+		}
+		return sink, nil
+	case "opsgenie":
+		if options["api_key"] == "" {
+			return nil, errors.New("needs the api_key of an API integration")
+		}
+		if err := checkURL(options["url"], false); err != nil {
+			return nil, err
+		}
+		sink := &opsgenieSink{url: defaultOpsgenieURL, apiKey: options["api_key"]}
+		switch options["region"] {
+		case "", "us":
+		case "eu":
+			sink.url = opsgenieEUURL
+		default:
+			return nil, errors.New("region must be us or eu")
+		}
+		if options["url"] != "" {
+			sink.url = strings.TrimRight(options["url"], "/")
+		}
+		return sink, nil
+	}
+	return nil, errors.New("type must be alertmanager, pagerduty or opsgenie")
+}
+
+func checkURL(value string, required bool) error {
+	if value == "" && !required {
+		return nil
+	}
+	if parsed, err := url.Parse(value); err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return errors.New("url must be an http or https URL")
+	}
+	return nil
+}
+
+// alertmanagerSink posts alerts to Alertmanager's API, labelled so that
+// alerts with the same Key group together. Alertmanager resolves an alert
+// that is not raised again within its resolve_timeout.
+type alertmanagerSink struct {
+	url      string
+	username string
+	password string
+}
+
+// alertNames are the alertname labels of the events.
+var alertNames = map[string]string{
+	EventStockOut:     "RTIMSStockOut",
+	EventSyncFailed:   "RTIMSSyncFailed",
+	EventBackupFailed: "RTIMSBackupFailed",
+	eventTest:         "RTIMSTest",
+}
+
+func (s *alertmanagerSink) Send(ctx context.Context, alert Alert, severity string) error {
+	annotations := map[string]string{"summary": alert.Summary}
+	for key, value := range alert.Details {
+		annotations[key] = value
+	}
+	body := []map[string]interface{}{{
+		"labels": map[string]string{
+			"alertname": alertNames[alert.Event],
+			"severity":  severity,
+			"service":   "rtims",
+			"source":    alert.Source,
+			"key":       alert.Key,
+		},
+		"annotations": annotations,
+		"startsAt":    alert.Time.UTC().Format(time.RFC3339),
+	}}
+	return post(ctx, s.url+"/api/v2/alerts", body, func(req *http.Request) {
+		if s.username != "" {
+			req.SetBasicAuth(s.username, s.password)
+		}
+	})
+}
+
+// pagerDutySink triggers events of an Events API v2 integration, deduped
+// by the alert's Key.
+type pagerDutySink struct {
+	url        string
+	routingKey string
+}
+
+func (s *pagerDutySink) Send(ctx context.Context, alert Alert, severity string) error {
+	body := map[string]interface{}{
+		"routing_key":  s.routingKey,
+		"event_action": "trigger",
+		"dedup_key":    alert.Key,
+		"payload": map[string]interface{}{
+			"summary":        truncate(alert.Summary, maxPagerDutySummary),
+			"source":         alert.Source,
+			"severity":       severity,
+			"timestamp":      alert.Time.UTC().Format(time.RFC3339),
+			"component":      "rtims",
+			"class":          alert.Event,
+			"custom_details": alert.Details,
+		},
+	}
+	return post(ctx, s.url, body, nil)
+}
+
+// opsgenieSink creates alerts of an API integration, deduped by the
+// alert's Key as their alias.
+type opsgenieSink struct {
+	url    string
+	apiKey string
+}
+
+// priorities are the Opsgenie priorities of the severities.
+var priorities = map[string]string{
+	SeverityCritical: "P1",
+	SeverityError:    "P2",
+	SeverityWarning:  "P3",
+}
+
+func (s *opsgenieSink) Send(ctx context.Context, alert Alert, severity string) error {
+	body := map[string]interface{}{
+		"message":     truncate(alert.Summary, maxOpsgenieMessage),
+		"alias":       alert.Key,
+		"description": alert.Summary,
+		"entity":      alert.Source,
+		"source":      "RTIMS",
+		"priority":    priorities[severity],
+		"tags":        []string{alert.Event},
+		"details":     alert.Details,
+	}
+	return post(ctx, s.url+"/v2/alerts", body, func(req *http.Request) {
+		req.Header.Set("Authorization", "GenieKey "+s.apiKey)
+	})
+}
+
+// post sends body as JSON, after authorize, if it is given, has set the
+// request's credentials.
+func post(ctx context.Context, rawURL string, body interface{}, authorize func(*http.Request)) error {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, bytes.NewReader(encoded))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if authorize != nil {
+		authorize(req)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return &APIError{Status: resp.StatusCode, Body: string(message)}
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// truncate shortens s to at most max characters.
+func truncate(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max-1]) + "…"
+}
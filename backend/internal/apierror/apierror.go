@@ -0,0 +1,368 @@
+// Package apierror defines the error responses of the API. Every error is
+// answered with the same envelope:
+//
+//	{"error": "Product not found", "code": "product_not_found", "request_id": "..."}
+//
+// error is a message for people, translated per Accept-Language, and may
+// change; code is stable, for clients to act on. details, when present,
+// lists the request fields that failed validation.
+package apierror
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/i18n"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/lib/pq"
+)
+
+// Code identifies an error for clients. Codes are never renamed.
+type Code string
+
+const (
+	CodeInvalidRequest     Code = "invalid_request"
+	CodeInvalidCursor      Code = "invalid_cursor"
+	CodeValidationFailed   Code = "validation_failed"
+	CodeUnauthenticated    Code = "unauthenticated"
+	CodeInvalidToken       Code = "invalid_token"
+	CodeTokenExpired       Code = "token_expired"
+	CodeInvalidCredentials Code = "invalid_credentials"
+	CodeAccountDisabled    Code = "account_disabled"
+	CodeForbidden          Code = "forbidden"
+	CodeNotFound           Code = "not_found"
+	CodeConflict           Code = "conflict"
+	CodeRateLimited        Code = "rate_limited"
+	CodeUnavailable        Code = "service_unavailable"
+	CodeTimeout            Code = "timeout"
+	CodeInternal           Code = "internal_error"
+
+	CodeProductNotFound       Code = "product_not_found"
+	CodeMovementNotFound      Code = "stock_movement_not_found"
+	CodeDuplicateSKU          Code = "duplicate_sku"
+	CodeInsufficientStock     Code = "insufficient_stock"
+	CodeUserNotFound          Code = "user_not_found"
+	CodeDuplicateEmail        Code = "duplicate_email"
+	CodeCategoryNotFound      Code = "category_not_found"
+	CodeDuplicateCategory     Code = "duplicate_category"
+	CodeCategoryInUse         Code = "category_in_use"
+	CodeNotificationNotFound  Code = "notification_not_found"
+	CodeAuditLogNotFound      Code = "audit_log_not_found"
+	CodeTemplateNotFound      Code = "notification_template_not_found"
+	CodeReportNotFound        Code = "report_not_found"
+	CodeReportNotReady        Code = "report_not_ready"
+	CodeScheduleNotFound      Code = "report_schedule_not_found"
+	CodeReportQueueFull       Code = "report_queue_full"
+	CodeOrganizationNotFound  Code = "organization_not_found"
+	CodeDuplicateOrganization Code = "duplicate_organization"
+	CodeOrganizationInactive  Code = "organization_inactive"
+	CodeBackupInProgress      Code = "backup_in_progress"
+	CodeBackupNotFound        Code = "backup_not_found"
+	CodeBackupNotReady        Code = "backup_not_ready"
+	CodeRevisionNotFound      Code = "settings_revision_not_found"
+	CodeWebhookNotFound       Code = "webhook_not_found"
+	CodeShopifyStoreNotFound  Code = "shopify_store_not_found"
+	CodeVariantNotFound       Code = "shopify_variant_not_found"
+	CodeShopifyAccount        Code = "shopify_account_unavailable"
+	CodeShopifyUnavailable    Code = "shopify_unavailable"
+	CodeWooStoreNotFound      Code = "woocommerce_store_not_found"
+	CodeWooProductNotFound    Code = "woocommerce_product_not_found"
+	CodeWooConflictNotFound   Code = "woocommerce_conflict_not_found"
+	CodeWooConflictResolved   Code = "woocommerce_conflict_resolved"
+	CodeWooStockNotTracked    Code = "woocommerce_stock_not_tracked"
+	CodeWooAccount            Code = "woocommerce_account_unavailable"
+	CodeWooUnavailable        Code = "woocommerce_unavailable"
+	CodeExportNotFound        Code = "accounting_export_not_found"
+	CodePeriodExported        Code = "accounting_period_exported"
+	CodePeriodNotOver         Code = "accounting_period_not_over"
+	CodePeriodLocked          Code = "accounting_period_locked"
+	CodeEDIPartnerNotFound    Code = "edi_partner_not_found"
+	CodeEDIDocumentNotFound   Code = "edi_document_not_found"
+	CodeEDIOrganization       Code = "edi_organization_unavailable"
+	CodeIngestSupplierMissing Code = "ingest_supplier_not_found"
+	CodeIngestFileNotFound    Code = "ingest_file_not_found"
+	CodeIngestAccount         Code = "ingest_account_unavailable"
+	CodeTelegramNotConfigured Code = "telegram_not_configured"
+	CodeTelegramUnavailable   Code = "telegram_unavailable"
+	CodeTelegramChatNotFound  Code = "telegram_chat_not_found"
+	CodeProductImageNotFound  Code = "product_image_not_found"
+	CodeAttachmentNotFound    Code = "attachment_not_found"
+	CodeFileLinkInvalid       Code = "file_link_invalid"
+	CodeSearchDisabled        Code = "search_disabled"
+	CodeReindexRunning        Code = "search_reindex_running"
+	CodeSheetsExportNotFound  Code = "sheets_export_not_found"
+	CodeSheetsNotConfigured   Code = "sheets_not_configured"
+	CodeSheetsAccount         Code = "sheets_account_unavailable"
+	CodeSheetsUnavailable     Code = "sheets_unavailable"
+	CodeAlertSinkNotFound     Code = "alert_sink_not_found"
+	CodeAlertSinkUnavailable  Code = "alert_sink_unavailable"
+	CodeCalendarEventNotFound Code = "calendar_event_not_found"
+	CodeCalendarFeedInvalid   Code = "calendar_feed_invalid"
+	CodeCommentNotFound       Code = "comment_not_found"
+	CodeImportNotFound        Code = "movement_import_not_found"
+	CodeAlreadyImported       Code = "movements_already_imported"
+	CodeImportQueueFull       Code = "movement_import_queue_full"
+	CodeDeletionPending       Code = "deletion_pending"
+	CodeNoPendingDeletion     Code = "no_pending_deletion"
+)
+
+// Error is an error response. Its cause, if any, is logged for server
+// errors but never sent to the client.
+type Error struct {
+	Status  int
+	Code    Code
+	Message string
+	Details interface{}
+
+	cause error
+}
+
+// New returns an error answered with status, code and message.
+func New(status int, code Code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return e.Message + ": " + e.cause.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Wrap returns a copy of e caused by err.
+func (e *Error) Wrap(err error) *Error {
+	wrapped := *e
+	wrapped.cause = err
+	return &wrapped
+}
+
+// WithDetails returns a copy of e carrying details.
+func (e *Error) WithDetails(details interface{}) *Error {
+	detailed := *e
+	detailed.Details = details
+	return &detailed
+}
+
+// Errors shared by several handlers.
+var (
+	ErrUnauthenticated    = New(http.StatusUnauthorized, CodeUnauthenticated, "User not authenticated")
+	ErrForbidden          = New(http.StatusForbidden, CodeForbidden, "You do not have permission to do this")
+	ErrNotFound           = New(http.StatusNotFound, CodeNotFound, "Not found")
+	ErrInvalidCredentials = New(http.StatusUnauthorized, CodeInvalidCredentials, "Invalid credentials")
+	ErrAccountDisabled    = New(http.StatusUnauthorized, CodeAccountDisabled, "Account is deactivated")
+	ErrInternal           = New(http.StatusInternalServerError, CodeInternal, "Internal server error")
+
+	ErrProductNotFound       = New(http.StatusNotFound, CodeProductNotFound, "Product not found")
+	ErrMovementNotFound      = New(http.StatusNotFound, CodeMovementNotFound, "Stock movement not found")
+	ErrInsufficientStock     = New(http.StatusConflict, CodeInsufficientStock, "Stock cannot go below zero")
+	ErrDuplicateSKU          = New(http.StatusConflict, CodeDuplicateSKU, "A product with this SKU already exists")
+	ErrUserNotFound          = New(http.StatusNotFound, CodeUserNotFound, "User not found")
+	ErrDuplicateEmail        = New(http.StatusConflict, CodeDuplicateEmail, "User with this email already exists")
+	ErrCategoryNotFound      = New(http.StatusNotFound, CodeCategoryNotFound, "Category not found")
+	ErrDuplicateCategory     = New(http.StatusConflict, CodeDuplicateCategory, "A category with this name already exists")
+	ErrNotificationNotFound  = New(http.StatusNotFound, CodeNotificationNotFound, "Notification not found")
+	ErrTemplateNotFound      = New(http.StatusNotFound, CodeTemplateNotFound, "Notification template not found")
+	ErrAuditLogNotFound      = New(http.StatusNotFound, CodeAuditLogNotFound, "Audit log not found")
+	ErrReportNotFound        = New(http.StatusNotFound, CodeReportNotFound, "Report not found")
+	ErrScheduleNotFound      = New(http.StatusNotFound, CodeScheduleNotFound, "Report schedule not found")
+	ErrOrganizationNotFound  = New(http.StatusNotFound, CodeOrganizationNotFound, "Organization not found")
+	ErrDuplicateOrganization = New(http.StatusConflict, CodeDuplicateOrganization, "An organization with this slug already exists")
+	ErrOrganizationInactive  = New(http.StatusForbidden, CodeOrganizationInactive, "Organization is deactivated")
+	ErrBackupInProgress      = New(http.StatusConflict, CodeBackupInProgress, "A backup is already running")
+	ErrBackupNotFound        = New(http.StatusNotFound, CodeBackupNotFound, "Backup not found")
+	ErrRevisionNotFound      = New(http.StatusNotFound, CodeRevisionNotFound, "Settings revision not found")
+	ErrWebhookNotFound       = New(http.StatusNotFound, CodeWebhookNotFound, "Webhook not found")
+	ErrShopifyStoreNotFound  = New(http.StatusNotFound, CodeShopifyStoreNotFound, "Shopify store not configured")
+	ErrVariantNotFound       = New(http.StatusNotFound, CodeVariantNotFound, "Shopify variant not found")
+	ErrShopifyAccount        = New(http.StatusConflict, CodeShopifyAccount, "The account of the Shopify store is missing or deactivated")
+	ErrShopifyUnavailable    = New(http.StatusBadGateway, CodeShopifyUnavailable, "Shopify could not be reached or rejected the request")
+	ErrWooStoreNotFound      = New(http.StatusNotFound, CodeWooStoreNotFound, "WooCommerce store not configured")
+	ErrWooProductNotFound    = New(http.StatusNotFound, CodeWooProductNotFound, "WooCommerce product not found")
+	ErrWooConflictNotFound   = New(http.StatusNotFound, CodeWooConflictNotFound, "WooCommerce conflict not found")
+	ErrWooConflictResolved   = New(http.StatusConflict, CodeWooConflictResolved, "WooCommerce conflict is already resolved")
+	ErrWooStockNotTracked    = New(http.StatusConflict, CodeWooStockNotTracked, "The WooCommerce product no longer tracks its stock")
+	ErrWooAccount            = New(http.StatusConflict, CodeWooAccount, "The account of the WooCommerce store is missing or deactivated")
+	ErrWooUnavailable        = New(http.StatusBadGateway, CodeWooUnavailable, "WooCommerce could not be reached or rejected the request")
+	ErrExportNotFound        = New(http.StatusNotFound, CodeExportNotFound, "Accounting export not found")
+	ErrPeriodExported        = New(http.StatusConflict, CodePeriodExported, "This period has already been exported")
+	ErrPeriodNotOver         = New(http.StatusBadRequest, CodePeriodNotOver, "Only days that have ended can be exported")
+	ErrPeriodLocked          = New(http.StatusConflict, CodePeriodLocked, "Stock movements in a period exported to accounting cannot be changed")
+	ErrEDIPartnerNotFound    = New(http.StatusNotFound, CodeEDIPartnerNotFound, "EDI partner not configured")
+	ErrEDIDocumentNotFound   = New(http.StatusNotFound, CodeEDIDocumentNotFound, "EDI document not found")
+	ErrEDIOrganization       = New(http.StatusConflict, CodeEDIOrganization, "The organization of the EDI partner is missing or deactivated")
+	ErrIngestSupplierMissing = New(http.StatusNotFound, CodeIngestSupplierMissing, "Supplier not configured")
+	ErrIngestFileNotFound    = New(http.StatusNotFound, CodeIngestFileNotFound, "Supplier file not found")
+	ErrIngestAccount         = New(http.StatusConflict, CodeIngestAccount, "The account of the supplier is missing or deactivated")
+	ErrTelegramNotConfigured = New(http.StatusConflict, CodeTelegramNotConfigured, "The Telegram bot token, webhook secret or public URL is not set")
+	ErrTelegramUnavailable   = New(http.StatusBadGateway, CodeTelegramUnavailable, "Telegram could not be reached or rejected the request")
+	ErrTelegramChatNotFound  = New(http.StatusNotFound, CodeTelegramChatNotFound, "Telegram chat not linked")
+	ErrProductImageNotFound  = New(http.StatusNotFound, CodeProductImageNotFound, "Product has no image")
+	ErrAttachmentNotFound    = New(http.StatusNotFound, CodeAttachmentNotFound, "Attachment not found")
+	ErrFileLinkInvalid       = New(http.StatusForbidden, CodeFileLinkInvalid, "File link is invalid or has expired")
+	ErrSearchDisabled        = New(http.StatusConflict, CodeSearchDisabled, "Search indexing is not enabled")
+	ErrReindexRunning        = New(http.StatusConflict, CodeReindexRunning, "A search reindex is already running")
+	ErrSheetsExportNotFound  = New(http.StatusNotFound, CodeSheetsExportNotFound, "Sheets export not configured")
+	ErrSheetsNotConfigured   = New(http.StatusConflict, CodeSheetsNotConfigured, "The Google service account is not set")
+	ErrSheetsAccount         = New(http.StatusConflict, CodeSheetsAccount, "The account of the Sheets export is missing, deactivated or may not run its report")
+	ErrSheetsUnavailable     = New(http.StatusBadGateway, CodeSheetsUnavailable, "Google Sheets could not be reached or rejected the request")
+	ErrAlertSinkNotFound     = New(http.StatusNotFound, CodeAlertSinkNotFound, "Alert sink not configured")
+	ErrAlertSinkUnavailable  = New(http.StatusBadGateway, CodeAlertSinkUnavailable, "The alert sink could not be reached or rejected the alert")
+	ErrCalendarEventNotFound = New(http.StatusNotFound, CodeCalendarEventNotFound, "Calendar event not found")
+	ErrCalendarFeedInvalid   = New(http.StatusForbidden, CodeCalendarFeedInvalid, "Calendar feed link is invalid")
+	ErrCommentNotFound       = New(http.StatusNotFound, CodeCommentNotFound, "Comment not found")
+	ErrImportNotFound        = New(http.StatusNotFound, CodeImportNotFound, "Stock movement import not found")
+	ErrAlreadyImported       = New(http.StatusConflict, CodeAlreadyImported, "This file has already been imported")
+	ErrDeletionPending       = New(http.StatusConflict, CodeDeletionPending, "This record is already being deleted")
+	ErrNoPendingDeletion     = New(http.StatusNotFound, CodeNoPendingDeletion, "No deletion is pending for this record")
+)
+
+// uniqueViolations maps the unique constraints clients can run into to the
+// error for them.
+var uniqueViolations = map[string]*Error{
+	"products_organization_sku_key":    ErrDuplicateSKU,
+	"users_email_key":                  ErrDuplicateEmail,
+	"categories_organization_name_key": ErrDuplicateCategory,
+	"organizations_slug_key":           ErrDuplicateOrganization,
+	"backups_one_active":               ErrBackupInProgress,
+	"stock_movement_imports_content":   ErrAlreadyImported,
+	"pending_deletions_record_key":     ErrDeletionPending,
+}
+
+// BadRequest returns a 400 with message.
+func BadRequest(message string) *Error {
+	return New(http.StatusBadRequest, CodeInvalidRequest, message)
+}
+
+// Invalid returns the 400 for a request that failed to bind or validate.
+// err's message is sent, so use it for errors written for clients, such as
+// binding errors and the validation errors of the reports package.
+func Invalid(err error) *Error {
+	var validationErrs validator.ValidationErrors
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &validationErrs):
+		return failedValidation(validationErrs).Wrap(err)
+	case errors.As(err, &syntaxErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return BadRequest("Request body is not valid JSON").Wrap(err)
+	case errors.As(err, &typeErr):
+		return BadRequest(fmt.Sprintf("%s must be a %s", typeErr.Field, typeErr.Type)).Wrap(err)
+	}
+	return BadRequest(err.Error()).Wrap(err)
+}
+
+// FieldError is one entry of the details of a validation_failed error.
+type FieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+	Param string `json:"param,omitempty"`
+}
+
+func failedValidation(errs validator.ValidationErrors) *Error {
+	fields := make([]FieldError, 0, len(errs))
+	names := make([]string, 0, len(errs))
+	for _, fieldErr := range errs {
+		fields = append(fields, FieldError{Field: fieldErr.Field(), Rule: fieldErr.Tag(), Param: fieldErr.Param()})
+		names = append(names, fieldErr.Field())
+	}
+	message := "Invalid value for " + strings.Join(names, ", ")
+	return New(http.StatusBadRequest, CodeValidationFailed, message).WithDetails(fields)
+}
+
+// From maps err to its response. Errors this package, the database package
+// and Postgres define get their own status and code; anything else is a 500
+// that does not reveal err.
+func From(err error) *Error {
+	var apiErr *Error
+	var pqErr *pq.Error
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr
+	case errors.Is(err, database.ErrNotFound), errors.Is(err, sql.ErrNoRows):
+		return ErrNotFound.Wrap(err)
+	case errors.Is(err, database.ErrNoUpdates):
+		return BadRequest("No valid updates provided").Wrap(err)
+	case errors.Is(err, database.ErrInvalidCursor):
+		return New(http.StatusBadRequest, CodeInvalidCursor, "Invalid cursor, start again from the first page").Wrap(err)
+	case errors.Is(err, database.ErrInsufficientStock):
+		return ErrInsufficientStock.Wrap(err)
+	case errors.Is(err, database.ErrPeriodExported):
+		return ErrPeriodExported.Wrap(err)
+	case errors.Is(err, database.ErrUnavailable):
+		return New(http.StatusServiceUnavailable, CodeUnavailable, "Service temporarily unavailable, please retry shortly").Wrap(err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return New(http.StatusGatewayTimeout, CodeTimeout, "The request took too long, please retry").Wrap(err)
+	case errors.As(err, &pqErr):
+		// Raised by the trigger that keeps exported periods from changing
+		if pqErr.Code.Name() == "check_violation" && pqErr.Constraint == "accounting_period_locked" {
+			return ErrPeriodLocked.Wrap(err)
+		}
+		if pqErr.Code.Name() == "unique_violation" {
+			if conflict, ok := uniqueViolations[pqErr.Constraint]; ok {
+				return conflict.Wrap(err)
+			}
+			return New(http.StatusConflict, CodeConflict, "A record with these values already exists").Wrap(err)
+		}
+	}
+	return ErrInternal.Wrap(err)
+}
+
+// Failed maps err like From, but answers an unexpected err with message,
+// e.g. "Failed to get products", rather than a bare internal error.
+func Failed(message string, err error) *Error {
+	mapped := From(err)
+	if mapped.Code == CodeInternal {
+		mapped = New(http.StatusInternalServerError, CodeInternal, message).Wrap(err)
+	}
+	return mapped
+}
+
+// Missing answers with notFound when err says the record does not exist,
+// and as From otherwise.
+func Missing(notFound *Error, err error) *Error {
+	if errors.Is(err, database.ErrNotFound) || errors.Is(err, sql.ErrNoRows) {
+		return notFound.Wrap(err)
+	}
+	return From(err)
+}
+
+// Respond writes the envelope for err and aborts the request. The message
+// is translated into the language the client asks for with
+// Accept-Language. Server errors other than 503 are logged, and attached
+// to the context so the recovery middleware reports them with their cause.
+func Respond(c *gin.Context, err error) {
+	apiErr := From(err)
+	if apiErr.Status >= http.StatusInternalServerError && apiErr.Status != http.StatusServiceUnavailable {
+		log.Printf("%s %s: %v", c.Request.Method, c.Request.URL.Path, apiErr)
+		c.Error(apiErr)
+	}
+	language := i18n.FromAcceptLanguage(c.GetHeader("Accept-Language"))
+	c.Header("Content-Language", language)
+	c.AbortWithStatusJSON(apiErr.Status, envelope{
+		Error:     i18n.T(language, apiErr.Message),
+		Code:      apiErr.Code,
+		Details:   apiErr.Details,
+		RequestID: c.GetString("request_id"),
+	})
+}
+
+type envelope struct {
+	Error     string      `json:"error"`
+	Code      Code        `json:"code"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+}
@@ -0,0 +1,167 @@
+package apierror
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/lib/pq"
+)
+
+func TestFrom(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   Code
+	}{
+		{"api error", ErrProductNotFound, http.StatusNotFound, CodeProductNotFound},
+		{"wrapped not found", fmt.Errorf("product %w", database.ErrNotFound), http.StatusNotFound, CodeNotFound},
+		{"no rows", sql.ErrNoRows, http.StatusNotFound, CodeNotFound},
+		{"no updates", database.ErrNoUpdates, http.StatusBadRequest, CodeInvalidRequest},
+		{"insufficient stock", database.ErrInsufficientStock, http.StatusConflict, CodeInsufficientStock},
+		{"invalid cursor", database.ErrInvalidCursor, http.StatusBadRequest, CodeInvalidCursor},
+		{"unavailable", database.ErrUnavailable, http.StatusServiceUnavailable, CodeUnavailable},
+		{"duplicate sku", &pq.Error{Code: "23505", Constraint: "products_organization_sku_key"}, http.StatusConflict, CodeDuplicateSKU},
+		{"other unique", &pq.Error{Code: "23505", Constraint: "other_key"}, http.StatusConflict, CodeConflict},
+		{"period exported", database.ErrPeriodExported, http.StatusConflict, CodePeriodExported},
+		{"period locked", &pq.Error{Code: "23514", Constraint: "accounting_period_locked"}, http.StatusConflict, CodePeriodLocked},
+		{"other postgres", &pq.Error{Code: "42601"}, http.StatusInternalServerError, CodeInternal},
+		{"unexpected", errors.New("connection reset"), http.StatusInternalServerError, CodeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := From(tt.err)
+			if got.Status != tt.status || got.Code != tt.code {
+				t.Errorf("From(%v) = %d %s, want %d %s", tt.err, got.Status, got.Code, tt.status, tt.code)
+			}
+		})
+	}
+}
+
+func TestFailedHidesCause(t *testing.T) {
+	err := Failed("Failed to get products", errors.New(`pq: relation "products" does not exist`))
+	if err.Status != http.StatusInternalServerError || err.Message != "Failed to get products" {
+		t.Errorf("unexpected error %d %q", err.Status, err.Message)
+	}
+
+	err = Failed("Failed to update stock", database.ErrInsufficientStock)
+	if err.Code != CodeInsufficientStock {
+		t.Errorf("expected known errors to keep their code, got %s", err.Code)
+	}
+}
+
+func TestMissing(t *testing.T) {
+	if got := Missing(ErrProductNotFound, sql.ErrNoRows); got.Code != CodeProductNotFound {
+		t.Errorf("expected product_not_found, got %s", got.Code)
+	}
+	if got := Missing(ErrProductNotFound, errors.New("timeout")); got.Status != http.StatusInternalServerError {
+		t.Errorf("expected other errors to be a 500, got %d", got.Status)
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	validation.Install()
+
+	var req struct {
+		Name  string `json:"name" validate:"required"`
+		Stock int    `json:"stock" validate:"min=0"`
+	}
+	err := binding.JSON.BindBody([]byte(`{"stock": -1}`), &req)
+	got := Invalid(err)
+	if got.Code != CodeValidationFailed {
+		t.Fatalf("expected validation_failed, got %s", got.Code)
+	}
+	fields, ok := got.Details.([]FieldError)
+	if !ok || len(fields) != 2 || fields[0].Field != "name" || fields[1].Rule != "min" {
+		t.Errorf("unexpected details %+v", got.Details)
+	}
+
+	if got := Invalid(binding.JSON.BindBody([]byte(`{"name": `), &req)); got.Message != "Request body is not valid JSON" {
+		t.Errorf("unexpected message for bad JSON: %q", got.Message)
+	}
+}
+
+func TestRespond(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/products/:id", func(c *gin.Context) {
+		c.Set("request_id", "req-1")
+		Respond(c, Failed("Failed to get product", errors.New("pq: connection refused")))
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/products/1", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", w.Code)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]interface{}{"error": "Failed to get product", "code": "internal_error", "request_id": "req-1"}
+	if len(body) != len(want) {
+		t.Errorf("unexpected body %v", body)
+	}
+	for key, value := range want {
+		if body[key] != value {
+			t.Errorf("expected %s %v, got %v", key, value, body[key])
+		}
+	}
+}
+
+func TestRespondInsufficientStock(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/products/:id/stock", func(c *gin.Context) {
+		// As UpdateStock answers a movement that would oversell
+		Respond(c, Failed("Failed to update stock", fmt.Errorf("sale: %w", database.ErrInsufficientStock)))
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/products/1/stock", nil))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", w.Code)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if body["code"] != string(CodeInsufficientStock) || body["error"] != "Stock cannot go below zero" {
+		t.Errorf("unexpected body %v", body)
+	}
+}
+
+func TestRespondTranslates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/products/:id", func(c *gin.Context) {
+		Respond(c, Missing(ErrProductNotFound, sql.ErrNoRows))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/products/1", nil)
+	req.Header.Set("Accept-Language", "id-ID,id;q=0.9,en;q=0.8")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if body["error"] != "Produk tidak ditemukan" || body["code"] != "product_not_found" {
+		t.Errorf("unexpected body %v", body)
+	}
+	if w.Header().Get("Content-Language") != "id" {
+		t.Errorf("expected Content-Language id, got %q", w.Header().Get("Content-Language"))
+	}
+}
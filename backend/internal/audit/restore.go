@@ -0,0 +1,327 @@
+package audit
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrNotRestorable = errors.New("only update and delete entries for products, categories and users can be restored")
+	ErrNoOldValues   = errors.New("audit entry has no previous values to restore")
+	ErrRecordExists  = errors.New("the deleted record exists again; restore one of its update entries instead")
+	ErrRecordMissing = errors.New("the record no longer exists; restore its delete entry instead")
+	ErrRedacted      = errors.New("audit entry has values that were redacted when it was recorded, so they cannot be restored")
+)
+
+// restoredFields are the fields Restore applies for each table.
+var restoredFields = map[string][]string{
+	"products":   {"name", "sku", "stock", "price", "category", "minimum_threshold", "supplier_info"},
+	"categories": {"name", "description"},
+	"users":      {"name", "email", "role", "is_active"},
+}
+
+// Restorer re-applies the previous values recorded in an audit entry
+// through the service that owns the table.
+type Restorer struct {
+	productService  *database.ProductService
+	categoryService *database.CategoryService
+	userService     *database.UserService
+}
+
+func NewRestorer(db *sql.DB) *Restorer {
+	return &Restorer{
+		productService:  database.NewProductService(db),
+		categoryService: database.NewCategoryService(db),
+		userService:     database.NewUserService(db),
+	}
+}
+
+// Restore puts the record back as it was before the entry's update or
+// delete and returns the values it applied. Entries with a redacted value
+// in a field it would apply, at any depth, are refused. Stock changes are booked as an
+// adjustment movement by restoredBy so the stock ledger still adds up.
+// Restored users get a random password and must reset it.
+func (r *Restorer) Restore(ctx context.Context, entry *models.AuditLog, restoredBy uuid.UUID) (map[string]interface{}, error) {
+	if entry.Action != models.ActionUpdate && entry.Action != models.ActionDelete {
+		return nil, ErrNotRestorable
+	}
+	if len(entry.OldValues) == 0 {
+		return nil, ErrNoOldValues
+	}
+	fields, ok := restoredFields[entry.TableName]
+	if !ok {
+		return nil, ErrNotRestorable
+	}
+	// Writing the redaction marker back would replace the real values
+	if redacted := redactedFields(entry.OldValues, fields); len(redacted) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrRedacted, strings.Join(redacted, ", "))
+	}
+
+	switch entry.TableName {
+	case "products":
+		return r.restoreProduct(ctx, entry, restoredBy)
+	case "categories":
+		return r.restoreCategory(ctx, entry)
+	case "users":
+		return r.restoreUser(ctx, entry)
+	default:
+		return nil, ErrNotRestorable
+	}
+}
+
+func (r *Restorer) restoreProduct(ctx context.Context, entry *models.AuditLog, restoredBy uuid.UUID) (map[string]interface{}, error) {
+	old := entry.OldValues
+	existing, err := r.productService.GetProduct(ctx, entry.RecordID)
+
+	if entry.Action == models.ActionDelete {
+		if err == nil {
+			return nil, ErrRecordExists
+		}
+		now := time.Now()
+		product := &models.Product{
+			ID:               entry.RecordID,
+			Name:             stringValue(old["name"]),
+			SKU:              stringValue(old["sku"]),
+			Stock:            intValue(old["stock"]),
+			Price:            floatValue(old["price"]),
+			Category:         stringValue(old["category"]),
+			MinimumThreshold: intValue(old["minimum_threshold"]),
+			SupplierInfo:     supplierInfo(old["supplier_info"]),
+			CreatedAt:        now,
+			UpdatedAt:        now,
+		}
+		if err := r.productService.CreateProduct(ctx, product); err != nil {
+			return nil, err
+		}
+		return old, nil
+	}
+
+	if err != nil {
+		return nil, ErrRecordMissing
+	}
+
+	updates := map[string]interface{}{}
+	for _, field := range []string{"name", "sku", "category"} {
+		if value, ok := old[field]; ok {
+			updates[field] = stringValue(value)
+		}
+	}
+	if value, ok := old["price"]; ok {
+		updates["price"] = floatValue(value)
+	}
+	if value, ok := old["minimum_threshold"]; ok {
+		updates["minimum_threshold"] = intValue(value)
+	}
+	if value, ok := old["supplier_info"]; ok {
+		updates["supplier_info"] = supplierInfo(value)
+	}
+	if len(updates) > 0 {
+		if err := r.productService.UpdateProduct(ctx, entry.RecordID, updates); err != nil {
+			return nil, err
+		}
+	}
+
+	if value, ok := old["stock"]; ok {
+		if change := intValue(value) - existing.Stock; change != 0 {
+			notes := fmt.Sprintf("Restored from audit entry %s", entry.ID)
+			if _, err := r.productService.UpdateProductStock(ctx, entry.RecordID, change, models.ReasonAdjustment, restoredBy, notes); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return old, nil
+}
+
+func (r *Restorer) restoreCategory(ctx context.Context, entry *models.AuditLog) (map[string]interface{}, error) {
+	old := entry.OldValues
+	_, err := r.categoryService.GetCategory(ctx, entry.RecordID)
+
+	if entry.Action == models.ActionDelete {
+		if err == nil {
+			return nil, ErrRecordExists
+		}
+		category := &models.Category{
+			ID:          entry.RecordID,
+			Name:        stringValue(old["name"]),
+			Description: stringValue(old["description"]),
+			CreatedAt:   time.Now(),
+		}
+		if err := r.categoryService.CreateCategory(ctx, category); err != nil {
+			return nil, err
+		}
+		return old, nil
+	}
+
+	if err != nil {
+		return nil, ErrRecordMissing
+	}
+	updates := map[string]interface{}{}
+	for _, field := range []string{"name", "description"} {
+		if value, ok := old[field]; ok {
+			updates[field] = stringValue(value)
+		}
+	}
+	if err := r.categoryService.UpdateCategory(ctx, entry.RecordID, updates); err != nil {
+		return nil, err
+	}
+	return old, nil
+}
+
+func (r *Restorer) restoreUser(ctx context.Context, entry *models.AuditLog) (map[string]interface{}, error) {
+	old := entry.OldValues
+	_, err := r.userService.GetUser(ctx, entry.RecordID)
+
+	if entry.Action == models.ActionDelete {
+		if err == nil {
+			return nil, ErrRecordExists
+		}
+		password, err := randomPasswordHash()
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		user := &models.User{
+			ID:        entry.RecordID,
+			Name:      stringValue(old["name"]),
+			Email:     stringValue(old["email"]),
+			Password:  password,
+			Role:      models.UserRole(stringValue(old["role"])),
+			IsActive:  boolValue(old["is_active"]),
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if user.Role != models.RoleAdmin {
+			user.Role = models.RoleStaff
+		}
+		if err := r.userService.CreateUser(ctx, user); err != nil {
+			return nil, err
+		}
+		return old, nil
+	}
+
+	if err != nil {
+		return nil, ErrRecordMissing
+	}
+	updates := map[string]interface{}{}
+	for _, field := range []string{"name", "email", "role"} {
+		if value, ok := old[field]; ok {
+			updates[field] = stringValue(value)
+		}
+	}
+	if value, ok := old["is_active"]; ok {
+		updates["is_active"] = boolValue(value)
+	}
+	if err := r.userService.UpdateUser(ctx, entry.RecordID, updates); err != nil {
+		return nil, err
+	}
+	return old, nil
+}
+
+// randomPasswordHash returns the hash of a password nobody knows, so a
+// restored user has to reset theirs before signing in.
+func randomPasswordHash() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(hex.EncodeToString(secret)), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// redactedFields returns those of fields whose value in values is the
+// redaction marker or holds it inside an object or list.
+func redactedFields(values map[string]interface{}, fields []string) []string {
+	var redacted []string
+	for _, field := range fields {
+		if value, ok := values[field]; ok && isRedacted(value) {
+			redacted = append(redacted, field)
+		}
+	}
+	return redacted
+}
+
+func isRedacted(value interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		return v == database.RedactedValue
+	case map[string]interface{}:
+		for _, item := range v {
+			if isRedacted(item) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if isRedacted(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Audit values come back from JSON, so numbers are float64.
+
+func stringValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return ""
+}
+
+func floatValue(value interface{}) float64 {
+	if f, ok := value.(float64); ok {
+		return f
+	}
+	return 0
+}
+
+func intValue(value interface{}) int {
+	return int(floatValue(value))
+}
+
+func boolValue(value interface{}) bool {
+	b, _ := value.(bool)
+	return b
+}
+
+// supplierInfo converts a recorded supplier_info back to JSON for the JSONB
+// column. Entries recorded before it was decoded for redaction hold the raw
+// bytes read from the database, which JSON encodes as base64, so a string
+// is decoded first.
+func supplierInfo(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		if decoded, err := base64.StdEncoding.DecodeString(v); err == nil && json.Valid(decoded) {
+			return string(decoded)
+		}
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		return string(encoded)
+	}
+}
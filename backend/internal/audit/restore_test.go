@@ -0,0 +1,65 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestRestoreRejects(t *testing.T) {
+	tests := []struct {
+		entry    models.AuditLog
+		expected error
+	}{
+		{models.AuditLog{TableName: "products", Action: models.ActionCreate, OldValues: map[string]interface{}{"name": "Widget"}}, ErrNotRestorable},
+		{models.AuditLog{TableName: "products", Action: models.ActionView}, ErrNotRestorable},
+		{models.AuditLog{TableName: "products", Action: models.ActionDelete}, ErrNoOldValues},
+		{models.AuditLog{TableName: "reports", Action: models.ActionDelete, OldValues: map[string]interface{}{"type": "inventory"}}, ErrNotRestorable},
+		{models.AuditLog{TableName: "users", Action: models.ActionUpdate, OldValues: map[string]interface{}{"email": "[REDACTED]"}}, ErrRedacted},
+		{models.AuditLog{TableName: "products", Action: models.ActionDelete, OldValues: map[string]interface{}{
+			"name": "Widget", "supplier_info": map[string]interface{}{"contacts": []interface{}{map[string]interface{}{"iban": "[REDACTED]"}}},
+		}}, ErrRedacted},
+	}
+
+	restorer := &Restorer{}
+	for _, tt := range tests {
+		if _, err := restorer.Restore(context.Background(), &tt.entry, uuid.New()); !errors.Is(err, tt.expected) {
+			t.Errorf("%s %s: expected %v, got %v", tt.entry.Action, tt.entry.TableName, tt.expected, err)
+		}
+	}
+}
+
+func TestRedactedFields(t *testing.T) {
+	values := map[string]interface{}{
+		"name":          "Widget",
+		"password":      "[REDACTED]",
+		"supplier_info": map[string]interface{}{"name": "Acme", "bank": map[string]interface{}{"iban": "[REDACTED]"}},
+		"description":   "not [REDACTED] on its own",
+	}
+	redacted := redactedFields(values, []string{"name", "description", "supplier_info"})
+	if len(redacted) != 1 || redacted[0] != "supplier_info" {
+		t.Errorf("expected only supplier_info reported, got %v", redacted)
+	}
+}
+
+func TestSupplierInfo(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected interface{}
+	}{
+		{nil, nil},
+		// JSONB read as bytes is recorded base64 encoded
+		{"eyJuYW1lIjoiQWNtZSJ9", `{"name":"Acme"}`},
+		{map[string]interface{}{"name": "Acme"}, `{"name":"Acme"}`},
+		{"Acme Ltd", `"Acme Ltd"`},
+	}
+	for _, tt := range tests {
+		if got := supplierInfo(tt.value); got != tt.expected {
+			t.Errorf("%v: expected %v, got %v", tt.value, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package audit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/models"
+	"rtims-backend/internal/storage"
+
+	"github.com/google/uuid"
+)
+
+// Settings keys that configure audit log retention.
+const (
+	// SettingRetentionDays is how many days audit entries are kept; 0 or
+	// unset keeps them forever.
+	SettingRetentionDays = "audit_retention_days"
+	// SettingArchive, when "true", exports entries to the object store
+	// before they are purged.
+	SettingArchive = "audit_archive"
+)
+
+// archiveBatchSize is how many entries go into one archive object.
+const archiveBatchSize = 10000
+
+// purgeTimeout bounds one retention run.
+const purgeTimeout = 10 * time.Minute
+
+var ErrInvalidRetention = errors.New("audit retention must be a whole number of days, or 0 to keep audit logs forever")
+
+// ParseRetentionDays validates a SettingRetentionDays value.
+func ParseRetentionDays(raw string) (int, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	days, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil || days < 0 {
+		return 0, ErrInvalidRetention
+	}
+	return days, nil
+}
+
+// PurgeRetention deletes audit entries older than the retention setting,
+// first exporting them to gzipped JSON lines objects under
+// storage.PrefixAuditArchives when archiving is enabled.
+func PurgeRetention(db *sql.DB, store storage.Store) error {
+	settings, err := database.NewSettingsService(db).GetSettings()
+	if err != nil {
+		return fmt.Errorf("failed to load audit retention settings: %w", err)
+	}
+	purge(database.NewAuditService(db), settings, store)
+	return nil
+}
+
+func purge(auditService *database.AuditService, settings map[string]interface{}, store storage.Store) {
+	raw, _ := settings[SettingRetentionDays].(string)
+	days, err := ParseRetentionDays(raw)
+	if err != nil {
+		log.Printf("Invalid %s setting, keeping audit logs: %v", SettingRetentionDays, err)
+		return
+	}
+	if days == 0 {
+		return
+	}
+
+	// Purges can outlast the default per-query timeout on a large table
+	ctx, cancel := context.WithTimeout(context.Background(), purgeTimeout)
+	defer cancel()
+
+	cutoff := time.Now().AddDate(0, 0, -days)
+	var purged int64
+	if archive, _ := settings[SettingArchive].(string); archive == "true" {
+		purged, err = archiveBefore(ctx, auditService, store, cutoff)
+	} else {
+		purged, err = auditService.PurgeAuditLogsBefore(ctx, cutoff)
+	}
+
+	if err != nil {
+		log.Printf("Audit log retention purge failed: %v", err)
+	}
+	if purged > 0 {
+		log.Printf("Audit log retention purge removed %d entries", purged)
+	}
+}
+
+// archiveBefore exports entries older than the cutoff in batches, deleting
+// each batch only once the store has its archive.
+func archiveBefore(ctx context.Context, auditService *database.AuditService, store storage.Store, cutoff time.Time) (int64, error) {
+	var purged int64
+	for {
+		auditLogs, err := auditService.GetAuditLogsBefore(ctx, cutoff, archiveBatchSize)
+		if err != nil || len(auditLogs) == 0 {
+			return purged, err
+		}
+
+		if err := writeArchive(ctx, store, auditLogs); err != nil {
+			return purged, err
+		}
+
+		ids := make([]uuid.UUID, len(auditLogs))
+		for i, auditLog := range auditLogs {
+			ids[i] = auditLog.ID
+		}
+		deleted, err := auditService.DeleteAuditLogs(ctx, ids)
+		purged += deleted
+		if err != nil || len(auditLogs) < archiveBatchSize {
+			return purged, err
+		}
+	}
+}
+
+// archiveKey names the archive of a batch after its first entry.
+func archiveKey(first models.AuditLog) string {
+	return fmt.Sprintf("%saudit-%s-%s.jsonl.gz", storage.PrefixAuditArchives,
+		first.ChangedAt.UTC().Format("20060102T150405"), first.ID.String()[:8])
+}
+
+// writeArchive stores auditLogs as a gzipped JSON lines object.
+func writeArchive(ctx context.Context, store storage.Store, auditLogs []models.AuditLog) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	encoder := json.NewEncoder(zw)
+	for _, auditLog := range auditLogs {
+		if err := encoder.Encode(auditLog); err != nil {
+			return fmt.Errorf("failed to write audit archive: %w", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to write audit archive: %w", err)
+	}
+
+	if err := store.Put(ctx, archiveKey(auditLogs[0]), &buf, int64(buf.Len()), "application/gzip"); err != nil {
+		return fmt.Errorf("failed to store audit archive: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package audit
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"rtims-backend/internal/models"
+	"rtims-backend/internal/storage"
+
+	"github.com/google/uuid"
+)
+
+func TestParseRetentionDays(t *testing.T) {
+	tests := []struct {
+		raw      string
+		days     int
+		expected error
+	}{
+		{"", 0, nil},
+		{"0", 0, nil},
+		{"365", 365, nil},
+		{" 30 ", 30, nil},
+		{"-1", 0, ErrInvalidRetention},
+		{"1y", 0, ErrInvalidRetention},
+	}
+	for _, tt := range tests {
+		days, err := ParseRetentionDays(tt.raw)
+		if err != tt.expected || days != tt.days {
+			t.Errorf("%q: expected %d, %v, got %d, %v", tt.raw, tt.days, tt.expected, days, err)
+		}
+	}
+}
+
+func TestWriteArchive(t *testing.T) {
+	store, err := storage.NewLocal(t.TempDir(), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auditLogs := []models.AuditLog{
+		{ID: uuid.New(), TableName: "products", Action: models.ActionUpdate, ChangedAt: time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{ID: uuid.New(), TableName: "users", Action: models.ActionDelete, ChangedAt: time.Date(2023, 1, 3, 0, 0, 0, 0, time.UTC)},
+	}
+
+	if err := writeArchive(context.Background(), store, auditLogs); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	objects, err := store.List(context.Background(), storage.PrefixAuditArchives)
+	if err != nil || len(objects) != 1 || !strings.HasPrefix(objects[0].Key, storage.PrefixAuditArchives+"audit-20230102T030405-") {
+		t.Fatalf("expected one archive object, got %v, %v", objects, err)
+	}
+
+	object, err := store.Open(context.Background(), objects[0].Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer object.Close()
+	zr, err := gzip.NewReader(object)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoder := json.NewDecoder(zr)
+	for _, expected := range auditLogs {
+		var auditLog models.AuditLog
+		if err := decoder.Decode(&auditLog); err != nil {
+			t.Fatalf("failed to decode archived entry: %v", err)
+		}
+		if auditLog.ID != expected.ID || auditLog.TableName != expected.TableName {
+			t.Errorf("expected %s %s, got %s %s", expected.ID, expected.TableName, auditLog.ID, auditLog.TableName)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"log/syslog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Settings keys that configure streaming audit entries to a SIEM.
+const (
+	// SettingStreamURL is where audit entries are forwarded: an http(s) URL
+	// receiving newline-delimited JSON (e.g. a Splunk HEC raw endpoint or a
+	// Logstash http input), or udp:// or tcp:// for a syslog server. Empty
+	// disables streaming.
+	SettingStreamURL = "audit_stream_url"
+	// SettingStreamAuth is sent as the Authorization header to http(s)
+	// endpoints, e.g. "Splunk <token>".
+	SettingStreamAuth = "audit_stream_auth"
+)
+
+const (
+	streamBatchSize   = 500
+	streamMaxAttempts = 3
+	streamBaseBackoff = time.Second
+	syslogTag         = "rtims-audit"
+)
+
+var ErrInvalidStreamURL = errors.New("audit stream URL must be an http, https, udp or tcp URL with a host")
+
+// ParseStreamURL validates a SettingStreamURL value.
+func ParseStreamURL(raw string) (*url.URL, error) {
+	target, err := url.Parse(raw)
+	if err != nil || target.Host == "" {
+		return nil, ErrInvalidStreamURL
+	}
+	switch target.Scheme {
+	case "http", "https", "udp", "tcp":
+		return target, nil
+	}
+	return nil, ErrInvalidStreamURL
+}
+
+// Streamer forwards audit entries to the SIEM configured in settings.
+type Streamer struct {
+	auditService    *database.AuditService
+	settingsService *database.SettingsService
+	httpClient      *http.Client
+	// syslog connection, kept open between batches
+	syslogWriter *syslog.Writer
+	syslogTarget string
+}
+
+func NewStreamer(db *sql.DB) *Streamer {
+	return &Streamer{
+		auditService:    database.NewAuditService(db),
+		settingsService: database.NewSettingsService(db),
+		httpClient:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Run forwards new audit entries in batches every interval. Entries are
+// marked once delivered, so a failed batch is retried on the next run. It
+// blocks until ctx is done, so run it in its own goroutine.
+func (s *Streamer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		settings, err := s.settingsService.GetSettings()
+		if err != nil {
+			log.Printf("Failed to load audit streaming settings: %v", err)
+			continue
+		}
+
+		raw, _ := settings[SettingStreamURL].(string)
+		if raw == "" {
+			continue
+		}
+		target, err := ParseStreamURL(raw)
+		if err != nil {
+			log.Printf("Invalid %s setting: %v", SettingStreamURL, err)
+			continue
+		}
+		auth, _ := settings[SettingStreamAuth].(string)
+
+		if err := s.stream(target, auth); err != nil {
+			log.Printf("Audit streaming failed: %v", err)
+		}
+	}
+}
+
+// stream sends every pending entry, stopping at the first failed batch.
+func (s *Streamer) stream(target *url.URL, auth string) error {
+	for {
+		auditLogs, err := s.auditService.GetUnstreamedAuditLogs(context.Background(), streamBatchSize)
+		if err != nil || len(auditLogs) == 0 {
+			return err
+		}
+
+		if target.Scheme == "http" || target.Scheme == "https" {
+			err = s.postBatch(target.String(), auth, auditLogs)
+		} else {
+			err = s.syslogBatch(target, auditLogs)
+		}
+		if err != nil {
+			return err
+		}
+
+		ids := make([]uuid.UUID, len(auditLogs))
+		for i, auditLog := range auditLogs {
+			ids[i] = auditLog.ID
+		}
+		if err := s.auditService.MarkAuditLogsStreamed(context.Background(), ids); err != nil {
+			return err
+		}
+		if len(auditLogs) < streamBatchSize {
+			return nil
+		}
+	}
+}
+
+// postBatch posts the entries as newline-delimited JSON, retrying with
+// exponential backoff on network errors, rate limiting, and server errors.
+func (s *Streamer) postBatch(target, auth string, auditLogs []models.AuditLog) error {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, auditLog := range auditLogs {
+		if err := encoder.Encode(auditLog); err != nil {
+			return fmt.Errorf("failed to encode audit log: %w", err)
+		}
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < streamMaxAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(streamBaseBackoff << (attempt - 1))
+		}
+
+		req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body.Bytes()))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+
+		resp, err := s.httpClient.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return nil
+		}
+
+		lastErr = fmt.Errorf("SIEM endpoint returned status %d", resp.StatusCode)
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+			// Client errors will not succeed on retry
+			break
+		}
+	}
+
+	return fmt.Errorf("audit batch delivery failed after retries: %w", lastErr)
+}
+
+// syslogBatch writes one syslog message per entry.
+func (s *Streamer) syslogBatch(target *url.URL, auditLogs []models.AuditLog) error {
+	for _, auditLog := range auditLogs {
+		message, err := json.Marshal(auditLog)
+		if err != nil {
+			return fmt.Errorf("failed to encode audit log: %w", err)
+		}
+		if err := s.writeSyslog(target, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSyslog sends one message, redialling the server with exponential
+// backoff when the connection fails.
+func (s *Streamer) writeSyslog(target *url.URL, message []byte) error {
+	var lastErr error
+	for attempt := 0; attempt < streamMaxAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(streamBaseBackoff << (attempt - 1))
+		}
+
+		writer, err := s.syslog(target)
+		if err == nil {
+			if _, err = writer.Write(message); err == nil {
+				return nil
+			}
+			s.closeSyslog()
+		}
+		lastErr = err
+	}
+
+	return fmt.Errorf("audit syslog delivery failed after retries: %w", lastErr)
+}
+
+// syslog returns a connection to target, reusing the open one if it points
+// at the same server.
+func (s *Streamer) syslog(target *url.URL) (*syslog.Writer, error) {
+	if s.syslogWriter != nil && s.syslogTarget == target.String() {
+		return s.syslogWriter, nil
+	}
+	s.closeSyslog()
+
+	writer, err := syslog.Dial(target.Scheme, target.Host, syslog.LOG_INFO|syslog.LOG_AUTH, syslogTag)
+	if err != nil {
+		return nil, err
+	}
+	s.syslogWriter = writer
+	s.syslogTarget = target.String()
+	return writer, nil
+}
+
+func (s *Streamer) closeSyslog() {
+	if s.syslogWriter != nil {
+		s.syslogWriter.Close()
+		s.syslogWriter = nil
+	}
+}
@@ -0,0 +1,80 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestParseStreamURL(t *testing.T) {
+	valid := []string{"https://splunk.example.com:8088/services/collector/raw", "http://logstash:8080", "udp://siem:514", "tcp://siem:6514"}
+	for _, raw := range valid {
+		if _, err := ParseStreamURL(raw); err != nil {
+			t.Errorf("%s: unexpected error %v", raw, err)
+		}
+	}
+
+	invalid := []string{"siem:514", "ftp://siem", "https://", "not a url"}
+	for _, raw := range invalid {
+		if _, err := ParseStreamURL(raw); err != ErrInvalidStreamURL {
+			t.Errorf("%s: expected ErrInvalidStreamURL, got %v", raw, err)
+		}
+	}
+}
+
+func TestPostBatch(t *testing.T) {
+	auditLogs := []models.AuditLog{
+		{ID: uuid.New(), TableName: "products", Action: models.ActionCreate},
+		{ID: uuid.New(), TableName: "users", Action: models.ActionDelete},
+	}
+
+	var received []models.AuditLog
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var auditLog models.AuditLog
+			if err := json.Unmarshal(scanner.Bytes(), &auditLog); err != nil {
+				t.Errorf("invalid line %q: %v", scanner.Text(), err)
+			}
+			received = append(received, auditLog)
+		}
+	}))
+	defer server.Close()
+
+	streamer := &Streamer{httpClient: server.Client()}
+	if err := streamer.postBatch(server.URL, "Splunk token", auditLogs); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if auth != "Splunk token" {
+		t.Errorf("expected Authorization header to be sent, got %q", auth)
+	}
+	if len(received) != 2 || received[0].ID != auditLogs[0].ID || received[1].ID != auditLogs[1].ID {
+		t.Errorf("expected both entries in order, got %+v", received)
+	}
+}
+
+func TestPostBatchClientError(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	streamer := &Streamer{httpClient: server.Client()}
+	if err := streamer.postBatch(server.URL, "", []models.AuditLog{{ID: uuid.New()}}); err == nil {
+		t.Fatal("expected an error")
+	}
+	if attempts != 1 {
+		t.Errorf("expected client errors not to be retried, got %d attempts", attempts)
+	}
+}
@@ -0,0 +1,338 @@
+// Package backup dumps the database with pg_dump and keeps the dumps on
+// local disk, in S3 or in the shared object store, recording each run in
+// the backups table.
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"rtims-backend/internal/alerting"
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Timeout bounds one backup, from pg_dump to the end of the upload.
+const Timeout = 2 * time.Hour
+
+// maxStderr caps how much of pg_dump's error output is kept for the
+// backups table.
+const maxStderr = 4096
+
+// Runner takes backups. Only one backup runs at a time across instances;
+// starting another meanwhile fails with a unique violation on
+// backups_one_active.
+type Runner struct {
+	databaseURL     string
+	pgDump          string
+	storage         Storage
+	backupService   *database.BackupService
+	settingsService *database.SettingsService
+
+	// Alerts, when set, raises an alert when a backup fails.
+	Alerts *alerting.Router
+}
+
+// ErrRunning is returned for a backup that cannot be read or deleted
+// before it finishes.
+var ErrRunning = errors.New("backup has not finished")
+
+// NewRunner returns a Runner dumping the database at databaseURL with the
+// pg_dump binary at pgDump. Backups left unfinished by an instance that
+// exited are marked failed.
+func NewRunner(db *sql.DB, databaseURL, pgDump string, storage Storage) *Runner {
+	r := &Runner{
+		databaseURL:     databaseURL,
+		pgDump:          pgDump,
+		storage:         storage,
+		backupService:   database.NewBackupService(db),
+		settingsService: database.NewSettingsService(db),
+	}
+	r.failStale()
+	return r
+}
+
+// Start records a backup and takes it in the background.
+func (r *Runner) Start(requestedBy *uuid.UUID) (*models.Backup, error) {
+	backup, err := r.create(requestedBy)
+	if err != nil {
+		return nil, err
+	}
+	go r.run(backup.ID)
+	return backup, nil
+}
+
+// RunNow records and takes a backup in the calling goroutine, and returns
+// its final state. A backup that failed is returned with its error.
+func (r *Runner) RunNow(requestedBy *uuid.UUID) (*models.Backup, error) {
+	backup, err := r.create(requestedBy)
+	if err != nil {
+		return nil, err
+	}
+	r.run(backup.ID)
+
+	backup, err = r.backupService.GetBackup(backup.ID)
+	if err != nil {
+		return nil, err
+	}
+	if backup.Status == models.BackupFailed {
+		return backup, errors.New(backup.Error)
+	}
+	return backup, nil
+}
+
+// Backups returns the most recent backups, newest first.
+func (r *Runner) Backups(limit int) ([]models.Backup, error) {
+	return r.backupService.GetBackups(limit)
+}
+
+// Get returns the current state of a backup.
+func (r *Runner) Get(id uuid.UUID) (*models.Backup, error) {
+	return r.backupService.GetBackup(id)
+}
+
+// Open reads back the dump of a completed backup.
+func (r *Runner) Open(ctx context.Context, backup *models.Backup) (io.ReadCloser, error) {
+	if backup.Status != models.BackupCompleted {
+		return nil, ErrRunning
+	}
+	if err := r.stored(backup); err != nil {
+		return nil, err
+	}
+	return r.storage.Open(ctx, backup.Location)
+}
+
+// Delete removes a finished backup and its dump. A failed backup has no
+// dump, so only its record goes.
+func (r *Runner) Delete(ctx context.Context, backup *models.Backup) error {
+	switch backup.Status {
+	case models.BackupQueued, models.BackupRunning:
+		return ErrRunning
+	case models.BackupCompleted:
+		if err := r.stored(backup); err != nil {
+			return err
+		}
+		if err := r.storage.Delete(ctx, backup.Location); err != nil {
+			return err
+		}
+	}
+	return r.backupService.DeleteBackup(backup.ID)
+}
+
+// Prune deletes the completed backups past the retention settings, and
+// returns how many it deleted.
+func (r *Runner) Prune(ctx context.Context) (int, error) {
+	settings, err := r.settingsService.GetSettings()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load backup retention settings: %w", err)
+	}
+	rawCount, _ := settings[SettingRetentionCount].(string)
+	keepCount, err := ParseRetention(rawCount)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s setting: %w", SettingRetentionCount, err)
+	}
+	rawDays, _ := settings[SettingRetentionDays].(string)
+	keepDays, err := ParseRetention(rawDays)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s setting: %w", SettingRetentionDays, err)
+	}
+	if keepCount == 0 && keepDays == 0 {
+		return 0, nil
+	}
+
+	completed, err := r.backupService.GetCompletedBackups()
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, backup := range expired(completed, keepCount, keepDays, time.Now()) {
+		backup := backup
+		if err := r.Delete(ctx, &backup); err != nil {
+			return deleted, fmt.Errorf("failed to delete backup %s: %w", backup.ID, err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// stored checks that backup was stored in the configured storage, which
+// is the only one the Runner can reach.
+func (r *Runner) stored(backup *models.Backup) error {
+	if backup.Storage != r.storage.Name() {
+		return fmt.Errorf("backup %s is in %s storage, but %s storage is configured", backup.ID, backup.Storage, r.storage.Name())
+	}
+	return nil
+}
+
+func (r *Runner) create(requestedBy *uuid.UUID) (*models.Backup, error) {
+	r.failStale()
+
+	backup := &models.Backup{
+		ID:          uuid.New(),
+		Status:      models.BackupQueued,
+		Storage:     r.storage.Name(),
+		RequestedBy: requestedBy,
+		CreatedAt:   time.Now(),
+	}
+	if err := r.backupService.CreateBackup(backup); err != nil {
+		return nil, err
+	}
+	return backup, nil
+}
+
+func (r *Runner) failStale() {
+	if n, err := r.backupService.FailStale(Timeout); err != nil {
+		log.Printf("Failed to clean up interrupted backups: %v", err)
+	} else if n > 0 {
+		log.Printf("Marked %d interrupted backups as failed", n)
+	}
+}
+
+func (r *Runner) run(id uuid.UUID) {
+	if err := r.backupService.MarkRunning(id); err != nil {
+		log.Printf("Failed to mark backup %s running: %v", id, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+
+	location, size, checksum, err := r.backup(ctx, id)
+	if err != nil {
+		log.Printf("Backup %s failed: %v", id, err)
+		if err := r.backupService.MarkFailed(id, err.Error()); err != nil {
+			log.Printf("Failed to mark backup %s failed: %v", id, err)
+		}
+		if r.Alerts != nil {
+			r.Alerts.Fire(alerting.BackupFailed(id, err.Error()))
+		}
+		return
+	}
+
+	log.Printf("Backup %s stored at %s (%d bytes)", id, location, size)
+	if err := r.backupService.MarkCompleted(id, location, size, checksum); err != nil {
+		log.Printf("Failed to mark backup %s completed: %v", id, err)
+		return
+	}
+
+	// Apply retention once there is a new backup to keep
+	if deleted, err := r.Prune(ctx); err != nil {
+		log.Printf("Failed to apply backup retention: %v", err)
+	} else if deleted > 0 {
+		log.Printf("Deleted %d backups past retention", deleted)
+	}
+}
+
+// backup dumps the database to a temporary file, so its size and checksum
+// are known before the upload starts, then hands it to the storage.
+func (r *Runner) backup(ctx context.Context, id uuid.UUID) (string, int64, string, error) {
+	file, err := os.CreateTemp("", "rtims-backup-*.dump")
+	if err != nil {
+		return "", 0, "", fmt.Errorf("failed to create dump file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	size, checksum, err := r.dump(ctx, file)
+	if err != nil {
+		return "", 0, "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", 0, "", fmt.Errorf("failed to rewind dump file: %w", err)
+	}
+
+	location, err := r.storage.Put(ctx, Key(id, time.Now()), file, size, checksum)
+	if err != nil {
+		return "", 0, "", err
+	}
+	return location, size, checksum, nil
+}
+
+// dump writes a pg_dump custom format archive to w, and returns its size
+// and hex SHA-256.
+func (r *Runner) dump(ctx context.Context, w io.Writer) (int64, string, error) {
+	dbname, env, err := connection(r.databaseURL)
+	if err != nil {
+		return 0, "", err
+	}
+
+	hash := sha256.New()
+	counter := &countingWriter{}
+	stderr := &limitedBuffer{limit: maxStderr}
+
+	cmd := exec.CommandContext(ctx, r.pgDump, "--format=custom", "--no-owner", "--no-privileges", "--dbname="+dbname)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = io.MultiWriter(w, hash, counter)
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return 0, "", fmt.Errorf("pg_dump failed: %w: %s", err, message)
+		}
+		return 0, "", fmt.Errorf("pg_dump failed: %w", err)
+	}
+
+	return counter.n, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Key names the dump of backup id taken at t. Keys sort by time.
+func Key(id uuid.UUID, t time.Time) string {
+	return fmt.Sprintf("rtims-%s-%s.dump", t.UTC().Format("20060102T150405Z"), id)
+}
+
+// connection splits the password out of databaseURL into PGPASSWORD, so it
+// does not show in pg_dump's command line.
+func connection(databaseURL string) (string, []string, error) {
+	parsed, err := url.Parse(databaseURL)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid database URL: %w", err)
+	}
+	if parsed.User == nil {
+		return databaseURL, nil, nil
+	}
+	password, ok := parsed.User.Password()
+	if !ok {
+		return databaseURL, nil, nil
+	}
+	parsed.User = url.User(parsed.User.Username())
+	return parsed.String(), []string{"PGPASSWORD=" + password}, nil
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// limitedBuffer keeps the first limit bytes written to it and discards the
+// rest.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); room > 0 {
+		if len(p) > room {
+			b.Buffer.Write(p[:room])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,211 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"rtims-backend/internal/storage"
+
+	"github.com/google/uuid"
+)
+
+func TestConnection(t *testing.T) {
+	dbname, env, err := connection("postgres://rtims:s3cret@db:5432/rtims?sslmode=disable")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if dbname != "postgres://rtims@db:5432/rtims?sslmode=disable" {
+		t.Errorf("expected the password to be removed, got %s", dbname)
+	}
+	if len(env) != 1 || env[0] != "PGPASSWORD=s3cret" {
+		t.Errorf("expected the password in PGPASSWORD, got %v", env)
+	}
+
+	if _, env, _ := connection("postgres://db/rtims"); env != nil {
+		t.Errorf("expected no environment without a password, got %v", env)
+	}
+}
+
+func TestKey(t *testing.T) {
+	id := uuid.MustParse("6f1c2a3e-0d4b-4c5a-9e8f-7a6b5c4d3e2f")
+	at := time.Date(2024, 3, 1, 2, 0, 5, 0, time.FixedZone("WIB", 7*60*60))
+	if key := Key(id, at); key != "rtims-20240229T190005Z-6f1c2a3e-0d4b-4c5a-9e8f-7a6b5c4d3e2f.dump" {
+		t.Errorf("unexpected key %s", key)
+	}
+}
+
+// fakePgDump writes a script standing in for pg_dump.
+func fakePgDump(t *testing.T, script string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "pg_dump")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDump(t *testing.T) {
+	r := &Runner{databaseURL: "postgres://rtims:s3cret@db/rtims", pgDump: fakePgDump(t, `printf '%s' "$PGPASSWORD"`)}
+
+	var out bytes.Buffer
+	size, checksum, err := r.dump(context.Background(), &out)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.String() != "s3cret" || size != 6 {
+		t.Errorf("expected the dump output, got %q (%d bytes)", out.String(), size)
+	}
+	if checksum != "1ec1c26b50d5d3c58d9583181af8076655fe00756bf7285940ba3670f99fcba0" {
+		t.Errorf("unexpected checksum %s", checksum)
+	}
+}
+
+func TestDumpFailure(t *testing.T) {
+	r := &Runner{databaseURL: "postgres://db/rtims", pgDump: fakePgDump(t, `echo 'connection refused' >&2; exit 1`)}
+
+	_, _, err := r.dump(context.Background(), &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("expected pg_dump's error output, got %v", err)
+	}
+}
+
+func TestLocalStorage(t *testing.T) {
+	storage, err := NewLocalStorage(filepath.Join(t.TempDir(), "backups"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	location, err := storage.Put(context.Background(), "rtims.dump", strings.NewReader("dump"), 4, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, err := os.ReadFile(location); err != nil || string(data) != "dump" {
+		t.Errorf("expected the dump at %s, got %q %v", location, data, err)
+	}
+
+	if _, err := storage.Put(context.Background(), "short.dump", strings.NewReader("du"), 4, ""); err == nil {
+		t.Error("expected a short write to fail")
+	}
+	if _, err := os.Stat(filepath.Join(storage.Dir, "short.dump")); !os.IsNotExist(err) {
+		t.Error("expected no file for a failed write")
+	}
+	if _, err := storage.Put(context.Background(), "../escape.dump", strings.NewReader("dump"), 4, ""); err == nil {
+		t.Error("expected a key with a path to be refused")
+	}
+
+	dump, err := storage.Open(context.Background(), location)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, _ := io.ReadAll(dump)
+	dump.Close()
+	if string(data) != "dump" {
+		t.Errorf("expected to read the dump back, got %q", data)
+	}
+	if _, err := storage.Open(context.Background(), "/etc/passwd"); err == nil {
+		t.Error("expected files outside the backup directory to be refused")
+	}
+
+	if err := storage.Delete(context.Background(), location); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(location); !os.IsNotExist(err) {
+		t.Error("expected the dump to be deleted")
+	}
+	if err := storage.Delete(context.Background(), location); err != nil {
+		t.Errorf("expected deleting a missing dump to succeed, got %v", err)
+	}
+}
+
+func TestSharedStorage(t *testing.T) {
+	store, err := storage.NewLocal(t.TempDir(), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared := &SharedStorage{Store: store}
+
+	location, err := shared.Put(context.Background(), "rtims.dump", strings.NewReader("dump"), 4, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if location != "backups/rtims.dump" {
+		t.Errorf("unexpected location %s", location)
+	}
+	dump, err := shared.Open(context.Background(), location)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, _ := io.ReadAll(dump)
+	dump.Close()
+	if string(data) != "dump" {
+		t.Errorf("expected to read the dump back, got %q", data)
+	}
+	if _, err := shared.Open(context.Background(), "products/1/image.png"); err == nil {
+		t.Error("expected objects outside backups/ to be refused")
+	}
+	if err := shared.Delete(context.Background(), location); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestBucketStorage(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.Method {
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodGet:
+			w.Header().Set("Last-Modified", "Fri, 02 Jan 2026 03:04:05 GMT")
+			io.WriteString(w, "dump")
+		}
+	}))
+	defer server.Close()
+
+	bucket := &BucketStorage{Bucket: &storage.S3{Endpoint: server.URL, Region: "us-east-1", Bucket: "rtims-backups",
+		Prefix: "/nightly/", AccessKeyID: "key", SecretAccessKey: "secret", Transport: server.Client().Transport}}
+	location, err := bucket.Put(context.Background(), "rtims.dump", strings.NewReader("dump"), 4, "checksum")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if location != "s3://rtims-backups/nightly/rtims.dump" {
+		t.Errorf("unexpected location %s", location)
+	}
+
+	// Dumps stored under an earlier prefix are found by their location
+	dump, err := bucket.Open(context.Background(), "s3://rtims-backups/weekly/rtims.dump")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, _ := io.ReadAll(dump)
+	dump.Close()
+	if string(data) != "dump" {
+		t.Errorf("expected the dump, got %q", data)
+	}
+	if err := bucket.Delete(context.Background(), location); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"PUT /rtims-backups/nightly/rtims.dump", "GET /rtims-backups/weekly/rtims.dump", "DELETE /rtims-backups/nightly/rtims.dump"}
+	if strings.Join(requests, ", ") != strings.Join(want, ", ") {
+		t.Errorf("expected requests %v, got %v", want, requests)
+	}
+	if _, err := bucket.Open(context.Background(), "s3://other-bucket/rtims.dump"); err == nil {
+		t.Error("expected other buckets to be refused")
+	}
+	if _, err := bucket.Put(context.Background(), "nested/rtims.dump", strings.NewReader("dump"), 4, ""); err == nil {
+		t.Error("expected keys with a slash to be refused")
+	}
+}
@@ -0,0 +1,116 @@
+package backup
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"rtims-backend/internal/models"
+	"rtims-backend/internal/scheduler"
+)
+
+// Settings keys that configure scheduled backups and their retention.
+const (
+	// SettingAuto, when "false", stops scheduled backups. Backups can
+	// still be started by hand.
+	SettingAuto = "auto_backup"
+	// SettingFrequency is how often scheduled backups run: hourly, daily,
+	// weekly or monthly.
+	SettingFrequency = "backup_frequency"
+	// SettingRetentionCount is how many completed backups are kept; 0 or
+	// unset keeps them all.
+	SettingRetentionCount = "backup_retention_count"
+	// SettingRetentionDays is how many days completed backups are kept; 0
+	// or unset keeps them forever.
+	SettingRetentionDays = "backup_retention_days"
+)
+
+// frequencies are the schedules of the backup_frequency values. Backups
+// run at 02:00, weekly ones on Sundays and monthly ones on the 1st.
+var frequencies = map[string]string{
+	"hourly":  "0 * * * *",
+	"daily":   "0 2 * * *",
+	"weekly":  "0 2 * * 0",
+	"monthly": "0 2 1 * *",
+}
+
+var (
+	ErrInvalidAuto      = errors.New("auto_backup must be true or false")
+	ErrInvalidFrequency = errors.New("backup_frequency must be hourly, daily, weekly or monthly")
+	ErrInvalidRetention = errors.New("backup retention must be a whole number, or 0 for no limit")
+)
+
+// ParseAuto validates a SettingAuto value. Unset means on.
+func ParseAuto(raw string) (bool, error) {
+	if strings.TrimSpace(raw) == "" {
+		return true, nil
+	}
+	auto, err := strconv.ParseBool(strings.TrimSpace(raw))
+	if err != nil {
+		return false, ErrInvalidAuto
+	}
+	return auto, nil
+}
+
+// ParseFrequency validates a SettingFrequency value and returns its cron
+// schedule. Unset means daily.
+func ParseFrequency(raw string) (string, error) {
+	frequency := strings.ToLower(strings.TrimSpace(raw))
+	if frequency == "" {
+		frequency = "daily"
+	}
+	schedule, ok := frequencies[frequency]
+	if !ok {
+		return "", ErrInvalidFrequency
+	}
+	return schedule, nil
+}
+
+// ParseRetention validates a SettingRetentionCount or SettingRetentionDays
+// value.
+func ParseRetention(raw string) (int, error) {
+	if strings.TrimSpace(raw) == "" {
+		return 0, nil
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil || limit < 0 {
+		return 0, ErrInvalidRetention
+	}
+	return limit, nil
+}
+
+// Schedule is the default schedule of the backup job: off when auto_backup
+// is false, otherwise the one for backup_frequency. Invalid values, which
+// UpdateSettings refuses, fall back to daily backups rather than none.
+func Schedule(settings map[string]interface{}) string {
+	auto, _ := settings[SettingAuto].(string)
+	if enabled, err := ParseAuto(auto); err == nil && !enabled {
+		return scheduler.ScheduleOff
+	}
+	frequency, _ := settings[SettingFrequency].(string)
+	schedule, err := ParseFrequency(frequency)
+	if err != nil {
+		return frequencies["daily"]
+	}
+	return schedule
+}
+
+// expired returns the completed backups, newest first, that fall outside
+// keepCount or keepDays as of now. The newest backup is always kept, so
+// retention never leaves no backup at all.
+func expired(backups []models.Backup, keepCount, keepDays int, now time.Time) []models.Backup {
+	cutoff := now.AddDate(0, 0, -keepDays)
+	var result []models.Backup
+	for i, backup := range backups {
+		if i == 0 {
+			continue
+		}
+		tooMany := keepCount > 0 && i >= keepCount
+		tooOld := keepDays > 0 && backup.CompletedAt != nil && backup.CompletedAt.Before(cutoff)
+		if tooMany || tooOld {
+			result = append(result, backup)
+		}
+	}
+	return result
+}
@@ -0,0 +1,71 @@
+package backup
+
+import (
+	"testing"
+	"time"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestSchedule(t *testing.T) {
+	tests := []struct {
+		settings map[string]interface{}
+		schedule string
+	}{
+		{map[string]interface{}{}, "0 2 * * *"},
+		{map[string]interface{}{SettingAuto: "true", SettingFrequency: "hourly"}, "0 * * * *"},
+		{map[string]interface{}{SettingFrequency: "Weekly"}, "0 2 * * 0"},
+		{map[string]interface{}{SettingFrequency: "monthly"}, "0 2 1 * *"},
+		{map[string]interface{}{SettingAuto: "false", SettingFrequency: "hourly"}, "off"},
+		{map[string]interface{}{SettingAuto: "maybe", SettingFrequency: "fortnightly"}, "0 2 * * *"},
+	}
+	for _, tt := range tests {
+		if schedule := Schedule(tt.settings); schedule != tt.schedule {
+			t.Errorf("Schedule(%v) = %q, want %q", tt.settings, schedule, tt.schedule)
+		}
+	}
+}
+
+func TestParseSettings(t *testing.T) {
+	if _, err := ParseAuto("yes"); err != ErrInvalidAuto {
+		t.Errorf("expected ErrInvalidAuto, got %v", err)
+	}
+	if _, err := ParseFrequency("fortnightly"); err != ErrInvalidFrequency {
+		t.Errorf("expected ErrInvalidFrequency, got %v", err)
+	}
+	for _, raw := range []string{"-1", "7 days", "1.5"} {
+		if _, err := ParseRetention(raw); err != ErrInvalidRetention {
+			t.Errorf("ParseRetention(%q): expected ErrInvalidRetention, got %v", raw, err)
+		}
+	}
+	if days, err := ParseRetention(" 30 "); err != nil || days != 30 {
+		t.Errorf("expected 30, got %d %v", days, err)
+	}
+}
+
+func TestExpired(t *testing.T) {
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	var backups []models.Backup
+	for _, age := range []int{0, 1, 2, 10, 40, 50} {
+		completedAt := now.AddDate(0, 0, -age)
+		backups = append(backups, models.Backup{ID: uuid.New(), CompletedAt: &completedAt})
+	}
+
+	if got := expired(backups, 0, 0, now); len(got) != 0 {
+		t.Errorf("expected no limits to keep everything, got %d", len(got))
+	}
+	if got := expired(backups, 3, 0, now); len(got) != 3 || got[0].ID != backups[3].ID {
+		t.Errorf("expected all but the 3 newest, got %v", got)
+	}
+	if got := expired(backups, 0, 30, now); len(got) != 2 || got[0].ID != backups[4].ID {
+		t.Errorf("expected the backups older than 30 days, got %v", got)
+	}
+	if got := expired(backups, 5, 5, now); len(got) != 3 {
+		t.Errorf("expected either limit to expire a backup, got %d", len(got))
+	}
+	if got := expired(backups[4:], 0, 30, now); len(got) != 1 || got[0].ID != backups[5].ID {
+		t.Errorf("expected the newest backup to be kept however old, got %v", got)
+	}
+}
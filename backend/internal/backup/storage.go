@@ -0,0 +1,222 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"rtims-backend/config"
+	"rtims-backend/internal/storage"
+)
+
+// Storage keeps backup dumps.
+type Storage interface {
+	// Name identifies the storage in the backups table, e.g. "local".
+	Name() string
+	// Put stores the size bytes of r under key and returns where they
+	// went. checksum is their hex SHA-256.
+	Put(ctx context.Context, key string, r io.Reader, size int64, checksum string) (string, error)
+	// Open reads back the dump Put stored at location.
+	Open(ctx context.Context, location string) (io.ReadCloser, error)
+	// Delete removes the dump at location. A dump that is already gone is
+	// not an error.
+	Delete(ctx context.Context, location string) error
+}
+
+// NewStorage returns the storage BACKUP_STORAGE selects. shared is the
+// object store configured with STORAGE_DRIVER.
+func NewStorage(cfg *config.Config, shared storage.Store) (Storage, error) {
+	switch cfg.BackupStorage {
+	case "shared":
+		return &SharedStorage{Store: shared}, nil
+	case "s3":
+		endpoint := cfg.BackupS3Endpoint
+		if endpoint == "" {
+			endpoint = "https://s3." + cfg.BackupS3Region + ".amazonaws.com"
+		}
+		return &BucketStorage{Bucket: &storage.S3{
+			Endpoint:        endpoint,
+			Region:          cfg.BackupS3Region,
+			Bucket:          cfg.BackupS3Bucket,
+			Prefix:          cfg.BackupS3Prefix,
+			AccessKeyID:     cfg.BackupS3AccessKeyID,
+			SecretAccessKey: cfg.BackupS3SecretAccessKey,
+		}}, nil
+	default:
+		return NewLocalStorage(cfg.BackupDir)
+	}
+}
+
+// LocalStorage keeps dumps as files in a directory, which may be a mounted
+// volume or network share.
+type LocalStorage struct {
+	Dir string
+}
+
+// NewLocalStorage creates dir if needed.
+func NewLocalStorage(dir string) (*LocalStorage, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	return &LocalStorage{Dir: dir}, nil
+}
+
+func (s *LocalStorage) Name() string {
+	return "local"
+}
+
+// Put writes the dump under a temporary name and renames it once complete,
+// so a file named like a dump is never a partial one.
+func (s *LocalStorage) Put(ctx context.Context, key string, r io.Reader, size int64, checksum string) (string, error) {
+	if strings.ContainsAny(key, `/\`) {
+		return "", fmt.Errorf("invalid backup key %q", key)
+	}
+	path := filepath.Join(s.Dir, key)
+	partial := path + ".partial"
+
+	file, err := os.OpenFile(partial, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return "", fmt.Errorf("failed to create backup file: %w", err)
+	}
+	written, err := io.Copy(file, r)
+	if err == nil && written != size {
+		err = fmt.Errorf("wrote %d of %d bytes", written, size)
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(partial)
+		return "", fmt.Errorf("failed to write backup file: %w", err)
+	}
+
+	if err := os.Rename(partial, path); err != nil {
+		os.Remove(partial)
+		return "", fmt.Errorf("failed to store backup file: %w", err)
+	}
+	return path, nil
+}
+
+func (s *LocalStorage) Open(ctx context.Context, location string) (io.ReadCloser, error) {
+	if err := s.contains(location); err != nil {
+		return nil, err
+	}
+	file, err := os.Open(location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open backup file: %w", err)
+	}
+	return file, nil
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, location string) error {
+	if err := s.contains(location); err != nil {
+		return err
+	}
+	if err := os.Remove(location); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete backup file: %w", err)
+	}
+	return nil
+}
+
+// contains checks that location is a file in the backup directory.
+func (s *LocalStorage) contains(location string) error {
+	if filepath.Dir(filepath.Clean(location)) != filepath.Clean(s.Dir) {
+		return fmt.Errorf("backup %s is not in %s", location, s.Dir)
+	}
+	return nil
+}
+
+// SharedStorage keeps dumps under backups/ in the object store the rest of
+// the system uses, with their object keys as locations.
+type SharedStorage struct {
+	Store storage.Store
+}
+
+func (s *SharedStorage) Name() string {
+	return "shared"
+}
+
+func (s *SharedStorage) Put(ctx context.Context, key string, r io.Reader, size int64, checksum string) (string, error) {
+	if strings.Contains(key, "/") {
+		return "", fmt.Errorf("invalid backup key %q", key)
+	}
+	location := storage.PrefixBackups + key
+	if err := s.Store.Put(ctx, location, r, size, "application/octet-stream"); err != nil {
+		return "", fmt.Errorf("failed to store backup: %w", err)
+	}
+	return location, nil
+}
+
+func (s *SharedStorage) Open(ctx context.Context, location string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(location, storage.PrefixBackups) {
+		return nil, fmt.Errorf("backup %s is not in %s", location, storage.PrefixBackups)
+	}
+	return s.Store.Open(ctx, location)
+}
+
+func (s *SharedStorage) Delete(ctx context.Context, location string) error {
+	if !strings.HasPrefix(location, storage.PrefixBackups) {
+		return fmt.Errorf("backup %s is not in %s", location, storage.PrefixBackups)
+	}
+	return s.Store.Delete(ctx, location)
+}
+
+// BucketStorage keeps dumps under the prefix of a bucket of their own, on
+// S3 or a service with its API, with s3://bucket/key locations.
+type BucketStorage struct {
+	Bucket *storage.S3
+}
+
+func (s *BucketStorage) Name() string {
+	return "s3"
+}
+
+func (s *BucketStorage) Put(ctx context.Context, key string, r io.Reader, size int64, checksum string) (string, error) {
+	if strings.Contains(key, "/") {
+		return "", fmt.Errorf("invalid backup key %q", key)
+	}
+	if err := s.Bucket.Put(ctx, key, r, size, "application/octet-stream"); err != nil {
+		return "", fmt.Errorf("failed to store backup: %w", err)
+	}
+	objectKey := key
+	if prefix := strings.Trim(s.Bucket.Prefix, "/"); prefix != "" {
+		objectKey = prefix + "/" + key
+	}
+	return "s3://" + s.Bucket.Bucket + "/" + objectKey, nil
+}
+
+func (s *BucketStorage) Open(ctx context.Context, location string) (io.ReadCloser, error) {
+	bucket, objectKey, err := s.object(location)
+	if err != nil {
+		return nil, err
+	}
+	return bucket.Open(ctx, objectKey)
+}
+
+func (s *BucketStorage) Delete(ctx context.Context, location string) error {
+	bucket, objectKey, err := s.object(location)
+	if err != nil {
+		return err
+	}
+	return bucket.Delete(ctx, objectKey)
+}
+
+// object returns the bucket without its prefix and the key of the object
+// at an s3://bucket/key location, so dumps stored before the prefix changed
+// are found too.
+func (s *BucketStorage) object(location string) (*storage.S3, string, error) {
+	parsed, err := url.Parse(location)
+	if err != nil || parsed.Scheme != "s3" || parsed.Host != s.Bucket.Bucket {
+		return nil, "", fmt.Errorf("backup %s is not in bucket %s", location, s.Bucket.Bucket)
+	}
+	bucket := *s.Bucket
+	bucket.Prefix = ""
+	return &bucket, strings.TrimPrefix(parsed.Path, "/"), nil
+}
@@ -0,0 +1,66 @@
+// Package buildinfo identifies the running build and how long it has been
+// up. Version, Commit and BuildTime are set when building:
+//
+//	go build -ldflags "-X rtims-backend/internal/buildinfo.Version=1.4.0 -X rtims-backend/internal/buildinfo.Commit=$(git rev-parse HEAD)"
+//
+// Without them, the commit and build time recorded by the Go toolchain are
+// used when there are any.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+var (
+	Version   = "1.0.0"
+	Commit    = ""
+	BuildTime = ""
+)
+
+// started is when the process started, near enough: package variables are
+// initialized before main runs.
+var started = time.Now()
+
+// Info describes the running build.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+	GoVersion string `json:"go_version"`
+	// Modified is set for builds from a checkout with uncommitted changes.
+	Modified bool `json:"modified,omitempty"`
+}
+
+// Get returns the build's Info.
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, BuildTime: BuildTime, GoVersion: runtime.Version()}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildTime == "" {
+					info.BuildTime = setting.Value
+				}
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+	return info
+}
+
+// Started returns when the process started.
+func Started() time.Time {
+	return started
+}
+
+// Uptime returns how long the process has been running.
+func Uptime() time.Duration {
+	return time.Since(started)
+}
@@ -0,0 +1,21 @@
+package buildinfo
+
+import (
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	defer func(version, commit string) { Version, Commit = version, commit }(Version, Commit)
+	Version, Commit = "2.3.0", "abc123"
+
+	info := Get()
+	if info.Version != "2.3.0" || info.Commit != "abc123" || info.GoVersion == "" {
+		t.Errorf("expected the linked version and commit, got %+v", info)
+	}
+}
+
+func TestUptime(t *testing.T) {
+	if Uptime() <= 0 || Started().IsZero() {
+		t.Errorf("expected the process start to be recorded, got %v", Started())
+	}
+}
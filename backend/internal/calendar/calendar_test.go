@@ -0,0 +1,137 @@
+package calendar
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestEscape(t *testing.T) {
+	got := escape("Stocktake; aisle 3, 4 \\ back\nbring scanners")
+	want := `Stocktake\; aisle 3\, 4 \\ back\nbring scanners`
+	if got != want {
+		t.Errorf("escape = %q, want %q", got, want)
+	}
+}
+
+func TestWriteLine(t *testing.T) {
+	var buf bytes.Buffer
+	line := "DESCRIPTION:" + strings.Repeat("Gudang Surabaya ü ", 12)
+	writeLine(&buf, line)
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n")
+	if len(lines) < 3 {
+		t.Fatalf("expected the line to be folded, got %q", buf.String())
+	}
+	var unfolded string
+	for i, folded := range lines {
+		if len(folded) > maxLineOctets {
+			t.Errorf("line %d is %d octets", i, len(folded))
+		}
+		if !utf8.ValidString(folded) {
+			t.Errorf("line %d splits a character: %q", i, folded)
+		}
+		if i > 0 {
+			if !strings.HasPrefix(folded, " ") {
+				t.Errorf("line %d does not continue with a space: %q", i, folded)
+			}
+			folded = folded[1:]
+		}
+		unfolded += folded
+	}
+	if unfolded != line {
+		t.Errorf("unfolded %q, want %q", unfolded, line)
+	}
+}
+
+func TestRender(t *testing.T) {
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	wib := time.FixedZone("WIB", 7*60*60)
+	body := string(Render("RTIMS", []Event{{
+		UID:        "a@rtims",
+		Summary:    "Stocktake, aisle 3",
+		Categories: "Stocktake",
+		Start:      time.Date(2025, 3, 29, 8, 0, 0, 0, wib),
+		End:        time.Date(2025, 3, 29, 17, 0, 0, 0, wib),
+	}}, now))
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\n",
+		"PRODID:" + prodID + "\r\n",
+		"BEGIN:VEVENT\r\nUID:a@rtims\r\nDTSTAMP:20250301T090000Z\r\n",
+		"DTSTART:20250329T010000Z\r\nDTEND:20250329T100000Z\r\n",
+		"SUMMARY:Stocktake\\, aisle 3\r\n",
+		"END:VEVENT\r\nEND:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected the calendar to contain %q, got\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "DESCRIPTION") {
+		t.Error("expected no DESCRIPTION for an event without one")
+	}
+}
+
+func TestToken(t *testing.T) {
+	feed := NewFeed(nil, "s3cret")
+	userID := uuid.New()
+	const userSecret = "user-secret"
+
+	token := feed.token(userID, userSecret)
+	if got, signature, err := parse(token); err != nil || got != userID || !feed.check(got, signature, userSecret) {
+		t.Errorf("expected %q to be valid for %s, got %s, %v", token, userID, got, err)
+	}
+
+	for _, token := range []string{
+		"",
+		userID.String(),
+		userID.String() + ".",
+		uuid.New().String() + token[strings.Index(token, "."):],
+		NewFeed(nil, "other").token(userID, userSecret),
+		// Regenerated since
+		feed.token(userID, "old-secret"),
+	} {
+		got, signature, err := parse(token)
+		if err == nil && got == userID && feed.check(got, signature, userSecret) {
+			t.Errorf("expected %q to be invalid", token)
+		}
+	}
+
+	// A user who never asked for the feed has no valid token
+	if feed.check(userID, feed.sign(userID, ""), "") {
+		t.Error("expected a user without a secret to have no valid token")
+	}
+}
+
+func TestRuns(t *testing.T) {
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	schedule := models.ReportSchedule{ID: uuid.New(), Name: "Weekly stock", CronExpression: "0 8 * * 1", IsActive: true}
+
+	events := runs(schedule, now, now.Add(futureWindow))
+	if len(events) != 13 {
+		t.Fatalf("expected 13 weekly runs in 90 days, got %d", len(events))
+	}
+	if first := events[0]; !first.Start.Equal(time.Date(2025, 3, 3, 8, 0, 0, 0, time.UTC)) || first.End.Sub(first.Start) != runDuration ||
+		first.Summary != "Report: Weekly stock" {
+		t.Errorf("unexpected first run %+v", first)
+	}
+	if events[0].UID == events[1].UID {
+		t.Error("expected every run to have its own UID")
+	}
+
+	schedule.CronExpression = "* * * * *"
+	if events := runs(schedule, now, now.Add(futureWindow)); len(events) != maxRuns {
+		t.Errorf("expected %d runs at most, got %d", maxRuns, len(events))
+	}
+
+	schedule.IsActive = false
+	if events := runs(schedule, now, now.Add(futureWindow)); len(events) != 0 {
+		t.Errorf("expected no runs of an inactive schedule, got %d", len(events))
+	}
+}
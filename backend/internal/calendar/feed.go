@@ -0,0 +1,233 @@
+package calendar
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/models"
+	"rtims-backend/internal/reports"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// The feed holds the events from pastWindow ago to futureWindow ahead.
+	pastWindow   = 30 * 24 * time.Hour
+	futureWindow = 90 * 24 * time.Hour
+	// maxRuns is the most runs of a report schedule the feed holds, so a
+	// schedule running every minute does not flood calendars.
+	maxRuns = 50
+	// runDuration is how long a report run is shown to take.
+	runDuration = 15 * time.Minute
+)
+
+// ErrInvalidToken is returned for a feed token that was not signed by this
+// server, was regenerated since, or whose user or organization is
+// deactivated.
+var ErrInvalidToken = errors.New("invalid calendar feed token")
+
+// Feed builds the calendar feeds of users.
+type Feed struct {
+	calendarService       *database.CalendarService
+	reportScheduleService *database.ReportScheduleService
+	userService           *database.UserService
+	organizationService   *database.OrganizationService
+	secret                []byte
+}
+
+// NewFeed returns a Feed whose tokens are signed with a key derived from
+// secret, the JWT secret, and the calendar feed secret of their user.
+func NewFeed(db *sql.DB, secret string) *Feed {
+	key := hmac.New(sha256.New, []byte(secret))
+	key.Write([]byte("calendar feeds"))
+	return &Feed{
+		calendarService:       database.NewCalendarService(db),
+		reportScheduleService: database.NewReportScheduleService(db),
+		userService:           database.NewUserService(db),
+		organizationService:   database.NewOrganizationService(db),
+		secret:                key.Sum(nil),
+	}
+}
+
+// Token returns the token of a user's feed, creating the user's feed
+// secret the first time. It does not expire; the feed stops working when
+// the token is regenerated or the user or their organization is
+// deactivated.
+func (f *Feed) Token(ctx context.Context, userID uuid.UUID) (string, error) {
+	userSecret, err := f.userService.EnsureCalendarFeedSecret(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	return f.token(userID, userSecret), nil
+}
+
+// Regenerate replaces the user's feed secret, revoking the token given
+// out before, and returns the new token.
+func (f *Feed) Regenerate(ctx context.Context, userID uuid.UUID) (string, error) {
+	userSecret, err := f.userService.RegenerateCalendarFeedSecret(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	return f.token(userID, userSecret), nil
+}
+
+func (f *Feed) token(userID uuid.UUID, userSecret string) string {
+	return userID.String() + "." + f.sign(userID, userSecret)
+}
+
+func (f *Feed) sign(userID uuid.UUID, userSecret string) string {
+	mac := hmac.New(sha256.New, f.secret)
+	mac.Write([]byte(userID.String() + "." + userSecret))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// parse returns the user and signature of a token.
+func parse(token string) (uuid.UUID, string, error) {
+	id, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return uuid.Nil, "", ErrInvalidToken
+	}
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, "", ErrInvalidToken
+	}
+	return userID, signature, nil
+}
+
+// check reports whether signature was made for userID with userSecret. A
+// user without a secret has never been given a token.
+func (f *Feed) check(userID uuid.UUID, signature, userSecret string) bool {
+	return userSecret != "" && hmac.Equal([]byte(signature), []byte(f.sign(userID, userSecret)))
+}
+
+// Verify returns the user a token was signed for, or ErrInvalidToken.
+func (f *Feed) Verify(ctx context.Context, token string) (uuid.UUID, error) {
+	userID, signature, err := parse(token)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	userSecret, err := f.userService.GetCalendarFeedSecret(ctx, userID)
+	if errors.Is(err, database.ErrNotFound) {
+		return uuid.Nil, ErrInvalidToken
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if !f.check(userID, signature, userSecret) {
+		return uuid.Nil, ErrInvalidToken
+	}
+	return userID, nil
+}
+
+// Render returns the iCalendar document of the feed of token: the
+// stocktakes and maintenance windows of the user's organization and, for
+// admins, the runs of its report schedules.
+func (f *Feed) Render(ctx context.Context, token string, now time.Time) ([]byte, error) {
+	userID, err := f.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	ctx, user, err := f.account(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	from, to := now.Add(-pastWindow), now.Add(futureWindow)
+	calendarEvents, err := f.calendarService.GetCalendarEvents(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]Event, 0, len(calendarEvents))
+	for _, event := range calendarEvents {
+		events = append(events, Event{
+			UID:         event.ID.String() + "@rtims",
+			Summary:     event.Title,
+			Description: event.Description,
+			Categories:  categories[event.Kind],
+			Start:       event.StartsAt,
+			End:         event.EndsAt,
+			Updated:     event.UpdatedAt,
+		})
+	}
+
+	if user.Role == models.RoleAdmin {
+		schedules, err := f.reportScheduleService.GetSchedules(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, schedule := range schedules {
+			events = append(events, runs(schedule, now, to)...)
+		}
+	}
+
+	return Render("RTIMS", events, now), nil
+}
+
+// categories are the CATEGORIES of the kinds of calendar events.
+var categories = map[models.CalendarEventKind]string{
+	models.CalendarStocktake:   "Stocktake",
+	models.CalendarMaintenance: "Maintenance",
+}
+
+// runs returns the runs of an active schedule from now until to, at most
+// maxRuns of them.
+func runs(schedule models.ReportSchedule, now, to time.Time) []Event {
+	if !schedule.IsActive {
+		return nil
+	}
+	cron, err := reports.ParseCron(schedule.CronExpression)
+	if err != nil {
+		log.Printf("Calendar feed skipped report schedule %s: %v", schedule.ID, err)
+		return nil
+	}
+
+	var events []Event
+	for next := cron.Next(now); !next.IsZero() && next.Before(to) && len(events) < maxRuns; next = cron.Next(next) {
+		events = append(events, Event{
+			UID:        fmt.Sprintf("report-%s-%d@rtims", schedule.ID, next.Unix()),
+			Summary:    "Report: " + schedule.Name,
+			Categories: "Report",
+			Start:      next,
+			End:        next.Add(runDuration),
+			Updated:    schedule.UpdatedAt,
+		})
+	}
+	return events
+}
+
+// account returns ctx scoped to the organization of the user, or
+// ErrInvalidToken when the user or organization is deactivated.
+func (f *Feed) account(ctx context.Context, userID uuid.UUID) (context.Context, *models.User, error) {
+	user, err := f.userService.GetUser(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if !user.IsActive {
+		return nil, nil, ErrInvalidToken
+	}
+
+	organizationID := user.OrganizationID
+	if organizationID == uuid.Nil {
+		organizationID = database.DefaultOrganizationID
+	}
+	organization, err := f.organizationService.GetOrganization(ctx, organizationID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !organization.IsActive {
+		return nil, nil, ErrInvalidToken
+	}
+	return database.WithOrganization(ctx, organizationID), user, nil
+}
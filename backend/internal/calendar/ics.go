@@ -0,0 +1,101 @@
+// Package calendar publishes the scheduled stocktakes, maintenance windows
+// and report runs of an organization as an iCalendar (RFC 5545) feed that
+// calendar apps subscribe to with a signed link.
+package calendar
+
+import (
+	"bytes"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	prodID = "-//RTIMS//Inventory Calendar//EN"
+	// refreshInterval is how often calendar apps are asked to fetch the
+	// feed again.
+	refreshInterval = "PT1H"
+	// maxLineOctets is the longest a content line may be before it is
+	// folded, not counting its CRLF.
+	maxLineOctets = 75
+
+	icsTime = "20060102T150405Z"
+)
+
+// Event is an event of the feed.
+type Event struct {
+	// UID stays the same across fetches so calendar apps update the event
+	// instead of adding it again.
+	UID         string
+	Summary     string
+	Description string
+	Categories  string
+	Start       time.Time
+	End         time.Time
+	Updated     time.Time
+}
+
+// Render returns the iCalendar document of events, named name in calendar
+// apps.
+func Render(name string, events []Event, now time.Time) []byte {
+	var buf bytes.Buffer
+	line := func(name, value string) {
+		writeLine(&buf, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", prodID)
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	line("X-WR-CALNAME", escape(name))
+	line("REFRESH-INTERVAL;VALUE=DURATION", refreshInterval)
+	line("X-PUBLISHED-TTL", refreshInterval)
+	for _, event := range events {
+		stamp := event.Updated
+		if stamp.IsZero() {
+			stamp = now
+		}
+		line("BEGIN", "VEVENT")
+		line("UID", escape(event.UID))
+		line("DTSTAMP", stamp.UTC().Format(icsTime))
+		line("DTSTART", event.Start.UTC().Format(icsTime))
+		line("DTEND", event.End.UTC().Format(icsTime))
+		line("SUMMARY", escape(event.Summary))
+		if event.Description != "" {
+			line("DESCRIPTION", escape(event.Description))
+		}
+		if event.Categories != "" {
+			line("CATEGORIES", escape(event.Categories))
+		}
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+	return buf.Bytes()
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// escape escapes a TEXT value.
+func escape(value string) string {
+	return escaper.Replace(value)
+}
+
+// writeLine writes a content line, folded into lines of at most
+// maxLineOctets octets without splitting a UTF-8 character.
+func writeLine(buf *bytes.Buffer, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		buf.WriteString(line[:cut])
+		buf.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines start with the space
+		limit = maxLineOctets - 1
+	}
+	buf.WriteString(line)
+	buf.WriteString("\r\n")
+}
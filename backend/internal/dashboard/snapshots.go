@@ -0,0 +1,45 @@
+package dashboard
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/models"
+)
+
+// MaxTrendDays caps how far back a trend may reach.
+const MaxTrendDays = 365
+
+var (
+	ErrUnknownMetric = errors.New("metric must be one of stock_value, movements or low_stock")
+	ErrInvalidPeriod = errors.New("period must be a number of days between 1d and 365d, e.g. 30d")
+)
+
+// ParseMetric checks that metric names a supported trend.
+func ParseMetric(metric string) (models.TrendMetric, error) {
+	switch m := models.TrendMetric(metric); m {
+	case models.TrendStockValue, models.TrendMovements, models.TrendLowStock:
+		return m, nil
+	}
+	return "", ErrUnknownMetric
+}
+
+// ParsePeriod parses a period such as "30d" into a number of days.
+func ParsePeriod(period string) (int, error) {
+	days, err := strconv.Atoi(strings.TrimSuffix(period, "d"))
+	if err != nil || !strings.HasSuffix(period, "d") || days < 1 || days > MaxTrendDays {
+		return 0, ErrInvalidPeriod
+	}
+	return days, nil
+}
+
+// RecordSnapshot records or overwrites today's dashboard snapshot. Run it
+// through the day, last shortly before midnight, so the day's row ends up
+// holding its closing figures.
+func RecordSnapshot(db *sql.DB) error {
+	return database.NewSnapshotService(db).RecordSnapshot(time.Now())
+}
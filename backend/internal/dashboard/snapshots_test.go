@@ -0,0 +1,37 @@
+package dashboard
+
+import "testing"
+
+func TestParsePeriod(t *testing.T) {
+	tests := []struct {
+		period   string
+		days     int
+		expected error
+	}{
+		{"30d", 30, nil},
+		{"1d", 1, nil},
+		{"365d", 365, nil},
+		{"0d", 0, ErrInvalidPeriod},
+		{"366d", 0, ErrInvalidPeriod},
+		{"30", 0, ErrInvalidPeriod},
+		{"4w", 0, ErrInvalidPeriod},
+		{"", 0, ErrInvalidPeriod},
+	}
+	for _, tt := range tests {
+		days, err := ParsePeriod(tt.period)
+		if err != tt.expected || days != tt.days {
+			t.Errorf("%q: expected %d, %v, got %d, %v", tt.period, tt.days, tt.expected, days, err)
+		}
+	}
+}
+
+func TestParseMetric(t *testing.T) {
+	for _, metric := range []string{"stock_value", "movements", "low_stock"} {
+		if _, err := ParseMetric(metric); err != nil {
+			t.Errorf("%s: unexpected error %v", metric, err)
+		}
+	}
+	if _, err := ParseMetric("revenue"); err != ErrUnknownMetric {
+		t.Errorf("expected ErrUnknownMetric, got %v", err)
+	}
+}
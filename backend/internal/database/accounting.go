@@ -0,0 +1,212 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrPeriodExported is returned when exporting a period that ends no later
+// than the organization's last export.
+var ErrPeriodExported = errors.New("period already exported")
+
+const accountingExportColumns = `id, organization_id, format, period_start, period_end, journals, debits, filename, created_by, created_at`
+
+// movementPrice is the price of a movement's product at the time of the
+// movement: the price it was last changed to before then, or, if it has
+// not been changed since, its current price.
+const movementPrice = `COALESCE(
+	(SELECT pc.new_price FROM product_price_changes pc
+		WHERE pc.product_id = sm.product_id AND pc.changed_at <= sm.created_at ORDER BY pc.changed_at DESC LIMIT 1),
+	(SELECT pc.old_price FROM product_price_changes pc
+		WHERE pc.product_id = sm.product_id AND pc.changed_at > sm.created_at ORDER BY pc.changed_at LIMIT 1),
+	p.price)`
+
+func scanAccountingExport(row interface{ Scan(...interface{}) error }, e *models.AccountingExport, extra ...interface{}) error {
+	var periodStart sql.NullTime
+	var createdBy uuid.NullUUID
+
+	dest := append([]interface{}{&e.ID, &e.OrganizationID, &e.Format, &periodStart, &e.PeriodEnd, &e.Journals, &e.Debits,
+		&e.Filename, &createdBy, &e.CreatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+
+	if periodStart.Valid {
+		e.PeriodStart = &periodStart.Time
+	}
+	if createdBy.Valid {
+		e.CreatedBy = &createdBy.UUID
+	}
+	return nil
+}
+
+// AccountingService stores accounting exports and reads the postings they
+// are made of.
+type AccountingService struct {
+	db *sql.DB
+}
+
+func NewAccountingService(db *sql.DB) *AccountingService {
+	return &AccountingService{db: db}
+}
+
+// CreateAccountingExport exports the period of export's organization from
+// the end of its last export to export.PeriodEnd. render is given the
+// postings of the period to fill in the export's content, journals and
+// debits. Exports of one organization run one at a time, and the export
+// locks the period as it is saved.
+func (s *AccountingService) CreateAccountingExport(ctx context.Context, export *models.AccountingExport,
+	render func(export *models.AccountingExport, postings []models.AccountingPosting) error) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var locked string
+	err = tx.QueryRowContext(ctx, `SELECT id FROM organizations WHERE id = $1 FOR UPDATE`, export.OrganizationID).Scan(&locked)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("organization %w", ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock organization: %w", err)
+	}
+
+	var periodStart sql.NullTime
+	if err := tx.QueryRowContext(ctx, `SELECT MAX(period_end) FROM accounting_exports WHERE organization_id = $1`,
+		export.OrganizationID).Scan(&periodStart); err != nil {
+		return fmt.Errorf("failed to get last accounting export: %w", err)
+	}
+	export.PeriodStart = nil
+	if periodStart.Valid {
+		if !export.PeriodEnd.After(periodStart.Time) {
+			return ErrPeriodExported
+		}
+		export.PeriodStart = &periodStart.Time
+	}
+
+	postings, err := accountingPostings(ctx, tx, export)
+	if err != nil {
+		return err
+	}
+	if err := render(export, postings); err != nil {
+		return err
+	}
+
+	export.ID = uuid.New()
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO accounting_exports (id, organization_id, format, period_start, period_end, journals, debits, filename, content, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING created_at`,
+		export.ID, export.OrganizationID, export.Format, export.PeriodStart, export.PeriodEnd, export.Journals, export.Debits,
+		export.Filename, export.Content, export.CreatedBy).Scan(&export.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save accounting export: %w", err)
+	}
+	return tx.Commit()
+}
+
+// accountingPostings sums the value of the period's movements, by day and
+// reason, and of its price changes, by day, in UTC.
+func accountingPostings(ctx context.Context, tx *sql.Tx, export *models.AccountingExport) ([]models.AccountingPosting, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT date_trunc('day', sm.created_at AT TIME ZONE 'UTC') AS day, sm.reason AS kind,
+		       SUM(sm.change * `+movementPrice+`), COUNT(*)
+		FROM stock_movements sm JOIN products p ON p.id = sm.product_id
+		WHERE sm.organization_id = $1 AND ($2::timestamptz IS NULL OR sm.created_at >= $2) AND sm.created_at < $3
+		GROUP BY 1, 2
+		UNION ALL
+		SELECT date_trunc('day', pc.changed_at AT TIME ZONE 'UTC'), $4::text, SUM(pc.stock * (pc.new_price - pc.old_price)), COUNT(*)
+		FROM product_price_changes pc
+		WHERE pc.organization_id = $1 AND ($2::timestamptz IS NULL OR pc.changed_at >= $2) AND pc.changed_at < $3
+		GROUP BY 1
+		ORDER BY day, kind`,
+		export.OrganizationID, export.PeriodStart, export.PeriodEnd, models.PostingRevaluation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accounting postings: %w", err)
+	}
+	defer rows.Close()
+
+	var postings []models.AccountingPosting
+	for rows.Next() {
+		var posting models.AccountingPosting
+		if err := rows.Scan(&posting.Date, &posting.Kind, &posting.Value, &posting.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan accounting posting: %w", err)
+		}
+		posting.Date = time.Date(posting.Date.Year(), posting.Date.Month(), posting.Date.Day(), 0, 0, 0, 0, time.UTC)
+		postings = append(postings, posting)
+	}
+	return postings, rows.Err()
+}
+
+// LockedUntil returns the end of the period last exported to accounting by
+// ctx's organization, before which stock movements cannot be dated, or nil
+// if it has exported none.
+func (s *AccountingService) LockedUntil(ctx context.Context) (*time.Time, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var lockedUntil sql.NullTime
+	err := s.db.QueryRowContext(ctx, `SELECT MAX(period_end) FROM accounting_exports
+		WHERE ($1::uuid IS NULL OR organization_id = $1)`, organizationArg(ctx)).Scan(&lockedUntil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last accounting export: %w", err)
+	}
+	if !lockedUntil.Valid {
+		return nil, nil
+	}
+	return &lockedUntil.Time, nil
+}
+
+// GetAccountingExports returns the exports of ctx's organization, newest
+// first, without their content.
+func (s *AccountingService) GetAccountingExports(ctx context.Context) ([]models.AccountingExport, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+accountingExportColumns+` FROM accounting_exports
+		WHERE ($1::uuid IS NULL OR organization_id = $1)
+		ORDER BY period_end DESC`, organizationArg(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accounting exports: %w", err)
+	}
+	defer rows.Close()
+
+	exports := []models.AccountingExport{}
+	for rows.Next() {
+		var export models.AccountingExport
+		if err := scanAccountingExport(rows, &export); err != nil {
+			return nil, fmt.Errorf("failed to scan accounting export: %w", err)
+		}
+		exports = append(exports, export)
+	}
+	return exports, rows.Err()
+}
+
+// GetAccountingExport returns an export of ctx's organization with its
+// content.
+func (s *AccountingService) GetAccountingExport(ctx context.Context, id uuid.UUID) (*models.AccountingExport, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var export models.AccountingExport
+	row := s.db.QueryRowContext(ctx, `SELECT `+accountingExportColumns+`, content FROM accounting_exports
+		WHERE id = $1 AND ($2::uuid IS NULL OR organization_id = $2)`, id, organizationArg(ctx))
+	if err := scanAccountingExport(row, &export, &export.Content); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("accounting export %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get accounting export: %w", err)
+	}
+	return &export, nil
+}
@@ -0,0 +1,188 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// activityFeedSource unions the entries of the activity feed: the audit
+// entries handlers wrote for products and categories, and stock movements.
+// Entries the audit middleware wrote carry a status code and no details, so
+// they are left out, as are product updates of the stock alone, which
+// their stock movement covers.
+const activityFeedSource = `
+	SELECT a.id, CASE a.table_name WHEN 'products' THEN 'product' ELSE 'category' END AS entity_type,
+	       a.record_id AS entity_id, a.action, CASE WHEN a.table_name = 'products' THEN a.record_id END AS product_id,
+	       a.old_values, a.new_values, 0 AS change, '' AS reason,
+	       a.changed_by AS user_id, a.changed_at AS occurred_at,
+	       COALESCE(p.name, '') AS product_name, COALESCE(p.sku, '') AS product_sku, a.organization_id
+	FROM audit_logs a LEFT JOIN products p ON a.table_name = 'products' AND p.id = a.record_id
+	WHERE a.table_name IN ('products', 'categories') AND a.action IN ('create', 'update', 'delete')
+	AND a.status_code IS NULL
+	AND NOT (a.table_name = 'products' AND a.action = 'update' AND COALESCE(a.new_values, '{}') - 'stock' = '{}')
+	UNION ALL
+	SELECT m.id, 'stock_movement', m.id, 'create', m.product_id,
+	       NULL, NULL, m.change, m.reason,
+	       m.created_by, m.created_at,
+	       p.name, p.sku, m.organization_id
+	FROM stock_movements m JOIN products p ON p.id = m.product_id`
+
+// activityFeedConditions filter activityFeedSource, aliased feed, by
+// organization, entity type, entity, user and period, $1 to $6.
+const activityFeedConditions = `
+	($1::uuid IS NULL OR feed.organization_id = $1)
+	AND ($2::text IS NULL OR feed.entity_type = $2)
+	AND ($3::uuid IS NULL OR feed.entity_id = $3 OR feed.product_id = $3)
+	AND ($4::uuid IS NULL OR feed.user_id = $4)
+	AND ($5::timestamptz IS NULL OR feed.occurred_at >= $5)
+	AND ($6::timestamptz IS NULL OR feed.occurred_at <= $6)`
+
+const activityFeedColumns = `feed.id, feed.entity_type, feed.entity_id, feed.action, feed.product_id,
+	feed.old_values, feed.new_values, feed.change, feed.reason, feed.user_id, COALESCE(u.name, ''),
+	feed.occurred_at, feed.product_name, feed.product_sku`
+
+func scanFeedEntry(row interface{ Scan(...interface{}) error }, e *models.FeedEntry, extra ...interface{}) error {
+	var productID uuid.NullUUID
+	var oldValues, newValues []byte
+
+	dest := []interface{}{&e.ID, &e.EntityType, &e.EntityID, &e.Action, &productID,
+		&oldValues, &newValues, &e.Change, &e.Reason, &e.UserID, &e.UserName,
+		&e.OccurredAt, &e.Name, &e.SKU}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+
+	if productID.Valid {
+		e.ProductID = &productID.UUID
+	}
+	describeChange(e, unmarshalAuditValues(oldValues), unmarshalAuditValues(newValues))
+	return nil
+}
+
+// describeChange fills in the name of the product or category an audit
+// entry changed, what the change was, and for products moved to another
+// category that category.
+func describeChange(e *models.FeedEntry, oldValues, newValues map[string]interface{}) {
+	if e.EntityType == models.FeedStockMovement {
+		return
+	}
+
+	values := newValues
+	if e.Action == models.ActionDelete {
+		values = oldValues
+	}
+	// A product's current name is joined in, and only its audit entry
+	// has the name of a deleted product
+	if name, ok := values["name"].(string); ok && (e.Name == "" || e.Action != models.ActionUpdate) {
+		e.Name = name
+	}
+	if sku, ok := values["sku"].(string); ok && e.SKU == "" {
+		e.SKU = sku
+	}
+	if e.Action != models.ActionUpdate {
+		return
+	}
+
+	for field, value := range newValues {
+		if old, ok := oldValues[field]; !ok || !reflect.DeepEqual(old, value) {
+			e.ChangedFields = append(e.ChangedFields, field)
+		}
+	}
+	sort.Strings(e.ChangedFields)
+
+	oldName, _ := oldValues["name"].(string)
+	newName, _ := newValues["name"].(string)
+	if oldName != "" && newName != "" && oldName != newName {
+		e.Name, e.NewName = oldName, newName
+	}
+	if len(e.ChangedFields) == 1 && e.ChangedFields[0] == "category" && e.EntityType == models.FeedProduct {
+		e.Category, _ = newValues["category"].(string)
+	}
+}
+
+// GetActivityFeed returns the entries of the activity feed of the
+// organization of ctx that filter selects, newest first, and how many
+// there are. Messages are left to the caller.
+func (s *AuditService) GetActivityFeed(ctx context.Context, filter models.ActivityFeedFilter) ([]models.FeedEntry, int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	// The feed reads far back into the audit history, so it runs on the
+	// read replica
+	db := ForReads(s.db)
+
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	args := []interface{}{organizationArg(ctx), filter.EntityType, filter.EntityID, filter.UserID,
+		filter.StartDate, filter.EndDate}
+
+	query := `SELECT ` + activityFeedColumns + `, ` + totalColumn + `
+		FROM (` + activityFeedSource + `) feed LEFT JOIN users u ON u.id = feed.user_id
+		WHERE ` + activityFeedConditions + `
+		ORDER BY feed.occurred_at DESC, feed.id
+		LIMIT $7 OFFSET $8`
+	rows, err := db.QueryContext(ctx, query, append(args, filter.Limit, (filter.Page-1)*filter.Limit)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get activity feed: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.FeedEntry{}
+	var total int
+	for rows.Next() {
+		var entry models.FeedEntry
+		if err := scanFeedEntry(rows, &entry, &total); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan activity feed: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to get activity feed: %w", err)
+	}
+
+	if len(entries) == 0 {
+		countQuery := `SELECT COUNT(*) FROM (` + activityFeedSource + `) feed WHERE ` + activityFeedConditions
+		if total, err = pastEndTotal(ctx, db, filter.Page, countQuery, args...); err != nil {
+			return nil, 0, fmt.Errorf("failed to count activity feed: %w", err)
+		}
+	}
+	return entries, total, nil
+}
+
+// GetActivityFeedSince returns up to limit entries of the activity feed of
+// the organization of ctx that occurred after since, oldest first.
+func (s *AuditService) GetActivityFeedSince(ctx context.Context, since time.Time, limit int) ([]models.FeedEntry, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	// Entries are pushed as they happen, so the primary is read rather
+	// than a replica that may lag behind
+	query := `SELECT ` + activityFeedColumns + `
+		FROM (` + activityFeedSource + `) feed LEFT JOIN users u ON u.id = feed.user_id
+		WHERE ` + activityFeedConditions + ` AND feed.occurred_at > $7
+		ORDER BY feed.occurred_at, feed.id
+		LIMIT $8`
+	rows, err := s.db.QueryContext(ctx, query, organizationArg(ctx), nil, nil, nil, nil, nil, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get activity feed: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.FeedEntry{}
+	for rows.Next() {
+		var entry models.FeedEntry
+		if err := scanFeedEntry(rows, &entry); err != nil {
+			return nil, fmt.Errorf("failed to scan activity feed: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
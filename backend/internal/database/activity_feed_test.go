@@ -0,0 +1,43 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+
+	"rtims-backend/internal/models"
+)
+
+func TestDescribeChange(t *testing.T) {
+	// A rename keeps the old name as Name, whatever the product is called now
+	renamed := models.FeedEntry{EntityType: models.FeedProduct, Action: models.ActionUpdate, Name: "Drill 18V Pro"}
+	describeChange(&renamed,
+		map[string]interface{}{"name": "Drill", "sku": "TL-1", "price": 10.0},
+		map[string]interface{}{"name": "Drill 18V", "sku": "TL-1", "price": 12.5})
+	if renamed.Name != "Drill" || renamed.NewName != "Drill 18V" {
+		t.Errorf("expected the rename from Drill to Drill 18V, got %q to %q", renamed.Name, renamed.NewName)
+	}
+	if !reflect.DeepEqual(renamed.ChangedFields, []string{"name", "price"}) {
+		t.Errorf("expected name and price to have changed, got %v", renamed.ChangedFields)
+	}
+
+	// Merging categories only changes the category of products
+	moved := models.FeedEntry{EntityType: models.FeedProduct, Action: models.ActionUpdate, Name: "Drill"}
+	describeChange(&moved, map[string]interface{}{"category": "Tool"}, map[string]interface{}{"category": "Tools"})
+	if moved.Category != "Tools" || moved.Name != "Drill" {
+		t.Errorf("expected Drill moved to Tools, got %q to %q", moved.Name, moved.Category)
+	}
+
+	// Deleted products are no longer joined in, so their name comes from
+	// the entry
+	deleted := models.FeedEntry{EntityType: models.FeedProduct, Action: models.ActionDelete}
+	describeChange(&deleted, map[string]interface{}{"name": "Drill", "sku": "TL-1"}, nil)
+	if deleted.Name != "Drill" || deleted.SKU != "TL-1" || deleted.ChangedFields != nil {
+		t.Errorf("expected the deleted product's name and SKU, got %+v", deleted)
+	}
+
+	created := models.FeedEntry{EntityType: models.FeedCategory, Action: models.ActionCreate}
+	describeChange(&created, nil, map[string]interface{}{"name": "Tools", "description": ""})
+	if created.Name != "Tools" {
+		t.Errorf("expected the category's name, got %q", created.Name)
+	}
+}
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"rtims-backend/internal/models"
+)
+
+type AnnouncementService struct {
+	db *sql.DB
+}
+
+func NewAnnouncementService(db *sql.DB) *AnnouncementService {
+	return &AnnouncementService{db: db}
+}
+
+func (s *AnnouncementService) CreateAnnouncement(announcement *models.Announcement) error {
+	query := `INSERT INTO announcements (id, title, message, severity, expires_at, created_by, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err := s.db.Exec(query,
+		announcement.ID,
+		announcement.Title,
+		announcement.Message,
+		announcement.Severity,
+		announcement.ExpiresAt,
+		announcement.CreatedBy,
+		announcement.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create announcement: %w", err)
+	}
+
+	return nil
+}
+
+// GetActiveAnnouncements returns announcements that have not yet expired,
+// newest first.
+func (s *AnnouncementService) GetActiveAnnouncements() ([]models.Announcement, error) {
+	query := `SELECT id, title, message, severity, expires_at, created_by, created_at
+			  FROM announcements
+			  WHERE expires_at IS NULL OR expires_at > NOW()
+			  ORDER BY created_at DESC`
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get announcements: %w", err)
+	}
+	defer rows.Close()
+
+	announcements := []models.Announcement{}
+	for rows.Next() {
+		var announcement models.Announcement
+		var expiresAt sql.NullTime
+		err := rows.Scan(
+			&announcement.ID,
+			&announcement.Title,
+			&announcement.Message,
+			&announcement.Severity,
+			&expiresAt,
+			&announcement.CreatedBy,
+			&announcement.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan announcement: %w", err)
+		}
+		if expiresAt.Valid {
+			announcement.ExpiresAt = &expiresAt.Time
+		}
+		announcements = append(announcements, announcement)
+	}
+
+	return announcements, nil
+}
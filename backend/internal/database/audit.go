@@ -0,0 +1,240 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const auditLogColumns = `id, table_name, record_id, action, old_values, new_values,
+	changed_by, changed_at, COALESCE(host(ip_address), ''), COALESCE(user_agent, ''),
+	COALESCE(status_code, 0), COALESCE(error_message, ''), COALESCE(duration_ms, 0)`
+
+// scanAuditLog scans auditLogColumns into a, followed by any extra columns.
+func scanAuditLog(row interface{ Scan(...interface{}) error }, a *models.AuditLog, extra ...interface{}) error {
+	var oldValues, newValues []byte
+
+	dest := []interface{}{&a.ID, &a.TableName, &a.RecordID, &a.Action, &oldValues, &newValues,
+		&a.ChangedBy, &a.ChangedAt, &a.IPAddress, &a.UserAgent, &a.StatusCode, &a.ErrorMessage, &a.DurationMs}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+
+	a.OldValues = unmarshalAuditValues(oldValues)
+	a.NewValues = unmarshalAuditValues(newValues)
+	return nil
+}
+
+// marshalAuditValues encodes audit values for a JSONB column, storing NULL
+// when there are none.
+func marshalAuditValues(values map[string]interface{}) (interface{}, error) {
+	if values == nil {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode audit values: %w", err)
+	}
+	return string(encoded), nil
+}
+
+// marshalAuditSample encodes a sampled body for a JSONB column, storing NULL
+// when the request was not sampled.
+func marshalAuditSample(body interface{}) (interface{}, error) {
+	if body == nil {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode audit sample: %w", err)
+	}
+	return string(encoded), nil
+}
+
+func unmarshalAuditSample(raw []byte) interface{} {
+	if raw == nil {
+		return nil
+	}
+	var body interface{}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		log.Printf("Failed to decode audit sample: %v", err)
+	}
+	return body
+}
+
+func unmarshalAuditValues(raw []byte) map[string]interface{} {
+	if raw == nil {
+		return nil
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal(raw, &values); err != nil {
+		log.Printf("Failed to decode audit values: %v", err)
+	}
+	return values
+}
+
+// GetRecordTimeline returns the audit trail of one record, oldest first,
+// with the name and email of whoever made each change. View entries are left
+// out unless includeViews is set.
+func (s *AuditService) GetRecordTimeline(ctx context.Context, tableName string, recordID uuid.UUID, includeViews bool) ([]models.AuditTimelineEntry, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	// auditLogColumns are unqualified, so the actor is looked up in
+	// subqueries rather than a join
+	query := `SELECT ` + auditLogColumns + `,
+			         COALESCE((SELECT u.name FROM users u WHERE u.id = audit_logs.changed_by), ''),
+			         COALESCE((SELECT u.email FROM users u WHERE u.id = audit_logs.changed_by), '')
+			  FROM audit_logs
+			  WHERE table_name = $1 AND record_id = $2 AND ($3 OR action <> 'view')
+			  AND ($4::uuid IS NULL OR organization_id = $4)
+			  ORDER BY changed_at`
+
+	rows, err := ForReads(s.db).QueryContext(ctx, query, tableName, recordID, includeViews, organizationArg(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audit timeline: %w", err)
+	}
+	defer rows.Close()
+
+	timeline := []models.AuditTimelineEntry{}
+	for rows.Next() {
+		var entry models.AuditTimelineEntry
+		if err := scanAuditLog(rows, &entry.AuditLog, &entry.ChangedByName, &entry.ChangedByEmail); err != nil {
+			return nil, fmt.Errorf("failed to scan audit log: %w", err)
+		}
+		timeline = append(timeline, entry)
+	}
+
+	return timeline, rows.Err()
+}
+
+// GetAuditLogsBefore returns up to limit of the oldest audit entries changed
+// before the cutoff.
+func (s *AuditService) GetAuditLogsBefore(ctx context.Context, cutoff time.Time, limit int) ([]models.AuditLog, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + auditLogColumns + `
+			  FROM audit_logs
+			  WHERE changed_at < $1
+			  ORDER BY changed_at
+			  LIMIT $2`
+
+	rows, err := s.db.QueryContext(ctx, query, cutoff, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audit logs: %w", err)
+	}
+	defer rows.Close()
+
+	auditLogs := []models.AuditLog{}
+	for rows.Next() {
+		var a models.AuditLog
+		if err := scanAuditLog(rows, &a); err != nil {
+			return nil, fmt.Errorf("failed to scan audit log: %w", err)
+		}
+		auditLogs = append(auditLogs, a)
+	}
+
+	return auditLogs, rows.Err()
+}
+
+// DeleteAuditLogs removes the given audit entries.
+func (s *AuditService) DeleteAuditLogs(ctx context.Context, ids []uuid.UUID) (int64, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := "DELETE FROM audit_logs WHERE id = ANY($1::uuid[])"
+	if productSearcher != nil {
+		query = `WITH deleted AS (` + query + ` RETURNING id) ` + searchIndexInsert(models.SearchAuditLogs) + `deleted`
+	}
+	result, err := s.db.ExecContext(ctx, query, pq.Array(uuidStrings(ids)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete audit logs: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// PurgeAuditLogsBefore deletes every audit entry changed before the cutoff.
+func (s *AuditService) PurgeAuditLogsBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM audit_logs WHERE changed_at < $1", cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge audit logs: %w", err)
+	}
+	if productSearcher != nil {
+		// One event drops them all from the index
+		err := enqueueEvent(ctx, tx, models.EventSearchIndex, models.SearchIndexEvent{Index: models.SearchAuditLogs, Before: &cutoff})
+		if err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetUnstreamedAuditLogs returns up to limit of the oldest audit entries not
+// yet forwarded to the SIEM.
+func (s *AuditService) GetUnstreamedAuditLogs(ctx context.Context, limit int) ([]models.AuditLog, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + auditLogColumns + `
+			  FROM audit_logs
+			  WHERE streamed_at IS NULL
+			  ORDER BY changed_at
+			  LIMIT $1`
+
+	rows, err := s.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get unstreamed audit logs: %w", err)
+	}
+	defer rows.Close()
+
+	auditLogs := []models.AuditLog{}
+	for rows.Next() {
+		var a models.AuditLog
+		if err := scanAuditLog(rows, &a); err != nil {
+			return nil, fmt.Errorf("failed to scan audit log: %w", err)
+		}
+		auditLogs = append(auditLogs, a)
+	}
+
+	return auditLogs, rows.Err()
+}
+
+// MarkAuditLogsStreamed records that the given entries reached the SIEM.
+func (s *AuditService) MarkAuditLogsStreamed(ctx context.Context, ids []uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, "UPDATE audit_logs SET streamed_at = NOW() WHERE id = ANY($1::uuid[])", pq.Array(uuidStrings(ids)))
+	if err != nil {
+		return fmt.Errorf("failed to mark audit logs streamed: %w", err)
+	}
+	return nil
+}
+
+func uuidStrings(ids []uuid.UUID) []string {
+	values := make([]string, len(ids))
+	for i, id := range ids {
+		values[i] = id.String()
+	}
+	return values
+}
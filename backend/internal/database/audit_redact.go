@@ -0,0 +1,141 @@
+package database
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"rtims-backend/internal/models"
+)
+
+// SettingAuditRedactedFields holds a comma-separated list of extra field
+// names whose values are masked in audit entries, on top of
+// defaultRedactedFields.
+const SettingAuditRedactedFields = "audit_redacted_fields"
+
+const (
+	// RedactedValue replaces the values of masked fields in audit entries.
+	RedactedValue = "[REDACTED]"
+	// redactionRefresh is how long the redaction list is cached before the
+	// setting is read again.
+	redactionRefresh = 30 * time.Second
+)
+
+// defaultRedactedFields are always masked, whatever the setting says.
+var defaultRedactedFields = []string{
+	"password", "password_hash", "current_password", "new_password", "confirm_password",
+	"token", "refresh_token", "access_token", "reset_token", "secret", "api_key",
+	"bank_account", "account_number", "iban", "swift", "bic", "routing_number", "sort_code",
+	"slack_webhook_url", "teams_webhook_url", "audit_stream_auth", "telegram_bot_token", "telegram_webhook_secret",
+	// Credentials inside the JSON settings of integrations
+	"webhook_secret", "consumer_key", "consumer_secret", "client_secret", "private_key", "private_key_id",
+	"passphrase", "secret_access_key", "routing_key",
+}
+
+// ParseRedactedFields splits a SettingAuditRedactedFields value into
+// lowercase field names.
+func ParseRedactedFields(raw string) []string {
+	fields := []string{}
+	for _, field := range strings.Split(raw, ",") {
+		if field = strings.ToLower(strings.TrimSpace(field)); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// RedactAuditValues returns a copy of values with the named fields masked,
+// including inside nested objects and lists, and inside JSON held as bytes,
+// like a JSONB column read from the database, which is decoded. Field names
+// match case-insensitively.
+func RedactAuditValues(values map[string]interface{}, fields map[string]bool) map[string]interface{} {
+	if values == nil {
+		return nil
+	}
+
+	redacted := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		if fields[strings.ToLower(key)] {
+			redacted[key] = RedactedValue
+		} else {
+			redacted[key] = redactValue(value, fields)
+		}
+	}
+	return redacted
+}
+
+func redactValue(value interface{}, fields map[string]bool) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return RedactAuditValues(v, fields)
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = redactValue(item, fields)
+		}
+		return items
+	case json.RawMessage:
+		return redactJSON(v, fields)
+	case []byte:
+		return redactJSON(v, fields)
+	default:
+		return value
+	}
+}
+
+// redactJSON decodes data and redacts it, so that it is recorded as the
+// JSON it holds rather than as base64. Bytes that are not a JSON object or
+// list are left as they are.
+func redactJSON(data []byte, fields map[string]bool) interface{} {
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return data
+	}
+	switch decoded.(type) {
+	case map[string]interface{}, []interface{}:
+		return redactValue(decoded, fields)
+	default:
+		return data
+	}
+}
+
+// redact masks sensitive fields in the entry's old and new values, and in
+// its sampled bodies.
+func (s *AuditService) redact(auditLog *models.AuditLog) {
+	fields := s.redactedFields()
+	auditLog.OldValues = RedactAuditValues(auditLog.OldValues, fields)
+	auditLog.NewValues = RedactAuditValues(auditLog.NewValues, fields)
+	if auditLog.RequestBody != nil || auditLog.ResponseBody != nil {
+		piiSafe := s.sampling().piiSafe
+		auditLog.RequestBody = RedactSample(auditLog.RequestBody, fields, piiSafe)
+		auditLog.ResponseBody = RedactSample(auditLog.ResponseBody, fields, piiSafe)
+	}
+}
+
+// redactedFields returns the default and configured field names, refreshing
+// the setting at most every redactionRefresh.
+func (s *AuditService) redactedFields() map[string]bool {
+	s.redactMu.Lock()
+	defer s.redactMu.Unlock()
+
+	if s.redactFields != nil && time.Since(s.redactLoaded) < redactionRefresh {
+		return s.redactFields
+	}
+
+	fields := make(map[string]bool, len(defaultRedactedFields))
+	for _, field := range defaultRedactedFields {
+		fields[field] = true
+	}
+
+	var raw string
+	err := s.db.QueryRow("SELECT value FROM system_settings WHERE key = $1", SettingAuditRedactedFields).Scan(&raw)
+	if err == nil {
+		for _, field := range ParseRedactedFields(raw) {
+			fields[field] = true
+		}
+	}
+
+	s.redactFields = fields
+	s.redactLoaded = time.Now()
+	return fields
+}
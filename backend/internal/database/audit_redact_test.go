@@ -0,0 +1,103 @@
+package database
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRedactedFields(t *testing.T) {
+	fields := ParseRedactedFields(" Supplier_IBAN, tax_id,,")
+	if !reflect.DeepEqual(fields, []string{"supplier_iban", "tax_id"}) {
+		t.Errorf("unexpected fields %v", fields)
+	}
+}
+
+func TestRedactAuditValues(t *testing.T) {
+	fields := map[string]bool{"password": true, "iban": true}
+	values := map[string]interface{}{
+		"email":    "staff@example.com",
+		"Password": "hunter2",
+		"supplier": map[string]interface{}{"name": "Acme", "iban": "DE89370400440532013000"},
+		"contacts": []interface{}{map[string]interface{}{"password": "x"}, "plain"},
+	}
+
+	redacted := RedactAuditValues(values, fields)
+
+	expected := map[string]interface{}{
+		"email":    "staff@example.com",
+		"Password": RedactedValue,
+		"supplier": map[string]interface{}{"name": "Acme", "iban": RedactedValue},
+		"contacts": []interface{}{map[string]interface{}{"password": RedactedValue}, "plain"},
+	}
+	if !reflect.DeepEqual(redacted, expected) {
+		t.Errorf("expected %v, got %v", expected, redacted)
+	}
+	if values["Password"] != "hunter2" {
+		t.Error("expected the original values to be left untouched")
+	}
+	if RedactAuditValues(nil, fields) != nil {
+		t.Error("expected nil values to stay nil")
+	}
+}
+
+func TestRedactAuditValuesInJSONBytes(t *testing.T) {
+	fields := map[string]bool{"iban": true, "bank_account": true}
+	values := map[string]interface{}{
+		// supplier_info as scanned from its JSONB column
+		"supplier_info": []byte(`{"name":"Acme","iban":"DE89370400440532013000","bank":{"bank_account":"12345678"}}`),
+		"raw":           json.RawMessage(`[{"iban":"GB29NWBK60161331926819"}]`),
+		"plain":         []byte("not json"),
+	}
+
+	redacted := RedactAuditValues(values, fields)
+
+	expected := map[string]interface{}{
+		"supplier_info": map[string]interface{}{"name": "Acme", "iban": RedactedValue, "bank": map[string]interface{}{"bank_account": RedactedValue}},
+		"raw":           []interface{}{map[string]interface{}{"iban": RedactedValue}},
+		"plain":         []byte("not json"),
+	}
+	if !reflect.DeepEqual(redacted, expected) {
+		t.Errorf("expected %v, got %v", expected, redacted)
+	}
+	encoded, _ := json.Marshal(redacted)
+	if strings.Contains(string(encoded), "DE89") || strings.Contains(string(encoded), "12345678") {
+		t.Errorf("expected no bank details in %s", encoded)
+	}
+}
+
+func TestDefaultRedactedFieldsCoverSettingSecrets(t *testing.T) {
+	fields := make(map[string]bool, len(defaultRedactedFields))
+	for _, field := range defaultRedactedFields {
+		fields[field] = true
+	}
+	values := map[string]interface{}{
+		"telegram_bot_token":      "123456:telegram-bot-token",
+		"telegram_webhook_secret": "telegram-webhook-secret",
+		"woocommerce_stores": map[string]interface{}{
+			"shop": map[string]interface{}{"consumer_key": "ck", "consumer_secret": "cs"},
+		},
+		"google_service_account": map[string]interface{}{"private_key_id": "id", "private_key": "pk"},
+		"shopify_stores": map[string]interface{}{
+			"main": map[string]interface{}{"access_token": "shpat", "webhook_secret": "whsec"},
+		},
+	}
+
+	redacted := RedactAuditValues(values, fields)
+
+	expected := map[string]interface{}{
+		"telegram_bot_token":      RedactedValue,
+		"telegram_webhook_secret": RedactedValue,
+		"woocommerce_stores": map[string]interface{}{
+			"shop": map[string]interface{}{"consumer_key": RedactedValue, "consumer_secret": RedactedValue},
+		},
+		"google_service_account": map[string]interface{}{"private_key_id": RedactedValue, "private_key": RedactedValue},
+		"shopify_stores": map[string]interface{}{
+			"main": map[string]interface{}{"access_token": RedactedValue, "webhook_secret": RedactedValue},
+		},
+	}
+	if !reflect.DeepEqual(redacted, expected) {
+		t.Errorf("expected %v, got %v", expected, redacted)
+	}
+}
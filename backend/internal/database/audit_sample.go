@@ -0,0 +1,151 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SettingAuditSamplePercent is the percentage of mutating requests
+	// whose request and response bodies are kept in their audit entry.
+	SettingAuditSamplePercent = "audit_sample_percent"
+	// SettingAuditSamplePIISafe masks personal data in sampled bodies, on
+	// top of the redacted fields.
+	SettingAuditSamplePIISafe = "audit_sample_pii_safe"
+)
+
+// MaxSampleBody caps the size of a sampled body. Larger bodies are noted by
+// their size instead.
+const MaxSampleBody = 64 << 10
+
+// piiFields are masked in sampled bodies in PII-safe mode.
+var piiFields = []string{
+	"email", "contact_email", "customer_email", "phone", "phone_number", "mobile",
+	"address", "street", "postal_code", "zip", "first_name", "last_name", "full_name",
+	"contact_name", "customer_name", "birth_date", "date_of_birth", "tax_id", "national_id",
+}
+
+// emailPattern finds email addresses in sampled values in PII-safe mode,
+// wherever they are.
+var emailPattern = regexp.MustCompile(`[^\s@"<>,;:]+@[^\s@"<>,;:]+\.[a-zA-Z]{2,}`)
+
+// ParseSamplePercent parses a SettingAuditSamplePercent value.
+func ParseSamplePercent(value string) (int, error) {
+	percent, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || percent < 0 || percent > 100 {
+		return 0, fmt.Errorf("%q is not a percentage from 0 to 100", value)
+	}
+	return percent, nil
+}
+
+// SampleBody decodes a request or response body for an audit sample. JSON
+// is kept as it is, so its fields can be masked; other bodies, and those
+// larger than MaxSampleBody, are noted by their size and content type.
+func SampleBody(body []byte, contentType string) interface{} {
+	if len(body) == 0 {
+		return nil
+	}
+	if len(body) > MaxSampleBody {
+		return fmt.Sprintf("[more than %d bytes, not sampled]", MaxSampleBody)
+	}
+
+	var decoded interface{}
+	if json.Unmarshal(body, &decoded) == nil {
+		return decoded
+	}
+	if contentType == "" {
+		contentType = "unknown type"
+	}
+	return fmt.Sprintf("[%d bytes of %s, not sampled]", len(body), contentType)
+}
+
+// RedactSample returns a copy of a sampled body with the named fields
+// masked. In PII-safe mode personal fields and email addresses in any value
+// are masked too.
+func RedactSample(body interface{}, fields map[string]bool, piiSafe bool) interface{} {
+	if body == nil {
+		return nil
+	}
+	if !piiSafe {
+		return redactValue(body, fields)
+	}
+
+	all := make(map[string]bool, len(fields)+len(piiFields))
+	for field := range fields {
+		all[field] = true
+	}
+	for _, field := range piiFields {
+		all[field] = true
+	}
+	return maskEmails(redactValue(body, all))
+}
+
+// maskEmails masks the email addresses in the strings of value, which
+// redactValue has already copied.
+func maskEmails(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = maskEmails(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = maskEmails(item)
+		}
+	case string:
+		return emailPattern.ReplaceAllString(v, RedactedValue)
+	}
+	return value
+}
+
+// auditSampling is how requests are sampled, from the settings.
+type auditSampling struct {
+	percent int
+	piiSafe bool
+}
+
+// Sample reports whether to keep the bodies of a mutating request, picking
+// SettingAuditSamplePercent of them at random.
+func (s *AuditService) Sample() bool {
+	percent := s.sampling().percent
+	return percent > 0 && rand.Intn(100) < percent
+}
+
+// sampling returns the sampling settings, refreshing them at most every
+// redactionRefresh. PII-safe mode is on unless turned off.
+func (s *AuditService) sampling() auditSampling {
+	s.sampleMu.Lock()
+	defer s.sampleMu.Unlock()
+
+	if !s.sampleLoaded.IsZero() && time.Since(s.sampleLoaded) < redactionRefresh {
+		return s.sampleSettings
+	}
+
+	sampling := auditSampling{piiSafe: true}
+	rows, err := s.db.Query("SELECT key, value FROM system_settings WHERE key IN ($1, $2)",
+		SettingAuditSamplePercent, SettingAuditSamplePIISafe)
+	if err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var key, value string
+			if rows.Scan(&key, &value) != nil {
+				continue
+			}
+			switch key {
+			case SettingAuditSamplePercent:
+				sampling.percent, _ = ParseSamplePercent(value)
+			case SettingAuditSamplePIISafe:
+				sampling.piiSafe = value != "false"
+			}
+		}
+	}
+
+	s.sampleSettings = sampling
+	s.sampleLoaded = time.Now()
+	return sampling
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSamplePercent(t *testing.T) {
+	if percent, err := ParseSamplePercent(" 25 "); err != nil || percent != 25 {
+		t.Errorf("expected 25, got %d, %v", percent, err)
+	}
+	for _, value := range []string{"", "-1", "101", "2.5", "all"} {
+		if _, err := ParseSamplePercent(value); err == nil {
+			t.Errorf("expected %q to be rejected", value)
+		}
+	}
+}
+
+func TestSampleBody(t *testing.T) {
+	if SampleBody(nil, "application/json") != nil {
+		t.Error("expected an empty body to stay nil")
+	}
+	body := SampleBody([]byte(`{"sku":"SKU-1","stock":5}`), "application/json")
+	if !reflect.DeepEqual(body, map[string]interface{}{"sku": "SKU-1", "stock": 5.0}) {
+		t.Errorf("expected JSON to be decoded, got %v", body)
+	}
+	if body := SampleBody([]byte("sku,stock\nSKU-1,5\n"), "text/csv"); body != "[18 bytes of text/csv, not sampled]" {
+		t.Errorf("expected other bodies to be noted, got %v", body)
+	}
+	large := []byte(`"` + strings.Repeat("x", MaxSampleBody) + `"`)
+	if body := SampleBody(large, "application/json"); !strings.HasPrefix(body.(string), "[more than") {
+		t.Errorf("expected a large body to be noted, got %.40v", body)
+	}
+}
+
+func TestRedactSample(t *testing.T) {
+	fields := map[string]bool{"password": true}
+	body := map[string]interface{}{
+		"password": "hunter2",
+		"email":    "ana@example.com",
+		"notes":    "ask budi@example.co.id first",
+		"items":    []interface{}{map[string]interface{}{"phone": "+62 812 0000"}, "plain"},
+	}
+
+	redacted := RedactSample(body, fields, false)
+	if m := redacted.(map[string]interface{}); m["password"] != RedactedValue || m["email"] != "ana@example.com" {
+		t.Errorf("expected only the redacted fields to be masked, got %v", redacted)
+	}
+
+	expected := map[string]interface{}{
+		"password": RedactedValue,
+		"email":    RedactedValue,
+		"notes":    "ask " + RedactedValue + " first",
+		"items":    []interface{}{map[string]interface{}{"phone": RedactedValue}, "plain"},
+	}
+	if redacted := RedactSample(body, fields, true); !reflect.DeepEqual(redacted, expected) {
+		t.Errorf("expected %v, got %v", expected, redacted)
+	}
+	if body["notes"] != "ask budi@example.co.id first" {
+		t.Error("expected the original body to be left untouched")
+	}
+	if RedactSample(nil, fields, true) != nil {
+		t.Error("expected a missing body to stay nil")
+	}
+}
@@ -0,0 +1,38 @@
+package database
+
+import (
+	"strings"
+	"testing"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestAuditInsert(t *testing.T) {
+	entries := []*models.AuditLog{
+		{ID: uuid.New(), TableName: "products", Action: models.ActionUpdate, NewValues: map[string]interface{}{"stock": 5},
+			RequestBody: map[string]interface{}{"stock": 5}, ResponseBody: "[2048 bytes of text/csv, not sampled]"},
+		{ID: uuid.New(), TableName: "users", Action: models.ActionDelete, StatusCode: 204},
+	}
+
+	query, args, err := auditInsert(entries)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(args) != 30 {
+		t.Errorf("expected 15 arguments per entry, got %d", len(args))
+	}
+	if strings.Count(query, "::inet") != 2 {
+		t.Errorf("expected one row per entry in %s", query)
+	}
+	if !strings.Contains(query, "NULLIF($26, 0), NULLIF($27, ''), CASE WHEN $26 = 0 THEN NULL ELSE $28 END, $29, $30)") {
+		t.Errorf("expected the second row to use its own placeholders in %s", query)
+	}
+	if args[5] != `{"stock":5}` || args[20] != nil {
+		t.Errorf("expected new values to be encoded, got %v and %v", args[5], args[20])
+	}
+	if args[13] != `{"stock":5}` || args[14] != `"[2048 bytes of text/csv, not sampled]"` || args[28] != nil {
+		t.Errorf("expected sampled bodies to be encoded, got %v, %v and %v", args[13], args[14], args[28])
+	}
+}
@@ -0,0 +1,141 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+
+	"rtims-backend/internal/models"
+)
+
+// auditBatchSize caps how many entries go into one INSERT.
+const auditBatchSize = 500
+
+// auditWriter, when set, takes audit entries recorded with
+// AuditService.Record.
+var auditWriter *AuditWriter
+
+// UseAuditWriter sends entries recorded with AuditService.Record to w
+// instead of inserting them in the caller. Call it once at startup.
+func UseAuditWriter(w *AuditWriter) {
+	auditWriter = w
+}
+
+// AuditWriter inserts audit entries in the background. Each worker takes
+// whatever has queued up, up to auditBatchSize entries, and writes it with
+// one multi-row INSERT.
+type AuditWriter struct {
+	auditService *AuditService
+	entries      chan *models.AuditLog
+	workers      sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewAuditWriter starts workers that write queued audit entries. At most
+// queueSize entries wait for them.
+func NewAuditWriter(db *sql.DB, workers, queueSize int) *AuditWriter {
+	if workers < 1 {
+		workers = 1
+	}
+
+	w := &AuditWriter{
+		auditService: NewAuditService(db),
+		entries:      make(chan *models.AuditLog, queueSize),
+	}
+	w.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go w.run()
+	}
+	return w
+}
+
+// Enqueue queues an entry without waiting. It returns false if the queue is
+// full or the writer has been closed.
+func (w *AuditWriter) Enqueue(auditLog *models.AuditLog) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return false
+	}
+
+	select {
+	case w.entries <- auditLog:
+		return true
+	default:
+		return false
+	}
+}
+
+// Sample reports whether to keep the bodies of a mutating request in its
+// entry, see AuditService.Sample.
+func (w *AuditWriter) Sample() bool {
+	return w.auditService.Sample()
+}
+
+// Close stops accepting entries and waits until the queued ones are written
+// or ctx expires. Call it only once the server has stopped serving requests.
+func (w *AuditWriter) Close(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.entries)
+	}
+	w.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		w.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *AuditWriter) run() {
+	defer w.workers.Done()
+
+	batch := make([]*models.AuditLog, 0, auditBatchSize)
+	for auditLog := range w.entries {
+		batch = append(batch[:0], auditLog)
+	drain:
+		for len(batch) < auditBatchSize {
+			select {
+			case next, ok := <-w.entries:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, next)
+			default:
+				break drain
+			}
+		}
+		w.write(batch)
+	}
+}
+
+func (w *AuditWriter) write(batch []*models.AuditLog) {
+	err := w.auditService.CreateAuditLogs(context.Background(), batch)
+	if err == nil {
+		return
+	}
+	if len(batch) == 1 {
+		log.Printf("Failed to create audit log: %v", err)
+		return
+	}
+
+	// One bad entry must not lose the rest of the batch
+	log.Printf("Failed to write batch of %d audit logs, writing them one by one: %v", len(batch), err)
+	for _, auditLog := range batch {
+		if err := w.auditService.CreateAuditLog(context.Background(), auditLog); err != nil {
+			log.Printf("Failed to create audit log: %v", err)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const backupColumns = `id, status, storage, COALESCE(location, ''), size, COALESCE(checksum, ''),
+	COALESCE(error, ''), requested_by, created_at, started_at, completed_at`
+
+type BackupService struct {
+	db *sql.DB
+}
+
+func NewBackupService(db *sql.DB) *BackupService {
+	return &BackupService{db: db}
+}
+
+func scanBackup(row interface{ Scan(...interface{}) error }, b *models.Backup) error {
+	var requestedBy uuid.NullUUID
+	var startedAt, completedAt sql.NullTime
+
+	err := row.Scan(&b.ID, &b.Status, &b.Storage, &b.Location, &b.Size, &b.Checksum,
+		&b.Error, &requestedBy, &b.CreatedAt, &startedAt, &completedAt)
+	if err != nil {
+		return err
+	}
+
+	if requestedBy.Valid {
+		b.RequestedBy = &requestedBy.UUID
+	}
+	if startedAt.Valid {
+		b.StartedAt = &startedAt.Time
+	}
+	if completedAt.Valid {
+		b.CompletedAt = &completedAt.Time
+	}
+	return nil
+}
+
+// CreateBackup records a queued backup. It fails with a unique violation on
+// backups_one_active while another backup is queued or running.
+func (s *BackupService) CreateBackup(backup *models.Backup) error {
+	query := `INSERT INTO backups (id, status, storage, requested_by, created_at)
+			  VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := s.db.Exec(query, backup.ID, backup.Status, backup.Storage, backup.RequestedBy, backup.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create backup: %w", err)
+	}
+
+	return nil
+}
+
+func (s *BackupService) GetBackup(id uuid.UUID) (*models.Backup, error) {
+	query := `SELECT ` + backupColumns + ` FROM backups WHERE id = $1`
+
+	var backup models.Backup
+	if err := scanBackup(s.db.QueryRow(query, id), &backup); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("backup %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get backup: %w", err)
+	}
+
+	return &backup, nil
+}
+
+// GetBackups returns the most recent backups, newest first.
+func (s *BackupService) GetBackups(limit int) ([]models.Backup, error) {
+	query := `SELECT ` + backupColumns + ` FROM backups ORDER BY created_at DESC LIMIT $1`
+
+	rows, err := s.db.Query(query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get backups: %w", err)
+	}
+	defer rows.Close()
+
+	backups := []models.Backup{}
+	for rows.Next() {
+		var backup models.Backup
+		if err := scanBackup(rows, &backup); err != nil {
+			return nil, fmt.Errorf("failed to scan backup: %w", err)
+		}
+		backups = append(backups, backup)
+	}
+
+	return backups, nil
+}
+
+// GetLastCompletedBackup returns the newest backup that completed, or nil
+// when none has.
+func (s *BackupService) GetLastCompletedBackup() (*models.Backup, error) {
+	query := `SELECT ` + backupColumns + ` FROM backups WHERE status = $1 ORDER BY completed_at DESC LIMIT 1`
+
+	var backup models.Backup
+	if err := scanBackup(s.db.QueryRow(query, models.BackupCompleted), &backup); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get last backup: %w", err)
+	}
+
+	return &backup, nil
+}
+
+// GetCompletedBackups returns every completed backup, newest first.
+func (s *BackupService) GetCompletedBackups() ([]models.Backup, error) {
+	query := `SELECT ` + backupColumns + ` FROM backups WHERE status = $1 ORDER BY completed_at DESC`
+
+	rows, err := s.db.Query(query, models.BackupCompleted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get completed backups: %w", err)
+	}
+	defer rows.Close()
+
+	backups := []models.Backup{}
+	for rows.Next() {
+		var backup models.Backup
+		if err := scanBackup(rows, &backup); err != nil {
+			return nil, fmt.Errorf("failed to scan backup: %w", err)
+		}
+		backups = append(backups, backup)
+	}
+
+	return backups, nil
+}
+
+func (s *BackupService) DeleteBackup(id uuid.UUID) error {
+	result, err := s.db.Exec(`DELETE FROM backups WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete backup: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("backup %w", ErrNotFound)
+	}
+	return nil
+}
+
+func (s *BackupService) MarkRunning(id uuid.UUID) error {
+	query := `UPDATE backups SET status = $1, started_at = NOW() WHERE id = $2`
+	_, err := s.db.Exec(query, models.BackupRunning, id)
+	return err
+}
+
+func (s *BackupService) MarkCompleted(id uuid.UUID, location string, size int64, checksum string) error {
+	query := `UPDATE backups SET status = $1, location = $2, size = $3, checksum = $4, completed_at = NOW()
+			  WHERE id = $5`
+	_, err := s.db.Exec(query, models.BackupCompleted, location, size, checksum, id)
+	return err
+}
+
+func (s *BackupService) MarkFailed(id uuid.UUID, reason string) error {
+	query := `UPDATE backups SET status = $1, error = $2, completed_at = NOW() WHERE id = $3`
+	_, err := s.db.Exec(query, models.BackupFailed, reason, id)
+	return err
+}
+
+// FailStale marks backups queued or running for longer than maxAge as
+// failed. A backup outlives its time limit only when the process running it
+// exited, and it would otherwise block every later backup.
+func (s *BackupService) FailStale(maxAge time.Duration) (int64, error) {
+	query := `UPDATE backups SET status = $1, error = 'interrupted before completing', completed_at = NOW()
+			  WHERE status IN ($2, $3) AND created_at < $4`
+	result, err := s.db.Exec(query, models.BackupFailed, models.BackupQueued, models.BackupRunning, time.Now().Add(-maxAge))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
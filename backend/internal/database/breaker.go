@@ -0,0 +1,208 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/lib/pq"
+	"github.com/sony/gobreaker"
+)
+
+// ErrUnavailable is returned without contacting Postgres or Redis while its
+// circuit breaker is open.
+var ErrUnavailable = errors.New("service temporarily unavailable")
+
+// Resilience configures the circuit breakers and retries around Postgres
+// and Redis.
+type Resilience struct {
+	// Failures is how many consecutive failures open a breaker.
+	Failures uint32
+	// OpenFor is how long an open breaker fails calls before letting a
+	// trial call through.
+	OpenFor time.Duration
+	// Retries is how many times a failed connection attempt is retried,
+	// after RetryBackoff doubled each time, plus jitter.
+	Retries      int
+	RetryBackoff time.Duration
+}
+
+var (
+	resilience = Resilience{Failures: 5, OpenFor: 30 * time.Second, Retries: 2, RetryBackoff: 100 * time.Millisecond}
+
+	postgresBreaker = newBreaker("postgres")
+	replicaBreaker  = newBreaker("postgres-replica")
+	redisBreaker    = newBreaker("redis")
+)
+
+// ConfigureResilience replaces the breaker and retry settings. Call it once
+// at startup, before InitDB and InitRedis.
+func ConfigureResilience(settings Resilience) {
+	resilience = settings
+	postgresBreaker = newBreaker("postgres")
+	replicaBreaker = newBreaker("postgres-replica")
+	redisBreaker = newBreaker("redis")
+}
+
+// PostgresUnavailable reports whether the Postgres breaker is open, and if
+// so roughly how long until it lets a call through again.
+func PostgresUnavailable() (time.Duration, bool) {
+	return postgresBreaker.openFor()
+}
+
+// breaker is a gobreaker circuit breaker that remembers when it opened.
+type breaker struct {
+	*gobreaker.TwoStepCircuitBreaker
+
+	mu       sync.Mutex
+	openedAt time.Time
+}
+
+func newBreaker(name string) *breaker {
+	b := &breaker{}
+	b.TwoStepCircuitBreaker = gobreaker.NewTwoStepCircuitBreaker(gobreaker.Settings{
+		Name:    name,
+		Timeout: resilience.OpenFor,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= resilience.Failures
+		},
+		OnStateChange: func(_ string, _, to gobreaker.State) {
+			if to == gobreaker.StateOpen {
+				b.mu.Lock()
+				b.openedAt = time.Now()
+				b.mu.Unlock()
+			}
+		},
+	})
+	return b
+}
+
+func (b *breaker) openFor() (time.Duration, bool) {
+	if b.State() != gobreaker.StateOpen {
+		return 0, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	remaining := resilience.OpenFor - time.Since(b.openedAt)
+	if remaining < time.Second {
+		remaining = time.Second
+	}
+	return remaining, true
+}
+
+// allow asks the breaker to let a call through, returning ErrUnavailable
+// if it is open.
+func (b *breaker) allow() (func(success bool), error) {
+	done, err := b.Allow()
+	if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+		return nil, ErrUnavailable
+	}
+	return done, err
+}
+
+// retry calls fn until it succeeds, fails permanently, or has been retried
+// resilience.Retries times, backing off exponentially with jitter.
+func retry(ctx context.Context, permanent func(error) bool, fn func() error) error {
+	backoff := resilience.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || permanent(err) || attempt >= resilience.Retries || backoff <= 0 {
+			return err
+		}
+		wait := backoff + time.Duration(rand.Int63n(int64(backoff)))
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+}
+
+// postgresConnector opens Postgres connections through a breaker, so once
+// Postgres is down new connections fail at once instead of each waiting out
+// a connect timeout. Calls on pooled connections that break come back here
+// when database/sql reconnects.
+type postgresConnector struct {
+	driver.Connector
+	breaker *breaker
+}
+
+func (c postgresConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	done, err := c.breaker.allow()
+	if err != nil {
+		return nil, err
+	}
+
+	var conn driver.Conn
+	err = retry(ctx, postgresReachable, func() (err error) {
+		conn, err = c.Connector.Connect(ctx)
+		return err
+	})
+	// An error from the server itself, such as a bad password, means
+	// Postgres is up
+	done(err == nil || postgresReachable(err))
+	return conn, err
+}
+
+func postgresReachable(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) || errors.Is(err, context.Canceled)
+}
+
+// redisBreakerHook sends Redis commands through the breaker. go-redis
+// retries commands itself (see redisOptions), so a command counts as one
+// call however many attempts it took.
+type redisBreakerHook struct{}
+
+type redisDoneKey struct{}
+
+func (redisBreakerHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return redisBefore(ctx)
+}
+
+func (redisBreakerHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	redisAfter(ctx, cmd.Err())
+	return nil
+}
+
+func (redisBreakerHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return redisBefore(ctx)
+}
+
+func (redisBreakerHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if !redisReachable(cmd.Err()) {
+			err = cmd.Err()
+			break
+		}
+	}
+	redisAfter(ctx, err)
+	return nil
+}
+
+func redisBefore(ctx context.Context) (context.Context, error) {
+	done, err := redisBreaker.allow()
+	if err != nil {
+		return ctx, err
+	}
+	return context.WithValue(ctx, redisDoneKey{}, done), nil
+}
+
+func redisAfter(ctx context.Context, err error) {
+	if done, ok := ctx.Value(redisDoneKey{}).(func(bool)); ok {
+		done(redisReachable(err))
+	}
+}
+
+// redisReachable reports whether err, if any, came from Redis itself (a
+// missing key or a command error) rather than from failing to reach it.
+func redisReachable(err error) bool {
+	var redisErr redis.Error
+	return err == nil || errors.As(err, &redisErr) || errors.Is(err, context.Canceled)
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/lib/pq"
+)
+
+type failingConnector struct {
+	calls int
+	err   error
+}
+
+func (c *failingConnector) Connect(context.Context) (driver.Conn, error) {
+	c.calls++
+	return nil, c.err
+}
+
+func (c *failingConnector) Driver() driver.Driver { return nil }
+
+func TestPostgresConnectorBreaker(t *testing.T) {
+	defer ConfigureResilience(resilience)
+	ConfigureResilience(Resilience{Failures: 2, OpenFor: time.Minute, Retries: 1, RetryBackoff: time.Millisecond})
+
+	refused := &failingConnector{err: errors.New("dial tcp: connection refused")}
+	connector := postgresConnector{refused, postgresBreaker}
+	for i := 0; i < 2; i++ {
+		if _, err := connector.Connect(context.Background()); err == nil || errors.Is(err, ErrUnavailable) {
+			t.Fatalf("attempt %d: expected the connection error, got %v", i, err)
+		}
+	}
+	if refused.calls != 4 {
+		t.Errorf("expected each attempt to be retried once, got %d calls", refused.calls)
+	}
+
+	if _, err := connector.Connect(context.Background()); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable once the breaker opened, got %v", err)
+	}
+	if refused.calls != 4 {
+		t.Errorf("expected an open breaker not to dial, got %d calls", refused.calls)
+	}
+	if retryAfter, unavailable := PostgresUnavailable(); !unavailable || retryAfter > time.Minute {
+		t.Errorf("expected Postgres to be reported unavailable, got %v %v", retryAfter, unavailable)
+	}
+}
+
+func TestPostgresConnectorServerErrors(t *testing.T) {
+	defer ConfigureResilience(resilience)
+	ConfigureResilience(Resilience{Failures: 1, OpenFor: time.Minute, Retries: 3, RetryBackoff: time.Millisecond})
+
+	rejected := &failingConnector{err: &pq.Error{Code: "28P01", Message: "password authentication failed"}}
+	connector := postgresConnector{rejected, postgresBreaker}
+	for i := 0; i < 3; i++ {
+		if _, err := connector.Connect(context.Background()); errors.Is(err, ErrUnavailable) {
+			t.Fatal("expected errors from the server not to open the breaker")
+		}
+	}
+	if rejected.calls != 3 {
+		t.Errorf("expected errors from the server not to be retried, got %d calls", rejected.calls)
+	}
+	if _, unavailable := PostgresUnavailable(); unavailable {
+		t.Error("expected Postgres to be reported available")
+	}
+}
+
+func TestRedisReachable(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{nil, true},
+		{redis.Nil, true},
+		{context.Canceled, true},
+		{context.DeadlineExceeded, false},
+		{errors.New("dial tcp: i/o timeout"), false},
+	}
+	for _, tt := range tests {
+		if got := redisReachable(tt.err); got != tt.expected {
+			t.Errorf("redisReachable(%v) = %v, want %v", tt.err, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	productCacheKey        = "cache:product:"
+	productListVersionKey  = "cache:products:version"
+	productListCacheKey    = "cache:products:"
+	dashboardStatsCacheKey = "cache:dashboard:stats"
+
+	// hotProductPages is how many leading pages of an unsearched product
+	// listing are cached; deeper pages and searches go to the database.
+	hotProductPages = 3
+)
+
+// Cache is a read-through Redis cache for hot queries. Writes through the
+// services invalidate what they change; the TTL bounds staleness from
+// writes that bypass them. A nil *Cache caches nothing, and Redis errors
+// fall back to the database.
+type Cache struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func NewCache(client *redis.Client, ttl time.Duration) *Cache {
+	if client == nil || ttl <= 0 {
+		return nil
+	}
+	return &Cache{client: client, ttl: ttl}
+}
+
+// get decodes the cached value for key into dest and reports whether there
+// was one.
+func (c *Cache) get(ctx context.Context, key string, dest interface{}) bool {
+	if c == nil {
+		return false
+	}
+	raw, err := c.client.Get(ctx, key).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			log.Printf("Cache read of %s failed: %v", key, err)
+		}
+		return false
+	}
+	if err := json.Unmarshal(raw, dest); err != nil {
+		log.Printf("Discarding unreadable cache entry %s: %v", key, err)
+		return false
+	}
+	return true
+}
+
+func (c *Cache) set(ctx context.Context, key string, value interface{}) {
+	if c == nil {
+		return
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		log.Printf("Failed to encode cache entry %s: %v", key, err)
+		return
+	}
+	if err := c.client.Set(ctx, key, raw, c.ttl).Err(); err != nil {
+		log.Printf("Cache write of %s failed: %v", key, err)
+	}
+}
+
+func (c *Cache) delete(ctx context.Context, keys ...string) {
+	if c == nil {
+		return
+	}
+	if err := c.client.Del(ctx, keys...).Err(); err != nil {
+		log.Printf("Cache invalidation of %v failed: %v", keys, err)
+	}
+}
+
+// productListKey returns the key for a product listing page. Listings are
+// keyed under a version number so one INCR invalidates every page.
+func (c *Cache) productListKey(ctx context.Context, filter interface{}) (string, bool) {
+	version, err := c.client.Get(ctx, productListVersionKey).Int64()
+	if err != nil && err != redis.Nil {
+		log.Printf("Cache read of %s failed: %v", productListVersionKey, err)
+		return "", false
+	}
+	encoded, err := json.Marshal(filter)
+	if err != nil {
+		return "", false
+	}
+	sum := sha1.Sum(encoded)
+	return productListCacheKey + strconv.FormatInt(version, 10) + ":" + organizationKeyPart(ctx) + hex.EncodeToString(sum[:]), true
+}
+
+// dashboardStatsKey returns the key of the dashboard stats of the
+// organization of ctx.
+func dashboardStatsKey(ctx context.Context) string {
+	if id, ok := OrganizationFrom(ctx); ok {
+		return dashboardStatsCacheKey + ":" + id.String()
+	}
+	return dashboardStatsCacheKey
+}
+
+// organizationKeyPart keeps the cache entries of organizations apart;
+// unscoped contexts share one set of entries.
+func organizationKeyPart(ctx context.Context) string {
+	if id, ok := OrganizationFrom(ctx); ok {
+		return id.String() + ":"
+	}
+	return ""
+}
+
+// invalidateProducts drops the cached product, every cached listing and the
+// dashboard stats, which all count products and stock.
+func (c *Cache) invalidateProducts(ctx context.Context, ids ...string) {
+	if c == nil {
+		return
+	}
+	if err := c.client.Incr(ctx, productListVersionKey).Err(); err != nil {
+		log.Printf("Cache invalidation of product listings failed: %v", err)
+	}
+	keys := []string{dashboardStatsKey(ctx)}
+	for _, id := range ids {
+		keys = append(keys, productCacheKey+id)
+	}
+	c.delete(ctx, keys...)
+}
@@ -0,0 +1,28 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func TestDisabledCache(t *testing.T) {
+	if cache := NewCache(nil, time.Minute); cache != nil {
+		t.Error("expected no cache without a Redis client")
+	}
+	if cache := NewCache(redis.NewClient(&redis.Options{}), 0); cache != nil {
+		t.Error("expected no cache with a zero TTL")
+	}
+
+	// A nil cache misses and ignores writes, so services work without Redis
+	var cache *Cache
+	ctx := context.Background()
+	cache.set(ctx, productCacheKey+"1", map[string]int{"stock": 3})
+	var dest map[string]int
+	if cache.get(ctx, productCacheKey+"1", &dest) {
+		t.Error("expected a nil cache to miss")
+	}
+	cache.invalidateProducts(ctx, "1")
+}
@@ -0,0 +1,155 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"rtims-backend/internal/models"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+)
+
+const calendarEventColumns = `id, organization_id, kind, title, description, starts_at, ends_at, created_by, created_at, updated_at`
+
+func scanCalendarEvent(row interface{ Scan(...interface{}) error }, e *models.CalendarEvent) error {
+	var createdBy uuid.NullUUID
+
+	err := row.Scan(&e.ID, &e.OrganizationID, &e.Kind, &e.Title, &e.Description, &e.StartsAt, &e.EndsAt, &createdBy,
+		&e.CreatedAt, &e.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if createdBy.Valid {
+		e.CreatedBy = &createdBy.UUID
+	}
+	return nil
+}
+
+// CalendarService manages the scheduled stocktakes and maintenance
+// windows of organizations.
+type CalendarService struct {
+	db *sql.DB
+}
+
+func NewCalendarService(db *sql.DB) *CalendarService {
+	return &CalendarService{db: db}
+}
+
+// GetCalendarEvents returns the events of the organization of ctx that
+// overlap from to to, earliest first.
+func (s *CalendarService) GetCalendarEvents(ctx context.Context, from, to time.Time) ([]models.CalendarEvent, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+calendarEventColumns+` FROM calendar_events
+		WHERE ($1::uuid IS NULL OR organization_id = $1) AND ends_at > $2 AND starts_at < $3
+		ORDER BY starts_at, id`, organizationArg(ctx), from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get calendar events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []models.CalendarEvent{}
+	for rows.Next() {
+		var event models.CalendarEvent
+		if err := scanCalendarEvent(rows, &event); err != nil {
+			return nil, fmt.Errorf("failed to scan calendar event: %w", err)
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+func (s *CalendarService) GetCalendarEvent(ctx context.Context, id uuid.UUID) (*models.CalendarEvent, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + calendarEventColumns + ` FROM calendar_events
+			  WHERE id = $1 AND ($2::uuid IS NULL OR organization_id = $2)`
+
+	var event models.CalendarEvent
+	if err := scanCalendarEvent(s.db.QueryRowContext(ctx, query, id, organizationArg(ctx)), &event); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("calendar event %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get calendar event: %w", err)
+	}
+	return &event, nil
+}
+
+// CreateCalendarEvent creates an event in the organization of ctx.
+func (s *CalendarService) CreateCalendarEvent(ctx context.Context, event *models.CalendarEvent) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	if organizationID, ok := OrganizationFrom(ctx); ok {
+		event.OrganizationID = organizationID
+	} else if event.OrganizationID == uuid.Nil {
+		event.OrganizationID = DefaultOrganizationID
+	}
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO calendar_events (id, organization_id, kind, title, description, starts_at, ends_at, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		event.ID, event.OrganizationID, event.Kind, event.Title, event.Description, event.StartsAt, event.EndsAt,
+		event.CreatedBy, event.CreatedAt, event.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create calendar event: %w", err)
+	}
+	return nil
+}
+
+// UpdateCalendarEvent changes the fields of req that are set.
+func (s *CalendarService) UpdateCalendarEvent(ctx context.Context, id uuid.UUID, req models.UpdateCalendarEventRequest) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	values := map[string]interface{}{"updated_at": sq.Expr("NOW()")}
+	if req.Kind != nil {
+		values["kind"] = *req.Kind
+	}
+	if req.Title != nil {
+		values["title"] = *req.Title
+	}
+	if req.Description != nil {
+		values["description"] = *req.Description
+	}
+	if req.StartsAt != nil {
+		values["starts_at"] = *req.StartsAt
+	}
+	if req.EndsAt != nil {
+		values["ends_at"] = *req.EndsAt
+	}
+
+	query, args, err := psql.Update("calendar_events").SetMap(values).Where(idInOrganization(ctx, id)).ToSql()
+	if err != nil {
+		return err
+	}
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update calendar event: %w", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		return fmt.Errorf("calendar event %w", ErrNotFound)
+	}
+	return nil
+}
+
+func (s *CalendarService) DeleteCalendarEvent(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM calendar_events WHERE id = $1 AND ($2::uuid IS NULL OR organization_id = $2)`,
+		id, organizationArg(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to delete calendar event: %w", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		return fmt.Errorf("calendar event %w", ErrNotFound)
+	}
+	return nil
+}
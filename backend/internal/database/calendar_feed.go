@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// newCalendarFeedSecret returns a random secret for a calendar feed.
+func newCalendarFeedSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate calendar feed secret: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// GetCalendarFeedSecret returns the secret the user's calendar feed token
+// is derived from, or "" when the user has never asked for the feed. It
+// returns ErrNotFound for a user who does not exist.
+func (s *UserService) GetCalendarFeedSecret(ctx context.Context, id uuid.UUID) (string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var secret sql.NullString
+	err := s.db.QueryRowContext(ctx, `
+		SELECT calendar_feed_secret FROM users
+		WHERE id = $1 AND ($2::uuid IS NULL OR organization_id = $2)`, id, organizationArg(ctx)).Scan(&secret)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("user %w", ErrNotFound)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get calendar feed secret: %w", err)
+	}
+	return secret.String, nil
+}
+
+// EnsureCalendarFeedSecret returns the user's calendar feed secret,
+// creating it the first time.
+func (s *UserService) EnsureCalendarFeedSecret(ctx context.Context, id uuid.UUID) (string, error) {
+	secret, err := s.GetCalendarFeedSecret(ctx, id)
+	if err != nil || secret != "" {
+		return secret, err
+	}
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	created, err := newCalendarFeedSecret()
+	if err != nil {
+		return "", err
+	}
+	// Two first requests at once agree on whichever secret was stored first
+	err = s.db.QueryRowContext(ctx, `
+		UPDATE users SET calendar_feed_secret = COALESCE(calendar_feed_secret, $3)
+		WHERE id = $1 AND ($2::uuid IS NULL OR organization_id = $2)
+		RETURNING calendar_feed_secret`, id, organizationArg(ctx), created).Scan(&secret)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("user %w", ErrNotFound)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to create calendar feed secret: %w", err)
+	}
+	return secret, nil
+}
+
+// RegenerateCalendarFeedSecret replaces the user's calendar feed secret,
+// so links to the feed given out before stop working, and returns the new
+// one.
+func (s *UserService) RegenerateCalendarFeedSecret(ctx context.Context, id uuid.UUID) (string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	secret, err := newCalendarFeedSecret()
+	if err != nil {
+		return "", err
+	}
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE users SET calendar_feed_secret = $3
+		WHERE id = $1 AND ($2::uuid IS NULL OR organization_id = $2)`, id, organizationArg(ctx), secret)
+	if err != nil {
+		return "", fmt.Errorf("failed to regenerate calendar feed secret: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return "", fmt.Errorf("user %w", ErrNotFound)
+	}
+	return secret, nil
+}
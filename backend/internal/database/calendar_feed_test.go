@@ -0,0 +1,45 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestCalendarFeedSecret(t *testing.T) {
+	db := testDB(t)
+	users := NewUserService(db)
+
+	user := &models.User{ID: uuid.New(), Name: "Planner", Email: "planner-" + uuid.NewString()[:8] + "@example.com", Password: "x", Role: models.RoleStaff, IsActive: true, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := users.CreateUser(ctx, user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	t.Cleanup(func() { db.Exec(`DELETE FROM users WHERE id = $1`, user.ID) })
+
+	if secret, err := users.GetCalendarFeedSecret(ctx, user.ID); err != nil || secret != "" {
+		t.Errorf("expected no secret before the feed is asked for, got %q, %v", secret, err)
+	}
+	first, err := users.EnsureCalendarFeedSecret(ctx, user.ID)
+	if err != nil || first == "" {
+		t.Fatalf("EnsureCalendarFeedSecret = %q, %v", first, err)
+	}
+	if again, _ := users.EnsureCalendarFeedSecret(ctx, user.ID); again != first {
+		t.Errorf("expected the secret to be kept, got %q then %q", first, again)
+	}
+
+	regenerated, err := users.RegenerateCalendarFeedSecret(ctx, user.ID)
+	if err != nil || regenerated == first {
+		t.Errorf("expected a new secret, got %q, %v", regenerated, err)
+	}
+	if stored, _ := users.GetCalendarFeedSecret(ctx, user.ID); stored != regenerated {
+		t.Errorf("expected %q to be stored, got %q", regenerated, stored)
+	}
+
+	if _, err := users.RegenerateCalendarFeedSecret(ctx, uuid.New()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unknown user, got %v", err)
+	}
+}
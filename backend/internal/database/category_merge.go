@@ -0,0 +1,106 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// ErrSameCategory is returned by MergeCategory for a category merged into
+// itself.
+var ErrSameCategory = errors.New("a category cannot be merged into itself")
+
+// MergeCategory moves the products of the source category to the target
+// category and deletes the source, in one transaction. Products refer to
+// their category by name, so they take the target's name. It returns the
+// outcome and the IDs of the products moved, or ErrNotFound when either
+// category is not in the organization of ctx.
+func (s *ProductService) MergeCategory(ctx context.Context, sourceID, targetID uuid.UUID) (*models.CategoryMerge, []uuid.UUID, error) {
+	if sourceID == targetID {
+		return nil, nil, ErrSameCategory
+	}
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock both, so neither is renamed or deleted halfway
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, name, description, created_at FROM categories
+		WHERE id = ANY($1::uuid[]) AND ($2::uuid IS NULL OR organization_id = $2)
+		ORDER BY id FOR UPDATE`, pq.Array([]string{sourceID.String(), targetID.String()}), organizationArg(ctx))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get categories: %w", err)
+	}
+	merge := &models.CategoryMerge{}
+	found := 0
+	for rows.Next() {
+		var category models.Category
+		if err := rows.Scan(&category.ID, &category.Name, &category.Description, &category.CreatedAt); err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("failed to scan category: %w", err)
+		}
+		if category.ID == sourceID {
+			merge.Source = category
+		} else {
+			merge.Target = category
+		}
+		found++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	if found != 2 {
+		return nil, nil, fmt.Errorf("category %w", ErrNotFound)
+	}
+
+	rows, err = tx.QueryContext(ctx, `
+		UPDATE products SET category = $1, updated_at = NOW()
+		WHERE category = $2 AND ($3::uuid IS NULL OR organization_id = $3)
+		RETURNING id`, merge.Target.Name, merge.Source.Name, organizationArg(ctx))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to move products: %w", err)
+	}
+	var moved []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("failed to scan product: %w", err)
+		}
+		moved = append(moved, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	merge.ProductsMoved = len(moved)
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM categories WHERE id = $1`, sourceID); err != nil {
+		return nil, nil, fmt.Errorf("failed to delete category: %w", err)
+	}
+	if err := queueSearchIndex(ctx, tx, models.SearchProducts, moved...); err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+
+	ids := make([]string, len(moved))
+	for i, id := range moved {
+		ids[i] = id.String()
+	}
+	s.cache.invalidateProducts(ctx, ids...)
+	return merge, moved, nil
+}
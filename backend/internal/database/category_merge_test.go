@@ -0,0 +1,68 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestMergeCategorySame(t *testing.T) {
+	id := uuid.New()
+	if _, _, err := NewProductService(nil).MergeCategory(ctx, id, id); !errors.Is(err, ErrSameCategory) {
+		t.Errorf("expected ErrSameCategory, got %v", err)
+	}
+}
+
+func TestMergeCategory(t *testing.T) {
+	db := testDB(t)
+	categories := NewCategoryService(db)
+	products := NewProductService(db)
+
+	suffix := uuid.NewString()[:8]
+	source := &models.Category{ID: uuid.New(), Name: "Cables " + suffix, CreatedAt: time.Now()}
+	target := &models.Category{ID: uuid.New(), Name: "Cabling " + suffix, CreatedAt: time.Now()}
+	for _, category := range []*models.Category{source, target} {
+		if err := categories.CreateCategory(ctx, category); err != nil {
+			t.Fatalf("Failed to create category: %v", err)
+		}
+	}
+	moving := &models.Product{ID: uuid.New(), Name: "HDMI cable", SKU: "HDMI-" + suffix, Stock: 4, Category: source.Name}
+	staying := &models.Product{ID: uuid.New(), Name: "Patch cable", SKU: "PATCH-" + suffix, Stock: 2, Category: target.Name}
+	for _, product := range []*models.Product{moving, staying} {
+		if err := products.CreateProduct(ctx, product); err != nil {
+			t.Fatalf("Failed to create product: %v", err)
+		}
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM products WHERE id IN ($1, $2)`, moving.ID, staying.ID)
+		db.Exec(`DELETE FROM categories WHERE id IN ($1, $2)`, source.ID, target.ID)
+	})
+
+	merge, moved, err := products.MergeCategory(ctx, source.ID, target.ID)
+	if err != nil {
+		t.Fatalf("MergeCategory: %v", err)
+	}
+	if merge.Source.Name != source.Name || merge.Target.Name != target.Name || merge.ProductsMoved != 1 {
+		t.Errorf("unexpected merge %+v", merge)
+	}
+	if len(moved) != 1 || moved[0] != moving.ID {
+		t.Errorf("expected only %s to move, got %v", moving.ID, moved)
+	}
+	product, err := products.GetProduct(ctx, moving.ID)
+	if err != nil || product.Category != target.Name {
+		t.Errorf("expected the product to take the target's name, got %+v, %v", product, err)
+	}
+
+	// The source is gone, and a category of another organization is not found
+	if _, _, err := products.MergeCategory(ctx, source.ID, target.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a merged category, got %v", err)
+	}
+	other := WithOrganization(ctx, uuid.New())
+	if _, _, err := products.MergeCategory(other, target.ID, uuid.New()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound in another organization, got %v", err)
+	}
+}
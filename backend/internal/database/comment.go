@@ -0,0 +1,225 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// maxMentions is the most users one comment notifies.
+const maxMentions = 20
+
+// mentionPattern matches @ followed by an email address or the part of one
+// before the @, when it starts a word, so the domain of an email in the
+// text is not read as a mention.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w.@])@([\w.%+-]+(?:@[\w-]+(?:\.[\w-]+)+)?)`)
+
+const commentColumns = `c.id, c.product_id, c.movement_id, c.author_id, COALESCE(u.name, ''), c.body, c.mentions, c.created_at`
+
+func scanComment(row interface{ Scan(...interface{}) error }, c *models.Comment) error {
+	var movementID, authorID uuid.NullUUID
+	var mentions []string
+
+	err := row.Scan(&c.ID, &c.ProductID, &movementID, &authorID, &c.AuthorName, &c.Body, pq.Array(&mentions), &c.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	if movementID.Valid {
+		c.MovementID = &movementID.UUID
+	}
+	if authorID.Valid {
+		c.AuthorID = &authorID.UUID
+	}
+	c.Mentions = make([]uuid.UUID, 0, len(mentions))
+	for _, mention := range mentions {
+		if id, err := uuid.Parse(mention); err == nil {
+			c.Mentions = append(c.Mentions, id)
+		}
+	}
+	return nil
+}
+
+// CommentService handles the comments on products and stock movements.
+type CommentService struct {
+	db *sql.DB
+}
+
+func NewCommentService(db *sql.DB) *CommentService {
+	return &CommentService{db: db}
+}
+
+func (s *CommentService) queryComments(ctx context.Context, condition string, args ...interface{}) ([]models.Comment, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+commentColumns+`
+		FROM comments c LEFT JOIN users u ON u.id = c.author_id
+		WHERE `+condition+` ORDER BY c.created_at, c.id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comments: %w", err)
+	}
+	defer rows.Close()
+
+	comments := []models.Comment{}
+	for rows.Next() {
+		var comment models.Comment
+		if err := scanComment(rows, &comment); err != nil {
+			return nil, fmt.Errorf("failed to scan comment: %w", err)
+		}
+		comments = append(comments, comment)
+	}
+	return comments, rows.Err()
+}
+
+// GetProductComments returns the comments on a product, oldest first,
+// leaving out those on its stock movements.
+func (s *CommentService) GetProductComments(ctx context.Context, productID uuid.UUID) ([]models.Comment, error) {
+	return s.queryComments(ctx, `c.product_id = $1 AND c.movement_id IS NULL
+		AND ($2::uuid IS NULL OR c.organization_id = $2)`, productID, organizationArg(ctx))
+}
+
+// GetMovementComments returns the comments on a stock movement, oldest
+// first.
+func (s *CommentService) GetMovementComments(ctx context.Context, movementID uuid.UUID) ([]models.Comment, error) {
+	return s.queryComments(ctx, `c.movement_id = $1 AND ($2::uuid IS NULL OR c.organization_id = $2)`,
+		movementID, organizationArg(ctx))
+}
+
+func (s *CommentService) GetComment(ctx context.Context, id uuid.UUID) (*models.Comment, error) {
+	comments, err := s.queryComments(ctx, `c.id = $1 AND ($2::uuid IS NULL OR c.organization_id = $2)`, id, organizationArg(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if len(comments) == 0 {
+		return nil, fmt.Errorf("comment %w", ErrNotFound)
+	}
+	return &comments[0], nil
+}
+
+// CreateComment saves a comment in the organization of ctx.
+func (s *CommentService) CreateComment(ctx context.Context, comment *models.Comment) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	organizationID, ok := OrganizationFrom(ctx)
+	if !ok {
+		organizationID = DefaultOrganizationID
+	}
+	mentions := make([]string, len(comment.Mentions))
+	for i, id := range comment.Mentions {
+		mentions[i] = id.String()
+	}
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO comments (id, organization_id, product_id, movement_id, author_id, body, mentions, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7::uuid[], $8)`,
+		comment.ID, organizationID, comment.ProductID, comment.MovementID, comment.AuthorID, comment.Body,
+		pq.Array(mentions), comment.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create comment: %w", err)
+	}
+	return nil
+}
+
+func (s *CommentService) DeleteComment(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM comments WHERE id = $1 AND ($2::uuid IS NULL OR organization_id = $2)`,
+		id, organizationArg(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to delete comment: %w", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		return fmt.Errorf("comment %w", ErrNotFound)
+	}
+	return nil
+}
+
+// GetMentionedUsers returns the active users of the organization of ctx
+// that body mentions, at most maxMentions of them. A user is mentioned by
+// @ and their email address, or only the part before its @ when no other
+// user's address has the same part.
+func (s *CommentService) GetMentionedUsers(ctx context.Context, body string) ([]models.User, error) {
+	handles := mentionHandles(body)
+	if len(handles) == 0 {
+		return nil, nil
+	}
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, name, email, role, COALESCE(locale, '') FROM users
+		WHERE is_active = true AND ($2::uuid IS NULL OR organization_id = $2)
+			AND (LOWER(email) = ANY($1) OR LOWER(SPLIT_PART(email, '@', 1)) = ANY($1))`,
+		pq.Array(handles), organizationArg(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mentioned users: %w", err)
+	}
+	defer rows.Close()
+
+	var candidates []models.User
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.Locale); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		candidates = append(candidates, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return matchMentions(handles, candidates), nil
+}
+
+// mentionHandles returns what body mentions, lowercased, each once, in the
+// order they appear.
+func mentionHandles(body string) []string {
+	var handles []string
+	seen := map[string]bool{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		handle := strings.ToLower(strings.TrimRight(match[1], "."))
+		if handle == "" || seen[handle] {
+			continue
+		}
+		seen[handle] = true
+		handles = append(handles, handle)
+	}
+	return handles
+}
+
+// matchMentions returns the users handles mention, in the order of
+// handles. A handle without @ only mentions the one user whose address
+// has it before the @, and no one when several do.
+func matchMentions(handles []string, users []models.User) []models.User {
+	var mentioned []models.User
+	added := map[uuid.UUID]bool{}
+	for _, handle := range handles {
+		var matches []models.User
+		for _, user := range users {
+			email := strings.ToLower(user.Email)
+			local, _, _ := strings.Cut(email, "@")
+			if email == handle || (!strings.Contains(handle, "@") && local == handle) {
+				matches = append(matches, user)
+			}
+		}
+		if len(matches) != 1 || added[matches[0].ID] {
+			continue
+		}
+		added[matches[0].ID] = true
+		mentioned = append(mentioned, matches[0])
+		if len(mentioned) == maxMentions {
+			break
+		}
+	}
+	return mentioned
+}
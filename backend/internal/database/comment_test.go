@@ -0,0 +1,61 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestMentionHandles(t *testing.T) {
+	tests := []struct {
+		body string
+		want []string
+	}{
+		{"no mentions here", nil},
+		{"@budi please recount", []string{"budi"}},
+		{"cc @Budi.Santoso and @siti@example.com.", []string{"budi.santoso", "siti@example.com"}},
+		{"@budi, @budi and @BUDI", []string{"budi"}},
+		// An email address in the text is not a mention
+		{"mail budi@example.com about it", nil},
+		{"(@siti) counted 12", []string{"siti"}},
+		{"@ alone", nil},
+	}
+
+	for _, tt := range tests {
+		if got := mentionHandles(tt.body); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("mentionHandles(%q) = %v, want %v", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestMatchMentions(t *testing.T) {
+	budi := models.User{ID: uuid.New(), Email: "Budi@example.com"}
+	siti := models.User{ID: uuid.New(), Email: "siti@example.com"}
+	sitiWarehouse := models.User{ID: uuid.New(), Email: "siti@warehouse.example.com"}
+	users := []models.User{budi, siti, sitiWarehouse}
+
+	tests := []struct {
+		name    string
+		handles []string
+		want    []uuid.UUID
+	}{
+		{"local part", []string{"budi"}, []uuid.UUID{budi.ID}},
+		{"full address", []string{"siti@warehouse.example.com"}, []uuid.UUID{sitiWarehouse.ID}},
+		{"ambiguous local part", []string{"siti"}, nil},
+		{"unknown", []string{"andi", "andi@example.com"}, nil},
+		{"same user twice", []string{"budi", "budi@example.com", "siti@example.com"}, []uuid.UUID{budi.ID, siti.ID}},
+	}
+
+	for _, tt := range tests {
+		var got []uuid.UUID
+		for _, user := range matchMentions(tt.handles, users) {
+			got = append(got, user.ID)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+)
+
+// ErrInvalidCursor is returned by DecodeCursor for a cursor this server
+// did not issue.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks the last row of a page for keyset pagination. Rows are
+// ordered newest first by created_at, then id, so the next page starts
+// right after the cursor however many rows were inserted in between.
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+}
+
+// Encode returns the cursor as an opaque string for clients.
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a cursor from Encode. An empty string is the first
+// page and returns nil.
+func DecodeCursor(encoded string) (*Cursor, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == uuid.Nil {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// keyset orders query newest first, starts it after cursor and fetches one
+// row more than limit, which tells whether there is a next page.
+func keyset(query sq.SelectBuilder, after *Cursor, limit int) sq.SelectBuilder {
+	if after != nil {
+		query = query.Where(sq.Expr("(created_at, id) < (?, ?)", after.CreatedAt, after.ID))
+	}
+	return query.OrderBy("created_at DESC", "id DESC").Limit(uint64(limit + 1))
+}
@@ -0,0 +1,208 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const ediDocumentColumns = `id, partner, organization_id, control_number, items, filename, transport, status, attempts,
+	COALESCE(error, ''), created_at, delivered_at`
+
+// maxEDIControlNumber is the largest interchange control number, which has
+// nine digits; numbers start over at 1 after it.
+const maxEDIControlNumber = 999999999
+
+func scanEDIDocument(row interface{ Scan(...interface{}) error }, d *models.EDIDocument, extra ...interface{}) error {
+	var deliveredAt sql.NullTime
+
+	dest := append([]interface{}{&d.ID, &d.Partner, &d.OrganizationID, &d.ControlNumber, &d.Items, &d.Filename,
+		&d.Transport, &d.Status, &d.Attempts, &d.Error, &d.CreatedAt, &deliveredAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+
+	if deliveredAt.Valid {
+		d.DeliveredAt = &deliveredAt.Time
+	}
+	return nil
+}
+
+// EDIService stores the EDI documents generated for trading partners and
+// reads the inventory they advise. The partners themselves are configured
+// in settings.
+type EDIService struct {
+	db *sql.DB
+}
+
+func NewEDIService(db *sql.DB) *EDIService {
+	return &EDIService{db: db}
+}
+
+// NextEDIControlNumber returns the next interchange control number of
+// partner, starting at 1.
+func (s *EDIService) NextEDIControlNumber(ctx context.Context, partner string) (int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var number int
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO edi_control_numbers (partner, last_number) VALUES ($1, 1)
+		ON CONFLICT (partner) DO UPDATE SET last_number =
+			CASE WHEN edi_control_numbers.last_number >= $2 THEN 1 ELSE edi_control_numbers.last_number + 1 END
+		RETURNING last_number`, partner, maxEDIControlNumber).Scan(&number)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get EDI control number: %w", err)
+	}
+	return number, nil
+}
+
+// GetEDIInventory returns the products of an organization in the given
+// categories, or in every category if there are none, by SKU.
+func (s *EDIService) GetEDIInventory(ctx context.Context, organizationID uuid.UUID, categories []string) ([]models.Product, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	if categories == nil {
+		categories = []string{}
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT `+productColumns+` FROM products
+		WHERE organization_id = $1 AND (cardinality($2::text[]) = 0 OR category = ANY($2))
+		ORDER BY sku`, organizationID, pq.Array(categories))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get EDI inventory: %w", err)
+	}
+	defer rows.Close()
+
+	products := []models.Product{}
+	for rows.Next() {
+		var product models.Product
+		if err := scanProduct(rows, &product); err != nil {
+			return nil, fmt.Errorf("failed to scan product: %w", err)
+		}
+		products = append(products, product)
+	}
+	return products, rows.Err()
+}
+
+// CreateEDIDocument saves a document before its delivery is attempted.
+func (s *EDIService) CreateEDIDocument(ctx context.Context, d *models.EDIDocument) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	d.ID = uuid.New()
+	d.Status = models.EDIPending
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO edi_documents (id, partner, organization_id, control_number, items, filename, content, transport, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING created_at`,
+		d.ID, d.Partner, d.OrganizationID, d.ControlNumber, d.Items, d.Filename, d.Content, d.Transport, d.Status).Scan(&d.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save EDI document: %w", err)
+	}
+	return nil
+}
+
+// RecordEDIDelivery records an attempt to deliver a document: delivered
+// if deliveryErr is nil, failed with its message otherwise.
+func (s *EDIService) RecordEDIDelivery(ctx context.Context, d *models.EDIDocument, deliveryErr error) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	d.Attempts++
+	d.Status, d.Error, d.DeliveredAt = models.EDIDelivered, "", nil
+	if deliveryErr != nil {
+		d.Status, d.Error = models.EDIFailed, deliveryErr.Error()
+	} else {
+		now := time.Now()
+		d.DeliveredAt = &now
+	}
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE edi_documents SET status = $2, attempts = $3, error = NULLIF($4, ''), delivered_at = $5, transport = $6
+		WHERE id = $1`, d.ID, d.Status, d.Attempts, d.Error, d.DeliveredAt, d.Transport)
+	if err != nil {
+		return fmt.Errorf("failed to record EDI delivery: %w", err)
+	}
+	return nil
+}
+
+// GetEDIDocuments returns the documents matching filter, newest first,
+// without their content.
+func (s *EDIService) GetEDIDocuments(ctx context.Context, filter models.EDIDocumentFilter) ([]models.EDIDocument, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT `+ediDocumentColumns+` FROM edi_documents
+		WHERE ($1 = '' OR partner = $1) AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC LIMIT $3`, filter.Partner, string(filter.Status), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get EDI documents: %w", err)
+	}
+	defer rows.Close()
+
+	documents := []models.EDIDocument{}
+	for rows.Next() {
+		var document models.EDIDocument
+		if err := scanEDIDocument(rows, &document); err != nil {
+			return nil, fmt.Errorf("failed to scan EDI document: %w", err)
+		}
+		documents = append(documents, document)
+	}
+	return documents, rows.Err()
+}
+
+// GetEDIDocument returns a document with its content.
+func (s *EDIService) GetEDIDocument(ctx context.Context, id uuid.UUID) (*models.EDIDocument, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var document models.EDIDocument
+	row := s.db.QueryRowContext(ctx, `SELECT `+ediDocumentColumns+`, content FROM edi_documents WHERE id = $1`, id)
+	if err := scanEDIDocument(row, &document, &document.Content); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("EDI document %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get EDI document: %w", err)
+	}
+	return &document, nil
+}
+
+// GetLastEDIDocument returns the newest document of partner without its
+// content, or nil if it has none.
+func (s *EDIService) GetLastEDIDocument(ctx context.Context, partner string) (*models.EDIDocument, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var document models.EDIDocument
+	row := s.db.QueryRowContext(ctx, `SELECT `+ediDocumentColumns+` FROM edi_documents
+		WHERE partner = $1 ORDER BY created_at DESC LIMIT 1`, partner)
+	if err := scanEDIDocument(row, &document); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get last EDI document: %w", err)
+	}
+	return &document, nil
+}
+
+// PurgeEDIDocuments deletes the documents created before the given time.
+func (s *EDIService) PurgeEDIDocuments(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM edi_documents WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge EDI documents: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,212 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const attachmentColumns = `id, movement_id, storage_key, filename, content_type, size, uploaded_by, created_at`
+
+func scanAttachment(row interface{ Scan(...interface{}) error }, a *models.MovementAttachment) error {
+	var uploadedBy uuid.NullUUID
+	if err := row.Scan(&a.ID, &a.MovementID, &a.StorageKey, &a.Filename, &a.ContentType, &a.Size, &uploadedBy, &a.CreatedAt); err != nil {
+		return err
+	}
+	if uploadedBy.Valid {
+		a.UploadedBy = &uploadedBy.UUID
+	}
+	return nil
+}
+
+// FileService records the product images and stock movement attachments
+// kept in the object store.
+type FileService struct {
+	db *sql.DB
+}
+
+func NewFileService(db *sql.DB) *FileService {
+	return &FileService{db: db}
+}
+
+// GetProductImage returns the image of a product.
+func (s *FileService) GetProductImage(ctx context.Context, productID uuid.UUID) (*models.ProductImage, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var image models.ProductImage
+	var uploadedBy uuid.NullUUID
+	err := s.db.QueryRowContext(ctx, `SELECT product_id, storage_key, filename, content_type, size, uploaded_by, updated_at
+		FROM product_images WHERE product_id = $1`, productID).
+		Scan(&image.ProductID, &image.StorageKey, &image.Filename, &image.ContentType, &image.Size, &uploadedBy, &image.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("product image %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get product image: %w", err)
+	}
+	if uploadedBy.Valid {
+		image.UploadedBy = &uploadedBy.UUID
+	}
+	return &image, nil
+}
+
+// SetProductImage records the image of a product, replacing any it had,
+// and returns the storage key of the image it replaced or "".
+func (s *FileService) SetProductImage(ctx context.Context, image *models.ProductImage) (string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var previous sql.NullString
+	err := s.db.QueryRowContext(ctx, `
+		WITH previous AS (SELECT storage_key FROM product_images WHERE product_id = $1 FOR UPDATE)
+		INSERT INTO product_images (product_id, storage_key, filename, content_type, size, uploaded_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (product_id) DO UPDATE SET storage_key = EXCLUDED.storage_key, filename = EXCLUDED.filename,
+			content_type = EXCLUDED.content_type, size = EXCLUDED.size, uploaded_by = EXCLUDED.uploaded_by, updated_at = NOW()
+		RETURNING updated_at, (SELECT storage_key FROM previous)`,
+		image.ProductID, image.StorageKey, image.Filename, image.ContentType, image.Size, image.UploadedBy).
+		Scan(&image.UpdatedAt, &previous)
+	if err != nil {
+		return "", fmt.Errorf("failed to save product image: %w", err)
+	}
+	return previous.String, nil
+}
+
+// DeleteProductImage removes the image of a product and returns its
+// storage key.
+func (s *FileService) DeleteProductImage(ctx context.Context, productID uuid.UUID) (string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var key string
+	err := s.db.QueryRowContext(ctx, `DELETE FROM product_images WHERE product_id = $1 RETURNING storage_key`, productID).Scan(&key)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("product image %w", ErrNotFound)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to delete product image: %w", err)
+	}
+	return key, nil
+}
+
+// CreateMovementAttachment records a file attached to a stock movement.
+func (s *FileService) CreateMovementAttachment(ctx context.Context, a *models.MovementAttachment) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO movement_attachments (id, movement_id, storage_key, filename, content_type, size, uploaded_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING created_at`,
+		a.ID, a.MovementID, a.StorageKey, a.Filename, a.ContentType, a.Size, a.UploadedBy).Scan(&a.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save attachment: %w", err)
+	}
+	return nil
+}
+
+// GetMovementAttachments returns the files attached to a stock movement,
+// oldest first.
+func (s *FileService) GetMovementAttachments(ctx context.Context, movementID uuid.UUID) ([]models.MovementAttachment, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+attachmentColumns+` FROM movement_attachments
+		WHERE movement_id = $1 ORDER BY created_at`, movementID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attachments: %w", err)
+	}
+	defer rows.Close()
+
+	attachments := []models.MovementAttachment{}
+	for rows.Next() {
+		var a models.MovementAttachment
+		if err := scanAttachment(rows, &a); err != nil {
+			return nil, fmt.Errorf("failed to scan attachment: %w", err)
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, rows.Err()
+}
+
+// GetMovementAttachment returns a file attached to a stock movement.
+func (s *FileService) GetMovementAttachment(ctx context.Context, movementID, id uuid.UUID) (*models.MovementAttachment, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var a models.MovementAttachment
+	err := scanAttachment(s.db.QueryRowContext(ctx, `SELECT `+attachmentColumns+` FROM movement_attachments
+		WHERE id = $1 AND movement_id = $2`, id, movementID), &a)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("attachment %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attachment: %w", err)
+	}
+	return &a, nil
+}
+
+// DeleteMovementAttachment removes a file attached to a stock movement and
+// returns its storage key.
+func (s *FileService) DeleteMovementAttachment(ctx context.Context, movementID, id uuid.UUID) (string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var key string
+	err := s.db.QueryRowContext(ctx, `DELETE FROM movement_attachments WHERE id = $1 AND movement_id = $2
+		RETURNING storage_key`, id, movementID).Scan(&key)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("attachment %w", ErrNotFound)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to delete attachment: %w", err)
+	}
+	return key, nil
+}
+
+// DeleteOrphanedAttachments removes the attachments, added before cutoff,
+// of stock movements that no longer exist, such as those of deleted
+// products.
+func (s *FileService) DeleteOrphanedAttachments(ctx context.Context, cutoff time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM movement_attachments a WHERE a.created_at < $1
+		AND NOT EXISTS (SELECT 1 FROM stock_movements m WHERE m.id = a.movement_id)`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete orphaned attachments: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// StorageKeys returns the keys of every object a product image, stock
+// movement attachment or report refers to.
+func (s *FileService) StorageKeys(ctx context.Context) (map[string]bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT storage_key FROM product_images
+		UNION ALL SELECT storage_key FROM movement_attachments
+		UNION ALL SELECT storage_key FROM reports WHERE storage_key IS NOT NULL`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get storage keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := map[string]bool{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to scan storage key: %w", err)
+		}
+		keys[key] = true
+	}
+	return keys, rows.Err()
+}
@@ -0,0 +1,167 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const ingestFileColumns = `id, supplier, filename, checksum, organization_id, status, total_rows, applied, unchanged, failed,
+	COALESCE(error, ''), processed_at`
+
+func scanIngestFile(row interface{ Scan(...interface{}) error }, f *models.IngestFile, extra ...interface{}) error {
+	var organizationID uuid.NullUUID
+
+	dest := append([]interface{}{&f.ID, &f.Supplier, &f.Filename, &f.Checksum, &organizationID, &f.Status, &f.Rows, &f.Applied,
+		&f.Unchanged, &f.Failed, &f.Error, &f.ProcessedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+
+	if organizationID.Valid {
+		f.OrganizationID = &organizationID.UUID
+	}
+	return nil
+}
+
+// IngestService records the supplier files that were ingested. The
+// suppliers themselves are configured in settings.
+type IngestService struct {
+	db *sql.DB
+}
+
+func NewIngestService(db *sql.DB) *IngestService {
+	return &IngestService{db: db}
+}
+
+// IsIngestFileProcessed reports whether a file with the same name and
+// content was already processed for supplier.
+func (s *IngestService) IsIngestFileProcessed(ctx context.Context, supplier, filename, checksum string) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var processed bool
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM ingest_files
+		WHERE supplier = $1 AND filename = $2 AND checksum = $3)`, supplier, filename, checksum).Scan(&processed)
+	if err != nil {
+		return false, fmt.Errorf("failed to check ingested files: %w", err)
+	}
+	return processed, nil
+}
+
+// CreateIngestFile records a processed file with the results of its rows.
+func (s *IngestService) CreateIngestFile(ctx context.Context, f *models.IngestFile) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	results, err := json.Marshal(f.Results)
+	if err != nil {
+		return fmt.Errorf("failed to encode ingest results: %w", err)
+	}
+	if f.Results == nil {
+		results = []byte("[]")
+	}
+
+	f.ID = uuid.New()
+	err = s.db.QueryRowContext(ctx, `
+		INSERT INTO ingest_files (id, supplier, filename, checksum, organization_id, status, total_rows, applied, unchanged, failed,
+			error, results)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, ''), $12)
+		ON CONFLICT (supplier, filename, checksum) DO NOTHING
+		RETURNING processed_at`,
+		f.ID, f.Supplier, f.Filename, f.Checksum, f.OrganizationID, f.Status, f.Rows, f.Applied, f.Unchanged, f.Failed,
+		f.Error, results).Scan(&f.ProcessedAt)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("ingest file %s of %s already recorded", f.Filename, f.Supplier)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record ingest file: %w", err)
+	}
+	return nil
+}
+
+// GetIngestFiles returns the files matching filter, newest first, without
+// the results of their rows.
+func (s *IngestService) GetIngestFiles(ctx context.Context, filter models.IngestFileFilter) ([]models.IngestFile, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT `+ingestFileColumns+` FROM ingest_files
+		WHERE ($1 = '' OR supplier = $1) AND ($2 = '' OR status = $2)
+		ORDER BY processed_at DESC LIMIT $3`, filter.Supplier, string(filter.Status), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ingest files: %w", err)
+	}
+	defer rows.Close()
+
+	files := []models.IngestFile{}
+	for rows.Next() {
+		var file models.IngestFile
+		if err := scanIngestFile(rows, &file); err != nil {
+			return nil, fmt.Errorf("failed to scan ingest file: %w", err)
+		}
+		files = append(files, file)
+	}
+	return files, rows.Err()
+}
+
+// GetIngestFile returns a file with the results of its rows.
+func (s *IngestService) GetIngestFile(ctx context.Context, id uuid.UUID) (*models.IngestFile, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var file models.IngestFile
+	var results []byte
+	row := s.db.QueryRowContext(ctx, `SELECT `+ingestFileColumns+`, results FROM ingest_files WHERE id = $1`, id)
+	if err := scanIngestFile(row, &file, &results); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("ingest file %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get ingest file: %w", err)
+	}
+	if err := json.Unmarshal(results, &file.Results); err != nil {
+		return nil, fmt.Errorf("failed to decode ingest results: %w", err)
+	}
+	return &file, nil
+}
+
+// GetLastIngestFile returns the newest file of supplier without its
+// results, or nil if it has none.
+func (s *IngestService) GetLastIngestFile(ctx context.Context, supplier string) (*models.IngestFile, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var file models.IngestFile
+	row := s.db.QueryRowContext(ctx, `SELECT `+ingestFileColumns+` FROM ingest_files
+		WHERE supplier = $1 ORDER BY processed_at DESC LIMIT 1`, supplier)
+	if err := scanIngestFile(row, &file); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get last ingest file: %w", err)
+	}
+	return &file, nil
+}
+
+// PurgeIngestFiles deletes the records of files processed before the
+// given time.
+func (s *IngestService) PurgeIngestFiles(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM ingest_files WHERE processed_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge ingest files: %w", err)
+	}
+	return result.RowsAffected()
+}
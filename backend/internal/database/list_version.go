@@ -0,0 +1,48 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ListVersion identifies the state of a list: any insert or update moves
+// LastModified forward and any delete changes Count, so together they make
+// a cheap validator for conditional GETs.
+type ListVersion struct {
+	Count        int
+	LastModified time.Time
+}
+
+func queryListVersion(ctx context.Context, db *sql.DB, query string, args ...interface{}) (ListVersion, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var version ListVersion
+	var lastModified sql.NullTime
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&version.Count, &lastModified); err != nil {
+		return ListVersion{}, err
+	}
+	version.LastModified = lastModified.Time
+	return version, nil
+}
+
+// GetProductsVersion returns the version of the organization's products.
+func (s *ProductService) GetProductsVersion(ctx context.Context) (ListVersion, error) {
+	return queryListVersion(ctx, s.db, "SELECT COUNT(*), MAX(updated_at) FROM products WHERE ($1::uuid IS NULL OR organization_id = $1)", organizationArg(ctx))
+}
+
+// GetCategoriesVersion returns the version of the categories list.
+func (s *CategoryService) GetCategoriesVersion(ctx context.Context) (ListVersion, error) {
+	return queryListVersion(ctx, s.db, "SELECT COUNT(*), MAX(updated_at) FROM categories WHERE ($1::uuid IS NULL OR organization_id = $1)", organizationArg(ctx))
+}
+
+// GetNotificationsVersion returns the version of a user's notifications,
+// archived or not.
+func (s *NotificationService) GetNotificationsVersion(ctx context.Context, userID uuid.UUID) (ListVersion, error) {
+	return queryListVersion(ctx, s.db, `
+		SELECT COUNT(*), MAX(updated_at) FROM notifications
+		WHERE user_id = $1 AND delivered_at IS NOT NULL`, userID)
+}
@@ -0,0 +1,266 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// migrationLockTimeout is how long to wait for another instance that is
+// migrating, which may be in the middle of a long migration.
+const migrationLockTimeout = 15 * time.Minute
+
+var (
+	ErrDirtySchema  = errors.New("a previous migration failed and left the schema dirty; fix it by hand, then run `migrate force <version>`")
+	ErrUnversioned  = errors.New("the schema was created without the migrator; run `migrate force <version>` once to record its version")
+	ErrNoDownScript = errors.New("migration has no down script")
+)
+
+// Migration is one numbered schema change.
+type Migration struct {
+	Version int64
+	Name    string
+	// Reversible is whether it has a down script.
+	Reversible bool
+}
+
+// MigrationStatus describes where the schema stands.
+type MigrationStatus struct {
+	Version int64
+	Dirty   bool
+	Latest  int64
+	Pending []Migration
+}
+
+// LoadMigrations lists the NNN_name.up.sql and NNN_name.down.sql files in
+// fsys, sorted by version.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	files, err := iofs.New(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	defer files.Close()
+
+	var migrations []Migration
+	version, err := files.First()
+	for err == nil {
+		up, name, readErr := files.ReadUp(version)
+		if readErr != nil {
+			return nil, fmt.Errorf("migration %d has no up script", version)
+		}
+		up.Close()
+
+		migration := Migration{Version: int64(version), Name: name}
+		if down, _, err := files.ReadDown(version); err == nil {
+			down.Close()
+			migration.Reversible = true
+		}
+		migrations = append(migrations, migration)
+		version, err = files.Next(version)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return migrations, nil
+}
+
+// Migrator applies migrations with golang-migrate, which records the
+// current version in the schema_migrations table and holds a Postgres
+// advisory lock while migrating, so two instances never migrate at once.
+type Migrator struct {
+	db         *sql.DB
+	fsys       fs.FS
+	migrations []Migration
+}
+
+func NewMigrator(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, fsys: fsys, migrations: migrations}, nil
+}
+
+// Up applies every pending migration and returns how many ran.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+	err := m.run(ctx, func(migrator *migrate.Migrate) error {
+		before, err := m.currentVersion(ctx, migrator)
+		if err != nil {
+			return err
+		}
+		err = migrator.Up()
+		after, _ := readVersion(migrator)
+		applied = m.between(before, after)
+		if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+			return fmt.Errorf("migration %d failed: %w", m.next(after), err)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the newest steps migrations and returns how many ran.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	reverted := 0
+	err := m.run(ctx, func(migrator *migrate.Migrate) error {
+		before, err := m.currentVersion(ctx, migrator)
+		if err != nil {
+			return err
+		}
+		if applied := m.between(0, before); steps > applied {
+			steps = applied
+		}
+		if steps == 0 {
+			return nil
+		}
+		for i, checked := len(m.migrations)-1, 0; i >= 0 && checked < steps; i-- {
+			migration := m.migrations[i]
+			if migration.Version > before {
+				continue
+			}
+			if !migration.Reversible {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, ErrNoDownScript)
+			}
+			checked++
+		}
+
+		err = migrator.Steps(-steps)
+		after, _ := readVersion(migrator)
+		reverted = m.between(after, before)
+		if err != nil {
+			return fmt.Errorf("reverting migration %d failed: %w", after, err)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status reports the recorded version and the migrations not yet applied.
+func (m *Migrator) Status(ctx context.Context) (*MigrationStatus, error) {
+	status := &MigrationStatus{Pending: []Migration{}}
+	err := m.run(ctx, func(migrator *migrate.Migrate) error {
+		version, dirty, err := migrator.Version()
+		if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+			return err
+		}
+		status.Version, status.Dirty = int64(version), dirty
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, migration := range m.migrations {
+		status.Latest = migration.Version
+		if migration.Version > status.Version {
+			status.Pending = append(status.Pending, migration)
+		}
+	}
+	return status, nil
+}
+
+// Force records version as applied and clears the dirty flag without running
+// any SQL, for baselining an existing database or recovering from a failed
+// migration that was fixed by hand. Version 0 means no migrations applied.
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	return m.run(ctx, func(migrator *migrate.Migrate) error {
+		if version == 0 {
+			return migrator.Force(-1)
+		}
+		return migrator.Force(int(version))
+	})
+}
+
+// run runs fn with golang-migrate on a connection of its own, which it
+// closes afterwards; closing golang-migrate would otherwise close db.
+func (m *Migrator) run(ctx context.Context, fn func(migrator *migrate.Migrate) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	driver, err := postgres.WithConnection(ctx, conn, &postgres.Config{})
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to prepare migrations: %w", err)
+	}
+	files, err := iofs.New(m.fsys, ".")
+	if err != nil {
+		driver.Close()
+		return err
+	}
+	migrator, err := migrate.NewWithInstance("iofs", files, "postgres", driver)
+	if err != nil {
+		files.Close()
+		driver.Close()
+		return err
+	}
+	defer migrator.Close()
+	migrator.LockTimeout = migrationLockTimeout
+
+	return fn(migrator)
+}
+
+// currentVersion returns the recorded version, refusing to continue from a
+// dirty schema or one created before the migrator was in use.
+func (m *Migrator) currentVersion(ctx context.Context, migrator *migrate.Migrate) (int64, error) {
+	version, dirty, err := migrator.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		var hasUsers bool
+		err := m.db.QueryRowContext(ctx, `SELECT EXISTS (
+			SELECT FROM information_schema.tables WHERE table_schema = 'public' AND table_name = 'users'
+		)`).Scan(&hasUsers)
+		if err != nil {
+			return 0, err
+		}
+		if hasUsers {
+			return 0, ErrUnversioned
+		}
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, ErrDirtySchema
+	}
+	return int64(version), nil
+}
+
+// readVersion returns the recorded version, 0 when none is.
+func readVersion(migrator *migrate.Migrate) (int64, bool) {
+	version, dirty, err := migrator.Version()
+	if err != nil {
+		return 0, false
+	}
+	return int64(version), dirty
+}
+
+// between counts the migrations after from up to and including to.
+func (m *Migrator) between(from, to int64) int {
+	n := 0
+	for _, migration := range m.migrations {
+		if migration.Version > from && migration.Version <= to {
+			n++
+		}
+	}
+	return n
+}
+
+// next returns the version of the migration after version.
+func (m *Migrator) next(version int64) int64 {
+	for _, migration := range m.migrations {
+		if migration.Version > version {
+			return migration.Version
+		}
+	}
+	return version
+}
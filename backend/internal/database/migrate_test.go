@@ -0,0 +1,50 @@
+package database
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"rtims-backend/migrations"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"002_add_locale.up.sql":   {Data: []byte("ALTER TABLE users ADD COLUMN locale TEXT;")},
+		"002_add_locale.down.sql": {Data: []byte("ALTER TABLE users DROP COLUMN locale;")},
+		"001_init.up.sql":         {Data: []byte("CREATE TABLE users (id INT);")},
+		"README.md":               {Data: []byte("not a migration")},
+	}
+
+	loaded, err := LoadMigrations(fsys)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(loaded) != 2 || loaded[0].Version != 1 || loaded[1].Version != 2 {
+		t.Fatalf("expected versions 1 and 2 in order, got %+v", loaded)
+	}
+	if loaded[1].Name != "add_locale" || !loaded[1].Reversible || loaded[0].Reversible {
+		t.Errorf("unexpected migrations %+v", loaded)
+	}
+
+	if _, err := LoadMigrations(fstest.MapFS{"003_orphan.down.sql": {Data: []byte("SELECT 1;")}}); err == nil {
+		t.Error("expected an error for a migration without an up script")
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	loaded, err := LoadMigrations(migrations.FS)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, migration := range loaded {
+		if migration.Version != int64(i+1) {
+			t.Errorf("expected migration %d, got %d_%s", i+1, migration.Version, migration.Name)
+		}
+		if !migration.Reversible {
+			t.Errorf("migration %d_%s has no down script", migration.Version, migration.Name)
+		}
+	}
+	if latest := loaded[len(loaded)-1].Version; latest != SchemaVersion {
+		t.Errorf("SchemaVersion is %d but the newest migration is %d", SchemaVersion, latest)
+	}
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// SchemaVersion is the number of the newest file in backend/migrations.
+// Bump it with every new migration.
+const SchemaVersion = 43
+
+// CheckMigrations reports whether the database schema is fully migrated.
+// When the migrate command (or golang-migrate) applied the migrations their
+// schema_migrations table is checked; otherwise, as for databases created
+// by Postgres running the scripts on first start, the tables added by recent
+// migrations must exist.
+func CheckMigrations(ctx context.Context, db *sql.DB) error {
+	var hasVersionTable bool
+	err := db.QueryRowContext(ctx, `SELECT EXISTS (
+		SELECT FROM information_schema.tables WHERE table_schema = 'public' AND table_name = 'schema_migrations'
+	)`).Scan(&hasVersionTable)
+	if err != nil {
+		return err
+	}
+
+	if hasVersionTable {
+		var version int64
+		var dirty bool
+		err := db.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("no migrations applied")
+		}
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return fmt.Errorf("migration %d failed and left the schema dirty", version)
+		}
+		if version < SchemaVersion {
+			return fmt.Errorf("schema is at version %d, expected %d", version, SchemaVersion)
+		}
+		return nil
+	}
+
+	for _, table := range []string{"reports", "report_schedules", "report_shares", "dashboard_snapshots"} {
+		var exists bool
+		err := db.QueryRowContext(ctx, `SELECT EXISTS (
+			SELECT FROM information_schema.tables WHERE table_schema = 'public' AND table_name = $1
+		)`, table).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("table %s is missing", table)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// movementImportBatchSize is how many movements go into one INSERT, and
+// how often an import records its progress.
+const movementImportBatchSize = 500
+
+const movementImportColumns = `id, filename, status, total_rows, processed_rows, imported, invalid, COALESCE(error, ''),
+	requested_by, created_at, started_at, completed_at`
+
+func scanMovementImport(row interface{ Scan(...interface{}) error }, m *models.MovementImport, extra ...interface{}) error {
+	var requestedBy uuid.NullUUID
+	var startedAt, completedAt sql.NullTime
+
+	dest := append([]interface{}{&m.ID, &m.Filename, &m.Status, &m.Rows, &m.Processed, &m.Imported, &m.Invalid, &m.Error,
+		&requestedBy, &m.CreatedAt, &startedAt, &completedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+
+	if requestedBy.Valid {
+		m.RequestedBy = &requestedBy.UUID
+	}
+	if startedAt.Valid {
+		m.StartedAt = &startedAt.Time
+	}
+	if completedAt.Valid {
+		m.CompletedAt = &completedAt.Time
+	}
+	return nil
+}
+
+// MovementImportService records stock movement imports and imports their
+// movements.
+type MovementImportService struct {
+	db *sql.DB
+}
+
+func NewMovementImportService(db *sql.DB) *MovementImportService {
+	return &MovementImportService{db: db}
+}
+
+// CreateMovementImport records a queued import of a file in the
+// organization of ctx, its invalid rows already processed. checksum
+// identifies the file's content; importing it again while an earlier import
+// of it has not failed violates stock_movement_imports_content.
+func (s *MovementImportService) CreateMovementImport(ctx context.Context, m *models.MovementImport, checksum string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	organizationID, ok := OrganizationFrom(ctx)
+	if !ok {
+		organizationID = DefaultOrganizationID
+	}
+
+	m.ID = uuid.New()
+	m.Status = models.MovementImportQueued
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO stock_movement_imports (id, organization_id, filename, checksum, status, total_rows, processed_rows, invalid,
+			requested_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7, $8)
+		RETURNING created_at`,
+		m.ID, organizationID, m.Filename, checksum, m.Status, m.Rows, m.Invalid, m.RequestedBy).Scan(&m.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record movement import: %w", err)
+	}
+	return nil
+}
+
+// GetMovementImports returns the latest imports of the organization of
+// ctx, newest first, without their results.
+func (s *MovementImportService) GetMovementImports(ctx context.Context, limit int) ([]models.MovementImport, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+movementImportColumns+` FROM stock_movement_imports
+		WHERE ($1::uuid IS NULL OR organization_id = $1)
+		ORDER BY created_at DESC LIMIT $2`, organizationArg(ctx), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get movement imports: %w", err)
+	}
+	defer rows.Close()
+
+	imports := []models.MovementImport{}
+	for rows.Next() {
+		var m models.MovementImport
+		if err := scanMovementImport(rows, &m); err != nil {
+			return nil, fmt.Errorf("failed to scan movement import: %w", err)
+		}
+		imports = append(imports, m)
+	}
+	return imports, rows.Err()
+}
+
+// GetMovementImport returns an import of the organization of ctx with the
+// rows it could not import.
+func (s *MovementImportService) GetMovementImport(ctx context.Context, id uuid.UUID) (*models.MovementImport, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var m models.MovementImport
+	var results []byte
+	row := s.db.QueryRowContext(ctx, `SELECT `+movementImportColumns+`, results FROM stock_movement_imports
+		WHERE id = $1 AND ($2::uuid IS NULL OR organization_id = $2)`, id, organizationArg(ctx))
+	if err := scanMovementImport(row, &m, &results); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("movement import %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get movement import: %w", err)
+	}
+	if err := json.Unmarshal(results, &m.Results); err != nil {
+		return nil, fmt.Errorf("failed to decode movement import results: %w", err)
+	}
+	return &m, nil
+}
+
+// MarkMovementImportRunning records that an import started.
+func (s *MovementImportService) MarkMovementImportRunning(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `UPDATE stock_movement_imports SET status = $1, started_at = NOW() WHERE id = $2`,
+		models.MovementImportRunning, id)
+	return err
+}
+
+// MarkMovementImportFailed records that an import failed, and nothing of it
+// was imported.
+func (s *MovementImportService) MarkMovementImportFailed(ctx context.Context, id uuid.UUID, reason string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `UPDATE stock_movement_imports SET status = $1, error = $2, imported = 0, completed_at = NOW()
+		WHERE id = $3`, models.MovementImportFailed, reason, id)
+	return err
+}
+
+// FailInterruptedMovementImports marks imports left queued or running by a
+// previous process as failed. Their transaction was rolled back when it
+// exited, so they can be imported again.
+func (s *MovementImportService) FailInterruptedMovementImports(ctx context.Context) (int64, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `UPDATE stock_movement_imports
+		SET status = $1, error = 'interrupted by server restart', imported = 0, completed_at = NOW()
+		WHERE status IN ($2, $3)`, models.MovementImportFailed, models.MovementImportQueued, models.MovementImportRunning)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// ImportMovements records the movements of import id in one transaction,
+// so either all of them are imported or none. The import's progress is
+// recorded after every batch, outside the transaction, so it can be
+// followed while the import runs. Once all are in, the import is completed
+// with results, the rows it could not import. The movements do not change
+// their products' stock, and no events are sent for them, since they
+// happened long ago.
+func (s *MovementImportService) ImportMovements(ctx context.Context, id uuid.UUID, movements []models.StockMovement, results []models.MovementImportRow) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	encoded, err := json.Marshal(results)
+	if err != nil {
+		return fmt.Errorf("failed to encode movement import results: %w", err)
+	}
+	if results == nil {
+		encoded = []byte("[]")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for start := 0; start < len(movements); start += movementImportBatchSize {
+		batch := movements[start:min(start+movementImportBatchSize, len(movements))]
+
+		rows := make([]string, len(batch))
+		args := make([]interface{}, 0, len(batch)*7)
+		ids := make([]uuid.UUID, len(batch))
+		for i, movement := range batch {
+			n := len(args)
+			rows[i] = fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6, n+7)
+			args = append(args, movement.ID, movement.ProductID, movement.Change, movement.Reason, movement.CreatedBy,
+				movement.CreatedAt, movement.Notes)
+			ids[i] = movement.ID
+		}
+		_, err := tx.ExecContext(ctx, `INSERT INTO stock_movements (id, product_id, change, reason, created_by, created_at, notes)
+			VALUES `+strings.Join(rows, ", "), args...)
+		if err != nil {
+			return fmt.Errorf("failed to import stock movements: %w", err)
+		}
+		if err := queueSearchIndex(ctx, tx, models.SearchStockMovements, ids...); err != nil {
+			return err
+		}
+
+		// Invalid rows count as processed from the start
+		done := start + len(batch)
+		_, err = s.db.ExecContext(ctx, `UPDATE stock_movement_imports SET processed_rows = invalid + $1 WHERE id = $2`, done, id)
+		if err != nil {
+			return fmt.Errorf("failed to record movement import progress: %w", err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE stock_movement_imports
+		SET status = $1, processed_rows = total_rows, imported = $2, results = $3, completed_at = $4
+		WHERE id = $5`, models.MovementImportCompleted, len(movements), string(encoded), time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to complete movement import: %w", err)
+	}
+	return tx.Commit()
+}
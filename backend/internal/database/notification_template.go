@@ -0,0 +1,141 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"rtims-backend/internal/i18n"
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.]+)\s*\}\}`)
+
+type NotificationTemplateService struct {
+	db *sql.DB
+}
+
+func NewNotificationTemplateService(db *sql.DB) *NotificationTemplateService {
+	return &NotificationTemplateService{db: db}
+}
+
+func (s *NotificationTemplateService) GetTemplates() ([]models.NotificationTemplate, error) {
+	query := `SELECT key, type, body, COALESCE(description, ''), updated_by, updated_at
+			  FROM notification_templates ORDER BY key`
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification templates: %w", err)
+	}
+	defer rows.Close()
+
+	templates := []models.NotificationTemplate{}
+	for rows.Next() {
+		var t models.NotificationTemplate
+		var updatedBy uuid.NullUUID
+		if err := rows.Scan(&t.Key, &t.Type, &t.Body, &t.Description, &updatedBy, &t.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan notification template: %w", err)
+		}
+		if updatedBy.Valid {
+			t.UpdatedBy = &updatedBy.UUID
+		}
+		templates = append(templates, t)
+	}
+
+	return templates, nil
+}
+
+func (s *NotificationTemplateService) GetTemplate(key string) (*models.NotificationTemplate, error) {
+	query := `SELECT key, type, body, COALESCE(description, ''), updated_by, updated_at
+			  FROM notification_templates WHERE key = $1`
+
+	var t models.NotificationTemplate
+	var updatedBy uuid.NullUUID
+	err := s.db.QueryRow(query, key).Scan(&t.Key, &t.Type, &t.Body, &t.Description, &updatedBy, &t.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("notification template %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get notification template: %w", err)
+	}
+	if updatedBy.Valid {
+		t.UpdatedBy = &updatedBy.UUID
+	}
+
+	return &t, nil
+}
+
+func (s *NotificationTemplateService) UpdateTemplate(key, body string, updatedBy uuid.UUID) error {
+	query := `UPDATE notification_templates SET body = $1, updated_by = $2, updated_at = NOW() WHERE key = $3`
+
+	result, err := s.db.Exec(query, body, updatedBy, key)
+	if err != nil {
+		return fmt.Errorf("failed to update notification template: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("notification template %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// Render loads the template stored under key and interpolates vars into it.
+// If the template cannot be loaded, fallback is rendered instead so that a
+// missing row never blocks a notification.
+func (s *NotificationTemplateService) Render(key string, vars map[string]interface{}, fallback string) string {
+	body := fallback
+	if t, err := s.GetTemplate(key); err == nil {
+		body = t.Body
+	} else {
+		log.Printf("Using built-in text for notification template %s: %v", key, err)
+	}
+	return RenderTemplate(body, vars)
+}
+
+// RenderIn is Render for a recipient who reads language. Stored templates
+// are written in English, so when the i18n bundle for language translates
+// fallback, the translation is rendered instead.
+func (s *NotificationTemplateService) RenderIn(key, language string, vars map[string]interface{}, fallback string) string {
+	if translated := i18n.T(language, fallback); translated != fallback {
+		return RenderTemplate(translated, vars)
+	}
+	return s.Render(key, vars, fallback)
+}
+
+// RenderTemplate replaces {{name}} and {{object.field}} placeholders with
+// values from vars. Unknown placeholders are left untouched so mistakes are
+// visible in the delivered message.
+func RenderTemplate(body string, vars map[string]interface{}) string {
+	return placeholderPattern.ReplaceAllStringFunc(body, func(match string) string {
+		path := placeholderPattern.FindStringSubmatch(match)[1]
+		if value, ok := lookupPath(vars, strings.Split(path, ".")); ok {
+			return fmt.Sprintf("%v", value)
+		}
+		return match
+	})
+}
+
+func lookupPath(vars map[string]interface{}, path []string) (interface{}, bool) {
+	value, ok := vars[path[0]]
+	if !ok {
+		return nil, false
+	}
+	if len(path) == 1 {
+		return value, true
+	}
+
+	nested, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	return lookupPath(nested, path[1:])
+}
@@ -0,0 +1,33 @@
+package database
+
+import "testing"
+
+func TestRenderTemplate(t *testing.T) {
+	vars := map[string]interface{}{
+		"stock": 3,
+		"product": map[string]interface{}{
+			"name": "Widget",
+			"sku":  "W-1",
+		},
+	}
+
+	tests := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{"simple variable", "{{stock}} left", "3 left"},
+		{"nested variable", "Product '{{product.name}}' ({{ product.sku }})", "Product 'Widget' (W-1)"},
+		{"unknown variable", "Hello {{user.name}}", "Hello {{user.name}}"},
+		{"path through scalar", "{{stock.value}}", "{{stock.value}}"},
+		{"no placeholders", "Plain text", "Plain text"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RenderTemplate(tt.body, vars); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// notificationUser creates a user to own notifications, removed with them
+// when the test ends.
+func notificationUser(t *testing.T, users *UserService) uuid.UUID {
+	t.Helper()
+	user := &models.User{ID: uuid.New(), Name: "Reader", Email: "reader-" + uuid.NewString()[:8] + "@example.com", Password: "x", Role: models.RoleStaff, IsActive: true, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := users.CreateUser(ctx, user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	return user.ID
+}
+
+func createNotification(t *testing.T, notifications *NotificationService, userID uuid.UUID, scheduledAt *time.Time) uuid.UUID {
+	t.Helper()
+	notification := &models.Notification{ID: uuid.New(), UserID: userID, Message: "Stock is low", Type: models.NotificationLowStock, CreatedAt: time.Now(), ScheduledAt: scheduledAt}
+	if err := notifications.CreateNotification(notification); err != nil {
+		t.Fatalf("Failed to create notification: %v", err)
+	}
+	return notification.ID
+}
+
+func TestBulkUpdateSkipsScheduledNotifications(t *testing.T) {
+	db := testDB(t)
+	notifications := NewNotificationService(db)
+	userID := notificationUser(t, NewUserService(db))
+	t.Cleanup(func() { db.Exec(`DELETE FROM users WHERE id = $1`, userID) })
+
+	later := time.Now().Add(time.Hour)
+	delivered := createNotification(t, notifications, userID, nil)
+	scheduled := createNotification(t, notifications, userID, &later)
+
+	affected, err := notifications.BulkUpdate(userID, models.BulkNotificationRequest{
+		Action: models.BulkActionDelete,
+		IDs:    []uuid.UUID{delivered, scheduled},
+	})
+	if err != nil || affected != 1 {
+		t.Fatalf("expected only the delivered notification deleted, got %d, %v", affected, err)
+	}
+	var remaining uuid.UUID
+	if err := db.QueryRow(`SELECT id FROM notifications WHERE user_id = $1`, userID).Scan(&remaining); err != nil || remaining != scheduled {
+		t.Errorf("expected the scheduled notification kept, got %v, %v", remaining, err)
+	}
+}
+
+func TestBulkNotificationQuery(t *testing.T) {
+	userID, first, second := uuid.New(), uuid.New(), uuid.New()
+
+	query, args, err := bulkNotificationQuery(userID, models.BulkNotificationRequest{Action: models.BulkActionDelete, IDs: []uuid.UUID{first, second}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "DELETE FROM notifications WHERE (user_id = $1 AND delivered_at IS NOT NULL AND id IN ($2,$3))"; query != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, query)
+	}
+	if !reflect.DeepEqual(args, []interface{}{userID.String(), first, second}) {
+		t.Errorf("unexpected args %v", args)
+	}
+
+	lowStock, unread, before := models.NotificationLowStock, false, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	query, args, err = bulkNotificationQuery(userID, models.BulkNotificationRequest{
+		Action: models.BulkActionMarkRead,
+		Filter: &models.BulkNotificationFilter{Type: &lowStock, IsRead: &unread, Before: &before},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "UPDATE notifications SET is_read = $1 WHERE (user_id = $2 AND delivered_at IS NOT NULL AND type = $3 AND is_read = $4 AND created_at < $5)"; query != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, query)
+	}
+	if !reflect.DeepEqual(args, []interface{}{true, userID.String(), lowStock, false, before}) {
+		t.Errorf("unexpected args %v", args)
+	}
+
+	query, _, err = bulkNotificationQuery(userID, models.BulkNotificationRequest{Action: models.BulkActionArchive, All: true})
+	if want := "UPDATE notifications SET is_archived = $1 WHERE (user_id = $2 AND delivered_at IS NOT NULL)"; err != nil || query != want {
+		t.Errorf("expected\n%s\ngot\n%s, %v", want, query, err)
+	}
+
+	for _, req := range []models.BulkNotificationRequest{
+		{Action: models.BulkActionDelete},
+		{Action: models.BulkActionDelete, Filter: &models.BulkNotificationFilter{}},
+	} {
+		if _, _, err := bulkNotificationQuery(userID, req); err == nil {
+			t.Errorf("expected %+v to be refused for selecting nothing", req)
+		}
+	}
+}
+
+// createAlert creates a critical notification delivered at deliveredAt.
+func createAlert(t *testing.T, notifications *NotificationService, userID uuid.UUID, deliveredAt time.Time) uuid.UUID {
+	t.Helper()
+	notification := &models.Notification{ID: uuid.New(), UserID: userID, Message: "Freezer is warm", Type: models.NotificationSystem, Priority: models.PriorityCritical, CreatedAt: deliveredAt}
+	if err := notifications.CreateNotification(notification); err != nil {
+		t.Fatalf("Failed to create alert: %v", err)
+	}
+	return notification.ID
+}
+
+func TestAcknowledge(t *testing.T) {
+	db := testDB(t)
+	notifications := NewNotificationService(db)
+	users := NewUserService(db)
+	userID, otherID := notificationUser(t, users), notificationUser(t, users)
+	t.Cleanup(func() { db.Exec(`DELETE FROM users WHERE id IN ($1, $2)`, userID, otherID) })
+
+	alert := createAlert(t, notifications, userID, time.Now())
+	normal := createNotification(t, notifications, userID, nil)
+
+	if err := notifications.Acknowledge(alert, otherID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected another user's alert to be refused, got %v", err)
+	}
+	if err := notifications.Acknowledge(normal, userID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected a normal notification to be refused, got %v", err)
+	}
+	if err := notifications.Acknowledge(alert, userID); err != nil {
+		t.Fatalf("Failed to acknowledge: %v", err)
+	}
+	if err := notifications.Acknowledge(alert, userID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected a second acknowledgement to be refused, got %v", err)
+	}
+
+	var acknowledgedBy uuid.UUID
+	var isRead bool
+	if err := db.QueryRow(`SELECT acknowledged_by, is_read FROM notifications WHERE id = $1`, alert).Scan(&acknowledgedBy, &isRead); err != nil {
+		t.Fatalf("Failed to load alert: %v", err)
+	}
+	if acknowledgedBy != userID || !isRead {
+		t.Errorf("expected the alert acknowledged by %s and read, got %s, %v", userID, acknowledgedBy, isRead)
+	}
+}
+
+func TestClaimUnacknowledged(t *testing.T) {
+	db := testDB(t)
+	notifications := NewNotificationService(db)
+	userID := notificationUser(t, NewUserService(db))
+	t.Cleanup(func() { db.Exec(`DELETE FROM users WHERE id = $1`, userID) })
+
+	cutoff := time.Now().Add(-30 * time.Minute)
+	overdue := createAlert(t, notifications, userID, cutoff.Add(-time.Minute))
+	acknowledged := createAlert(t, notifications, userID, cutoff.Add(-time.Minute))
+	recent := createAlert(t, notifications, userID, time.Now())
+	normal := createNotification(t, notifications, userID, nil)
+	db.Exec(`UPDATE notifications SET created_at = $2, delivered_at = $2 WHERE id = $1`, normal, cutoff.Add(-time.Minute))
+	if err := notifications.Acknowledge(acknowledged, userID); err != nil {
+		t.Fatalf("Failed to acknowledge: %v", err)
+	}
+
+	claimed := func() []uuid.UUID {
+		alerts, err := notifications.ClaimUnacknowledged(cutoff)
+		if err != nil {
+			t.Fatalf("Failed to claim: %v", err)
+		}
+		var ids []uuid.UUID
+		for _, alert := range alerts {
+			if alert.UserID == userID {
+				ids = append(ids, alert.ID)
+			}
+		}
+		return ids
+	}
+	if ids := claimed(); !reflect.DeepEqual(ids, []uuid.UUID{overdue}) {
+		t.Errorf("expected only %s claimed, got %v (recent %s)", overdue, ids, recent)
+	}
+	if ids := claimed(); len(ids) != 0 {
+		t.Errorf("expected an alert to be escalated once, got %v", ids)
+	}
+}
@@ -0,0 +1,168 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"rtims-backend/internal/models"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+)
+
+// DefaultOrganizationID is the organization data created before
+// organizations existed belongs to. Its admins manage the other
+// organizations.
+var DefaultOrganizationID = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+type organizationKey struct{}
+
+// WithOrganization scopes the queries made with ctx to one organization.
+// JWTAuth does this for every authenticated request.
+func WithOrganization(ctx context.Context, id uuid.UUID) context.Context {
+	return context.WithValue(ctx, organizationKey{}, id)
+}
+
+// OrganizationFrom returns the organization ctx is scoped to. Contexts
+// without one, such as those of background jobs and logins, see every
+// organization.
+func OrganizationFrom(ctx context.Context) (uuid.UUID, bool) {
+	id, ok := ctx.Value(organizationKey{}).(uuid.UUID)
+	return id, ok
+}
+
+// inOrganization adds a condition on column to conditions when ctx is
+// scoped to an organization.
+func inOrganization(ctx context.Context, conditions sq.And, column string) sq.And {
+	if id, ok := OrganizationFrom(ctx); ok {
+		return append(conditions, sq.Eq{column: id})
+	}
+	return conditions
+}
+
+// idInOrganization matches the row with id, if it belongs to the
+// organization of ctx.
+func idInOrganization(ctx context.Context, id uuid.UUID) sq.Eq {
+	condition := sq.Eq{"id": id}
+	if organizationID, ok := OrganizationFrom(ctx); ok {
+		condition["organization_id"] = organizationID
+	}
+	return condition
+}
+
+// organizationArg returns the organization of ctx as a query argument,
+// nil when ctx is unscoped, for hand-written queries that filter with
+// "($n::uuid IS NULL OR organization_id = $n)".
+func organizationArg(ctx context.Context) interface{} {
+	if id, ok := OrganizationFrom(ctx); ok {
+		return id
+	}
+	return nil
+}
+
+// OrganizationService manages organizations.
+type OrganizationService struct {
+	db *sql.DB
+}
+
+func NewOrganizationService(db *sql.DB) *OrganizationService {
+	return &OrganizationService{db: db}
+}
+
+const organizationColumns = "id, name, slug, is_active, created_at, updated_at"
+
+func scanOrganization(row interface{ Scan(...interface{}) error }, o *models.Organization) error {
+	return row.Scan(&o.ID, &o.Name, &o.Slug, &o.IsActive, &o.CreatedAt, &o.UpdatedAt)
+}
+
+func (s *OrganizationService) GetOrganizations(ctx context.Context) ([]models.Organization, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, "SELECT "+organizationColumns+" FROM organizations ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organizations: %w", err)
+	}
+	defer rows.Close()
+
+	organizations := []models.Organization{}
+	for rows.Next() {
+		var organization models.Organization
+		if err := scanOrganization(rows, &organization); err != nil {
+			return nil, fmt.Errorf("failed to scan organization: %w", err)
+		}
+		organizations = append(organizations, organization)
+	}
+	return organizations, rows.Err()
+}
+
+func (s *OrganizationService) GetOrganization(ctx context.Context, id uuid.UUID) (*models.Organization, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var organization models.Organization
+	err := scanOrganization(s.db.QueryRowContext(ctx, "SELECT "+organizationColumns+" FROM organizations WHERE id = $1", id), &organization)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("organization %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+	return &organization, nil
+}
+
+// CreateOrganization creates organization and its first admin together,
+// so there is never an organization nobody can log in to.
+func (s *OrganizationService) CreateOrganization(ctx context.Context, organization *models.Organization, admin *models.User) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO organizations (id, name, slug, is_active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		organization.ID, organization.Name, organization.Slug, organization.IsActive, organization.CreatedAt, organization.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO users (id, name, email, password, role, is_active, created_at, updated_at, organization_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		admin.ID, admin.Name, admin.Email, admin.Password, admin.Role, admin.IsActive, admin.CreatedAt, admin.UpdatedAt, organization.ID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *OrganizationService) UpdateOrganization(ctx context.Context, id uuid.UUID, req models.UpdateOrganizationRequest) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	values := map[string]interface{}{}
+	if req.Name != nil {
+		values["name"] = *req.Name
+	}
+	if req.IsActive != nil {
+		values["is_active"] = *req.IsActive
+	}
+	if len(values) == 0 {
+		return ErrNoUpdates
+	}
+	query, args, err := psql.Update("organizations").SetMap(values).Where(sq.Eq{"id": id}).ToSql()
+	if err != nil {
+		return err
+	}
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update organization: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("organization %w", ErrNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// outboxLease is how long a claimed event is hidden from other relays. An
+// event whose relay crashes before marking it published is claimed again
+// once the lease runs out.
+const outboxLease = time.Minute
+
+// MaxOutboxAttempts is how many times an event is claimed before the relay
+// gives up on it.
+const MaxOutboxAttempts = 10
+
+// enqueueEvent writes an event to the outbox in tx, so it is published if
+// and only if tx commits.
+func enqueueEvent(ctx context.Context, tx *sql.Tx, eventType models.OutboxEventType, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+	query := `INSERT INTO outbox_events (id, type, payload, created_at) VALUES ($1, $2, $3, $4)`
+	if _, err := tx.ExecContext(ctx, query, uuid.New(), eventType, body, time.Now()); err != nil {
+		return fmt.Errorf("failed to write %s event to outbox: %w", eventType, err)
+	}
+	return nil
+}
+
+// OutboxService lets the relay claim and publish outbox events.
+type OutboxService struct {
+	db *sql.DB
+}
+
+func NewOutboxService(db *sql.DB) *OutboxService {
+	return &OutboxService{db: db}
+}
+
+// Claim leases up to limit unpublished events, oldest first. Events claimed
+// by another relay whose lease has not run out are skipped.
+func (s *OutboxService) Claim(ctx context.Context, limit int) ([]models.OutboxEvent, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		UPDATE outbox_events SET attempts = attempts + 1, locked_until = $3
+		WHERE id IN (
+			SELECT id FROM outbox_events
+			WHERE published_at IS NULL AND attempts < $2 AND (locked_until IS NULL OR locked_until < NOW())
+			ORDER BY created_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, type, payload, created_at, attempts
+	`
+	rows, err := s.db.QueryContext(ctx, query, limit, MaxOutboxAttempts, time.Now().Add(outboxLease))
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []models.OutboxEvent
+	for rows.Next() {
+		var event models.OutboxEvent
+		if err := rows.Scan(&event.ID, &event.Type, &event.Payload, &event.CreatedAt, &event.Attempts); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox event: %w", err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+
+	// UPDATE ... RETURNING does not keep the subquery's order
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].CreatedAt.Before(events[j].CreatedAt)
+	})
+	return events, nil
+}
+
+// MarkPublished records that an event has been published.
+func (s *OutboxService) MarkPublished(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `UPDATE outbox_events SET published_at = NOW(), locked_until = NULL WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox event published: %w", err)
+	}
+	return nil
+}
+
+// PurgePublished deletes events published before the given time.
+func (s *OutboxService) PurgePublished(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM outbox_events WHERE published_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge outbox events: %w", err)
+	}
+	return result.RowsAffected()
+}
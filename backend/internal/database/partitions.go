@@ -0,0 +1,43 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// PartitionsAhead is how many months of partitions are kept ready beyond
+// the current one.
+const PartitionsAhead = 3
+
+// partitionedTables are partitioned by month, audit_logs on changed_at and
+// stock_movements on created_at. Rows outside every monthly partition land
+// in the table's _default partition.
+var partitionedTables = []string{"audit_logs", "stock_movements"}
+
+// EnsurePartitions creates the monthly partitions of every partitioned
+// table from the month of from through the following months, skipping
+// existing ones.
+func EnsurePartitions(ctx context.Context, db *sql.DB, from time.Time, months int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	start := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for _, table := range partitionedTables {
+		for i := 0; i <= months; i++ {
+			month := start.AddDate(0, i, 0)
+			query := fmt.Sprintf(
+				`CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')`,
+				partitionName(table, month), table, month.Format("2006-01-02"), month.AddDate(0, 1, 0).Format("2006-01-02"))
+			if _, err := db.ExecContext(ctx, query); err != nil {
+				return fmt.Errorf("failed to create %s partition for %s: %w", table, month.Format("2006-01"), err)
+			}
+		}
+	}
+	return nil
+}
+
+func partitionName(table string, month time.Time) string {
+	return fmt.Sprintf("%s_y%04dm%02d", table, month.Year(), int(month.Month()))
+}
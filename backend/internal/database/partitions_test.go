@@ -0,0 +1,16 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPartitionName(t *testing.T) {
+	month := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	if name := partitionName("audit_logs", month); name != "audit_logs_y2024m03" {
+		t.Errorf("unexpected partition name %s", name)
+	}
+	if name := partitionName("stock_movements", month); name != "stock_movements_y2024m03" {
+		t.Errorf("unexpected partition name %s", name)
+	}
+}
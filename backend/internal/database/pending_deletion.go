@@ -0,0 +1,290 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// SettingDeleteUndoMinutes is how long deleting a user, category or
+// product can be undone before it is carried out; 0 deletes at once.
+const SettingDeleteUndoMinutes = "delete_undo_minutes"
+
+// DefaultDeleteUndoMinutes applies while SettingDeleteUndoMinutes is unset.
+const DefaultDeleteUndoMinutes = 10
+
+// ErrNotPending is returned when carrying out a deletion that was undone,
+// or carried out by another instance, since it was found due.
+var ErrNotPending = errors.New("deletion is no longer pending")
+
+const pendingDeletionColumns = `id, organization_id, table_name, record_id, COALESCE(restore_active, false), requested_by,
+	requested_at, execute_at`
+
+func scanPendingDeletion(row interface{ Scan(...interface{}) error }, d *models.PendingDeletion) error {
+	var requestedBy uuid.NullUUID
+	err := row.Scan(&d.ID, &d.OrganizationID, &d.TableName, &d.RecordID, &d.RestoreActive, &requestedBy,
+		&d.RequestedAt, &d.ExecuteAt)
+	if err != nil {
+		return err
+	}
+	if requestedBy.Valid {
+		d.RequestedBy = &requestedBy.UUID
+	}
+	return nil
+}
+
+// notPendingDeletion is a condition on rows of table, which has an id
+// column, leaving out those whose deletion is pending.
+func notPendingDeletion(table string) string {
+	return "NOT EXISTS (SELECT 1 FROM pending_deletions pd WHERE pd.table_name = '" + table + "' AND pd.record_id = " + table + ".id)"
+}
+
+// ParseDeleteUndoMinutes parses a SettingDeleteUndoMinutes value.
+func ParseDeleteUndoMinutes(value string) (int, error) {
+	minutes, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || minutes < 0 {
+		return 0, fmt.Errorf("%q is not a number of minutes", value)
+	}
+	return minutes, nil
+}
+
+// PendingDeletionService records the deletions that can still be undone.
+type PendingDeletionService struct {
+	db *sql.DB
+}
+
+func NewPendingDeletionService(db *sql.DB) *PendingDeletionService {
+	return &PendingDeletionService{db: db}
+}
+
+// UndoWindow returns how long deletions can be undone, from
+// SettingDeleteUndoMinutes.
+func (s *PendingDeletionService) UndoWindow(ctx context.Context) (time.Duration, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var raw string
+	err := s.db.QueryRowContext(ctx, "SELECT value FROM system_settings WHERE key = $1", SettingDeleteUndoMinutes).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return DefaultDeleteUndoMinutes * time.Minute, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get undo window: %w", err)
+	}
+	minutes, err := ParseDeleteUndoMinutes(raw)
+	if err != nil {
+		return DefaultDeleteUndoMinutes * time.Minute, nil
+	}
+	return time.Duration(minutes) * time.Minute, nil
+}
+
+// ScheduleDeletion records that the record of table in the organization of
+// ctx is to be deleted after window. Users are deactivated until then, so
+// they cannot log in. Deleting a record whose deletion is already pending
+// violates pending_deletions_record_key.
+func (s *PendingDeletionService) ScheduleDeletion(ctx context.Context, table string, recordID, requestedBy uuid.UUID, window time.Duration) (*models.PendingDeletion, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	organizationID, ok := OrganizationFrom(ctx)
+	if !ok {
+		organizationID = DefaultOrganizationID
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	d := &models.PendingDeletion{
+		ID:             uuid.New(),
+		OrganizationID: organizationID,
+		TableName:      table,
+		RecordID:       recordID,
+		RequestedBy:    &requestedBy,
+	}
+
+	var restoreActive sql.NullBool
+	if table == models.DeletionUsers {
+		err := tx.QueryRowContext(ctx, `SELECT is_active FROM users
+			WHERE id = $1 AND ($2::uuid IS NULL OR organization_id = $2) AND anonymized_at IS NULL
+			FOR UPDATE`, recordID, organizationArg(ctx)).Scan(&d.RestoreActive)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("user %w", ErrNotFound)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user: %w", err)
+		}
+		restoreActive = sql.NullBool{Bool: d.RestoreActive, Valid: true}
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO pending_deletions (id, organization_id, table_name, record_id, restore_active, requested_by, execute_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW() + $7 * INTERVAL '1 second')
+		RETURNING requested_at, execute_at`,
+		d.ID, organizationID, table, recordID, restoreActive, requestedBy, int64(window/time.Second)).Scan(&d.RequestedAt, &d.ExecuteAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to schedule deletion: %w", err)
+	}
+
+	if table == models.DeletionUsers {
+		if _, err := tx.ExecContext(ctx, `UPDATE users SET is_active = false, updated_at = NOW() WHERE id = $1`, recordID); err != nil {
+			return nil, fmt.Errorf("failed to deactivate user: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// UndoDeletion cancels the pending deletion of the record of table in the
+// organization of ctx, reactivating a user who was active. It returns
+// ErrNotFound when none is pending, including once it was carried out.
+func (s *PendingDeletionService) UndoDeletion(ctx context.Context, table string, recordID uuid.UUID) (*models.PendingDeletion, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var d models.PendingDeletion
+	row := tx.QueryRowContext(ctx, `DELETE FROM pending_deletions
+		WHERE table_name = $1 AND record_id = $2 AND ($3::uuid IS NULL OR organization_id = $3)
+		RETURNING `+pendingDeletionColumns, table, recordID, organizationArg(ctx))
+	if err := scanPendingDeletion(row, &d); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("pending deletion %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to undo deletion: %w", err)
+	}
+
+	if d.TableName == models.DeletionUsers && d.RestoreActive {
+		if _, err := tx.ExecContext(ctx, `UPDATE users SET is_active = true, updated_at = NOW() WHERE id = $1`, recordID); err != nil {
+			return nil, fmt.Errorf("failed to reactivate user: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// GetPendingDeletions returns the pending deletions of the organization of
+// ctx, the soonest first.
+func (s *PendingDeletionService) GetPendingDeletions(ctx context.Context) ([]models.PendingDeletion, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+pendingDeletionColumns+` FROM pending_deletions
+		WHERE ($1::uuid IS NULL OR organization_id = $1)
+		ORDER BY execute_at`, organizationArg(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending deletions: %w", err)
+	}
+	defer rows.Close()
+
+	deletions := []models.PendingDeletion{}
+	for rows.Next() {
+		var d models.PendingDeletion
+		if err := scanPendingDeletion(rows, &d); err != nil {
+			return nil, fmt.Errorf("failed to scan pending deletion: %w", err)
+		}
+		deletions = append(deletions, d)
+	}
+	return deletions, rows.Err()
+}
+
+// DueDeletions returns up to limit deletions, of every organization, whose
+// undo window has passed, the longest due first. Carry each out with a
+// context from WithPendingDeletion.
+func (s *PendingDeletionService) DueDeletions(ctx context.Context, limit int) ([]models.PendingDeletion, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+pendingDeletionColumns+` FROM pending_deletions
+		WHERE execute_at <= NOW()
+		ORDER BY execute_at LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get due deletions: %w", err)
+	}
+	defer rows.Close()
+
+	deletions := []models.PendingDeletion{}
+	for rows.Next() {
+		var d models.PendingDeletion
+		if err := scanPendingDeletion(rows, &d); err != nil {
+			return nil, fmt.Errorf("failed to scan pending deletion: %w", err)
+		}
+		deletions = append(deletions, d)
+	}
+	return deletions, rows.Err()
+}
+
+// PostponeDeletion sets a due deletion that failed to be tried again at
+// retryAt.
+func (s *PendingDeletionService) PostponeDeletion(ctx context.Context, id uuid.UUID, retryAt time.Time) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	if _, err := s.db.ExecContext(ctx, `UPDATE pending_deletions SET execute_at = $2 WHERE id = $1`, id, retryAt); err != nil {
+		return fmt.Errorf("failed to postpone deletion: %w", err)
+	}
+	return nil
+}
+
+// DropDeletion forgets a due deletion that need not be carried out, such
+// as one of a record that no longer exists.
+func (s *PendingDeletionService) DropDeletion(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM pending_deletions WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to drop deletion: %w", err)
+	}
+	return nil
+}
+
+type pendingDeletionKey struct{}
+
+// WithPendingDeletion returns ctx for carrying out the pending deletion id.
+// DeleteProduct, DeleteCategory and AnonymizeUser called with it remove the
+// pending deletion in the transaction that deletes the record, so a
+// deletion cannot be undone once it is made, nor made once it was undone.
+// They return ErrNotPending when it is gone.
+func WithPendingDeletion(ctx context.Context, id uuid.UUID) context.Context {
+	return context.WithValue(ctx, pendingDeletionKey{}, id)
+}
+
+// completePendingDeletion removes the pending deletion of ctx, if it has
+// one, in tx. Run it before deleting the record, so that of two instances
+// carrying out the same deletion the second waits for the first and then
+// gets ErrNotPending.
+func completePendingDeletion(ctx context.Context, tx *sql.Tx) error {
+	id, ok := ctx.Value(pendingDeletionKey{}).(uuid.UUID)
+	if !ok {
+		return nil
+	}
+	result, err := tx.ExecContext(ctx, `DELETE FROM pending_deletions WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to complete pending deletion: %w", err)
+	}
+	if deleted, err := result.RowsAffected(); err != nil || deleted == 0 {
+		return ErrNotPending
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestParseDeleteUndoMinutes(t *testing.T) {
+	for value, expected := range map[string]int{"10": 10, " 0 ": 0, "1440": 1440} {
+		if minutes, err := ParseDeleteUndoMinutes(value); err != nil || minutes != expected {
+			t.Errorf("%q: expected %d, got %d, %v", value, expected, minutes, err)
+		}
+	}
+	for _, value := range []string{"", "-1", "2.5", "soon"} {
+		if _, err := ParseDeleteUndoMinutes(value); err == nil {
+			t.Errorf("expected %q to be rejected", value)
+		}
+	}
+}
+
+func TestCarryOutPendingDeletion(t *testing.T) {
+	db := testDB(t)
+	products := NewProductService(db)
+	pending := NewPendingDeletionService(db)
+	scoped := WithOrganization(ctx, DefaultOrganizationID)
+
+	suffix := uuid.NewString()[:8]
+	user := &models.User{ID: uuid.New(), Name: "Clerk", Email: "clerk-" + suffix + "@example.com", Password: "x", Role: models.RoleAdmin, IsActive: true, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := NewUserService(db).CreateUser(ctx, user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	product := &models.Product{ID: uuid.New(), Name: "Discontinued", SKU: "GONE-" + suffix, Stock: 1, Category: "Electronics"}
+	if err := products.CreateProduct(scoped, product); err != nil {
+		t.Fatalf("Failed to create product: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM pending_deletions WHERE record_id = $1`, product.ID)
+		db.Exec(`DELETE FROM products WHERE id = $1`, product.ID)
+		db.Exec(`DELETE FROM users WHERE id = $1`, user.ID)
+	})
+
+	d, err := pending.ScheduleDeletion(scoped, models.DeletionProducts, product.ID, user.ID, 0)
+	if err != nil {
+		t.Fatalf("Failed to schedule deletion: %v", err)
+	}
+	listed, _, err := products.GetProducts(scoped, models.ProductFilter{Search: product.SKU, Page: 1, Limit: 10})
+	if err != nil || len(listed) != 0 {
+		t.Errorf("expected a product pending deletion to be left out of lists, got %d, %v", len(listed), err)
+	}
+
+	// An undo that wins the race leaves the deletion nothing to carry out
+	if _, err := pending.UndoDeletion(scoped, models.DeletionProducts, product.ID); err != nil {
+		t.Fatalf("Failed to undo deletion: %v", err)
+	}
+	if err := products.DeleteProduct(WithPendingDeletion(scoped, d.ID), product.ID); !errors.Is(err, ErrNotPending) {
+		t.Fatalf("expected ErrNotPending after the undo, got %v", err)
+	}
+	if _, err := products.GetProduct(scoped, product.ID); err != nil {
+		t.Fatalf("expected the product to be kept, got %v", err)
+	}
+
+	// Carried out, the deletion takes its pending row with it
+	d, err = pending.ScheduleDeletion(scoped, models.DeletionProducts, product.ID, user.ID, 0)
+	if err != nil {
+		t.Fatalf("Failed to schedule deletion: %v", err)
+	}
+	if err := products.DeleteProduct(WithPendingDeletion(scoped, d.ID), product.ID); err != nil {
+		t.Fatalf("Failed to carry out deletion: %v", err)
+	}
+	if _, err := pending.UndoDeletion(scoped, models.DeletionProducts, product.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected a carried out deletion to be past undoing, got %v", err)
+	}
+}
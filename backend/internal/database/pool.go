@@ -0,0 +1,67 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Pool sizes a Postgres connection pool and bounds how long its sessions'
+// statements may run and wait for locks. A zero timeout leaves the server's
+// setting, which is no limit by default.
+type Pool struct {
+	MaxOpen     int
+	MaxIdle     int
+	MaxLifetime time.Duration
+	MaxIdleTime time.Duration
+
+	StatementTimeout time.Duration
+	LockTimeout      time.Duration
+}
+
+// sessionURL adds the pool's statement and lock timeouts to a postgres://
+// URL as session parameters. Timeouts already given in the URL are kept.
+func sessionURL(databaseURL string, pool Pool) (string, error) {
+	if pool.StatementTimeout <= 0 && pool.LockTimeout <= 0 {
+		return databaseURL, nil
+	}
+	parsed, err := url.Parse(databaseURL)
+	if err != nil {
+		return "", err
+	}
+	query := parsed.Query()
+	for name, timeout := range map[string]time.Duration{
+		"statement_timeout": pool.StatementTimeout,
+		"lock_timeout":      pool.LockTimeout,
+	} {
+		if timeout > 0 && !query.Has(name) {
+			query.Set(name, strconv.FormatInt(timeout.Milliseconds(), 10))
+		}
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), nil
+}
+
+// openPostgres opens a connection pool whose connections are made through
+// the given breaker.
+func openPostgres(databaseURL string, pool Pool, b *breaker) (*sql.DB, error) {
+	dsn, err := sessionURL(databaseURL, pool)
+	if err != nil {
+		return nil, fmt.Errorf("invalid database URL: %w", err)
+	}
+	connector, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	db := sql.OpenDB(postgresConnector{Connector: connector, breaker: b})
+	db.SetMaxOpenConns(pool.MaxOpen)
+	db.SetMaxIdleConns(pool.MaxIdle)
+	db.SetConnMaxLifetime(pool.MaxLifetime)
+	db.SetConnMaxIdleTime(pool.MaxIdleTime)
+	return db, nil
+}
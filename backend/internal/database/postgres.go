@@ -5,31 +5,32 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"rtims-backend/internal/email"
 	"rtims-backend/internal/models"
 
+	sq "github.com/Masterminds/squirrel"
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
-func InitDB(databaseURL string) *sql.DB {
+func InitDB(databaseURL string, pool Pool) *sql.DB {
  	log.Printf("Opening database connection to: %s", databaseURL)
 
- 	db, err := sql.Open("postgres", databaseURL)
+ 	// Connections are opened through the Postgres circuit breaker
+ 	db, err := openPostgres(databaseURL, pool, postgresBreaker)
  	if err != nil {
  		log.Fatal("Failed to open database connection:", err)
  	}
-
- 	// Configure connection pool
- 	db.SetMaxOpenConns(25)
- 	db.SetMaxIdleConns(25)
- 	db.SetConnMaxLifetime(5 * time.Minute)
- 	log.Printf("Database connection pool configured: MaxOpen=%d, MaxIdle=%d, MaxLifetime=5min", 25, 25)
+ 	log.Printf("Database connection pool configured: MaxOpen=%d, MaxIdle=%d, MaxLifetime=%s, MaxIdleTime=%s, StatementTimeout=%s, LockTimeout=%s",
+ 		pool.MaxOpen, pool.MaxIdle, pool.MaxLifetime, pool.MaxIdleTime, pool.StatementTimeout, pool.LockTimeout)
 
  	// Test the connection
  	log.Println("Testing database connection...")
@@ -41,24 +42,70 @@ func InitDB(databaseURL string) *sql.DB {
  	return db
  }
 
-func InitRedis(redisURL string) *redis.Client {
- 	log.Printf("Initializing Redis client with URL: %s", redisURL)
+// RedisPool sizes the Redis connection pool. Zero values leave go-redis
+// defaults, and settings given as REDIS_URL query parameters (such as
+// ?pool_size=20) take precedence.
+type RedisPool struct {
+	Size         int
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+}
 
- 	rdb := redis.NewClient(&redis.Options{
- 		Addr:     strings.TrimPrefix(redisURL, "redis://"),
- 		Password: "", // no password set
- 		DB:       0,  // use default DB
- 	})
+// redisOptions parses a redis:// or rediss:// (TLS) URL, including its
+// username, password and /<db> number, and applies pool.
+func redisOptions(redisURL string, pool RedisPool) (*redis.Options, error) {
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	if opts.PoolSize == 0 {
+		opts.PoolSize = pool.Size
+	}
+	if opts.DialTimeout == 0 {
+		opts.DialTimeout = pool.DialTimeout
+	}
+	if opts.ReadTimeout == 0 {
+		opts.ReadTimeout = pool.ReadTimeout
+	}
+	if opts.WriteTimeout == 0 {
+		opts.WriteTimeout = pool.WriteTimeout
+	}
+	// go-redis retries failed commands with jittered backoff; -1 is its
+	// value for no retries
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = resilience.Retries
+		if opts.MaxRetries == 0 {
+			opts.MaxRetries = -1
+		}
+	}
+	if opts.MinRetryBackoff == 0 {
+		opts.MinRetryBackoff = resilience.RetryBackoff
+	}
+	return opts, nil
+}
 
- 	// Test the connection
- 	log.Println("Testing Redis connection...")
- 	if err := rdb.Ping(context.Background()).Err(); err != nil {
- 		log.Fatal("Failed to connect to Redis:", err)
- 	}
+func InitRedis(redisURL string, pool RedisPool) *redis.Client {
+	opts, err := redisOptions(redisURL, pool)
+	if err != nil {
+		log.Fatal("Invalid REDIS_URL:", err)
+	}
+	log.Printf("Initializing Redis client for %s (db %d, tls %v)", opts.Addr, opts.DB, opts.TLSConfig != nil)
 
- 	log.Println("Successfully connected to Redis")
- 	return rdb
- }
+	rdb := redis.NewClient(opts)
+	rdb.AddHook(redisBreakerHook{})
+
+	// Test the connection. The client reconnects by itself, so an
+	// unreachable Redis is not fatal
+	log.Println("Testing Redis connection...")
+	if err := rdb.Ping(context.Background()).Err(); err != nil {
+		log.Printf("Warning: Redis is unreachable, continuing without it: %v", err)
+		return rdb
+	}
+
+	log.Println("Successfully connected to Redis")
+	return rdb
+}
 
 // ValidateDatabaseConnection validates the database connection and required tables
 func ValidateDatabaseConnection(db *sql.DB) error {
@@ -70,7 +117,7 @@ func ValidateDatabaseConnection(db *sql.DB) error {
  	// Check if required tables exist
  	requiredTables := []string{
  		"users", "products", "categories", "stock_movements",
- 		"notifications", "audit_logs", "system_settings",
+ 		"notifications", "audit_logs", "system_settings", "outbox_events",
  	}
 
  	for _, table := range requiredTables {
@@ -142,17 +189,23 @@ func NewNotificationService(db *sql.DB) *NotificationService {
 }
 
 func (s *NotificationService) GetNotifications(filter models.NotificationFilter) ([]models.Notification, int, error) {
-	// Build query
+	// Build query; archived notifications are hidden unless asked for
 	query := `
-		SELECT id, user_id, message, type, is_read, created_at
+		SELECT id, user_id, message, type, is_read, is_archived, created_at,
+		       email_status, email_sent_at, email_error, scheduled_at, delivered_at,
+		       priority, acknowledged_at, acknowledged_by, escalated_at,
+		       entity_type, entity_id, action, link
 		FROM notifications
-		WHERE user_id = $1
+		WHERE user_id = $1 AND delivered_at IS NOT NULL
+		AND ($2::text IS NULL OR type = $2)
+		AND ($3::boolean IS NULL OR is_read = $3)
+		AND is_archived = COALESCE($4::boolean, false)
 		ORDER BY created_at DESC
-		LIMIT $2 OFFSET $3
+		LIMIT $5 OFFSET $6
 	`
 	offset := (filter.Page - 1) * filter.Limit
 
-	rows, err := s.db.Query(query, filter.UserID, filter.Limit, offset)
+	rows, err := s.db.Query(query, filter.UserID, filter.Type, filter.IsRead, filter.IsArchived, filter.Limit, offset)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -161,8 +214,7 @@ func (s *NotificationService) GetNotifications(filter models.NotificationFilter)
 	var notifications []models.Notification
 	for rows.Next() {
 		var n models.Notification
-		err := rows.Scan(&n.ID, &n.UserID, &n.Message, &n.Type, &n.IsRead, &n.CreatedAt)
-		if err != nil {
+		if err := scanNotification(rows, &n); err != nil {
 			return nil, 0, err
 		}
 		notifications = append(notifications, n)
@@ -170,8 +222,14 @@ func (s *NotificationService) GetNotifications(filter models.NotificationFilter)
 
 	// Get total count
 	var total int
-	countQuery := "SELECT COUNT(*) FROM notifications WHERE user_id = $1"
-	err = s.db.QueryRow(countQuery, filter.UserID).Scan(&total)
+	countQuery := `
+		SELECT COUNT(*) FROM notifications
+		WHERE user_id = $1 AND delivered_at IS NOT NULL
+		AND ($2::text IS NULL OR type = $2)
+		AND ($3::boolean IS NULL OR is_read = $3)
+		AND is_archived = COALESCE($4::boolean, false)
+	`
+	err = s.db.QueryRow(countQuery, filter.UserID, filter.Type, filter.IsRead, filter.IsArchived).Scan(&total)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -179,11 +237,74 @@ func (s *NotificationService) GetNotifications(filter models.NotificationFilter)
 	return notifications, total, nil
 }
 
+// scanNotification scans a row selected with the column list used by
+// GetNotifications, ClaimDueScheduled and ClaimUnacknowledged.
+func scanNotification(rows *sql.Rows, n *models.Notification) error {
+	var emailSentAt, scheduledAt, deliveredAt, acknowledgedAt, escalatedAt sql.NullTime
+	var emailError, entityType, action, link sql.NullString
+	var acknowledgedBy, entityID uuid.NullUUID
+	err := rows.Scan(&n.ID, &n.UserID, &n.Message, &n.Type, &n.IsRead, &n.IsArchived, &n.CreatedAt,
+		&n.EmailStatus, &emailSentAt, &emailError, &scheduledAt, &deliveredAt,
+		&n.Priority, &acknowledgedAt, &acknowledgedBy, &escalatedAt,
+		&entityType, &entityID, &action, &link)
+	if err != nil {
+		return err
+	}
+	if entityType.Valid || entityID.Valid || action.Valid || link.Valid {
+		n.Metadata = &models.NotificationMetadata{
+			EntityType: entityType.String,
+			Action:     models.NotificationAction(action.String),
+			Link:       link.String,
+		}
+		if entityID.Valid {
+			n.Metadata.EntityID = &entityID.UUID
+		}
+	}
+	if acknowledgedAt.Valid {
+		n.AcknowledgedAt = &acknowledgedAt.Time
+	}
+	if acknowledgedBy.Valid {
+		n.AcknowledgedBy = &acknowledgedBy.UUID
+	}
+	if escalatedAt.Valid {
+		n.EscalatedAt = &escalatedAt.Time
+	}
+	if emailSentAt.Valid {
+		n.EmailSentAt = &emailSentAt.Time
+	}
+	if scheduledAt.Valid {
+		n.ScheduledAt = &scheduledAt.Time
+	}
+	if deliveredAt.Valid {
+		n.DeliveredAt = &deliveredAt.Time
+	}
+	n.EmailError = emailError.String
+	return nil
+}
+
+// CreateNotification saves the notification. Notifications scheduled for a
+// later time are stored undelivered and picked up by ClaimDueScheduled;
+// everything else is marked delivered on insert.
 func (s *NotificationService) CreateNotification(notification *models.Notification) error {
 	query := `
-		INSERT INTO notifications (id, user_id, message, type, is_read, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO notifications (id, user_id, message, type, is_read, created_at, email_status, scheduled_at, delivered_at, priority,
+		                           entity_type, entity_id, action, link)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10,
+		        NULLIF($11, ''), $12, NULLIF($13, ''), NULLIF($14, ''))
 	`
+	metadata := models.NotificationMetadata{}
+	if notification.Metadata != nil {
+		metadata = *notification.Metadata
+	}
+	if notification.EmailStatus == "" {
+		notification.EmailStatus = models.EmailStatusNone
+	}
+	if notification.Priority == "" {
+		notification.Priority = models.PriorityNormal
+	}
+	if !notification.IsScheduled() {
+		notification.DeliveredAt = &notification.CreatedAt
+	}
 	_, err := s.db.Exec(query,
 		notification.ID,
 		notification.UserID,
@@ -191,127 +312,521 @@ func (s *NotificationService) CreateNotification(notification *models.Notificati
 		notification.Type,
 		notification.IsRead,
 		notification.CreatedAt,
+		notification.EmailStatus,
+		notification.ScheduledAt,
+		notification.DeliveredAt,
+		notification.Priority,
+		metadata.EntityType,
+		metadata.EntityID,
+		metadata.Action,
+		metadata.Link,
 	)
 	return err
 }
 
+// ClaimDueScheduled marks up to limit scheduled notifications whose time has
+// come as delivered and returns them for dispatch. Rows locked by another
+// instance are skipped so each notification is claimed exactly once.
+func (s *NotificationService) ClaimDueScheduled(limit int) ([]models.Notification, error) {
+	query := `
+		UPDATE notifications SET delivered_at = NOW()
+		WHERE id IN (
+			SELECT id FROM notifications
+			WHERE delivered_at IS NULL AND scheduled_at <= NOW()
+			ORDER BY scheduled_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, user_id, message, type, is_read, is_archived, created_at,
+		          email_status, email_sent_at, email_error, scheduled_at, delivered_at,
+		          priority, acknowledged_at, acknowledged_by, escalated_at,
+		          entity_type, entity_id, action, link
+	`
+	rows, err := s.db.Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []models.Notification
+	for rows.Next() {
+		var n models.Notification
+		if err := scanNotification(rows, &n); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+
+	return notifications, nil
+}
+
+// Acknowledge records that the user has seen a critical alert, which stops
+// it from being escalated. It returns sql.ErrNoRows if the notification does
+// not exist, belongs to another user, or was already acknowledged.
+func (s *NotificationService) Acknowledge(id uuid.UUID, userID uuid.UUID) error {
+	query := `
+		UPDATE notifications SET acknowledged_at = NOW(), acknowledged_by = $2, is_read = true
+		WHERE id = $1 AND user_id = $2 AND priority = 'critical' AND acknowledged_at IS NULL
+	`
+	result, err := s.db.Exec(query, id, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ClaimUnacknowledged marks critical notifications delivered before cutoff
+// and still unacknowledged as escalated, and returns them. Each notification
+// is escalated at most once.
+func (s *NotificationService) ClaimUnacknowledged(cutoff time.Time) ([]models.Notification, error) {
+	query := `
+		UPDATE notifications SET escalated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM notifications
+			WHERE priority = 'critical' AND acknowledged_at IS NULL AND escalated_at IS NULL
+			AND delivered_at < $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, user_id, message, type, is_read, is_archived, created_at,
+		          email_status, email_sent_at, email_error, scheduled_at, delivered_at,
+		          priority, acknowledged_at, acknowledged_by, escalated_at,
+		          entity_type, entity_id, action, link
+	`
+	rows, err := s.db.Query(query, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []models.Notification
+	for rows.Next() {
+		var n models.Notification
+		if err := scanNotification(rows, &n); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+
+	return notifications, nil
+}
+
 func (s *NotificationService) MarkAsRead(id uuid.UUID, userID uuid.UUID) error {
 	query := "UPDATE notifications SET is_read = true WHERE id = $1 AND user_id = $2"
 	_, err := s.db.Exec(query, id, userID)
 	return err
 }
 
+func (s *NotificationService) DeleteNotification(id uuid.UUID, userID uuid.UUID) error {
+	query := "DELETE FROM notifications WHERE id = $1 AND user_id = $2"
+	result, err := s.db.Exec(query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *NotificationService) SetArchived(id uuid.UUID, userID uuid.UUID, archived bool) error {
+	query := "UPDATE notifications SET is_archived = $1 WHERE id = $2 AND user_id = $3"
+	result, err := s.db.Exec(query, archived, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// PurgeReadOlderThan deletes read notifications created before the cutoff.
+func (s *NotificationService) PurgeReadOlderThan(cutoff time.Time) (int64, error) {
+	query := "DELETE FROM notifications WHERE is_read = true AND created_at < $1"
+	result, err := s.db.Exec(query, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// MarkAllAsRead marks every unread notification for the user as read.
+func (s *NotificationService) MarkAllAsRead(userID uuid.UUID) (int64, error) {
+	query := "UPDATE notifications SET is_read = true WHERE user_id = $1 AND is_read = false AND delivered_at IS NOT NULL"
+	result, err := s.db.Exec(query, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// BulkUpdate applies a mark-read, archive or delete action to the user's
+// delivered notifications, selected by ID list, by filter or with all.
+func (s *NotificationService) BulkUpdate(userID uuid.UUID, req models.BulkNotificationRequest) (int64, error) {
+	query, args, err := bulkNotificationQuery(userID, req)
+	if err != nil {
+		return 0, err
+	}
+	result, err := s.db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// bulkNotificationQuery builds the statement behind BulkUpdate. Scheduled
+// notifications the user has not been shown are left alone.
+func bulkNotificationQuery(userID uuid.UUID, req models.BulkNotificationRequest) (string, []interface{}, error) {
+	conditions := sq.And{sq.Eq{"user_id": userID}, sq.NotEq{"delivered_at": nil}}
+	switch {
+	case len(req.IDs) > 0:
+		conditions = append(conditions, sq.Eq{"id": req.IDs})
+	case !req.Filter.Empty():
+		if req.Filter.Type != nil {
+			conditions = append(conditions, sq.Eq{"type": *req.Filter.Type})
+		}
+		if req.Filter.IsRead != nil {
+			conditions = append(conditions, sq.Eq{"is_read": *req.Filter.IsRead})
+		}
+		if req.Filter.Before != nil {
+			conditions = append(conditions, sq.Lt{"created_at": *req.Filter.Before})
+		}
+	case req.All:
+	default:
+		return "", nil, fmt.Errorf("ids, a filter with at least one field or all is required")
+	}
+
+	switch req.Action {
+	case models.BulkActionMarkRead:
+		return psql.Update("notifications").Set("is_read", true).Where(conditions).ToSql()
+	case models.BulkActionArchive:
+		return psql.Update("notifications").Set("is_archived", true).Where(conditions).ToSql()
+	case models.BulkActionDelete:
+		return psql.Delete("notifications").Where(conditions).ToSql()
+	default:
+		return "", nil, fmt.Errorf("unsupported bulk action: %s", req.Action)
+	}
+}
+
+func (s *NotificationService) GetPreferences(userID uuid.UUID) ([]models.NotificationPreference, error) {
+	query := "SELECT type, email_enabled FROM notification_preferences WHERE user_id = $1 ORDER BY type"
+	rows, err := s.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	preferences := []models.NotificationPreference{}
+	for rows.Next() {
+		var p models.NotificationPreference
+		if err := rows.Scan(&p.Type, &p.EmailEnabled); err != nil {
+			return nil, err
+		}
+		preferences = append(preferences, p)
+	}
+	return preferences, nil
+}
+
+func (s *NotificationService) UpdatePreferences(userID uuid.UUID, preferences []models.NotificationPreference) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, p := range preferences {
+		query := `
+			INSERT INTO notification_preferences (user_id, type, email_enabled, updated_at)
+			VALUES ($1, $2, $3, NOW())
+			ON CONFLICT (user_id, type) DO UPDATE SET
+				email_enabled = EXCLUDED.email_enabled,
+				updated_at = NOW()
+		`
+		if _, err := tx.Exec(query, userID, p.Type, p.EmailEnabled); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// IsEmailEnabled reports whether the user opted in to email for this notification type.
+func (s *NotificationService) IsEmailEnabled(userID uuid.UUID, notifType models.NotificationType) (bool, error) {
+	var enabled bool
+	query := "SELECT email_enabled FROM notification_preferences WHERE user_id = $1 AND type = $2"
+	err := s.db.QueryRow(query, userID, notifType).Scan(&enabled)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return enabled, err
+}
+
+func (s *NotificationService) UpdateEmailStatus(id uuid.UUID, status models.EmailDeliveryStatus, emailError string) error {
+	query := `
+		UPDATE notifications
+		SET email_status = $1,
+		    email_sent_at = CASE WHEN $1 = 'sent' THEN NOW() ELSE email_sent_at END,
+		    email_error = NULLIF($2, '')
+		WHERE id = $3
+	`
+	_, err := s.db.Exec(query, status, emailError, id)
+	return err
+}
+
+// DeliverEmail sends a notification by email when the recipient has opted in
+// for its type, recording the outcome on the notification row. It is meant to
+// run in its own goroutine after the notification has been saved.
+func (s *NotificationService) DeliverEmail(notification *models.Notification, mailer *email.Mailer) {
+	if !mailer.Enabled() {
+		return
+	}
+
+	enabled, err := s.IsEmailEnabled(notification.UserID, notification.Type)
+	if err != nil {
+		log.Printf("Failed to check email preference for notification %s: %v", notification.ID, err)
+		return
+	}
+	if !enabled {
+		return
+	}
+
+	if err := s.UpdateEmailStatus(notification.ID, models.EmailStatusPending, ""); err != nil {
+		log.Printf("Failed to mark notification %s email pending: %v", notification.ID, err)
+	}
+
+	var name, address string
+	err = s.db.QueryRow("SELECT name, email FROM users WHERE id = $1", notification.UserID).Scan(&name, &address)
+	if err != nil {
+		s.recordEmailFailure(notification.ID, fmt.Errorf("failed to look up recipient: %w", err))
+		return
+	}
+
+	body, err := email.Render("notification.html", gin.H{
+		"Title":     notificationEmailSubject(notification.Type),
+		"UserName":  name,
+		"Message":   notification.Message,
+		"CreatedAt": notification.CreatedAt,
+	})
+	if err != nil {
+		s.recordEmailFailure(notification.ID, err)
+		return
+	}
+
+	if err := mailer.Send(address, notificationEmailSubject(notification.Type), body); err != nil {
+		s.recordEmailFailure(notification.ID, err)
+		return
+	}
+
+	if err := s.UpdateEmailStatus(notification.ID, models.EmailStatusSent, ""); err != nil {
+		log.Printf("Failed to mark notification %s email sent: %v", notification.ID, err)
+	}
+}
+
+func (s *NotificationService) recordEmailFailure(id uuid.UUID, cause error) {
+	log.Printf("Failed to email notification %s: %v", id, cause)
+	if err := s.UpdateEmailStatus(id, models.EmailStatusFailed, cause.Error()); err != nil {
+		log.Printf("Failed to mark notification %s email failed: %v", id, err)
+	}
+}
+
+func notificationEmailSubject(notifType models.NotificationType) string {
+	switch notifType {
+	case models.NotificationLowStock:
+		return "RTIMS: Low stock alert"
+	case models.NotificationSystem:
+		return "RTIMS: System notification"
+	default:
+		return "RTIMS: New notification"
+	}
+}
+
 // AuditService handles audit log database operations
 type AuditService struct {
 	db *sql.DB
+
+	// cached redaction list, see redactedFields
+	redactMu     sync.Mutex
+	redactFields map[string]bool
+	redactLoaded time.Time
+
+	// cached sampling settings, see sampling
+	sampleMu       sync.Mutex
+	sampleSettings auditSampling
+	sampleLoaded   time.Time
 }
 
 func NewAuditService(db *sql.DB) *AuditService {
 	return &AuditService{db: db}
 }
 
-func (s *AuditService) GetAuditLogs(filter models.AuditLogFilter) ([]models.AuditLog, int, error) {
-	// Build query with filters
-	query := `
-		SELECT id, table_name, record_id, action, old_values, new_values,
-		       changed_by, changed_at, ip_address, user_agent
-		FROM audit_logs
-		WHERE ($1 = '' OR table_name = $1)
-		AND ($2::uuid IS NULL OR changed_by = $2)
-		AND ($3 = '' OR action = $3)
-		AND ($4::timestamptz IS NULL OR changed_at >= $4)
-		AND ($5::timestamptz IS NULL OR changed_at <= $5)
-		ORDER BY changed_at DESC
-		LIMIT $6 OFFSET $7
-	`
-	offset := (filter.Page - 1) * filter.Limit
+func (s *AuditService) GetAuditLogs(ctx context.Context, filter models.AuditLogFilter) ([]models.AuditLog, int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 
-	rows, err := s.db.Query(query,
-		filter.TableName,
-		filter.ChangedBy,
-		filter.Action,
-		filter.StartDate,
-		filter.EndDate,
-		filter.Limit,
-		offset,
-	)
+	// Listing can scan a lot of history, so it runs on the read replica
+	db := ForReads(s.db)
+
+	conditions := inOrganization(ctx, auditConditions(filter), "organization_id")
+	query, args, err := page(where(psql.Select(auditLogColumns, totalColumn).From("audit_logs"), conditions).
+		OrderBy("changed_at DESC"), filter.Page, filter.Limit).ToSql()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, 0, err
 	}
 	defer rows.Close()
 
 	var auditLogs []models.AuditLog
+	var total int
 	for rows.Next() {
 		var a models.AuditLog
-		err := rows.Scan(&a.ID, &a.TableName, &a.RecordID, &a.Action,
-			&a.OldValues, &a.NewValues, &a.ChangedBy, &a.ChangedAt,
-			&a.IPAddress, &a.UserAgent)
-		if err != nil {
+		if err := scanAuditLog(rows, &a, &total); err != nil {
 			return nil, 0, err
 		}
 		auditLogs = append(auditLogs, a)
 	}
 
-	// Get total count
-	var total int
-	countQuery := `
-		SELECT COUNT(*) FROM audit_logs
-		WHERE ($1 = '' OR table_name = $1)
-		AND ($2::uuid IS NULL OR changed_by = $2)
-		AND ($3 = '' OR action = $3)
-		AND ($4::timestamptz IS NULL OR changed_at >= $4)
-		AND ($5::timestamptz IS NULL OR changed_at <= $5)
-	`
-	err = s.db.QueryRow(countQuery,
-		filter.TableName,
-		filter.ChangedBy,
-		filter.Action,
-		filter.StartDate,
-		filter.EndDate,
-	).Scan(&total)
-	if err != nil {
-		return nil, 0, err
+	if len(auditLogs) == 0 {
+		countQuery, countArgs, err := where(psql.Select("COUNT(*)").From("audit_logs"), conditions).ToSql()
+		if err != nil {
+			return nil, 0, err
+		}
+		if total, err = pastEndTotal(ctx, db, filter.Page, countQuery, countArgs...); err != nil {
+			return nil, 0, err
+		}
 	}
 
 	return auditLogs, total, nil
 }
 
-func (s *AuditService) CreateAuditLog(auditLog *models.AuditLog) error {
+// Record writes an audit entry through the background audit writer, so the
+// request does not wait for the insert. Without a writer, or while its queue
+// is full, the entry is inserted before Record returns.
+func (s *AuditService) Record(ctx context.Context, auditLog *models.AuditLog) error {
+	if auditWriter != nil && auditWriter.Enqueue(auditLog) {
+		return nil
+	}
+	return s.CreateAuditLog(ctx, auditLog)
+}
+
+func (s *AuditService) CreateAuditLog(ctx context.Context, auditLog *models.AuditLog) error {
+	return s.CreateAuditLogs(ctx, []*models.AuditLog{auditLog})
+}
+
+// CreateAuditLogs inserts audit entries with one multi-row INSERT.
+func (s *AuditService) CreateAuditLogs(ctx context.Context, auditLogs []*models.AuditLog) error {
+	if len(auditLogs) == 0 {
+		return nil
+	}
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	for _, auditLog := range auditLogs {
+		s.redact(auditLog)
+	}
+	query, args, err := auditInsert(auditLogs)
+	if err != nil {
+		return err
+	}
+	if productSearcher != nil {
+		// Queued by the same statement, so entries are indexed if and only
+		// if they are written
+		query = `WITH inserted AS (` + query + ` RETURNING id) ` + searchIndexInsert(models.SearchAuditLogs) + `inserted`
+	}
+	_, err = s.db.ExecContext(ctx, query, args...)
+	return err
+}
+
+// auditInsert builds the INSERT for a batch of audit entries.
+func auditInsert(auditLogs []*models.AuditLog) (string, []interface{}, error) {
+	rows := make([]string, 0, len(auditLogs))
+	args := make([]interface{}, 0, len(auditLogs)*15)
+	for _, auditLog := range auditLogs {
+		oldValues, err := marshalAuditValues(auditLog.OldValues)
+		if err != nil {
+			return "", nil, err
+		}
+		newValues, err := marshalAuditValues(auditLog.NewValues)
+		if err != nil {
+			return "", nil, err
+		}
+		requestBody, err := marshalAuditSample(auditLog.RequestBody)
+		if err != nil {
+			return "", nil, err
+		}
+		responseBody, err := marshalAuditSample(auditLog.ResponseBody)
+		if err != nil {
+			return "", nil, err
+		}
+
+		n := len(args)
+		rows = append(rows, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, NULLIF($%d, '')::inet, $%d, "+
+			"NULLIF($%d, 0), NULLIF($%d, ''), CASE WHEN $%d = 0 THEN NULL ELSE $%d END, $%d, $%d)",
+			n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9, n+10, n+11, n+12, n+11, n+13, n+14, n+15))
+		args = append(args,
+			auditLog.ID,
+			auditLog.TableName,
+			auditLog.RecordID,
+			auditLog.Action,
+			oldValues,
+			newValues,
+			auditLog.ChangedBy,
+			auditLog.ChangedAt,
+			auditLog.IPAddress,
+			auditLog.UserAgent,
+			auditLog.StatusCode,
+			auditLog.ErrorMessage,
+			auditLog.DurationMs,
+			requestBody,
+			responseBody,
+		)
+	}
+
 	query := `
 		INSERT INTO audit_logs (id, table_name, record_id, action, old_values, new_values,
-		                       changed_by, changed_at, ip_address, user_agent)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-	`
-	_, err := s.db.Exec(query,
-		auditLog.ID,
-		auditLog.TableName,
-		auditLog.RecordID,
-		auditLog.Action,
-		auditLog.OldValues,
-		auditLog.NewValues,
-		auditLog.ChangedBy,
-		auditLog.ChangedAt,
-		auditLog.IPAddress,
-		auditLog.UserAgent,
-	)
-	return err
+		                       changed_by, changed_at, ip_address, user_agent,
+		                       status_code, error_message, duration_ms, request_body, response_body)
+		VALUES ` + strings.Join(rows, ", ")
+	return query, args, nil
 }
 
-func (s *AuditService) GetAuditLog(id uuid.UUID) (*models.AuditLog, error) {
+func (s *AuditService) GetAuditLog(ctx context.Context, id uuid.UUID) (*models.AuditLog, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
 	query := `
-		SELECT id, table_name, record_id, action, old_values, new_values,
-		       changed_by, changed_at, ip_address, user_agent
-		FROM audit_logs WHERE id = $1
+		SELECT ` + auditLogColumns + `, request_body, response_body
+		FROM audit_logs WHERE id = $1 AND ($2::uuid IS NULL OR organization_id = $2)
 	`
 	var auditLog models.AuditLog
-	err := s.db.QueryRow(query, id).Scan(
-		&auditLog.ID, &auditLog.TableName, &auditLog.RecordID, &auditLog.Action,
-		&auditLog.OldValues, &auditLog.NewValues, &auditLog.ChangedBy,
-		&auditLog.ChangedAt, &auditLog.IPAddress, &auditLog.UserAgent,
-	)
-	if err != nil {
+	var requestBody, responseBody []byte
+	row := s.db.QueryRowContext(ctx, query, id, organizationArg(ctx))
+	if err := scanAuditLog(row, &auditLog, &requestBody, &responseBody); err != nil {
 		return nil, err
 	}
+	auditLog.RequestBody = unmarshalAuditSample(requestBody)
+	auditLog.ResponseBody = unmarshalAuditSample(responseBody)
 	return &auditLog, nil
 }
 
@@ -324,24 +839,30 @@ func NewUserService(db *sql.DB) *UserService {
 	return &UserService{db: db}
 }
 
-func (s *UserService) GetUsers(filter models.UserFilter) ([]models.User, int, error) {
+func (s *UserService) GetUsers(ctx context.Context, filter models.UserFilter) ([]models.User, int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
 	query := `
-		SELECT id, name, email, role, is_active, created_at, updated_at
+		SELECT id, name, email, role, is_active, created_at, updated_at, organization_id, COUNT(*) OVER()
 		FROM users
 		WHERE ($1 = '' OR name ILIKE '%' || $1 || '%' OR email ILIKE '%' || $1 || '%')
 		AND ($2 = '' OR role = $2)
 		AND ($3 = '' OR is_active = $3::boolean)
+		AND ($6::uuid IS NULL OR organization_id = $6)
+		AND anonymized_at IS NULL
 		ORDER BY created_at DESC
 		LIMIT $4 OFFSET $5
 	`
 	offset := (filter.Page - 1) * filter.Limit
 
-	rows, err := s.db.Query(query,
+	rows, err := s.db.QueryContext(ctx, query,
 		filter.Search,
 		filter.Role,
 		filter.IsActive,
 		filter.Limit,
 		offset,
+		organizationArg(ctx),
 	)
 	if err != nil {
 		return nil, 0, err
@@ -349,50 +870,110 @@ func (s *UserService) GetUsers(filter models.UserFilter) ([]models.User, int, er
 	defer rows.Close()
 
 	var users []models.User
+	var total int
 	for rows.Next() {
 		var u models.User
-		err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.Role, &u.IsActive, &u.CreatedAt, &u.UpdatedAt)
+		err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.Role, &u.IsActive, &u.CreatedAt, &u.UpdatedAt, &u.OrganizationID, &total)
 		if err != nil {
 			return nil, 0, err
 		}
 		users = append(users, u)
 	}
 
-	// Get total count
-	var total int
-	countQuery := `
-		SELECT COUNT(*) FROM users
-		WHERE ($1 = '' OR name ILIKE '%' || $1 || '%' OR email ILIKE '%' || $1 || '%')
-		AND ($2 = '' OR role = $2)
-		AND ($3 = '' OR is_active = $3::boolean)
+	if len(users) == 0 {
+		countQuery := `
+			SELECT COUNT(*) FROM users
+			WHERE ($1 = '' OR name ILIKE '%' || $1 || '%' OR email ILIKE '%' || $1 || '%')
+			AND ($2 = '' OR role = $2)
+			AND ($3 = '' OR is_active = $3::boolean)
+			AND ($4::uuid IS NULL OR organization_id = $4)
+		`
+		total, err = pastEndTotal(ctx, s.db, filter.Page, countQuery, filter.Search, filter.Role, filter.IsActive, organizationArg(ctx))
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return users, total, nil
+}
+
+// GetActiveUsersByRole returns all active users with the given role.
+func (s *UserService) GetActiveUsersByRole(ctx context.Context, role models.UserRole) ([]models.User, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT id, name, email, role, is_active, COALESCE(locale, ''), created_at, updated_at, organization_id
+		FROM users WHERE role = $1 AND is_active = true
+		AND ($2::uuid IS NULL OR organization_id = $2)
+		ORDER BY created_at
 	`
-	err = s.db.QueryRow(countQuery, filter.Search, filter.Role, filter.IsActive).Scan(&total)
+	rows, err := s.db.QueryContext(ctx, query, role, organizationArg(ctx))
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
+	defer rows.Close()
 
-	return users, total, nil
+	var users []models.User
+	for rows.Next() {
+		var u models.User
+		err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.Role, &u.IsActive, &u.Locale, &u.CreatedAt, &u.UpdatedAt, &u.OrganizationID)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, nil
 }
 
-func (s *UserService) GetUser(id uuid.UUID) (*models.User, error) {
+func (s *UserService) GetUser(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
 	query := `
-		SELECT id, name, email, role, is_active, created_at, updated_at
-		FROM users WHERE id = $1
+		SELECT id, name, email, role, is_active, COALESCE(locale, ''), created_at, updated_at, organization_id
+		FROM users WHERE id = $1 AND ($2::uuid IS NULL OR organization_id = $2)
 	`
 	var user models.User
-	err := s.db.QueryRow(query, id).Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt)
+	err := s.db.QueryRowContext(ctx, query, id, organizationArg(ctx)).Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.IsActive, &user.Locale, &user.CreatedAt, &user.UpdatedAt, &user.OrganizationID)
 	if err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
-func (s *UserService) CreateUser(user *models.User) error {
+// GetLocale returns the user's preferred report locale, or "" if they have
+// not chosen one.
+func (s *UserService) GetLocale(ctx context.Context, id uuid.UUID) (string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var locale sql.NullString
+	err := s.db.QueryRowContext(ctx, "SELECT locale FROM users WHERE id = $1 AND ($2::uuid IS NULL OR organization_id = $2)", id, organizationArg(ctx)).Scan(&locale)
+	if err != nil {
+		return "", err
+	}
+	return locale.String, nil
+}
+
+func (s *UserService) CreateUser(ctx context.Context, user *models.User) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	// Users join the organization of whoever creates them; sign-ups join
+	// the default one
+	if organizationID, ok := OrganizationFrom(ctx); ok {
+		user.OrganizationID = organizationID
+	} else if user.OrganizationID == uuid.Nil {
+		user.OrganizationID = DefaultOrganizationID
+	}
+
 	query := `
-		INSERT INTO users (id, name, email, password, role, is_active, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO users (id, name, email, password, role, is_active, created_at, updated_at, organization_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 	`
-	_, err := s.db.Exec(query,
+	_, err := s.db.ExecContext(ctx, query,
 		user.ID,
 		user.Name,
 		user.Email,
@@ -401,66 +982,133 @@ func (s *UserService) CreateUser(user *models.User) error {
 		user.IsActive,
 		user.CreatedAt,
 		user.UpdatedAt,
+		user.OrganizationID,
 	)
 	return err
 }
 
-func (s *UserService) UpdateUser(id uuid.UUID, updates map[string]interface{}) error {
+func (s *UserService) UpdateUser(ctx context.Context, id uuid.UUID, updates map[string]interface{}) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
 	if len(updates) == 0 {
 		return nil
 	}
 
-	query := "UPDATE users SET "
-	args := []interface{}{}
-	setParts := []string{}
+	query, args, err := userUpdate(ctx, id, updates)
+	if err != nil || query == "" {
+		return err
+	}
 
-	for field, value := range updates {
-		switch field {
-		case "name":
-			setParts = append(setParts, "name = $"+strconv.Itoa(len(args)+1))
-			args = append(args, value)
-		case "email":
-			setParts = append(setParts, "email = $"+strconv.Itoa(len(args)+1))
-			args = append(args, value)
-		case "role":
-			setParts = append(setParts, "role = $"+strconv.Itoa(len(args)+1))
-			args = append(args, value)
-		case "is_active":
-			setParts = append(setParts, "is_active = $"+strconv.Itoa(len(args)+1))
-			args = append(args, value)
+	changed := []string{}
+	for field := range updates {
+		if userUpdateColumns[field] {
+			changed = append(changed, field)
 		}
 	}
+	sort.Strings(changed)
 
-	if len(setParts) == 0 {
-		return nil
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	query += strings.Join(setParts, ", ") + ", updated_at = NOW()"
-	args = append(args, id)
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	if err := queueUserUpdated(ctx, tx, result, id, changed); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
 
-	_, err := s.db.Exec(query, args...)
-	return err
+// SetPassword replaces a user's password hash. UpdateUser never changes
+// passwords, so an admin editing a user cannot set one by accident.
+func (s *UserService) SetPassword(ctx context.Context, id uuid.UUID, hashedPassword string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE users SET password = $2, updated_at = NOW()
+		WHERE id = $1 AND anonymized_at IS NULL`, id, hashedPassword)
+	if err != nil {
+		return fmt.Errorf("failed to set password: %w", err)
+	}
+	if err := queueUserUpdated(ctx, tx, result, id, []string{"password"}); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (s *UserService) DeleteUser(id uuid.UUID) error {
-	query := "DELETE FROM users WHERE id = $1"
-	_, err := s.db.Exec(query, id)
-	return err
+// queueUserUpdated queues user.updated for the user an update in tx
+// changed, if it changed them.
+func queueUserUpdated(ctx context.Context, tx *sql.Tx, result sql.Result, id uuid.UUID, changed []string) error {
+	if rowsAffected, err := result.RowsAffected(); err != nil || rowsAffected == 0 {
+		return err
+	}
+
+	var user models.User
+	err := tx.QueryRowContext(ctx, `
+		SELECT id, name, email, role, is_active, COALESCE(locale, ''), created_at, updated_at, organization_id
+		FROM users WHERE id = $1`, id).Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.IsActive, &user.Locale,
+		&user.CreatedAt, &user.UpdatedAt, &user.OrganizationID)
+	if err != nil {
+		return fmt.Errorf("failed to get updated user: %w", err)
+	}
+	return queueWebhookEvent(ctx, tx, user.OrganizationID, models.WebhookUserUpdated, models.UserUpdatedEvent{User: user, Changed: changed})
 }
 
-func (s *UserService) GetUserByEmail(email string) (*models.User, error) {
+func (s *UserService) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
 	query := `
-		SELECT id, name, email, password, role, is_active, created_at, updated_at
+		SELECT id, name, email, password, role, is_active, created_at, updated_at, organization_id
 		FROM users WHERE email = $1
 	`
 	var user models.User
-	err := s.db.QueryRow(query, email).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt)
+	err := s.db.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt, &user.OrganizationID)
 	if err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
+// TakenEmails returns which of emails, lowercased, already belong to a
+// user of any organization, as emails are unique across all of them.
+func (s *UserService) TakenEmails(ctx context.Context, emails []string) (map[string]bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	lowered := make([]string, len(emails))
+	for i, email := range emails {
+		lowered[i] = strings.ToLower(email)
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT LOWER(email) FROM users WHERE LOWER(email) = ANY($1)", pq.Array(lowered))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check emails: %w", err)
+	}
+	defer rows.Close()
+
+	taken := map[string]bool{}
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			return nil, fmt.Errorf("failed to scan email: %w", err)
+		}
+		taken[email] = true
+	}
+	return taken, rows.Err()
+}
+
 // CategoryService handles category database operations
 type CategoryService struct {
 	db *sql.DB
@@ -470,9 +1118,11 @@ func NewCategoryService(db *sql.DB) *CategoryService {
 	return &CategoryService{db: db}
 }
 
-func (s *CategoryService) GetCategories() ([]models.Category, error) {
-	query := "SELECT id, name, description, created_at FROM categories ORDER BY name"
-	rows, err := s.db.Query(query)
+func (s *CategoryService) GetCategories(ctx context.Context) ([]models.Category, error) {
+	// Categories whose deletion is pending are gone unless it is undone
+	query := "SELECT id, name, description, created_at FROM categories WHERE ($1::uuid IS NULL OR organization_id = $1) AND " +
+		notPendingDeletion(models.DeletionCategories) + " ORDER BY name"
+	rows, err := s.db.QueryContext(ctx, query, organizationArg(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -490,21 +1140,26 @@ func (s *CategoryService) GetCategories() ([]models.Category, error) {
 	return categories, nil
 }
 
-func (s *CategoryService) CreateCategory(category *models.Category) error {
+func (s *CategoryService) CreateCategory(ctx context.Context, category *models.Category) error {
+	organizationID, ok := OrganizationFrom(ctx)
+	if !ok {
+		organizationID = DefaultOrganizationID
+	}
 	query := `
-		INSERT INTO categories (id, name, description, created_at)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO categories (id, name, description, created_at, organization_id)
+		VALUES ($1, $2, $3, $4, $5)
 	`
-	_, err := s.db.Exec(query,
+	_, err := s.db.ExecContext(ctx, query,
 		category.ID,
 		category.Name,
 		category.Description,
 		category.CreatedAt,
+		organizationID,
 	)
 	return err
 }
 
-func (s *CategoryService) UpdateCategory(id uuid.UUID, updates map[string]interface{}) error {
+func (s *CategoryService) UpdateCategory(ctx context.Context, id uuid.UUID, updates map[string]interface{}) error {
 	if len(updates) == 0 {
 		return nil
 	}
@@ -528,23 +1183,37 @@ func (s *CategoryService) UpdateCategory(id uuid.UUID, updates map[string]interf
 		return nil
 	}
 
-	query += strings.Join(setParts, ", ")
-	args = append(args, id)
+	args = append(args, id, organizationArg(ctx))
+	query += strings.Join(setParts, ", ") + " WHERE id = $" + strconv.Itoa(len(args)-1) +
+		" AND ($" + strconv.Itoa(len(args)) + "::uuid IS NULL OR organization_id = $" + strconv.Itoa(len(args)) + ")"
 
-	_, err := s.db.Exec(query, args...)
+	_, err := s.db.ExecContext(ctx, query, args...)
 	return err
 }
 
-func (s *CategoryService) DeleteCategory(id uuid.UUID) error {
-	query := "DELETE FROM categories WHERE id = $1"
-	_, err := s.db.Exec(query, id)
-	return err
+// DeleteCategory deletes the category, and with a context from
+// WithPendingDeletion its pending deletion along with it.
+func (s *CategoryService) DeleteCategory(ctx context.Context, id uuid.UUID) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := completePendingDeletion(ctx, tx); err != nil {
+		return err
+	}
+	query := "DELETE FROM categories WHERE id = $1 AND ($2::uuid IS NULL OR organization_id = $2)"
+	if _, err := tx.ExecContext(ctx, query, id, organizationArg(ctx)); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (s *CategoryService) GetCategory(id uuid.UUID) (*models.Category, error) {
-	query := "SELECT id, name, description, created_at FROM categories WHERE id = $1"
+func (s *CategoryService) GetCategory(ctx context.Context, id uuid.UUID) (*models.Category, error) {
+	query := "SELECT id, name, description, created_at FROM categories WHERE id = $1 AND ($2::uuid IS NULL OR organization_id = $2)"
 	var category models.Category
-	err := s.db.QueryRow(query, id).Scan(&category.ID, &category.Name, &category.Description, &category.CreatedAt)
+	err := s.db.QueryRowContext(ctx, query, id, organizationArg(ctx)).Scan(&category.ID, &category.Name, &category.Description, &category.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -553,19 +1222,34 @@ func (s *CategoryService) GetCategory(id uuid.UUID) (*models.Category, error) {
 
 // DashboardService handles dashboard data operations
 type DashboardService struct {
-	db *sql.DB
+	db    *sql.DB
+	cache *Cache
 }
 
 func NewDashboardService(db *sql.DB) *DashboardService {
 	return &DashboardService{db: db}
 }
 
-func (s *DashboardService) GetStats() (map[string]interface{}, error) {
+// NewCachedDashboardService returns a DashboardService whose stats are read
+// through cache. Product and stock writes invalidate them.
+func NewCachedDashboardService(db *sql.DB, cache *Cache) *DashboardService {
+	return &DashboardService{db: db, cache: cache}
+}
+
+func (s *DashboardService) GetStats(ctx context.Context) (map[string]interface{}, error) {
 	stats := make(map[string]interface{})
+	if s.cache.get(ctx, dashboardStatsKey(ctx), &stats) {
+		stats["server_time"] = time.Now()
+		return stats, nil
+	}
+
+	// The aggregates run on the read replica when there is one
+	db := ForReads(s.db)
+	organization := organizationArg(ctx)
 
 	// Get total products
 	var totalProducts int
-	err := s.db.QueryRow("SELECT COUNT(*) FROM products").Scan(&totalProducts)
+	err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM products WHERE ($1::uuid IS NULL OR organization_id = $1)", organization).Scan(&totalProducts)
 	if err != nil {
 		return nil, err
 	}
@@ -573,7 +1257,7 @@ func (s *DashboardService) GetStats() (map[string]interface{}, error) {
 
 	// Get low stock count
 	var lowStockCount int
-	err = s.db.QueryRow("SELECT COUNT(*) FROM products WHERE stock <= minimum_threshold AND minimum_threshold > 0").Scan(&lowStockCount)
+	err = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM products WHERE stock <= minimum_threshold AND minimum_threshold > 0 AND ($1::uuid IS NULL OR organization_id = $1)", organization).Scan(&lowStockCount)
 	if err != nil {
 		return nil, err
 	}
@@ -581,7 +1265,7 @@ func (s *DashboardService) GetStats() (map[string]interface{}, error) {
 
 	// Get total users
 	var totalUsers int
-	err = s.db.QueryRow("SELECT COUNT(*) FROM users WHERE is_active = true").Scan(&totalUsers)
+	err = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE is_active = true AND ($1::uuid IS NULL OR organization_id = $1)", organization).Scan(&totalUsers)
 	if err != nil {
 		return nil, err
 	}
@@ -589,7 +1273,7 @@ func (s *DashboardService) GetStats() (map[string]interface{}, error) {
 
 	// Get total categories
 	var totalCategories int
-	err = s.db.QueryRow("SELECT COUNT(*) FROM categories").Scan(&totalCategories)
+	err = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM categories WHERE ($1::uuid IS NULL OR organization_id = $1)", organization).Scan(&totalCategories)
 	if err != nil {
 		return nil, err
 	}
@@ -597,10 +1281,11 @@ func (s *DashboardService) GetStats() (map[string]interface{}, error) {
 
 	// Get total movements this month
 	var totalMovements int
-	err = s.db.QueryRow(`
+	err = db.QueryRowContext(ctx, `
 		SELECT COUNT(*) FROM stock_movements
 		WHERE created_at >= date_trunc('month', CURRENT_DATE)
-	`).Scan(&totalMovements)
+		AND ($1::uuid IS NULL OR organization_id = $1)
+	`, organization).Scan(&totalMovements)
 	if err != nil {
 		return nil, err
 	}
@@ -608,12 +1293,13 @@ func (s *DashboardService) GetStats() (map[string]interface{}, error) {
 
 	// Get revenue this month (simplified calculation)
 	var revenueThisMonth float64
-	err = s.db.QueryRow(`
+	err = db.QueryRowContext(ctx, `
 		SELECT COALESCE(SUM(p.price * sm.change), 0)
 		FROM products p
 		JOIN stock_movements sm ON p.id = sm.product_id
 		WHERE sm.reason = 'sale' AND sm.created_at >= date_trunc('month', CURRENT_DATE)
-	`).Scan(&revenueThisMonth)
+		AND ($1::uuid IS NULL OR p.organization_id = $1)
+	`, organization).Scan(&revenueThisMonth)
 	if err != nil {
 		return nil, err
 	}
@@ -625,15 +1311,16 @@ func (s *DashboardService) GetStats() (map[string]interface{}, error) {
 		Name  string
 		Sales int
 	}
-	err = s.db.QueryRow(`
+	err = db.QueryRowContext(ctx, `
 		SELECT p.id, p.name, SUM(ABS(sm.change)) as total_sales
 		FROM products p
 		JOIN stock_movements sm ON p.id = sm.product_id
 		WHERE sm.reason = 'sale' AND sm.created_at >= date_trunc('month', CURRENT_DATE)
+		AND ($1::uuid IS NULL OR p.organization_id = $1)
 		GROUP BY p.id, p.name
 		ORDER BY total_sales DESC
 		LIMIT 1
-	`).Scan(&topProduct.ID, &topProduct.Name, &topProduct.Sales)
+	`, organization).Scan(&topProduct.ID, &topProduct.Name, &topProduct.Sales)
 	if err != nil && err != sql.ErrNoRows {
 		return nil, err
 	}
@@ -647,21 +1334,25 @@ func (s *DashboardService) GetStats() (map[string]interface{}, error) {
 		}
 	}
 
+	s.cache.set(ctx, dashboardStatsKey(ctx), stats)
 	stats["server_time"] = time.Now()
 
 	return stats, nil
 }
 
-func (s *DashboardService) GetAlerts() ([]map[string]interface{}, error) {
+func (s *DashboardService) GetAlerts(ctx context.Context) ([]map[string]interface{}, error) {
+	db := ForReads(s.db)
+
 	query := `
 		SELECT p.id, p.name, p.sku, p.stock, p.minimum_threshold
 		FROM products p
 		WHERE p.stock <= p.minimum_threshold AND p.minimum_threshold > 0
+		AND ($1::uuid IS NULL OR p.organization_id = $1)
 		ORDER BY p.stock ASC
 		LIMIT 10
 	`
 
-	rows, err := s.db.Query(query)
+	rows, err := db.QueryContext(ctx, query, organizationArg(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -711,21 +1402,10 @@ func NewSettingsService(db *sql.DB) *SettingsService {
 func (s *SettingsService) GetSettings() (map[string]interface{}, error) {
 	settings := make(map[string]interface{})
 
-	// Get settings from database
-	query := "SELECT key, value FROM system_settings"
-	rows, err := s.db.Query(query)
+	// The table and its defaults come from the system_settings migration
+	rows, err := s.db.Query("SELECT key, value FROM system_settings")
 	if err != nil {
-		// If table doesn't exist, create it with default values
-		log.Printf("system_settings table not found, creating with defaults: %v", err)
-		err = s.initializeDefaultSettings()
-		if err != nil {
-			return nil, fmt.Errorf("failed to initialize default settings: %w", err)
-		}
-		// Retry query after initialization
-		rows, err = s.db.Query(query)
-		if err != nil {
-			return nil, fmt.Errorf("failed to query settings after initialization: %w", err)
-		}
+		return nil, fmt.Errorf("failed to query settings: %w", err)
 	}
 	defer rows.Close()
 
@@ -741,45 +1421,6 @@ func (s *SettingsService) GetSettings() (map[string]interface{}, error) {
 	return settings, nil
 }
 
-func (s *SettingsService) initializeDefaultSettings() error {
-	// Create system_settings table if it doesn't exist
-	createTableQuery := `
-		CREATE TABLE IF NOT EXISTS system_settings (
-			key VARCHAR(255) PRIMARY KEY,
-			value TEXT,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)
-	`
-
-	_, err := s.db.Exec(createTableQuery)
-	if err != nil {
-		return fmt.Errorf("failed to create system_settings table: %w", err)
-	}
-
-	// Insert default settings
-	defaultSettings := map[string]interface{}{
-		"low_stock_threshold": 10,
-		"notification_emails": []string{"admin@example.com"},
-		"auto_backup":         true,
-		"backup_frequency":    "daily",
-		"maintenance_mode":    false,
-	}
-
-	for key, value := range defaultSettings {
-		query := `
-			INSERT INTO system_settings (key, value, updated_at)
-			VALUES ($1, $2, NOW())
-			ON CONFLICT (key) DO NOTHING
-		`
-		_, err = s.db.Exec(query, key, value)
-		if err != nil {
-			return fmt.Errorf("failed to insert default setting %s: %w", key, err)
-		}
-	}
-
-	return nil
-}
-
 func (s *SettingsService) UpdateSettings(updates map[string]interface{}) error {
 	tx, err := s.db.Begin()
 	if err != nil {
@@ -804,31 +1445,37 @@ func (s *SettingsService) UpdateSettings(updates map[string]interface{}) error {
 	return tx.Commit()
 }
 
-func (s *SettingsService) GetSystemStatus() (map[string]interface{}, error) {
+// GetSystemStatus reports on the database, its read replica if there is
+// one, Redis, storage and backups. redisClient may be nil when Redis is not
+// used.
+func (s *SettingsService) GetSystemStatus(ctx context.Context, redisClient *redis.Client) (map[string]interface{}, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 	status := make(map[string]interface{})
 
-	// Database status
+	// Database status, with this instance's connection pool
 	var dbConnections int
-	err := s.db.QueryRow("SELECT COUNT(*) FROM pg_stat_activity WHERE state = 'active'").Scan(&dbConnections)
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM pg_stat_activity WHERE state = 'active'").Scan(&dbConnections)
 	if err != nil {
-		status["database"] = gin.H{"status": "error", "error": err.Error()}
+		status["database"] = gin.H{"status": "error", "error": err.Error(), "pool": poolStatus(s.db)}
 	} else {
 		status["database"] = gin.H{
 			"status":      "healthy",
 			"connections": dbConnections,
+			"pool":        poolStatus(s.db),
 			"last_check":  time.Now(),
 		}
 	}
-
-	// Cache status (Redis) - simplified check
-	status["cache"] = gin.H{
-		"status":     "healthy",
-		"last_check": time.Now(),
+	if replica := replicaStatus(ctx); replica != nil {
+		status["replica"] = replica
 	}
 
+	// Cache status, from Redis INFO
+	status["cache"] = redisStatus(ctx, redisClient)
+
 	// Storage status - get actual database size
 	var dbSize float64
-	err = s.db.QueryRow(`
+	err = s.db.QueryRowContext(ctx, `
 		SELECT
 			pg_database_size(current_database()) / 1024.0 / 1024.0 as size_mb
 	`).Scan(&dbSize)
@@ -842,63 +1489,28 @@ func (s *SettingsService) GetSystemStatus() (map[string]interface{}, error) {
 		}
 	}
 
-	// Last backup - get from audit logs or system settings
-	var lastBackupTime time.Time
-	err = s.db.QueryRow(`
-		SELECT changed_at FROM audit_logs
-		WHERE action = 'backup_triggered'
-		ORDER BY changed_at DESC
-		LIMIT 1
-	`).Scan(&lastBackupTime)
+	// Last completed backup, and the latest attempt when it has not
+	// completed, so a failing nightly backup is noticed
+	backupService := NewBackupService(s.db)
+	lastBackup, err := backupService.GetLastCompletedBackup()
 	if err != nil {
-		// No backup found, use current time as fallback
-		lastBackupTime = time.Now()
-	}
-
-	status["last_backup"] = gin.H{
-		"timestamp": lastBackupTime,
-		"status":    "success",
+		status["last_backup"] = gin.H{"status": "error", "error": err.Error()}
+	} else if lastBackup == nil {
+		status["last_backup"] = gin.H{"status": "none"}
+	} else {
+		status["last_backup"] = gin.H{
+			"status":    "success",
+			"timestamp": lastBackup.CompletedAt,
+			"id":        lastBackup.ID,
+			"storage":   lastBackup.Storage,
+			"location":  lastBackup.Location,
+			"size":      lastBackup.Size,
+			"checksum":  lastBackup.Checksum,
+		}
 	}
-
-	// Uptime - calculate from current session
-	status["uptime"] = gin.H{
-		"last_check": time.Now(),
+	if latest, err := backupService.GetBackups(1); err == nil && len(latest) == 1 && latest[0].Status != models.BackupCompleted {
+		status["latest_backup"] = latest[0]
 	}
 
 	return status, nil
 }
-
-func (s *SettingsService) TriggerBackup() (map[string]interface{}, error) {
-	// Get current database size for estimation
-	var dbSize float64
-	err := s.db.QueryRow("SELECT pg_database_size(current_database()) / 1024.0 / 1024.0").Scan(&dbSize)
-	if err != nil {
-		dbSize = 100 // fallback estimate in MB
-	}
-
-	// Estimate backup time based on database size
-	var estimatedTime string
-	if dbSize < 50 {
-		estimatedTime = "1-2 minutes"
-	} else if dbSize < 200 {
-		estimatedTime = "3-5 minutes"
-	} else {
-		estimatedTime = "5-10 minutes"
-	}
-
-	backup := map[string]interface{}{
-		"success":        true,
-		"message":        "Backup initiated successfully",
-		"backup_id":      uuid.New(),
-		"estimated_time": estimatedTime,
-		"started_at":     time.Now(),
-		"database_size":  fmt.Sprintf("%.2fMB", dbSize),
-	}
-
-	// In a real implementation, this would trigger an actual backup process
-	// For now, we'll just log the backup initiation
-	log.Printf("Backup initiated with ID: %s, estimated time: %s, database size: %.2fMB",
-		backup["backup_id"], estimatedTime, dbSize)
-
-	return backup, nil
-}
\ No newline at end of file
@@ -1,161 +1,224 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"strings"
+	"log"
 	"time"
 
 	"rtims-backend/internal/models"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 type ProductService struct {
-	db *sql.DB
+	db    *sql.DB
+	cache *Cache
 }
 
 func NewProductService(db *sql.DB) *ProductService {
 	return &ProductService{db: db}
 }
 
-func (s *ProductService) GetProducts(filter models.ProductFilter) ([]models.Product, int, error) {
-	// Build query
-	query := `SELECT id, name, sku, stock, price, category, minimum_threshold, supplier_info, created_at, updated_at FROM products`
-	countQuery := `SELECT COUNT(*) FROM products`
-	var args []interface{}
-	var conditions []string
+// NewCachedProductService returns a ProductService that reads products and
+// the first pages of product listings through cache, and invalidates them
+// on every write.
+func NewCachedProductService(db *sql.DB, cache *Cache) *ProductService {
+	return &ProductService{db: db, cache: cache}
+}
 
-	// Add filters
-	if filter.Search != "" {
-		conditions = append(conditions, "(name ILIKE $%d OR sku ILIKE $%d OR category ILIKE $%d)")
-		args = append(args, "%"+filter.Search+"%", "%"+filter.Search+"%", "%"+filter.Search+"%")
-	}
+// scanProduct scans a row of productColumns, followed by extra columns.
+func scanProduct(row interface{ Scan(...interface{}) error }, p *models.Product, extra ...interface{}) error {
+	return row.Scan(append([]interface{}{
+		&p.ID, &p.Name, &p.SKU, &p.Stock, &p.Price, &p.Category, &p.MinimumThreshold,
+		&p.SupplierInfo, &p.CreatedAt, &p.UpdatedAt, &p.OrganizationID,
+	}, extra...)...)
+}
 
-	if filter.Category != "" {
-		conditions = append(conditions, "category = $%d")
-		args = append(args, filter.Category)
-	}
+// cachedProductPage is the cached form of one GetProducts result.
+type cachedProductPage struct {
+	Products []models.Product `json:"products"`
+	Total    int              `json:"total"`
+}
 
-	if filter.MinStock != nil {
-		conditions = append(conditions, "stock >= $%d")
-		args = append(args, *filter.MinStock)
-	}
+func (s *ProductService) GetProducts(ctx context.Context, filter models.ProductFilter) ([]models.Product, int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 
-	if filter.MaxStock != nil {
-		conditions = append(conditions, "stock <= $%d")
-		args = append(args, *filter.MaxStock)
+	// Searches go to the search index when there is one, and to Postgres
+	// while it is unreachable
+	if productSearcher != nil && filter.Search != "" {
+		products, total, err := productSearcher.SearchProducts(ctx, filter)
+		if err == nil {
+			return products, total, nil
+		}
+		log.Printf("Product search failed, searching the database instead: %v", err)
 	}
 
-	if filter.MinPrice != nil {
-		conditions = append(conditions, "price >= $%d")
-		args = append(args, *filter.MinPrice)
+	var cacheKey string
+	if s.cache != nil && filter.Search == "" && filter.Page <= hotProductPages {
+		if key, ok := s.cache.productListKey(ctx, filter); ok {
+			var cached cachedProductPage
+			if s.cache.get(ctx, key, &cached) {
+				return cached.Products, cached.Total, nil
+			}
+			cacheKey = key
+		}
 	}
 
-	if filter.MaxPrice != nil {
-		conditions = append(conditions, "price <= $%d")
-		args = append(args, *filter.MaxPrice)
-	}
+	query, count := productQueries(ctx, filter)
 
-	if filter.LowStockOnly {
-		conditions = append(conditions, "stock <= minimum_threshold")
+	pageQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to build product query: %w", err)
 	}
+	rows, err := s.db.QueryContext(ctx, pageQuery, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get products: %w", err)
+	}
+	defer rows.Close()
 
-	// Add WHERE clause if conditions exist
-	if len(conditions) > 0 {
-		whereClause := " WHERE " + strings.Join(conditions, " AND ")
-		query += whereClause
-		countQuery += whereClause
+	var products []models.Product
+	var total int
+	for rows.Next() {
+		var product models.Product
+		err := scanProduct(rows, &product, &total)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan product: %w", err)
+		}
+		products = append(products, product)
 	}
 
-	// Add sorting
-	sortBy := "created_at"
-	sortOrder := "DESC"
-	if filter.SortBy != "" {
-		switch filter.SortBy {
-		case "name", "sku", "stock", "price", "category", "created_at", "updated_at":
-			sortBy = filter.SortBy
+	if len(products) == 0 {
+		countQuery, args, err := count.ToSql()
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to build product count query: %w", err)
+		}
+		if total, err = pastEndTotal(ctx, s.db, filter.Page, countQuery, args...); err != nil {
+			return nil, 0, fmt.Errorf("failed to get product count: %w", err)
 		}
 	}
-	if filter.SortOrder != "" && (filter.SortOrder == "ASC" || filter.SortOrder == "DESC") {
-		sortOrder = filter.SortOrder
+
+	if cacheKey != "" {
+		s.cache.set(ctx, cacheKey, cachedProductPage{Products: products, Total: total})
 	}
-	query += fmt.Sprintf(" ORDER BY %s %s", sortBy, sortOrder)
+	return products, total, nil
+}
 
-	// Add pagination
-	offset := (filter.Page - 1) * filter.Limit
-	query += fmt.Sprintf(" LIMIT %d OFFSET %d", filter.Limit, offset)
+// GetProductsAfter returns up to limit products matching filter, newest
+// first, starting after the cursor, and the cursor of the next page, which
+// is nil on the last one. Listings by cursor are not cached.
+func (s *ProductService) GetProductsAfter(ctx context.Context, filter models.ProductFilter, after *Cursor, limit int) ([]models.Product, *Cursor, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 
-	// Get total count
-	var total int
-	err := s.db.QueryRow(countQuery, args...).Scan(&total)
+	query, args, err := productKeysetQuery(ctx, filter, after, limit).ToSql()
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to get product count: %w", err)
+		return nil, nil, fmt.Errorf("failed to build product query: %w", err)
 	}
-
-	// Get products
-	rows, err := s.db.Query(query, args...)
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to get products: %w", err)
+		return nil, nil, fmt.Errorf("failed to get products: %w", err)
 	}
 	defer rows.Close()
 
-	var products []models.Product
+	products := []models.Product{}
 	for rows.Next() {
 		var product models.Product
-		err := rows.Scan(
-			&product.ID,
-			&product.Name,
-			&product.SKU,
-			&product.Stock,
-			&product.Price,
-			&product.Category,
-			&product.MinimumThreshold,
-			&product.SupplierInfo,
-			&product.CreatedAt,
-			&product.UpdatedAt,
-		)
+		err := scanProduct(rows, &product)
 		if err != nil {
-			return nil, 0, fmt.Errorf("failed to scan product: %w", err)
+			return nil, nil, fmt.Errorf("failed to scan product: %w", err)
 		}
 		products = append(products, product)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to get products: %w", err)
+	}
 
-	return products, total, nil
+	if len(products) <= limit {
+		return products, nil, nil
+	}
+	products = products[:limit]
+	last := products[limit-1]
+	return products, &Cursor{CreatedAt: last.CreatedAt, ID: last.ID}, nil
 }
 
-func (s *ProductService) GetProduct(id uuid.UUID) (*models.Product, error) {
-	query := `SELECT id, name, sku, stock, price, category, minimum_threshold, supplier_info, created_at, updated_at
-			  FROM products WHERE id = $1`
+func (s *ProductService) GetProduct(ctx context.Context, id uuid.UUID) (*models.Product, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 
 	var product models.Product
-	err := s.db.QueryRow(query, id).Scan(
-		&product.ID,
-		&product.Name,
-		&product.SKU,
-		&product.Stock,
-		&product.Price,
-		&product.Category,
-		&product.MinimumThreshold,
-		&product.SupplierInfo,
-		&product.CreatedAt,
-		&product.UpdatedAt,
-	)
+	if s.cache.get(ctx, productCacheKey+id.String(), &product) {
+		if organizationID, ok := OrganizationFrom(ctx); ok && product.OrganizationID != organizationID {
+			return nil, fmt.Errorf("product %w", ErrNotFound)
+		}
+		return &product, nil
+	}
+
+	query := `SELECT ` + productColumns + `
+			  FROM products WHERE id = $1 AND ($2::uuid IS NULL OR organization_id = $2)`
+
+	err := scanProduct(s.db.QueryRowContext(ctx, query, id, organizationArg(ctx)), &product)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("product not found")
+			return nil, fmt.Errorf("product %w", ErrNotFound)
 		}
 		return nil, fmt.Errorf("failed to get product: %w", err)
 	}
 
+	s.cache.set(ctx, productCacheKey+id.String(), product)
 	return &product, nil
 }
 
-func (s *ProductService) CreateProduct(product *models.Product) error {
-	query := `INSERT INTO products (id, name, sku, stock, price, category, minimum_threshold, supplier_info, created_at, updated_at)
-			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+// GetProductsBySKU returns the products of the organization of ctx with the
+// given SKUs, by SKU. SKUs no product has are left out.
+func (s *ProductService) GetProductsBySKU(ctx context.Context, skus []string) (map[string]models.Product, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + productColumns + `
+			  FROM products WHERE sku = ANY($1) AND ($2::uuid IS NULL OR organization_id = $2)`
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(skus), organizationArg(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get products by SKU: %w", err)
+	}
+	defer rows.Close()
+
+	products := make(map[string]models.Product)
+	for rows.Next() {
+		var product models.Product
+		if err := scanProduct(rows, &product); err != nil {
+			return nil, fmt.Errorf("failed to scan product: %w", err)
+		}
+		products[product.SKU] = product
+	}
+	return products, rows.Err()
+}
+
+func (s *ProductService) CreateProduct(ctx context.Context, product *models.Product) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	if organizationID, ok := OrganizationFrom(ctx); ok {
+		product.OrganizationID = organizationID
+	} else if product.OrganizationID == uuid.Nil {
+		product.OrganizationID = DefaultOrganizationID
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO products (id, name, sku, stock, price, category, minimum_threshold, supplier_info, created_at, updated_at, organization_id)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
 
-	_, err := s.db.Exec(query,
+	_, err = tx.ExecContext(ctx, query,
 		product.ID,
 		product.Name,
 		product.SKU,
@@ -166,54 +229,46 @@ func (s *ProductService) CreateProduct(product *models.Product) error {
 		product.SupplierInfo,
 		time.Now(),
 		time.Now(),
+		product.OrganizationID,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create product: %w", err)
 	}
 
+	if err := queueWebhookEvent(ctx, tx, product.OrganizationID, models.WebhookProductCreated, product); err != nil {
+		return err
+	}
+	if err := queueSearchIndex(ctx, tx, models.SearchProducts, product.ID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.cache.invalidateProducts(ctx, product.ID.String())
 	return nil
 }
 
-func (s *ProductService) UpdateProduct(id uuid.UUID, updates map[string]interface{}) error {
+func (s *ProductService) UpdateProduct(ctx context.Context, id uuid.UUID, updates map[string]interface{}) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
 	if len(updates) == 0 {
-		return fmt.Errorf("no updates provided")
-	}
-
-	var setParts []string
-	var args []interface{}
-	argIndex := 1
-
-	for field, value := range updates {
-		switch field {
-		case "name", "sku", "category", "supplier_info":
-			setParts = append(setParts, fmt.Sprintf("%s = $%d", field, argIndex))
-			args = append(args, value)
-			argIndex++
-		case "stock", "minimum_threshold":
-			setParts = append(setParts, fmt.Sprintf("%s = $%d", field, argIndex))
-			args = append(args, value)
-			argIndex++
-		case "price":
-			setParts = append(setParts, fmt.Sprintf("%s = $%d", field, argIndex))
-			args = append(args, value)
-			argIndex++
-		}
+		return ErrNoUpdates
 	}
 
-	if len(setParts) == 0 {
-		return fmt.Errorf("no valid updates provided")
+	query, args, err := productUpdate(ctx, id, updates)
+	if err != nil {
+		return err
 	}
 
-	setParts = append(setParts, fmt.Sprintf("updated_at = $%d", argIndex))
-	args = append(args, time.Now())
-	argIndex++
-
-	args = append(args, id)
-
-	query := fmt.Sprintf("UPDATE products SET %s WHERE id = $%d",
-		strings.Join(setParts, ", "), argIndex)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
 
-	result, err := s.db.Exec(query, args...)
+	result, err := tx.ExecContext(ctx, query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to update product: %w", err)
 	}
@@ -224,16 +279,38 @@ func (s *ProductService) UpdateProduct(id uuid.UUID, updates map[string]interfac
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("product not found")
+		return fmt.Errorf("product %w", ErrNotFound)
 	}
 
+	if err := queueSearchIndex(ctx, tx, models.SearchProducts, id); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.cache.invalidateProducts(ctx, id.String())
 	return nil
 }
 
-func (s *ProductService) DeleteProduct(id uuid.UUID) error {
-	query := `DELETE FROM products WHERE id = $1`
+// DeleteProduct deletes the product, and with a context from
+// WithPendingDeletion its pending deletion along with it.
+func (s *ProductService) DeleteProduct(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 
-	result, err := s.db.Exec(query, id)
+	query := `DELETE FROM products WHERE id = $1 AND ($2::uuid IS NULL OR organization_id = $2)`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := completePendingDeletion(ctx, tx); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, query, id, organizationArg(ctx))
 	if err != nil {
 		return fmt.Errorf("failed to delete product: %w", err)
 	}
@@ -244,106 +321,161 @@ func (s *ProductService) DeleteProduct(id uuid.UUID) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("product not found")
+		return fmt.Errorf("product %w", ErrNotFound)
 	}
 
+	// The indexer drops the product's movements, deleted with it, too
+	if err := queueSearchIndex(ctx, tx, models.SearchProducts, id); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.cache.invalidateProducts(ctx, id.String())
 	return nil
 }
 
-func (s *ProductService) UpdateProductStock(productID uuid.UUID, change int, reason models.MovementReason, createdBy uuid.UUID, notes string) error {
-	tx, err := s.db.Begin()
+// ErrNoUpdates is returned by UpdateProduct when updates holds nothing a
+// product may change.
+var ErrNoUpdates = errors.New("no valid updates provided")
+
+// ErrInsufficientStock is returned when a stock movement would take a
+// product's stock below zero.
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// UpdateProductStock applies change to a product's stock, records the
+// movement and queues an EventStockUpdated outbox event. The product row is
+// locked for the whole transaction, so concurrent movements apply one after
+// another and the returned stock and low-stock flag reflect exactly this
+// movement.
+func (s *ProductService) UpdateProductStock(ctx context.Context, productID uuid.UUID, change int, reason models.MovementReason, createdBy uuid.UUID, notes string) (*models.StockUpdate, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
+	var product models.Product
+	query := `SELECT ` + productColumns + `
+			  FROM products WHERE id = $1 AND ($2::uuid IS NULL OR organization_id = $2) FOR UPDATE`
+	err = scanProduct(tx.QueryRowContext(ctx, query, productID, organizationArg(ctx)), &product)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("product %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to lock product: %w", err)
+	}
+
+	previousStock := product.Stock
+	if previousStock+change < 0 {
+		return nil, ErrInsufficientStock
+	}
+	product.Stock = previousStock + change
+	product.UpdatedAt = time.Now()
+
 	// Update product stock
-	query := `UPDATE products SET stock = stock + $1, updated_at = $2 WHERE id = $3`
-	_, err = tx.Exec(query, change, time.Now(), productID)
+	updateQuery := `UPDATE products SET stock = $1, updated_at = $2 WHERE id = $3`
+	_, err = tx.ExecContext(ctx, updateQuery, product.Stock, product.UpdatedAt, productID)
 	if err != nil {
-		return fmt.Errorf("failed to update product stock: %w", err)
+		return nil, fmt.Errorf("failed to update product stock: %w", err)
 	}
 
 	// Create stock movement record
+	movement := models.StockMovement{
+		ID:        uuid.New(),
+		ProductID: productID,
+		Change:    change,
+		Reason:    reason,
+		CreatedBy: createdBy,
+		CreatedAt: product.UpdatedAt,
+		Notes:     notes,
+	}
 	movementQuery := `INSERT INTO stock_movements (id, product_id, change, reason, created_by, created_at, notes)
 					  VALUES ($1, $2, $3, $4, $5, $6, $7)`
-	movementID := uuid.New()
-	_, err = tx.Exec(movementQuery, movementID, productID, change, reason, createdBy, time.Now(), notes)
+	_, err = tx.ExecContext(ctx, movementQuery, movement.ID, movement.ProductID, movement.Change, movement.Reason,
+		movement.CreatedBy, movement.CreatedAt, movement.Notes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stock movement: %w", err)
+	}
+
+	update := &models.StockUpdate{
+		Product:       product,
+		PreviousStock: previousStock,
+		Movement:      movement,
+		LowStock:      product.MinimumThreshold > 0 && product.Stock <= product.MinimumThreshold,
+	}
+
+	// Live updates and low stock alerts go out through the outbox, so they
+	// are sent if and only if the movement commits
+	err = enqueueEvent(ctx, tx, models.EventStockUpdated, models.StockUpdatedEvent{
+		ProductID:        product.ID,
+		Name:             product.Name,
+		SKU:              product.SKU,
+		Stock:            product.Stock,
+		MinimumThreshold: product.MinimumThreshold,
+		LowStock:         update.LowStock,
+		ChangedBy:        createdBy,
+		OrganizationID:   product.OrganizationID,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to create stock movement: %w", err)
+		return nil, err
 	}
 
-	return tx.Commit()
-}
-
-func (s *ProductService) GetStockMovements(filter models.StockMovementFilter) ([]models.StockMovement, int, error) {
-	// Build query
-	query := `SELECT id, product_id, change, reason, created_by, created_at, notes FROM stock_movements`
-	countQuery := `SELECT COUNT(*) FROM stock_movements`
-	var args []interface{}
-	var conditions []string
-
-	// Add filters
-	if filter.ProductID != nil {
-		conditions = append(conditions, "product_id = $1")
-		args = append(args, *filter.ProductID)
+	// Webhooks hear of every movement, and of low stock when the movement
+	// takes the product down to its threshold
+	event := models.MovementCreatedEvent{
+		Movement:         movement,
+		ProductName:      product.Name,
+		ProductSKU:       product.SKU,
+		PreviousStock:    previousStock,
+		Stock:            product.Stock,
+		MinimumThreshold: product.MinimumThreshold,
 	}
-
-	if filter.Reason != nil {
-		conditions = append(conditions, "reason = $%d")
-		args = append(args, *filter.Reason)
+	if err := queueWebhookEvent(ctx, tx, product.OrganizationID, models.WebhookMovementCreated, event); err != nil {
+		return nil, err
 	}
-
-	if filter.StartDate != nil {
-		conditions = append(conditions, "created_at >= $%d")
-		args = append(args, *filter.StartDate)
+	if update.LowStock && previousStock > product.MinimumThreshold {
+		if err := queueWebhookEvent(ctx, tx, product.OrganizationID, models.WebhookStockLow, event); err != nil {
+			return nil, err
+		}
 	}
 
-	if filter.EndDate != nil {
-		conditions = append(conditions, "created_at <= $%d")
-		args = append(args, *filter.EndDate)
+	if err := queueSearchIndex(ctx, tx, models.SearchProducts, product.ID); err != nil {
+		return nil, err
 	}
-
-	// Add WHERE clause if conditions exist
-	if len(conditions) > 0 {
-		whereClause := " WHERE " + strings.Join(conditions, " AND ")
-		query += whereClause
-		countQuery += whereClause
+	if err := queueSearchIndex(ctx, tx, models.SearchStockMovements, movement.ID); err != nil {
+		return nil, err
 	}
 
-	// Add sorting
-	sortBy := "created_at"
-	sortOrder := "DESC"
-	if filter.SortBy != "" {
-		switch filter.SortBy {
-		case "created_at", "change", "reason":
-			sortBy = filter.SortBy
-		}
-	}
-	if filter.SortOrder != "" && (filter.SortOrder == "ASC" || filter.SortOrder == "DESC") {
-		sortOrder = filter.SortOrder
+	if err := tx.Commit(); err != nil {
+		return nil, err
 	}
-	query += fmt.Sprintf(" ORDER BY %s %s", sortBy, sortOrder)
+	s.cache.invalidateProducts(ctx, productID.String())
 
-	// Add pagination
-	offset := (filter.Page - 1) * filter.Limit
-	query += fmt.Sprintf(" LIMIT %d OFFSET %d", filter.Limit, offset)
+	return update, nil
+}
 
-	// Get total count
-	var total int
-	err := s.db.QueryRow(countQuery, args...).Scan(&total)
+func (s *ProductService) GetStockMovements(ctx context.Context, filter models.StockMovementFilter) ([]models.StockMovement, int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query, count := movementQueries(ctx, filter)
+
+	pageQuery, args, err := query.ToSql()
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to get stock movements count: %w", err)
+		return nil, 0, fmt.Errorf("failed to build stock movements query: %w", err)
 	}
-
-	// Get stock movements
-	rows, err := s.db.Query(query, args...)
+	rows, err := s.db.QueryContext(ctx, pageQuery, args...)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to get stock movements: %w", err)
 	}
 	defer rows.Close()
 
 	var movements []models.StockMovement
+	var total int
 	for rows.Next() {
 		var movement models.StockMovement
 		err := rows.Scan(
@@ -354,6 +486,7 @@ func (s *ProductService) GetStockMovements(filter models.StockMovementFilter) ([
 			&movement.CreatedBy,
 			&movement.CreatedAt,
 			&movement.Notes,
+			&total,
 		)
 		if err != nil {
 			return nil, 0, fmt.Errorf("failed to scan stock movement: %w", err)
@@ -361,15 +494,74 @@ func (s *ProductService) GetStockMovements(filter models.StockMovementFilter) ([
 		movements = append(movements, movement)
 	}
 
+	if len(movements) == 0 {
+		countQuery, args, err := count.ToSql()
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to build stock movements count query: %w", err)
+		}
+		if total, err = pastEndTotal(ctx, s.db, filter.Page, countQuery, args...); err != nil {
+			return nil, 0, fmt.Errorf("failed to get stock movements count: %w", err)
+		}
+	}
+
 	return movements, total, nil
 }
 
-func (s *ProductService) GetStockMovement(id uuid.UUID) (*models.StockMovement, error) {
-	query := `SELECT id, product_id, change, reason, created_by, created_at, notes
-			  FROM stock_movements WHERE id = $1`
+// GetStockMovementsAfter returns up to limit stock movements matching
+// filter, newest first, starting after the cursor, and the cursor of the
+// next page, which is nil on the last one.
+func (s *ProductService) GetStockMovementsAfter(ctx context.Context, filter models.StockMovementFilter, after *Cursor, limit int) ([]models.StockMovement, *Cursor, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query, args, err := movementKeysetQuery(ctx, filter, after, limit).ToSql()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build stock movements query: %w", err)
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get stock movements: %w", err)
+	}
+	defer rows.Close()
+
+	movements := []models.StockMovement{}
+	for rows.Next() {
+		var movement models.StockMovement
+		err := rows.Scan(
+			&movement.ID,
+			&movement.ProductID,
+			&movement.Change,
+			&movement.Reason,
+			&movement.CreatedBy,
+			&movement.CreatedAt,
+			&movement.Notes,
+		)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to scan stock movement: %w", err)
+		}
+		movements = append(movements, movement)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to get stock movements: %w", err)
+	}
+
+	if len(movements) <= limit {
+		return movements, nil, nil
+	}
+	movements = movements[:limit]
+	last := movements[limit-1]
+	return movements, &Cursor{CreatedAt: last.CreatedAt, ID: last.ID}, nil
+}
+
+func (s *ProductService) GetStockMovement(ctx context.Context, id uuid.UUID) (*models.StockMovement, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + movementColumns + `
+			  FROM stock_movements WHERE id = $1 AND ($2::uuid IS NULL OR organization_id = $2)`
 
 	var movement models.StockMovement
-	err := s.db.QueryRow(query, id).Scan(
+	err := s.db.QueryRowContext(ctx, query, id, organizationArg(ctx)).Scan(
 		&movement.ID,
 		&movement.ProductID,
 		&movement.Change,
@@ -380,10 +572,40 @@ func (s *ProductService) GetStockMovement(id uuid.UUID) (*models.StockMovement,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("stock movement not found")
+			return nil, fmt.Errorf("stock movement %w", ErrNotFound)
 		}
 		return nil, fmt.Errorf("failed to get stock movement: %w", err)
 	}
 
 	return &movement, nil
-}
\ No newline at end of file
+}
+// GetLowStockMovedSince returns products that are at or below their minimum
+// threshold and have had at least one stock movement since the given time.
+func (s *ProductService) GetLowStockMovedSince(ctx context.Context, since time.Time) ([]models.Product, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT p.id, p.name, p.sku, p.stock, p.price, p.category, p.minimum_threshold, p.supplier_info, p.created_at, p.updated_at, p.organization_id
+			  FROM products p
+			  WHERE p.minimum_threshold > 0 AND p.stock <= p.minimum_threshold
+			  AND EXISTS (SELECT 1 FROM stock_movements sm WHERE sm.product_id = p.id AND sm.created_at >= $1)
+			  ORDER BY p.stock, p.name`
+
+	rows, err := s.db.QueryContext(ctx, query, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get low stock products: %w", err)
+	}
+	defer rows.Close()
+
+	var products []models.Product
+	for rows.Next() {
+		var product models.Product
+		err := scanProduct(rows, &product)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan product: %w", err)
+		}
+		products = append(products, product)
+	}
+
+	return products, nil
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// GetProductsByID returns the products of the organization of ctx with the
+// given IDs, in the order of ids. IDs no product has are left out.
+func (s *ProductService) GetProductsByID(ctx context.Context, ids []uuid.UUID) ([]models.Product, error) {
+	if len(ids) == 0 {
+		return []models.Product{}, nil
+	}
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	values := make([]string, len(ids))
+	for i, id := range ids {
+		values[i] = id.String()
+	}
+
+	query := `SELECT ` + productColumns + `
+			  FROM products WHERE id = ANY($1::uuid[]) AND ($2::uuid IS NULL OR organization_id = $2)`
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(values), organizationArg(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get products: %w", err)
+	}
+	defer rows.Close()
+
+	found := make(map[uuid.UUID]models.Product, len(ids))
+	for rows.Next() {
+		var product models.Product
+		if err := scanProduct(rows, &product); err != nil {
+			return nil, fmt.Errorf("failed to scan product: %w", err)
+		}
+		found[product.ID] = product
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	products := make([]models.Product, 0, len(found))
+	for _, id := range ids {
+		if product, ok := found[id]; ok {
+			products = append(products, product)
+		}
+	}
+	return products, nil
+}
+
+// GetFavoriteProducts returns the products the user marked as favorites,
+// most recently marked first.
+func (s *ProductService) GetFavoriteProducts(ctx context.Context, userID uuid.UUID) ([]models.Product, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT p.id, p.name, p.sku, p.stock, p.price, p.category, p.minimum_threshold, p.supplier_info, p.created_at, p.updated_at, p.organization_id
+			  FROM product_favorites f JOIN products p ON p.id = f.product_id
+			  WHERE f.user_id = $1 AND ($2::uuid IS NULL OR p.organization_id = $2)
+			  ORDER BY f.created_at DESC, p.name`
+
+	rows, err := s.db.QueryContext(ctx, query, userID, organizationArg(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get favorite products: %w", err)
+	}
+	defer rows.Close()
+
+	products := []models.Product{}
+	for rows.Next() {
+		var product models.Product
+		if err := scanProduct(rows, &product); err != nil {
+			return nil, fmt.Errorf("failed to scan product: %w", err)
+		}
+		products = append(products, product)
+	}
+	return products, rows.Err()
+}
+
+// AddFavoriteProduct marks a product of the organization of ctx as a
+// favorite of the user. Marking it again changes nothing.
+func (s *ProductService) AddFavoriteProduct(ctx context.Context, userID, productID uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO product_favorites (user_id, product_id)
+		SELECT $1, id FROM products WHERE id = $2 AND ($3::uuid IS NULL OR organization_id = $3)
+		ON CONFLICT (user_id, product_id) DO NOTHING`, userID, productID, organizationArg(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to add favorite product: %w", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		// Either the product is missing or it already is a favorite
+		if _, err := s.GetProduct(ctx, productID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveFavoriteProduct unmarks a favorite of the user. Unmarking a
+// product that is not a favorite changes nothing.
+func (s *ProductService) RemoveFavoriteProduct(ctx context.Context, userID, productID uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `DELETE FROM product_favorites WHERE user_id = $1 AND product_id = $2`, userID, productID)
+	if err != nil {
+		return fmt.Errorf("failed to remove favorite product: %w", err)
+	}
+	return nil
+}
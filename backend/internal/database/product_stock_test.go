@@ -0,0 +1,77 @@
+package database
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestConcurrentDecrementsCannotOversell(t *testing.T) {
+	db := testDB(t)
+	products := NewProductService(db)
+
+	suffix := uuid.NewString()[:8]
+	user := &models.User{ID: uuid.New(), Name: "Till", Email: "till-" + suffix + "@example.com", Password: "x", Role: models.RoleStaff, IsActive: true, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := NewUserService(db).CreateUser(ctx, user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	product := &models.Product{ID: uuid.New(), Name: "Last units", SKU: "LAST-" + suffix, Stock: 5, Category: "Electronics", MinimumThreshold: 2}
+	if err := products.CreateProduct(ctx, product); err != nil {
+		t.Fatalf("Failed to create product: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM stock_movements WHERE product_id = $1`, product.ID)
+		db.Exec(`DELETE FROM products WHERE id = $1`, product.ID)
+		db.Exec(`DELETE FROM users WHERE id = $1`, user.ID)
+	})
+
+	// Twenty tills sell the last five units at once
+	const sales = 20
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	sold, refused, lowStock := 0, 0, 0
+	for i := 0; i < sales; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			update, err := products.UpdateProductStock(ctx, product.ID, -1, models.ReasonSale, user.ID, "")
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				sold++
+				if update.LowStock {
+					lowStock++
+				}
+			case errors.Is(err, ErrInsufficientStock):
+				refused++
+			default:
+				t.Errorf("UpdateProductStock: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if sold != 5 || refused != sales-5 {
+		t.Errorf("expected 5 sales and %d refusals, got %d and %d", sales-5, sold, refused)
+	}
+	// Each sale saw the stock it left, so the last three were low
+	if lowStock != 3 {
+		t.Errorf("expected 3 sales to leave the stock low, got %d", lowStock)
+	}
+	var stock, moved int
+	if err := db.QueryRow(`SELECT stock FROM products WHERE id = $1`, product.ID).Scan(&stock); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow(`SELECT COALESCE(SUM(change), 0) FROM stock_movements WHERE product_id = $1 AND reason = $2`, product.ID, models.ReasonSale).Scan(&moved); err != nil {
+		t.Fatal(err)
+	}
+	if stock != 0 || moved != -5 {
+		t.Errorf("expected the stock at 0 with -5 recorded, got %d and %d", stock, moved)
+	}
+}
@@ -0,0 +1,233 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"rtims-backend/internal/models"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+)
+
+// psql builds queries with Postgres' numbered placeholders. Use it for any
+// query whose conditions depend on the request, rather than formatting
+// placeholders by hand.
+var psql = sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+// ErrNotFound is wrapped by errors for a record that does not exist, e.g.
+// "product not found". Some older lookups return sql.ErrNoRows instead.
+var ErrNotFound = errors.New("not found")
+
+const productColumns = "id, name, sku, stock, price, category, minimum_threshold, supplier_info, created_at, updated_at, organization_id"
+
+const movementColumns = "id, product_id, change, reason, created_by, created_at, notes"
+
+// totalColumn returns, on every row of a page, how many rows match the
+// query's WHERE clause, so lists need no second COUNT query.
+const totalColumn = "COUNT(*) OVER()"
+
+// Columns callers may sort by; anything else falls back to created_at.
+var (
+	productSortColumns  = map[string]bool{"name": true, "sku": true, "stock": true, "price": true, "category": true, "created_at": true, "updated_at": true}
+	movementSortColumns = map[string]bool{"created_at": true, "change": true, "reason": true}
+)
+
+// Columns UpdateProduct and UpdateUser may change; other keys are ignored.
+var (
+	productUpdateColumns = map[string]bool{"name": true, "sku": true, "category": true, "supplier_info": true, "stock": true, "minimum_threshold": true, "price": true}
+	userUpdateColumns    = map[string]bool{"name": true, "email": true, "role": true, "is_active": true, "locale": true}
+)
+
+// orderBy returns the ORDER BY clause for a whitelisted column and
+// direction, defaulting to newest first.
+func orderBy(sortBy, sortOrder string, allowed map[string]bool) string {
+	if !allowed[sortBy] {
+		sortBy = "created_at"
+	}
+	if sortOrder != "ASC" {
+		sortOrder = "DESC"
+	}
+	return sortBy + " " + sortOrder
+}
+
+// where adds conditions to query, if there are any.
+func where(query sq.SelectBuilder, conditions sq.And) sq.SelectBuilder {
+	if len(conditions) == 0 {
+		return query
+	}
+	return query.Where(conditions)
+}
+
+// page applies LIMIT and OFFSET for a 1-based page number.
+func page(query sq.SelectBuilder, pageNumber, limit int) sq.SelectBuilder {
+	if limit <= 0 {
+		return query
+	}
+	if pageNumber < 1 {
+		pageNumber = 1
+	}
+	return query.Limit(uint64(limit)).Offset(uint64((pageNumber - 1) * limit))
+}
+
+// pastEndTotal returns the total for a page that came back empty. The
+// window total only comes with returned rows, so a page past the last one
+// falls back to the count query.
+func pastEndTotal(ctx context.Context, db *sql.DB, pageNumber int, countQuery string, args ...interface{}) (int, error) {
+	if pageNumber <= 1 {
+		return 0, nil
+	}
+	var total int
+	err := db.QueryRowContext(ctx, countQuery, args...).Scan(&total)
+	return total, err
+}
+
+// productConditions turns the set fields of filter into WHERE conditions.
+// Products whose deletion is pending are left out, as they are gone as far
+// as lists are concerned unless the deletion is undone.
+func productConditions(filter models.ProductFilter) sq.And {
+	conditions := sq.And{sq.Expr(notPendingDeletion(models.DeletionProducts))}
+	if filter.Search != "" {
+		pattern := "%" + filter.Search + "%"
+		conditions = append(conditions, sq.Or{
+			sq.ILike{"name": pattern},
+			sq.ILike{"sku": pattern},
+			sq.ILike{"category": pattern},
+		})
+	}
+	if filter.Category != "" {
+		conditions = append(conditions, sq.Eq{"category": filter.Category})
+	}
+	if filter.MinStock != nil {
+		conditions = append(conditions, sq.GtOrEq{"stock": *filter.MinStock})
+	}
+	if filter.MaxStock != nil {
+		conditions = append(conditions, sq.LtOrEq{"stock": *filter.MaxStock})
+	}
+	if filter.MinPrice != nil {
+		conditions = append(conditions, sq.GtOrEq{"price": *filter.MinPrice})
+	}
+	if filter.MaxPrice != nil {
+		conditions = append(conditions, sq.LtOrEq{"price": *filter.MaxPrice})
+	}
+	if filter.LowStockOnly {
+		conditions = append(conditions, sq.Expr("stock <= minimum_threshold"))
+	}
+	return conditions
+}
+
+// productQueries builds the page query for GetProducts, which carries the
+// total, and the count query for pages past the end.
+func productQueries(ctx context.Context, filter models.ProductFilter) (sq.SelectBuilder, sq.SelectBuilder) {
+	conditions := inOrganization(ctx, productConditions(filter), "organization_id")
+	query := where(psql.Select(productColumns, totalColumn).From("products"), conditions).
+		OrderBy(orderBy(filter.SortBy, filter.SortOrder, productSortColumns))
+	count := where(psql.Select("COUNT(*)").From("products"), conditions)
+	return page(query, filter.Page, filter.Limit), count
+}
+
+// productKeysetQuery builds the query for GetProductsAfter. Sorting and
+// page numbers in filter are ignored.
+func productKeysetQuery(ctx context.Context, filter models.ProductFilter, after *Cursor, limit int) sq.SelectBuilder {
+	query := where(psql.Select(productColumns).From("products"), inOrganization(ctx, productConditions(filter), "organization_id"))
+	return keyset(query, after, limit)
+}
+
+// movementConditions turns the set fields of filter into WHERE conditions.
+func movementConditions(filter models.StockMovementFilter) sq.And {
+	conditions := sq.And{}
+	if filter.ProductID != nil {
+		conditions = append(conditions, sq.Eq{"product_id": *filter.ProductID})
+	}
+	if filter.Reason != nil && *filter.Reason != "" {
+		conditions = append(conditions, sq.Eq{"reason": *filter.Reason})
+	}
+	if filter.StartDate != nil {
+		conditions = append(conditions, sq.GtOrEq{"created_at": *filter.StartDate})
+	}
+	if filter.EndDate != nil {
+		conditions = append(conditions, sq.LtOrEq{"created_at": *filter.EndDate})
+	}
+	return conditions
+}
+
+// movementQueries builds the page query for GetStockMovements, which
+// carries the total, and the count query for pages past the end.
+func movementQueries(ctx context.Context, filter models.StockMovementFilter) (sq.SelectBuilder, sq.SelectBuilder) {
+	conditions := inOrganization(ctx, movementConditions(filter), "organization_id")
+	query := where(psql.Select(movementColumns, totalColumn).From("stock_movements"), conditions).
+		OrderBy(orderBy(filter.SortBy, filter.SortOrder, movementSortColumns))
+	count := where(psql.Select("COUNT(*)").From("stock_movements"), conditions)
+	return page(query, filter.Page, filter.Limit), count
+}
+
+// movementKeysetQuery builds the query for GetStockMovementsAfter.
+// Sorting and page numbers in filter are ignored.
+func movementKeysetQuery(ctx context.Context, filter models.StockMovementFilter, after *Cursor, limit int) sq.SelectBuilder {
+	query := where(psql.Select(movementColumns).From("stock_movements"), inOrganization(ctx, movementConditions(filter), "organization_id"))
+	return keyset(query, after, limit)
+}
+
+// auditConditions turns the set fields of filter into WHERE conditions.
+// Only filters that are set go into the query, so date filters let Postgres
+// skip the monthly partitions outside the range.
+func auditConditions(filter models.AuditLogFilter) sq.And {
+	conditions := sq.And{}
+	if filter.TableName != nil && *filter.TableName != "" {
+		conditions = append(conditions, sq.Eq{"table_name": *filter.TableName})
+	}
+	if filter.Action != nil && *filter.Action != "" {
+		conditions = append(conditions, sq.Eq{"action": *filter.Action})
+	}
+	if filter.ChangedBy != nil {
+		conditions = append(conditions, sq.Eq{"changed_by": *filter.ChangedBy})
+	}
+	if filter.StartDate != nil {
+		conditions = append(conditions, sq.GtOrEq{"changed_at": *filter.StartDate})
+	}
+	if filter.EndDate != nil {
+		conditions = append(conditions, sq.LtOrEq{"changed_at": *filter.EndDate})
+	}
+	return conditions
+}
+
+// productUpdate builds the UPDATE for UpdateProduct.
+func productUpdate(ctx context.Context, id uuid.UUID, updates map[string]interface{}) (string, []interface{}, error) {
+	values := map[string]interface{}{}
+	for field, value := range updates {
+		if productUpdateColumns[field] {
+			values[field] = value
+		}
+	}
+	if len(values) == 0 {
+		return "", nil, ErrNoUpdates
+	}
+	values["updated_at"] = time.Now()
+
+	return psql.Update("products").SetMap(values).Where(idInOrganization(ctx, id)).ToSql()
+}
+
+// userUpdate builds the UPDATE for UpdateUser, or returns "" when updates
+// holds nothing a user may change.
+func userUpdate(ctx context.Context, id uuid.UUID, updates map[string]interface{}) (string, []interface{}, error) {
+	values := map[string]interface{}{}
+	for field, value := range updates {
+		if !userUpdateColumns[field] {
+			continue
+		}
+		if field == "locale" {
+			// An empty locale falls back to the organization default
+			value = sq.Expr("NULLIF(?, '')", value)
+		}
+		values[field] = value
+	}
+	if len(values) == 0 {
+		return "", nil, nil
+	}
+	values["updated_at"] = sq.Expr("NOW()")
+
+	// Anonymized users stay scrubbed
+	return psql.Update("users").SetMap(values).Where(idInOrganization(ctx, id)).Where("anonymized_at IS NULL").ToSql()
+}
@@ -0,0 +1,184 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// listed is the condition product lists start with, leaving out products
+// whose deletion is pending.
+var listed = notPendingDeletion(models.DeletionProducts)
+
+func TestProductQueries(t *testing.T) {
+	minStock, maxPrice := 5, 20.0
+	query, count := productQueries(context.Background(), models.ProductFilter{
+		Search:    "drill",
+		Category:  "Tools",
+		MinStock:  &minStock,
+		MaxPrice:  &maxPrice,
+		Page:      3,
+		Limit:     10,
+		SortBy:    "price; DROP TABLE products",
+		SortOrder: "ASC",
+	})
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "SELECT " + productColumns + ", COUNT(*) OVER() FROM products WHERE (" + listed + " AND (name ILIKE $1 OR sku ILIKE $2 OR category ILIKE $3) AND category = $4 AND stock >= $5 AND price <= $6) ORDER BY created_at ASC LIMIT 10 OFFSET 20"
+	if sql != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, sql)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"%drill%", "%drill%", "%drill%", "Tools", 5, 20.0}) {
+		t.Errorf("unexpected args %v", args)
+	}
+
+	sql, args, _ = count.ToSql()
+	if sql != "SELECT COUNT(*) FROM products WHERE ("+listed+" AND (name ILIKE $1 OR sku ILIKE $2 OR category ILIKE $3) AND category = $4 AND stock >= $5 AND price <= $6)" || len(args) != 6 {
+		t.Errorf("unexpected count query %s %v", sql, args)
+	}
+
+	lowStock, _ := productQueries(context.Background(), models.ProductFilter{LowStockOnly: true, SortBy: "stock"})
+	if sql, _, _ := lowStock.ToSql(); sql != "SELECT "+productColumns+", COUNT(*) OVER() FROM products WHERE ("+listed+" AND stock <= minimum_threshold) ORDER BY stock DESC" {
+		t.Errorf("unexpected low stock query %s", sql)
+	}
+}
+
+func TestMovementQueries(t *testing.T) {
+	productID := uuid.New()
+	reason := models.ReasonSale
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	query, _ := movementQueries(context.Background(), models.StockMovementFilter{ProductID: &productID, Reason: &reason, StartDate: &start, Page: 1, Limit: 50})
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "SELECT id, product_id, change, reason, created_by, created_at, notes, COUNT(*) OVER() FROM stock_movements WHERE (product_id = $1 AND reason = $2 AND created_at >= $3) ORDER BY created_at DESC LIMIT 50 OFFSET 0"
+	if sql != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, sql)
+	}
+	if len(args) != 3 || args[0] != productID.String() || args[1] != reason || args[2] != start {
+		t.Errorf("unexpected args %v", args)
+	}
+}
+
+func TestAuditConditions(t *testing.T) {
+	if conditions := auditConditions(models.AuditLogFilter{}); len(conditions) != 0 {
+		t.Errorf("expected no conditions for an empty filter, got %v", conditions)
+	}
+
+	tableName := "products"
+	empty := models.AuditAction("")
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	sql, args, err := auditConditions(models.AuditLogFilter{TableName: &tableName, Action: &empty, StartDate: &start}).ToSql()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sql != "(table_name = ? AND changed_at >= ?)" {
+		t.Errorf("unexpected conditions %s", sql)
+	}
+	if len(args) != 2 || args[0] != "products" || args[1] != start {
+		t.Errorf("unexpected args %v", args)
+	}
+}
+
+func TestUpdates(t *testing.T) {
+	id := uuid.New()
+
+	sql, args, err := productUpdate(context.Background(), id, map[string]interface{}{"price": 9.5, "name": "Drill", "id": uuid.New()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sql != "UPDATE products SET name = $1, price = $2, updated_at = $3 WHERE id = $4" || len(args) != 4 || args[3] != id.String() {
+		t.Errorf("unexpected product update %s %v", sql, args)
+	}
+	if _, _, err := productUpdate(context.Background(), id, map[string]interface{}{"created_at": time.Now()}); err == nil {
+		t.Error("expected an error when no column may be updated")
+	}
+
+	sql, args, _ = userUpdate(context.Background(), id, map[string]interface{}{"locale": "", "password": "secret"})
+	if sql != "UPDATE users SET locale = NULLIF($1, ''), updated_at = NOW() WHERE id = $2 AND anonymized_at IS NULL" || len(args) != 2 {
+		t.Errorf("unexpected user update %s %v", sql, args)
+	}
+	if sql, _, _ := userUpdate(context.Background(), id, map[string]interface{}{"password": "secret"}); sql != "" {
+		t.Errorf("expected no update, got %s", sql)
+	}
+}
+
+func TestOrganizationScope(t *testing.T) {
+	org := uuid.New()
+	ctx := WithOrganization(context.Background(), org)
+
+	query, count := productQueries(ctx, models.ProductFilter{Category: "Tools"})
+	sql, args, _ := query.ToSql()
+	if sql != "SELECT "+productColumns+", COUNT(*) OVER() FROM products WHERE ("+listed+" AND category = $1 AND organization_id = $2) ORDER BY created_at DESC" {
+		t.Errorf("unexpected scoped query %s", sql)
+	}
+	if len(args) != 2 || args[1] != org.String() {
+		t.Errorf("unexpected args %v", args)
+	}
+	if sql, _, _ := count.ToSql(); sql != "SELECT COUNT(*) FROM products WHERE ("+listed+" AND category = $1 AND organization_id = $2)" {
+		t.Errorf("unexpected scoped count query %s", sql)
+	}
+
+	sql, args, _ = productUpdate(ctx, org, map[string]interface{}{"name": "Drill"})
+	if sql != "UPDATE products SET name = $1, updated_at = $2 WHERE id = $3 AND organization_id = $4" || len(args) != 4 {
+		t.Errorf("unexpected scoped update %s %v", sql, args)
+	}
+
+	if id, ok := OrganizationFrom(context.Background()); ok || id != uuid.Nil {
+		t.Errorf("expected an unscoped context, got %v", id)
+	}
+}
+
+func TestKeysetQueries(t *testing.T) {
+	first, _, err := productKeysetQuery(context.Background(), models.ProductFilter{Category: "Tools", Page: 4, SortBy: "price"}, nil, 20).ToSql()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first != "SELECT "+productColumns+" FROM products WHERE ("+listed+" AND category = $1) ORDER BY created_at DESC, id DESC LIMIT 21" {
+		t.Errorf("unexpected first page query %s", first)
+	}
+
+	after := &Cursor{CreatedAt: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), ID: uuid.New()}
+	next, args, err := movementKeysetQuery(context.Background(), models.StockMovementFilter{}, after, 50).ToSql()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "SELECT id, product_id, change, reason, created_by, created_at, notes FROM stock_movements WHERE (created_at, id) < ($1, $2) ORDER BY created_at DESC, id DESC LIMIT 51"
+	if next != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, next)
+	}
+	if !reflect.DeepEqual(args, []interface{}{after.CreatedAt, after.ID}) {
+		t.Errorf("unexpected args %v", args)
+	}
+}
+
+func TestCursor(t *testing.T) {
+	cursor := Cursor{CreatedAt: time.Date(2024, 3, 1, 12, 0, 0, 123456000, time.UTC), ID: uuid.New()}
+	decoded, err := DecodeCursor(cursor.Encode())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !decoded.CreatedAt.Equal(cursor.CreatedAt) || decoded.ID != cursor.ID {
+		t.Errorf("expected %+v back, got %+v", cursor, decoded)
+	}
+
+	if decoded, err := DecodeCursor(""); decoded != nil || err != nil {
+		t.Errorf("expected no cursor for the first page, got %v %v", decoded, err)
+	}
+	for _, invalid := range []string{"not base64!", "bm90IGpzb24", "e30"} {
+		if _, err := DecodeCursor(invalid); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("DecodeCursor(%q): expected ErrInvalidCursor, got %v", invalid, err)
+		}
+	}
+}
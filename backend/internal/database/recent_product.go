@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+const (
+	recentProductsKey = "recent_products:"
+
+	// MaxRecentProducts is how many recently viewed products are kept per
+	// user.
+	MaxRecentProducts = 20
+	// recentProductsTTL drops the list of a user who has not viewed a
+	// product for that long.
+	recentProductsTTL = 30 * 24 * time.Hour
+)
+
+// RecentProducts keeps the products each user viewed last in a Redis
+// list, newest first. It is best effort: a nil *RecentProducts records
+// nothing, and while Redis is down views are not recorded and lists are
+// empty.
+type RecentProducts struct {
+	client *redis.Client
+}
+
+func NewRecentProducts(client *redis.Client) *RecentProducts {
+	if client == nil {
+		return nil
+	}
+	return &RecentProducts{client: client}
+}
+
+// Record moves productID to the front of the user's list.
+func (r *RecentProducts) Record(ctx context.Context, userID, productID uuid.UUID) {
+	if r == nil {
+		return
+	}
+	key := recentProductsKey + userID.String()
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LRem(ctx, key, 0, productID.String())
+		pipe.LPush(ctx, key, productID.String())
+		pipe.LTrim(ctx, key, 0, MaxRecentProducts-1)
+		pipe.Expire(ctx, key, recentProductsTTL)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to record product view of user %s: %v", userID, err)
+	}
+}
+
+// Get returns the IDs of the products the user viewed last, newest first.
+func (r *RecentProducts) Get(ctx context.Context, userID uuid.UUID) []uuid.UUID {
+	if r == nil {
+		return nil
+	}
+	values, err := r.client.LRange(ctx, recentProductsKey+userID.String(), 0, MaxRecentProducts-1).Result()
+	if err != nil {
+		log.Printf("Failed to get recent products of user %s: %v", userID, err)
+		return nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(values))
+	for _, value := range values {
+		if id, err := uuid.Parse(value); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Clear forgets the products the user viewed.
+func (r *RecentProducts) Clear(ctx context.Context, userID uuid.UUID) {
+	if r == nil {
+		return
+	}
+	if err := r.client.Del(ctx, recentProductsKey+userID.String()).Err(); err != nil {
+		log.Printf("Failed to clear recent products of user %s: %v", userID, err)
+	}
+}
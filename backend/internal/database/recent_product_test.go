@@ -0,0 +1,29 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+func TestRecentProductsWithoutRedis(t *testing.T) {
+	if recent := NewRecentProducts(nil); recent != nil {
+		t.Error("expected no recent products without a Redis client")
+	}
+
+	// A nil list records nothing, and an unreachable Redis is logged, so
+	// product lookups work without Redis
+	ctx := context.Background()
+	userID, productID := uuid.New(), uuid.New()
+	unreachable := NewRecentProducts(redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: 100 * time.Millisecond}))
+	for _, recent := range []*RecentProducts{nil, unreachable} {
+		recent.Record(ctx, userID, productID)
+		if ids := recent.Get(ctx, userID); len(ids) != 0 {
+			t.Errorf("expected no recent products, got %v", ids)
+		}
+		recent.Clear(ctx, userID)
+	}
+}
@@ -0,0 +1,48 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+)
+
+// readReplica, when set, serves heavy read-only queries.
+var readReplica *sql.DB
+
+// InitReadReplica opens a connection pool to a read replica. Unlike InitDB
+// it does not exit if the replica is unreachable: reads fall back to the
+// primary until it recovers.
+func InitReadReplica(replicaURL string, pool Pool) *sql.DB {
+	// The replica has its own breaker, so losing it does not take the
+	// primary out of service
+	replica, err := openPostgres(replicaURL, pool, replicaBreaker)
+	if err != nil {
+		log.Fatal("Failed to open read replica connection:", err)
+	}
+
+	if err := replica.Ping(); err != nil {
+		log.Printf("Warning: read replica unreachable, reading from the primary until it recovers: %v", err)
+	} else {
+		log.Println("Successfully connected to the read replica")
+	}
+	return replica
+}
+
+// UseReadReplica routes heavy read-only queries (report generation, audit
+// log listing and dashboard aggregates) to replica. Call it once at
+// startup.
+func UseReadReplica(replica *sql.DB) {
+	readReplica = replica
+}
+
+// ForReads returns the database a heavy read-only query should run on: the
+// read replica if one is configured and reachable, otherwise primary.
+// Writes, and reads that must see a write just made, always use primary.
+func ForReads(primary *sql.DB) *sql.DB {
+	if readReplica == nil {
+		return primary
+	}
+	if _, unavailable := replicaBreaker.openFor(); unavailable {
+		return primary
+	}
+	return readReplica
+}
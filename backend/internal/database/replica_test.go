@@ -0,0 +1,39 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestForReads(t *testing.T) {
+	defer UseReadReplica(nil)
+	defer ConfigureResilience(resilience)
+	ConfigureResilience(Resilience{Failures: 1, OpenFor: time.Minute})
+
+	primary := &sql.DB{}
+	if got := ForReads(primary); got != primary {
+		t.Error("expected reads to use the primary without a replica")
+	}
+
+	replica := sql.OpenDB(postgresConnector{Connector: &failingConnector{err: errors.New("dial tcp: connection refused")}, breaker: replicaBreaker})
+	defer replica.Close()
+	UseReadReplica(replica)
+	if got := ForReads(primary); got != replica {
+		t.Error("expected reads to use the replica")
+	}
+
+	// Once the replica's breaker opens, reads fall back to the primary
+	// without affecting the primary's breaker
+	if err := replica.PingContext(context.Background()); err == nil {
+		t.Fatal("expected the replica to be unreachable")
+	}
+	if got := ForReads(primary); got != primary {
+		t.Error("expected reads to fall back to the primary while the replica is down")
+	}
+	if _, unavailable := PostgresUnavailable(); unavailable {
+		t.Error("expected the primary to stay available")
+	}
+}
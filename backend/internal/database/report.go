@@ -0,0 +1,259 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const reportColumns = `id, type, format, status, parameters, COALESCE(storage_key, ''), COALESCE(filename, ''),
+	size, row_count, COALESCE(error, ''), requested_by, created_at, started_at, completed_at`
+
+type ReportService struct {
+	db *sql.DB
+}
+
+func NewReportService(db *sql.DB) *ReportService {
+	return &ReportService{db: db}
+}
+
+func scanReport(row interface{ Scan(...interface{}) error }, r *models.Report) error {
+	var parameters []byte
+	var requestedBy uuid.NullUUID
+	var startedAt, completedAt sql.NullTime
+
+	err := row.Scan(&r.ID, &r.Type, &r.Format, &r.Status, &parameters, &r.StorageKey, &r.Filename,
+		&r.Size, &r.RowCount, &r.Error, &requestedBy, &r.CreatedAt, &startedAt, &completedAt)
+	if err != nil {
+		return err
+	}
+
+	r.Parameters = parameters
+	if requestedBy.Valid {
+		r.RequestedBy = &requestedBy.UUID
+	}
+	if startedAt.Valid {
+		r.StartedAt = &startedAt.Time
+	}
+	if completedAt.Valid {
+		r.CompletedAt = &completedAt.Time
+	}
+	return nil
+}
+
+func (s *ReportService) CreateReport(report *models.Report) error {
+	query := `INSERT INTO reports (id, type, format, status, parameters, requested_by, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err := s.db.Exec(query,
+		report.ID,
+		report.Type,
+		report.Format,
+		report.Status,
+		[]byte(report.Parameters),
+		report.RequestedBy,
+		report.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create report: %w", err)
+	}
+
+	return nil
+}
+
+func (s *ReportService) GetReport(id uuid.UUID) (*models.Report, error) {
+	query := `SELECT ` + reportColumns + ` FROM reports WHERE id = $1`
+
+	var report models.Report
+	if err := scanReport(s.db.QueryRow(query, id), &report); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("report %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get report: %w", err)
+	}
+
+	return &report, nil
+}
+
+// GetRecentReports returns the most recently requested reports of the
+// organization of ctx; reports belong to the organization of their
+// requester.
+func (s *ReportService) GetRecentReports(ctx context.Context, limit int) ([]models.Report, error) {
+	query := `SELECT ` + reportColumns + ` FROM reports
+		WHERE ($2::uuid IS NULL OR requested_by IN (SELECT id FROM users WHERE organization_id = $2))
+		ORDER BY created_at DESC LIMIT $1`
+
+	rows, err := s.db.QueryContext(ctx, query, limit, organizationArg(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recent reports: %w", err)
+	}
+	defer rows.Close()
+
+	reports := []models.Report{}
+	for rows.Next() {
+		var report models.Report
+		if err := scanReport(rows, &report); err != nil {
+			return nil, fmt.Errorf("failed to scan report: %w", err)
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, nil
+}
+
+func (s *ReportService) MarkRunning(id uuid.UUID) error {
+	query := `UPDATE reports SET status = $1, started_at = NOW() WHERE id = $2`
+	_, err := s.db.Exec(query, models.ReportRunning, id)
+	return err
+}
+
+func (s *ReportService) MarkCompleted(id uuid.UUID, storageKey, filename string, size int64, rowCount int) error {
+	query := `UPDATE reports SET status = $1, storage_key = $2, filename = $3, size = $4, row_count = $5,
+			  completed_at = NOW() WHERE id = $6`
+	_, err := s.db.Exec(query, models.ReportCompleted, storageKey, filename, size, rowCount, id)
+	return err
+}
+
+func (s *ReportService) MarkFailed(id uuid.UUID, reason string) error {
+	query := `UPDATE reports SET status = $1, error = $2, completed_at = NOW() WHERE id = $3`
+	_, err := s.db.Exec(query, models.ReportFailed, reason, id)
+	return err
+}
+
+// FailInterrupted marks reports left queued or running by a previous process
+// as failed, since their work was lost when it exited.
+func (s *ReportService) FailInterrupted() (int64, error) {
+	query := `UPDATE reports SET status = $1, error = 'interrupted by server restart', completed_at = NOW()
+			  WHERE status IN ($2, $3)`
+	result, err := s.db.Exec(query, models.ReportFailed, models.ReportQueued, models.ReportRunning)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// DeleteReportsBefore removes the finished reports created before cutoff
+// and returns the storage keys of their artifacts.
+func (s *ReportService) DeleteReportsBefore(ctx context.Context, cutoff time.Time) ([]string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `DELETE FROM reports WHERE created_at < $1 AND status IN ($2, $3)
+		RETURNING COALESCE(storage_key, '')`, cutoff, models.ReportCompleted, models.ReportFailed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete reports: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to scan report: %w", err)
+		}
+		if key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys, rows.Err()
+}
+
+// GetReportsForUser returns the reports a user requested or that were shared
+// with them, most recent first.
+func (s *ReportService) GetReportsForUser(userID uuid.UUID, limit int) ([]models.Report, error) {
+	query := `SELECT ` + reportColumns + ` FROM reports
+			  WHERE requested_by = $1 OR id IN (SELECT report_id FROM report_shares WHERE user_id = $1)
+			  ORDER BY created_at DESC LIMIT $2`
+
+	rows, err := s.db.Query(query, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reports: %w", err)
+	}
+	defer rows.Close()
+
+	reports := []models.Report{}
+	for rows.Next() {
+		var report models.Report
+		if err := scanReport(rows, &report); err != nil {
+			return nil, fmt.Errorf("failed to scan report: %w", err)
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, nil
+}
+
+// ShareReport grants each user access to a report. Users it is already
+// shared with are left unchanged.
+func (s *ReportService) ShareReport(reportID uuid.UUID, userIDs []uuid.UUID, sharedBy uuid.UUID) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, userID := range userIDs {
+		query := `INSERT INTO report_shares (report_id, user_id, shared_by, created_at)
+				  VALUES ($1, $2, $3, NOW())
+				  ON CONFLICT (report_id, user_id) DO NOTHING`
+		if _, err := tx.Exec(query, reportID, userID, sharedBy); err != nil {
+			return fmt.Errorf("failed to share report: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UnshareReport revokes a user's access to a report.
+func (s *ReportService) UnshareReport(reportID, userID uuid.UUID) error {
+	query := `DELETE FROM report_shares WHERE report_id = $1 AND user_id = $2`
+	result, err := s.db.Exec(query, reportID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to unshare report: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("report share %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// GetReportShares returns the IDs of the users a report is shared with.
+func (s *ReportService) GetReportShares(reportID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := s.db.Query(`SELECT user_id FROM report_shares WHERE report_id = $1 ORDER BY created_at`, reportID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get report shares: %w", err)
+	}
+	defer rows.Close()
+
+	userIDs := []uuid.UUID{}
+	for rows.Next() {
+		var userID uuid.UUID
+		if err := rows.Scan(&userID); err != nil {
+			return nil, fmt.Errorf("failed to scan report share: %w", err)
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, rows.Err()
+}
+
+// IsSharedWith reports whether a report has been shared with a user.
+func (s *ReportService) IsSharedWith(reportID, userID uuid.UUID) (bool, error) {
+	var shared bool
+	query := `SELECT EXISTS (SELECT 1 FROM report_shares WHERE report_id = $1 AND user_id = $2)`
+	if err := s.db.QueryRow(query, reportID, userID).Scan(&shared); err != nil {
+		return false, fmt.Errorf("failed to check report share: %w", err)
+	}
+	return shared, nil
+}
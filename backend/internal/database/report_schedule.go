@@ -0,0 +1,206 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const reportScheduleColumns = `id, name, parameters, cron_expression, recipients, is_active, next_run_at, last_run_at,
+	last_report_id, created_by, created_at, updated_at`
+
+type ReportScheduleService struct {
+	db *sql.DB
+}
+
+func NewReportScheduleService(db *sql.DB) *ReportScheduleService {
+	return &ReportScheduleService{db: db}
+}
+
+func scanReportSchedule(row interface{ Scan(...interface{}) error }, s *models.ReportSchedule) error {
+	var parameters, recipients []byte
+	var nextRunAt, lastRunAt sql.NullTime
+	var lastReportID, createdBy uuid.NullUUID
+
+	err := row.Scan(&s.ID, &s.Name, &parameters, &s.CronExpression, &recipients, &s.IsActive, &nextRunAt, &lastRunAt,
+		&lastReportID, &createdBy, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	s.Report = parameters
+	if err := json.Unmarshal(recipients, &s.Recipients); err != nil {
+		return fmt.Errorf("invalid recipients for schedule %s: %w", s.ID, err)
+	}
+	if nextRunAt.Valid {
+		s.NextRunAt = &nextRunAt.Time
+	}
+	if lastRunAt.Valid {
+		s.LastRunAt = &lastRunAt.Time
+	}
+	if lastReportID.Valid {
+		s.LastReportID = &lastReportID.UUID
+	}
+	if createdBy.Valid {
+		s.CreatedBy = &createdBy.UUID
+	}
+	return nil
+}
+
+func (s *ReportScheduleService) querySchedules(ctx context.Context, query string, args ...interface{}) ([]models.ReportSchedule, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get report schedules: %w", err)
+	}
+	defer rows.Close()
+
+	schedules := []models.ReportSchedule{}
+	for rows.Next() {
+		var schedule models.ReportSchedule
+		if err := scanReportSchedule(rows, &schedule); err != nil {
+			return nil, fmt.Errorf("failed to scan report schedule: %w", err)
+		}
+		schedules = append(schedules, schedule)
+	}
+
+	return schedules, nil
+}
+
+// GetSchedules returns the schedules of the organization of ctx, which
+// schedules share with their creator.
+func (s *ReportScheduleService) GetSchedules(ctx context.Context) ([]models.ReportSchedule, error) {
+	return s.querySchedules(ctx, `SELECT `+reportScheduleColumns+` FROM report_schedules
+		WHERE ($1::uuid IS NULL OR created_by IN (SELECT id FROM users WHERE organization_id = $1))
+		ORDER BY name`, organizationArg(ctx))
+}
+
+// GetDueSchedules returns active schedules whose next run is at or before now.
+func (s *ReportScheduleService) GetDueSchedules(now time.Time) ([]models.ReportSchedule, error) {
+	return s.querySchedules(context.Background(), `SELECT `+reportScheduleColumns+` FROM report_schedules
+		WHERE is_active = true AND next_run_at <= $1 ORDER BY next_run_at`, now)
+}
+
+func (s *ReportScheduleService) GetSchedule(ctx context.Context, id uuid.UUID) (*models.ReportSchedule, error) {
+	query := `SELECT ` + reportScheduleColumns + ` FROM report_schedules WHERE id = $1
+		AND ($2::uuid IS NULL OR created_by IN (SELECT id FROM users WHERE organization_id = $2))`
+
+	var schedule models.ReportSchedule
+	if err := scanReportSchedule(s.db.QueryRowContext(ctx, query, id, organizationArg(ctx)), &schedule); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("report schedule %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get report schedule: %w", err)
+	}
+
+	return &schedule, nil
+}
+
+func (s *ReportScheduleService) CreateSchedule(schedule *models.ReportSchedule) error {
+	recipients, err := json.Marshal(schedule.Recipients)
+	if err != nil {
+		return fmt.Errorf("failed to encode recipients: %w", err)
+	}
+
+	query := `INSERT INTO report_schedules (id, name, parameters, cron_expression, recipients, is_active, next_run_at,
+			  created_by, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+
+	_, err = s.db.Exec(query,
+		schedule.ID,
+		schedule.Name,
+		[]byte(schedule.Report),
+		schedule.CronExpression,
+		recipients,
+		schedule.IsActive,
+		schedule.NextRunAt,
+		schedule.CreatedBy,
+		schedule.CreatedAt,
+		schedule.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create report schedule: %w", err)
+	}
+
+	return nil
+}
+
+func (s *ReportScheduleService) UpdateSchedule(schedule *models.ReportSchedule) error {
+	recipients, err := json.Marshal(schedule.Recipients)
+	if err != nil {
+		return fmt.Errorf("failed to encode recipients: %w", err)
+	}
+
+	query := `UPDATE report_schedules SET name = $1, parameters = $2, cron_expression = $3, recipients = $4,
+			  is_active = $5, next_run_at = $6, updated_at = NOW()
+			  WHERE id = $7`
+
+	result, err := s.db.Exec(query,
+		schedule.Name,
+		[]byte(schedule.Report),
+		schedule.CronExpression,
+		recipients,
+		schedule.IsActive,
+		schedule.NextRunAt,
+		schedule.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update report schedule: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("report schedule %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+func (s *ReportScheduleService) DeleteSchedule(id uuid.UUID) error {
+	result, err := s.db.Exec("DELETE FROM report_schedules WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete report schedule: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("report schedule %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// ClaimRun moves a due schedule on to its next run time. It only succeeds if
+// next_run_at still equals dueAt, so when several instances see the same due
+// schedule exactly one of them runs it.
+func (s *ReportScheduleService) ClaimRun(id uuid.UUID, dueAt time.Time, nextRunAt *time.Time) (bool, error) {
+	query := `UPDATE report_schedules SET next_run_at = $1, last_run_at = NOW()
+			  WHERE id = $2 AND next_run_at = $3`
+
+	result, err := s.db.Exec(query, nextRunAt, id, dueAt)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+func (s *ReportScheduleService) SetLastReport(id, reportID uuid.UUID) error {
+	_, err := s.db.Exec("UPDATE report_schedules SET last_report_id = $1 WHERE id = $2", reportID, id)
+	return err
+}
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"rtims-backend/internal/models"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// ProductSearcher answers product searches from a search index instead of
+// Postgres.
+type ProductSearcher interface {
+	SearchProducts(ctx context.Context, filter models.ProductFilter) ([]models.Product, int, error)
+}
+
+// productSearcher, when set, answers product searches, and every product,
+// stock movement and audit entry written queues an EventSearchIndex event.
+var productSearcher ProductSearcher
+
+// UseSearch sends GetProducts searches to searcher and has the outbox
+// relay keep its index up to date. Call it once at startup.
+func UseSearch(searcher ProductSearcher) {
+	productSearcher = searcher
+}
+
+// searchIndexInsert returns an INSERT that queues an EventSearchIndex
+// event for each row of index with an id column in the FROM clause that
+// follows it.
+func searchIndexInsert(index models.SearchIndex) string {
+	return `INSERT INTO outbox_events (id, type, payload, created_at)
+		SELECT uuid_generate_v4(), '` + string(models.EventSearchIndex) + `', jsonb_build_object('index', ` +
+		pq.QuoteLiteral(string(index)) + `, 'id', id), NOW() FROM `
+}
+
+// queueSearchIndex queues an EventSearchIndex event in tx for each row of
+// index, when search is in use.
+func queueSearchIndex(ctx context.Context, tx *sql.Tx, index models.SearchIndex, ids ...uuid.UUID) error {
+	if productSearcher == nil {
+		return nil
+	}
+	for _, id := range ids {
+		if err := enqueueEvent(ctx, tx, models.EventSearchIndex, models.SearchIndexEvent{Index: index, ID: id}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SearchDocument is a row as it is indexed for search: a
+// models.SearchProduct, models.SearchMovement or models.SearchAuditLog.
+type SearchDocument struct {
+	ID   uuid.UUID
+	Body interface{}
+}
+
+// searchSource reads the documents of one index.
+type searchSource struct {
+	query    sq.SelectBuilder
+	idColumn string
+	scan     func(rows *sql.Rows) (SearchDocument, error)
+}
+
+var searchSources = map[models.SearchIndex]searchSource{
+	models.SearchProducts: {
+		query:    psql.Select(productColumns).From("products"),
+		idColumn: "id",
+		scan: func(rows *sql.Rows) (SearchDocument, error) {
+			var p models.SearchProduct
+			if err := scanProduct(rows, &p.Product); err != nil {
+				return SearchDocument{}, err
+			}
+			p.LowStock = p.Stock <= p.MinimumThreshold
+			return SearchDocument{ID: p.ID, Body: p}, nil
+		},
+	},
+	models.SearchStockMovements: {
+		query: psql.Select("m.id, m.product_id, m.change, m.reason, m.created_by, m.created_at, COALESCE(m.notes, ''), " +
+			"m.organization_id, p.name, p.sku, p.category").
+			From("stock_movements m").Join("products p ON p.id = m.product_id"),
+		idColumn: "m.id",
+		scan: func(rows *sql.Rows) (SearchDocument, error) {
+			var m models.SearchMovement
+			err := rows.Scan(&m.ID, &m.ProductID, &m.Change, &m.Reason, &m.CreatedBy, &m.CreatedAt, &m.Notes,
+				&m.OrganizationID, &m.ProductName, &m.ProductSKU, &m.Category)
+			return SearchDocument{ID: m.ID, Body: m}, err
+		},
+	},
+	models.SearchAuditLogs: {
+		query:    psql.Select(auditLogColumns, "organization_id").From("audit_logs"),
+		idColumn: "id",
+		scan: func(rows *sql.Rows) (SearchDocument, error) {
+			var a models.SearchAuditLog
+			err := scanAuditLog(rows, &a.AuditLog, &a.OrganizationID)
+			return SearchDocument{ID: a.ID, Body: a}, err
+		},
+	},
+}
+
+// SearchService reads the rows mirrored into the search index.
+type SearchService struct {
+	db *sql.DB
+}
+
+func NewSearchService(db *sql.DB) *SearchService {
+	return &SearchService{db: db}
+}
+
+// GetSearchDocuments returns the documents of the rows of index with the
+// given IDs. Rows that no longer exist are left out.
+func (s *SearchService) GetSearchDocuments(ctx context.Context, index models.SearchIndex, ids []uuid.UUID) ([]SearchDocument, error) {
+	source, ok := searchSources[index]
+	if !ok {
+		return nil, fmt.Errorf("unknown search index %q", index)
+	}
+	return s.documents(ctx, index, source.query.Where(source.idColumn+" = ANY(?::uuid[])", pq.Array(uuidStrings(ids))))
+}
+
+// GetSearchDocumentsAfter returns up to limit documents of index in order
+// of ID, starting after the given one, to reindex a table a page at a time.
+func (s *SearchService) GetSearchDocumentsAfter(ctx context.Context, index models.SearchIndex, after uuid.UUID, limit int) ([]SearchDocument, error) {
+	source, ok := searchSources[index]
+	if !ok {
+		return nil, fmt.Errorf("unknown search index %q", index)
+	}
+	query := source.query.Where(sq.Gt{source.idColumn: after}).OrderBy(source.idColumn).Limit(uint64(limit))
+	return s.documents(ctx, index, query)
+}
+
+func (s *SearchService) documents(ctx context.Context, index models.SearchIndex, query sq.SelectBuilder) ([]SearchDocument, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build %s search query: %w", index, err)
+	}
+	rows, err := s.db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s to index: %w", index, err)
+	}
+	defer rows.Close()
+
+	var documents []SearchDocument
+	for rows.Next() {
+		document, err := searchSources[index].scan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan %s to index: %w", index, err)
+		}
+		documents = append(documents, document)
+	}
+	return documents, rows.Err()
+}
@@ -0,0 +1,184 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// settingsRevisionColumns are qualified, as revisions are read joined to
+// the user who made them.
+const settingsRevisionColumns = `r.id, r.version, r.changes, r.changed_by, COALESCE(u.name, ''), COALESCE(u.email, ''),
+	r.rollback_of, r.created_at`
+
+func scanSettingsRevision(row interface{ Scan(...interface{}) error }, r *models.SettingsRevision) error {
+	var changes []byte
+	var changedBy, rollbackOf uuid.NullUUID
+
+	err := row.Scan(&r.ID, &r.Version, &changes, &changedBy, &r.ChangedByName, &r.ChangedByEmail,
+		&rollbackOf, &r.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(changes, &r.Changes); err != nil {
+		return fmt.Errorf("failed to decode settings changes: %w", err)
+	}
+	if changedBy.Valid {
+		r.ChangedBy = &changedBy.UUID
+	}
+	if rollbackOf.Valid {
+		r.RollbackOf = &rollbackOf.UUID
+	}
+	return nil
+}
+
+// settingsChanges returns the updates that change a current value.
+func settingsChanges(current, updates map[string]string) map[string]models.SettingChange {
+	changes := map[string]models.SettingChange{}
+	for key, value := range updates {
+		old, exists := current[key]
+		if exists && old == value {
+			continue
+		}
+		change := models.SettingChange{New: value}
+		if exists {
+			change.Old = &old
+		}
+		changes[key] = change
+	}
+	return changes
+}
+
+// ApplySettings stores updates, which must already be valid, and records
+// the change as a new revision. Keys whose value stays the same are left
+// out of it; when nothing changes, no revision is recorded and nil is
+// returned. rollbackOf is the revision the change undoes, if any.
+func (s *SettingsService) ApplySettings(ctx context.Context, updates map[string]string, changedBy uuid.UUID, rollbackOf *uuid.UUID) (*models.SettingsRevision, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Changes are applied one at a time, so each revision holds the values
+	// it replaced and versions have no gaps
+	if _, err := tx.ExecContext(ctx, "LOCK TABLE settings_revisions IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		return nil, fmt.Errorf("failed to lock settings revisions: %w", err)
+	}
+
+	keys := make([]string, 0, len(updates))
+	for key := range updates {
+		keys = append(keys, key)
+	}
+
+	current := map[string]string{}
+	rows, err := tx.QueryContext(ctx, "SELECT key, COALESCE(value, '') FROM system_settings WHERE key = ANY($1)", pq.Array(keys))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get settings: %w", err)
+	}
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan setting: %w", err)
+		}
+		current[key] = value
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get settings: %w", err)
+	}
+
+	changes := settingsChanges(current, updates)
+	for key, change := range changes {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO system_settings (key, value, updated_at)
+			VALUES ($1, $2, NOW())
+			ON CONFLICT (key) DO UPDATE SET
+				value = EXCLUDED.value,
+				updated_at = NOW()`, key, change.New)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update setting %s: %w", key, err)
+		}
+	}
+	if len(changes) == 0 {
+		return nil, tx.Commit()
+	}
+
+	encoded, err := json.Marshal(changes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode settings changes: %w", err)
+	}
+	revision := &models.SettingsRevision{ID: uuid.New(), Changes: changes, ChangedBy: &changedBy, RollbackOf: rollbackOf}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO settings_revisions (id, version, changes, changed_by, rollback_of)
+		SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3, $4 FROM settings_revisions
+		RETURNING version, created_at`,
+		revision.ID, string(encoded), changedBy, rollbackOf).Scan(&revision.Version, &revision.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record settings revision: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return revision, nil
+}
+
+// GetSettingsRevisions returns the most recent settings revisions, newest
+// first.
+func (s *SettingsService) GetSettingsRevisions(ctx context.Context, limit int) ([]models.SettingsRevision, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + settingsRevisionColumns + `
+			  FROM settings_revisions r LEFT JOIN users u ON u.id = r.changed_by
+			  ORDER BY r.version DESC
+			  LIMIT $1`
+
+	rows, err := s.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get settings revisions: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := []models.SettingsRevision{}
+	for rows.Next() {
+		var revision models.SettingsRevision
+		if err := scanSettingsRevision(rows, &revision); err != nil {
+			return nil, fmt.Errorf("failed to scan settings revision: %w", err)
+		}
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, rows.Err()
+}
+
+func (s *SettingsService) GetSettingsRevision(ctx context.Context, id uuid.UUID) (*models.SettingsRevision, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + settingsRevisionColumns + `
+			  FROM settings_revisions r LEFT JOIN users u ON u.id = r.changed_by
+			  WHERE r.id = $1`
+
+	var revision models.SettingsRevision
+	if err := scanSettingsRevision(s.db.QueryRowContext(ctx, query, id), &revision); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("settings revision %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get settings revision: %w", err)
+	}
+
+	return &revision, nil
+}
@@ -0,0 +1,22 @@
+package database
+
+import (
+	"testing"
+)
+
+func TestSettingsChanges(t *testing.T) {
+	changes := settingsChanges(
+		map[string]string{"low_stock_threshold": "10", "auto_backup": "true"},
+		map[string]string{"low_stock_threshold": "0", "auto_backup": "true", "backup_frequency": "weekly"},
+	)
+	if len(changes) != 2 {
+		t.Fatalf("expected the unchanged setting to be left out, got %v", changes)
+	}
+	threshold := changes["low_stock_threshold"]
+	if threshold.Old == nil || *threshold.Old != "10" || threshold.New != "0" {
+		t.Errorf("unexpected threshold change %+v", threshold)
+	}
+	if frequency := changes["backup_frequency"]; frequency.Old != nil || frequency.New != "weekly" {
+		t.Errorf("expected a setting set for the first time to have no old value, got %+v", frequency)
+	}
+}
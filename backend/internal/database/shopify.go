@@ -0,0 +1,337 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// shopifyPushLease is how long a claimed stock push is hidden from other
+// workers while it is sent.
+const shopifyPushLease = time.Minute
+
+// queueShopifyPushes is the upsert that queues the current stock of the
+// mapped products selected by where. A push already waiting for the same
+// inventory item is replaced, and its retries start over.
+const queueShopifyPushes = `
+	INSERT INTO shopify_stock_pushes (shop, inventory_item_id, product_id, available, next_attempt_at, updated_at)
+	SELECT v.shop, v.inventory_item_id, v.product_id, p.stock, NOW(), NOW()
+	FROM shopify_variants v JOIN products p ON p.id = v.product_id
+	WHERE %s
+	ON CONFLICT (shop, inventory_item_id) DO UPDATE SET
+		product_id = EXCLUDED.product_id,
+		available = EXCLUDED.available,
+		attempts = 0,
+		next_attempt_at = NOW(),
+		error = NULL,
+		updated_at = NOW()`
+
+// ShopifyService stores the mappings, stock pushes and ingested orders of
+// the Shopify integration. The stores themselves are configured in
+// settings.
+type ShopifyService struct {
+	db *sql.DB
+}
+
+func NewShopifyService(db *sql.DB) *ShopifyService {
+	return &ShopifyService{db: db}
+}
+
+// GetShopifyVariants returns the variants of shop mapped to products of the
+// organization of ctx.
+func (s *ShopifyService) GetShopifyVariants(ctx context.Context, shop string) ([]models.ShopifyVariant, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT v.shop, v.variant_id, v.inventory_item_id, v.product_id, p.name, v.sku, v.created_at
+		FROM shopify_variants v JOIN products p ON p.id = v.product_id
+		WHERE v.shop = $1 AND ($2::uuid IS NULL OR p.organization_id = $2)
+		ORDER BY p.name, v.variant_id`, shop, organizationArg(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Shopify variants: %w", err)
+	}
+	defer rows.Close()
+
+	variants := []models.ShopifyVariant{}
+	for rows.Next() {
+		var v models.ShopifyVariant
+		if err := rows.Scan(&v.Shop, &v.VariantID, &v.InventoryItemID, &v.ProductID, &v.ProductName, &v.SKU, &v.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan Shopify variant: %w", err)
+		}
+		variants = append(variants, v)
+	}
+	return variants, rows.Err()
+}
+
+// GetShopifyVariantProduct returns the product a variant of shop is mapped
+// to.
+func (s *ShopifyService) GetShopifyVariantProduct(ctx context.Context, shop string, variantID int64) (uuid.UUID, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var productID uuid.UUID
+	err := s.db.QueryRowContext(ctx, `SELECT product_id FROM shopify_variants WHERE shop = $1 AND variant_id = $2`,
+		shop, variantID).Scan(&productID)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, fmt.Errorf("Shopify variant %w", ErrNotFound)
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to get Shopify variant: %w", err)
+	}
+	return productID, nil
+}
+
+// SaveShopifyVariants maps variants to their products, replacing the
+// mappings the variants already had.
+func (s *ShopifyService) SaveShopifyVariants(ctx context.Context, variants []models.ShopifyVariant) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, v := range variants {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO shopify_variants (shop, variant_id, inventory_item_id, product_id, sku)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (shop, variant_id) DO UPDATE SET
+				inventory_item_id = EXCLUDED.inventory_item_id,
+				product_id = EXCLUDED.product_id,
+				sku = EXCLUDED.sku`,
+			v.Shop, v.VariantID, v.InventoryItemID, v.ProductID, v.SKU)
+		if err != nil {
+			return fmt.Errorf("failed to save Shopify variant %d: %w", v.VariantID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteShopifyVariant unmaps a variant of shop from its product in the
+// organization of ctx.
+func (s *ShopifyService) DeleteShopifyVariant(ctx context.Context, shop string, variantID int64) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM shopify_variants v USING products p
+		WHERE p.id = v.product_id AND v.shop = $1 AND v.variant_id = $2 AND ($3::uuid IS NULL OR p.organization_id = $3)`,
+		shop, variantID, organizationArg(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to delete Shopify variant: %w", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		return fmt.Errorf("Shopify variant %w", ErrNotFound)
+	}
+	return nil
+}
+
+// QueueShopifyStockPushes queues the current stock of a product for every
+// variant it is mapped to. Reading the stock when queueing, rather than
+// taking it from the event, means a late or repeated event never pushes an
+// old level.
+func (s *ShopifyService) QueueShopifyStockPushes(ctx context.Context, productID uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(queueShopifyPushes, "v.product_id = $1"), productID); err != nil {
+		return fmt.Errorf("failed to queue Shopify stock pushes: %w", err)
+	}
+	return nil
+}
+
+// QueueShopifyStorePushes queues the current stock of every product mapped
+// to a variant of shop, as after its variants are mapped.
+func (s *ShopifyService) QueueShopifyStorePushes(ctx context.Context, shop string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(queueShopifyPushes, "v.shop = $1"), shop); err != nil {
+		return fmt.Errorf("failed to queue Shopify stock pushes: %w", err)
+	}
+	return nil
+}
+
+// ClaimShopifyStockPushes leases up to limit pushes that are due, oldest
+// first, so other workers skip them while they are sent.
+func (s *ShopifyService) ClaimShopifyStockPushes(ctx context.Context, limit int) ([]models.ShopifyStockPush, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		UPDATE shopify_stock_pushes SET next_attempt_at = $2
+		WHERE (shop, inventory_item_id) IN (
+			SELECT shop, inventory_item_id FROM shopify_stock_pushes
+			WHERE next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING shop, inventory_item_id, product_id, available, attempts, updated_at`,
+		limit, time.Now().Add(shopifyPushLease))
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim Shopify stock pushes: %w", err)
+	}
+	defer rows.Close()
+
+	var pushes []models.ShopifyStockPush
+	for rows.Next() {
+		var p models.ShopifyStockPush
+		if err := rows.Scan(&p.Shop, &p.InventoryItemID, &p.ProductID, &p.Available, &p.Attempts, &p.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan Shopify stock push: %w", err)
+		}
+		pushes = append(pushes, p)
+	}
+	return pushes, rows.Err()
+}
+
+// RecordShopifyStockPush stores the outcome of sending a claimed push. A
+// push that was sent is removed; one that failed keeps its error and is
+// retried at nextAttemptAt, or not at all if that is nil. A push queued
+// again while it was being sent is left for the next claim either way.
+func (s *ShopifyService) RecordShopifyStockPush(ctx context.Context, push models.ShopifyStockPush, pushErr string, nextAttemptAt *time.Time) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var err error
+	if pushErr == "" {
+		_, err = s.db.ExecContext(ctx, `DELETE FROM shopify_stock_pushes
+			WHERE shop = $1 AND inventory_item_id = $2 AND updated_at = $3`,
+			push.Shop, push.InventoryItemID, push.UpdatedAt)
+	} else {
+		_, err = s.db.ExecContext(ctx, `UPDATE shopify_stock_pushes SET
+				attempts = attempts + 1,
+				error = $4,
+				next_attempt_at = $5
+			WHERE shop = $1 AND inventory_item_id = $2 AND updated_at = $3`,
+			push.Shop, push.InventoryItemID, push.UpdatedAt, pushErr, nextAttemptAt)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record Shopify stock push: %w", err)
+	}
+	return nil
+}
+
+// ClaimShopifyOrderLine records a line item of an order before it is
+// ingested, and reports false if it already was, so a repeated webhook
+// does not move stock twice.
+func (s *ShopifyService) ClaimShopifyOrderLine(ctx context.Context, line *models.ShopifyOrderLine) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO shopify_order_lines (shop, order_id, line_item_id, order_name, variant_id, product_id, quantity, error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''))
+		ON CONFLICT DO NOTHING`,
+		line.Shop, line.OrderID, line.LineItemID, line.OrderName, line.VariantID, line.ProductID, line.Quantity, line.Error)
+	if err != nil {
+		return false, fmt.Errorf("failed to record Shopify order line: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+// FinishShopifyOrderLine stores the movement a claimed line item was
+// recorded as, or why it was not.
+func (s *ShopifyService) FinishShopifyOrderLine(ctx context.Context, line *models.ShopifyOrderLine) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `UPDATE shopify_order_lines SET movement_id = $4, error = NULLIF($5, '')
+		WHERE shop = $1 AND order_id = $2 AND line_item_id = $3`,
+		line.Shop, line.OrderID, line.LineItemID, line.MovementID, line.Error)
+	if err != nil {
+		return fmt.Errorf("failed to record Shopify order line: %w", err)
+	}
+	return nil
+}
+
+// ReleaseShopifyOrderLine forgets a claimed line item that could not be
+// ingested, so it is ingested when Shopify sends the order again.
+func (s *ShopifyService) ReleaseShopifyOrderLine(ctx context.Context, line *models.ShopifyOrderLine) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `DELETE FROM shopify_order_lines WHERE shop = $1 AND order_id = $2 AND line_item_id = $3`,
+		line.Shop, line.OrderID, line.LineItemID)
+	if err != nil {
+		return fmt.Errorf("failed to release Shopify order line: %w", err)
+	}
+	return nil
+}
+
+// GetShopifyOrderLines returns the most recently ingested line items of
+// shop, newest first.
+func (s *ShopifyService) GetShopifyOrderLines(ctx context.Context, shop string, limit int) ([]models.ShopifyOrderLine, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT shop, order_id, line_item_id, order_name, variant_id, product_id, quantity, movement_id, COALESCE(error, ''), created_at
+		FROM shopify_order_lines
+		WHERE shop = $1
+		ORDER BY created_at DESC, order_id DESC, line_item_id
+		LIMIT $2`, shop, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Shopify order lines: %w", err)
+	}
+	defer rows.Close()
+
+	lines := []models.ShopifyOrderLine{}
+	for rows.Next() {
+		var line models.ShopifyOrderLine
+		var variantID sql.NullInt64
+		var productID, movementID uuid.NullUUID
+		err := rows.Scan(&line.Shop, &line.OrderID, &line.LineItemID, &line.OrderName, &variantID, &productID,
+			&line.Quantity, &movementID, &line.Error, &line.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan Shopify order line: %w", err)
+		}
+		if variantID.Valid {
+			line.VariantID = &variantID.Int64
+		}
+		if productID.Valid {
+			line.ProductID = &productID.UUID
+		}
+		if movementID.Valid {
+			line.MovementID = &movementID.UUID
+		}
+		lines = append(lines, line)
+	}
+	return lines, rows.Err()
+}
+
+// GetShopifyStoreStatus fills in the sync state of store: how many variants
+// are mapped, how many pushes are waiting or have failed, and when an order
+// was last ingested.
+func (s *ShopifyService) GetShopifyStoreStatus(ctx context.Context, store *models.ShopifyStore) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var lastOrderAt sql.NullTime
+	err := s.db.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM shopify_variants WHERE shop = $1),
+			(SELECT COUNT(*) FROM shopify_stock_pushes WHERE shop = $1 AND next_attempt_at IS NOT NULL),
+			(SELECT COUNT(*) FROM shopify_stock_pushes WHERE shop = $1 AND next_attempt_at IS NULL),
+			(SELECT MAX(created_at) FROM shopify_order_lines WHERE shop = $1)`,
+		store.Shop).Scan(&store.Variants, &store.PendingPushes, &store.FailedPushes, &lastOrderAt)
+	if err != nil {
+		return fmt.Errorf("failed to get Shopify store status: %w", err)
+	}
+	if lastOrderAt.Valid {
+		store.LastOrderAt = &lastOrderAt.Time
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"rtims-backend/internal/models"
+)
+
+// trendColumns maps each trend metric to its dashboard_snapshots column.
+var trendColumns = map[models.TrendMetric]string{
+	models.TrendStockValue: "stock_value",
+	models.TrendMovements:  "movements",
+	models.TrendLowStock:   "low_stock_count",
+}
+
+type SnapshotService struct {
+	db *sql.DB
+}
+
+func NewSnapshotService(db *sql.DB) *SnapshotService {
+	return &SnapshotService{db: db}
+}
+
+// RecordSnapshot stores each organization's current stock figures and the
+// day's movements under day's date, replacing earlier snapshots of the
+// same day.
+func (s *SnapshotService) RecordSnapshot(day time.Time) error {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+
+	query := `
+		INSERT INTO dashboard_snapshots (snapshot_date, organization_id, stock_value, total_stock, low_stock_count,
+		                                 movements, units_in, units_out, updated_at)
+		SELECT $1::date, o.id,
+		       COALESCE(p.stock_value, 0), COALESCE(p.total_stock, 0), COALESCE(p.low_stock_count, 0),
+		       COALESCE(m.movements, 0), COALESCE(m.units_in, 0), COALESCE(m.units_out, 0),
+		       NOW()
+		FROM organizations o
+		LEFT JOIN (
+			SELECT organization_id,
+			       SUM(stock * price) AS stock_value,
+			       SUM(stock) AS total_stock,
+			       COUNT(*) FILTER (WHERE stock <= minimum_threshold AND minimum_threshold > 0) AS low_stock_count
+			FROM products
+			GROUP BY organization_id
+		) p ON p.organization_id = o.id
+		LEFT JOIN (
+			SELECT organization_id,
+			       COUNT(*) AS movements,
+			       SUM(CASE WHEN change > 0 THEN change ELSE 0 END) AS units_in,
+			       SUM(CASE WHEN change < 0 THEN -change ELSE 0 END) AS units_out
+			FROM stock_movements
+			WHERE created_at >= $2 AND created_at < $3
+			GROUP BY organization_id
+		) m ON m.organization_id = o.id
+		ON CONFLICT (snapshot_date, organization_id) DO UPDATE SET
+			stock_value = EXCLUDED.stock_value,
+			total_stock = EXCLUDED.total_stock,
+			low_stock_count = EXCLUDED.low_stock_count,
+			movements = EXCLUDED.movements,
+			units_in = EXCLUDED.units_in,
+			units_out = EXCLUDED.units_out,
+			updated_at = NOW()
+	`
+
+	_, err := s.db.Exec(query, start.Format("2006-01-02"), start, start.AddDate(0, 0, 1))
+	if err != nil {
+		return fmt.Errorf("failed to record dashboard snapshot: %w", err)
+	}
+	return nil
+}
+
+// GetTrend returns the daily values of metric for the organization of ctx
+// from since onwards, oldest first. Days without a snapshot are left out.
+// An unscoped ctx sums every organization.
+func (s *SnapshotService) GetTrend(ctx context.Context, metric models.TrendMetric, since time.Time) ([]models.TrendPoint, error) {
+	column, ok := trendColumns[metric]
+	if !ok {
+		return nil, fmt.Errorf("unknown trend metric %q", metric)
+	}
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT to_char(snapshot_date, 'YYYY-MM-DD'), SUM(` + column + `)
+			  FROM dashboard_snapshots
+			  WHERE snapshot_date >= $1::date AND ($2::uuid IS NULL OR organization_id = $2)
+			  GROUP BY snapshot_date
+			  ORDER BY snapshot_date`
+
+	rows, err := s.db.QueryContext(ctx, query, since.Format("2006-01-02"), organizationArg(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s trend: %w", metric, err)
+	}
+	defer rows.Close()
+
+	points := []models.TrendPoint{}
+	for rows.Next() {
+		var point models.TrendPoint
+		if err := rows.Scan(&point.Date, &point.Value); err != nil {
+			return nil, fmt.Errorf("failed to scan trend point: %w", err)
+		}
+		points = append(points, point)
+	}
+
+	return points, rows.Err()
+}
@@ -0,0 +1,48 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestTrendsArePerOrganization(t *testing.T) {
+	db := testDB(t)
+	snapshots := NewSnapshotService(db)
+
+	suffix := uuid.NewString()[:8]
+	first, second := uuid.New(), uuid.New()
+	for i, organizationID := range []uuid.UUID{first, second} {
+		if _, err := db.Exec(`INSERT INTO organizations (id, name, slug) VALUES ($1, $2, $3)`,
+			organizationID, "Trends "+suffix, "trends-"+suffix+"-"+string(rune('a'+i))); err != nil {
+			t.Fatalf("Failed to create organization: %v", err)
+		}
+		// 10 units at 2.00 in the first, 20 in the second
+		if _, err := db.Exec(`INSERT INTO products (name, sku, stock, price, category, minimum_threshold, organization_id)
+			VALUES ('Widget', $1, $2, 2, 'Parts', 0, $3)`, "TREND-"+suffix, 10*(i+1), organizationID); err != nil {
+			t.Fatalf("Failed to create product: %v", err)
+		}
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM products WHERE organization_id IN ($1, $2)`, first, second)
+		db.Exec(`DELETE FROM organizations WHERE id IN ($1, $2)`, first, second)
+	})
+
+	today := time.Now()
+	if err := snapshots.RecordSnapshot(today); err != nil {
+		t.Fatalf("RecordSnapshot: %v", err)
+	}
+
+	for organizationID, want := range map[uuid.UUID]float64{first: 20, second: 40} {
+		points, err := snapshots.GetTrend(WithOrganization(ctx, organizationID), models.TrendStockValue, today)
+		if err != nil {
+			t.Fatalf("GetTrend: %v", err)
+		}
+		if len(points) != 1 || points[0].Value != want {
+			t.Errorf("expected a stock value of %v for %s, got %+v", want, organizationID, points)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// replicaLagWarning is how far behind the primary the read replica may be
+// before the system status warns about it.
+const replicaLagWarning = 30 * time.Second
+
+// poolStatus describes a Postgres connection pool.
+func poolStatus(db *sql.DB) gin.H {
+	stats := db.Stats()
+	return gin.H{
+		"max_open":        stats.MaxOpenConnections,
+		"open":            stats.OpenConnections,
+		"in_use":          stats.InUse,
+		"idle":            stats.Idle,
+		"wait_count":      stats.WaitCount,
+		"wait_duration":   stats.WaitDuration.String(),
+		"max_idle_closed": stats.MaxIdleClosed,
+	}
+}
+
+// parseRedisInfo reads the "key:value" lines of an INFO reply.
+func parseRedisInfo(raw string) map[string]string {
+	info := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(raw))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			info[key] = value
+		}
+	}
+	return info
+}
+
+// redisStatus describes the Redis server from its INFO reply, and the
+// client's connection pool.
+func redisStatus(ctx context.Context, client *redis.Client) gin.H {
+	if client == nil {
+		return gin.H{"status": "disabled", "last_check": time.Now()}
+	}
+
+	start := time.Now()
+	raw, err := client.Info(ctx).Result()
+	if err != nil {
+		return gin.H{"status": "error", "error": err.Error(), "last_check": time.Now()}
+	}
+	latency := time.Since(start)
+	info := parseRedisInfo(raw)
+
+	integer := func(key string) int64 {
+		n, _ := strconv.ParseInt(info[key], 10, 64)
+		return n
+	}
+	status := gin.H{
+		"status":             "healthy",
+		"latency_ms":         float64(latency.Microseconds()) / 1000,
+		"version":            info["redis_version"],
+		"role":               info["role"],
+		"uptime_seconds":     integer("uptime_in_seconds"),
+		"connected_clients":  integer("connected_clients"),
+		"used_memory":        integer("used_memory"),
+		"used_memory_human":  info["used_memory_human"],
+		"max_memory":         integer("maxmemory"),
+		"ops_per_second":     integer("instantaneous_ops_per_sec"),
+		"commands_processed": integer("total_commands_processed"),
+		"evicted_keys":       integer("evicted_keys"),
+		"keyspace_hits":      integer("keyspace_hits"),
+		"keyspace_misses":    integer("keyspace_misses"),
+		"last_check":         time.Now(),
+	}
+	if lookups := integer("keyspace_hits") + integer("keyspace_misses"); lookups > 0 {
+		status["hit_rate"] = float64(integer("keyspace_hits")) / float64(lookups)
+	}
+	// Close to maxmemory, Redis starts evicting (or refusing) writes
+	if limit := integer("maxmemory"); limit > 0 && integer("used_memory") > limit*9/10 {
+		status["status"] = "warning"
+	}
+
+	pool := client.PoolStats()
+	status["pool"] = gin.H{
+		"total":    pool.TotalConns,
+		"idle":     pool.IdleConns,
+		"stale":    pool.StaleConns,
+		"hits":     pool.Hits,
+		"misses":   pool.Misses,
+		"timeouts": pool.Timeouts,
+	}
+	return status
+}
+
+// replicaStatus describes the read replica, if one is configured, with how
+// far it lags behind the primary. A replica that has replayed everything
+// it received has no lag, however long ago the primary last wrote.
+func replicaStatus(ctx context.Context) gin.H {
+	if readReplica == nil {
+		return nil
+	}
+
+	status := gin.H{"pool": poolStatus(readReplica), "last_check": time.Now()}
+	var inRecovery bool
+	var lag sql.NullFloat64
+	err := readReplica.QueryRowContext(ctx, `
+		SELECT pg_is_in_recovery(),
+		       CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		            ELSE EXTRACT(EPOCH FROM NOW() - pg_last_xact_replay_timestamp()) END`).Scan(&inRecovery, &lag)
+	switch {
+	case err != nil:
+		status["status"] = "error"
+		status["error"] = err.Error()
+	case !inRecovery:
+		status["status"] = "error"
+		status["error"] = "the read replica is not replicating from a primary"
+	default:
+		status["status"] = "healthy"
+		if lag.Valid {
+			status["lag_seconds"] = lag.Float64
+			if lag.Float64 > replicaLagWarning.Seconds() {
+				status["status"] = "warning"
+			}
+		}
+	}
+	if _, unavailable := replicaBreaker.openFor(); unavailable {
+		// Reads are going to the primary until the breaker closes
+		status["status"] = "error"
+		status["serving_reads"] = false
+	}
+	return status
+}
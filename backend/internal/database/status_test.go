@@ -0,0 +1,18 @@
+package database
+
+import (
+	"testing"
+)
+
+func TestParseRedisInfo(t *testing.T) {
+	info := parseRedisInfo("# Server\r\nredis_version:7.2.4\r\nuptime_in_seconds:3600\r\n\r\n# Keyspace\r\ndb0:keys=12,expires=3,avg_ttl=0\r\n")
+	if info["redis_version"] != "7.2.4" || info["uptime_in_seconds"] != "3600" {
+		t.Errorf("unexpected info %v", info)
+	}
+	if info["db0"] != "keys=12,expires=3,avg_ttl=0" {
+		t.Errorf("expected values to keep their colons and commas, got %q", info["db0"])
+	}
+	if _, ok := info["# Server"]; ok {
+		t.Error("expected section headers to be skipped")
+	}
+}
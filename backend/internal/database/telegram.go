@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// TelegramService stores the Telegram chats linked to users.
+type TelegramService struct {
+	db *sql.DB
+}
+
+func NewTelegramService(db *sql.DB) *TelegramService {
+	return &TelegramService{db: db}
+}
+
+// LinkTelegramChat links a chat to a user, taking it over from any user it
+// was linked to before.
+func (s *TelegramService) LinkTelegramChat(ctx context.Context, chat *models.TelegramChat) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO telegram_chats (chat_id, user_id, title)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (chat_id) DO UPDATE SET user_id = EXCLUDED.user_id, title = EXCLUDED.title, linked_at = NOW()
+		RETURNING linked_at`, chat.ChatID, chat.UserID, chat.Title).Scan(&chat.LinkedAt)
+	if err != nil {
+		return fmt.Errorf("failed to link Telegram chat: %w", err)
+	}
+	return nil
+}
+
+// GetTelegramChat returns a linked chat.
+func (s *TelegramService) GetTelegramChat(ctx context.Context, chatID int64) (*models.TelegramChat, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var chat models.TelegramChat
+	err := s.db.QueryRowContext(ctx, `SELECT chat_id, user_id, title, linked_at FROM telegram_chats WHERE chat_id = $1`, chatID).
+		Scan(&chat.ChatID, &chat.UserID, &chat.Title, &chat.LinkedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("Telegram chat %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Telegram chat: %w", err)
+	}
+	return &chat, nil
+}
+
+// GetTelegramChats returns the chats linked to a user, oldest first.
+func (s *TelegramService) GetTelegramChats(ctx context.Context, userID uuid.UUID) ([]models.TelegramChat, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT chat_id, user_id, title, linked_at FROM telegram_chats
+		WHERE user_id = $1 ORDER BY linked_at`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Telegram chats: %w", err)
+	}
+	defer rows.Close()
+
+	chats := []models.TelegramChat{}
+	for rows.Next() {
+		var chat models.TelegramChat
+		if err := rows.Scan(&chat.ChatID, &chat.UserID, &chat.Title, &chat.LinkedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan Telegram chat: %w", err)
+		}
+		chats = append(chats, chat)
+	}
+	return chats, rows.Err()
+}
+
+// UnlinkTelegramChat unlinks a chat from a user.
+func (s *TelegramService) UnlinkTelegramChat(ctx context.Context, chatID int64, userID uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM telegram_chats WHERE chat_id = $1 AND user_id = $2`, chatID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to unlink Telegram chat: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("Telegram chat %w", ErrNotFound)
+	}
+	return nil
+}
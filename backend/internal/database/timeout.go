@@ -0,0 +1,26 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// queryTimeout bounds each service call so a stuck query cannot hold a
+// pooled connection forever. Zero disables it.
+var queryTimeout = 10 * time.Second
+
+// ConfigureQueryTimeout sets the per-call query timeout. Call it once at
+// startup, before any service is used.
+func ConfigureQueryTimeout(timeout time.Duration) {
+	queryTimeout = timeout
+}
+
+// withTimeout derives the context a service call runs its queries under.
+// A caller that set its own deadline, such as a maintenance job that needs
+// longer, keeps it.
+func withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, queryTimeout)
+}
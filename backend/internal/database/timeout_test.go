@@ -0,0 +1,33 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWithTimeout(t *testing.T) {
+	defer ConfigureQueryTimeout(queryTimeout)
+	ConfigureQueryTimeout(time.Second)
+
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > time.Second {
+		t.Errorf("expected a deadline within a second, got %v", deadline)
+	}
+
+	parent, cancelParent := context.WithTimeout(context.Background(), time.Hour)
+	defer cancelParent()
+	ctx, cancel = withTimeout(parent)
+	defer cancel()
+	if deadline, _ := ctx.Deadline(); time.Until(deadline) < 59*time.Minute {
+		t.Errorf("expected the caller's deadline to be kept, got %v", deadline)
+	}
+
+	ConfigureQueryTimeout(0)
+	ctx, cancel = withTimeout(context.Background())
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("expected no deadline when the timeout is disabled")
+	}
+}
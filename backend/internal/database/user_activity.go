@@ -0,0 +1,193 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Login events recorded by the auth handlers. Successful token refreshes
+// happen every few minutes of a session, so they are counted as neither
+// logins nor timeline entries.
+const (
+	eventLogin        = "login"
+	eventLoginFailed  = "login_failed"
+	eventTokenRefresh = "token_refresh"
+)
+
+// countAuditActivity adds count audit entries with action and event, the
+// newest made at last, to summary.
+func countAuditActivity(summary *models.ActivitySummary, action models.AuditAction, event string, count int, last time.Time) {
+	switch {
+	case action == models.ActionLogout:
+		summary.Logouts += count
+	case action != models.ActionLogin:
+		summary.AuditActions[action] += count
+	case event == eventLogin:
+		summary.Logins += count
+		if summary.LastLoginAt == nil || last.After(*summary.LastLoginAt) {
+			summary.LastLoginAt = &last
+		}
+	case event == eventLoginFailed:
+		summary.FailedLogins += count
+	}
+}
+
+// mergeActivity merges two timelines that are newest first into one of at
+// most limit entries, and reports whether entries were cut.
+func mergeActivity(a, b []models.ActivityEntry, limit int) ([]models.ActivityEntry, bool) {
+	merged := make([]models.ActivityEntry, 0, len(a)+len(b))
+	for len(a) > 0 || len(b) > 0 {
+		if len(b) == 0 || (len(a) > 0 && !a[0].OccurredAt.Before(b[0].OccurredAt)) {
+			merged = append(merged, a[0])
+			a = a[1:]
+		} else {
+			merged = append(merged, b[0])
+			b = b[1:]
+		}
+	}
+	if len(merged) > limit {
+		return merged[:limit], true
+	}
+	return merged, false
+}
+
+// GetUserActivity returns what a user did between from and to: their
+// audited actions, login history and stock movements, counted over the
+// whole period, and the newest limit of them as a timeline.
+func (s *UserService) GetUserActivity(ctx context.Context, id uuid.UUID, from, to time.Time, limit int) (*models.UserActivity, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	// Activity covers months of audit history, so it is read from the replica
+	db := ForReads(s.db)
+
+	activity := &models.UserActivity{
+		UserID: id,
+		From:   from,
+		To:     to,
+		Summary: models.ActivitySummary{
+			AuditActions:      map[models.AuditAction]int{},
+			MovementsByReason: map[models.MovementReason]int{},
+		},
+	}
+	summary := &activity.Summary
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT action, COALESCE(new_values->>'event', ''), COUNT(*), MAX(changed_at)
+		FROM audit_logs
+		WHERE changed_by = $1 AND changed_at >= $2 AND changed_at <= $3
+		AND ($4::uuid IS NULL OR organization_id = $4)
+		GROUP BY 1, 2`, id, from, to, organizationArg(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to count audit activity: %w", err)
+	}
+	for rows.Next() {
+		var action models.AuditAction
+		var event string
+		var count int
+		var last time.Time
+		if err := rows.Scan(&action, &event, &count, &last); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan audit activity: %w", err)
+		}
+		countAuditActivity(summary, action, event, count, last)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to count audit activity: %w", err)
+	}
+
+	rows, err = db.QueryContext(ctx, `
+		SELECT reason, COUNT(*),
+		       COALESCE(SUM(change) FILTER (WHERE change > 0), 0),
+		       COALESCE(-SUM(change) FILTER (WHERE change < 0), 0)
+		FROM stock_movements
+		WHERE created_by = $1 AND created_at >= $2 AND created_at <= $3
+		AND ($4::uuid IS NULL OR organization_id = $4)
+		GROUP BY reason`, id, from, to, organizationArg(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to count stock movements: %w", err)
+	}
+	for rows.Next() {
+		var reason models.MovementReason
+		var count, in, out int
+		if err := rows.Scan(&reason, &count, &in, &out); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan stock movements: %w", err)
+		}
+		summary.StockMovements += count
+		summary.MovementsByReason[reason] = count
+		summary.UnitsIn += in
+		summary.UnitsOut += out
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to count stock movements: %w", err)
+	}
+
+	// Each source is read one past the limit, so merging tells whether the
+	// timeline was cut
+	rows, err = db.QueryContext(ctx, `
+		SELECT `+auditLogColumns+`
+		FROM audit_logs
+		WHERE changed_by = $1 AND changed_at >= $2 AND changed_at <= $3
+		AND ($4::uuid IS NULL OR organization_id = $4)
+		AND NOT (action = 'login' AND COALESCE(new_values->>'event', '') = $5)
+		ORDER BY changed_at DESC
+		LIMIT $6`, id, from, to, organizationArg(ctx), eventTokenRefresh, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audit activity: %w", err)
+	}
+	audited := []models.ActivityEntry{}
+	for rows.Next() {
+		var auditLog models.AuditLog
+		if err := scanAuditLog(rows, &auditLog); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan audit activity: %w", err)
+		}
+		kind := models.ActivityAudit
+		if auditLog.Action == models.ActionLogin || auditLog.Action == models.ActionLogout {
+			kind = models.ActivityLogin
+		}
+		audited = append(audited, models.ActivityEntry{Kind: kind, OccurredAt: auditLog.ChangedAt, Audit: &auditLog})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get audit activity: %w", err)
+	}
+
+	rows, err = db.QueryContext(ctx, `
+		SELECT m.id, m.product_id, m.change, m.reason, m.created_by, m.created_at, COALESCE(m.notes, ''),
+		       p.name, p.sku
+		FROM stock_movements m JOIN products p ON p.id = m.product_id
+		WHERE m.created_by = $1 AND m.created_at >= $2 AND m.created_at <= $3
+		AND ($4::uuid IS NULL OR m.organization_id = $4)
+		ORDER BY m.created_at DESC
+		LIMIT $5`, id, from, to, organizationArg(ctx), limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stock movement activity: %w", err)
+	}
+	movements := []models.ActivityEntry{}
+	for rows.Next() {
+		var movement models.ActivityMovement
+		err := rows.Scan(&movement.ID, &movement.ProductID, &movement.Change, &movement.Reason, &movement.CreatedBy,
+			&movement.CreatedAt, &movement.Notes, &movement.ProductName, &movement.ProductSKU)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan stock movement activity: %w", err)
+		}
+		movements = append(movements, models.ActivityEntry{Kind: models.ActivityStockMovement, OccurredAt: movement.CreatedAt, Movement: &movement})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get stock movement activity: %w", err)
+	}
+
+	activity.Timeline, activity.Truncated = mergeActivity(audited, movements, limit)
+	return activity, nil
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"rtims-backend/internal/models"
+)
+
+func TestCountAuditActivity(t *testing.T) {
+	summary := models.ActivitySummary{AuditActions: map[models.AuditAction]int{}}
+	earlier := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	later := earlier.Add(48 * time.Hour)
+
+	countAuditActivity(&summary, models.ActionUpdate, "", 4, later)
+	countAuditActivity(&summary, models.ActionView, "", 2, later)
+	countAuditActivity(&summary, models.ActionLogin, eventLogin, 3, later)
+	countAuditActivity(&summary, models.ActionLogin, eventLogin, 1, earlier)
+	countAuditActivity(&summary, models.ActionLogin, eventLoginFailed, 2, later)
+	countAuditActivity(&summary, models.ActionLogin, eventTokenRefresh, 40, later)
+	countAuditActivity(&summary, models.ActionLogout, "logout", 1, earlier)
+
+	if summary.AuditActions[models.ActionUpdate] != 4 || summary.AuditActions[models.ActionView] != 2 || len(summary.AuditActions) != 2 {
+		t.Errorf("expected login events kept out of audit actions, got %v", summary.AuditActions)
+	}
+	if summary.Logins != 4 || summary.FailedLogins != 2 || summary.Logouts != 1 {
+		t.Errorf("expected 4 logins, 2 failed and 1 logout without token refreshes, got %+v", summary)
+	}
+	if summary.LastLoginAt == nil || !summary.LastLoginAt.Equal(later) {
+		t.Errorf("expected the last login at %v, got %v", later, summary.LastLoginAt)
+	}
+}
+
+func TestMergeActivity(t *testing.T) {
+	at := func(hour int) time.Time { return time.Date(2024, 5, 1, hour, 0, 0, 0, time.UTC) }
+	entry := func(kind models.ActivityKind, hour int) models.ActivityEntry {
+		return models.ActivityEntry{Kind: kind, OccurredAt: at(hour)}
+	}
+	audited := []models.ActivityEntry{entry(models.ActivityAudit, 9), entry(models.ActivityLogin, 7), entry(models.ActivityAudit, 3)}
+	movements := []models.ActivityEntry{entry(models.ActivityStockMovement, 8), entry(models.ActivityStockMovement, 3)}
+
+	merged, truncated := mergeActivity(audited, movements, 10)
+	if truncated || len(merged) != 5 {
+		t.Fatalf("expected all 5 entries, got %d (truncated %v)", len(merged), truncated)
+	}
+	for i, want := range []int{9, 8, 7, 3, 3} {
+		if !merged[i].OccurredAt.Equal(at(want)) {
+			t.Errorf("entry %d: expected %02d:00, got %v", i, want, merged[i].OccurredAt)
+		}
+	}
+
+	merged, truncated = mergeActivity(audited, movements, 2)
+	if !truncated || len(merged) != 2 || merged[1].Kind != models.ActivityStockMovement {
+		t.Errorf("expected the 2 newest entries, got %+v (truncated %v)", merged, truncated)
+	}
+}
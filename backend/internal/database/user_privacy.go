@@ -0,0 +1,195 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// AnonymizedName replaces the name of a deleted user.
+const AnonymizedName = "Deleted user"
+
+// AnonymizeUser deletes a user by scrubbing their personal data rather
+// than the row, which their stock movements, reports and audit entries
+// still reference. The user can no longer log in; their notifications,
+// preferences, report shares, linked Telegram chats and favorite products
+// are deleted, their email is taken off report schedule recipients, and the
+// audit trail keeps what they did but not their name, email, IP addresses
+// or browsers. It returns ErrNotFound for a user who does not exist or was
+// already anonymized. With a context from WithPendingDeletion, the user's
+// pending deletion is removed along with it.
+func (s *UserService) AnonymizeUser(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := completePendingDeletion(ctx, tx); err != nil {
+		return err
+	}
+
+	var email string
+	err = tx.QueryRowContext(ctx, `
+		SELECT email FROM users
+		WHERE id = $1 AND ($2::uuid IS NULL OR organization_id = $2) AND anonymized_at IS NULL
+		FOR UPDATE`, id, organizationArg(ctx)).Scan(&email)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("user %w", ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	// The email stays unique, and the empty password matches no login
+	_, err = tx.ExecContext(ctx, `
+		UPDATE users SET
+			name = $2,
+			email = 'deleted-' || id || '@anonymized.invalid',
+			password = '',
+			is_active = false,
+			locale = NULL,
+			calendar_feed_secret = NULL,
+			anonymized_at = NOW(),
+			updated_at = NOW()
+		WHERE id = $1`, id, AnonymizedName)
+	if err != nil {
+		return fmt.Errorf("failed to anonymize user: %w", err)
+	}
+
+	statements := []struct {
+		what  string
+		query string
+		args  []interface{}
+	}{
+		{"notifications", "DELETE FROM notifications WHERE user_id = $1", []interface{}{id}},
+		{"notification preferences", "DELETE FROM notification_preferences WHERE user_id = $1", []interface{}{id}},
+		{"report shares", "DELETE FROM report_shares WHERE user_id = $1", []interface{}{id}},
+		{"Telegram chats", "DELETE FROM telegram_chats WHERE user_id = $1", []interface{}{id}},
+		{"favorite products", "DELETE FROM product_favorites WHERE user_id = $1", []interface{}{id}},
+		{"report recipients", "UPDATE report_schedules SET recipients = recipients - $1::text WHERE recipients ? $1::text", []interface{}{email}},
+		{"audit origins", "UPDATE audit_logs SET ip_address = NULL, user_agent = NULL, request_body = NULL, response_body = NULL WHERE changed_by = $1", []interface{}{id}},
+		{"audit values", `UPDATE audit_logs SET old_values = old_values - 'name' - 'email', new_values = new_values - 'name' - 'email'
+			WHERE table_name = 'users' AND record_id = $1`, []interface{}{id}},
+	}
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement.query, statement.args...); err != nil {
+			return fmt.Errorf("failed to scrub %s: %w", statement.what, err)
+		}
+	}
+
+	if productSearcher != nil {
+		// The search index drops the scrubbed details when it reads the
+		// entries again
+		query := searchIndexInsert(models.SearchAuditLogs) +
+			"audit_logs WHERE changed_by = $1 OR (table_name = 'users' AND record_id = $1)"
+		if _, err := tx.ExecContext(ctx, query, id); err != nil {
+			return fmt.Errorf("failed to queue audit logs for the search index: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UserDataSection is one part of a user's personal data export, such as
+// their profile or their stock movements.
+type UserDataSection struct {
+	Name string
+	Rows []map[string]interface{}
+}
+
+// userDataQueries select the personal data a user's export holds, each
+// with the user's ID as $1.
+var userDataQueries = []struct {
+	name  string
+	query string
+}{
+	{"profile", `SELECT id, name, email, role, is_active, locale, organization_id, created_at, updated_at, anonymized_at
+		FROM users WHERE id = $1`},
+	{"notification_preferences", `SELECT type, email_enabled, updated_at FROM notification_preferences WHERE user_id = $1 ORDER BY type`},
+	{"notifications", `SELECT id, type, message, is_read, is_archived, created_at FROM notifications WHERE user_id = $1 ORDER BY created_at`},
+	{"stock_movements", `SELECT id, product_id, change, reason, notes, created_at FROM stock_movements WHERE created_by = $1 ORDER BY created_at`},
+	{"reports", `SELECT id, type, format, status, parameters, filename, created_at FROM reports WHERE requested_by = $1 ORDER BY created_at`},
+	{"report_schedules", `SELECT id, name, parameters, cron_expression, recipients, is_active, created_at FROM report_schedules
+		WHERE created_by = $1 ORDER BY created_at`},
+	{"reports_shared_with_user", `SELECT report_id, shared_by FROM report_shares WHERE user_id = $1`},
+	{"telegram_chats", `SELECT chat_id, title, linked_at FROM telegram_chats WHERE user_id = $1 ORDER BY linked_at`},
+	{"favorite_products", `SELECT product_id, created_at FROM product_favorites WHERE user_id = $1 ORDER BY created_at`},
+	{"comments", `SELECT id, product_id, movement_id, body, created_at FROM comments WHERE author_id = $1 ORDER BY created_at`},
+	{"announcements", `SELECT id, title, message, severity, expires_at, created_at FROM announcements WHERE created_by = $1 ORDER BY created_at`},
+	{"activity", `SELECT id, table_name, record_id, action, old_values, new_values, changed_at, ip_address, user_agent, status_code,
+		request_body, response_body FROM audit_logs WHERE changed_by = $1 ORDER BY changed_at`},
+	{"account_history", `SELECT id, action, old_values, new_values, changed_by, changed_at
+		FROM audit_logs WHERE table_name = 'users' AND record_id = $1 ORDER BY changed_at`},
+}
+
+// ExportUserData returns the personal data held about a user, section by
+// section. The caller checks the user belongs to the organization.
+func (s *UserService) ExportUserData(ctx context.Context, id uuid.UUID) ([]UserDataSection, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	sections := make([]UserDataSection, 0, len(userDataQueries))
+	for _, export := range userDataQueries {
+		rows, err := ForReads(s.db).QueryContext(ctx, export.query, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", export.name, err)
+		}
+		exported, err := scanMaps(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", export.name, err)
+		}
+		sections = append(sections, UserDataSection{Name: export.name, Rows: exported})
+	}
+	return sections, nil
+}
+
+// scanMaps reads every row into a map of column to value, and closes rows.
+// Text comes back as strings and JSON columns as raw JSON.
+func scanMaps(rows *sql.Rows) ([]map[string]interface{}, error) {
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []map[string]interface{}{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			row[column] = exportValue(values[i])
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// exportValue makes a scanned value encode as it reads in the database.
+func exportValue(value interface{}) interface{} {
+	raw, ok := value.([]byte)
+	if !ok {
+		return value
+	}
+	if len(raw) > 0 && (raw[0] == '{' || raw[0] == '[') && json.Valid(raw) {
+		return json.RawMessage(raw)
+	}
+	return string(raw)
+}
@@ -0,0 +1,38 @@
+package database
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestExportValue(t *testing.T) {
+	encoded, err := json.Marshal(map[string]interface{}{
+		"id":         exportValue([]byte("6f1c6a51-6f62-4d8e-9a57-3c1f1f7e2b10")),
+		"parameters": exportValue([]byte(`{"category":"Tools"}`)),
+		"notes":      exportValue([]byte("[damaged] box")),
+		"change":     exportValue(int64(-3)),
+		"locale":     exportValue(nil),
+		"created_at": exportValue(time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)),
+	})
+	if err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+	want := `{"change":-3,"created_at":"2024-05-01T08:00:00Z","id":"6f1c6a51-6f62-4d8e-9a57-3c1f1f7e2b10","locale":null,"notes":"[damaged] box","parameters":{"category":"Tools"}}`
+	if string(encoded) != want {
+		t.Errorf("expected %s, got %s", want, encoded)
+	}
+}
+
+func TestUserDataQueries(t *testing.T) {
+	seen := map[string]bool{}
+	for _, export := range userDataQueries {
+		if seen[export.name] {
+			t.Errorf("section %s is exported twice", export.name)
+		}
+		seen[export.name] = true
+	}
+	if !seen["profile"] || !seen["activity"] {
+		t.Error("expected the profile and activity to be exported")
+	}
+}
@@ -0,0 +1,372 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"rtims-backend/internal/models"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// webhookColumns leave out the secret, which is only read to sign
+// deliveries.
+const webhookColumns = `id, organization_id, url, description, events, is_active, created_by, created_at, updated_at`
+
+const webhookDeliveryColumns = `id, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at,
+	COALESCE(response_status, 0), COALESCE(response_body, ''), COALESCE(error, ''), COALESCE(duration_ms, 0),
+	created_at, last_attempt_at`
+
+// webhookLease is how long a claimed delivery is hidden from other workers
+// while it is being sent.
+const webhookLease = time.Minute
+
+// queryer is a *sql.DB or a *sql.Tx.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// queueWebhookEvent queues event for every active webhook of organizationID
+// that subscribes to it. Run in the transaction of the change the event
+// describes, the deliveries exist if and only if the change commits.
+func queueWebhookEvent(ctx context.Context, q queryer, organizationID uuid.UUID, event models.WebhookEvent, data interface{}) error {
+	payload := models.WebhookPayload{
+		ID:             uuid.New(),
+		Type:           event,
+		OrganizationID: organizationID,
+		CreatedAt:      time.Now(),
+		Data:           data,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s webhook event: %w", event, err)
+	}
+
+	_, err = q.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (id, webhook_id, event_id, event_type, payload, next_attempt_at)
+		SELECT uuid_generate_v4(), id, $1, $2, $3, NOW() FROM webhooks
+		WHERE organization_id = $4 AND is_active AND $2 = ANY(events)`,
+		payload.ID, string(event), string(body), organizationID)
+	if err != nil {
+		return fmt.Errorf("failed to queue %s webhooks: %w", event, err)
+	}
+	return nil
+}
+
+func scanWebhook(row interface{ Scan(...interface{}) error }, w *models.Webhook) error {
+	var events []string
+	var createdBy uuid.NullUUID
+
+	err := row.Scan(&w.ID, &w.OrganizationID, &w.URL, &w.Description, pq.Array(&events), &w.IsActive, &createdBy,
+		&w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	w.Events = make([]models.WebhookEvent, len(events))
+	for i, event := range events {
+		w.Events[i] = models.WebhookEvent(event)
+	}
+	if createdBy.Valid {
+		w.CreatedBy = &createdBy.UUID
+	}
+	return nil
+}
+
+func scanWebhookDelivery(row interface{ Scan(...interface{}) error }, d *models.WebhookDelivery) error {
+	var nextAttemptAt, lastAttemptAt sql.NullTime
+
+	err := row.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts, &nextAttemptAt,
+		&d.ResponseStatus, &d.ResponseBody, &d.Error, &d.DurationMs, &d.CreatedAt, &lastAttemptAt)
+	if err != nil {
+		return err
+	}
+
+	if nextAttemptAt.Valid {
+		d.NextAttemptAt = &nextAttemptAt.Time
+	}
+	if lastAttemptAt.Valid {
+		d.LastAttemptAt = &lastAttemptAt.Time
+	}
+	return nil
+}
+
+func webhookEvents(events []models.WebhookEvent) interface{} {
+	values := make([]string, len(events))
+	for i, event := range events {
+		values[i] = string(event)
+	}
+	return pq.Array(values)
+}
+
+// WebhookService manages webhooks and their deliveries.
+type WebhookService struct {
+	db *sql.DB
+}
+
+func NewWebhookService(db *sql.DB) *WebhookService {
+	return &WebhookService{db: db}
+}
+
+// GetWebhooks returns the webhooks of the organization of ctx.
+func (s *WebhookService) GetWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+webhookColumns+` FROM webhooks
+		WHERE ($1::uuid IS NULL OR organization_id = $1)
+		ORDER BY created_at`, organizationArg(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := []models.Webhook{}
+	for rows.Next() {
+		var webhook models.Webhook
+		if err := scanWebhook(rows, &webhook); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+func (s *WebhookService) GetWebhook(ctx context.Context, id uuid.UUID) (*models.Webhook, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + webhookColumns + ` FROM webhooks
+			  WHERE id = $1 AND ($2::uuid IS NULL OR organization_id = $2)`
+
+	var webhook models.Webhook
+	if err := scanWebhook(s.db.QueryRowContext(ctx, query, id, organizationArg(ctx)), &webhook); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("webhook %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	return &webhook, nil
+}
+
+// GetWebhookSecret returns the secret that signs a webhook's deliveries.
+func (s *WebhookService) GetWebhookSecret(ctx context.Context, id uuid.UUID) (string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var secret string
+	err := s.db.QueryRowContext(ctx, `SELECT secret FROM webhooks WHERE id = $1 AND ($2::uuid IS NULL OR organization_id = $2)`,
+		id, organizationArg(ctx)).Scan(&secret)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("webhook %w", ErrNotFound)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get webhook secret: %w", err)
+	}
+	return secret, nil
+}
+
+// CreateWebhook creates a webhook in the organization of ctx.
+func (s *WebhookService) CreateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	if organizationID, ok := OrganizationFrom(ctx); ok {
+		webhook.OrganizationID = organizationID
+	} else if webhook.OrganizationID == uuid.Nil {
+		webhook.OrganizationID = DefaultOrganizationID
+	}
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO webhooks (id, organization_id, url, description, secret, events, is_active, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		webhook.ID, webhook.OrganizationID, webhook.URL, webhook.Description, webhook.Secret, webhookEvents(webhook.Events),
+		webhook.IsActive, webhook.CreatedBy, webhook.CreatedAt, webhook.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+	return nil
+}
+
+// UpdateWebhook changes the fields of req that are set, and the secret
+// when one is given.
+func (s *WebhookService) UpdateWebhook(ctx context.Context, id uuid.UUID, req models.UpdateWebhookRequest, secret string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	values := map[string]interface{}{"updated_at": sq.Expr("NOW()")}
+	if req.URL != nil {
+		values["url"] = *req.URL
+	}
+	if req.Description != nil {
+		values["description"] = *req.Description
+	}
+	if req.Events != nil {
+		values["events"] = webhookEvents(req.Events)
+	}
+	if req.IsActive != nil {
+		values["is_active"] = *req.IsActive
+	}
+	if secret != "" {
+		values["secret"] = secret
+	}
+
+	query, args, err := psql.Update("webhooks").SetMap(values).Where(idInOrganization(ctx, id)).ToSql()
+	if err != nil {
+		return err
+	}
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook: %w", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		return fmt.Errorf("webhook %w", ErrNotFound)
+	}
+	return nil
+}
+
+// DeleteWebhook deletes a webhook with its delivery log.
+func (s *WebhookService) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1 AND ($2::uuid IS NULL OR organization_id = $2)`,
+		id, organizationArg(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		return fmt.Errorf("webhook %w", ErrNotFound)
+	}
+	return nil
+}
+
+// GetWebhookDeliveries returns a webhook's most recent deliveries, newest
+// first, optionally only those with status.
+func (s *WebhookService) GetWebhookDeliveries(ctx context.Context, webhookID uuid.UUID, status models.WebhookDeliveryStatus, limit int) ([]models.WebhookDelivery, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries
+		WHERE webhook_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC
+		LIMIT $3`, webhookID, string(status), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		if err := scanWebhookDelivery(rows, &delivery); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// CreateWebhookDelivery records a delivery that is sent right away rather
+// than by the delivery worker, such as a test-fire.
+func (s *WebhookService) CreateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (id, webhook_id, event_id, event_type, payload, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		delivery.ID, delivery.WebhookID, delivery.EventID, delivery.EventType, string(delivery.Payload), delivery.Status,
+		delivery.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// PendingDelivery is a claimed delivery with where to send it.
+type PendingDelivery struct {
+	models.WebhookDelivery
+	URL    string
+	Secret string
+}
+
+// ClaimWebhookDeliveries leases up to limit deliveries that are due, oldest
+// first, so other workers skip them while they are sent. Deliveries of
+// inactive webhooks wait until the webhook is activated again.
+func (s *WebhookService) ClaimWebhookDeliveries(ctx context.Context, limit int) ([]PendingDelivery, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		UPDATE webhook_deliveries d SET next_attempt_at = $2
+		FROM webhooks w
+		WHERE w.id = d.webhook_id AND d.id IN (
+			SELECT dd.id FROM webhook_deliveries dd JOIN webhooks ww ON ww.id = dd.webhook_id
+			WHERE dd.status = 'pending' AND dd.next_attempt_at <= NOW() AND ww.is_active
+			ORDER BY dd.next_attempt_at
+			LIMIT $1
+			FOR UPDATE OF dd SKIP LOCKED
+		)
+		RETURNING d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.attempts, d.created_at, w.url, w.secret`,
+		limit, time.Now().Add(webhookLease))
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []PendingDelivery
+	for rows.Next() {
+		var d PendingDelivery
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Payload, &d.Attempts, &d.CreatedAt, &d.URL, &d.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		d.Status = models.DeliveryPending
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// RecordWebhookAttempt stores the outcome of a delivery's latest attempt:
+// its status, and when it is retried, if it is.
+func (s *WebhookService) RecordWebhookAttempt(ctx context.Context, id uuid.UUID, attempt models.WebhookAttempt, status models.WebhookDeliveryStatus, nextAttemptAt *time.Time) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries SET
+			status = $2,
+			attempts = attempts + 1,
+			next_attempt_at = $3,
+			response_status = NULLIF($4, 0),
+			response_body = NULLIF($5, ''),
+			error = NULLIF($6, ''),
+			duration_ms = $7,
+			last_attempt_at = NOW()
+		WHERE id = $1`,
+		id, status, nextAttemptAt, attempt.ResponseStatus, attempt.ResponseBody, attempt.Error, attempt.Duration.Milliseconds())
+	if err != nil {
+		return fmt.Errorf("failed to record webhook attempt: %w", err)
+	}
+	return nil
+}
+
+// PurgeWebhookDeliveries deletes finished deliveries created before the
+// given time.
+func (s *WebhookService) PurgeWebhookDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE status <> 'pending' AND created_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge webhook deliveries: %w", err)
+	}
+	return result.RowsAffected()
+}
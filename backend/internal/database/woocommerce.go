@@ -0,0 +1,280 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const wooCommerceConflictColumns = `c.id, c.store, c.woo_id, c.product_id, p.name, c.sku, c.rtims_stock, c.woo_stock, c.synced_stock,
+	c.detected_at, c.updated_at, c.resolution, c.resolved_by, c.resolved_at`
+
+const wooCommerceRunColumns = `id, store, started_at, finished_at, checked, pushed, pulled, conflicts, failed, COALESCE(error, '')`
+
+func scanWooCommerceConflict(row interface{ Scan(...interface{}) error }, c *models.WooCommerceConflict) error {
+	var syncedStock sql.NullInt64
+	var resolution sql.NullString
+	var resolvedBy uuid.NullUUID
+	var resolvedAt sql.NullTime
+
+	err := row.Scan(&c.ID, &c.Store, &c.WooID, &c.ProductID, &c.ProductName, &c.SKU, &c.RTIMSStock, &c.WooStock, &syncedStock,
+		&c.DetectedAt, &c.UpdatedAt, &resolution, &resolvedBy, &resolvedAt)
+	if err != nil {
+		return err
+	}
+
+	if syncedStock.Valid {
+		stock := int(syncedStock.Int64)
+		c.SyncedStock = &stock
+	}
+	if resolution.Valid {
+		r := models.WooCommerceResolution(resolution.String)
+		c.Resolution = &r
+	}
+	if resolvedBy.Valid {
+		c.ResolvedBy = &resolvedBy.UUID
+	}
+	if resolvedAt.Valid {
+		c.ResolvedAt = &resolvedAt.Time
+	}
+	return nil
+}
+
+// WooCommerceService stores the mappings, conflicts and reconciliation runs
+// of the WooCommerce integration. The stores themselves are configured in
+// settings.
+type WooCommerceService struct {
+	db *sql.DB
+}
+
+func NewWooCommerceService(db *sql.DB) *WooCommerceService {
+	return &WooCommerceService{db: db}
+}
+
+// GetWooCommerceMappings returns the mappings of store, by WooCommerce ID.
+func (s *WooCommerceService) GetWooCommerceMappings(ctx context.Context, store string) (map[int64]models.WooCommerceMapping, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT store, woo_id, parent_id, product_id, sku, synced_stock, synced_at
+		FROM woocommerce_products WHERE store = $1`, store)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get WooCommerce mappings: %w", err)
+	}
+	defer rows.Close()
+
+	mappings := make(map[int64]models.WooCommerceMapping)
+	for rows.Next() {
+		var m models.WooCommerceMapping
+		var syncedStock sql.NullInt64
+		var syncedAt sql.NullTime
+		if err := rows.Scan(&m.Store, &m.WooID, &m.ParentID, &m.ProductID, &m.SKU, &syncedStock, &syncedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan WooCommerce mapping: %w", err)
+		}
+		if syncedStock.Valid {
+			stock := int(syncedStock.Int64)
+			m.SyncedStock = &stock
+		}
+		if syncedAt.Valid {
+			m.SyncedAt = &syncedAt.Time
+		}
+		mappings[m.WooID] = m
+	}
+	return mappings, rows.Err()
+}
+
+// SaveWooCommerceMapping creates or replaces the mapping of a WooCommerce
+// product.
+func (s *WooCommerceService) SaveWooCommerceMapping(ctx context.Context, m *models.WooCommerceMapping) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO woocommerce_products (store, woo_id, parent_id, product_id, sku, synced_stock, synced_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (store, woo_id) DO UPDATE SET
+			parent_id = EXCLUDED.parent_id,
+			product_id = EXCLUDED.product_id,
+			sku = EXCLUDED.sku,
+			synced_stock = EXCLUDED.synced_stock,
+			synced_at = EXCLUDED.synced_at`,
+		m.Store, m.WooID, m.ParentID, m.ProductID, m.SKU, m.SyncedStock, m.SyncedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save WooCommerce mapping: %w", err)
+	}
+	return nil
+}
+
+// GetOpenWooCommerceConflicts returns the conflicts waiting to be resolved,
+// of every store or only of store, oldest first.
+func (s *WooCommerceService) GetOpenWooCommerceConflicts(ctx context.Context, store string) ([]models.WooCommerceConflict, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+wooCommerceConflictColumns+`
+		FROM woocommerce_conflicts c JOIN products p ON p.id = c.product_id
+		WHERE c.resolved_at IS NULL AND ($1 = '' OR c.store = $1)
+		ORDER BY c.detected_at`, store)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get WooCommerce conflicts: %w", err)
+	}
+	defer rows.Close()
+
+	conflicts := []models.WooCommerceConflict{}
+	for rows.Next() {
+		var conflict models.WooCommerceConflict
+		if err := scanWooCommerceConflict(rows, &conflict); err != nil {
+			return nil, fmt.Errorf("failed to scan WooCommerce conflict: %w", err)
+		}
+		conflicts = append(conflicts, conflict)
+	}
+	return conflicts, rows.Err()
+}
+
+func (s *WooCommerceService) GetWooCommerceConflict(ctx context.Context, id uuid.UUID) (*models.WooCommerceConflict, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + wooCommerceConflictColumns + `
+		FROM woocommerce_conflicts c JOIN products p ON p.id = c.product_id
+		WHERE c.id = $1`
+
+	var conflict models.WooCommerceConflict
+	if err := scanWooCommerceConflict(s.db.QueryRowContext(ctx, query, id), &conflict); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("WooCommerce conflict %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get WooCommerce conflict: %w", err)
+	}
+	return &conflict, nil
+}
+
+// SaveWooCommerceConflict records a conflict, or updates the stock levels
+// of the open conflict of the same WooCommerce product.
+func (s *WooCommerceService) SaveWooCommerceConflict(ctx context.Context, c *models.WooCommerceConflict) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO woocommerce_conflicts (id, store, woo_id, product_id, sku, rtims_stock, woo_stock, synced_stock)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (store, woo_id) WHERE resolved_at IS NULL DO UPDATE SET
+			product_id = EXCLUDED.product_id,
+			sku = EXCLUDED.sku,
+			rtims_stock = EXCLUDED.rtims_stock,
+			woo_stock = EXCLUDED.woo_stock,
+			synced_stock = EXCLUDED.synced_stock,
+			updated_at = NOW()`,
+		c.ID, c.Store, c.WooID, c.ProductID, c.SKU, c.RTIMSStock, c.WooStock, c.SyncedStock)
+	if err != nil {
+		return fmt.Errorf("failed to save WooCommerce conflict: %w", err)
+	}
+	return nil
+}
+
+// ResolveWooCommerceConflict closes an open conflict. resolvedBy is nil
+// for conflicts that resolved themselves.
+func (s *WooCommerceService) ResolveWooCommerceConflict(ctx context.Context, id uuid.UUID, resolution models.WooCommerceResolution, resolvedBy *uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `UPDATE woocommerce_conflicts
+		SET resolution = $2, resolved_by = $3, resolved_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND resolved_at IS NULL`, id, resolution, resolvedBy)
+	if err != nil {
+		return fmt.Errorf("failed to resolve WooCommerce conflict: %w", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		return fmt.Errorf("open WooCommerce conflict %w", ErrNotFound)
+	}
+	return nil
+}
+
+func (s *WooCommerceService) CreateWooCommerceRun(ctx context.Context, run *models.WooCommerceRun) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO woocommerce_runs (id, store, started_at, finished_at, checked, pushed, pulled, conflicts, failed, error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''))`,
+		run.ID, run.Store, run.StartedAt, run.FinishedAt, run.Checked, run.Pushed, run.Pulled, run.Conflicts, run.Failed, run.Error)
+	if err != nil {
+		return fmt.Errorf("failed to record WooCommerce run: %w", err)
+	}
+	return nil
+}
+
+// GetWooCommerceStoreStatus fills in the sync state of store: how many
+// products are mapped, how many conflicts are open, and its last run.
+func (s *WooCommerceService) GetWooCommerceStoreStatus(ctx context.Context, store *models.WooCommerceStore) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM woocommerce_products WHERE store = $1),
+			(SELECT COUNT(*) FROM woocommerce_conflicts WHERE store = $1 AND resolved_at IS NULL)`,
+		store.Name).Scan(&store.Mapped, &store.OpenConflicts)
+	if err != nil {
+		return fmt.Errorf("failed to get WooCommerce store status: %w", err)
+	}
+
+	var run models.WooCommerceRun
+	err = s.db.QueryRowContext(ctx, `SELECT `+wooCommerceRunColumns+` FROM woocommerce_runs
+		WHERE store = $1 ORDER BY started_at DESC LIMIT 1`, store.Name).Scan(
+		&run.ID, &run.Store, &run.StartedAt, &run.FinishedAt, &run.Checked, &run.Pushed, &run.Pulled, &run.Conflicts,
+		&run.Failed, &run.Error)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get last WooCommerce run: %w", err)
+	}
+	store.LastRun = &run
+	return nil
+}
+
+// PurgeWooCommerceRuns deletes the runs started before the given time.
+func (s *WooCommerceService) PurgeWooCommerceRuns(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM woocommerce_runs WHERE started_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge WooCommerce runs: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// DeleteStaleWooCommerceMappings deletes the mappings of store, and their
+// open conflicts, other than those of the WooCommerce IDs in keep: the
+// products the store no longer has or whose SKU no longer matches.
+func (s *WooCommerceService) DeleteStaleWooCommerceMappings(ctx context.Context, store string, keep []int64) error {
+	if keep == nil {
+		keep = []int64{} // A nil array is NULL, which keeps every mapping
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM woocommerce_conflicts
+		WHERE store = $1 AND resolved_at IS NULL AND NOT (woo_id = ANY($2))`, store, pq.Array(keep)); err != nil {
+		return fmt.Errorf("failed to delete stale WooCommerce conflicts: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM woocommerce_products
+		WHERE store = $1 AND NOT (woo_id = ANY($2))`, store, pq.Array(keep)); err != nil {
+		return fmt.Errorf("failed to delete stale WooCommerce mappings: %w", err)
+	}
+	return tx.Commit()
+}
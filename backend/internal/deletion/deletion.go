@@ -0,0 +1,172 @@
+// Package deletion carries out the deletions of users, categories and
+// products once their undo window has passed.
+package deletion
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/models"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// batchSize is how many deletions one run claims at most.
+const batchSize = 100
+
+// retryDelay is how long a deletion that failed waits to be tried again.
+const retryDelay = 5 * time.Minute
+
+// errCategoryInUse skips a category that got products during its window.
+var errCategoryInUse = errors.New("category has products")
+
+// Runner deletes the records whose pending deletion is due, as the DELETE
+// endpoints would have at once, and audits each deletion as made by the
+// admin who asked for it.
+type Runner struct {
+	db              *sql.DB
+	pending         *database.PendingDeletionService
+	productService  *database.ProductService
+	categoryService *database.CategoryService
+	userService     *database.UserService
+	auditService    *database.AuditService
+	recent          *database.RecentProducts
+}
+
+func NewRunner(db *sql.DB, cache *database.Cache, redisClient *redis.Client) *Runner {
+	return &Runner{
+		db:              db,
+		pending:         database.NewPendingDeletionService(db),
+		productService:  database.NewCachedProductService(db, cache),
+		categoryService: database.NewCategoryService(db),
+		userService:     database.NewUserService(db),
+		auditService:    database.NewAuditService(db),
+		recent:          database.NewRecentProducts(redisClient),
+	}
+}
+
+// RunDue carries out the deletions whose window has passed, each removing
+// its pending deletion in the transaction that deletes the record. A
+// deletion that fails is tried again after retryDelay; records already
+// gone, and categories that gained products, are dropped.
+func (r *Runner) RunDue() error {
+	due, err := r.pending.DueDeletions(context.Background(), batchSize)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, d := range due {
+		ctx := database.WithPendingDeletion(database.WithOrganization(context.Background(), d.OrganizationID), d.ID)
+		err := r.delete(ctx, d)
+		switch {
+		case err == nil, errors.Is(err, database.ErrNotPending):
+		case errors.Is(err, database.ErrNotFound), errors.Is(err, sql.ErrNoRows):
+			log.Printf("Pending deletion of %s %s dropped, the record no longer exists", d.TableName, d.RecordID)
+			r.drop(d)
+		case errors.Is(err, errCategoryInUse):
+			log.Printf("Pending deletion of category %s dropped, it has products again", d.RecordID)
+			r.drop(d)
+		default:
+			failed++
+			log.Printf("Failed to delete %s %s: %v", d.TableName, d.RecordID, err)
+			if err := r.pending.PostponeDeletion(context.Background(), d.ID, time.Now().Add(retryDelay)); err != nil {
+				log.Printf("Failed to postpone deletion of %s %s: %v", d.TableName, d.RecordID, err)
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d pending deletions failed", failed, len(due))
+	}
+	return nil
+}
+
+func (r *Runner) drop(d models.PendingDeletion) {
+	if err := r.pending.DropDeletion(context.Background(), d.ID); err != nil {
+		log.Printf("Failed to drop deletion of %s %s: %v", d.TableName, d.RecordID, err)
+	}
+}
+
+func (r *Runner) delete(ctx context.Context, d models.PendingDeletion) error {
+	var oldValues, newValues map[string]interface{}
+	switch d.TableName {
+	case models.DeletionProducts:
+		product, err := r.productService.GetProduct(ctx, d.RecordID)
+		if err != nil {
+			return err
+		}
+		if err := r.productService.DeleteProduct(ctx, d.RecordID); err != nil {
+			return err
+		}
+		oldValues = map[string]interface{}{
+			"name":              product.Name,
+			"sku":               product.SKU,
+			"stock":             product.Stock,
+			"price":             product.Price,
+			"category":          product.Category,
+			"minimum_threshold": product.MinimumThreshold,
+			"supplier_info":     product.SupplierInfo,
+		}
+
+	case models.DeletionCategories:
+		category, err := r.categoryService.GetCategory(ctx, d.RecordID)
+		if err != nil {
+			return err
+		}
+		var productCount int
+		err = r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM products WHERE category = $1 AND organization_id = $2",
+			category.Name, d.OrganizationID).Scan(&productCount)
+		if err != nil {
+			return fmt.Errorf("failed to check category usage: %w", err)
+		}
+		if productCount > 0 {
+			return errCategoryInUse
+		}
+		if err := r.categoryService.DeleteCategory(ctx, d.RecordID); err != nil {
+			return err
+		}
+		oldValues = map[string]interface{}{"name": category.Name, "description": category.Description}
+
+	case models.DeletionUsers:
+		user, err := r.userService.GetUser(ctx, d.RecordID)
+		if err != nil {
+			return err
+		}
+		if err := r.userService.AnonymizeUser(ctx, d.RecordID); err != nil {
+			return err
+		}
+		r.recent.Clear(ctx, d.RecordID)
+		// Like DELETE, the entry names no one; is_active is as it was
+		// before the deletion deactivated the user
+		oldValues = map[string]interface{}{"role": user.Role, "is_active": d.RestoreActive}
+		newValues = map[string]interface{}{"anonymized": true}
+
+	default:
+		return fmt.Errorf("cannot delete records of %s", d.TableName)
+	}
+
+	changedBy := uuid.Nil
+	if d.RequestedBy != nil {
+		changedBy = *d.RequestedBy
+	}
+	auditLog := &models.AuditLog{
+		ID:        uuid.New(),
+		TableName: d.TableName,
+		RecordID:  d.RecordID,
+		Action:    models.ActionDelete,
+		OldValues: oldValues,
+		NewValues: newValues,
+		ChangedBy: changedBy,
+		ChangedAt: time.Now(),
+	}
+	if err := r.auditService.Record(ctx, auditLog); err != nil {
+		log.Printf("Failed to create audit log: %v", err)
+	}
+	return nil
+}
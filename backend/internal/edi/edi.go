@@ -0,0 +1,134 @@
+// Package edi sends EDI 846 inventory advice documents to the trading
+// partners configured in settings. Each partner profile holds the
+// interchange qualifiers and IDs of both sides, the organization whose
+// stock is advised, and the transport documents are delivered by: SFTP
+// and AS2 are built in, and others can be added with RegisterTransport.
+package edi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SettingPartners holds a JSON object of the trading partners to send
+// inventory advice to, by a name of lowercase letters, digits, hyphens and
+// underscores, e.g. {"acme": {"organization": "default",
+// "sender_qualifier": "ZZ", "sender_id": "RTIMS", "receiver_qualifier":
+// "01", "receiver_id": "123456789", "transport": "sftp", "options":
+// {"host": "edi.acme.com", …}}}.
+const SettingPartners = "edi_partners"
+
+// ErrUnknownPartner is returned for a partner that is not configured.
+var ErrUnknownPartner = errors.New("EDI partner is not configured")
+
+var (
+	partnerName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,99}$`)
+	// qualifier matches ISA and product ID qualifiers
+	qualifier = regexp.MustCompile(`^[A-Z0-9]{2}$`)
+	// interchangeID matches ISA sender and receiver IDs, which are padded
+	// to 15 characters, and GS application codes
+	interchangeID = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 ._/-]{0,14}$`)
+)
+
+// Partner is the profile of one trading partner. The application codes of
+// the functional group default to the interchange IDs.
+type Partner struct {
+	Name                string `json:"-"`
+	Organization        string `json:"organization"`
+	SenderQualifier     string `json:"sender_qualifier"`
+	SenderID            string `json:"sender_id"`
+	ReceiverQualifier   string `json:"receiver_qualifier"`
+	ReceiverID          string `json:"receiver_id"`
+	ApplicationSender   string `json:"application_sender,omitempty"`
+	ApplicationReceiver string `json:"application_receiver,omitempty"`
+	// ProductQualifier is how LIN segments identify products by SKU;
+	// "SK" (stock keeping unit) unless set.
+	ProductQualifier string `json:"product_qualifier,omitempty"`
+	// Test marks interchanges as test data rather than production.
+	Test bool `json:"test,omitempty"`
+	// Categories limits the advice to products in them.
+	Categories []string          `json:"categories,omitempty"`
+	Transport  string            `json:"transport"`
+	Options    map[string]string `json:"options"`
+}
+
+// ParsePartners parses the SettingPartners value, checking that every
+// profile is complete and that its transport accepts its options.
+func ParsePartners(value string) (map[string]Partner, error) {
+	partners := map[string]Partner{}
+	if strings.TrimSpace(value) == "" {
+		return partners, nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&partners); err != nil {
+		return nil, errors.New("must be an object mapping partner names to partner profiles")
+	}
+
+	names := make([]string, 0, len(partners))
+	for name := range partners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		partner := partners[name]
+		partner.Name = name
+		if partner.ApplicationSender == "" {
+			partner.ApplicationSender = partner.SenderID
+		}
+		if partner.ApplicationReceiver == "" {
+			partner.ApplicationReceiver = partner.ReceiverID
+		}
+		if partner.ProductQualifier == "" {
+			partner.ProductQualifier = "SK"
+		}
+		if !partnerName.MatchString(name) {
+			return nil, fmt.Errorf("%q is not a partner name of lowercase letters, digits, hyphens and underscores", name)
+		}
+		if err := partner.check(); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		partners[name] = partner
+	}
+	return partners, nil
+}
+
+func (p Partner) check() error {
+	if p.Organization == "" {
+		return errors.New("needs the organization whose stock it is sent")
+	}
+	for _, field := range []struct {
+		name, value string
+		pattern     *regexp.Regexp
+	}{
+		{"sender_qualifier", p.SenderQualifier, qualifier},
+		{"sender_id", p.SenderID, interchangeID},
+		{"receiver_qualifier", p.ReceiverQualifier, qualifier},
+		{"receiver_id", p.ReceiverID, interchangeID},
+		{"application_sender", p.ApplicationSender, interchangeID},
+		{"application_receiver", p.ApplicationReceiver, interchangeID},
+		{"product_qualifier", p.ProductQualifier, qualifier},
+	} {
+		if field.pattern.MatchString(field.value) {
+			continue
+		}
+		if field.pattern == qualifier {
+			return fmt.Errorf("%s must be two uppercase letters or digits", field.name)
+		}
+		return fmt.Errorf("%s must be 1 to 15 letters, digits, spaces or ._/-", field.name)
+	}
+
+	transport, ok := lookupTransport(p.Transport)
+	if !ok {
+		return fmt.Errorf("transport must be one of %s", strings.Join(Transports(), ", "))
+	}
+	if err := transport.Validate(p.Options); err != nil {
+		return fmt.Errorf("%s options: %w", p.Transport, err)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package edi
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"rtims-backend/internal/models"
+)
+
+const as2Partner = `"transport": "as2", "options": {"url": "https://as2.example.com", "as2_from": "RTIMS", "as2_to": "ACME"}`
+
+func TestParsePartners(t *testing.T) {
+	partners, err := ParsePartners(`{"acme": {"organization": "default", "sender_qualifier": "ZZ", "sender_id": "RTIMS",
+		"receiver_qualifier": "01", "receiver_id": "123456789", ` + as2Partner + `}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	partner := partners["acme"]
+	if partner.Name != "acme" || partner.ApplicationSender != "RTIMS" || partner.ApplicationReceiver != "123456789" ||
+		partner.ProductQualifier != "SK" {
+		t.Errorf("unexpected partner %+v", partner)
+	}
+
+	if partners, err := ParsePartners(""); err != nil || len(partners) != 0 {
+		t.Errorf("expected no partners for an empty value, got %v, %v", partners, err)
+	}
+
+	ids := `"organization": "default", "sender_qualifier": "ZZ", "sender_id": "RTIMS", "receiver_qualifier": "01", "receiver_id": "123456789"`
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"not an object", `["acme"]`, "must be an object"},
+		{"unknown field", `{"acme": {"colour": "red"}}`, "must be an object"},
+		{"bad name", `{"ACME Corp": {` + ids + `, ` + as2Partner + `}}`, "not a partner name"},
+		{"no organization", `{"acme": {"sender_qualifier": "ZZ", ` + as2Partner + `}}`, "needs the organization"},
+		{"bad qualifier", `{"acme": {"organization": "default", "sender_qualifier": "zz", "sender_id": "RTIMS", "receiver_qualifier": "01", "receiver_id": "1", ` + as2Partner + `}}`, "sender_qualifier must be"},
+		{"long ID", `{"acme": {"organization": "default", "sender_qualifier": "ZZ", "sender_id": "RTIMS", "receiver_qualifier": "01", "receiver_id": "1234567890123456", ` + as2Partner + `}}`, "receiver_id must be"},
+		{"delimiter in ID", `{"acme": {"organization": "default", "sender_qualifier": "ZZ", "sender_id": "RT*IMS", "receiver_qualifier": "01", "receiver_id": "1", ` + as2Partner + `}}`, "sender_id must be"},
+		{"unknown transport", `{"acme": {` + ids + `, "transport": "fax"}}`, "transport must be one of as2, sftp"},
+		{"AS2 without url", `{"acme": {` + ids + `, "transport": "as2", "options": {"as2_from": "A", "as2_to": "B"}}}`, "as2 options: needs an http or https url"},
+		{"SFTP without credentials", `{"acme": {` + ids + `, "transport": "sftp", "options": {"host": "edi.example.com", "username": "rtims", "host_key": "SHA256:abc"}}}`, "needs password or private_key"},
+		{"SFTP host key", `{"acme": {` + ids + `, "transport": "sftp", "options": {"host": "edi.example.com", "username": "rtims", "password": "p", "host_key": "ab:cd"}}}`, "host_key must be"},
+	}
+	for _, tt := range tests {
+		if _, err := ParsePartners(tt.value); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestBuild846(t *testing.T) {
+	partner := Partner{Name: "acme", SenderQualifier: "ZZ", SenderID: "RTIMS", ReceiverQualifier: "01", ReceiverID: "123456789",
+		ApplicationSender: "RTIMS", ApplicationReceiver: "ACME", ProductQualifier: "SK", Test: true}
+	products := []models.Product{
+		{SKU: "WID-1", Name: "Widget *large*", Stock: 12},
+		{SKU: "GAD-2", Name: "Gadget", Stock: 0},
+	}
+	at := time.Date(2026, 10, 16, 9, 5, 0, 0, time.UTC)
+
+	want := "ISA*00*          *00*          *ZZ*RTIMS          *01*123456789      *261016*0905*U*00401*000000042*0*T*>~\n" +
+		"GS*IB*RTIMS*ACME*20261016*0905*42*X*004010~\n" +
+		"ST*846*0001~\n" +
+		"BIA*00*SI*RTIMS000000042*20261016~\n" +
+		"LIN*1*SK*WID-1~\n" +
+		"PID*F****Widget  large~\n" +
+		"QTY*33*12*EA~\n" +
+		"LIN*2*SK*GAD-2~\n" +
+		"PID*F****Gadget~\n" +
+		"QTY*33*0*EA~\n" +
+		"CTT*2~\n" +
+		"SE*10*0001~\n" +
+		"GE*1*42~\n" +
+		"IEA*1*000000042~\n"
+	if got := string(Build846(partner, 42, products, at)); got != want {
+		t.Errorf("unexpected document:\n%s\nwant:\n%s", got, want)
+	}
+
+	isa := strings.SplitN(want, "~", 2)[0]
+	if len(isa) != 105 {
+		t.Errorf("expected a 106 character ISA segment, got %d", len(isa)+1)
+	}
+}
+
+func TestAS2Deliver(t *testing.T) {
+	var got *http.Request
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		buf := new(strings.Builder)
+		_, _ = io.Copy(buf, r.Body)
+		body = buf.String()
+		if r.Header.Get("AS2-To") != "ACME" {
+			http.Error(w, "unknown partner", http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	partner := Partner{Options: map[string]string{"url": server.URL, "as2_from": "RTIMS", "as2_to": "ACME", "username": "u", "password": "p"}}
+	transport := AS2{Client: server.Client()}
+	if err := transport.Deliver(context.Background(), partner, "846.edi", []byte("ISA*00~")); err != nil {
+		t.Fatal(err)
+	}
+	username, password, _ := got.BasicAuth()
+	if got.Method != http.MethodPost || got.Header.Get("AS2-From") != "RTIMS" || got.Header.Get("Content-Type") != "application/EDI-X12" ||
+		got.Header.Get("Message-ID") == "" || username != "u" || password != "p" || body != "ISA*00~" {
+		t.Errorf("unexpected request %s %v with body %q", got.Method, got.Header, body)
+	}
+
+	partner.Options["as2_to"] = "OTHER"
+	err := transport.Deliver(context.Background(), partner, "846.edi", []byte("ISA*00~"))
+	if deliveryErr, ok := err.(*DeliveryError); !ok || deliveryErr.Status != http.StatusForbidden {
+		t.Errorf("expected a 403 delivery error, got %v", err)
+	}
+}
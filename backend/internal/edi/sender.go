@@ -0,0 +1,212 @@
+package edi
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// documentRetention is how long generated documents are kept.
+const documentRetention = 90 * 24 * time.Hour
+
+// ErrOrganization is returned when the organization of a partner is
+// missing or deactivated.
+var ErrOrganization = errors.New("EDI partner organization is unavailable")
+
+// Sender generates and delivers the documents of the configured partners.
+type Sender struct {
+	ediService          *database.EDIService
+	settingsService     *database.SettingsService
+	organizationService *database.OrganizationService
+}
+
+func NewSender(db *sql.DB) *Sender {
+	return &Sender{
+		ediService:          database.NewEDIService(db),
+		settingsService:     database.NewSettingsService(db),
+		organizationService: database.NewOrganizationService(db),
+	}
+}
+
+// Partners returns the configured partners.
+func (s *Sender) Partners() (map[string]Partner, error) {
+	settings, err := s.settingsService.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	value, _ := settings[SettingPartners].(string)
+	return ParsePartners(value)
+}
+
+// Partner returns the profile of the partner called name.
+func (s *Sender) Partner(name string) (Partner, error) {
+	partners, err := s.Partners()
+	if err != nil {
+		return Partner{}, err
+	}
+	partner, ok := partners[name]
+	if !ok {
+		return Partner{}, ErrUnknownPartner
+	}
+	return partner, nil
+}
+
+// Status returns the configured partners with their last document.
+func (s *Sender) Status(ctx context.Context) ([]models.EDIPartner, error) {
+	partners, err := s.Partners()
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]models.EDIPartner, 0, len(partners))
+	for _, partner := range partners {
+		state := models.EDIPartner{
+			Name:              partner.Name,
+			Organization:      partner.Organization,
+			ReceiverQualifier: partner.ReceiverQualifier,
+			ReceiverID:        partner.ReceiverID,
+			Transport:         partner.Transport,
+		}
+		if state.LastDocument, err = s.ediService.GetLastEDIDocument(ctx, partner.Name); err != nil {
+			return nil, err
+		}
+		status = append(status, state)
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].Name < status[j].Name })
+	return status, nil
+}
+
+// SendAll sends every configured partner its inventory advice and purges
+// old documents. It is the scheduled job; a partner whose document cannot
+// be generated or delivered is logged without stopping the others.
+func (s *Sender) SendAll() error {
+	partners, err := s.Partners()
+	if err != nil {
+		return fmt.Errorf("failed to read EDI partners: %w", err)
+	}
+
+	names := make([]string, 0, len(partners))
+	for name := range partners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	failed := 0
+	for _, name := range names {
+		document, err := s.send(context.Background(), partners[name])
+		if err != nil {
+			log.Printf("EDI 846 for %s failed: %v", name, err)
+			failed++
+			continue
+		}
+		if document.Status == models.EDIFailed {
+			log.Printf("EDI 846 %s for %s was not delivered: %s", document.Filename, name, document.Error)
+			failed++
+			continue
+		}
+		log.Printf("Sent EDI 846 %s with %d items to %s", document.Filename, document.Items, name)
+	}
+
+	if _, err := s.ediService.PurgeEDIDocuments(context.Background(), time.Now().Add(-documentRetention)); err != nil {
+		log.Printf("Failed to purge EDI documents: %v", err)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d EDI partners were not sent their inventory advice", failed, len(names))
+	}
+	return nil
+}
+
+// Send sends the partner called name its inventory advice now. A document
+// that could not be delivered is returned as failed, to be resent.
+func (s *Sender) Send(ctx context.Context, name string) (*models.EDIDocument, error) {
+	partner, err := s.Partner(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.send(ctx, partner)
+}
+
+// organization returns the active organization with the partner's slug.
+func (s *Sender) organization(ctx context.Context, partner Partner) (*models.Organization, error) {
+	organizations, err := s.organizationService.GetOrganizations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, organization := range organizations {
+		if organization.Slug != partner.Organization {
+			continue
+		}
+		if !organization.IsActive {
+			return nil, fmt.Errorf("%w: %s is deactivated", ErrOrganization, organization.Name)
+		}
+		return &organization, nil
+	}
+	return nil, fmt.Errorf("%w: no organization %s", ErrOrganization, partner.Organization)
+}
+
+// send generates a document of the partner's current inventory, saves it
+// and delivers it.
+func (s *Sender) send(ctx context.Context, partner Partner) (*models.EDIDocument, error) {
+	organization, err := s.organization(ctx, partner)
+	if err != nil {
+		return nil, err
+	}
+	products, err := s.ediService.GetEDIInventory(ctx, organization.ID, partner.Categories)
+	if err != nil {
+		return nil, err
+	}
+	controlNumber, err := s.ediService.NextEDIControlNumber(ctx, partner.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	document := &models.EDIDocument{
+		Partner:        partner.Name,
+		OrganizationID: organization.ID,
+		ControlNumber:  controlNumber,
+		Items:          len(products),
+		Filename:       fmt.Sprintf("846_%s_%09d_%s.edi", partner.Name, controlNumber, now.Format("20060102150405")),
+		Transport:      partner.Transport,
+		Content:        Build846(partner, controlNumber, products, now),
+	}
+	if err := s.ediService.CreateEDIDocument(ctx, document); err != nil {
+		return nil, err
+	}
+	return document, s.deliver(ctx, partner, document)
+}
+
+// Resend delivers a document again, by its partner's current transport,
+// as it was generated: with the same control number.
+func (s *Sender) Resend(ctx context.Context, id uuid.UUID) (*models.EDIDocument, error) {
+	document, err := s.ediService.GetEDIDocument(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	partner, err := s.Partner(document.Partner)
+	if err != nil {
+		return nil, err
+	}
+	document.Transport = partner.Transport
+	return document, s.deliver(ctx, partner, document)
+}
+
+// deliver sends a saved document and records the outcome. Only failing to
+// record it is returned; a failed delivery is recorded on the document.
+func (s *Sender) deliver(ctx context.Context, partner Partner, document *models.EDIDocument) error {
+	transport, ok := lookupTransport(partner.Transport)
+	if !ok {
+		return fmt.Errorf("EDI transport %s is not registered", partner.Transport)
+	}
+	deliveryErr := transport.Deliver(ctx, partner, document.Filename, document.Content)
+	return s.ediService.RecordEDIDelivery(context.Background(), document, deliveryErr)
+}
@@ -0,0 +1,33 @@
+package edi
+
+import (
+	"context"
+	"path"
+
+	"rtims-backend/internal/sftp"
+)
+
+// SFTP uploads documents to a partner's SFTP server. Its options are
+// host, port (22 unless set), username, password or private_key (PEM),
+// host_key (the server key's SHA256 fingerprint, as ssh-keygen -l prints
+// it) and directory. Documents are written under a temporary name and
+// renamed once complete, so partners never pick up part of one.
+type SFTP struct{}
+
+func (SFTP) Validate(options map[string]string) error {
+	return sftp.ConfigFromOptions(options).Validate()
+}
+
+func (SFTP) Deliver(ctx context.Context, partner Partner, filename string, document []byte) error {
+	client, err := sftp.Dial(ctx, sftp.ConfigFromOptions(partner.Options))
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	directory := partner.Options["directory"]
+	if directory == "" {
+		directory = "."
+	}
+	return client.Upload(path.Join(directory, filename), document)
+}
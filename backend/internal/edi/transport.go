@@ -0,0 +1,131 @@
+package edi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Transport delivers documents to trading partners. Partner profiles pick
+// a transport by the name it is registered under and configure it with
+// their options.
+type Transport interface {
+	// Validate checks a partner's options when the partners setting is
+	// saved, so misconfigured partners are caught before a delivery.
+	Validate(options map[string]string) error
+	// Deliver sends the document to the partner as a file called filename.
+	Deliver(ctx context.Context, partner Partner, filename string, document []byte) error
+}
+
+var (
+	transportsMu sync.RWMutex
+	transports   = map[string]Transport{}
+)
+
+func init() {
+	RegisterTransport("sftp", SFTP{})
+	RegisterTransport("as2", AS2{Client: &http.Client{Timeout: 60 * time.Second}})
+}
+
+// RegisterTransport makes a transport available to partner profiles under
+// name. It is meant to be called from the init function of the package
+// providing the transport, and panics if name is taken.
+func RegisterTransport(name string, transport Transport) {
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	if _, ok := transports[name]; ok {
+		panic("edi: transport " + name + " registered twice")
+	}
+	transports[name] = transport
+}
+
+// Transports returns the names of the registered transports, sorted.
+func Transports() []string {
+	transportsMu.RLock()
+	defer transportsMu.RUnlock()
+	names := make([]string, 0, len(transports))
+	for name := range transports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupTransport(name string) (Transport, bool) {
+	transportsMu.RLock()
+	defer transportsMu.RUnlock()
+	transport, ok := transports[name]
+	return transport, ok
+}
+
+// DeliveryError is returned when a partner's server refuses a document.
+type DeliveryError struct {
+	Status int
+	Body   string
+}
+
+func (e *DeliveryError) Error() string {
+	return fmt.Sprintf("partner responded with status %d: %s", e.Status, e.Body)
+}
+
+// as2Name matches AS2-From and AS2-To identifiers, which are sent
+// unquoted.
+var as2Name = regexp.MustCompile(`^[!#-\[\]-~]{1,128}$`)
+
+// AS2 posts documents as unsigned, unencrypted AS2 messages without a
+// receipt request. Partners requiring signed or encrypted messages and
+// MDNs are reached by pointing url at an AS2 gateway that adds them. Its
+// options are url, as2_from, as2_to, and the optional username and
+// password of HTTP basic authentication.
+type AS2 struct {
+	Client *http.Client
+}
+
+func (AS2) Validate(options map[string]string) error {
+	parsed, err := url.Parse(options["url"])
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return errors.New("needs an http or https url")
+	}
+	if !as2Name.MatchString(options["as2_from"]) || !as2Name.MatchString(options["as2_to"]) {
+		return errors.New("needs as2_from and as2_to identifiers of up to 128 printable characters without spaces or quotes")
+	}
+	return nil
+}
+
+func (t AS2) Deliver(ctx context.Context, partner Partner, filename string, document []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, partner.Options["url"], bytes.NewReader(document))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("AS2-Version", "1.2")
+	req.Header.Set("AS2-From", partner.Options["as2_from"])
+	req.Header.Set("AS2-To", partner.Options["as2_to"])
+	req.Header.Set("Message-ID", fmt.Sprintf("<%s@rtims>", uuid.New()))
+	req.Header.Set("Subject", "EDI 846 inventory advice")
+	req.Header.Set("Content-Type", "application/EDI-X12")
+	req.Header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if username := partner.Options["username"]; username != "" {
+		req.SetBasicAuth(username, partner.Options["password"])
+	}
+
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &DeliveryError{Status: resp.StatusCode, Body: string(body)}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package edi
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"rtims-backend/internal/models"
+)
+
+// The delimiters of generated interchanges. Segments end in a newline too,
+// which translators ignore, to keep documents readable.
+const (
+	elementSeparator    = "*"
+	componentSeparator  = ">"
+	segmentTerminator   = "~\n"
+	maxDescriptionRunes = 80
+)
+
+// x12Text keeps a value from breaking the delimiters of an interchange.
+var x12Text = strings.NewReplacer("*", " ", ">", " ", "~", " ", "\r", " ", "\n", " ")
+
+// Build846 writes an X12 004010 interchange holding one 846 inventory
+// advice, listing the quantity of every product that is available to
+// sell, at the given time in UTC.
+func Build846(partner Partner, controlNumber int, products []models.Product, at time.Time) []byte {
+	at = at.UTC()
+	var buf bytes.Buffer
+	segment := func(elements ...string) {
+		buf.WriteString(strings.Join(elements, elementSeparator))
+		buf.WriteString(segmentTerminator)
+	}
+	usage := "P"
+	if partner.Test {
+		usage = "T"
+	}
+	control := fmt.Sprintf("%09d", controlNumber)
+	group := fmt.Sprint(controlNumber)
+
+	segment("ISA", "00", fmt.Sprintf("%-10s", ""), "00", fmt.Sprintf("%-10s", ""),
+		partner.SenderQualifier, fmt.Sprintf("%-15s", partner.SenderID),
+		partner.ReceiverQualifier, fmt.Sprintf("%-15s", partner.ReceiverID),
+		at.Format("060102"), at.Format("1504"), "U", "00401", control, "0", usage, componentSeparator)
+	segment("GS", "IB", partner.ApplicationSender, partner.ApplicationReceiver, at.Format("20060102"), at.Format("1504"),
+		group, "X", "004010")
+	segment("ST", "846", "0001")
+	segment("BIA", "00", "SI", "RTIMS"+control, at.Format("20060102"))
+	for i, product := range products {
+		description := []rune(x12Text.Replace(product.Name))
+		if len(description) > maxDescriptionRunes {
+			description = description[:maxDescriptionRunes]
+		}
+		segment("LIN", fmt.Sprint(i+1), partner.ProductQualifier, x12Text.Replace(product.SKU))
+		segment("PID", "F", "", "", "", strings.TrimSpace(string(description)))
+		segment("QTY", "33", fmt.Sprint(product.Stock), "EA")
+	}
+	segment("CTT", fmt.Sprint(len(products)))
+	// ST, BIA, three segments a product, CTT and SE itself
+	segment("SE", fmt.Sprint(3*len(products)+4), "0001")
+	segment("GE", "1", group)
+	segment("IEA", "1", control)
+	return buf.Bytes()
+}
@@ -0,0 +1,133 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io"
+	"mime/multipart"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+
+	"rtims-backend/config"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var templates = template.Must(template.ParseFS(templateFS, "templates/*.html"))
+
+// Mailer sends HTML email through the configured SMTP server.
+type Mailer struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+}
+
+func NewMailer(cfg *config.Config) *Mailer {
+	return &Mailer{
+		host:     cfg.SMTPHost,
+		port:     cfg.SMTPPort,
+		username: cfg.SMTPUsername,
+		password: cfg.SMTPPassword,
+		from:     cfg.EmailFrom,
+	}
+}
+
+// Enabled reports whether SMTP credentials have been configured.
+func (m *Mailer) Enabled() bool {
+	return m != nil && m.host != "" && m.username != ""
+}
+
+// Attachment is a file sent along with an email.
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Send delivers an HTML message to a single recipient.
+func (m *Mailer) Send(to, subject, htmlBody string) error {
+	return m.SendWithAttachments(to, subject, htmlBody)
+}
+
+// SendWithAttachments delivers an HTML message with files attached as a
+// multipart/mixed message.
+func (m *Mailer) SendWithAttachments(to, subject, htmlBody string, attachments ...Attachment) error {
+	if !m.Enabled() {
+		return fmt.Errorf("email delivery is not configured")
+	}
+
+	var msg strings.Builder
+	msg.WriteString("From: " + m.from + "\r\n")
+	msg.WriteString("To: " + to + "\r\n")
+	msg.WriteString("Subject: " + subject + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+
+	if len(attachments) == 0 {
+		msg.WriteString("Content-Type: text/html; charset=\"UTF-8\"\r\n")
+		msg.WriteString("\r\n")
+		msg.WriteString(htmlBody)
+	} else {
+		writer := multipart.NewWriter(&msg)
+		msg.WriteString("Content-Type: multipart/mixed; boundary=\"" + writer.Boundary() + "\"\r\n")
+		msg.WriteString("\r\n")
+
+		body, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type": {"text/html; charset=\"UTF-8\""},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to build email: %w", err)
+		}
+		body.Write([]byte(htmlBody))
+
+		for _, attachment := range attachments {
+			part, err := writer.CreatePart(textproto.MIMEHeader{
+				"Content-Type":              {attachment.ContentType},
+				"Content-Transfer-Encoding": {"base64"},
+				"Content-Disposition":       {fmt.Sprintf("attachment; filename=%q", attachment.Filename)},
+			})
+			if err != nil {
+				return fmt.Errorf("failed to build email: %w", err)
+			}
+			writeBase64Lines(part, attachment.Data)
+		}
+
+		if err := writer.Close(); err != nil {
+			return fmt.Errorf("failed to build email: %w", err)
+		}
+	}
+
+	addr := fmt.Sprintf("%s:%d", m.host, m.port)
+	auth := smtp.PlainAuth("", m.username, m.password, m.host)
+	if err := smtp.SendMail(addr, auth, m.from, []string{to}, []byte(msg.String())); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
+
+// writeBase64Lines encodes data as base64 wrapped at 76 characters, as
+// required for MIME bodies.
+func writeBase64Lines(w io.Writer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		io.WriteString(w, encoded[:76]+"\r\n")
+		encoded = encoded[76:]
+	}
+	io.WriteString(w, encoded+"\r\n")
+}
+
+// Render executes the named HTML template with data.
+func Render(name string, data interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", fmt.Errorf("failed to render email template %s: %w", name, err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,257 @@
+// Package files keeps product images and stock movement attachments in the
+// object store, records them in the database and hands them out as signed
+// URLs. It also runs the storage cleanup, deleting objects nothing refers
+// to any more.
+package files
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/models"
+	"rtims-backend/internal/storage"
+
+	"github.com/google/uuid"
+)
+
+// Largest files accepted.
+const (
+	MaxImageSize      = 5 << 20
+	MaxAttachmentSize = 20 << 20
+)
+
+// cleanupGrace spares objects uploaded in the last day from the cleanup,
+// as their row may not be written yet.
+const cleanupGrace = 24 * time.Hour
+
+// maxFilenameLength is the length of the filename columns.
+const maxFilenameLength = 255
+
+// imageTypes are the content types of product images by extension.
+var imageTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+}
+
+// attachmentTypes are the content types of movement attachments by
+// extension: documents, spreadsheets and photos.
+var attachmentTypes = map[string]string{
+	".pdf":  "application/pdf",
+	".csv":  "text/csv",
+	".txt":  "text/plain",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+}
+
+var (
+	ErrImageType      = errors.New("image must be a PNG, JPEG, GIF or WebP file")
+	ErrAttachmentType = errors.New("attachment must be a PDF, CSV, text, XLSX, DOCX or image file")
+)
+
+// ContentType returns the content type of a stored file from the
+// extension of its key.
+func ContentType(key string) string {
+	if contentType, ok := attachmentTypes[strings.ToLower(filepath.Ext(key))]; ok {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// Library keeps product images and movement attachments.
+type Library struct {
+	store       storage.Store
+	fileService *database.FileService
+	// expiry is how long signed URLs stay valid.
+	expiry time.Duration
+}
+
+func NewLibrary(db *sql.DB, store storage.Store, expiry time.Duration) *Library {
+	return &Library{store: store, fileService: database.NewFileService(db), expiry: expiry}
+}
+
+// ProductImage returns the image of a product with a signed URL.
+func (l *Library) ProductImage(ctx context.Context, productID uuid.UUID) (*models.ProductImage, error) {
+	image, err := l.fileService.GetProductImage(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if image.URL, err = l.store.SignedURL(ctx, image.StorageKey, "", l.expiry); err != nil {
+		return nil, err
+	}
+	return image, nil
+}
+
+// SetProductImage stores data as the image of a product, replacing the one
+// it had. The image is stored under a new key each time, so a URL signed
+// for the old one never shows the new image.
+func (l *Library) SetProductImage(ctx context.Context, productID uuid.UUID, filename string, data []byte, uploadedBy uuid.UUID) (*models.ProductImage, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	contentType, ok := imageTypes[ext]
+	// The content must be what the extension says, since images are
+	// shown inline
+	if !ok || http.DetectContentType(data) != contentType {
+		return nil, ErrImageType
+	}
+
+	image := &models.ProductImage{
+		ProductID:   productID,
+		StorageKey:  fmt.Sprintf("%s%s/%s%s", storage.PrefixProducts, productID, uuid.New(), ext),
+		Filename:    cleanFilename(filename),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		UploadedBy:  &uploadedBy,
+	}
+	if err := l.store.Put(ctx, image.StorageKey, bytes.NewReader(data), image.Size, contentType); err != nil {
+		return nil, err
+	}
+	previous, err := l.fileService.SetProductImage(ctx, image)
+	if err != nil {
+		l.delete(ctx, image.StorageKey)
+		return nil, err
+	}
+	if previous != "" {
+		l.delete(ctx, previous)
+	}
+
+	if image.URL, err = l.store.SignedURL(ctx, image.StorageKey, "", l.expiry); err != nil {
+		return nil, err
+	}
+	return image, nil
+}
+
+// DeleteProductImage removes the image of a product.
+func (l *Library) DeleteProductImage(ctx context.Context, productID uuid.UUID) error {
+	key, err := l.fileService.DeleteProductImage(ctx, productID)
+	if err != nil {
+		return err
+	}
+	l.delete(ctx, key)
+	return nil
+}
+
+// Attachments returns the files attached to a stock movement with signed
+// URLs.
+func (l *Library) Attachments(ctx context.Context, movementID uuid.UUID) ([]models.MovementAttachment, error) {
+	attachments, err := l.fileService.GetMovementAttachments(ctx, movementID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range attachments {
+		if attachments[i].URL, err = l.store.SignedURL(ctx, attachments[i].StorageKey, attachments[i].Filename, l.expiry); err != nil {
+			return nil, err
+		}
+	}
+	return attachments, nil
+}
+
+// AddAttachment stores data as a file attached to a stock movement.
+func (l *Library) AddAttachment(ctx context.Context, movementID uuid.UUID, filename string, data []byte, uploadedBy uuid.UUID) (*models.MovementAttachment, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	contentType, ok := attachmentTypes[ext]
+	if !ok {
+		return nil, ErrAttachmentType
+	}
+
+	id := uuid.New()
+	attachment := &models.MovementAttachment{
+		ID:          id,
+		MovementID:  movementID,
+		StorageKey:  fmt.Sprintf("%s%s/%s%s", storage.PrefixAttachments, movementID, id, ext),
+		Filename:    cleanFilename(filename),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		UploadedBy:  &uploadedBy,
+	}
+	if err := l.store.Put(ctx, attachment.StorageKey, bytes.NewReader(data), attachment.Size, contentType); err != nil {
+		return nil, err
+	}
+	if err := l.fileService.CreateMovementAttachment(ctx, attachment); err != nil {
+		l.delete(ctx, attachment.StorageKey)
+		return nil, err
+	}
+
+	var err error
+	if attachment.URL, err = l.store.SignedURL(ctx, attachment.StorageKey, attachment.Filename, l.expiry); err != nil {
+		return nil, err
+	}
+	return attachment, nil
+}
+
+// DeleteAttachment removes a file attached to a stock movement.
+func (l *Library) DeleteAttachment(ctx context.Context, movementID, id uuid.UUID) error {
+	key, err := l.fileService.DeleteMovementAttachment(ctx, movementID, id)
+	if err != nil {
+		return err
+	}
+	l.delete(ctx, key)
+	return nil
+}
+
+// Cleanup deletes the attachments of stock movements that are gone, then
+// the product images, attachments and report artifacts in the object
+// store that no row refers to, such as those of deleted products, expired
+// reports and uploads that failed halfway.
+func (l *Library) Cleanup() error {
+	ctx := context.Background()
+	cutoff := time.Now().Add(-cleanupGrace)
+
+	orphaned, err := l.fileService.DeleteOrphanedAttachments(ctx, cutoff)
+	if err != nil {
+		return err
+	}
+	keys, err := l.fileService.StorageKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	deleted := 0
+	var firstErr error
+	for _, prefix := range []string{storage.PrefixProducts, storage.PrefixAttachments, storage.PrefixReports} {
+		n, err := storage.Sweep(ctx, l.store, prefix, cutoff, func(key string) bool { return keys[key] })
+		deleted += n
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if orphaned > 0 || deleted > 0 {
+		log.Printf("Storage cleanup removed %d orphaned attachments and %d unreferenced objects", orphaned, deleted)
+	}
+	return firstErr
+}
+
+// delete removes an object that is no longer recorded. An object it fails
+// to delete is left to the cleanup.
+func (l *Library) delete(ctx context.Context, key string) {
+	if err := l.store.Delete(ctx, key); err != nil {
+		log.Printf("Failed to delete %s from storage: %v", key, err)
+	}
+}
+
+// cleanFilename keeps the base name of an uploaded file, short enough for
+// the filename columns.
+func cleanFilename(filename string) string {
+	filename = strings.TrimSpace(filepath.Base(strings.ReplaceAll(filename, `\`, "/")))
+	if len(filename) > maxFilenameLength {
+		ext := filepath.Ext(filename)
+		// Cutting bytes may split a character, which is dropped
+		filename = strings.ToValidUTF8(filename[:maxFilenameLength-len(ext)], "") + ext
+	}
+	return filename
+}
@@ -0,0 +1,38 @@
+package files
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestContentType(t *testing.T) {
+	tests := map[string]string{
+		"attachments/1/2.PDF":  "application/pdf",
+		"products/1/2.jpeg":    "image/jpeg",
+		"attachments/1/2.html": "application/octet-stream",
+		"attachments/1/2":      "application/octet-stream",
+	}
+	for key, expected := range tests {
+		if got := ContentType(key); got != expected {
+			t.Errorf("%s: expected %s, got %s", key, expected, got)
+		}
+	}
+}
+
+func TestCleanFilename(t *testing.T) {
+	tests := map[string]string{
+		"  delivery note.pdf ":           "delivery note.pdf",
+		"../../etc/passwd":               "passwd",
+		`C:\Users\ops\Desktop\photo.jpg`: "photo.jpg",
+	}
+	for filename, expected := range tests {
+		if got := cleanFilename(filename); got != expected {
+			t.Errorf("%q: expected %q, got %q", filename, expected, got)
+		}
+	}
+
+	long := cleanFilename(strings.Repeat("é", 200) + ".pdf")
+	if len(long) > maxFilenameLength || !strings.HasSuffix(long, ".pdf") || !strings.HasPrefix(long, "éé") {
+		t.Errorf("unexpected shortened filename %q (%d bytes)", long, len(long))
+	}
+}
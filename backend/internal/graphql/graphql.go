@@ -0,0 +1,88 @@
+// Package graphql answers GraphQL queries for products, stock movements,
+// categories and the caller's notifications, with resolvers generated by
+// github.com/99designs/gqlgen from schema.graphqls. Relations that every
+// item of a list looks up go through per-request dataloaders.
+package graphql
+
+//go:generate go run github.com/99designs/gqlgen@v0.17.55 generate
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// MaxDepth is how deeply selections may nest, so a query following
+// relations back and forth cannot make the server do unbounded work.
+const MaxDepth = 8
+
+// Request is a GraphQL request as clients post it.
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Response is the result of a request. Data is missing when the request
+// could not be executed at all; otherwise fields that failed are null in
+// it and have an entry in Errors.
+type Response struct {
+	Data   interface{}   `json:"data,omitempty"`
+	Errors gqlerror.List `json:"errors,omitempty"`
+}
+
+// DepthLimit refuses operations whose selections nest more than MaxDepth
+// levels deep, counting fragments as the fields they select, as it does
+// queries that do not validate.
+type DepthLimit struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationContextMutator
+} = DepthLimit{}
+
+func (DepthLimit) ExtensionName() string {
+	return "DepthLimit"
+}
+
+func (DepthLimit) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (DepthLimit) MutateOperationContext(_ context.Context, rc *graphql.OperationContext) *gqlerror.Error {
+	if depth(rc.Operation.SelectionSet, 1) > MaxDepth {
+		err := gqlerror.Errorf("the query is nested more than %d levels deep", MaxDepth)
+		errcode.Set(err, errcode.ValidationFailed)
+		return err
+	}
+	return nil
+}
+
+// depth returns how deeply selections nest, the fields in them being at
+// level.
+func depth(selections ast.SelectionSet, level int) int {
+	deepest := 0
+	for _, selection := range selections {
+		var d int
+		switch selection := selection.(type) {
+		case *ast.Field:
+			d = level
+			if len(selection.SelectionSet) > 0 {
+				d = depth(selection.SelectionSet, level+1)
+			}
+		case *ast.InlineFragment:
+			d = depth(selection.SelectionSet, level)
+		case *ast.FragmentSpread:
+			if selection.Definition != nil {
+				d = depth(selection.Definition.SelectionSet, level)
+			}
+		}
+		if d > deepest {
+			deepest = d
+		}
+	}
+	return deepest
+}
@@ -0,0 +1,86 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/google/uuid"
+)
+
+// post answers query with a resolver that has no services, which is enough
+// for queries that are refused or only introspect the schema.
+func post(t *testing.T, query string) (int, Response) {
+	t.Helper()
+	resolver := NewResolver(nil, nil, nil)
+	server := handler.New(NewExecutableSchema(Config{Resolvers: resolver}))
+	server.AddTransport(transport.POST{})
+	server.Use(extension.Introspection{})
+	server.Use(DepthLimit{})
+
+	body, _ := json.Marshal(Request{Query: query})
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(resolver.WithRequest(context.Background(), uuid.New()))
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	var response Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response %q: %v", rec.Body.String(), err)
+	}
+	return rec.Code, response
+}
+
+func TestDepthLimit(t *testing.T) {
+	// Nine levels, following products and their categories back and forth
+	code, response := post(t, `{ products { category { products { category { products { category { products { category { name } } } } } } } } }`)
+	if code != http.StatusUnprocessableEntity || len(response.Errors) != 1 || !strings.Contains(response.Errors[0].Message, "nested more than 8 levels") {
+		t.Errorf("expected the query to be refused for its depth, got %d %+v", code, response)
+	}
+
+	// Fragments count as the fields they select
+	code, response = post(t, `query { products { ...Deep } }
+		fragment Deep on Product { category { products { category { products { category { products { category { name } } } } } } } }`)
+	if code != http.StatusUnprocessableEntity || len(response.Errors) != 1 {
+		t.Errorf("expected the query to be refused for its depth, got %d %+v", code, response)
+	}
+
+	code, response = post(t, `{ __schema { types { fields { type { ofType { ofType { name } } } } } } }`)
+	if code != http.StatusOK || len(response.Errors) != 0 || response.Data == nil {
+		t.Errorf("expected a shallower query to be answered, got %d %+v", code, response)
+	}
+}
+
+func TestInvalidQuery(t *testing.T) {
+	code, response := post(t, `{ products { name unknownField } }`)
+	if code != http.StatusUnprocessableEntity || len(response.Errors) != 1 || response.Data != nil {
+		t.Errorf("expected an unknown field to be refused, got %d %+v", code, response)
+	}
+}
+
+func TestPaging(t *testing.T) {
+	intPtr := func(n int) *int { return &n }
+
+	if page, limit, err := paging(nil, nil); err != nil || page != 1 || limit != defaultLimit {
+		t.Errorf("expected the defaults, got %d %d %v", page, limit, err)
+	}
+	if page, limit, err := paging(intPtr(3), intPtr(maxLimit)); err != nil || page != 3 || limit != maxLimit {
+		t.Errorf("expected page 3 of %d, got %d %d %v", maxLimit, page, limit, err)
+	}
+	for _, tt := range []struct{ page, limit *int }{
+		{intPtr(0), nil},
+		{nil, intPtr(0)},
+		{nil, intPtr(maxLimit + 1)},
+	} {
+		if _, _, err := paging(tt.page, tt.limit); err == nil {
+			t.Errorf("expected an error for page %v limit %v", tt.page, tt.limit)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package graphql
+
+import (
+	"context"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/graph-gophers/dataloader/v7"
+)
+
+// loaders batch the lookups of relations made while resolving one request,
+// so a list of movements loads its products in one query and a list of
+// products its categories in one, instead of one per item.
+type loaders struct {
+	products   *dataloader.Loader[uuid.UUID, *models.Product]
+	categories *dataloader.Loader[string, *models.Category]
+}
+
+func (r *Resolver) newLoaders() *loaders {
+	return &loaders{
+		products:   dataloader.NewBatchedLoader(r.loadProducts),
+		categories: dataloader.NewBatchedLoader(r.loadCategories),
+	}
+}
+
+// loadProducts loads the products with the given IDs; those there are none
+// of in the caller's organization are nil.
+func (r *Resolver) loadProducts(ctx context.Context, ids []uuid.UUID) []*dataloader.Result[*models.Product] {
+	products, err := r.productService.GetProductsByID(ctx, ids)
+	if err != nil {
+		return failAll[*models.Product](len(ids), resolverError("Failed to get products", err))
+	}
+	found := make(map[uuid.UUID]*models.Product, len(products))
+	for i := range products {
+		found[products[i].ID] = &products[i]
+	}
+	results := make([]*dataloader.Result[*models.Product], len(ids))
+	for i, id := range ids {
+		results[i] = &dataloader.Result[*models.Product]{Data: found[id]}
+	}
+	return results
+}
+
+// loadCategories loads the categories with the given names; those there are
+// none of are nil. The categories are few, so all of them are read.
+func (r *Resolver) loadCategories(ctx context.Context, names []string) []*dataloader.Result[*models.Category] {
+	categories, err := r.categoryService.GetCategories(ctx)
+	if err != nil {
+		return failAll[*models.Category](len(names), resolverError("Failed to get categories", err))
+	}
+	found := make(map[string]*models.Category, len(categories))
+	for i := range categories {
+		found[categories[i].Name] = &categories[i]
+	}
+	results := make([]*dataloader.Result[*models.Category], len(names))
+	for i, name := range names {
+		results[i] = &dataloader.Result[*models.Category]{Data: found[name]}
+	}
+	return results
+}
+
+func failAll[V any](n int, err error) []*dataloader.Result[V] {
+	results := make([]*dataloader.Result[V], n)
+	for i := range results {
+		results[i] = &dataloader.Result[V]{Error: err}
+	}
+	return results
+}
+
+// product returns a product, or nil if there is none in the caller's
+// organization.
+func product(ctx context.Context, id uuid.UUID) (*models.Product, error) {
+	return requestFrom(ctx).loaders.products.Load(ctx, id)()
+}
+
+// category returns the category named name, or nil if there is none.
+func category(ctx context.Context, name string) (*models.Category, error) {
+	return requestFrom(ctx).loaders.categories.Load(ctx, name)()
+}
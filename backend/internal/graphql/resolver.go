@@ -0,0 +1,128 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Lists are paged by page and limit like their v1 routes.
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+// Resolver resolves queries through the same services as the REST routes.
+// Requests must be prepared with WithRequest, which scopes them to the
+// caller.
+type Resolver struct {
+	productService      *database.ProductService
+	categoryService     *database.CategoryService
+	notificationService *database.NotificationService
+}
+
+func NewResolver(productService *database.ProductService, categoryService *database.CategoryService, notificationService *database.NotificationService) *Resolver {
+	return &Resolver{
+		productService:      productService,
+		categoryService:     categoryService,
+		notificationService: notificationService,
+	}
+}
+
+type requestKey struct{}
+
+// request is what resolvers share while answering one request: the caller,
+// and the loaders that batch the relations of the items of a list.
+type request struct {
+	userID  uuid.UUID
+	loaders *loaders
+}
+
+// WithRequest prepares ctx for answering a request of userID.
+func (r *Resolver) WithRequest(ctx context.Context, userID uuid.UUID) context.Context {
+	return context.WithValue(ctx, requestKey{}, &request{userID: userID, loaders: r.newLoaders()})
+}
+
+func requestFrom(ctx context.Context) *request {
+	return ctx.Value(requestKey{}).(*request)
+}
+
+// resolverError logs err and returns message, so that database errors are
+// not shown to clients.
+func resolverError(message string, err error) error {
+	log.Printf("GraphQL: %s: %v", message, err)
+	return errors.New(message)
+}
+
+// paging returns the page and limit of a list, with their defaults.
+func paging(page, limit *int) (int, int, error) {
+	p, l := 1, defaultLimit
+	if page != nil {
+		p = *page
+	}
+	if limit != nil {
+		l = *limit
+	}
+	if p < 1 {
+		return 0, 0, fmt.Errorf("page must be at least 1")
+	}
+	if l < 1 || l > maxLimit {
+		return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+	}
+	return p, l, nil
+}
+
+func (r *Resolver) products(ctx context.Context, search, category *string, lowStockOnly *bool, page, limit *int) ([]*models.Product, error) {
+	p, l, err := paging(page, limit)
+	if err != nil {
+		return nil, err
+	}
+	filter := models.ProductFilter{Page: p, Limit: l}
+	if search != nil {
+		filter.Search = *search
+	}
+	if category != nil {
+		filter.Category = *category
+	}
+	if lowStockOnly != nil {
+		filter.LowStockOnly = *lowStockOnly
+	}
+	products, _, err := r.productService.GetProducts(ctx, filter)
+	if err != nil {
+		return nil, resolverError("Failed to get products", err)
+	}
+	return pointers(products), nil
+}
+
+func (r *Resolver) movements(ctx context.Context, productID *uuid.UUID, reason *string, page, limit *int) ([]*models.StockMovement, error) {
+	p, l, err := paging(page, limit)
+	if err != nil {
+		return nil, err
+	}
+	filter := models.StockMovementFilter{ProductID: productID, Page: p, Limit: l}
+	if reason != nil && *reason != "" {
+		movementReason := models.MovementReason(*reason)
+		filter.Reason = &movementReason
+	}
+	movements, _, err := r.productService.GetStockMovements(ctx, filter)
+	if err != nil {
+		return nil, resolverError("Failed to get stock movements", err)
+	}
+	return pointers(movements), nil
+}
+
+// pointers returns pointers to the items of list, which gqlgen resolves
+// objects from.
+func pointers[T any](list []T) []*T {
+	result := make([]*T, len(list))
+	for i := range list {
+		result[i] = &list[i]
+	}
+	return result
+}
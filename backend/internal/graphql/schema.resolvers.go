@@ -0,0 +1,132 @@
+package graphql
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.55
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Products is the resolver for the products field.
+func (r *categoryResolver) Products(ctx context.Context, obj *models.Category, search *string, lowStockOnly *bool, page *int, limit *int) ([]*models.Product, error) {
+	return r.products(ctx, search, &obj.Name, lowStockOnly, page, limit)
+}
+
+// Type is the resolver for the type field.
+func (r *notificationResolver) Type(ctx context.Context, obj *models.Notification) (string, error) {
+	return string(obj.Type), nil
+}
+
+// Priority is the resolver for the priority field.
+func (r *notificationResolver) Priority(ctx context.Context, obj *models.Notification) (string, error) {
+	return string(obj.Priority), nil
+}
+
+// LowStock is the resolver for the lowStock field.
+func (r *productResolver) LowStock(ctx context.Context, obj *models.Product) (bool, error) {
+	return obj.Stock <= obj.MinimumThreshold, nil
+}
+
+// Category is the resolver for the category field.
+func (r *productResolver) Category(ctx context.Context, obj *models.Product) (*models.Category, error) {
+	return category(ctx, obj.Category)
+}
+
+// Movements is the resolver for the movements field.
+func (r *productResolver) Movements(ctx context.Context, obj *models.Product, reason *string, page *int, limit *int) ([]*models.StockMovement, error) {
+	return r.movements(ctx, &obj.ID, reason, page, limit)
+}
+
+// Products is the resolver for the products field.
+func (r *queryResolver) Products(ctx context.Context, search *string, category *string, lowStockOnly *bool, page *int, limit *int) ([]*models.Product, error) {
+	return r.products(ctx, search, category, lowStockOnly, page, limit)
+}
+
+// Product is the resolver for the product field.
+func (r *queryResolver) Product(ctx context.Context, id uuid.UUID) (*models.Product, error) {
+	return product(ctx, id)
+}
+
+// Movements is the resolver for the movements field.
+func (r *queryResolver) Movements(ctx context.Context, productID *uuid.UUID, reason *string, page *int, limit *int) ([]*models.StockMovement, error) {
+	return r.movements(ctx, productID, reason, page, limit)
+}
+
+// Movement is the resolver for the movement field.
+func (r *queryResolver) Movement(ctx context.Context, id uuid.UUID) (*models.StockMovement, error) {
+	found, err := r.productService.GetStockMovement(ctx, id)
+	if errors.Is(err, database.ErrNotFound) || errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, resolverError("Failed to get stock movement", err)
+	}
+	return found, nil
+}
+
+// Categories is the resolver for the categories field.
+func (r *queryResolver) Categories(ctx context.Context) ([]*models.Category, error) {
+	categories, err := r.categoryService.GetCategories(ctx)
+	if err != nil {
+		return nil, resolverError("Failed to get categories", err)
+	}
+	return pointers(categories), nil
+}
+
+// Notifications is the resolver for the notifications field.
+func (r *queryResolver) Notifications(ctx context.Context, isRead *bool, archived *bool, page *int, limit *int) ([]*models.Notification, error) {
+	p, l, err := paging(page, limit)
+	if err != nil {
+		return nil, err
+	}
+	userID := requestFrom(ctx).userID
+	notifications, _, err := r.notificationService.GetNotifications(models.NotificationFilter{
+		UserID:     &userID,
+		IsRead:     isRead,
+		IsArchived: archived,
+		Page:       p,
+		Limit:      l,
+	})
+	if err != nil {
+		return nil, resolverError("Failed to get notifications", err)
+	}
+	return pointers(notifications), nil
+}
+
+// Reason is the resolver for the reason field.
+func (r *stockMovementResolver) Reason(ctx context.Context, obj *models.StockMovement) (string, error) {
+	return string(obj.Reason), nil
+}
+
+// Product is the resolver for the product field.
+func (r *stockMovementResolver) Product(ctx context.Context, obj *models.StockMovement) (*models.Product, error) {
+	return product(ctx, obj.ProductID)
+}
+
+// Category returns CategoryResolver implementation.
+func (r *Resolver) Category() CategoryResolver { return &categoryResolver{r} }
+
+// Notification returns NotificationResolver implementation.
+func (r *Resolver) Notification() NotificationResolver { return &notificationResolver{r} }
+
+// Product returns ProductResolver implementation.
+func (r *Resolver) Product() ProductResolver { return &productResolver{r} }
+
+// Query returns QueryResolver implementation.
+func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
+
+// StockMovement returns StockMovementResolver implementation.
+func (r *Resolver) StockMovement() StockMovementResolver { return &stockMovementResolver{r} }
+
+type categoryResolver struct{ *Resolver }
+type notificationResolver struct{ *Resolver }
+type productResolver struct{ *Resolver }
+type queryResolver struct{ *Resolver }
+type stockMovementResolver struct{ *Resolver }
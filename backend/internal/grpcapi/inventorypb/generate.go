@@ -0,0 +1,5 @@
+// Package inventorypb holds the protobuf messages and gRPC stubs of the
+// Inventory service, generated from inventory.proto.
+package inventorypb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative inventory.proto
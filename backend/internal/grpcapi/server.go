@@ -0,0 +1,360 @@
+// Package grpcapi serves the Inventory gRPC service for internal
+// integrations, such as picking robots, that want stock operations without
+// the overhead of JSON over HTTP. Connections use mutual TLS: a client's
+// certificate names the RTIMS account it acts as.
+package grpcapi
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/grpcapi/inventorypb"
+	"rtims-backend/internal/models"
+	"rtims-backend/internal/notify"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// streamBuffer is how many stock events a stream may fall behind by before
+// it is ended.
+const streamBuffer = 256
+
+// TLSConfig loads the server certificate and requires clients to present a
+// certificate signed by the CA in clientCAFile.
+func TLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load gRPC certificate: %w", err)
+	}
+	pem, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gRPC client CA: %w", err)
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", clientCAFile)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// Server implements the Inventory service on the same services as the
+// REST API.
+type Server struct {
+	inventorypb.UnimplementedInventoryServer
+
+	productService      *database.ProductService
+	userService         *database.UserService
+	organizationService *database.OrganizationService
+	auditService        *database.AuditService
+	feed                *notify.StockFeed
+}
+
+func NewServer(db *sql.DB, cache *database.Cache, feed *notify.StockFeed) *Server {
+	return &Server{
+		productService:      database.NewCachedProductService(db, cache),
+		userService:         database.NewUserService(db),
+		organizationService: database.NewOrganizationService(db),
+		auditService:        database.NewAuditService(db),
+		feed:                feed,
+	}
+}
+
+// GRPCServer returns a gRPC server for s that accepts only clients with a
+// certificate tlsConfig verifies.
+func (s *Server) GRPCServer(tlsConfig *tls.Config) *grpc.Server {
+	server := grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(tlsConfig)),
+		grpc.UnaryInterceptor(s.authenticateUnary),
+		grpc.StreamInterceptor(s.authenticateStream),
+	)
+	inventorypb.RegisterInventoryServer(server, s)
+	return server
+}
+
+type callerKey struct{}
+
+// callerFrom returns the account a call was authenticated as.
+func callerFrom(ctx context.Context) *models.User {
+	user, _ := ctx.Value(callerKey{}).(*models.User)
+	return user
+}
+
+// authenticate finds the account named by the common name of the client
+// certificate, and scopes the call to its organization. The account and
+// its organization must be active, as for logging in.
+func (s *Server) authenticate(ctx context.Context) (context.Context, error) {
+	email, err := certificateName(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	user, err := s.userService.GetUserByEmail(ctx, strings.ToLower(email))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, status.Errorf(codes.PermissionDenied, "no account for certificate %q", email)
+		}
+		return nil, internalError("failed to get account", err)
+	}
+	if !user.IsActive {
+		return nil, status.Error(codes.PermissionDenied, "account is deactivated")
+	}
+	organizationID := user.OrganizationID
+	if organizationID == uuid.Nil {
+		organizationID = database.DefaultOrganizationID
+	}
+	organization, err := s.organizationService.GetOrganization(ctx, organizationID)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	if !organization.IsActive {
+		return nil, status.Error(codes.PermissionDenied, "organization is deactivated")
+	}
+
+	ctx = context.WithValue(ctx, callerKey{}, user)
+	return database.WithOrganization(ctx, organizationID), nil
+}
+
+// certificateName returns the common name of the verified client
+// certificate of a call.
+func certificateName(ctx context.Context) (string, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", errors.New("no peer")
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return "", errors.New("a verified client certificate is required")
+	}
+	name := info.State.VerifiedChains[0][0].Subject.CommonName
+	if name == "" {
+		return "", errors.New("the client certificate has no common name")
+	}
+	return name, nil
+}
+
+func (s *Server) authenticateUnary(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) authenticateStream(srv interface{}, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.authenticate(stream.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+}
+
+// authenticatedStream is a stream whose context carries its caller.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *Server) GetProduct(ctx context.Context, req *inventorypb.GetProductRequest) (*inventorypb.Product, error) {
+	id, err := parseID("id", req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	product, err := s.productService.GetProduct(ctx, id)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return productMessage(product), nil
+}
+
+func (s *Server) AdjustStock(ctx context.Context, req *inventorypb.AdjustStockRequest) (*inventorypb.AdjustStockResponse, error) {
+	id, err := parseID("product_id", req.GetProductId())
+	if err != nil {
+		return nil, err
+	}
+	if req.GetChange() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "change must not be zero")
+	}
+	reason, ok := movementReasons[req.GetReason()]
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, "reason is required")
+	}
+
+	caller := callerFrom(ctx)
+	update, err := s.productService.UpdateProductStock(ctx, id, int(req.GetChange()), reason, caller.ID, req.GetNotes())
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	// The REST route records the same entry
+	auditLog := &models.AuditLog{
+		ID:        uuid.New(),
+		TableName: "products",
+		RecordID:  id,
+		Action:    models.ActionUpdate,
+		OldValues: map[string]interface{}{"stock": update.PreviousStock},
+		NewValues: map[string]interface{}{"stock": update.Product.Stock},
+		ChangedBy: caller.ID,
+		ChangedAt: time.Now(),
+		IPAddress: peerAddress(ctx),
+		UserAgent: "grpc",
+	}
+	if err := s.auditService.Record(ctx, auditLog); err != nil {
+		log.Printf("Failed to create audit log: %v", err)
+	}
+
+	return &inventorypb.AdjustStockResponse{
+		Movement: movementMessage(&update.Movement),
+		Product:  productMessage(&update.Product),
+		LowStock: update.LowStock,
+	}, nil
+}
+
+func (s *Server) StreamStockEvents(req *inventorypb.StreamStockEventsRequest, stream inventorypb.Inventory_StreamStockEventsServer) error {
+	products := map[uuid.UUID]bool{}
+	for _, raw := range req.GetProductIds() {
+		id, err := parseID("product_ids", raw)
+		if err != nil {
+			return err
+		}
+		products[id] = true
+	}
+	organizationID, _ := database.OrganizationFrom(stream.Context())
+
+	events, unsubscribe := s.feed.Subscribe(streamBuffer)
+	defer unsubscribe()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "the stream fell behind; reconnect and read current stock with GetProduct")
+			}
+			// Events queued before they carried an organization are
+			// from the default one
+			eventOrganization := event.OrganizationID
+			if eventOrganization == uuid.Nil {
+				eventOrganization = database.DefaultOrganizationID
+			}
+			if eventOrganization != organizationID || len(products) > 0 && !products[event.ProductID] {
+				continue
+			}
+			if err := stream.Send(eventMessage(event)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func parseID(field, value string) (uuid.UUID, error) {
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, status.Errorf(codes.InvalidArgument, "%s is not a valid ID", field)
+	}
+	return id, nil
+}
+
+func peerAddress(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+// statusError maps a service error to a gRPC status.
+func statusError(err error) error {
+	switch {
+	case errors.Is(err, database.ErrNotFound), errors.Is(err, sql.ErrNoRows):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, database.ErrInsufficientStock):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, database.ErrUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return internalError("request failed", err)
+}
+
+// internalError logs err and hides it from the client.
+func internalError(message string, err error) error {
+	log.Printf("gRPC: %s: %v", message, err)
+	return status.Error(codes.Internal, message)
+}
+
+var movementReasons = map[inventorypb.MovementReason]models.MovementReason{
+	inventorypb.MovementReason_MOVEMENT_REASON_PURCHASE:   models.ReasonPurchase,
+	inventorypb.MovementReason_MOVEMENT_REASON_SALE:       models.ReasonSale,
+	inventorypb.MovementReason_MOVEMENT_REASON_ADJUSTMENT: models.ReasonAdjustment,
+	inventorypb.MovementReason_MOVEMENT_REASON_RETURN:     models.ReasonReturn,
+	inventorypb.MovementReason_MOVEMENT_REASON_DAMAGE:     models.ReasonDamage,
+	inventorypb.MovementReason_MOVEMENT_REASON_TRANSFER:   models.ReasonTransfer,
+}
+
+func reasonMessage(reason models.MovementReason) inventorypb.MovementReason {
+	for message, r := range movementReasons {
+		if r == reason {
+			return message
+		}
+	}
+	return inventorypb.MovementReason_MOVEMENT_REASON_UNSPECIFIED
+}
+
+func productMessage(product *models.Product) *inventorypb.Product {
+	return &inventorypb.Product{
+		Id:               product.ID.String(),
+		Name:             product.Name,
+		Sku:              product.SKU,
+		Stock:            int32(product.Stock),
+		Price:            product.Price,
+		Category:         product.Category,
+		MinimumThreshold: int32(product.MinimumThreshold),
+		UpdatedAt:        timestamppb.New(product.UpdatedAt),
+	}
+}
+
+func movementMessage(movement *models.StockMovement) *inventorypb.StockMovement {
+	return &inventorypb.StockMovement{
+		Id:        movement.ID.String(),
+		ProductId: movement.ProductID.String(),
+		Change:    int32(movement.Change),
+		Reason:    reasonMessage(movement.Reason),
+		CreatedBy: movement.CreatedBy.String(),
+		CreatedAt: timestamppb.New(movement.CreatedAt),
+		Notes:     movement.Notes,
+	}
+}
+
+func eventMessage(event models.StockUpdatedEvent) *inventorypb.StockEvent {
+	return &inventorypb.StockEvent{
+		ProductId:        event.ProductID.String(),
+		Name:             event.Name,
+		Sku:              event.SKU,
+		Stock:            int32(event.Stock),
+		MinimumThreshold: int32(event.MinimumThreshold),
+		LowStock:         event.LowStock,
+		ChangedBy:        event.ChangedBy.String(),
+	}
+}
@@ -0,0 +1,109 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/grpcapi/inventorypb"
+	"rtims-backend/internal/models"
+	"rtims-backend/internal/notify"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeStream collects what a server streams, and signals each send.
+type fakeStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent chan *inventorypb.StockEvent
+}
+
+func (s *fakeStream) Context() context.Context { return s.ctx }
+
+func (s *fakeStream) Send(event *inventorypb.StockEvent) error {
+	s.sent <- event
+	return nil
+}
+
+func TestStreamStockEvents(t *testing.T) {
+	feed := notify.NewStockFeed()
+	s := &Server{feed: feed}
+	organizationID, otherOrganization := uuid.New(), uuid.New()
+	watched, unwatched := uuid.New(), uuid.New()
+
+	ctx, cancel := context.WithCancel(database.WithOrganization(context.Background(), organizationID))
+	stream := &fakeStream{ctx: ctx, sent: make(chan *inventorypb.StockEvent, 10)}
+	done := make(chan error)
+	go func() {
+		done <- s.StreamStockEvents(&inventorypb.StreamStockEventsRequest{ProductIds: []string{watched.String()}}, stream)
+	}()
+
+	// Wait for the stream to subscribe: events before that are not its own
+	for subscribed := false; !subscribed; {
+		feed.Publish(models.StockUpdatedEvent{ProductID: watched, Stock: -1, OrganizationID: organizationID})
+		select {
+		case <-stream.sent:
+			subscribed = true
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	feed.Publish(models.StockUpdatedEvent{ProductID: unwatched, Stock: 1, OrganizationID: organizationID})
+	feed.Publish(models.StockUpdatedEvent{ProductID: watched, Stock: 2, OrganizationID: otherOrganization})
+	feed.Publish(models.StockUpdatedEvent{ProductID: watched, Stock: 3, OrganizationID: organizationID, LowStock: true})
+
+	for event := range stream.sent {
+		if event.Stock == -1 {
+			continue
+		}
+		if event.ProductId != watched.String() || event.Stock != 3 || !event.LowStock {
+			t.Errorf("expected only the watched product in this organization, got %+v", event)
+		}
+		break
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("expected the stream to end cleanly when the client leaves, got %v", err)
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	tests := map[error]codes.Code{
+		fmt.Errorf("product %w", database.ErrNotFound): codes.NotFound,
+		database.ErrInsufficientStock:                  codes.FailedPrecondition,
+		database.ErrUnavailable:                        codes.Unavailable,
+		errors.New("connection reset"):                 codes.Internal,
+	}
+	for err, want := range tests {
+		if got := status.Code(statusError(err)); got != want {
+			t.Errorf("%v: expected %v, got %v", err, want, got)
+		}
+	}
+	if message := status.Convert(statusError(errors.New("pq: secret detail"))).Message(); message != "request failed" {
+		t.Errorf("expected internal errors to be hidden, got %q", message)
+	}
+}
+
+func TestMovementReasons(t *testing.T) {
+	for message, reason := range movementReasons {
+		if got := reasonMessage(reason); got != message {
+			t.Errorf("%s: expected %v, got %v", reason, message, got)
+		}
+	}
+	if got := reasonMessage("lost"); got != inventorypb.MovementReason_MOVEMENT_REASON_UNSPECIFIED {
+		t.Errorf("expected an unknown reason to be unspecified, got %v", got)
+	}
+}
+
+func TestTLSConfigRequiresClientCA(t *testing.T) {
+	if _, err := TLSConfig("missing.crt", "missing.key", "missing-ca.crt"); err == nil {
+		t.Error("expected missing certificates to be an error")
+	}
+}
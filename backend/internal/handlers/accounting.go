@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"rtims-backend/internal/accounting"
+	"rtims-backend/internal/apierror"
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/middleware"
+	"rtims-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AccountingHandler exports the journals of the admin's organization for
+// QuickBooks or Xero.
+type AccountingHandler struct {
+	accountingService *database.AccountingService
+	auditService      *database.AuditService
+	exporter          *accounting.Exporter
+}
+
+func NewAccountingHandler(db *sql.DB, exporter *accounting.Exporter) *AccountingHandler {
+	return &AccountingHandler{
+		accountingService: database.NewAccountingService(db),
+		auditService:      database.NewAuditService(db),
+		exporter:          exporter,
+	}
+}
+
+func (h *AccountingHandler) GetExports(c *gin.Context) {
+	exports, err := h.accountingService.GetAccountingExports(c.Request.Context())
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get accounting exports", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, exports)
+}
+
+// CreateExport exports the journal of the period since the last export
+// through the requested day, and locks it.
+func (h *AccountingHandler) CreateExport(c *gin.Context) {
+	var req models.CreateAccountingExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+	through, _ := time.Parse("2006-01-02", req.Through)
+
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	export, err := h.exporter.Export(c.Request.Context(), middleware.GetOrganization(c), req.Format, through, &userID)
+	if err != nil {
+		if errors.Is(err, accounting.ErrPeriodNotOver) {
+			apierror.Respond(c, apierror.ErrPeriodNotOver.Wrap(err))
+			return
+		}
+		apierror.Respond(c, apierror.Failed("Failed to export accounting journal", err))
+		return
+	}
+
+	auditLog := &models.AuditLog{
+		ID:        uuid.New(),
+		TableName: "accounting_exports",
+		RecordID:  export.ID,
+		Action:    models.ActionCreate,
+		NewValues: map[string]interface{}{
+			"format":       export.Format,
+			"period_start": export.PeriodStart,
+			"period_end":   export.PeriodEnd,
+			"journals":     export.Journals,
+			"debits":       export.Debits,
+		},
+		ChangedBy: userID,
+		ChangedAt: time.Now(),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+	}
+	middleware.MarkAudited(c)
+	if err := h.auditService.Record(c.Request.Context(), auditLog); err != nil {
+		log.Printf("Failed to create audit log: %v", err)
+	}
+
+	c.JSON(http.StatusCreated, export)
+}
+
+// DownloadExport returns the file of an export, to import into the
+// accounting system.
+func (h *AccountingHandler) DownloadExport(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid export ID"))
+		return
+	}
+
+	export, err := h.accountingService.GetAccountingExport(c.Request.Context(), id)
+	if err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrExportNotFound, err))
+		return
+	}
+
+	contentType := "text/csv"
+	if export.Format == models.AccountingQuickBooksIIF {
+		contentType = "text/plain"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", export.Filename))
+	c.Data(http.StatusOK, contentType, export.Content)
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"rtims-backend/internal/activity"
+	"rtims-backend/internal/apierror"
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/i18n"
+	"rtims-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ActivityFeedHandler serves the activity feed of the user's organization.
+type ActivityFeedHandler struct {
+	auditService *database.AuditService
+}
+
+func NewActivityFeedHandler(db *sql.DB) *ActivityFeedHandler {
+	return &ActivityFeedHandler{auditService: database.NewAuditService(db)}
+}
+
+// GetActivityFeed returns a page of the changes to products and categories
+// and the stock movements of the organization, newest first, each said in
+// a sentence in the language of Accept-Language. New entries are pushed
+// over the WebSocket as activity events.
+func (h *ActivityFeedHandler) GetActivityFeed(c *gin.Context) {
+	var filter models.ActivityFeedFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+	if filter.StartDate != nil && filter.EndDate != nil && filter.StartDate.After(*filter.EndDate) {
+		apierror.Respond(c, apierror.BadRequest("start_date must be before end_date"))
+		return
+	}
+
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = 20
+	}
+	if filter.Limit > 100 {
+		filter.Limit = 100
+	}
+
+	entries, total, err := h.auditService.GetActivityFeed(c.Request.Context(), filter)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get activity feed", err))
+		return
+	}
+	activity.DescribeAll(entries, i18n.FromAcceptLanguage(c.GetHeader("Accept-Language")))
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"pagination": gin.H{
+			"page":  filter.Page,
+			"limit": filter.Limit,
+			"total": total,
+			"pages": (total + filter.Limit - 1) / filter.Limit,
+		},
+	})
+}
@@ -1,22 +1,37 @@
 package handlers
 
 import (
+	"archive/zip"
+	"context"
 	"database/sql"
-	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"mime"
 	"net/http"
+	"path"
+	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 	"time"
 
+	"rtims-backend/internal/apierror"
+	"rtims-backend/internal/backup"
+	"rtims-backend/internal/buildinfo"
+	"rtims-backend/internal/dashboard"
 	"rtims-backend/internal/database"
 	"rtims-backend/internal/models"
 	"rtims-backend/internal/middleware"
+	"rtims-backend/internal/reports"
+	"rtims-backend/internal/settings"
+	"rtims-backend/internal/websocket"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
-	"github.com/jung-kurt/gofpdf"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -25,20 +40,36 @@ var now = time.Now()
 type AdminHandler struct {
 	userService     *database.UserService
 	categoryService *database.CategoryService
+	productService  *database.ProductService
 	dashboardService *database.DashboardService
+	snapshotService *database.SnapshotService
 	settingsService *database.SettingsService
 	auditService    *database.AuditService
+	reportService   *database.ReportService
+	pendingDeletions *database.PendingDeletionService
 	db              *sql.DB
+	hub             *websocket.Hub
+	reportQueue     *reports.Queue
+	backups         *backup.Runner
+	redisClient     *redis.Client
 }
 
-func NewAdminHandler(db *sql.DB) *AdminHandler {
+func NewAdminHandler(db *sql.DB, cache *database.Cache, redisClient *redis.Client, hub *websocket.Hub, reportQueue *reports.Queue, backups *backup.Runner) *AdminHandler {
 	return &AdminHandler{
 		userService:     database.NewUserService(db),
 		categoryService: database.NewCategoryService(db),
-		dashboardService: database.NewDashboardService(db),
+		productService:  database.NewCachedProductService(db, cache),
+		dashboardService: database.NewCachedDashboardService(db, cache),
+		snapshotService: database.NewSnapshotService(db),
 		settingsService: database.NewSettingsService(db),
 		auditService:    database.NewAuditService(db),
+		reportService:   database.NewReportService(db),
+		pendingDeletions: database.NewPendingDeletionService(db),
 		db:              db,
+		hub:             hub,
+		reportQueue:     reportQueue,
+		backups:         backups,
+		redisClient:     redisClient,
 	}
 }
 
@@ -71,15 +102,15 @@ func createAuditLog(c *gin.Context, tableName string, recordID uuid.UUID, action
 	// Note: This would be handled by the audit middleware in production
 	// but keeping this helper for specific audit logging needs
 	// For now, we'll use the audit service to log the action
-	if err := h.auditService.CreateAuditLog(auditLog); err != nil {
+	if err := h.auditService.CreateAuditLog(c.Request.Context(), auditLog); err != nil {
 		log.Printf("Failed to create audit log: %v", err)
 	}
 }
 
 func (h *AdminHandler) GetDashboardStats(c *gin.Context) {
-	stats, err := h.dashboardService.GetStats()
+	stats, err := h.dashboardService.GetStats(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get dashboard stats: " + err.Error()})
+		apierror.Respond(c, apierror.Failed("Failed to get dashboard stats", err))
 		return
 	}
 
@@ -87,15 +118,45 @@ func (h *AdminHandler) GetDashboardStats(c *gin.Context) {
 }
 
 func (h *AdminHandler) GetDashboardAlerts(c *gin.Context) {
-	alerts, err := h.dashboardService.GetAlerts()
+	alerts, err := h.dashboardService.GetAlerts(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get dashboard alerts: " + err.Error()})
+		apierror.Respond(c, apierror.Failed("Failed to get dashboard alerts", err))
 		return
 	}
 
 	c.JSON(http.StatusOK, alerts)
 }
 
+// GetDashboardTrends returns a daily time series of one dashboard metric,
+// e.g. ?metric=stock_value&period=30d, for sparklines.
+func (h *AdminHandler) GetDashboardTrends(c *gin.Context) {
+	metric, err := dashboard.ParseMetric(c.DefaultQuery("metric", string(models.TrendStockValue)))
+	if err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+
+	period := c.DefaultQuery("period", "30d")
+	days, err := dashboard.ParsePeriod(period)
+	if err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+
+	since := time.Now().AddDate(0, 0, 1-days)
+	points, err := h.snapshotService.GetTrend(c.Request.Context(), metric, since)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get dashboard trends", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"metric": metric,
+		"period": period,
+		"points": points,
+	})
+}
+
 func (h *AdminHandler) GetUsers(c *gin.Context) {
 	// Parse query parameters
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
@@ -122,9 +183,9 @@ func (h *AdminHandler) GetUsers(c *gin.Context) {
 		IsActive: isActive,
 	}
 
-	users, total, err := h.userService.GetUsers(filter)
+	users, total, err := h.userService.GetUsers(c.Request.Context(), filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get users: " + err.Error()})
+		apierror.Respond(c, apierror.Failed("Failed to get users", err))
 		return
 	}
 
@@ -142,34 +203,34 @@ func (h *AdminHandler) GetUsers(c *gin.Context) {
 func (h *AdminHandler) CreateUser(c *gin.Context) {
 	var req models.CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, apierror.Invalid(err))
 		return
 	}
 
 	// Validate input
 	if req.Name == "" || req.Email == "" || req.Password == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Name, email, and password are required"})
+		apierror.Respond(c, apierror.BadRequest("Name, email, and password are required"))
 		return
 	}
 
 	// Get current user for audit logging
 	userID, _, err := middleware.GetCurrentUser(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apierror.Respond(c, apierror.ErrUnauthenticated)
 		return
 	}
 
 	// Check if user already exists
-	existingUser, err := h.userService.GetUserByEmail(req.Email)
+	existingUser, err := h.userService.GetUserByEmail(c.Request.Context(), req.Email)
 	if err == nil && existingUser != nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "User with this email already exists"})
+		apierror.Respond(c, apierror.ErrDuplicateEmail)
 		return
 	}
 
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		apierror.Respond(c, apierror.Failed("Failed to hash password", err))
 		return
 	}
 
@@ -185,9 +246,9 @@ func (h *AdminHandler) CreateUser(c *gin.Context) {
 		UpdatedAt: time.Now(),
 	}
 
-	err = h.userService.CreateUser(user)
+	err = h.userService.CreateUser(c.Request.Context(), user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user: " + err.Error()})
+		apierror.Respond(c, apierror.Failed("Failed to create user", err))
 		return
 	}
 
@@ -205,7 +266,8 @@ func (h *AdminHandler) CreateUser(c *gin.Context) {
 		UserAgent:  c.GetHeader("User-Agent"),
 	}
 
-	err = h.auditService.CreateAuditLog(auditLog)
+	middleware.MarkAudited(c)
+	err = h.auditService.Record(c.Request.Context(), auditLog)
 	if err != nil {
 		// Log error but don't fail the request
 		log.Printf("Failed to create audit log: %v", err)
@@ -217,27 +279,27 @@ func (h *AdminHandler) CreateUser(c *gin.Context) {
 func (h *AdminHandler) UpdateUser(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		apierror.Respond(c, apierror.BadRequest("Invalid user ID"))
 		return
 	}
 
 	var req models.UpdateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, apierror.Invalid(err))
 		return
 	}
 
 	// Get current user for audit logging
 	userID, _, err := middleware.GetCurrentUser(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apierror.Respond(c, apierror.ErrUnauthenticated)
 		return
 	}
 
 	// Get existing user from database
-	oldUser, err := h.userService.GetUser(id)
+	oldUser, err := h.userService.GetUser(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		apierror.Respond(c, apierror.Missing(apierror.ErrUserNotFound, err))
 		return
 	}
 
@@ -257,16 +319,16 @@ func (h *AdminHandler) UpdateUser(c *gin.Context) {
 	}
 
 	// Update user in database
-	err = h.userService.UpdateUser(id, updates)
+	err = h.userService.UpdateUser(c.Request.Context(), id, updates)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user: " + err.Error()})
+		apierror.Respond(c, apierror.Failed("Failed to update user", err))
 		return
 	}
 
 	// Get updated user
-	user, err := h.userService.GetUser(id)
+	user, err := h.userService.GetUser(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get updated user: " + err.Error()})
+		apierror.Respond(c, apierror.Failed("Failed to get updated user", err))
 		return
 	}
 
@@ -284,7 +346,8 @@ func (h *AdminHandler) UpdateUser(c *gin.Context) {
 		UserAgent:  c.GetHeader("User-Agent"),
 	}
 
-	err = h.auditService.CreateAuditLog(auditLog)
+	middleware.MarkAudited(c)
+	err = h.auditService.Record(c.Request.Context(), auditLog)
 	if err != nil {
 		log.Printf("Failed to create audit log: %v", err)
 	}
@@ -295,46 +358,55 @@ func (h *AdminHandler) UpdateUser(c *gin.Context) {
 func (h *AdminHandler) DeleteUser(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		apierror.Respond(c, apierror.BadRequest("Invalid user ID"))
 		return
 	}
 
 	// Get current user for audit logging
 	userID, _, err := middleware.GetCurrentUser(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apierror.Respond(c, apierror.ErrUnauthenticated)
 		return
 	}
 
 	// Get user data for audit log before deletion
-	oldUser, err := h.userService.GetUser(id)
+	oldUser, err := h.userService.GetUser(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		apierror.Respond(c, apierror.Missing(apierror.ErrUserNotFound, err))
 		return
 	}
 
-	// Delete user from database
-	err = h.userService.DeleteUser(id)
+	// The deletion can be undone until the undo window passes
+	if scheduleDeletion(c, h.pendingDeletions, h.auditService, models.DeletionUsers, id, userID) {
+		return
+	}
+
+	// Anonymize the user; their movements and audit entries still
+	// reference the row
+	err = h.userService.AnonymizeUser(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user: " + err.Error()})
+		apierror.Respond(c, apierror.Missing(apierror.ErrUserNotFound, err))
 		return
 	}
+	database.NewRecentProducts(h.redisClient).Clear(c.Request.Context(), id)
 
-	// Create audit log
+	// Create audit log. It names no one, as the user's name and email
+	// were just scrubbed from the audit trail
 	auditLog := &models.AuditLog{
 		ID:         uuid.New(),
 		TableName:  "users",
 		RecordID:   id,
 		Action:     models.ActionDelete,
-		OldValues:  map[string]interface{}{"name": oldUser.Name, "email": oldUser.Email, "role": oldUser.Role, "is_active": oldUser.IsActive},
-		NewValues:  nil,
+		OldValues:  map[string]interface{}{"role": oldUser.Role, "is_active": oldUser.IsActive},
+		NewValues:  map[string]interface{}{"anonymized": true},
 		ChangedBy:  userID,
 		ChangedAt:  time.Now(),
 		IPAddress:  c.ClientIP(),
 		UserAgent:  c.GetHeader("User-Agent"),
 	}
 
-	err = h.auditService.CreateAuditLog(auditLog)
+	middleware.MarkAudited(c)
+	err = h.auditService.Record(c.Request.Context(), auditLog)
 	if err != nil {
 		log.Printf("Failed to create audit log: %v", err)
 	}
@@ -342,10 +414,136 @@ func (h *AdminHandler) DeleteUser(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
 }
 
+// ExportUser downloads the personal data held about a user, as a zip of
+// one JSON file per section, for data subject access requests. Exports
+// are audited.
+func (h *AdminHandler) ExportUser(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid user ID"))
+		return
+	}
+
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	// Only users of the admin's organization can be exported
+	if _, err := h.userService.GetUser(c.Request.Context(), id); err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrUserNotFound, err))
+		return
+	}
+
+	sections, err := h.userService.ExportUserData(c.Request.Context(), id)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to export user data", err))
+		return
+	}
+
+	auditLog := &models.AuditLog{
+		ID:        uuid.New(),
+		TableName: "users",
+		RecordID:  id,
+		Action:    models.ActionView,
+		NewValues: map[string]interface{}{"action": "personal_data_exported"},
+		ChangedBy: userID,
+		ChangedAt: time.Now(),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+	}
+	middleware.MarkAudited(c)
+	if err := h.auditService.Record(c.Request.Context(), auditLog); err != nil {
+		log.Printf("Failed to create audit log: %v", err)
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=user-%s-export.zip", id))
+	c.Status(http.StatusOK)
+
+	archive := zip.NewWriter(c.Writer)
+	for _, section := range sections {
+		file, err := archive.Create(section.Name + ".json")
+		if err != nil {
+			log.Printf("Failed to write user export %s: %v", id, err)
+			return
+		}
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(section.Rows); err != nil {
+			log.Printf("Failed to write user export %s: %v", id, err)
+			return
+		}
+	}
+	if err := archive.Close(); err != nil {
+		log.Printf("Failed to write user export %s: %v", id, err)
+	}
+}
+
+// activityPeriod is the period of a user's activity when no start date is
+// given.
+const activityPeriod = 90 * 24 * time.Hour
+
+// GetUserActivity returns a user's audited actions, login history and stock
+// movements as one timeline with counts, for performance reviews and
+// incident investigations.
+func (h *AdminHandler) GetUserActivity(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid user ID"))
+		return
+	}
+
+	var filter models.ActivityFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+
+	to := time.Now()
+	if filter.EndDate != nil {
+		to = *filter.EndDate
+	}
+	from := to.Add(-activityPeriod)
+	if filter.StartDate != nil {
+		from = *filter.StartDate
+	}
+	if from.After(to) {
+		apierror.Respond(c, apierror.BadRequest("start_date must be before end_date"))
+		return
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = 100
+	}
+	if filter.Limit > 500 {
+		filter.Limit = 500
+	}
+
+	// Only users of the admin's organization can be looked into
+	if _, err := h.userService.GetUser(c.Request.Context(), id); err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrUserNotFound, err))
+		return
+	}
+
+	activity, err := h.userService.GetUserActivity(c.Request.Context(), id, from, to, filter.Limit)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get user activity", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, activity)
+}
+
 func (h *AdminHandler) GetCategories(c *gin.Context) {
-	categories, err := h.categoryService.GetCategories()
+	version, err := h.categoryService.GetCategoriesVersion(c.Request.Context())
+	if notModified(c, version, err) {
+		return
+	}
+
+	categories, err := h.categoryService.GetCategories(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get categories: " + err.Error()})
+		apierror.Respond(c, apierror.Failed("Failed to get categories", err))
 		return
 	}
 
@@ -355,20 +553,20 @@ func (h *AdminHandler) GetCategories(c *gin.Context) {
 func (h *AdminHandler) CreateCategory(c *gin.Context) {
 	var req models.CreateCategoryRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, apierror.Invalid(err))
 		return
 	}
 
 	// Validate input
 	if req.Name == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Category name is required"})
+		apierror.Respond(c, apierror.BadRequest("Category name is required"))
 		return
 	}
 
 	// Get current user for audit logging
 	userID, _, err := middleware.GetCurrentUser(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apierror.Respond(c, apierror.ErrUnauthenticated)
 		return
 	}
 
@@ -380,9 +578,9 @@ func (h *AdminHandler) CreateCategory(c *gin.Context) {
 		CreatedAt:   time.Now(),
 	}
 
-	err = h.categoryService.CreateCategory(category)
+	err = h.categoryService.CreateCategory(c.Request.Context(), category)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create category: " + err.Error()})
+		apierror.Respond(c, apierror.Failed("Failed to create category", err))
 		return
 	}
 
@@ -400,7 +598,8 @@ func (h *AdminHandler) CreateCategory(c *gin.Context) {
 		UserAgent:  c.GetHeader("User-Agent"),
 	}
 
-	err = h.auditService.CreateAuditLog(auditLog)
+	middleware.MarkAudited(c)
+	err = h.auditService.Record(c.Request.Context(), auditLog)
 	if err != nil {
 		log.Printf("Failed to create audit log: %v", err)
 	}
@@ -411,27 +610,27 @@ func (h *AdminHandler) CreateCategory(c *gin.Context) {
 func (h *AdminHandler) UpdateCategory(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		apierror.Respond(c, apierror.BadRequest("Invalid category ID"))
 		return
 	}
 
 	var req models.UpdateCategoryRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, apierror.Invalid(err))
 		return
 	}
 
 	// Get current user for audit logging
 	userID, _, err := middleware.GetCurrentUser(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apierror.Respond(c, apierror.ErrUnauthenticated)
 		return
 	}
 
 	// Get existing category from database
-	oldCategory, err := h.categoryService.GetCategory(id)
+	oldCategory, err := h.categoryService.GetCategory(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		apierror.Respond(c, apierror.Missing(apierror.ErrCategoryNotFound, err))
 		return
 	}
 
@@ -445,16 +644,16 @@ func (h *AdminHandler) UpdateCategory(c *gin.Context) {
 	}
 
 	// Update category in database
-	err = h.categoryService.UpdateCategory(id, updates)
+	err = h.categoryService.UpdateCategory(c.Request.Context(), id, updates)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update category: " + err.Error()})
+		apierror.Respond(c, apierror.Failed("Failed to update category", err))
 		return
 	}
 
 	// Get updated category
-	category, err := h.categoryService.GetCategory(id)
+	category, err := h.categoryService.GetCategory(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get updated category: " + err.Error()})
+		apierror.Respond(c, apierror.Failed("Failed to get updated category", err))
 		return
 	}
 
@@ -472,7 +671,8 @@ func (h *AdminHandler) UpdateCategory(c *gin.Context) {
 		UserAgent:  c.GetHeader("User-Agent"),
 	}
 
-	err = h.auditService.CreateAuditLog(auditLog)
+	middleware.MarkAudited(c)
+	err = h.auditService.Record(c.Request.Context(), auditLog)
 	if err != nil {
 		log.Printf("Failed to create audit log: %v", err)
 	}
@@ -483,41 +683,46 @@ func (h *AdminHandler) UpdateCategory(c *gin.Context) {
 func (h *AdminHandler) DeleteCategory(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		apierror.Respond(c, apierror.BadRequest("Invalid category ID"))
 		return
 	}
 
 	// Get current user for audit logging
 	userID, _, err := middleware.GetCurrentUser(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apierror.Respond(c, apierror.ErrUnauthenticated)
 		return
 	}
 
 	// Get category data for audit log before deletion
-	oldCategory, err := h.categoryService.GetCategory(id)
+	oldCategory, err := h.categoryService.GetCategory(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		apierror.Respond(c, apierror.Missing(apierror.ErrCategoryNotFound, err))
 		return
 	}
 
 	// Check if category has products
 	var productCount int
-	err = h.db.QueryRow("SELECT COUNT(*) FROM products WHERE category = $1", oldCategory.Name).Scan(&productCount)
+	err = h.db.QueryRow("SELECT COUNT(*) FROM products WHERE category = $1 AND organization_id = $2", oldCategory.Name, middleware.GetOrganization(c)).Scan(&productCount)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check category usage: " + err.Error()})
+		apierror.Respond(c, apierror.Failed("Failed to check category usage", err))
 		return
 	}
 
 	if productCount > 0 {
-		c.JSON(http.StatusConflict, gin.H{"error": "Cannot delete category with existing products"})
+		apierror.Respond(c, apierror.New(http.StatusConflict, apierror.CodeCategoryInUse, "Cannot delete category with existing products"))
+		return
+	}
+
+	// The deletion can be undone until the undo window passes
+	if scheduleDeletion(c, h.pendingDeletions, h.auditService, models.DeletionCategories, id, userID) {
 		return
 	}
 
 	// Delete category from database
-	err = h.categoryService.DeleteCategory(id)
+	err = h.categoryService.DeleteCategory(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete category: " + err.Error()})
+		apierror.Respond(c, apierror.Failed("Failed to delete category", err))
 		return
 	}
 
@@ -535,7 +740,8 @@ func (h *AdminHandler) DeleteCategory(c *gin.Context) {
 		UserAgent:  c.GetHeader("User-Agent"),
 	}
 
-	err = h.auditService.CreateAuditLog(auditLog)
+	middleware.MarkAudited(c)
+	err = h.auditService.Record(c.Request.Context(), auditLog)
 	if err != nil {
 		log.Printf("Failed to create audit log: %v", err)
 	}
@@ -543,533 +749,364 @@ func (h *AdminHandler) DeleteCategory(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Category deleted successfully"})
 }
 
-func (h *AdminHandler) GenerateInventoryReport(c *gin.Context) {
-	// Parse query parameters
-	startDate := c.Query("start_date")
-	endDate := c.Query("end_date")
-	category := c.Query("category")
-	format := c.DefaultQuery("format", "json") // json, csv, pdf
-
-	// Build query based on filters
-	query := `
-		SELECT p.id, p.name, p.sku, p.stock, p.price, p.category, p.minimum_threshold,
-		       c.name as category_name, p.created_at, p.updated_at
-		FROM products p
-		LEFT JOIN categories c ON p.category = c.name
-	`
-	args := []interface{}{}
-	argCount := 0
-
-	conditions := []string{}
-
-	if startDate != "" {
-		argCount++
-		conditions = append(conditions, fmt.Sprintf("p.created_at >= $%d", argCount))
-		args = append(args, startDate)
+// MergeCategory moves the products of a category to another and deletes
+// it, for near-duplicate categories DeleteCategory refuses to delete. Each
+// product moved gets an audit entry, as does the deleted category.
+func (h *AdminHandler) MergeCategory(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid category ID"))
+		return
 	}
 
-	if endDate != "" {
-		argCount++
-		conditions = append(conditions, fmt.Sprintf("p.created_at <= $%d", argCount))
-		args = append(args, endDate)
+	var req models.MergeCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
 	}
 
-	if category != "" {
-		argCount++
-		conditions = append(conditions, fmt.Sprintf("p.category = $%d", argCount))
-		args = append(args, category)
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
 	}
 
-	if len(conditions) > 0 {
-		query += " WHERE " + strings.Join(conditions, " AND ")
+	merge, moved, err := h.productService.MergeCategory(c.Request.Context(), id, req.TargetID)
+	if errors.Is(err, database.ErrSameCategory) {
+		apierror.Respond(c, apierror.BadRequest("A category cannot be merged into itself"))
+		return
+	}
+	if errors.Is(err, database.ErrNotFound) {
+		apierror.Respond(c, apierror.Missing(apierror.ErrCategoryNotFound, err))
+		return
 	}
-
-	query += " ORDER BY p.name"
-
-	rows, err := h.db.Query(query, args...)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate inventory report: " + err.Error()})
+		apierror.Respond(c, apierror.Failed("Failed to merge category", err))
 		return
 	}
-	defer rows.Close()
 
-	var products []gin.H
-	var totalValue float64
-	var lowStockCount int
+	now := time.Now()
+	auditLogs := make([]*models.AuditLog, 0, len(moved)+1)
+	for _, productID := range moved {
+		auditLogs = append(auditLogs, &models.AuditLog{
+			ID:        uuid.New(),
+			TableName: "products",
+			RecordID:  productID,
+			Action:    models.ActionUpdate,
+			OldValues: map[string]interface{}{"category": merge.Source.Name},
+			NewValues: map[string]interface{}{"category": merge.Target.Name},
+			ChangedBy: userID,
+			ChangedAt: now,
+			IPAddress: c.ClientIP(),
+			UserAgent: c.GetHeader("User-Agent"),
+		})
+	}
+	auditLogs = append(auditLogs, &models.AuditLog{
+		ID:        uuid.New(),
+		TableName: "categories",
+		RecordID:  id,
+		Action:    models.ActionDelete,
+		OldValues: map[string]interface{}{"name": merge.Source.Name, "description": merge.Source.Description},
+		ChangedBy: userID,
+		ChangedAt: now,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+	})
 
-	for rows.Next() {
-		var id, name, sku, categoryName string
-		var stock int
-		var price float64
-		var minimumThreshold int
-		var createdAt, updatedAt time.Time
+	// One insert for all of them, as a merge can move hundreds of products
+	middleware.MarkAudited(c)
+	if err := h.auditService.CreateAuditLogs(c.Request.Context(), auditLogs); err != nil {
+		log.Printf("Failed to create audit logs of category merge: %v", err)
+	}
 
-		err := rows.Scan(&id, &name, &sku, &stock, &price, &categoryName, &minimumThreshold, &categoryName, &createdAt, &updatedAt)
-		if err != nil {
-			continue
-		}
+	c.JSON(http.StatusOK, merge)
+}
 
-		product := gin.H{
-			"id":                id,
-			"name":              name,
-			"sku":               sku,
-			"stock":             stock,
-			"price":             price,
-			"category":          categoryName,
-			"minimum_threshold": minimumThreshold,
-			"created_at":        createdAt,
-			"updated_at":        updatedAt,
-		}
-		products = append(products, product)
-		totalValue += price * float64(stock)
+// maxSyncMovements caps ungrouped movement reports generated in the request.
+// Larger histories belong in an async job (POST /admin/reports).
+const maxSyncMovements = 100
 
-		if stock <= minimumThreshold {
-			lowStockCount++
-		}
+// GenerateInventoryReport lists products with their stock and value,
+// filtered by creation date and category.
+func (h *AdminHandler) GenerateInventoryReport(c *gin.Context) {
+	var filter models.InventoryReportFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
 	}
 
-	report := gin.H{
-		"report_type":    "inventory",
-		"generated_at":   time.Now(),
-		"date_range": gin.H{
-			"start": startDate,
-			"end":   endDate,
-		},
-		"filters": gin.H{
-			"category": category,
-		},
-		"summary": gin.H{
-			"total_products":   len(products),
-			"total_value":      totalValue,
-			"low_stock_items":  lowStockCount,
-			"average_price":    totalValue / float64(len(products)),
-		},
-		"data": products,
+	h.generateReport(c, reportParams("inventory", filter.ReportOutput, reports.Params{
+		StartDate: filter.StartDate,
+		EndDate:   filter.EndDate,
+		Category:  filter.Category,
+		CompareTo: filter.CompareTo,
+		Limit:     filter.Limit,
+	}))
+}
+
+// GenerateMovementReport lists stock movements, or aggregates them when
+// group_by is set, filtered by date, product and reason.
+func (h *AdminHandler) GenerateMovementReport(c *gin.Context) {
+	var filter models.MovementReportFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
 	}
 
-	if format == "json" {
-		c.JSON(http.StatusOK, report)
-	} else if format == "csv" {
-		// Generate CSV export
-		c.Header("Content-Type", "text/csv")
-		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=inventory_report_%s.csv", time.Now().Format("2006-01-02_15-04-05")))
-
-		writer := csv.NewWriter(c.Writer)
-		defer writer.Flush()
-
-		// Write CSV header
-		writer.Write([]string{"ID", "Name", "SKU", "Stock", "Price", "Category", "Minimum Threshold", "Created At", "Updated At"})
-
-		// Write product data
-		for _, product := range products {
-			writer.Write([]string{
-				fmt.Sprintf("%v", product["id"]),
-				fmt.Sprintf("%v", product["name"]),
-				fmt.Sprintf("%v", product["sku"]),
-				fmt.Sprintf("%v", product["stock"]),
-				fmt.Sprintf("%.2f", product["price"]),
-				fmt.Sprintf("%v", product["category"]),
-				fmt.Sprintf("%v", product["minimum_threshold"]),
-				fmt.Sprintf("%v", product["created_at"]),
-				fmt.Sprintf("%v", product["updated_at"]),
-			})
-		}
-	} else if format == "pdf" {
-		// Generate PDF export
-		pdf := gofpdf.New("P", "mm", "A4", "")
-		pdf.AddPage()
-		pdf.SetFont("Arial", "B", 16)
-
-		// Title
-		pdf.Cell(40, 10, "Inventory Report")
-		pdf.Ln(12)
-
-		pdf.SetFont("Arial", "", 10)
-
-		// Report metadata
-		pdf.Cell(40, 6, fmt.Sprintf("Generated At: %s", time.Now().Format("2006-01-02 15:04:05")))
-		pdf.Ln(6)
-		pdf.Cell(40, 6, fmt.Sprintf("Total Products: %d", len(products)))
-		pdf.Ln(6)
-		pdf.Cell(40, 6, fmt.Sprintf("Total Value: %.2f", totalValue))
-		pdf.Ln(6)
-		pdf.Cell(40, 6, fmt.Sprintf("Low Stock Items: %d", lowStockCount))
-		pdf.Ln(10)
-
-		// Table header
-		pdf.SetFont("Arial", "B", 8)
-		pdf.SetFillColor(240, 240, 240)
-		pdf.CellFormat(20, 8, "ID", "1", 0, "C", true, 0, "")
-		pdf.CellFormat(40, 8, "Name", "1", 0, "C", true, 0, "")
-		pdf.CellFormat(25, 8, "SKU", "1", 0, "C", true, 0, "")
-		pdf.CellFormat(15, 8, "Stock", "1", 0, "C", true, 0, "")
-		pdf.CellFormat(20, 8, "Price", "1", 0, "C", true, 0, "")
-		pdf.CellFormat(30, 8, "Category", "1", 0, "C", true, 0, "")
-		pdf.CellFormat(20, 8, "Min Threshold", "1", 0, "C", true, 0, "")
-		pdf.Ln(8)
-
-		// Table data
-		pdf.SetFont("Arial", "", 7)
-		pdf.SetFillColor(255, 255, 255)
-
-		for _, product := range products {
-			pdf.CellFormat(20, 6, fmt.Sprintf("%v", product["id"]), "1", 0, "L", false, 0, "")
-			pdf.CellFormat(40, 6, fmt.Sprintf("%v", product["name"]), "1", 0, "L", false, 0, "")
-			pdf.CellFormat(25, 6, fmt.Sprintf("%v", product["sku"]), "1", 0, "L", false, 0, "")
-			pdf.CellFormat(15, 6, fmt.Sprintf("%v", product["stock"]), "1", 0, "C", false, 0, "")
-			pdf.CellFormat(20, 6, fmt.Sprintf("%.2f", product["price"]), "1", 0, "R", false, 0, "")
-			pdf.CellFormat(30, 6, fmt.Sprintf("%v", product["category"]), "1", 0, "L", false, 0, "")
-			pdf.CellFormat(20, 6, fmt.Sprintf("%v", product["minimum_threshold"]), "1", 0, "C", false, 0, "")
-			pdf.Ln(6)
-		}
+	params := reportParams("movements", filter.ReportOutput, reports.Params{
+		StartDate: filter.StartDate,
+		EndDate:   filter.EndDate,
+		ProductID: filter.ProductID,
+		Reason:    filter.Reason,
+		GroupBy:   filter.GroupBy,
+		CompareTo: filter.CompareTo,
+		Limit:     filter.Limit,
+	})
+	if params.GroupBy == "" && (params.Limit == 0 || params.Limit > maxSyncMovements) {
+		params.Limit = maxSyncMovements
+	}
 
-		// Set headers for PDF download
-		c.Header("Content-Type", "application/pdf")
-		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=inventory_report_%s.pdf", time.Now().Format("2006-01-02_15-04-05")))
+	h.generateReport(c, params)
+}
 
-		// Output PDF to response writer
-		err := pdf.Output(c.Writer)
-		if err != nil {
-			log.Printf("Failed to generate PDF: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate PDF report"})
-			return
-		}
-	} else {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported format. Supported formats: json, csv, pdf"})
+// GenerateUserReport summarizes audit log activity per user, optionally for
+// a single user.
+func (h *AdminHandler) GenerateUserReport(c *gin.Context) {
+	var filter models.UserReportFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
 	}
+
+	h.generateReport(c, reportParams("users", filter.ReportOutput, reports.Params{
+		StartDate: filter.StartDate,
+		EndDate:   filter.EndDate,
+		UserID:    filter.UserID,
+		Limit:     filter.Limit,
+	}))
 }
 
-func (h *AdminHandler) GenerateMovementReport(c *gin.Context) {
-	// Parse query parameters
-	startDate := c.Query("start_date")
-	endDate := c.Query("end_date")
-	productID := c.Query("product_id")
-	reason := c.Query("reason")
-	format := c.DefaultQuery("format", "json")
-	reportType := "movements" // Define reportType for this function
+// GenerateFinancialReport values stock, sales and purchases per category.
+func (h *AdminHandler) GenerateFinancialReport(c *gin.Context) {
+	var filter models.FinancialReportFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
 
-	// Build query based on filters
-	query := `
-		SELECT sm.id, sm.product_id, sm.change, sm.reason, sm.created_at, sm.notes,
-		       p.name as product_name, u.name as user_name
-		FROM stock_movements sm
-		LEFT JOIN products p ON sm.product_id = p.id
-		LEFT JOIN users u ON sm.created_by = u.id
-	`
-	args := []interface{}{}
-	argCount := 0
+	h.generateReport(c, reportParams("financial", filter.ReportOutput, reports.Params{
+		StartDate: filter.StartDate,
+		EndDate:   filter.EndDate,
+		Category:  filter.Category,
+	}))
+}
 
-	conditions := []string{}
+// reportParams completes the type-specific filters with the report type and
+// output options. The format defaults to JSON.
+func reportParams(reportType string, output models.ReportOutput, params reports.Params) reports.Params {
+	params.Type = reportType
+	params.Format = output.Format
+	params.Layout = output.Layout
+	params.Locale = output.Locale
+	if params.Format == "" {
+		params.Format = "json"
+	}
+	return params
+}
 
-	if startDate != "" {
-		argCount++
-		conditions = append(conditions, fmt.Sprintf("sm.created_at >= $%d", argCount))
-		args = append(args, startDate)
+func (h *AdminHandler) GetSettings(c *gin.Context) {
+	stored, err := h.settingsService.GetSettings()
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get settings", err))
+		return
 	}
 
-	if endDate != "" {
-		argCount++
-		conditions = append(conditions, fmt.Sprintf("sm.created_at <= $%d", argCount))
-		args = append(args, endDate)
-	}
+	c.JSON(http.StatusOK, settings.Redacted(stored))
+}
 
-	if productID != "" {
-		argCount++
-		conditions = append(conditions, fmt.Sprintf("sm.product_id = $%d", argCount))
-		args = append(args, productID)
-	}
+// GetSettingsSchema lists the settings by group, with their types, defaults
+// and allowed values.
+func (h *AdminHandler) GetSettingsSchema(c *gin.Context) {
+	c.JSON(http.StatusOK, settings.Grouped())
+}
 
-	if reason != "" {
-		argCount++
-		conditions = append(conditions, fmt.Sprintf("sm.reason = $%d", argCount))
-		args = append(args, reason)
+// settingsError maps an error of settings.Normalize to its response.
+func settingsError(err error) error {
+	var unknown *settings.UnknownError
+	if errors.As(err, &unknown) {
+		fields := make([]apierror.FieldError, 0, len(unknown.Keys))
+		for _, key := range unknown.Keys {
+			fields = append(fields, apierror.FieldError{Field: key, Rule: "unknown"})
+		}
+		return apierror.New(http.StatusBadRequest, apierror.CodeValidationFailed, "Unknown settings").WithDetails(fields).Wrap(err)
 	}
+	return apierror.Invalid(err)
+}
 
-	if len(conditions) > 0 {
-		query += " WHERE " + strings.Join(conditions, " AND ")
+func (h *AdminHandler) UpdateSettings(c *gin.Context) {
+	var req map[string]interface{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
 	}
 
-	query += " ORDER BY sm.created_at DESC"
-
-	rows, err := h.db.Query(query, args...)
+	// Get current user for audit logging
+	userID, _, err := middleware.GetCurrentUser(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate movement report: " + err.Error()})
+		apierror.Respond(c, apierror.ErrUnauthenticated)
 		return
 	}
-	defer rows.Close()
 
-	var movements []gin.H
-	var totalIn, totalOut int
+	// Secrets sent back masked, as they were read, keep their stored value
+	current, err := h.settingsService.GetSettings()
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get current settings", err))
+		return
+	}
 
-	for rows.Next() {
-		var id, productID, reason, productName, userName, notes string
-		var change int
-		var createdAt time.Time
+	// Check the values against the schema and turn them into stored text
+	updates, err := settings.Normalize(settings.Unmask(req, current))
+	if err != nil {
+		apierror.Respond(c, settingsError(err))
+		return
+	}
 
-		err := rows.Scan(&id, &productID, &change, &reason, &createdAt, &notes, &productName, &userName)
-		if err != nil {
-			continue
-		}
+	_, newSettings, ok := h.applySettings(c, updates, userID, nil)
+	if !ok {
+		return
+	}
 
-		movement := gin.H{
-			"id":           id,
-			"product_id":   productID,
-			"product_name": productName,
-			"change":       change,
-			"reason":       reason,
-			"user_name":    userName,
-			"created_at":   createdAt,
-			"notes":        notes,
-		}
-		movements = append(movements, movement)
+	c.JSON(http.StatusOK, settings.Redacted(newSettings))
+}
 
-		if change > 0 {
-			totalIn += change
-		} else {
-			totalOut += int(-change)
-		}
+// applySettings stores validated updates as a new settings revision and
+// audits the change, answering the request itself when it fails. It
+// returns the revision, nil when nothing changed, and the stored settings.
+func (h *AdminHandler) applySettings(c *gin.Context, updates map[string]string, userID uuid.UUID, rollbackOf *uuid.UUID) (*models.SettingsRevision, map[string]interface{}, bool) {
+	// Get old settings for audit log
+	oldSettings, err := h.settingsService.GetSettings()
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get current settings", err))
+		return nil, nil, false
 	}
 
-	report := gin.H{
-		"report_type":    "stock_movements",
-		"generated_at":   time.Now(),
-		"date_range": gin.H{
-			"start": startDate,
-			"end":   endDate,
-		},
-		"filters": gin.H{
-			"product_id": productID,
-			"reason":     reason,
-		},
-		"summary": gin.H{
-			"total_movements": len(movements),
-			"total_in":        totalIn,
-			"total_out":       totalOut,
-			"net_change":      totalIn - totalOut,
-		},
-		"data": movements,
+	// Update settings in database, recording the revision
+	revision, err := h.settingsService.ApplySettings(c.Request.Context(), updates, userID, rollbackOf)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to update settings", err))
+		return nil, nil, false
 	}
 
-	if format == "json" {
-		c.JSON(http.StatusOK, report)
-	} else if format == "csv" {
-		// Generate CSV export
-		c.Header("Content-Type", "text/csv")
-		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s_report_%s.csv", reportType, time.Now().Format("2006-01-02_15-04-05")))
-
-		writer := csv.NewWriter(c.Writer)
-		defer writer.Flush()
-
-		// Write CSV header based on report type
-		switch reportType {
-		case "inventory":
-			writer.Write([]string{"ID", "Name", "SKU", "Stock", "Price", "Category", "Minimum Threshold"})
-			for _, item := range report["data"].([]gin.H) {
-				writer.Write([]string{
-					fmt.Sprintf("%v", item["id"]),
-					fmt.Sprintf("%v", item["name"]),
-					fmt.Sprintf("%v", item["sku"]),
-					fmt.Sprintf("%v", item["stock"]),
-					fmt.Sprintf("%.2f", item["price"]),
-					fmt.Sprintf("%v", item["category"]),
-					fmt.Sprintf("%v", item["minimum_threshold"]),
-				})
-			}
-		case "movements":
-			writer.Write([]string{"ID", "Product ID", "Product Name", "Change", "Reason", "Created At"})
-			for _, item := range report["data"].([]gin.H) {
-				writer.Write([]string{
-					fmt.Sprintf("%v", item["id"]),
-					fmt.Sprintf("%v", item["product_id"]),
-					fmt.Sprintf("%v", item["product_name"]),
-					fmt.Sprintf("%v", item["change"]),
-					fmt.Sprintf("%v", item["reason"]),
-					fmt.Sprintf("%v", item["created_at"]),
-				})
-			}
-		case "users":
-			writer.Write([]string{"User ID", "Actions", "Last Action"})
-			for _, item := range report["data"].([]gin.H) {
-				writer.Write([]string{
-					fmt.Sprintf("%v", item["user_id"]),
-					fmt.Sprintf("%v", item["actions"]),
-					fmt.Sprintf("%v", item["last_action"]),
-				})
-			}
-		}
-	} else if format == "pdf" {
-		// Generate PDF export
-		pdf := gofpdf.New("P", "mm", "A4", "")
-		pdf.AddPage()
-		pdf.SetFont("Arial", "B", 16)
-
-		// Title
-		pdf.Cell(40, 10, fmt.Sprintf("%s Report", strings.Title(reportType)))
-		pdf.Ln(12)
-
-		pdf.SetFont("Arial", "", 10)
-
-		// Report metadata
-		pdf.Cell(40, 6, fmt.Sprintf("Generated At: %s", time.Now().Format("2006-01-02 15:04:05")))
-		pdf.Ln(6)
-		pdf.Cell(40, 6, fmt.Sprintf("Report Type: %s", reportType))
-		pdf.Ln(6)
-		pdf.Cell(40, 6, fmt.Sprintf("Format: %s", format))
-		pdf.Ln(10)
-
-		// Table header and data based on report type
-		pdf.SetFont("Arial", "B", 8)
-		pdf.SetFillColor(240, 240, 240)
-
-		switch reportType {
-		case "inventory":
-			pdf.CellFormat(20, 8, "ID", "1", 0, "C", true, 0, "")
-			pdf.CellFormat(40, 8, "Name", "1", 0, "C", true, 0, "")
-			pdf.CellFormat(25, 8, "SKU", "1", 0, "C", true, 0, "")
-			pdf.CellFormat(15, 8, "Stock", "1", 0, "C", true, 0, "")
-			pdf.CellFormat(20, 8, "Price", "1", 0, "C", true, 0, "")
-			pdf.CellFormat(30, 8, "Category", "1", 0, "C", true, 0, "")
-			pdf.CellFormat(20, 8, "Min Threshold", "1", 0, "C", true, 0, "")
-			pdf.Ln(8)
-
-			pdf.SetFont("Arial", "", 7)
-			pdf.SetFillColor(255, 255, 255)
-			for _, item := range report["data"].([]gin.H) {
-				pdf.CellFormat(20, 6, fmt.Sprintf("%v", item["id"]), "1", 0, "L", false, 0, "")
-				pdf.CellFormat(40, 6, fmt.Sprintf("%v", item["name"]), "1", 0, "L", false, 0, "")
-				pdf.CellFormat(25, 6, fmt.Sprintf("%v", item["sku"]), "1", 0, "L", false, 0, "")
-				pdf.CellFormat(15, 6, fmt.Sprintf("%v", item["stock"]), "1", 0, "C", false, 0, "")
-				pdf.CellFormat(20, 6, fmt.Sprintf("%.2f", item["price"]), "1", 0, "R", false, 0, "")
-				pdf.CellFormat(30, 6, fmt.Sprintf("%v", item["category"]), "1", 0, "L", false, 0, "")
-				pdf.CellFormat(20, 6, fmt.Sprintf("%v", item["minimum_threshold"]), "1", 0, "C", false, 0, "")
-				pdf.Ln(6)
-			}
-		case "movements":
-			pdf.CellFormat(25, 8, "ID", "1", 0, "C", true, 0, "")
-			pdf.CellFormat(30, 8, "Product ID", "1", 0, "C", true, 0, "")
-			pdf.CellFormat(40, 8, "Product Name", "1", 0, "C", true, 0, "")
-			pdf.CellFormat(15, 8, "Change", "1", 0, "C", true, 0, "")
-			pdf.CellFormat(30, 8, "Reason", "1", 0, "C", true, 0, "")
-			pdf.CellFormat(30, 8, "Created At", "1", 0, "C", true, 0, "")
-			pdf.Ln(8)
-
-			pdf.SetFont("Arial", "", 7)
-			pdf.SetFillColor(255, 255, 255)
-			for _, item := range report["data"].([]gin.H) {
-				pdf.CellFormat(25, 6, fmt.Sprintf("%v", item["id"]), "1", 0, "L", false, 0, "")
-				pdf.CellFormat(30, 6, fmt.Sprintf("%v", item["product_id"]), "1", 0, "L", false, 0, "")
-				pdf.CellFormat(40, 6, fmt.Sprintf("%v", item["product_name"]), "1", 0, "L", false, 0, "")
-				pdf.CellFormat(15, 6, fmt.Sprintf("%v", item["change"]), "1", 0, "C", false, 0, "")
-				pdf.CellFormat(30, 6, fmt.Sprintf("%v", item["reason"]), "1", 0, "L", false, 0, "")
-				pdf.CellFormat(30, 6, fmt.Sprintf("%v", item["created_at"]), "1", 0, "L", false, 0, "")
-				pdf.Ln(6)
-			}
-		case "users":
-			pdf.CellFormat(50, 8, "User ID", "1", 0, "C", true, 0, "")
-			pdf.CellFormat(25, 8, "Actions", "1", 0, "C", true, 0, "")
-			pdf.CellFormat(50, 8, "Last Action", "1", 0, "C", true, 0, "")
-			pdf.Ln(8)
-
-			pdf.SetFont("Arial", "", 7)
-			pdf.SetFillColor(255, 255, 255)
-			for _, item := range report["data"].([]gin.H) {
-				pdf.CellFormat(50, 6, fmt.Sprintf("%v", item["user_id"]), "1", 0, "L", false, 0, "")
-				pdf.CellFormat(25, 6, fmt.Sprintf("%v", item["actions"]), "1", 0, "C", false, 0, "")
-				pdf.CellFormat(50, 6, fmt.Sprintf("%v", item["last_action"]), "1", 0, "L", false, 0, "")
-				pdf.Ln(6)
-			}
-		}
-
-		// Set headers for PDF download
-		c.Header("Content-Type", "application/pdf")
-		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s_report_%s.pdf", reportType, time.Now().Format("2006-01-02_15-04-05")))
+	// Get updated settings
+	newSettings, err := h.settingsService.GetSettings()
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get updated settings", err))
+		return nil, nil, false
+	}
 
-		// Output PDF to response writer
-		err := pdf.Output(c.Writer)
-		if err != nil {
-			log.Printf("Failed to generate PDF: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate PDF report"})
-			return
-		}
-	} else {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported format. Supported formats: json, csv, pdf"})
+	// Create audit log; a change that made a revision is logged against it
+	recordID := uuid.New()
+	if revision != nil {
+		recordID = revision.ID
+	}
+	auditLog := &models.AuditLog{
+		ID:        uuid.New(),
+		TableName: "system_settings",
+		RecordID:  recordID,
+		Action:    models.ActionUpdate,
+		OldValues: settings.Redacted(oldSettings),
+		NewValues: settings.Redacted(newSettings),
+		ChangedBy: userID,
+		ChangedAt: time.Now(),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+	}
+
+	middleware.MarkAudited(c)
+	err = h.auditService.Record(c.Request.Context(), auditLog)
+	if err != nil {
+		log.Printf("Failed to create audit log: %v", err)
 	}
+
+	return revision, newSettings, true
 }
 
-func (h *AdminHandler) GetSettings(c *gin.Context) {
-	settings, err := h.settingsService.GetSettings()
+// GetSettingsRevisions lists the 50 most recent settings changes, with the
+// old and new value of each key they changed.
+func (h *AdminHandler) GetSettingsRevisions(c *gin.Context) {
+	revisions, err := h.settingsService.GetSettingsRevisions(c.Request.Context(), 50)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get settings: " + err.Error()})
+		apierror.Respond(c, apierror.Failed("Failed to get settings revisions", err))
 		return
 	}
+	for i := range revisions {
+		revisions[i].Changes = settings.RedactChanges(revisions[i].Changes)
+	}
 
-	c.JSON(http.StatusOK, settings)
+	c.JSON(http.StatusOK, gin.H{"revisions": revisions})
 }
 
-func (h *AdminHandler) UpdateSettings(c *gin.Context) {
-	var req map[string]interface{}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+// RollbackSettingsRevision undoes a settings revision, restoring the
+// values the keys it changed had before it; keys it set for the first time
+// go back to their default. The rollback is itself a new revision, so it
+// can be undone too.
+func (h *AdminHandler) RollbackSettingsRevision(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid settings revision ID"))
 		return
 	}
 
-	// Get current user for audit logging
 	userID, _, err := middleware.GetCurrentUser(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apierror.Respond(c, apierror.ErrUnauthenticated)
 		return
 	}
 
-	// Get old settings for audit log
-	oldSettings, err := h.settingsService.GetSettings()
+	revision, err := h.settingsService.GetSettingsRevision(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current settings: " + err.Error()})
+		apierror.Respond(c, apierror.Missing(apierror.ErrRevisionNotFound, err))
 		return
 	}
 
-	// Update settings in database
-	err = h.settingsService.UpdateSettings(req)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update settings: " + err.Error()})
-		return
+	// The old values still go through the schema, which may have changed
+	// since the revision was made
+	restore := make(map[string]interface{}, len(revision.Changes))
+	for key, change := range revision.Changes {
+		if change.Old == nil {
+			restore[key] = nil
+		} else {
+			restore[key] = *change.Old
+		}
 	}
-
-	// Get updated settings
-	newSettings, err := h.settingsService.GetSettings()
+	updates, err := settings.Normalize(restore)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get updated settings: " + err.Error()})
+		apierror.Respond(c, settingsError(err))
 		return
 	}
 
-	// Create audit log
-	auditLog := &models.AuditLog{
-		ID:         uuid.New(),
-		TableName:  "system_settings",
-		RecordID:   uuid.New(), // Using new UUID since settings don't have a specific ID
-		Action:     models.ActionUpdate,
-		OldValues:  oldSettings,
-		NewValues:  newSettings,
-		ChangedBy:  userID,
-		ChangedAt:  time.Now(),
-		IPAddress:  c.ClientIP(),
-		UserAgent:  c.GetHeader("User-Agent"),
+	rollback, newSettings, ok := h.applySettings(c, updates, userID, &revision.ID)
+	if !ok {
+		return
 	}
-
-	err = h.auditService.CreateAuditLog(auditLog)
-	if err != nil {
-		log.Printf("Failed to create audit log: %v", err)
+	if rollback != nil {
+		rollback.Changes = settings.RedactChanges(rollback.Changes)
 	}
 
-	c.JSON(http.StatusOK, newSettings)
+	c.JSON(http.StatusOK, gin.H{"revision": rollback, "settings": settings.Redacted(newSettings)})
 }
 
 func (h *AdminHandler) GetReportStats(c *gin.Context) {
-	// Get report statistics from audit logs
+	// Reports belong to the organization of whoever requested them
+	organizationID := middleware.GetOrganization(c)
+
+	// Get report statistics from stored reports
 	var totalReports int
 	err := h.db.QueryRow(`
-		SELECT COUNT(*) FROM audit_logs
-		WHERE table_name = 'reports' OR action = 'report_generated'
-	`).Scan(&totalReports)
+		SELECT COUNT(*) FROM reports
+		WHERE status = 'completed'
+		AND requested_by IN (SELECT id FROM users WHERE organization_id = $1)
+	`, organizationID).Scan(&totalReports)
 	if err != nil {
 		totalReports = 0
 	}
@@ -1077,46 +1114,57 @@ func (h *AdminHandler) GetReportStats(c *gin.Context) {
 	// Get this month's reports
 	var thisMonth int
 	err = h.db.QueryRow(`
-		SELECT COUNT(*) FROM audit_logs
-		WHERE (table_name = 'reports' OR action = 'report_generated')
-		AND changed_at >= date_trunc('month', CURRENT_DATE)
-	`).Scan(&thisMonth)
+		SELECT COUNT(*) FROM reports
+		WHERE status = 'completed'
+		AND created_at >= date_trunc('month', CURRENT_DATE)
+		AND requested_by IN (SELECT id FROM users WHERE organization_id = $1)
+	`, organizationID).Scan(&thisMonth)
 	if err != nil {
 		thisMonth = 0
 	}
 
 	// Get total data points (approximate from products and movements)
 	var dataPoints int
-	err = h.db.QueryRow("SELECT (SELECT COUNT(*) FROM products) + (SELECT COUNT(*) FROM stock_movements)").Scan(&dataPoints)
+	err = h.db.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM products WHERE organization_id = $1)
+		     + (SELECT COUNT(*) FROM stock_movements WHERE organization_id = $1)
+	`, organizationID).Scan(&dataPoints)
 	if err != nil {
 		dataPoints = 0
 	}
 
-	// Get most popular report type from actual data
+	// Get most popular report type
 	var mostPopularType string
 	err = h.db.QueryRow(`
-		SELECT table_name, COUNT(*) as count
-		FROM audit_logs
-		WHERE table_name IN ('reports', 'products', 'stock_movements', 'users')
-		GROUP BY table_name
-		ORDER BY count DESC
+		SELECT type FROM reports
+		WHERE status = 'completed'
+		AND requested_by IN (SELECT id FROM users WHERE organization_id = $1)
+		GROUP BY type
+		ORDER BY COUNT(*) DESC
 		LIMIT 1
-	`).Scan(&mostPopularType)
+	`, organizationID).Scan(&mostPopularType)
 	if err != nil {
 		mostPopularType = "inventory" // fallback
 	}
 
-	// Calculate average report size from actual data
+	// Calculate average report size and last generation time
 	var avgSize float64
+	var lastGenerated sql.NullTime
 	err = h.db.QueryRow(`
-		SELECT AVG(LENGTH(COALESCE(old_values::text, '')) + LENGTH(COALESCE(new_values::text, '')))
-		FROM audit_logs
-		WHERE table_name IN ('reports', 'products', 'stock_movements', 'users')
-	`).Scan(&avgSize)
+		SELECT COALESCE(AVG(size), 0), MAX(completed_at)
+		FROM reports
+		WHERE status = 'completed'
+		AND requested_by IN (SELECT id FROM users WHERE organization_id = $1)
+	`, organizationID).Scan(&avgSize, &lastGenerated)
 	if err != nil {
 		avgSize = 0
 	}
 
+	var lastGeneratedAt *time.Time
+	if lastGenerated.Valid {
+		lastGeneratedAt = &lastGenerated.Time
+	}
+
 	// Format average size
 	var avgSizeStr string
 	if avgSize >= 1024*1024 {
@@ -1131,7 +1179,7 @@ func (h *AdminHandler) GetReportStats(c *gin.Context) {
 		"total_reports":     totalReports,
 		"this_month":        thisMonth,
 		"data_points":       dataPoints,
-		"last_generated":    time.Now(),
+		"last_generated":    lastGeneratedAt,
 		"most_popular_type": mostPopularType,
 		"average_size":      avgSizeStr,
 	}
@@ -1147,7 +1195,7 @@ func (h *AdminHandler) GetReportTypes(c *gin.Context) {
 			"name":        "Inventory Report",
 			"description": "Complete overview of all products and stock levels",
 			"available":   true,
-			"formats":     []string{"json", "csv", "pdf"},
+			"formats":     []string{"json", "csv", "pdf", "xlsx"},
 			"frequency":   "daily",
 		},
 		{
@@ -1155,7 +1203,7 @@ func (h *AdminHandler) GetReportTypes(c *gin.Context) {
 			"name":        "Stock Movements",
 			"description": "Track all inventory changes and transactions",
 			"available":   true,
-			"formats":     []string{"json", "csv", "pdf"},
+			"formats":     []string{"json", "csv", "pdf", "xlsx"},
 			"frequency":   "daily",
 		},
 		{
@@ -1163,218 +1211,104 @@ func (h *AdminHandler) GetReportTypes(c *gin.Context) {
 			"name":        "User Activity",
 			"description": "User actions and system usage statistics",
 			"available":   true,
-			"formats":     []string{"json", "csv"},
+			"formats":     []string{"json", "csv", "pdf", "xlsx"},
 			"frequency":   "weekly",
 		},
 	}
 
 	// Check if financial data is available
 	var productCount int
-	err := h.db.QueryRow("SELECT COUNT(*) FROM products").Scan(&productCount)
+	err := h.db.QueryRow("SELECT COUNT(*) FROM products WHERE organization_id = $1", middleware.GetOrganization(c)).Scan(&productCount)
 	if err == nil && productCount > 0 {
 		// Add financial report if we have products
 		financialReport := gin.H{
 			"id":          "financial",
 			"name":        "Financial Summary",
-			"description": "Revenue, costs, and profit analysis",
+			"description": "Stock, sales, and purchase value by category",
 			"available":   true,
-			"formats":     []string{"json", "pdf"},
+			"formats":     []string{"json", "csv", "pdf", "xlsx"},
 			"frequency":   "monthly",
 		}
 		reportTypes = append(reportTypes, financialReport)
 	}
 
+	// Mark the types the current user's role may not run
+	_, role, _ := middleware.GetCurrentUser(c)
+	allowed := map[string]bool{}
+	for _, reportType := range reports.AllowedTypes(h.db, role) {
+		allowed[reportType] = true
+	}
+	for _, reportType := range reportTypes {
+		if !allowed[reportType["id"].(string)] {
+			reportType["available"] = false
+		}
+	}
+
 	c.JSON(http.StatusOK, reportTypes)
 }
 
 func (h *AdminHandler) GetRecentReports(c *gin.Context) {
-	// Get recent reports from audit logs
-	query := `
-		SELECT id, table_name, action, changed_at, changed_by
-		FROM audit_logs
-		WHERE table_name IN ('reports', 'products', 'stock_movements')
-		ORDER BY changed_at DESC
-		LIMIT 10
-	`
-
-	rows, err := h.db.Query(query)
+	recent, err := h.reportService.GetRecentReports(c.Request.Context(), 10)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get recent reports: " + err.Error()})
+		apierror.Respond(c, apierror.Failed("Failed to get recent reports", err))
 		return
 	}
-	defer rows.Close()
-
-	var reports []gin.H
-	for rows.Next() {
-		var id, tableName, action string
-		var changedAt time.Time
-		var changedBy uuid.UUID
-
-		err := rows.Scan(&id, &tableName, &action, &changedAt, &changedBy)
-		if err != nil {
-			continue
-		}
-
-		// Calculate approximate size based on table name and record count
-		var estimatedSize int
-		switch tableName {
-		case "products":
-			estimatedSize = 1024 // ~1KB per product record
-		case "stock_movements":
-			estimatedSize = 512 // ~512B per movement record
-		case "users":
-			estimatedSize = 256 // ~256B per user record
-		default:
-			estimatedSize = 1024 // default estimate
-		}
 
-		report := gin.H{
-			"id":           id,
-			"name":         fmt.Sprintf("%s Report", strings.Title(tableName)),
-			"type":         tableName,
-			"format":       "json",
-			"generated_at": changedAt,
-			"size":         estimatedSize,
-			"status":       "completed",
-			"download_url": fmt.Sprintf("/api/admin/reports/%s/download/%s", tableName, id),
-		}
-		reports = append(reports, report)
+	for i := range recent {
+		recent[i].DownloadURL = reportDownloadURL(&recent[i])
 	}
 
-	c.JSON(http.StatusOK, reports)
+	c.JSON(http.StatusOK, recent)
 }
 
-func (h *AdminHandler) GenerateReport(c *gin.Context) {
-	reportType := c.Param("type")
-	format := c.DefaultQuery("format", "json")
+// reportDownloadURL returns the download link for a completed report.
+func reportDownloadURL(report *models.Report) string {
+	if report.Status != models.ReportCompleted {
+		return ""
+	}
+	return fmt.Sprintf("/api/v1/reports/%s/download", report.ID)
+}
 
+// generateReport builds the report described by params and writes it in the
+// requested format.
+func (h *AdminHandler) generateReport(c *gin.Context, params reports.Params) {
 	// Get current user for audit logging
-	userID, _, err := middleware.GetCurrentUser(c)
+	userID, role, err := middleware.GetCurrentUser(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apierror.Respond(c, apierror.ErrUnauthenticated)
 		return
 	}
 
-	report := gin.H{
-		"report_type":  reportType,
-		"format":       format,
-		"generated_at": time.Now(),
-		"data":         []gin.H{},
+	if err := params.Validate(); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
 	}
+	if !reports.Allowed(h.db, role, params.Type) {
+		apierror.Respond(c, apierror.New(http.StatusForbidden, apierror.CodeForbidden, reports.ErrForbiddenType.Error()))
+		return
+	}
+	if params.Locale == "" {
+		params.Locale = reports.LocaleFor(h.db, &userID)
+	}
+	params.OrganizationID = middleware.GetOrganization(c).String()
 
-	switch reportType {
-	case "inventory":
-		// Get all products
-		query := "SELECT id, name, sku, stock, price, category, minimum_threshold FROM products ORDER BY name"
-		rows, err := h.db.Query(query)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate inventory report: " + err.Error()})
-			return
-		}
-		defer rows.Close()
-
-		var products []gin.H
-		for rows.Next() {
-			var id, name, sku, category string
-			var stock int
-			var price float64
-			var minimumThreshold int
-
-			err := rows.Scan(&id, &name, &sku, &stock, &price, &category, &minimumThreshold)
-			if err != nil {
-				continue
-			}
-
-			product := gin.H{
-				"id":                id,
-				"name":              name,
-				"sku":               sku,
-				"stock":             stock,
-				"price":             price,
-				"category":          category,
-				"minimum_threshold": minimumThreshold,
-			}
-			products = append(products, product)
-		}
-		report["data"] = products
-
-	case "movements":
-		// Get recent stock movements
-		query := `
-			SELECT sm.id, sm.product_id, sm.change, sm.reason, sm.created_at, p.name
-			FROM stock_movements sm
-			JOIN products p ON sm.product_id = p.id
-			ORDER BY sm.created_at DESC
-			LIMIT 100
-		`
-		rows, err := h.db.Query(query)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate movements report: " + err.Error()})
-			return
-		}
-		defer rows.Close()
-
-		var movements []gin.H
-		for rows.Next() {
-			var id, productID, reason, productName string
-			var change int
-			var createdAt time.Time
-
-			err := rows.Scan(&id, &productID, &change, &reason, &createdAt, &productName)
-			if err != nil {
-				continue
-			}
-
-			movement := gin.H{
-				"id":         id,
-				"product_id": productID,
-				"product_name": productName,
-				"change":     change,
-				"reason":     reason,
-				"created_at": createdAt,
-			}
-			movements = append(movements, movement)
-		}
-		report["data"] = movements
-
-	case "users":
-		// Get user activity from audit logs
-		query := `
-			SELECT changed_by, COUNT(*) as actions, MAX(changed_at) as last_action
-			FROM audit_logs
-			WHERE changed_by IS NOT NULL
-			GROUP BY changed_by
-			ORDER BY actions DESC
-		`
-		rows, err := h.db.Query(query)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate users report: " + err.Error()})
-			return
-		}
-		defer rows.Close()
-
-		var userActivities []gin.H
-		for rows.Next() {
-			var userID uuid.UUID
-			var actions int
-			var lastAction time.Time
-
-			err := rows.Scan(&userID, &actions, &lastAction)
-			if err != nil {
-				continue
-			}
-
-			activity := gin.H{
-				"user_id":     userID,
-				"actions":     actions,
-				"last_action": lastAction,
-			}
-			userActivities = append(userActivities, activity)
-		}
-		report["data"] = userActivities
+	// Queue behind other reports rather than compete for DB connections
+	ctx := c.Request.Context()
+	if h.reportQueue.Limiter != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.reportQueue.Limiter.Wait)
+		defer cancel()
+	}
+	release, err := h.reportQueue.Acquire(ctx, &userID)
+	if err != nil {
+		apierror.Respond(c, apierror.New(http.StatusServiceUnavailable, apierror.CodeReportQueueFull, err.Error()))
+		return
+	}
+	defer release()
 
-	default:
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report type"})
+	report, err := reports.Generate(h.db, params)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to generate report", err))
 		return
 	}
 
@@ -1385,171 +1319,419 @@ func (h *AdminHandler) GenerateReport(c *gin.Context) {
 		RecordID:   uuid.New(),
 		Action:     models.ActionCreate,
 		OldValues:  nil,
-		NewValues:  map[string]interface{}{"report_type": reportType, "format": format, "data_count": len(report["data"].([]gin.H))},
+		NewValues:  map[string]interface{}{"report_type": params.Type, "format": params.Format, "group_by": params.GroupBy, "compare_to": params.CompareTo, "data_count": len(report.Data)},
 		ChangedBy:  userID,
 		ChangedAt:  time.Now(),
 		IPAddress:  c.ClientIP(),
 		UserAgent:  c.GetHeader("User-Agent"),
 	}
 
-	err = h.auditService.CreateAuditLog(auditLog)
+	middleware.MarkAudited(c)
+	err = h.auditService.Record(c.Request.Context(), auditLog)
 	if err != nil {
 		log.Printf("Failed to create audit log: %v", err)
 	}
 
-	if format == "json" {
+	if params.Format == "json" {
 		c.JSON(http.StatusOK, report)
-	} else if format == "csv" {
-		// Generate CSV export
-		c.Header("Content-Type", "text/csv")
-		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s_report_%s.csv", reportType, time.Now().Format("2006-01-02_15-04-05")))
-
-		writer := csv.NewWriter(c.Writer)
-		defer writer.Flush()
-
-		// Write CSV header based on report type
-		switch reportType {
-		case "inventory":
-			writer.Write([]string{"ID", "Name", "SKU", "Stock", "Price", "Category", "Minimum Threshold"})
-			for _, item := range report["data"].([]gin.H) {
-				writer.Write([]string{
-					fmt.Sprintf("%v", item["id"]),
-					fmt.Sprintf("%v", item["name"]),
-					fmt.Sprintf("%v", item["sku"]),
-					fmt.Sprintf("%v", item["stock"]),
-					fmt.Sprintf("%.2f", item["price"]),
-					fmt.Sprintf("%v", item["category"]),
-					fmt.Sprintf("%v", item["minimum_threshold"]),
-				})
-			}
-		case "movements":
-			writer.Write([]string{"ID", "Product ID", "Product Name", "Change", "Reason", "Created At"})
-			for _, item := range report["data"].([]gin.H) {
-				writer.Write([]string{
-					fmt.Sprintf("%v", item["id"]),
-					fmt.Sprintf("%v", item["product_id"]),
-					fmt.Sprintf("%v", item["product_name"]),
-					fmt.Sprintf("%v", item["change"]),
-					fmt.Sprintf("%v", item["reason"]),
-					fmt.Sprintf("%v", item["created_at"]),
-				})
-			}
-		case "users":
-			writer.Write([]string{"User ID", "Actions", "Last Action"})
-			for _, item := range report["data"].([]gin.H) {
-				writer.Write([]string{
-					fmt.Sprintf("%v", item["user_id"]),
-					fmt.Sprintf("%v", item["actions"]),
-					fmt.Sprintf("%v", item["last_action"]),
-				})
-			}
+		return
+	}
+
+	if params.Format == "pdf" {
+		if report.Branding, err = h.reportQueue.Branding(); err != nil {
+			log.Printf("Failed to load report branding, rendering unbranded: %v", err)
 		}
-	} else if format == "pdf" {
-		// Generate PDF export
-		pdf := gofpdf.New("P", "mm", "A4", "")
-		pdf.AddPage()
-		pdf.SetFont("Arial", "B", 16)
-
-		// Title
-		pdf.Cell(40, 10, fmt.Sprintf("%s Report", strings.Title(reportType)))
-		pdf.Ln(12)
-
-		pdf.SetFont("Arial", "", 10)
-
-		// Report metadata
-		pdf.Cell(40, 6, fmt.Sprintf("Generated At: %s", time.Now().Format("2006-01-02 15:04:05")))
-		pdf.Ln(6)
-		pdf.Cell(40, 6, fmt.Sprintf("Report Type: %s", reportType))
-		pdf.Ln(6)
-		pdf.Cell(40, 6, fmt.Sprintf("Format: %s", format))
-		pdf.Ln(10)
-
-		// Table header and data based on report type
-		pdf.SetFont("Arial", "B", 8)
-		pdf.SetFillColor(240, 240, 240)
-
-		switch reportType {
-		case "inventory":
-			pdf.CellFormat(20, 8, "ID", "1", 0, "C", true, 0, "")
-			pdf.CellFormat(40, 8, "Name", "1", 0, "C", true, 0, "")
-			pdf.CellFormat(25, 8, "SKU", "1", 0, "C", true, 0, "")
-			pdf.CellFormat(15, 8, "Stock", "1", 0, "C", true, 0, "")
-			pdf.CellFormat(20, 8, "Price", "1", 0, "C", true, 0, "")
-			pdf.CellFormat(30, 8, "Category", "1", 0, "C", true, 0, "")
-			pdf.CellFormat(20, 8, "Min Threshold", "1", 0, "C", true, 0, "")
-			pdf.Ln(8)
-
-			pdf.SetFont("Arial", "", 7)
-			pdf.SetFillColor(255, 255, 255)
-			for _, item := range report["data"].([]gin.H) {
-				pdf.CellFormat(20, 6, fmt.Sprintf("%v", item["id"]), "1", 0, "L", false, 0, "")
-				pdf.CellFormat(40, 6, fmt.Sprintf("%v", item["name"]), "1", 0, "L", false, 0, "")
-				pdf.CellFormat(25, 6, fmt.Sprintf("%v", item["sku"]), "1", 0, "L", false, 0, "")
-				pdf.CellFormat(15, 6, fmt.Sprintf("%v", item["stock"]), "1", 0, "C", false, 0, "")
-				pdf.CellFormat(20, 6, fmt.Sprintf("%.2f", item["price"]), "1", 0, "R", false, 0, "")
-				pdf.CellFormat(30, 6, fmt.Sprintf("%v", item["category"]), "1", 0, "L", false, 0, "")
-				pdf.CellFormat(20, 6, fmt.Sprintf("%v", item["minimum_threshold"]), "1", 0, "C", false, 0, "")
-				pdf.Ln(6)
-			}
-		case "movements":
-			pdf.CellFormat(25, 8, "ID", "1", 0, "C", true, 0, "")
-			pdf.CellFormat(30, 8, "Product ID", "1", 0, "C", true, 0, "")
-			pdf.CellFormat(40, 8, "Product Name", "1", 0, "C", true, 0, "")
-			pdf.CellFormat(15, 8, "Change", "1", 0, "C", true, 0, "")
-			pdf.CellFormat(30, 8, "Reason", "1", 0, "C", true, 0, "")
-			pdf.CellFormat(30, 8, "Created At", "1", 0, "C", true, 0, "")
-			pdf.Ln(8)
-
-			pdf.SetFont("Arial", "", 7)
-			pdf.SetFillColor(255, 255, 255)
-			for _, item := range report["data"].([]gin.H) {
-				pdf.CellFormat(25, 6, fmt.Sprintf("%v", item["id"]), "1", 0, "L", false, 0, "")
-				pdf.CellFormat(30, 6, fmt.Sprintf("%v", item["product_id"]), "1", 0, "L", false, 0, "")
-				pdf.CellFormat(40, 6, fmt.Sprintf("%v", item["product_name"]), "1", 0, "L", false, 0, "")
-				pdf.CellFormat(15, 6, fmt.Sprintf("%v", item["change"]), "1", 0, "C", false, 0, "")
-				pdf.CellFormat(30, 6, fmt.Sprintf("%v", item["reason"]), "1", 0, "L", false, 0, "")
-				pdf.CellFormat(30, 6, fmt.Sprintf("%v", item["created_at"]), "1", 0, "L", false, 0, "")
-				pdf.Ln(6)
-			}
-		case "users":
-			pdf.CellFormat(50, 8, "User ID", "1", 0, "C", true, 0, "")
-			pdf.CellFormat(25, 8, "Actions", "1", 0, "C", true, 0, "")
-			pdf.CellFormat(50, 8, "Last Action", "1", 0, "C", true, 0, "")
-			pdf.Ln(8)
-
-			pdf.SetFont("Arial", "", 7)
-			pdf.SetFillColor(255, 255, 255)
-			for _, item := range report["data"].([]gin.H) {
-				pdf.CellFormat(50, 6, fmt.Sprintf("%v", item["user_id"]), "1", 0, "L", false, 0, "")
-				pdf.CellFormat(25, 6, fmt.Sprintf("%v", item["actions"]), "1", 0, "C", false, 0, "")
-				pdf.CellFormat(50, 6, fmt.Sprintf("%v", item["last_action"]), "1", 0, "L", false, 0, "")
-				pdf.Ln(6)
-			}
+	}
+
+	c.Header("Content-Type", reports.ContentType(params.Format))
+	c.Header("Content-Disposition", "attachment; filename="+reports.Filename(report))
+
+	if err := reports.Render(c.Writer, report); err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to generate report", fmt.Errorf("render %s report: %w", params.Format, err)))
+	}
+}
+
+// EnqueueReport starts generating a report in the background and returns the
+// job for polling.
+func (h *AdminHandler) EnqueueReport(c *gin.Context) {
+	var params reports.Params
+	if err := c.ShouldBindJSON(&params); err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid request body"))
+		return
+	}
+	if params.Format == "" {
+		params.Format = "json"
+	}
+
+	userID, role, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	if err := params.Validate(); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+	if !reports.Allowed(h.db, role, params.Type) {
+		apierror.Respond(c, apierror.New(http.StatusForbidden, apierror.CodeForbidden, reports.ErrForbiddenType.Error()))
+		return
+	}
+
+	report, err := h.reportQueue.Enqueue(params, userID)
+	if err == reports.ErrQueueFull {
+		apierror.Respond(c, apierror.New(http.StatusServiceUnavailable, apierror.CodeReportQueueFull, err.Error()))
+		return
+	}
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to enqueue report", err))
+		return
+	}
+
+	// Create audit log for report request
+	auditLog := &models.AuditLog{
+		ID:         uuid.New(),
+		TableName:  "reports",
+		RecordID:   report.ID,
+		Action:     models.ActionCreate,
+		OldValues:  nil,
+		NewValues:  map[string]interface{}{"report_type": params.Type, "format": params.Format, "async": true},
+		ChangedBy:  userID,
+		ChangedAt:  time.Now(),
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.GetHeader("User-Agent"),
+	}
+
+	middleware.MarkAudited(c)
+	err = h.auditService.Record(c.Request.Context(), auditLog)
+	if err != nil {
+		log.Printf("Failed to create audit log: %v", err)
+	}
+
+	c.JSON(http.StatusAccepted, report)
+}
+
+func (h *AdminHandler) GetReportJob(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid job ID"))
+		return
+	}
+
+	report, err := h.reportQueue.Get(id)
+	if err != nil || !h.canAccessReport(c, report) {
+		apierror.Respond(c, apierror.ErrReportNotFound)
+		return
+	}
+	report.DownloadURL = reportDownloadURL(report)
+
+	c.JSON(http.StatusOK, report)
+}
+
+func (h *AdminHandler) DownloadReport(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid report ID"))
+		return
+	}
+
+	report, err := h.reportQueue.Get(id)
+	if err != nil || !h.canAccessReport(c, report) {
+		apierror.Respond(c, apierror.ErrReportNotFound)
+		return
+	}
+
+	if report.Status != models.ReportCompleted {
+		apierror.Respond(c, apierror.New(http.StatusConflict, apierror.CodeReportNotReady, "Report is not ready").WithDetails(gin.H{"status": report.Status}))
+		return
+	}
+
+	artifact, err := h.reportQueue.Open(c.Request.Context(), report)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to read report", err))
+		return
+	}
+	defer artifact.Close()
+
+	c.DataFromReader(http.StatusOK, report.Size, reports.ContentType(report.Format), artifact, map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": report.Filename}),
+	})
+}
+
+// canAccessReport reports whether the current user may see a generated
+// report: admins see every report of their organization, other users the
+// ones they requested or that were shared with them.
+func (h *AdminHandler) canAccessReport(c *gin.Context, report *models.Report) bool {
+	userID, role, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		return false
+	}
+	if report.RequestedBy != nil && *report.RequestedBy == userID {
+		return true
+	}
+	if role == models.RoleAdmin && report.RequestedBy != nil {
+		// The lookup is scoped, so it fails for other organizations' users
+		if _, err := h.userService.GetUser(c.Request.Context(), *report.RequestedBy); err == nil {
+			return true
 		}
+	}
 
-		// Set headers for PDF download
-		c.Header("Content-Type", "application/pdf")
-		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s_report_%s.pdf", reportType, time.Now().Format("2006-01-02_15-04-05")))
+	shared, err := h.reportService.IsSharedWith(report.ID, userID)
+	if err != nil {
+		log.Printf("Failed to check report %s access: %v", report.ID, err)
+	}
+	return shared
+}
 
-		// Output PDF to response writer
-		err := pdf.Output(c.Writer)
-		if err != nil {
-			log.Printf("Failed to generate PDF: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate PDF report"})
+// GetMyReports lists the reports the current user requested or that were
+// shared with them.
+func (h *AdminHandler) GetMyReports(c *gin.Context) {
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	myReports, err := h.reportService.GetReportsForUser(userID, 50)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get reports", err))
+		return
+	}
+
+	for i := range myReports {
+		myReports[i].DownloadURL = reportDownloadURL(&myReports[i])
+	}
+
+	c.JSON(http.StatusOK, myReports)
+}
+
+// GetReportShares lists the users a report is shared with.
+func (h *AdminHandler) GetReportShares(c *gin.Context) {
+	report, ok := h.ownedReport(c)
+	if !ok {
+		return
+	}
+
+	userIDs, err := h.reportService.GetReportShares(report.ID)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get report shares", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user_ids": userIDs})
+}
+
+// ShareReport gives other users access to a generated report.
+func (h *AdminHandler) ShareReport(c *gin.Context) {
+	report, ok := h.ownedReport(c)
+	if !ok {
+		return
+	}
+
+	var req models.ShareReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.UserIDs) == 0 {
+		apierror.Respond(c, apierror.BadRequest("user_ids must list at least one user"))
+		return
+	}
+
+	for _, id := range req.UserIDs {
+		user, err := h.userService.GetUser(c.Request.Context(), id)
+		if err != nil || !user.IsActive {
+			apierror.Respond(c, apierror.BadRequest("Unknown or inactive user: "+id.String()))
 			return
 		}
-	} else {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported format. Supported formats: json, csv, pdf"})
 	}
+
+	userID, _, _ := middleware.GetCurrentUser(c)
+	if err := h.reportService.ShareReport(report.ID, req.UserIDs, userID); err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to share report", err))
+		return
+	}
+
+	auditLog := &models.AuditLog{
+		ID:         uuid.New(),
+		TableName:  "report_shares",
+		RecordID:   report.ID,
+		Action:     models.ActionCreate,
+		OldValues:  nil,
+		NewValues:  map[string]interface{}{"user_ids": req.UserIDs},
+		ChangedBy:  userID,
+		ChangedAt:  time.Now(),
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.GetHeader("User-Agent"),
+	}
+
+	middleware.MarkAudited(c)
+	if err := h.auditService.Record(c.Request.Context(), auditLog); err != nil {
+		log.Printf("Failed to create audit log: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Report shared successfully"})
+}
+
+// UnshareReport revokes a user's access to a shared report.
+func (h *AdminHandler) UnshareReport(c *gin.Context) {
+	report, ok := h.ownedReport(c)
+	if !ok {
+		return
+	}
+
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid user ID"))
+		return
+	}
+
+	if err := h.reportService.UnshareReport(report.ID, userID); err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.New(http.StatusNotFound, apierror.CodeNotFound, "Report share not found"), err))
+		return
+	}
+
+	changedBy, _, _ := middleware.GetCurrentUser(c)
+	auditLog := &models.AuditLog{
+		ID:         uuid.New(),
+		TableName:  "report_shares",
+		RecordID:   report.ID,
+		Action:     models.ActionDelete,
+		OldValues:  map[string]interface{}{"user_id": userID},
+		NewValues:  nil,
+		ChangedBy:  changedBy,
+		ChangedAt:  time.Now(),
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.GetHeader("User-Agent"),
+	}
+
+	middleware.MarkAudited(c)
+	if err := h.auditService.Record(c.Request.Context(), auditLog); err != nil {
+		log.Printf("Failed to create audit log: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Report unshared successfully"})
+}
+
+// ownedReport loads the report named by the :id parameter for managing its
+// shares, which only its requester and admins may do. It writes the error
+// response and returns false otherwise.
+func (h *AdminHandler) ownedReport(c *gin.Context) (*models.Report, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid report ID"))
+		return nil, false
+	}
+
+	report, err := h.reportQueue.Get(id)
+	if err != nil || !h.canAccessReport(c, report) {
+		apierror.Respond(c, apierror.ErrReportNotFound)
+		return nil, false
+	}
+
+	userID, role, _ := middleware.GetCurrentUser(c)
+	if role != models.RoleAdmin && (report.RequestedBy == nil || *report.RequestedBy != userID) {
+		apierror.Respond(c, apierror.New(http.StatusForbidden, apierror.CodeForbidden, "Only the report's requester can share it"))
+		return nil, false
+	}
+
+	return report, true
+}
+
+// maxLogoSize is the largest logo accepted for report branding.
+const maxLogoSize = 2 << 20
+
+// UploadReportLogo replaces the logo printed in PDF report headers. The file
+// is sent as multipart form field "logo".
+func (h *AdminHandler) UploadReportLogo(c *gin.Context) {
+	fileHeader, err := c.FormFile("logo")
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Logo file is required"))
+		return
+	}
+	if fileHeader.Size > maxLogoSize {
+		apierror.Respond(c, apierror.BadRequest("Logo must be 2MB or smaller"))
+		return
+	}
+
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Failed to read logo"))
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Failed to read logo"))
+		return
+	}
+
+	ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
+	if !reports.LogoExtensions[ext] {
+		apierror.Respond(c, apierror.BadRequest("Logo must be a PNG or JPEG image"))
+		return
+	}
+
+	if err := h.reportQueue.SaveLogo(ext, data); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+
+	// Create audit log
+	auditLog := &models.AuditLog{
+		ID:         uuid.New(),
+		TableName:  "system_settings",
+		RecordID:   uuid.New(), // Using new UUID since settings don't have a specific ID
+		Action:     models.ActionUpdate,
+		OldValues:  nil,
+		NewValues:  map[string]interface{}{reports.SettingLogo: fileHeader.Filename, "size": fileHeader.Size},
+		ChangedBy:  userID,
+		ChangedAt:  time.Now(),
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.GetHeader("User-Agent"),
+	}
+
+	middleware.MarkAudited(c)
+	err = h.auditService.Record(c.Request.Context(), auditLog)
+	if err != nil {
+		log.Printf("Failed to create audit log: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Report logo updated successfully"})
 }
 
 func (h *AdminHandler) GetSystemStatus(c *gin.Context) {
-	status, err := h.settingsService.GetSystemStatus()
+	status, err := h.settingsService.GetSystemStatus(c.Request.Context(), h.redisClient)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get system status: " + err.Error()})
+		apierror.Respond(c, apierror.Failed("Failed to get system status", err))
 		return
 	}
 
+	// WebSocket connection counts live in the hub, not the database
+	status["websocket"] = h.hub.Stats()
+
+	// This instance: its build, how long it has run, and its Go runtime
+	var memory runtime.MemStats
+	runtime.ReadMemStats(&memory)
+	status["version"] = buildinfo.Get()
+	status["uptime"] = gin.H{
+		"started_at": buildinfo.Started(),
+		"seconds":    int64(buildinfo.Uptime().Seconds()),
+		"human":      buildinfo.Uptime().Round(time.Second).String(),
+		"last_check": time.Now(),
+	}
+	status["runtime"] = gin.H{
+		"goroutines": runtime.NumGoroutine(),
+		"heap_alloc": memory.HeapAlloc,
+		"sys":        memory.Sys,
+		"gc_runs":    memory.NumGC,
+		"cpus":       runtime.NumCPU(),
+		"max_procs":  runtime.GOMAXPROCS(0),
+	}
+
 	c.JSON(http.StatusOK, status)
 }
 
@@ -1557,13 +1739,13 @@ func (h *AdminHandler) TriggerBackup(c *gin.Context) {
 	// Get current user for audit logging
 	userID, _, err := middleware.GetCurrentUser(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apierror.Respond(c, apierror.ErrUnauthenticated)
 		return
 	}
 
-	backup, err := h.settingsService.TriggerBackup()
+	backup, err := h.backups.Start(&userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to trigger backup: " + err.Error()})
+		apierror.Respond(c, apierror.Failed("Failed to trigger backup", err))
 		return
 	}
 
@@ -1571,20 +1753,123 @@ func (h *AdminHandler) TriggerBackup(c *gin.Context) {
 	auditLog := &models.AuditLog{
 		ID:         uuid.New(),
 		TableName:  "system",
-		RecordID:   uuid.New(),
+		RecordID:   backup.ID,
 		Action:     models.ActionCreate,
 		OldValues:  nil,
-		NewValues:  map[string]interface{}{"backup_id": backup["backup_id"], "action": "backup_triggered"},
+		NewValues:  map[string]interface{}{"backup_id": backup.ID, "action": "backup_triggered"},
 		ChangedBy:  userID,
 		ChangedAt:  time.Now(),
 		IPAddress:  c.ClientIP(),
 		UserAgent:  c.GetHeader("User-Agent"),
 	}
 
-	err = h.auditService.CreateAuditLog(auditLog)
+	middleware.MarkAudited(c)
+	err = h.auditService.Record(c.Request.Context(), auditLog)
 	if err != nil {
 		log.Printf("Failed to create audit log: %v", err)
 	}
 
-	c.JSON(http.StatusOK, backup)
+	c.JSON(http.StatusAccepted, backup)
+}
+
+// GetBackups lists the 20 most recent backups, newest first.
+func (h *AdminHandler) GetBackups(c *gin.Context) {
+	backups, err := h.backups.Backups(20)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get backups", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"backups": backups})
+}
+
+// getBackup loads the backup named by the id parameter, answering the
+// request itself when it cannot.
+func (h *AdminHandler) getBackup(c *gin.Context) (*models.Backup, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid backup ID"))
+		return nil, false
+	}
+
+	found, err := h.backups.Get(id)
+	if err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrBackupNotFound, err))
+		return nil, false
+	}
+	return found, true
+}
+
+// backupNotReady answers a request for a backup that has not finished.
+func backupNotReady(found *models.Backup) *apierror.Error {
+	return apierror.New(http.StatusConflict, apierror.CodeBackupNotReady, "Backup is not complete").WithDetails(gin.H{"status": found.Status})
+}
+
+// DownloadBackup streams a completed backup's dump. Dumps hold every
+// organization's data, so each download is audited.
+func (h *AdminHandler) DownloadBackup(c *gin.Context) {
+	found, ok := h.getBackup(c)
+	if !ok {
+		return
+	}
+	if found.Status != models.BackupCompleted {
+		apierror.Respond(c, backupNotReady(found))
+		return
+	}
+
+	dump, err := h.backups.Open(c.Request.Context(), found)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to read backup", err))
+		return
+	}
+	defer dump.Close()
+
+	h.auditBackup(c, found, models.ActionView, "backup_downloaded")
+	c.DataFromReader(http.StatusOK, found.Size, "application/octet-stream", dump, map[string]string{
+		"Content-Disposition": "attachment; filename=" + path.Base(found.Location),
+	})
+}
+
+// DeleteBackup removes a finished backup and its dump.
+func (h *AdminHandler) DeleteBackup(c *gin.Context) {
+	found, ok := h.getBackup(c)
+	if !ok {
+		return
+	}
+
+	if err := h.backups.Delete(c.Request.Context(), found); err != nil {
+		if errors.Is(err, backup.ErrRunning) {
+			apierror.Respond(c, backupNotReady(found))
+			return
+		}
+		apierror.Respond(c, apierror.Missing(apierror.ErrBackupNotFound, err))
+		return
+	}
+
+	h.auditBackup(c, found, models.ActionDelete, "backup_deleted")
+	c.JSON(http.StatusOK, gin.H{"message": "Backup deleted successfully"})
+}
+
+func (h *AdminHandler) auditBackup(c *gin.Context, found *models.Backup, action models.AuditAction, event string) {
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		return
+	}
+
+	auditLog := &models.AuditLog{
+		ID:        uuid.New(),
+		TableName: "system",
+		RecordID:  found.ID,
+		Action:    action,
+		NewValues: map[string]interface{}{"backup_id": found.ID, "location": found.Location, "action": event},
+		ChangedBy: userID,
+		ChangedAt: time.Now(),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+	}
+
+	middleware.MarkAudited(c)
+	if err := h.auditService.Record(c.Request.Context(), auditLog); err != nil {
+		log.Printf("Failed to create audit log: %v", err)
+	}
 }
\ No newline at end of file
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"rtims-backend/internal/alerting"
+	"rtims-backend/internal/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AlertHandler checks the alert sinks configured in settings.
+type AlertHandler struct {
+	router *alerting.Router
+}
+
+func NewAlertHandler(router *alerting.Router) *AlertHandler {
+	return &AlertHandler{router: router}
+}
+
+// alertError maps the errors of alert sinks to responses, and others as
+// apierror.Failed.
+func alertError(message string, err error) *apierror.Error {
+	var apiErr *alerting.APIError
+	var urlErr *url.Error
+	switch {
+	case errors.Is(err, alerting.ErrUnknownSink):
+		return apierror.ErrAlertSinkNotFound.Wrap(err)
+	case errors.As(err, &apiErr), errors.As(err, &urlErr):
+		return apierror.ErrAlertSinkUnavailable.Wrap(err)
+	}
+	return apierror.Failed(message, err)
+}
+
+// TestSink raises a test alert in a sink, whatever the routes, to check
+// that it is set up.
+func (h *AlertHandler) TestSink(c *gin.Context) {
+	if err := h.router.Test(c.Request.Context(), c.Param("sink")); err != nil {
+		apierror.Respond(c, alertError("Failed to send test alert", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Test alert sent successfully"})
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"rtims-backend/internal/apierror"
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/middleware"
+	"rtims-backend/internal/models"
+	"rtims-backend/internal/websocket"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type AnnouncementHandler struct {
+	announcementService *database.AnnouncementService
+	auditService        *database.AuditService
+	db                  *sql.DB
+	hub                 *websocket.Hub
+}
+
+func NewAnnouncementHandler(db *sql.DB, hub *websocket.Hub) *AnnouncementHandler {
+	return &AnnouncementHandler{
+		announcementService: database.NewAnnouncementService(db),
+		auditService:        database.NewAuditService(db),
+		db:                  db,
+		hub:                 hub,
+	}
+}
+
+func (h *AnnouncementHandler) CreateAnnouncement(c *gin.Context) {
+	var req models.CreateAnnouncementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+
+	// Validate input
+	if req.Title == "" || req.Message == "" {
+		apierror.Respond(c, apierror.BadRequest("Title and message are required"))
+		return
+	}
+	if req.Severity == "" {
+		req.Severity = models.SeverityInfo
+	}
+	switch req.Severity {
+	case models.SeverityInfo, models.SeverityWarning, models.SeverityCritical:
+	default:
+		apierror.Respond(c, apierror.BadRequest("Severity must be one of: info, warning, critical"))
+		return
+	}
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+		apierror.Respond(c, apierror.BadRequest("Expiry must be in the future"))
+		return
+	}
+
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	announcement := &models.Announcement{
+		ID:        uuid.New(),
+		Title:     req.Title,
+		Message:   req.Message,
+		Severity:  req.Severity,
+		ExpiresAt: req.ExpiresAt,
+		CreatedBy: userID,
+		CreatedAt: time.Now(),
+	}
+
+	err = h.announcementService.CreateAnnouncement(announcement)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to create announcement", err))
+		return
+	}
+
+	// Create audit log
+	auditLog := &models.AuditLog{
+		ID:         uuid.New(),
+		TableName:  "announcements",
+		RecordID:   announcement.ID,
+		Action:     models.ActionCreate,
+		OldValues:  nil,
+		NewValues:  map[string]interface{}{"title": req.Title, "message": req.Message, "severity": req.Severity, "expires_at": req.ExpiresAt},
+		ChangedBy:  userID,
+		ChangedAt:  time.Now(),
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.GetHeader("User-Agent"),
+	}
+
+	middleware.MarkAudited(c)
+	err = h.auditService.Record(c.Request.Context(), auditLog)
+	if err != nil {
+		log.Printf("Failed to create audit log: %v", err)
+	}
+
+	// Push to every connected client
+	websocket.BroadcastAnnouncement(h.hub, *announcement)
+
+	c.JSON(http.StatusCreated, announcement)
+}
+
+func (h *AnnouncementHandler) GetActiveAnnouncements(c *gin.Context) {
+	announcements, err := h.announcementService.GetActiveAnnouncements()
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get announcements", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, announcements)
+}
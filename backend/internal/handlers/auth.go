@@ -8,9 +8,11 @@ import (
 	"net/http"
 	"time"
 
+	"rtims-backend/internal/apierror"
 	"rtims-backend/internal/database"
 	"rtims-backend/internal/models"
 	"rtims-backend/internal/middleware"
+	"rtims-backend/internal/reports"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
@@ -22,6 +24,7 @@ import (
 var jwtSecret []byte
 var userService *database.UserService
 var auditService *database.AuditService
+var organizationService *database.OrganizationService
 var redisClient *redis.Client
 var emailService *EmailService
 var ctx = context.Background()
@@ -44,6 +47,7 @@ func InitAuthHandlers(secret []byte, db *sql.DB, redis *redis.Client) {
 	jwtSecret = secret
 	userService = database.NewUserService(db)
 	auditService = database.NewAuditService(db)
+	organizationService = database.NewOrganizationService(db)
 	redisClient = redis
 	emailService = NewEmailService()
 }
@@ -51,14 +55,14 @@ func InitAuthHandlers(secret []byte, db *sql.DB, redis *redis.Client) {
 func Register(c *gin.Context) {
 	var req models.RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, apierror.Invalid(err))
 		return
 	}
 
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		apierror.Respond(c, apierror.Failed("Failed to hash password", err))
 		return
 	}
 
@@ -72,12 +76,15 @@ func Register(c *gin.Context) {
 		IsActive:  true,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
+		// Sign-ups join the default organization; other organizations'
+		// admins create their users
+		OrganizationID: database.DefaultOrganizationID,
 	}
 
 	// Save to database
-	err = userService.CreateUser(&user)
+	err = userService.CreateUser(c.Request.Context(), &user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user: " + err.Error()})
+		apierror.Respond(c, apierror.Failed("Failed to create user", err))
 		return
 	}
 
@@ -95,7 +102,7 @@ func Register(c *gin.Context) {
 		UserAgent:  c.GetHeader("User-Agent"),
 	}
 
-	err = auditService.CreateAuditLog(auditLog)
+	err = auditService.Record(c.Request.Context(), auditLog)
 	if err != nil {
 		// Log error but don't fail the request
 		log.Printf("Failed to create audit log: %v", err)
@@ -104,7 +111,7 @@ func Register(c *gin.Context) {
 	// Generate tokens
 	accessToken, _, err := generateTokens(user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
+		apierror.Respond(c, apierror.Failed("Failed to generate tokens", err))
 		return
 	}
 
@@ -121,109 +128,185 @@ func Register(c *gin.Context) {
 func Login(c *gin.Context) {
   var req models.LoginRequest
   if err := c.ShouldBindJSON(&req); err != nil {
-    c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    apierror.Respond(c, apierror.Invalid(err))
     return
   }
 
   // Get user from database
-  user, err := userService.GetUserByEmail(req.Email)
+  user, err := userService.GetUserByEmail(c.Request.Context(), req.Email)
   if err != nil {
-  	c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+  	apierror.Respond(c, apierror.ErrInvalidCredentials)
   	return
   }
 
   // Verify password against hashed password in database
   err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password))
   if err != nil {
-  	c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+  	logAuthEvent(c, user.ID, models.ActionLogin, "login_failed", http.StatusUnauthorized, "invalid password")
+  	apierror.Respond(c, apierror.ErrInvalidCredentials)
   	return
   }
 
   // Check if user is active
   if !user.IsActive {
-  	c.JSON(http.StatusUnauthorized, gin.H{"error": "Account is deactivated"})
+  	logAuthEvent(c, user.ID, models.ActionLogin, "login_failed", http.StatusUnauthorized, "account is deactivated")
+  	apierror.Respond(c, apierror.ErrAccountDisabled)
+  	return
+  }
+  if err := checkOrganization(c, user); err != nil {
+  	logAuthEvent(c, user.ID, models.ActionLogin, "login_failed", http.StatusForbidden, "organization is deactivated")
+  	apierror.Respond(c, err)
   	return
   }
 
   // Generate tokens
   accessToken, refreshTokenString, err := generateTokens(*user)
   if err != nil {
-  	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
+  	apierror.Respond(c, apierror.Failed("Failed to generate tokens", err))
   	return
   }
 
-  response := models.AuthResponse{
-  	User:        *user,
-  	AccessToken: accessToken,
-  	TokenType:   "Bearer",
-  	ExpiresIn:   3600, // 1 hour
-  }
-
-  // Save refresh token to Redis (24 hours expiry)
+  // Save refresh token to Redis (24 hours expiry). Without Redis the token
+  // is issued stateless, so the session survives the outage
   refreshTokenKey := "refresh_token:" + refreshTokenString
-  err = redisClient.Set(ctx, refreshTokenKey, user.ID.String(), 24*time.Hour).Err()
+  err = redisClient.Set(ctx, refreshTokenKey, user.ID.String(), refreshTokenTTL).Err()
   if err != nil {
-  	log.Printf("Failed to save refresh token to Redis: %v", err)
+  	log.Printf("Failed to save refresh token to Redis, issuing a stateless one: %v", err)
+  	refreshTokenString, err = signRefreshToken(user.ID, true)
+  	if err != nil {
+  		apierror.Respond(c, apierror.Failed("Failed to generate tokens", err))
+  		return
+  	}
+  }
+
+  response := models.AuthResponse{
+  	User:         *user,
+  	AccessToken:  accessToken,
+  	RefreshToken: refreshTokenString,
+  	TokenType:    "Bearer",
+  	ExpiresIn:    3600, // 1 hour
   }
 
+  logAuthEvent(c, user.ID, models.ActionLogin, "login", http.StatusOK, "")
   c.JSON(http.StatusOK, response)
 }
 
 func RefreshToken(c *gin.Context) {
 	var req models.RefreshTokenRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, apierror.Invalid(err))
 		return
 	}
 
-	// Validate refresh token from Redis
-		tokenKey := "refresh_token:" + req.RefreshToken
-		userIDStr, err := redisClient.Get(ctx, tokenKey).Result()
-	if err != nil || userIDStr == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+	// Validate refresh token against Redis, or from its signature alone
+	// while Redis is down
+	userID, err := validateRefreshToken(req.RefreshToken)
+	if err != nil {
+		apierror.Respond(c, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid refresh token"))
 		return
 	}
 
-	// Parse user ID from Redis
-	userID, err := uuid.Parse(userIDStr)
+	// Get user from database
+	user, err := userService.GetUser(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		apierror.Respond(c, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "User not found"))
 		return
 	}
 
-	// Get user from database
-	user, err := userService.GetUser(userID)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+	if !user.IsActive {
+		logAuthEvent(c, user.ID, models.ActionLogin, "token_refresh_failed", http.StatusUnauthorized, "account is deactivated")
+		apierror.Respond(c, apierror.ErrAccountDisabled)
+		return
+	}
+	if err := checkOrganization(c, user); err != nil {
+		logAuthEvent(c, user.ID, models.ActionLogin, "token_refresh_failed", http.StatusForbidden, "organization is deactivated")
+		apierror.Respond(c, err)
 		return
 	}
 
 	// Generate new access token
 	accessToken, _, err := generateTokens(*user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate access token"})
+		apierror.Respond(c, apierror.Failed("Failed to generate access token", err))
 		return
 	}
 
 	response := models.AuthResponse{
-		User:        *user,
-		AccessToken: accessToken,
-		TokenType:   "Bearer",
-		ExpiresIn:   3600,
+		User:         *user,
+		AccessToken:  accessToken,
+		RefreshToken: req.RefreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    3600,
 	}
 
+	logAuthEvent(c, user.ID, models.ActionLogin, "token_refresh", http.StatusOK, "")
 	c.JSON(http.StatusOK, response)
 }
 
+// Logout revokes the refresh token so it can no longer be exchanged for
+// access tokens.
+func Logout(c *gin.Context) {
+	var req models.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+
+	userID, err := validateRefreshToken(req.RefreshToken)
+	if err != nil {
+		// Already logged out or expired
+		c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+		return
+	}
+	// Mark the token revoked rather than deleting it, so a stateless token
+	// is not adopted again
+	if err := redisClient.Set(ctx, "refresh_token:"+req.RefreshToken, revokedRefreshToken, refreshTokenTTL).Err(); err != nil {
+		apierror.Respond(c, apierror.New(http.StatusServiceUnavailable, apierror.CodeUnavailable, "Failed to revoke refresh token, try again shortly").Wrap(err))
+		return
+	}
+
+	logAuthEvent(c, userID, models.ActionLogout, "logout", http.StatusOK, "")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// logAuthEvent records a sign-in related event against the user's record.
+// Attempts for unknown emails cannot be recorded, as audit entries must
+// reference a user.
+func logAuthEvent(c *gin.Context, userID uuid.UUID, action models.AuditAction, event string, status int, errorMessage string) {
+	auditLog := &models.AuditLog{
+		ID:           uuid.New(),
+		TableName:    "users",
+		RecordID:     userID,
+		Action:       action,
+		NewValues:    map[string]interface{}{"event": event},
+		ChangedBy:    userID,
+		ChangedAt:    time.Now(),
+		IPAddress:    c.ClientIP(),
+		UserAgent:    c.GetHeader("User-Agent"),
+		StatusCode:   status,
+		ErrorMessage: errorMessage,
+	}
+
+	if err := auditService.Record(c.Request.Context(), auditLog); err != nil {
+		log.Printf("Failed to create audit log: %v", err)
+	}
+}
+
 func ForgotPassword(c *gin.Context) {
 	var req struct {
 		Email string `json:"email" validate:"required,email"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, apierror.Invalid(err))
 		return
 	}
 
+	// Record the request against the account, if there is one
+	if user, err := userService.GetUserByEmail(c.Request.Context(), req.Email); err == nil {
+		logAuthEvent(c, user.ID, models.ActionUpdate, "password_reset_requested", http.StatusOK, "")
+	}
+
 	// Generate reset token
 	resetToken := uuid.New().String()
 
@@ -231,16 +314,16 @@ func ForgotPassword(c *gin.Context) {
 	resetTokenKey := "password_reset:" + resetToken
 	err := redisClient.Set(ctx, resetTokenKey, req.Email, time.Hour).Err()
 	if err != nil {
+		// Reset tokens live only in Redis, so resets wait for it to return
 		log.Printf("Failed to store password reset token: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process password reset request"})
+		apierror.Respond(c, apierror.New(http.StatusServiceUnavailable, apierror.CodeUnavailable, "Password reset is temporarily unavailable, try again shortly"))
 		return
 	}
 
 	// Send password reset email using the email service
 	err = emailService.SendPasswordResetEmail(req.Email, resetToken)
 	if err != nil {
-		log.Printf("Failed to send password reset email: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send password reset email"})
+		apierror.Respond(c, apierror.Failed("Failed to send password reset email", err))
 		return
 	}
 
@@ -253,13 +336,13 @@ func ResetPassword(c *gin.Context) {
 		Password string `json:"password" validate:"required,min=8"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, apierror.Invalid(err))
 		return
 	}
 
 	// Validate password strength
 	if len(req.Password) < 8 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Password must be at least 8 characters long"})
+		apierror.Respond(c, apierror.BadRequest("Password must be at least 8 characters long"))
 		return
 	}
 
@@ -267,31 +350,28 @@ func ResetPassword(c *gin.Context) {
 	resetTokenKey := "password_reset:" + req.Token
 	email, err := redisClient.Get(ctx, resetTokenKey).Result()
 	if err != nil || email == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		apierror.Respond(c, apierror.BadRequest("Invalid or expired reset token"))
 		return
 	}
 
 	// Get user by email
-	user, err := userService.GetUserByEmail(email)
+	user, err := userService.GetUserByEmail(c.Request.Context(), email)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		apierror.Respond(c, apierror.Missing(apierror.ErrUserNotFound, err))
 		return
 	}
 
 	// Hash new password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		apierror.Respond(c, apierror.Failed("Failed to hash password", err))
 		return
 	}
 
 	// Update user password in database
-	updates := map[string]interface{}{
-		"password": string(hashedPassword),
-	}
-	err = userService.UpdateUser(user.ID, updates)
+	err = userService.SetPassword(c.Request.Context(), user.ID, string(hashedPassword))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password: " + err.Error()})
+		apierror.Respond(c, apierror.Failed("Failed to update password", err))
 		return
 	}
 
@@ -305,14 +385,14 @@ func ResetPassword(c *gin.Context) {
 		RecordID:   user.ID,
 		Action:     models.ActionUpdate,
 		OldValues:  map[string]interface{}{"password": "[REDACTED]"},
-		NewValues:  map[string]interface{}{"password": "[REDACTED]"},
+		NewValues:  map[string]interface{}{"password": "[REDACTED]", "event": "password_reset"},
 		ChangedBy:  user.ID,
 		ChangedAt:  time.Now(),
 		IPAddress:  c.ClientIP(),
 		UserAgent:  c.GetHeader("User-Agent"),
 	}
 
-	err = auditService.CreateAuditLog(auditLog)
+	err = auditService.Record(c.Request.Context(), auditLog)
 	if err != nil {
 		log.Printf("Failed to create audit log: %v", err)
 	}
@@ -323,14 +403,14 @@ func ResetPassword(c *gin.Context) {
 func GetProfile(c *gin.Context) {
 	userID, _, err := middleware.GetCurrentUser(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apierror.Respond(c, apierror.ErrUnauthenticated)
 		return
 	}
 
 	// Get user profile from database
-	user, err := userService.GetUser(userID)
+	user, err := userService.GetUser(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		apierror.Respond(c, apierror.Missing(apierror.ErrUserNotFound, err))
 		return
 	}
 
@@ -340,20 +420,20 @@ func GetProfile(c *gin.Context) {
 func UpdateProfile(c *gin.Context) {
 	userID, _, err := middleware.GetCurrentUser(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apierror.Respond(c, apierror.ErrUnauthenticated)
 		return
 	}
 
 	var req models.UpdateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, apierror.Invalid(err))
 		return
 	}
 
 	// Get old user data for audit log
-	oldUser, err := userService.GetUser(userID)
+	oldUser, err := userService.GetUser(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		apierror.Respond(c, apierror.Missing(apierror.ErrUserNotFound, err))
 		return
 	}
 
@@ -371,18 +451,25 @@ func UpdateProfile(c *gin.Context) {
 	if req.IsActive != nil {
 		updates["is_active"] = *req.IsActive
 	}
+	if req.Locale != nil {
+		if _, ok := reports.LookupLocale(*req.Locale); *req.Locale != "" && !ok {
+			apierror.Respond(c, apierror.Invalid(reports.ErrUnknownLocale))
+			return
+		}
+		updates["locale"] = *req.Locale
+	}
 
 	// Update user profile in database
-	err = userService.UpdateUser(userID, updates)
+	err = userService.UpdateUser(c.Request.Context(), userID, updates)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user profile: " + err.Error()})
+		apierror.Respond(c, apierror.Failed("Failed to update user profile", err))
 		return
 	}
 
 	// Get updated user
-	user, err := userService.GetUser(userID)
+	user, err := userService.GetUser(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get updated user: " + err.Error()})
+		apierror.Respond(c, apierror.Failed("Failed to get updated user", err))
 		return
 	}
 
@@ -393,23 +480,40 @@ func UpdateProfile(c *gin.Context) {
 			"email":    oldUser.Email,
 			"role":     oldUser.Role,
 			"is_active": oldUser.IsActive,
+			"locale":   oldUser.Locale,
 		},
 		map[string]interface{}{
 			"name":     user.Name,
 			"email":    user.Email,
 			"role":     user.Role,
 			"is_active": user.IsActive,
+			"locale":   user.Locale,
 		})
 
 	c.JSON(http.StatusOK, user)
 }
 
+// checkOrganization returns an error unless the user's organization is
+// active, so deactivating an organization locks out all its users once
+// their access tokens expire.
+func checkOrganization(c *gin.Context, user *models.User) error {
+	organization, err := organizationService.GetOrganization(c.Request.Context(), user.OrganizationID)
+	if err != nil {
+		return apierror.Missing(apierror.ErrOrganizationNotFound, err)
+	}
+	if !organization.IsActive {
+		return apierror.ErrOrganizationInactive
+	}
+	return nil
+}
+
 func generateTokens(user models.User) (string, string, error) {
  	// Generate access token (1 hour)
  	accessClaims := models.Claims{
  		UserID: user.ID,
  		Email:  user.Email,
  		Role:   user.Role,
+ 		OrganizationID: user.OrganizationID,
  		RegisteredClaims: jwt.RegisteredClaims{
  			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
  			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -423,18 +527,10 @@ func generateTokens(user models.User) (string, string, error) {
  		return "", "", fmt.Errorf("failed to generate access token: %w", err)
  	}
 
- 	// Generate refresh token (24 hours) - using different secret for security
- 	refreshClaims := jwt.RegisteredClaims{
- 		Subject:   user.ID.String(),
- 		ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
- 		IssuedAt:  jwt.NewNumericDate(time.Now()),
- 		ID:       uuid.New().String(), // Unique token ID
- 	}
-
- 	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
- 	refreshTokenString, err := refreshToken.SignedString(jwtSecret)
+ 	// Generate refresh token (24 hours)
+ 	refreshTokenString, err := signRefreshToken(user.ID, false)
  	if err != nil {
- 		return "", "", fmt.Errorf("failed to generate refresh token: %w", err)
+ 		return "", "", err
  	}
 
  	return accessTokenString, refreshTokenString, nil
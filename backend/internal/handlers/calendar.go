@@ -0,0 +1,296 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"rtims-backend/internal/apierror"
+	"rtims-backend/internal/calendar"
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/middleware"
+	"rtims-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// calendarPeriod is the period of the calendar events listed when no end
+// date is given.
+const calendarPeriod = 90 * 24 * time.Hour
+
+// CalendarHandler manages the scheduled stocktakes and maintenance windows
+// of the admin's organization and serves the calendar feeds of users.
+type CalendarHandler struct {
+	calendarService *database.CalendarService
+	auditService    *database.AuditService
+	feed            *calendar.Feed
+	publicURL       string
+}
+
+func NewCalendarHandler(db *sql.DB, feed *calendar.Feed, publicURL string) *CalendarHandler {
+	return &CalendarHandler{
+		calendarService: database.NewCalendarService(db),
+		auditService:    database.NewAuditService(db),
+		feed:            feed,
+		publicURL:       strings.TrimSuffix(publicURL, "/"),
+	}
+}
+
+// GetEvents returns the calendar events overlapping start_date to
+// end_date, by default the next 90 days.
+func (h *CalendarHandler) GetEvents(c *gin.Context) {
+	var filter models.CalendarEventFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+
+	from := time.Now()
+	if filter.StartDate != nil {
+		from = *filter.StartDate
+	}
+	to := from.Add(calendarPeriod)
+	if filter.EndDate != nil {
+		to = *filter.EndDate
+	}
+	if from.After(to) {
+		apierror.Respond(c, apierror.BadRequest("start_date must be before end_date"))
+		return
+	}
+
+	events, err := h.calendarService.GetCalendarEvents(c.Request.Context(), from, to)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get calendar events", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+func (h *CalendarHandler) CreateEvent(c *gin.Context) {
+	var req models.CreateCalendarEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+	if !req.EndsAt.After(req.StartsAt) {
+		apierror.Respond(c, apierror.BadRequest("ends_at must be after starts_at"))
+		return
+	}
+
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	now := time.Now()
+	created := &models.CalendarEvent{
+		ID:          uuid.New(),
+		Kind:        req.Kind,
+		Title:       req.Title,
+		Description: req.Description,
+		StartsAt:    req.StartsAt,
+		EndsAt:      req.EndsAt,
+		CreatedBy:   &userID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := h.calendarService.CreateCalendarEvent(c.Request.Context(), created); err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to create calendar event", err))
+		return
+	}
+
+	h.audit(c, userID, created.ID, models.ActionCreate, nil, calendarEventValues(created))
+
+	c.JSON(http.StatusCreated, created)
+}
+
+func (h *CalendarHandler) UpdateEvent(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid calendar event ID"))
+		return
+	}
+
+	var req models.UpdateCalendarEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	old, err := h.calendarService.GetCalendarEvent(c.Request.Context(), id)
+	if err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrCalendarEventNotFound, err))
+		return
+	}
+
+	startsAt, endsAt := old.StartsAt, old.EndsAt
+	if req.StartsAt != nil {
+		startsAt = *req.StartsAt
+	}
+	if req.EndsAt != nil {
+		endsAt = *req.EndsAt
+	}
+	if !endsAt.After(startsAt) {
+		apierror.Respond(c, apierror.BadRequest("ends_at must be after starts_at"))
+		return
+	}
+
+	if err := h.calendarService.UpdateCalendarEvent(c.Request.Context(), id, req); err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrCalendarEventNotFound, err))
+		return
+	}
+
+	updated, err := h.calendarService.GetCalendarEvent(c.Request.Context(), id)
+	if err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrCalendarEventNotFound, err))
+		return
+	}
+
+	h.audit(c, userID, id, models.ActionUpdate, calendarEventValues(old), calendarEventValues(updated))
+
+	c.JSON(http.StatusOK, updated)
+}
+
+func (h *CalendarHandler) DeleteEvent(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid calendar event ID"))
+		return
+	}
+
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	old, err := h.calendarService.GetCalendarEvent(c.Request.Context(), id)
+	if err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrCalendarEventNotFound, err))
+		return
+	}
+
+	if err := h.calendarService.DeleteCalendarEvent(c.Request.Context(), id); err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrCalendarEventNotFound, err))
+		return
+	}
+
+	h.audit(c, userID, id, models.ActionDelete, calendarEventValues(old), nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Calendar event deleted successfully"})
+}
+
+// GetFeed returns the link of the current user's calendar feed.
+func (h *CalendarHandler) GetFeed(c *gin.Context) {
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	token, err := h.feed.Token(c.Request.Context(), userID)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get calendar feed", err))
+		return
+	}
+	c.JSON(http.StatusOK, h.feedLink(token))
+}
+
+// RegenerateFeed gives the current user's calendar feed a new link, for
+// when the old one has been shared too widely. The old link stops working.
+func (h *CalendarHandler) RegenerateFeed(c *gin.Context) {
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	token, err := h.feed.Regenerate(c.Request.Context(), userID)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to regenerate calendar feed", err))
+		return
+	}
+
+	// Recorded here, as the response holds the new link
+	middleware.MarkAudited(c)
+	auditLog := &models.AuditLog{
+		ID:        uuid.New(),
+		TableName: "users",
+		RecordID:  userID,
+		Action:    models.ActionUpdate,
+		NewValues: map[string]interface{}{"calendar_feed": "regenerated"},
+		ChangedBy: userID,
+		ChangedAt: time.Now(),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+	}
+	if err := h.auditService.Record(c.Request.Context(), auditLog); err != nil {
+		log.Printf("Failed to create audit log: %v", err)
+	}
+
+	c.JSON(http.StatusOK, h.feedLink(token))
+}
+
+func (h *CalendarHandler) feedLink(token string) models.CalendarFeed {
+	return models.CalendarFeed{URL: h.publicURL + "/api/v1/calendar/" + token + "/feed.ics"}
+}
+
+// ServeFeed serves a calendar feed to calendar apps, which authenticate
+// with the signed token in its link.
+func (h *CalendarHandler) ServeFeed(c *gin.Context) {
+	body, err := h.feed.Render(c.Request.Context(), c.Param("token"), time.Now())
+	if errors.Is(err, calendar.ErrInvalidToken) {
+		apierror.Respond(c, apierror.ErrCalendarFeedInvalid)
+		return
+	}
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get calendar feed", err))
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", body)
+}
+
+func (h *CalendarHandler) audit(c *gin.Context, userID, id uuid.UUID, action models.AuditAction, oldValues, newValues map[string]interface{}) {
+	auditLog := &models.AuditLog{
+		ID:        uuid.New(),
+		TableName: "calendar_events",
+		RecordID:  id,
+		Action:    action,
+		OldValues: oldValues,
+		NewValues: newValues,
+		ChangedBy: userID,
+		ChangedAt: time.Now(),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+	}
+	middleware.MarkAudited(c)
+	if err := h.auditService.Record(c.Request.Context(), auditLog); err != nil {
+		log.Printf("Failed to create audit log: %v", err)
+	}
+}
+
+// calendarEventValues are the fields of a calendar event recorded in the
+// audit trail.
+func calendarEventValues(e *models.CalendarEvent) map[string]interface{} {
+	return map[string]interface{}{
+		"kind":        e.Kind,
+		"title":       e.Title,
+		"description": e.Description,
+		"starts_at":   e.StartsAt,
+		"ends_at":     e.EndsAt,
+	}
+}
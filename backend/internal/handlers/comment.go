@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"rtims-backend/internal/apierror"
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/i18n"
+	"rtims-backend/internal/middleware"
+	"rtims-backend/internal/models"
+	"rtims-backend/internal/notify"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// mentionExcerptLength is the most characters of a comment quoted in the
+// notifications of its mentions.
+const mentionExcerptLength = 140
+
+// CommentHandler manages the comment threads of products and stock
+// movements, and notifies the users mentioned in them.
+type CommentHandler struct {
+	commentService      *database.CommentService
+	productService      *database.ProductService
+	userService         *database.UserService
+	notificationService *database.NotificationService
+	dispatcher          *notify.Dispatcher
+}
+
+func NewCommentHandler(db *sql.DB, cache *database.Cache, dispatcher *notify.Dispatcher) *CommentHandler {
+	return &CommentHandler{
+		commentService:      database.NewCommentService(db),
+		productService:      database.NewCachedProductService(db, cache),
+		userService:         database.NewUserService(db),
+		notificationService: database.NewNotificationService(db),
+		dispatcher:          dispatcher,
+	}
+}
+
+// GetProductComments returns the comments on a product, oldest first.
+func (h *CommentHandler) GetProductComments(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid product ID"))
+		return
+	}
+	product, err := h.productService.GetProduct(c.Request.Context(), id)
+	if err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrProductNotFound, err))
+		return
+	}
+
+	comments, err := h.commentService.GetProductComments(c.Request.Context(), product.ID)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get comments", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, comments)
+}
+
+// CreateProductComment comments on a product.
+func (h *CommentHandler) CreateProductComment(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid product ID"))
+		return
+	}
+	product, err := h.productService.GetProduct(c.Request.Context(), id)
+	if err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrProductNotFound, err))
+		return
+	}
+
+	h.create(c, product, nil)
+}
+
+// GetMovementComments returns the comments on a stock movement, oldest
+// first.
+func (h *CommentHandler) GetMovementComments(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid movement ID"))
+		return
+	}
+	movement, err := h.productService.GetStockMovement(c.Request.Context(), id)
+	if err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrMovementNotFound, err))
+		return
+	}
+
+	comments, err := h.commentService.GetMovementComments(c.Request.Context(), movement.ID)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get comments", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, comments)
+}
+
+// CreateMovementComment comments on a stock movement.
+func (h *CommentHandler) CreateMovementComment(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid movement ID"))
+		return
+	}
+	movement, err := h.productService.GetStockMovement(c.Request.Context(), id)
+	if err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrMovementNotFound, err))
+		return
+	}
+	product, err := h.productService.GetProduct(c.Request.Context(), movement.ProductID)
+	if err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrProductNotFound, err))
+		return
+	}
+
+	h.create(c, product, movement)
+}
+
+// create saves the comment in the request body on product, or on movement
+// when it is not nil, and notifies the users it mentions.
+func (h *CommentHandler) create(c *gin.Context, product *models.Product, movement *models.StockMovement) {
+	var req models.CreateCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+	author, err := h.userService.GetUser(c.Request.Context(), userID)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get user", err))
+		return
+	}
+
+	mentioned, err := h.commentService.GetMentionedUsers(c.Request.Context(), req.Body)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to resolve mentions", err))
+		return
+	}
+
+	comment := &models.Comment{
+		ID:         uuid.New(),
+		ProductID:  product.ID,
+		AuthorID:   &userID,
+		AuthorName: author.Name,
+		Body:       req.Body,
+		Mentions:   make([]uuid.UUID, 0, len(mentioned)),
+		CreatedAt:  time.Now(),
+	}
+	if movement != nil {
+		comment.MovementID = &movement.ID
+	}
+	for _, user := range mentioned {
+		comment.Mentions = append(comment.Mentions, user.ID)
+	}
+
+	if err := h.commentService.CreateComment(c.Request.Context(), comment); err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to create comment", err))
+		return
+	}
+
+	for i := range mentioned {
+		if mentioned[i].ID == userID {
+			continue
+		}
+		h.notifyMention(&mentioned[i], author, product, movement, comment)
+	}
+
+	c.JSON(http.StatusCreated, comment)
+}
+
+// notifyMention tells user that author mentioned them in comment, over the
+// channels of their notification preferences.
+func (h *CommentHandler) notifyMention(user, author *models.User, product *models.Product, movement *models.StockMovement, comment *models.Comment) {
+	excerpt := comment.Body
+	if utf8.RuneCountInString(excerpt) > mentionExcerptLength {
+		excerpt = string([]rune(excerpt)[:mentionExcerptLength]) + "…"
+	}
+
+	var message string
+	entityType, entityID := "product", product.ID
+	if movement != nil {
+		message = fmt.Sprintf(i18n.T(user.Locale, "%s mentioned you on a stock movement of %s: %s"), author.Name, product.Name, excerpt)
+		entityType, entityID = "stock_movement", movement.ID
+	} else {
+		message = fmt.Sprintf(i18n.T(user.Locale, "%s mentioned you on %s: %s"), author.Name, product.Name, excerpt)
+	}
+
+	notification := &models.Notification{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		Message:   message,
+		Type:      models.NotificationUser,
+		IsRead:    false,
+		CreatedAt: time.Now(),
+		Metadata: &models.NotificationMetadata{
+			EntityType: entityType,
+			EntityID:   &entityID,
+			Action:     models.ActionViewProduct,
+			Link:       "/products?id=" + product.ID.String(),
+		},
+	}
+	if err := h.notificationService.CreateNotification(notification); err != nil {
+		log.Printf("Failed to notify %s of comment %s: %v", user.Email, comment.ID, err)
+		return
+	}
+	h.dispatcher.Deliver(notification)
+}
+
+// DeleteComment deletes a comment. Users delete their own comments and
+// admins any comment.
+func (h *CommentHandler) DeleteComment(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid comment ID"))
+		return
+	}
+
+	userID, role, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	comment, err := h.commentService.GetComment(c.Request.Context(), id)
+	if err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrCommentNotFound, err))
+		return
+	}
+	if role != models.RoleAdmin && (comment.AuthorID == nil || *comment.AuthorID != userID) {
+		apierror.Respond(c, apierror.ErrForbidden)
+		return
+	}
+
+	if err := h.commentService.DeleteComment(c.Request.Context(), id); err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrCommentNotFound, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Comment deleted successfully"})
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"rtims-backend/internal/apierror"
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/edi"
+	"rtims-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// EDIHandler reports on the EDI trading partners configured in settings
+// and sends them inventory advice on demand.
+type EDIHandler struct {
+	ediService *database.EDIService
+	sender     *edi.Sender
+}
+
+func NewEDIHandler(db *sql.DB, sender *edi.Sender) *EDIHandler {
+	return &EDIHandler{ediService: database.NewEDIService(db), sender: sender}
+}
+
+// ediError maps the errors of the EDI integration to responses, and
+// others as apierror.Failed.
+func ediError(message string, err error) *apierror.Error {
+	switch {
+	case errors.Is(err, edi.ErrUnknownPartner):
+		return apierror.ErrEDIPartnerNotFound.Wrap(err)
+	case errors.Is(err, edi.ErrOrganization):
+		return apierror.ErrEDIOrganization.Wrap(err)
+	}
+	return apierror.Failed(message, err)
+}
+
+// GetPartners returns the configured partners with their last document.
+func (h *EDIHandler) GetPartners(c *gin.Context) {
+	partners, err := h.sender.Status(c.Request.Context())
+	if err != nil {
+		apierror.Respond(c, ediError("Failed to get EDI partners", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, partners)
+}
+
+// Send sends a partner its inventory advice now rather than at the next
+// scheduled run. A document that could not be delivered is returned with
+// status failed and the delivery error.
+func (h *EDIHandler) Send(c *gin.Context) {
+	document, err := h.sender.Send(c.Request.Context(), c.Param("partner"))
+	if err != nil {
+		apierror.Respond(c, ediError("Failed to send EDI inventory advice", err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, document)
+}
+
+func (h *EDIHandler) GetDocuments(c *gin.Context) {
+	var filter models.EDIDocumentFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+
+	documents, err := h.ediService.GetEDIDocuments(c.Request.Context(), filter)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get EDI documents", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, documents)
+}
+
+// DownloadDocument returns a document as it was sent.
+func (h *EDIHandler) DownloadDocument(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid document ID"))
+		return
+	}
+
+	document, err := h.ediService.GetEDIDocument(c.Request.Context(), id)
+	if err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrEDIDocumentNotFound, err))
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", document.Filename))
+	c.Data(http.StatusOK, "application/EDI-X12", document.Content)
+}
+
+// ResendDocument delivers a document again, such as one that failed or
+// one the partner lost, with its original control number.
+func (h *EDIHandler) ResendDocument(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid document ID"))
+		return
+	}
+
+	document, err := h.sender.Resend(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			apierror.Respond(c, apierror.ErrEDIDocumentNotFound.Wrap(err))
+			return
+		}
+		apierror.Respond(c, ediError("Failed to resend EDI document", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, document)
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"rtims-backend/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listETag derives a weak ETag from the list version and the query string,
+// so each page and filter combination gets its own tag.
+func listETag(version database.ListVersion, rawQuery string) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%d|%d|%s", version.Count, version.LastModified.UnixNano(), rawQuery)))
+	return `W/"` + hex.EncodeToString(sum[:10]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, using
+// weak comparison.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified sets ETag and Last-Modified for a list response and, when the
+// client's copy is still current, answers 304 and returns true. If the
+// version cannot be read the list is served as usual.
+//
+// If-Modified-Since is only consulted without If-None-Match, as a date alone
+// cannot tell that a row was deleted.
+func notModified(c *gin.Context, version database.ListVersion, err error) bool {
+	if err != nil {
+		log.Printf("Failed to get list version for %s: %v", c.Request.URL.Path, err)
+		return false
+	}
+
+	etag := listETag(version, c.Request.URL.RawQuery)
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "private, no-cache")
+	if !version.LastModified.IsZero() {
+		c.Header("Last-Modified", version.LastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if match := c.GetHeader("If-None-Match"); match != "" {
+		if etagMatches(match, etag) {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+		return false
+	}
+
+	if since, err := http.ParseTime(c.GetHeader("If-Modified-Since")); err == nil && !version.LastModified.IsZero() {
+		if !version.LastModified.Truncate(time.Second).After(since) {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
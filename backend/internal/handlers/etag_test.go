@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"rtims-backend/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNotModified(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	modified := time.Date(2024, 3, 1, 12, 0, 0, 500, time.UTC)
+	version := database.ListVersion{Count: 3, LastModified: modified}
+	etag := listETag(version, "page=1")
+
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		version  database.ListVersion
+		err      error
+		expected bool
+	}{
+		{"no validators", nil, version, nil, false},
+		{"matching etag", map[string]string{"If-None-Match": etag}, version, nil, true},
+		{"strong form of the etag", map[string]string{"If-None-Match": `"other", ` + etag[2:]}, version, nil, true},
+		{"row deleted", map[string]string{"If-None-Match": etag}, database.ListVersion{Count: 2, LastModified: modified}, nil, false},
+		{"unchanged since", map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)}, version, nil, true},
+		{"changed since", map[string]string{"If-Modified-Since": modified.Add(-time.Minute).Format(http.TimeFormat)}, version, nil, false},
+		{"etag wins over date", map[string]string{"If-None-Match": `W/"stale"`, "If-Modified-Since": modified.Format(http.TimeFormat)}, version, nil, false},
+		{"version unavailable", map[string]string{"If-None-Match": "*"}, version, errors.New("db down"), false},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/products/?page=1", nil)
+		for key, value := range tt.headers {
+			c.Request.Header.Set(key, value)
+		}
+
+		if got := notModified(c, tt.version, tt.err); got != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, got)
+		}
+		if tt.err == nil && w.Header().Get("ETag") == "" {
+			t.Errorf("%s: expected an ETag header", tt.name)
+		}
+	}
+}
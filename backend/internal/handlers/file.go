@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"rtims-backend/internal/apierror"
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/files"
+	"rtims-backend/internal/middleware"
+	"rtims-backend/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// FileHandler serves product images and stock movement attachments, and
+// the signed URLs of the local object store.
+type FileHandler struct {
+	library        *files.Library
+	store          storage.Store
+	productService *database.ProductService
+}
+
+func NewFileHandler(db *sql.DB, cache *database.Cache, library *files.Library, store storage.Store) *FileHandler {
+	return &FileHandler{
+		library:        library,
+		store:          store,
+		productService: database.NewCachedProductService(db, cache),
+	}
+}
+
+// readUpload reads the multipart file in field, refusing files larger than
+// maxSize with tooLarge.
+func readUpload(c *gin.Context, field string, maxSize int64, missing, tooLarge string) (string, []byte, bool) {
+	fileHeader, err := c.FormFile(field)
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest(missing))
+		return "", nil, false
+	}
+	if fileHeader.Size > maxSize {
+		apierror.Respond(c, apierror.BadRequest(tooLarge))
+		return "", nil, false
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Failed to read file"))
+		return "", nil, false
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxSize))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Failed to read file"))
+		return "", nil, false
+	}
+	return fileHeader.Filename, data, true
+}
+
+// product checks the product in the URL exists in the current user's
+// organization and returns its ID.
+func (h *FileHandler) product(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid product ID"))
+		return uuid.Nil, false
+	}
+	if _, err := h.productService.GetProduct(c.Request.Context(), id); err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrProductNotFound, err))
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// movement checks the stock movement in the URL exists in the current
+// user's organization and returns its ID.
+func (h *FileHandler) movement(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid movement ID"))
+		return uuid.Nil, false
+	}
+	if _, err := h.productService.GetStockMovement(c.Request.Context(), id); err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrMovementNotFound, err))
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// GetProductImage returns a product's image with a signed URL to show it.
+func (h *FileHandler) GetProductImage(c *gin.Context) {
+	productID, ok := h.product(c)
+	if !ok {
+		return
+	}
+
+	image, err := h.library.ProductImage(c.Request.Context(), productID)
+	if err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrProductImageNotFound, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, image)
+}
+
+// UploadProductImage replaces a product's image. The file is sent as
+// multipart form field "image".
+func (h *FileHandler) UploadProductImage(c *gin.Context) {
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+	productID, ok := h.product(c)
+	if !ok {
+		return
+	}
+	filename, data, ok := readUpload(c, "image", files.MaxImageSize, "Image file is required", "Image must be 5MB or smaller")
+	if !ok {
+		return
+	}
+
+	image, err := h.library.SetProductImage(c.Request.Context(), productID, filename, data, userID)
+	if errors.Is(err, files.ErrImageType) {
+		apierror.Respond(c, apierror.BadRequest("Image must be a PNG, JPEG, GIF or WebP file"))
+		return
+	}
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to save product image", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, image)
+}
+
+func (h *FileHandler) DeleteProductImage(c *gin.Context) {
+	productID, ok := h.product(c)
+	if !ok {
+		return
+	}
+
+	if err := h.library.DeleteProductImage(c.Request.Context(), productID); err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrProductImageNotFound, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Product image deleted successfully"})
+}
+
+// GetAttachments returns the files attached to a stock movement with
+// signed URLs to download them.
+func (h *FileHandler) GetAttachments(c *gin.Context) {
+	movementID, ok := h.movement(c)
+	if !ok {
+		return
+	}
+
+	attachments, err := h.library.Attachments(c.Request.Context(), movementID)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get attachments", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, attachments)
+}
+
+// UploadAttachment attaches a file, such as a delivery note, to a stock
+// movement. The file is sent as multipart form field "file".
+func (h *FileHandler) UploadAttachment(c *gin.Context) {
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+	movementID, ok := h.movement(c)
+	if !ok {
+		return
+	}
+	filename, data, ok := readUpload(c, "file", files.MaxAttachmentSize, "Attachment file is required",
+		"Attachment must be 20MB or smaller")
+	if !ok {
+		return
+	}
+
+	attachment, err := h.library.AddAttachment(c.Request.Context(), movementID, filename, data, userID)
+	if errors.Is(err, files.ErrAttachmentType) {
+		apierror.Respond(c, apierror.BadRequest("Attachment must be a PDF, CSV, text, XLSX, DOCX or image file"))
+		return
+	}
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to save attachment", err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, attachment)
+}
+
+func (h *FileHandler) DeleteAttachment(c *gin.Context) {
+	movementID, ok := h.movement(c)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(c.Param("attachment_id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid attachment ID"))
+		return
+	}
+
+	if err := h.library.DeleteAttachment(c.Request.Context(), movementID, id); err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrAttachmentNotFound, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Attachment deleted successfully"})
+}
+
+// ServeFile serves a file of the local object store at a URL it signed.
+// It is not authenticated by token but by the signature. Other stores sign
+// URLs of their own, so it serves nothing for them.
+func (h *FileHandler) ServeFile(c *gin.Context) {
+	local, ok := h.store.(*storage.Local)
+	if !ok {
+		apierror.Respond(c, apierror.ErrNotFound)
+		return
+	}
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if err := local.Verify(key, c.Request.URL.Query(), time.Now()); err != nil {
+		apierror.Respond(c, apierror.ErrFileLinkInvalid)
+		return
+	}
+
+	file, err := local.Open(c.Request.Context(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			apierror.Respond(c, apierror.ErrNotFound)
+			return
+		}
+		apierror.Respond(c, apierror.Failed("Failed to read file", err))
+		return
+	}
+	defer file.Close()
+
+	headers := map[string]string{
+		// Uploaded files are never run as pages of the API's origin
+		"Content-Security-Policy": "sandbox",
+		"X-Content-Type-Options":  "nosniff",
+		"Cache-Control":           "private, max-age=300",
+	}
+	if filename := c.Query("filename"); filename != "" {
+		headers["Content-Disposition"] = mime.FormatMediaType("attachment", map[string]string{"filename": filename})
+	}
+	c.DataFromReader(http.StatusOK, -1, files.ContentType(key), file, headers)
+}
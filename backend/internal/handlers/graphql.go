@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"database/sql"
+
+	"rtims-backend/internal/apierror"
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/graphql"
+	"rtims-backend/internal/middleware"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/gin-gonic/gin"
+)
+
+// GraphQLHandler answers GraphQL queries for products, stock movements,
+// categories and the caller's notifications, so clients fetch only the
+// fields and relations they show. It reads through the same services as
+// the REST routes, scoped to the caller's organization by JWTAuth.
+type GraphQLHandler struct {
+	resolver *graphql.Resolver
+	server   *handler.Server
+}
+
+func NewGraphQLHandler(db *sql.DB, cache *database.Cache) *GraphQLHandler {
+	resolver := graphql.NewResolver(
+		database.NewCachedProductService(db, cache),
+		database.NewCategoryService(db),
+		database.NewNotificationService(db),
+	)
+
+	server := handler.New(graphql.NewExecutableSchema(graphql.Config{Resolvers: resolver}))
+	server.AddTransport(transport.GET{})
+	server.AddTransport(transport.POST{})
+	server.Use(extension.Introspection{})
+	server.Use(graphql.DepthLimit{})
+
+	return &GraphQLHandler{resolver: resolver, server: server}
+}
+
+// Query executes a query posted as JSON, or given in the query string of a
+// GET. Field errors are answered with 200 alongside the data; queries that
+// do not parse or validate with 422.
+func (h *GraphQLHandler) Query(c *gin.Context) {
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	ctx := h.resolver.WithRequest(c.Request.Context(), userID)
+	h.server.ServeHTTP(c.Writer, c.Request.WithContext(ctx))
+}
@@ -1,23 +1,110 @@
 package handlers
 
 import (
+	"context"
 	"net/http"
+	"time"
+
+	"rtims-backend/internal/buildinfo"
 
 	"github.com/gin-gonic/gin"
 )
 
+// readinessTimeout bounds each dependency check.
+const readinessTimeout = 2 * time.Second
+
 type HealthResponse struct {
 	Status  string `json:"status"`
 	Message string `json:"message"`
 	Version string `json:"version"`
 }
 
+// DependencyStatus is the outcome of one readiness check.
+type DependencyStatus struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type ReadinessResponse struct {
+	Status string `json:"status"`
+	// Degraded is set while an optional dependency is down: the instance
+	// still serves requests, with reduced functionality.
+	Degraded bool                        `json:"degraded"`
+	Checks   map[string]DependencyStatus `json:"checks"`
+}
+
+// ReadinessCheck returns an error when a dependency cannot serve requests.
+type ReadinessCheck func(ctx context.Context) error
+
+// HealthHandler serves the readiness probe.
+type HealthHandler struct {
+	checks   map[string]ReadinessCheck
+	optional map[string]ReadinessCheck
+}
+
+// NewHealthHandler takes the checks the instance cannot serve without, and
+// optional ones whose failure only marks it degraded.
+func NewHealthHandler(checks, optional map[string]ReadinessCheck) *HealthHandler {
+	return &HealthHandler{checks: checks, optional: optional}
+}
+
+// HealthCheck is the liveness probe: it only shows the process is serving
+// requests, so a failing dependency never gets the pod restarted.
 func HealthCheck(c *gin.Context) {
 	response := HealthResponse{
 		Status:  "healthy",
 		Message: "RTIMS API is running",
-		Version: "1.0.0",
+		Version: buildinfo.Version,
 	}
 
 	c.JSON(http.StatusOK, response)
-}
\ No newline at end of file
+}
+
+// Readiness runs every dependency check and returns 503 if a required one
+// fails, so the load balancer stops routing to this instance. A failing
+// optional check only sets degraded.
+func (h *HealthHandler) Readiness(c *gin.Context) {
+	response := ReadinessResponse{
+		Status: "ready",
+		Checks: make(map[string]DependencyStatus, len(h.checks)+len(h.optional)),
+	}
+
+	for name, check := range h.checks {
+		status := runCheck(c.Request.Context(), check)
+		if status.Status != "up" {
+			response.Status = "not_ready"
+		}
+		response.Checks[name] = status
+	}
+	for name, check := range h.optional {
+		status := runCheck(c.Request.Context(), check)
+		if status.Status != "up" {
+			response.Degraded = true
+		}
+		response.Checks[name] = status
+	}
+
+	code := http.StatusOK
+	if response.Status != "ready" {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, response)
+}
+
+func runCheck(ctx context.Context, check ReadinessCheck) DependencyStatus {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+	start := time.Now()
+	err := check(ctx)
+
+	status := DependencyStatus{
+		Status:    "up",
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		status.Status = "down"
+		status.Error = err.Error()
+	}
+	return status
+}
@@ -1,6 +1,9 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -47,3 +50,57 @@ func TestHealthCheck(t *testing.T) {
 		t.Errorf("Expected Content-Type %s, got %s", "application/json; charset=utf-8", contentType)
 	}
 }
+
+func TestReadiness(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name             string
+		databaseErr      error
+		redisErr         error
+		expectedCode     int
+		expected         string
+		expectedDegraded bool
+	}{
+		{"all dependencies up", nil, nil, http.StatusOK, "ready", false},
+		{"redis down", nil, errors.New("connection refused"), http.StatusOK, "ready", true},
+		{"database down", errors.New("connection refused"), nil, http.StatusServiceUnavailable, "not_ready", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHealthHandler(map[string]ReadinessCheck{
+				"database": func(ctx context.Context) error { return tt.databaseErr },
+			}, map[string]ReadinessCheck{
+				"redis": func(ctx context.Context) error { return tt.redisErr },
+			})
+			router := gin.New()
+			router.GET("/readyz", handler.Readiness)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/readyz", nil)
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedCode {
+				t.Errorf("Expected status code %d, got %d", tt.expectedCode, w.Code)
+			}
+
+			var response ReadinessResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Status != tt.expected {
+				t.Errorf("Expected status %s, got %s", tt.expected, response.Status)
+			}
+			if response.Degraded != tt.expectedDegraded {
+				t.Errorf("Expected degraded %v, got %v", tt.expectedDegraded, response.Degraded)
+			}
+			if tt.databaseErr == nil && response.Checks["database"].Status != "up" {
+				t.Errorf("Expected database to be up, got %+v", response.Checks["database"])
+			}
+			if tt.redisErr != nil && response.Checks["redis"].Error != tt.redisErr.Error() {
+				t.Errorf("Expected redis error %q, got %+v", tt.redisErr, response.Checks["redis"])
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"rtims-backend/internal/apierror"
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/ingest"
+	"rtims-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// IngestHandler reports on the suppliers configured in settings and the
+// files they sent, and picks up new files on demand.
+type IngestHandler struct {
+	ingestService *database.IngestService
+	importer      *ingest.Importer
+}
+
+func NewIngestHandler(db *sql.DB, importer *ingest.Importer) *IngestHandler {
+	return &IngestHandler{ingestService: database.NewIngestService(db), importer: importer}
+}
+
+// ingestError maps the errors of supplier ingestion to responses, and
+// others as apierror.Failed.
+func ingestError(message string, err error) *apierror.Error {
+	switch {
+	case errors.Is(err, ingest.ErrUnknownSupplier):
+		return apierror.ErrIngestSupplierMissing.Wrap(err)
+	case errors.Is(err, ingest.ErrSupplierAccount):
+		return apierror.ErrIngestAccount.Wrap(err)
+	}
+	return apierror.Failed(message, err)
+}
+
+// GetSuppliers returns the configured suppliers with their last file.
+func (h *IngestHandler) GetSuppliers(c *gin.Context) {
+	suppliers, err := h.importer.Status(c.Request.Context())
+	if err != nil {
+		apierror.Respond(c, ingestError("Failed to get suppliers", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, suppliers)
+}
+
+// Run picks up a supplier's new files now rather than at the next
+// scheduled run, returning the files processed.
+func (h *IngestHandler) Run(c *gin.Context) {
+	files, err := h.importer.RunSupplier(c.Request.Context(), c.Param("supplier"))
+	if err != nil {
+		apierror.Respond(c, ingestError("Failed to ingest supplier files", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, files)
+}
+
+func (h *IngestHandler) GetFiles(c *gin.Context) {
+	var filter models.IngestFileFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+
+	files, err := h.ingestService.GetIngestFiles(c.Request.Context(), filter)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get supplier files", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, files)
+}
+
+// GetFile returns a file with the outcome of each of its rows.
+func (h *IngestHandler) GetFile(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid file ID"))
+		return
+	}
+
+	file, err := h.ingestService.GetIngestFile(c.Request.Context(), id)
+	if err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrIngestFileNotFound, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, file)
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"rtims-backend/internal/apierror"
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/middleware"
+	"rtims-backend/internal/models"
+	"rtims-backend/internal/movementimport"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxMovementImportSize is the largest stock movement import file accepted.
+const maxMovementImportSize = 5 << 20
+
+// MovementImportHandler loads historical stock movements in bulk from CSV
+// files, such as those exported from the system RTIMS replaces.
+type MovementImportHandler struct {
+	productService    *database.ProductService
+	accountingService *database.AccountingService
+	importService     *database.MovementImportService
+	auditService      *database.AuditService
+	queue             *movementimport.Queue
+}
+
+func NewMovementImportHandler(db *sql.DB, queue *movementimport.Queue) *MovementImportHandler {
+	return &MovementImportHandler{
+		productService:    database.NewProductService(db),
+		accountingService: database.NewAccountingService(db),
+		importService:     database.NewMovementImportService(db),
+		auditService:      database.NewAuditService(db),
+		queue:             queue,
+	}
+}
+
+// ImportMovements reads stock movements from the CSV file in multipart form
+// field "file". With dry_run=true it only validates the rows, answering per
+// row as JSON or, with format=csv, as a file to download; otherwise it
+// queues the valid ones for import and answers with the import, whose
+// progress GetMovementImport reports.
+func (h *MovementImportHandler) ImportMovements(c *gin.Context) {
+	dryRun := c.Query("dry_run") == "true"
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Import file is required"))
+		return
+	}
+	if fileHeader.Size > maxMovementImportSize {
+		apierror.Respond(c, apierror.BadRequest("Import file must be 5MB or smaller"))
+		return
+	}
+
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Failed to read import file"))
+		return
+	}
+	defer file.Close()
+
+	// Parse reads the whole file, so hash ends up with its checksum, which
+	// keeps the same file from being imported twice
+	hash := sha256.New()
+	rows, err := movementimport.Parse(io.TeeReader(file, hash))
+	if err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+
+	products, err := h.productService.GetProductsBySKU(c.Request.Context(), movementimport.SKUs(rows))
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get products", err))
+		return
+	}
+	productIDs := make(map[string]uuid.UUID, len(products))
+	for sku, product := range products {
+		productIDs[sku] = product.ID
+	}
+
+	lockedUntil, err := h.accountingService.LockedUntil(c.Request.Context())
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get accounting exports", err))
+		return
+	}
+
+	results := movementimport.Validate(rows, productIDs, lockedUntil, time.Now())
+	if dryRun {
+		if c.Query("format") == "csv" {
+			c.Header("Content-Type", "text/csv")
+			c.Header("Content-Disposition", "attachment; filename=movement-import-results.csv")
+			c.Status(http.StatusOK)
+			if err := movementimport.WriteCSV(c.Writer, results); err != nil {
+				log.Printf("Failed to write movement import results: %v", err)
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"dry_run": true,
+			"summary": movementimport.Summarize(results),
+			"results": results,
+		})
+		return
+	}
+
+	movementImport, err := h.queue.Enqueue(c.Request.Context(), fileHeader.Filename, hex.EncodeToString(hash.Sum(nil)), results, userID)
+	if err == movementimport.ErrQueueFull {
+		apierror.Respond(c, apierror.New(http.StatusServiceUnavailable, apierror.CodeImportQueueFull, err.Error()))
+		return
+	}
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to queue movement import", err))
+		return
+	}
+
+	summary := movementimport.Summarize(results)
+	auditLog := &models.AuditLog{
+		ID:        uuid.New(),
+		TableName: "stock_movement_imports",
+		RecordID:  movementImport.ID,
+		Action:    models.ActionCreate,
+		NewValues: map[string]interface{}{"filename": movementImport.Filename, "rows": summary.Total, "valid": summary.Valid, "invalid": summary.Invalid},
+		ChangedBy: userID,
+		ChangedAt: time.Now(),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+	}
+
+	middleware.MarkAudited(c)
+	if err := h.auditService.Record(c.Request.Context(), auditLog); err != nil {
+		log.Printf("Failed to create audit log: %v", err)
+	}
+
+	c.JSON(http.StatusAccepted, movementImport)
+}
+
+// GetMovementImports lists the latest imports, newest first.
+func (h *MovementImportHandler) GetMovementImports(c *gin.Context) {
+	imports, err := h.importService.GetMovementImports(c.Request.Context(), 20)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get movement imports", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"imports": imports})
+}
+
+// GetMovementImport reports the progress of an import and, once it
+// completes, the rows it could not import.
+func (h *MovementImportHandler) GetMovementImport(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid import ID"))
+		return
+	}
+
+	movementImport, err := h.importService.GetMovementImport(c.Request.Context(), id)
+	if err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrImportNotFound, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, movementImport)
+}
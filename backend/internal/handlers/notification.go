@@ -2,14 +2,17 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 	"log"
 	"net/http"
 	"time"
 
+	"rtims-backend/internal/apierror"
+	"rtims-backend/internal/audit"
 	"rtims-backend/internal/database"
 	"rtims-backend/internal/models"
 	"rtims-backend/internal/middleware"
-	"rtims-backend/internal/websocket"
+	"rtims-backend/internal/notify"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -17,31 +20,35 @@ import (
 
 type NotificationHandler struct {
 	notificationService *database.NotificationService
+	templateService     *database.NotificationTemplateService
 	auditService        *database.AuditService
+	userService         *database.UserService
 	db                  *sql.DB
-	hub                 *websocket.Hub
+	dispatcher          *notify.Dispatcher
 }
 
-func NewNotificationHandler(db *sql.DB, hub *websocket.Hub) *NotificationHandler {
+func NewNotificationHandler(db *sql.DB, dispatcher *notify.Dispatcher) *NotificationHandler {
 	return &NotificationHandler{
 		notificationService: database.NewNotificationService(db),
+		templateService:     database.NewNotificationTemplateService(db),
 		auditService:        database.NewAuditService(db),
+		userService:         database.NewUserService(db),
 		db:                  db,
-		hub:                 hub,
+		dispatcher:          dispatcher,
 	}
 }
 
 func (h *NotificationHandler) GetNotifications(c *gin.Context) {
 	userID, _, err := middleware.GetCurrentUser(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apierror.Respond(c, apierror.ErrUnauthenticated)
 		return
 	}
 
 	// Parse query parameters
 	var filter models.NotificationFilter
 	if err := c.ShouldBindQuery(&filter); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, apierror.Invalid(err))
 		return
 	}
 
@@ -58,10 +65,15 @@ func (h *NotificationHandler) GetNotifications(c *gin.Context) {
 
 	filter.UserID = &userID
 
+	version, err := h.notificationService.GetNotificationsVersion(c.Request.Context(), userID)
+	if notModified(c, version, err) {
+		return
+	}
+
 	// Get notifications from database
 	notifications, total, err := h.notificationService.GetNotifications(filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get notifications: " + err.Error()})
+		apierror.Respond(c, apierror.Failed("Failed to get notifications", err))
 		return
 	}
 
@@ -79,20 +91,20 @@ func (h *NotificationHandler) GetNotifications(c *gin.Context) {
 func (h *NotificationHandler) MarkNotificationRead(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID"})
+		apierror.Respond(c, apierror.BadRequest("Invalid notification ID"))
 		return
 	}
 
 	userID, _, err := middleware.GetCurrentUser(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apierror.Respond(c, apierror.ErrUnauthenticated)
 		return
 	}
 
 	// Mark notification as read in database
 	err = h.notificationService.MarkAsRead(id, userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark notification as read: " + err.Error()})
+		apierror.Respond(c, apierror.Failed("Failed to mark notification as read", err))
 		return
 	}
 
@@ -110,7 +122,8 @@ func (h *NotificationHandler) MarkNotificationRead(c *gin.Context) {
 		UserAgent:  c.GetHeader("User-Agent"),
 	}
 
-	err = h.auditService.CreateAuditLog(auditLog)
+	middleware.MarkAudited(c)
+	err = h.auditService.Record(c.Request.Context(), auditLog)
 	if err != nil {
 		// Log error but don't fail the request
 		log.Printf("Failed to create audit log: %v", err)
@@ -123,34 +136,311 @@ func (h *NotificationHandler) MarkNotificationRead(c *gin.Context) {
 	})
 }
 
+func (h *NotificationHandler) DeleteNotification(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid notification ID"))
+		return
+	}
+
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	err = h.notificationService.DeleteNotification(id, userID)
+	if err == sql.ErrNoRows {
+		apierror.Respond(c, apierror.ErrNotificationNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to delete notification", err))
+		return
+	}
+
+	// Create audit log
+	auditLog := &models.AuditLog{
+		ID:         uuid.New(),
+		TableName:  "notifications",
+		RecordID:   id,
+		Action:     models.ActionDelete,
+		OldValues:  nil,
+		NewValues:  nil,
+		ChangedBy:  userID,
+		ChangedAt:  time.Now(),
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.GetHeader("User-Agent"),
+	}
+
+	middleware.MarkAudited(c)
+	err = h.auditService.Record(c.Request.Context(), auditLog)
+	if err != nil {
+		log.Printf("Failed to create audit log: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification deleted successfully"})
+}
+
+func (h *NotificationHandler) ArchiveNotification(c *gin.Context) {
+	h.setArchived(c, true)
+}
+
+func (h *NotificationHandler) UnarchiveNotification(c *gin.Context) {
+	h.setArchived(c, false)
+}
+
+func (h *NotificationHandler) AcknowledgeNotification(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid notification ID"))
+		return
+	}
+
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	err = h.notificationService.Acknowledge(id, userID)
+	if err == sql.ErrNoRows {
+		apierror.Respond(c, apierror.New(http.StatusNotFound, apierror.CodeNotificationNotFound, "No unacknowledged critical alert found"))
+		return
+	}
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to acknowledge notification", err))
+		return
+	}
+
+	// Create audit log
+	auditLog := &models.AuditLog{
+		ID:         uuid.New(),
+		TableName:  "notifications",
+		RecordID:   id,
+		Action:     models.ActionUpdate,
+		OldValues:  gin.H{"acknowledged": false},
+		NewValues:  gin.H{"acknowledged": true, "is_read": true},
+		ChangedBy:  userID,
+		ChangedAt:  time.Now(),
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.GetHeader("User-Agent"),
+	}
+
+	middleware.MarkAudited(c)
+	err = h.auditService.Record(c.Request.Context(), auditLog)
+	if err != nil {
+		log.Printf("Failed to create audit log: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Alert acknowledged",
+		"id":      id,
+	})
+}
+
+func (h *NotificationHandler) setArchived(c *gin.Context, archived bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid notification ID"))
+		return
+	}
+
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	err = h.notificationService.SetArchived(id, userID, archived)
+	if err == sql.ErrNoRows {
+		apierror.Respond(c, apierror.ErrNotificationNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to update notification", err))
+		return
+	}
+
+	// Create audit log
+	auditLog := &models.AuditLog{
+		ID:         uuid.New(),
+		TableName:  "notifications",
+		RecordID:   id,
+		Action:     models.ActionUpdate,
+		OldValues:  gin.H{"is_archived": !archived},
+		NewValues:  gin.H{"is_archived": archived},
+		ChangedBy:  userID,
+		ChangedAt:  time.Now(),
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.GetHeader("User-Agent"),
+	}
+
+	middleware.MarkAudited(c)
+	err = h.auditService.Record(c.Request.Context(), auditLog)
+	if err != nil {
+		log.Printf("Failed to create audit log: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":          id,
+		"is_archived": archived,
+	})
+}
+
+func (h *NotificationHandler) MarkAllNotificationsRead(c *gin.Context) {
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	updated, err := h.notificationService.MarkAllAsRead(userID)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to mark notifications as read", err))
+		return
+	}
+
+	// Create audit log
+	auditLog := &models.AuditLog{
+		ID:         uuid.New(),
+		TableName:  "notifications",
+		RecordID:   uuid.Nil,
+		Action:     models.ActionUpdate,
+		OldValues:  gin.H{"is_read": false},
+		NewValues:  gin.H{"is_read": true, "count": updated},
+		ChangedBy:  userID,
+		ChangedAt:  time.Now(),
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.GetHeader("User-Agent"),
+	}
+
+	middleware.MarkAudited(c)
+	err = h.auditService.Record(c.Request.Context(), auditLog)
+	if err != nil {
+		log.Printf("Failed to create audit log: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "All notifications marked as read",
+		"updated": updated,
+	})
+}
+
+func (h *NotificationHandler) BulkUpdateNotifications(c *gin.Context) {
+	var req models.BulkNotificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+
+	// Validate input
+	switch req.Action {
+	case models.BulkActionMarkRead, models.BulkActionArchive, models.BulkActionDelete:
+	default:
+		apierror.Respond(c, apierror.BadRequest("Action must be one of: mark_read, archive, delete"))
+		return
+	}
+	if !req.Selects() {
+		apierror.Respond(c, apierror.BadRequest("Either ids, a filter with at least one field or all: true is required"))
+		return
+	}
+	if len(req.IDs) > 1000 {
+		apierror.Respond(c, apierror.BadRequest("At most 1000 ids can be processed per request"))
+		return
+	}
+
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	affected, err := h.notificationService.BulkUpdate(userID, req)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to update notifications", err))
+		return
+	}
+
+	action := models.ActionUpdate
+	if req.Action == models.BulkActionDelete {
+		action = models.ActionDelete
+	}
+
+	// Create audit log
+	auditLog := &models.AuditLog{
+		ID:         uuid.New(),
+		TableName:  "notifications",
+		RecordID:   uuid.Nil,
+		Action:     action,
+		OldValues:  nil,
+		NewValues:  gin.H{"bulk_action": req.Action, "ids": req.IDs, "filter": req.Filter, "count": affected},
+		ChangedBy:  userID,
+		ChangedAt:  time.Now(),
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.GetHeader("User-Agent"),
+	}
+
+	middleware.MarkAudited(c)
+	err = h.auditService.Record(c.Request.Context(), auditLog)
+	if err != nil {
+		log.Printf("Failed to create audit log: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Bulk operation completed",
+		"action":   req.Action,
+		"affected": affected,
+	})
+}
+
 func (h *NotificationHandler) CreateNotification(c *gin.Context) {
 	var req models.CreateNotificationRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+
+	if req.Priority != "" && req.Priority != models.PriorityNormal && req.Priority != models.PriorityCritical {
+		apierror.Respond(c, apierror.BadRequest("priority must be normal or critical"))
+		return
+	}
+
+	if req.ScheduledAt != nil && !req.ScheduledAt.After(time.Now()) {
+		apierror.Respond(c, apierror.BadRequest("scheduled_at must be in the future"))
 		return
 	}
 
 	// Get current user for audit logging
 	userID, _, err := middleware.GetCurrentUser(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	// Admins only notify users of their own organization
+	if _, err := h.userService.GetUser(c.Request.Context(), req.UserID); err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrUserNotFound, err))
 		return
 	}
 
 	// Create notification object
 	notification := &models.Notification{
-		ID:        uuid.New(),
-		UserID:    req.UserID,
-		Message:   req.Message,
-		Type:      req.Type,
-		IsRead:    false,
-		CreatedAt: time.Now(),
+		ID:          uuid.New(),
+		UserID:      req.UserID,
+		Message:     req.Message,
+		Type:        req.Type,
+		IsRead:      false,
+		CreatedAt:   time.Now(),
+		ScheduledAt: req.ScheduledAt,
+		Priority:    req.Priority,
+		Metadata:    req.Metadata,
 	}
 
 	// Save notification to database
 	err = h.notificationService.CreateNotification(notification)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create notification: " + err.Error()})
+		apierror.Respond(c, apierror.Failed("Failed to create notification", err))
 		return
 	}
 
@@ -161,30 +451,93 @@ func (h *NotificationHandler) CreateNotification(c *gin.Context) {
 		RecordID:   notification.ID,
 		Action:     models.ActionCreate,
 		OldValues:  nil,
-		NewValues:  gin.H{"user_id": req.UserID, "message": req.Message, "type": req.Type},
+		NewValues:  gin.H{"user_id": req.UserID, "message": req.Message, "type": req.Type, "scheduled_at": req.ScheduledAt, "priority": req.Priority},
 		ChangedBy:  userID,
 		ChangedAt:  time.Now(),
 		IPAddress:  c.ClientIP(),
 		UserAgent:  c.GetHeader("User-Agent"),
 	}
 
-	err = h.auditService.CreateAuditLog(auditLog)
+	middleware.MarkAudited(c)
+	err = h.auditService.Record(c.Request.Context(), auditLog)
 	if err != nil {
 		// Log error but don't fail the request
 		log.Printf("Failed to create audit log: %v", err)
 	}
 
-	// Send WebSocket notification
-	websocket.BroadcastNotification(h.hub, req.UserID, req.Message, string(req.Type))
+	// Deliver over WebSocket, email, and chat webhooks; scheduled
+	// notifications are delivered later by the scheduler
+	if !notification.IsScheduled() {
+		h.dispatcher.Deliver(notification)
+	}
 
 	c.JSON(http.StatusCreated, notification)
 }
 
+func (h *NotificationHandler) GetPreferences(c *gin.Context) {
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	preferences, err := h.notificationService.GetPreferences(userID)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get notification preferences", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"preferences":   preferences,
+		"email_enabled": h.dispatcher.EmailEnabled(),
+	})
+}
+
+func (h *NotificationHandler) UpdatePreferences(c *gin.Context) {
+	var req models.UpdateNotificationPreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+
+	for _, p := range req.Preferences {
+		switch p.Type {
+		case models.NotificationLowStock, models.NotificationSystem, models.NotificationUser:
+		default:
+			apierror.Respond(c, apierror.BadRequest("Invalid notification type: "+string(p.Type)))
+			return
+		}
+	}
+
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	err = h.notificationService.UpdatePreferences(userID, req.Preferences)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to update notification preferences", err))
+		return
+	}
+
+	preferences, err := h.notificationService.GetPreferences(userID)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get notification preferences", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"preferences":   preferences,
+		"email_enabled": h.dispatcher.EmailEnabled(),
+	})
+}
+
 func (h *NotificationHandler) GetAuditLogs(c *gin.Context) {
 	// Parse query parameters
 	var filter models.AuditLogFilter
 	if err := c.ShouldBindQuery(&filter); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, apierror.Invalid(err))
 		return
 	}
 
@@ -200,9 +553,9 @@ func (h *NotificationHandler) GetAuditLogs(c *gin.Context) {
 	}
 
 	// Get audit logs from database
-	auditLogs, total, err := h.auditService.GetAuditLogs(filter)
+	auditLogs, total, err := h.auditService.GetAuditLogs(c.Request.Context(), filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get audit logs: " + err.Error()})
+		apierror.Respond(c, apierror.Failed("Failed to get audit logs", err))
 		return
 	}
 
@@ -220,15 +573,177 @@ func (h *NotificationHandler) GetAuditLogs(c *gin.Context) {
 func (h *NotificationHandler) GetAuditLog(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid audit log ID"})
+		apierror.Respond(c, apierror.BadRequest("Invalid audit log ID"))
 		return
 	}
 
-	auditLog, err := h.auditService.GetAuditLog(id)
+	auditLog, err := h.auditService.GetAuditLog(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Audit log not found"})
+		apierror.Respond(c, apierror.Missing(apierror.ErrAuditLogNotFound, err))
 		return
 	}
 
 	c.JSON(http.StatusOK, auditLog)
-}
\ No newline at end of file
+}
+
+// GetRecordTimeline returns the audit trail of one record, oldest first.
+// Pass include_views=true to include view entries.
+func (h *NotificationHandler) GetRecordTimeline(c *gin.Context) {
+	recordID, err := uuid.Parse(c.Param("record_id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid record ID"))
+		return
+	}
+	tableName := c.Param("table")
+	includeViews := c.Query("include_views") == "true"
+
+	timeline, err := h.auditService.GetRecordTimeline(c.Request.Context(), tableName, recordID, includeViews)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get audit timeline", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"table_name": tableName,
+		"record_id":  recordID,
+		"timeline":   timeline,
+	})
+}
+
+// RestoreAuditLog puts a product, category or user back the way it was
+// before the update or delete recorded in the audit entry.
+func (h *NotificationHandler) RestoreAuditLog(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid audit log ID"))
+		return
+	}
+
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	entry, err := h.auditService.GetAuditLog(c.Request.Context(), id)
+	if err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrAuditLogNotFound, err))
+		return
+	}
+
+	restored, err := audit.NewRestorer(h.db).Restore(c.Request.Context(), entry, userID)
+	switch {
+	case errors.Is(err, audit.ErrNotRestorable), errors.Is(err, audit.ErrNoOldValues), errors.Is(err, audit.ErrRedacted):
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	case errors.Is(err, audit.ErrRecordExists), errors.Is(err, audit.ErrRecordMissing):
+		apierror.Respond(c, apierror.New(http.StatusConflict, apierror.CodeConflict, err.Error()))
+		return
+	case err != nil:
+		apierror.Respond(c, apierror.Failed("Failed to restore record", err))
+		return
+	}
+
+	// A restored delete recreates the record
+	action := models.ActionUpdate
+	if entry.Action == models.ActionDelete {
+		action = models.ActionCreate
+	}
+
+	auditLog := &models.AuditLog{
+		ID:         uuid.New(),
+		TableName:  entry.TableName,
+		RecordID:   entry.RecordID,
+		Action:     action,
+		OldValues:  entry.NewValues,
+		NewValues:  gin.H{"restored_from": entry.ID, "values": restored},
+		ChangedBy:  userID,
+		ChangedAt:  time.Now(),
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.GetHeader("User-Agent"),
+	}
+
+	middleware.MarkAudited(c)
+	err = h.auditService.Record(c.Request.Context(), auditLog)
+	if err != nil {
+		log.Printf("Failed to create audit log: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Record restored successfully",
+		"table_name": entry.TableName,
+		"record_id":  entry.RecordID,
+		"values":     restored,
+	})
+}
+
+func (h *NotificationHandler) GetTemplates(c *gin.Context) {
+	templates, err := h.templateService.GetTemplates()
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get notification templates", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, templates)
+}
+
+func (h *NotificationHandler) UpdateTemplate(c *gin.Context) {
+	key := c.Param("key")
+
+	var req models.UpdateNotificationTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid request body"))
+		return
+	}
+
+	if req.Body == "" {
+		apierror.Respond(c, apierror.BadRequest("Template body is required"))
+		return
+	}
+
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	oldTemplate, err := h.templateService.GetTemplate(key)
+	if err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrTemplateNotFound, err))
+		return
+	}
+
+	err = h.templateService.UpdateTemplate(key, req.Body, userID)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to update notification template", err))
+		return
+	}
+
+	// Create audit log
+	auditLog := &models.AuditLog{
+		ID:         uuid.New(),
+		TableName:  "notification_templates",
+		RecordID:   uuid.New(), // Templates are keyed by name, not by ID
+		Action:     models.ActionUpdate,
+		OldValues:  gin.H{"key": key, "body": oldTemplate.Body},
+		NewValues:  gin.H{"key": key, "body": req.Body},
+		ChangedBy:  userID,
+		ChangedAt:  time.Now(),
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.GetHeader("User-Agent"),
+	}
+
+	middleware.MarkAudited(c)
+	err = h.auditService.Record(c.Request.Context(), auditLog)
+	if err != nil {
+		log.Printf("Failed to create audit log: %v", err)
+	}
+
+	template, err := h.templateService.GetTemplate(key)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get updated template", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"regexp"
+	"time"
+
+	"rtims-backend/internal/apierror"
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/middleware"
+	"rtims-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// slugPattern is what organization slugs may look like: lowercase letters,
+// digits and hyphens, starting with a letter or digit.
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,62}$`)
+
+// OrganizationHandler manages organizations. Its routes are for admins of
+// the default organization, who run the instance.
+type OrganizationHandler struct {
+	organizationService *database.OrganizationService
+	userService         *database.UserService
+	auditService        *database.AuditService
+}
+
+func NewOrganizationHandler(db *sql.DB) *OrganizationHandler {
+	return &OrganizationHandler{
+		organizationService: database.NewOrganizationService(db),
+		userService:         database.NewUserService(db),
+		auditService:        database.NewAuditService(db),
+	}
+}
+
+func (h *OrganizationHandler) GetOrganizations(c *gin.Context) {
+	organizations, err := h.organizationService.GetOrganizations(c.Request.Context())
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get organizations", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, organizations)
+}
+
+func (h *OrganizationHandler) GetOrganization(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid organization ID"))
+		return
+	}
+
+	organization, err := h.organizationService.GetOrganization(c.Request.Context(), id)
+	if err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrOrganizationNotFound, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, organization)
+}
+
+// CreateOrganization creates an organization and its first admin.
+func (h *OrganizationHandler) CreateOrganization(c *gin.Context) {
+	var req models.CreateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+	if !slugPattern.MatchString(req.Slug) {
+		apierror.Respond(c, apierror.BadRequest("Slug may only contain lowercase letters, digits and hyphens"))
+		return
+	}
+
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	// Emails are unique across organizations
+	if existing, err := h.userService.GetUserByEmail(c.Request.Context(), req.Admin.Email); err == nil && existing != nil {
+		apierror.Respond(c, apierror.ErrDuplicateEmail)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Admin.Password), bcrypt.DefaultCost)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to hash password", err))
+		return
+	}
+
+	now := time.Now()
+	organization := &models.Organization{
+		ID:        uuid.New(),
+		Name:      req.Name,
+		Slug:      req.Slug,
+		IsActive:  true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	admin := &models.User{
+		ID:        uuid.New(),
+		Name:      req.Admin.Name,
+		Email:     req.Admin.Email,
+		Password:  string(hashedPassword),
+		Role:      models.RoleAdmin,
+		IsActive:  true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if err := h.organizationService.CreateOrganization(c.Request.Context(), organization, admin); err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to create organization", err))
+		return
+	}
+
+	auditLog := &models.AuditLog{
+		ID:        uuid.New(),
+		TableName: "organizations",
+		RecordID:  organization.ID,
+		Action:    models.ActionCreate,
+		NewValues: map[string]interface{}{"name": organization.Name, "slug": organization.Slug, "admin_email": admin.Email},
+		ChangedBy: userID,
+		ChangedAt: now,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+	}
+	middleware.MarkAudited(c)
+	if err := h.auditService.Record(c.Request.Context(), auditLog); err != nil {
+		log.Printf("Failed to create audit log: %v", err)
+	}
+
+	c.JSON(http.StatusCreated, organization)
+}
+
+// UpdateOrganization renames an organization or (de)activates it. Users of
+// a deactivated organization cannot log in or refresh their tokens.
+func (h *OrganizationHandler) UpdateOrganization(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid organization ID"))
+		return
+	}
+
+	var req models.UpdateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+	if id == database.DefaultOrganizationID && req.IsActive != nil && !*req.IsActive {
+		apierror.Respond(c, apierror.BadRequest("The default organization cannot be deactivated"))
+		return
+	}
+
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	old, err := h.organizationService.GetOrganization(c.Request.Context(), id)
+	if err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrOrganizationNotFound, err))
+		return
+	}
+
+	if err := h.organizationService.UpdateOrganization(c.Request.Context(), id, req); err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrOrganizationNotFound, err))
+		return
+	}
+
+	organization, err := h.organizationService.GetOrganization(c.Request.Context(), id)
+	if err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrOrganizationNotFound, err))
+		return
+	}
+
+	auditLog := &models.AuditLog{
+		ID:        uuid.New(),
+		TableName: "organizations",
+		RecordID:  id,
+		Action:    models.ActionUpdate,
+		OldValues: map[string]interface{}{"name": old.Name, "is_active": old.IsActive},
+		NewValues: map[string]interface{}{"name": organization.Name, "is_active": organization.IsActive},
+		ChangedBy: userID,
+		ChangedAt: time.Now(),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+	}
+	middleware.MarkAudited(c)
+	if err := h.auditService.Record(c.Request.Context(), auditLog); err != nil {
+		log.Printf("Failed to create audit log: %v", err)
+	}
+
+	c.JSON(http.StatusOK, organization)
+}
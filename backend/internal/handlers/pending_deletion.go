@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"rtims-backend/internal/apierror"
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/middleware"
+	"rtims-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// scheduleDeletion defers deleting a record of table by the undo window,
+// audits it and answers 202 with the pending deletion. It returns false
+// without answering when the window is 0, for the caller to delete the
+// record at once.
+func scheduleDeletion(c *gin.Context, pending *database.PendingDeletionService, auditService *database.AuditService,
+	table string, recordID, userID uuid.UUID) bool {
+	window, err := pending.UndoWindow(c.Request.Context())
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to schedule deletion", err))
+		return true
+	}
+	if window <= 0 {
+		return false
+	}
+
+	deletion, err := pending.ScheduleDeletion(c.Request.Context(), table, recordID, userID, window)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to schedule deletion", err))
+		return true
+	}
+
+	auditLog := &models.AuditLog{
+		ID:        uuid.New(),
+		TableName: table,
+		RecordID:  recordID,
+		Action:    models.ActionUpdate,
+		NewValues: map[string]interface{}{"delete_at": deletion.ExecuteAt},
+		ChangedBy: userID,
+		ChangedAt: time.Now(),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+	}
+	middleware.MarkAudited(c)
+	if err := auditService.Record(c.Request.Context(), auditLog); err != nil {
+		log.Printf("Failed to create audit log: %v", err)
+	}
+
+	c.JSON(http.StatusAccepted, deletion)
+	return true
+}
+
+// PendingDeletionHandler lists and undoes the deletions of users,
+// categories and products still in their undo window.
+type PendingDeletionHandler struct {
+	pending      *database.PendingDeletionService
+	auditService *database.AuditService
+}
+
+func NewPendingDeletionHandler(db *sql.DB) *PendingDeletionHandler {
+	return &PendingDeletionHandler{
+		pending:      database.NewPendingDeletionService(db),
+		auditService: database.NewAuditService(db),
+	}
+}
+
+// GetPendingDeletions lists the deletions that can still be undone, the
+// soonest first.
+func (h *PendingDeletionHandler) GetPendingDeletions(c *gin.Context) {
+	deletions, err := h.pending.GetPendingDeletions(c.Request.Context())
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get pending deletions", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deletions": deletions})
+}
+
+func (h *PendingDeletionHandler) UndoUserDeletion(c *gin.Context) {
+	h.undo(c, models.DeletionUsers, "Invalid user ID")
+}
+
+func (h *PendingDeletionHandler) UndoCategoryDeletion(c *gin.Context) {
+	h.undo(c, models.DeletionCategories, "Invalid category ID")
+}
+
+func (h *PendingDeletionHandler) UndoProductDeletion(c *gin.Context) {
+	h.undo(c, models.DeletionProducts, "Invalid product ID")
+}
+
+// undo cancels the pending deletion of the record of table named by the
+// id parameter.
+func (h *PendingDeletionHandler) undo(c *gin.Context, table, invalidID string) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest(invalidID))
+		return
+	}
+
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	deletion, err := h.pending.UndoDeletion(c.Request.Context(), table, id)
+	if err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrNoPendingDeletion, err))
+		return
+	}
+
+	auditLog := &models.AuditLog{
+		ID:        uuid.New(),
+		TableName: table,
+		RecordID:  id,
+		Action:    models.ActionUpdate,
+		OldValues: map[string]interface{}{"delete_at": deletion.ExecuteAt},
+		NewValues: map[string]interface{}{"delete_at": nil},
+		ChangedBy: userID,
+		ChangedAt: time.Now(),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+	}
+	middleware.MarkAudited(c)
+	if err := h.auditService.Record(c.Request.Context(), auditLog); err != nil {
+		log.Printf("Failed to create audit log: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Deletion undone successfully"})
+}
@@ -2,38 +2,37 @@ package handlers
 
 import (
 	"database/sql"
-	"fmt"
 	"log"
 	"net/http"
 	"time"
 
+	"rtims-backend/internal/apierror"
 	"rtims-backend/internal/database"
 	"rtims-backend/internal/models"
 	"rtims-backend/internal/middleware"
-	"rtims-backend/internal/websocket"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/go-redis/redis/v8"
 )
 
+// ProductHandler serves products and stock movements. Live stock updates
+// and low stock alerts are published by the outbox relay once a movement
+// commits (see notify.RunOutboxRelay).
 type ProductHandler struct {
-	productService      *database.ProductService
-	auditService        *database.AuditService
-	notificationService *database.NotificationService
-	db                  *sql.DB
-	redisClient         *redis.Client
-	hub                 *websocket.Hub
+	productService   *database.ProductService
+	auditService     *database.AuditService
+	pendingDeletions *database.PendingDeletionService
+	recent           *database.RecentProducts
+	db               *sql.DB
 }
 
-func NewProductHandler(db *sql.DB, redisClient *redis.Client, hub *websocket.Hub) *ProductHandler {
+func NewProductHandler(db *sql.DB, cache *database.Cache, recent *database.RecentProducts) *ProductHandler {
 	return &ProductHandler{
-		productService:      database.NewProductService(db),
-		auditService:        database.NewAuditService(db),
-		notificationService: database.NewNotificationService(db),
-		db:                  db,
-		redisClient:         redisClient,
-		hub:                 hub,
+		productService:   database.NewCachedProductService(db, cache),
+		auditService:     database.NewAuditService(db),
+		pendingDeletions: database.NewPendingDeletionService(db),
+		recent:           recent,
+		db:               db,
 	}
 }
 
@@ -58,7 +57,8 @@ func (h *ProductHandler) createAuditLog(c *gin.Context, recordID uuid.UUID, acti
 		UserAgent:  c.GetHeader("User-Agent"),
 	}
 
-	err = h.auditService.CreateAuditLog(auditLog)
+	middleware.MarkAudited(c)
+	err = h.auditService.Record(c.Request.Context(), auditLog)
 	if err != nil {
 		log.Printf("Failed to create audit log: %v", err)
 	}
@@ -68,7 +68,7 @@ func (h *ProductHandler) GetProducts(c *gin.Context) {
 	// Parse query parameters
 	var filter models.ProductFilter
 	if err := c.ShouldBindQuery(&filter); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, apierror.Invalid(err))
 		return
 	}
 
@@ -83,10 +83,15 @@ func (h *ProductHandler) GetProducts(c *gin.Context) {
 		filter.Limit = 100
 	}
 
+	version, err := h.productService.GetProductsVersion(c.Request.Context())
+	if notModified(c, version, err) {
+		return
+	}
+
 	// Get products from database
-	products, total, err := h.productService.GetProducts(filter)
+	products, total, err := h.productService.GetProducts(c.Request.Context(), filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get products: " + err.Error()})
+		apierror.Respond(c, apierror.Failed("Failed to get products", err))
 		return
 	}
 
@@ -104,15 +109,16 @@ func (h *ProductHandler) GetProducts(c *gin.Context) {
 func (h *ProductHandler) GetProduct(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		apierror.Respond(c, apierror.BadRequest("Invalid product ID"))
 		return
 	}
 
-	product, err := h.productService.GetProduct(id)
+	product, err := h.productService.GetProduct(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		apierror.Respond(c, apierror.Missing(apierror.ErrProductNotFound, err))
 		return
 	}
+	recordView(c, h.recent, id)
 
 	c.JSON(http.StatusOK, product)
 }
@@ -120,13 +126,13 @@ func (h *ProductHandler) GetProduct(c *gin.Context) {
 func (h *ProductHandler) CreateProduct(c *gin.Context) {
 	var req models.CreateProductRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, apierror.Invalid(err))
 		return
 	}
 
 	userID, _, err := middleware.GetCurrentUser(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apierror.Respond(c, apierror.ErrUnauthenticated)
 		return
 	}
 
@@ -144,9 +150,9 @@ func (h *ProductHandler) CreateProduct(c *gin.Context) {
 	}
 
 	// Save product to database
-	err = h.productService.CreateProduct(product)
+	err = h.productService.CreateProduct(c.Request.Context(), product)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product: " + err.Error()})
+		apierror.Respond(c, apierror.Failed("Failed to create product", err))
 		return
 	}
 
@@ -163,16 +169,11 @@ func (h *ProductHandler) CreateProduct(c *gin.Context) {
 
 	// Create stock movement if initial stock is provided
 	if req.Stock > 0 {
-		err = h.productService.UpdateProductStock(product.ID, req.Stock, models.ReasonPurchase, userID, "Initial stock")
+		_, err = h.productService.UpdateProductStock(c.Request.Context(), product.ID, req.Stock, models.ReasonPurchase, userID, "Initial stock")
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create initial stock movement: " + err.Error()})
+			apierror.Respond(c, apierror.Failed("Failed to create initial stock movement", err))
 			return
 		}
-
-		// Send WebSocket notification if stock is low
-		if req.Stock <= req.MinimumThreshold {
-			websocket.BroadcastStockUpdate(h.hub, product.ID, req.Stock)
-		}
 	}
 
 	c.JSON(http.StatusCreated, product)
@@ -181,19 +182,19 @@ func (h *ProductHandler) CreateProduct(c *gin.Context) {
 func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		apierror.Respond(c, apierror.BadRequest("Invalid product ID"))
 		return
 	}
 
 	var req models.UpdateProductRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, apierror.Invalid(err))
 		return
 	}
 
 	_, _, err = middleware.GetCurrentUser(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apierror.Respond(c, apierror.ErrUnauthenticated)
 		return
 	}
 
@@ -222,23 +223,23 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 	}
 
 	// Get old product for audit logging
-	oldProduct, err := h.productService.GetProduct(id)
+	oldProduct, err := h.productService.GetProduct(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current product: " + err.Error()})
+		apierror.Respond(c, apierror.Missing(apierror.ErrProductNotFound, err))
 		return
 	}
 
 	// Update product in database
-	err = h.productService.UpdateProduct(id, updates)
+	err = h.productService.UpdateProduct(c.Request.Context(), id, updates)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product: " + err.Error()})
+		apierror.Respond(c, apierror.Failed("Failed to update product", err))
 		return
 	}
 
 	// Get updated product
-	product, err := h.productService.GetProduct(id)
+	product, err := h.productService.GetProduct(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get updated product: " + err.Error()})
+		apierror.Respond(c, apierror.Failed("Failed to get updated product", err))
 		return
 	}
 
@@ -267,27 +268,32 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 func (h *ProductHandler) DeleteProduct(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		apierror.Respond(c, apierror.BadRequest("Invalid product ID"))
 		return
 	}
 
-	_, _, err = middleware.GetCurrentUser(c)
+	userID, _, err := middleware.GetCurrentUser(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apierror.Respond(c, apierror.ErrUnauthenticated)
 		return
 	}
 
 	// Get product for audit logging before deletion
-	product, err := h.productService.GetProduct(id)
+	product, err := h.productService.GetProduct(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get product: " + err.Error()})
+		apierror.Respond(c, apierror.Missing(apierror.ErrProductNotFound, err))
+		return
+	}
+
+	// The deletion can be undone until the undo window passes
+	if scheduleDeletion(c, h.pendingDeletions, h.auditService, models.DeletionProducts, id, userID) {
 		return
 	}
 
 	// Delete product from database
-	err = h.productService.DeleteProduct(id)
+	err = h.productService.DeleteProduct(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete product: " + err.Error()})
+		apierror.Respond(c, apierror.Failed("Failed to delete product", err))
 		return
 	}
 
@@ -308,89 +314,40 @@ func (h *ProductHandler) DeleteProduct(c *gin.Context) {
 func (h *ProductHandler) UpdateStock(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		apierror.Respond(c, apierror.BadRequest("Invalid product ID"))
 		return
 	}
 
 	var req models.CreateStockMovementRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, apierror.Invalid(err))
 		return
 	}
 
 	userID, _, err := middleware.GetCurrentUser(c)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	// Get current product for audit logging
-	product, err := h.productService.GetProduct(id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get product: " + err.Error()})
+		apierror.Respond(c, apierror.ErrUnauthenticated)
 		return
 	}
 
-	oldStock := product.Stock
-
-	// Update product stock in database
-	err = h.productService.UpdateProductStock(id, req.Change, req.Reason, userID, req.Notes)
+	// Update product stock in database; the stock below was read while the
+	// product row was locked
+	update, err := h.productService.UpdateProductStock(c.Request.Context(), id, req.Change, req.Reason, userID, req.Notes)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stock: " + err.Error()})
-		return
-	}
-
-	// Get updated product
-	updatedProduct, err := h.productService.GetProduct(id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get updated product: " + err.Error()})
+		apierror.Respond(c, apierror.Failed("Failed to update stock", err))
 		return
 	}
 
 	// Create audit log
 	h.createAuditLog(c, id, models.ActionUpdate, map[string]interface{}{
-		"stock": oldStock,
+		"stock": update.PreviousStock,
 	}, map[string]interface{}{
-		"stock": updatedProduct.Stock,
+		"stock": update.Product.Stock,
 	})
 
-	// Send WebSocket notification
-	websocket.BroadcastStockUpdate(h.hub, id, updatedProduct.Stock)
-
-	// Create notification if stock is low
-	if updatedProduct.Stock <= updatedProduct.MinimumThreshold && updatedProduct.MinimumThreshold > 0 {
-		notification := &models.Notification{
-			ID:        uuid.New(),
-			UserID:    userID,
-			Message:   fmt.Sprintf("Product '%s' stock is low (%d remaining)", updatedProduct.Name, updatedProduct.Stock),
-			Type:      models.NotificationLowStock,
-			IsRead:    false,
-			CreatedAt: time.Now(),
-		}
-
-		// Save notification to database
-		err = h.notificationService.CreateNotification(notification)
-		if err != nil {
-			log.Printf("Failed to create low stock notification: %v", err)
-		} else {
-			// Send WebSocket notification for low stock
-			websocket.BroadcastNotification(h.hub, userID, notification.Message, string(notification.Type))
-		}
-	}
-
-	stockMovement := models.StockMovement{
-		ID:        uuid.New(),
-		ProductID: id,
-		Change:    req.Change,
-		Reason:    req.Reason,
-		CreatedBy: userID,
-		CreatedAt: time.Now(),
-		Notes:     req.Notes,
-	}
-
 	c.JSON(http.StatusOK, gin.H{
 		"message":        "Stock updated successfully",
-		"stock_movement": stockMovement,
+		"stock_movement": update.Movement,
 	})
 }
 
@@ -398,7 +355,7 @@ func (h *ProductHandler) GetStockMovements(c *gin.Context) {
 	// Parse query parameters
 	var filter models.StockMovementFilter
 	if err := c.ShouldBindQuery(&filter); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, apierror.Invalid(err))
 		return
 	}
 
@@ -414,9 +371,9 @@ func (h *ProductHandler) GetStockMovements(c *gin.Context) {
 	}
 
 	// Get stock movements from database
-	movements, total, err := h.productService.GetStockMovements(filter)
+	movements, total, err := h.productService.GetStockMovements(c.Request.Context(), filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get stock movements: " + err.Error()})
+		apierror.Respond(c, apierror.Failed("Failed to get stock movements", err))
 		return
 	}
 
@@ -434,13 +391,13 @@ func (h *ProductHandler) GetStockMovements(c *gin.Context) {
 func (h *ProductHandler) GetStockMovement(c *gin.Context) {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid movement ID"})
+		apierror.Respond(c, apierror.BadRequest("Invalid movement ID"))
 		return
 	}
 
-	movement, err := h.productService.GetStockMovement(id)
+	movement, err := h.productService.GetStockMovement(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Stock movement not found"})
+		apierror.Respond(c, apierror.Missing(apierror.ErrMovementNotFound, err))
 		return
 	}
 
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"net/http"
+
+	"rtims-backend/internal/apierror"
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// recordView adds a product the current user opened to their recently
+// viewed products.
+func recordView(c *gin.Context, recent *database.RecentProducts, productID uuid.UUID) {
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		return
+	}
+	recent.Record(c.Request.Context(), userID, productID)
+}
+
+// GetRecentProducts returns the products the current user viewed last,
+// newest first. Products deleted since are left out, and the list is empty
+// while Redis is down.
+func (h *ProductHandler) GetRecentProducts(c *gin.Context) {
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	products, err := h.productService.GetProductsByID(c.Request.Context(), h.recent.Get(c.Request.Context(), userID))
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get recent products", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, products)
+}
+
+// GetFavoriteProducts returns the current user's favorite products, most
+// recently added first.
+func (h *ProductHandler) GetFavoriteProducts(c *gin.Context) {
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	products, err := h.productService.GetFavoriteProducts(c.Request.Context(), userID)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get favorite products", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, products)
+}
+
+func (h *ProductHandler) AddFavorite(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid product ID"))
+		return
+	}
+
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	if err := h.productService.AddFavoriteProduct(c.Request.Context(), userID, id); err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrProductNotFound, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Product added to favorites successfully"})
+}
+
+func (h *ProductHandler) RemoveFavorite(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid product ID"))
+		return
+	}
+
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	if err := h.productService.RemoveFavoriteProduct(c.Request.Context(), userID, id); err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to remove favorite product", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Product removed from favorites successfully"})
+}
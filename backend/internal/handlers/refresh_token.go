@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+const (
+	refreshTokenTTL = 24 * time.Hour
+	// revokedRefreshToken replaces the user ID of a logged out refresh token.
+	revokedRefreshToken = "revoked"
+)
+
+var errInvalidRefreshToken = errors.New("invalid refresh token")
+
+// refreshClaims are the claims of a refresh token. Stateless marks one
+// issued while Redis was unreachable, and so never recorded there.
+type refreshClaims struct {
+	Stateless bool `json:"stateless,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func signRefreshToken(userID uuid.UUID, stateless bool) (string, error) {
+	claims := refreshClaims{
+		Stateless: stateless,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID.String(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(refreshTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        uuid.New().String(), // Unique token ID
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	return token, nil
+}
+
+// validateRefreshToken returns the user a refresh token belongs to. Tokens
+// are recorded in Redis at login and marked revoked at logout; a stateless
+// token is recorded on its first use once Redis is back. While Redis is
+// unreachable any unexpired, correctly signed token is accepted, so a
+// logout cannot be enforced until Redis returns.
+func validateRefreshToken(tokenString string) (uuid.UUID, error) {
+	claims, err := parseRefreshToken(tokenString)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, errInvalidRefreshToken
+	}
+
+	key := "refresh_token:" + tokenString
+	stored, err := redisClient.Get(ctx, key).Result()
+	switch {
+	case err == redis.Nil:
+		if !claims.Stateless {
+			return uuid.Nil, errInvalidRefreshToken
+		}
+		if err := redisClient.Set(ctx, key, userID.String(), time.Until(claims.ExpiresAt.Time)).Err(); err != nil {
+			log.Printf("Failed to record stateless refresh token: %v", err)
+		}
+	case err != nil:
+		log.Printf("Redis unavailable, validating refresh token from its signature: %v", err)
+	case stored != userID.String():
+		return uuid.Nil, errInvalidRefreshToken
+	}
+	return userID, nil
+}
+
+func parseRefreshToken(tokenString string) (*refreshClaims, error) {
+	claims := &refreshClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return jwtSecret, nil
+	})
+	// Access tokens are signed with the same secret but carry no token ID
+	if err != nil || !token.Valid || claims.ID == "" {
+		return nil, errInvalidRefreshToken
+	}
+	return claims, nil
+}
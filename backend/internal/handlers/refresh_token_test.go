@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+func TestValidateRefreshTokenWithoutRedis(t *testing.T) {
+	defer func(secret []byte, client *redis.Client) { jwtSecret, redisClient = secret, client }(jwtSecret, redisClient)
+	jwtSecret = []byte("test-secret-that-is-long-enough-for-hs256")
+	// Nothing listens on port 1, so every Redis call fails at once
+	redisClient = redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: 100 * time.Millisecond})
+	defer redisClient.Close()
+
+	userID := uuid.New()
+	for _, stateless := range []bool{false, true} {
+		token, err := signRefreshToken(userID, stateless)
+		if err != nil {
+			t.Fatalf("Failed to sign refresh token: %v", err)
+		}
+		got, err := validateRefreshToken(token)
+		if err != nil || got != userID {
+			t.Errorf("Expected a signed token (stateless %v) to be accepted while Redis is down, got %v %v", stateless, got, err)
+		}
+	}
+
+	accessToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   userID.String(),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString(jwtSecret)
+	expired, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims{RegisteredClaims: jwt.RegisteredClaims{
+		Subject:   userID.String(),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+		ID:        uuid.New().String(),
+	}}).SignedString(jwtSecret)
+	forged, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims{RegisteredClaims: jwt.RegisteredClaims{
+		Subject:   userID.String(),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		ID:        uuid.New().String(),
+	}}).SignedString([]byte("some-other-secret"))
+
+	for name, token := range map[string]string{"access token": accessToken, "expired": expired, "forged": forged, "garbage": "not-a-jwt"} {
+		if _, err := validateRefreshToken(token); err == nil {
+			t.Errorf("Expected %s to be rejected", name)
+		}
+	}
+}
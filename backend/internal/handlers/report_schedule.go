@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/mail"
+	"time"
+
+	"rtims-backend/internal/apierror"
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/middleware"
+	"rtims-backend/internal/models"
+	"rtims-backend/internal/reports"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ReportScheduleHandler struct {
+	scheduleService *database.ReportScheduleService
+	auditService    *database.AuditService
+	db              *sql.DB
+}
+
+func NewReportScheduleHandler(db *sql.DB) *ReportScheduleHandler {
+	return &ReportScheduleHandler{
+		scheduleService: database.NewReportScheduleService(db),
+		auditService:    database.NewAuditService(db),
+		db:              db,
+	}
+}
+
+// validateSchedule checks the report parameters, cron expression and
+// recipients, and returns a message suitable for a 400 response.
+func validateSchedule(report json.RawMessage, cronExpression string, recipients []string) string {
+	var params reports.Params
+	if err := json.Unmarshal(report, &params); err != nil {
+		return "Invalid report parameters"
+	}
+	if err := params.Validate(); err != nil {
+		return err.Error()
+	}
+	if _, err := reports.ParseCron(cronExpression); err != nil {
+		return "Invalid cron expression: " + err.Error()
+	}
+	if len(recipients) == 0 {
+		return "At least one recipient is required"
+	}
+	for _, recipient := range recipients {
+		if _, err := mail.ParseAddress(recipient); err != nil {
+			return "Invalid recipient email: " + recipient
+		}
+	}
+	return ""
+}
+
+func (h *ReportScheduleHandler) GetSchedules(c *gin.Context) {
+	schedules, err := h.scheduleService.GetSchedules(c.Request.Context())
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get report schedules", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, schedules)
+}
+
+func (h *ReportScheduleHandler) CreateSchedule(c *gin.Context) {
+	var req models.CreateReportScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+
+	if req.Name == "" {
+		apierror.Respond(c, apierror.BadRequest("Name is required"))
+		return
+	}
+	if msg := validateSchedule(req.Report, req.CronExpression, req.Recipients); msg != "" {
+		apierror.Respond(c, apierror.BadRequest(msg))
+		return
+	}
+
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	schedule := &models.ReportSchedule{
+		ID:             uuid.New(),
+		Name:           req.Name,
+		Report:         req.Report,
+		CronExpression: req.CronExpression,
+		Recipients:     req.Recipients,
+		IsActive:       req.IsActive == nil || *req.IsActive,
+		CreatedBy:      &userID,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+	if schedule.IsActive {
+		schedule.NextRunAt, _ = reports.NextRun(schedule.CronExpression, time.Now())
+	}
+
+	err = h.scheduleService.CreateSchedule(schedule)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to create report schedule", err))
+		return
+	}
+
+	// Create audit log
+	auditLog := &models.AuditLog{
+		ID:         uuid.New(),
+		TableName:  "report_schedules",
+		RecordID:   schedule.ID,
+		Action:     models.ActionCreate,
+		OldValues:  nil,
+		NewValues:  map[string]interface{}{"name": schedule.Name, "cron_expression": schedule.CronExpression, "recipients": schedule.Recipients},
+		ChangedBy:  userID,
+		ChangedAt:  time.Now(),
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.GetHeader("User-Agent"),
+	}
+
+	middleware.MarkAudited(c)
+	err = h.auditService.Record(c.Request.Context(), auditLog)
+	if err != nil {
+		log.Printf("Failed to create audit log: %v", err)
+	}
+
+	c.JSON(http.StatusCreated, schedule)
+}
+
+func (h *ReportScheduleHandler) UpdateSchedule(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid schedule ID"))
+		return
+	}
+
+	var req models.UpdateReportScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	schedule, err := h.scheduleService.GetSchedule(c.Request.Context(), id)
+	if err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrScheduleNotFound, err))
+		return
+	}
+	oldValues := map[string]interface{}{
+		"name":            schedule.Name,
+		"cron_expression": schedule.CronExpression,
+		"recipients":      schedule.Recipients,
+		"is_active":       schedule.IsActive,
+	}
+
+	if req.Name != nil {
+		schedule.Name = *req.Name
+	}
+	if len(req.Report) > 0 {
+		schedule.Report = req.Report
+	}
+	if req.CronExpression != nil {
+		schedule.CronExpression = *req.CronExpression
+	}
+	if req.Recipients != nil {
+		schedule.Recipients = req.Recipients
+	}
+	if req.IsActive != nil {
+		schedule.IsActive = *req.IsActive
+	}
+
+	if schedule.Name == "" {
+		apierror.Respond(c, apierror.BadRequest("Name is required"))
+		return
+	}
+	if msg := validateSchedule(schedule.Report, schedule.CronExpression, schedule.Recipients); msg != "" {
+		apierror.Respond(c, apierror.BadRequest(msg))
+		return
+	}
+
+	// Recompute from now so edits and re-activation don't trigger a backlog run
+	schedule.NextRunAt = nil
+	if schedule.IsActive {
+		schedule.NextRunAt, _ = reports.NextRun(schedule.CronExpression, time.Now())
+	}
+
+	err = h.scheduleService.UpdateSchedule(schedule)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to update report schedule", err))
+		return
+	}
+
+	// Create audit log
+	auditLog := &models.AuditLog{
+		ID:         uuid.New(),
+		TableName:  "report_schedules",
+		RecordID:   id,
+		Action:     models.ActionUpdate,
+		OldValues:  oldValues,
+		NewValues:  map[string]interface{}{"name": schedule.Name, "cron_expression": schedule.CronExpression, "recipients": schedule.Recipients, "is_active": schedule.IsActive},
+		ChangedBy:  userID,
+		ChangedAt:  time.Now(),
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.GetHeader("User-Agent"),
+	}
+
+	middleware.MarkAudited(c)
+	err = h.auditService.Record(c.Request.Context(), auditLog)
+	if err != nil {
+		log.Printf("Failed to create audit log: %v", err)
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+func (h *ReportScheduleHandler) DeleteSchedule(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid schedule ID"))
+		return
+	}
+
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	schedule, err := h.scheduleService.GetSchedule(c.Request.Context(), id)
+	if err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrScheduleNotFound, err))
+		return
+	}
+
+	err = h.scheduleService.DeleteSchedule(id)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to delete report schedule", err))
+		return
+	}
+
+	// Create audit log
+	auditLog := &models.AuditLog{
+		ID:         uuid.New(),
+		TableName:  "report_schedules",
+		RecordID:   id,
+		Action:     models.ActionDelete,
+		OldValues:  map[string]interface{}{"name": schedule.Name, "cron_expression": schedule.CronExpression, "recipients": schedule.Recipients},
+		NewValues:  nil,
+		ChangedBy:  userID,
+		ChangedAt:  time.Now(),
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.GetHeader("User-Agent"),
+	}
+
+	middleware.MarkAudited(c)
+	err = h.auditService.Record(c.Request.Context(), auditLog)
+	if err != nil {
+		log.Printf("Failed to create audit log: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Report schedule deleted successfully"})
+}
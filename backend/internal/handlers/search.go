@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+
+	"rtims-backend/internal/apierror"
+	"rtims-backend/internal/search"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SearchHandler manages the search index. indexer is nil when SEARCH_URL
+// is not set.
+type SearchHandler struct {
+	indexer *search.Indexer
+}
+
+func NewSearchHandler(indexer *search.Indexer) *SearchHandler {
+	return &SearchHandler{indexer: indexer}
+}
+
+// Reindex starts copying every product, stock movement and audit entry
+// into the search index, answering before it is done.
+func (h *SearchHandler) Reindex(c *gin.Context) {
+	if h.indexer == nil {
+		apierror.Respond(c, apierror.ErrSearchDisabled)
+		return
+	}
+
+	// The only error is a reindex already running
+	if err := h.indexer.StartReindex(); err != nil {
+		apierror.Respond(c, apierror.ErrReindexRunning.Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Search reindex started"})
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"rtims-backend/internal/apierror"
+	"rtims-backend/internal/seed"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SeedHandler loads demo data. It is only routed for host admins, where
+// DEV_SEED_ENABLED is set.
+type SeedHandler struct {
+	db *sql.DB
+}
+
+func NewSeedHandler(db *sql.DB) *SeedHandler {
+	return &SeedHandler{db: db}
+}
+
+type SeedRequest struct {
+	Products int `json:"products"`
+	Days     int `json:"days"`
+}
+
+// Seed creates the demo admin and staff accounts, categories, products and
+// stock movements. Both body fields are optional. The accounts get a random
+// password, answered only by the run that creates them.
+func (h *SeedHandler) Seed(c *gin.Context) {
+	var req SeedRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Respond(c, apierror.BadRequest("Invalid request body"))
+			return
+		}
+	}
+
+	opts := seed.DefaultOptions()
+	if req.Products != 0 {
+		opts.Products = req.Products
+	}
+	if req.Days != 0 {
+		opts.Days = req.Days
+	}
+
+	result, err := seed.Run(h.db, opts)
+	if err == seed.ErrInvalidOptions {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to seed database", err))
+		return
+	}
+
+	response := gin.H{
+		"message":     "Database seeded",
+		"created":     result,
+		"admin_email": opts.AdminEmail,
+	}
+	if result.Password != "" {
+		response["admin_password"] = result.Password
+	}
+	c.JSON(http.StatusOK, response)
+}
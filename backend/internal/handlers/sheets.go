@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"rtims-backend/internal/apierror"
+	"rtims-backend/internal/sheets"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SheetsHandler pushes the Google Sheets exports configured in settings on
+// demand.
+type SheetsHandler struct {
+	exporter *sheets.Exporter
+}
+
+func NewSheetsHandler(exporter *sheets.Exporter) *SheetsHandler {
+	return &SheetsHandler{exporter: exporter}
+}
+
+// sheetsError maps the errors of Sheets exports to responses, and others
+// as apierror.Failed.
+func sheetsError(message string, err error) *apierror.Error {
+	var apiErr *sheets.APIError
+	var urlErr *url.Error
+	switch {
+	case errors.Is(err, sheets.ErrUnknownExport):
+		return apierror.ErrSheetsExportNotFound.Wrap(err)
+	case errors.Is(err, sheets.ErrNotConfigured):
+		return apierror.ErrSheetsNotConfigured.Wrap(err)
+	case errors.Is(err, sheets.ErrExportAccount):
+		return apierror.ErrSheetsAccount.Wrap(err)
+	case errors.As(err, &apiErr), errors.As(err, &urlErr):
+		return apierror.ErrSheetsUnavailable.Wrap(err)
+	}
+	return apierror.Failed(message, err)
+}
+
+// Run pushes an export to its sheet now rather than at the next scheduled
+// run.
+func (h *SheetsHandler) Run(c *gin.Context) {
+	push, err := h.exporter.RunExport(c.Request.Context(), c.Param("export"))
+	if err != nil {
+		apierror.Respond(c, sheetsError("Failed to push Sheets export", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, push)
+}
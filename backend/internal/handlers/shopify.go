@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"rtims-backend/internal/apierror"
+	"rtims-backend/internal/models"
+	"rtims-backend/internal/shopify"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxShopifyWebhookBody is the largest order webhook accepted.
+const maxShopifyWebhookBody = 5 << 20
+
+// ShopifyHandler maps the variants of the Shopify stores configured in
+// settings and receives their order webhooks.
+type ShopifyHandler struct {
+	syncer *shopify.Syncer
+}
+
+func NewShopifyHandler(syncer *shopify.Syncer) *ShopifyHandler {
+	return &ShopifyHandler{syncer: syncer}
+}
+
+// shopifyError maps the errors of the Shopify integration to responses,
+// and others as apierror.Failed.
+func shopifyError(message string, err error) *apierror.Error {
+	var apiErr *shopify.APIError
+	var urlErr *url.Error
+	switch {
+	case errors.Is(err, shopify.ErrUnknownStore):
+		return apierror.ErrShopifyStoreNotFound.Wrap(err)
+	case errors.Is(err, shopify.ErrVariantNotFound):
+		return apierror.ErrVariantNotFound.Wrap(err)
+	case errors.Is(err, shopify.ErrStoreAccount):
+		return apierror.ErrShopifyAccount.Wrap(err)
+	case errors.As(err, &apiErr), errors.As(err, &urlErr):
+		return apierror.ErrShopifyUnavailable.Wrap(err)
+	}
+	return apierror.Failed(message, err)
+}
+
+// variantID parses the variant_id path parameter.
+func variantID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("variant_id"), 10, 64)
+	if err != nil || id <= 0 {
+		apierror.Respond(c, apierror.BadRequest("Invalid variant ID"))
+		return 0, false
+	}
+	return id, true
+}
+
+// GetStores returns the configured stores with the state of their sync.
+func (h *ShopifyHandler) GetStores(c *gin.Context) {
+	stores, err := h.syncer.Status(c.Request.Context())
+	if err != nil {
+		apierror.Respond(c, shopifyError("Failed to get Shopify stores", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, stores)
+}
+
+func (h *ShopifyHandler) GetVariants(c *gin.Context) {
+	variants, err := h.syncer.Variants(c.Request.Context(), c.Param("shop"))
+	if err != nil {
+		apierror.Respond(c, shopifyError("Failed to get Shopify variants", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, variants)
+}
+
+// SyncVariants maps a store's variants to the products with their SKUs and
+// pushes the stock of every mapped product.
+func (h *ShopifyHandler) SyncVariants(c *gin.Context) {
+	result, err := h.syncer.SyncVariants(c.Request.Context(), c.Param("shop"))
+	if err != nil {
+		apierror.Respond(c, shopifyError("Failed to sync Shopify variants", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// MapVariant maps a variant to a product by hand.
+func (h *ShopifyHandler) MapVariant(c *gin.Context) {
+	id, ok := variantID(c)
+	if !ok {
+		return
+	}
+
+	var req models.MapShopifyVariantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+
+	variant, err := h.syncer.MapVariant(c.Request.Context(), c.Param("shop"), id, req)
+	if err != nil {
+		mapped := shopifyError("Failed to map Shopify variant", err)
+		if mapped.Code == apierror.CodeNotFound {
+			mapped = apierror.Missing(apierror.ErrProductNotFound, err)
+		}
+		apierror.Respond(c, mapped)
+		return
+	}
+
+	c.JSON(http.StatusOK, variant)
+}
+
+func (h *ShopifyHandler) UnmapVariant(c *gin.Context) {
+	id, ok := variantID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.syncer.UnmapVariant(c.Request.Context(), c.Param("shop"), id); err != nil {
+		mapped := shopifyError("Failed to unmap Shopify variant", err)
+		if mapped.Code == apierror.CodeNotFound {
+			mapped = apierror.ErrVariantNotFound.Wrap(err)
+		}
+		apierror.Respond(c, mapped)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Shopify variant unmapped successfully"})
+}
+
+// GetOrders returns the most recently ingested order lines of a store,
+// with the movement each was recorded as or why it was not.
+func (h *ShopifyHandler) GetOrders(c *gin.Context) {
+	var filter models.ShopifyOrderFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+	if filter.Limit > 200 {
+		filter.Limit = 200
+	}
+
+	lines, err := h.syncer.OrderLines(c.Request.Context(), c.Param("shop"), filter.Limit)
+	if err != nil {
+		apierror.Respond(c, shopifyError("Failed to get Shopify orders", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, lines)
+}
+
+// ReceiveWebhook ingests an order Shopify sends by webhook. It is not
+// authenticated by token but by the signature of the store's webhook
+// secret. Topics other than orders/create are acknowledged and ignored.
+func (h *ShopifyHandler) ReceiveWebhook(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxShopifyWebhookBody))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Failed to read request body"))
+		return
+	}
+
+	shop := c.GetHeader(shopify.ShopHeader)
+	store, err := h.syncer.Store(shop)
+	if err != nil && !errors.Is(err, shopify.ErrUnknownStore) {
+		apierror.Respond(c, apierror.Failed("Failed to receive Shopify webhook", err))
+		return
+	}
+	// An unknown shop is answered like a bad signature, so the endpoint does
+	// not reveal which stores are configured
+	if err != nil || !shopify.Verify(store.WebhookSecret, body, c.GetHeader(shopify.SignatureHeader)) {
+		apierror.Respond(c, apierror.New(http.StatusUnauthorized, apierror.CodeUnauthenticated, "Invalid Shopify webhook signature"))
+		return
+	}
+
+	if c.GetHeader(shopify.TopicHeader) != shopify.TopicOrderCreated {
+		c.Status(http.StatusOK)
+		return
+	}
+
+	var order shopify.Order
+	if err := json.Unmarshal(body, &order); err != nil || order.ID == 0 {
+		apierror.Respond(c, apierror.BadRequest("Request body is not a Shopify order"))
+		return
+	}
+
+	// Failing here makes Shopify send the order again; lines already
+	// ingested are skipped then
+	lines, err := h.syncer.IngestOrder(c.Request.Context(), shop, order)
+	if err != nil {
+		apierror.Respond(c, shopifyError("Failed to ingest Shopify order", err))
+		return
+	}
+	for _, line := range lines {
+		if line.Error != "" {
+			log.Printf("Shopify order %s (%s) line %d not recorded: %s", order.Name, shop, line.LineItemID, line.Error)
+		}
+	}
+
+	c.Status(http.StatusOK)
+}
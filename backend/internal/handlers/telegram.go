@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"rtims-backend/internal/apierror"
+	"rtims-backend/internal/middleware"
+	"rtims-backend/internal/telegram"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxTelegramUpdate is the largest update accepted.
+const maxTelegramUpdate = 1 << 20
+
+// TelegramHandler links users' Telegram chats and receives the updates
+// Telegram sends the bot.
+type TelegramHandler struct {
+	bot *telegram.Bot
+}
+
+func NewTelegramHandler(bot *telegram.Bot) *TelegramHandler {
+	return &TelegramHandler{bot: bot}
+}
+
+// telegramError maps the errors of the Telegram integration to responses,
+// and others as apierror.Failed.
+func telegramError(message string, err error) *apierror.Error {
+	var apiErr *telegram.APIError
+	var urlErr *url.Error
+	switch {
+	case errors.Is(err, telegram.ErrNotConfigured):
+		return apierror.ErrTelegramNotConfigured.Wrap(err)
+	case errors.Is(err, telegram.ErrLinkUnavailable):
+		return apierror.New(http.StatusServiceUnavailable, apierror.CodeUnavailable,
+			"Telegram linking is temporarily unavailable, try again shortly").Wrap(err)
+	case errors.As(err, &apiErr), errors.As(err, &urlErr):
+		return apierror.ErrTelegramUnavailable.Wrap(err)
+	}
+	return apierror.Failed(message, err)
+}
+
+// CreateLinkCode generates a code the current user sends the bot to link
+// a chat to themselves.
+func (h *TelegramHandler) CreateLinkCode(c *gin.Context) {
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	code, err := h.bot.LinkCode(c.Request.Context(), userID)
+	if err != nil {
+		apierror.Respond(c, telegramError("Failed to create Telegram link code", err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, code)
+}
+
+// GetChats returns the chats linked to the current user.
+func (h *TelegramHandler) GetChats(c *gin.Context) {
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	chats, err := h.bot.Chats(c.Request.Context(), userID)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get Telegram chats", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, chats)
+}
+
+func (h *TelegramHandler) UnlinkChat(c *gin.Context) {
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+	chatID, err := strconv.ParseInt(c.Param("chat_id"), 10, 64)
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid chat ID"))
+		return
+	}
+
+	if err := h.bot.Unlink(c.Request.Context(), chatID, userID); err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrTelegramChatNotFound, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Telegram chat unlinked successfully"})
+}
+
+// RegisterWebhook points the bot's webhook at this server.
+func (h *TelegramHandler) RegisterWebhook(c *gin.Context) {
+	webhookURL, err := h.bot.RegisterWebhook(c.Request.Context())
+	if err != nil {
+		apierror.Respond(c, telegramError("Failed to register Telegram webhook", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"url": webhookURL})
+}
+
+// ReceiveWebhook answers an update Telegram sends the bot. It is not
+// authenticated by token but by the webhook secret. The answer is written
+// as the response; an update that fails is still acknowledged, as Telegram
+// would otherwise hold back later updates to retry it.
+func (h *TelegramHandler) ReceiveWebhook(c *gin.Context) {
+	if !h.bot.Verify(c.GetHeader(telegram.SecretHeader)) {
+		apierror.Respond(c, apierror.New(http.StatusUnauthorized, apierror.CodeUnauthenticated, "Invalid Telegram webhook secret"))
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxTelegramUpdate))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Failed to read request body"))
+		return
+	}
+	var update telegram.Update
+	if err := json.Unmarshal(body, &update); err != nil {
+		apierror.Respond(c, apierror.BadRequest("Request body is not a Telegram update"))
+		return
+	}
+
+	reply, err := h.bot.Handle(c.Request.Context(), update)
+	if err != nil {
+		log.Printf("Failed to handle Telegram update %d: %v", update.UpdateID, err)
+		c.Status(http.StatusOK)
+		return
+	}
+	if reply == nil {
+		c.Status(http.StatusOK)
+		return
+	}
+
+	c.JSON(http.StatusOK, reply)
+}
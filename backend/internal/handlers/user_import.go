@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"rtims-backend/internal/apierror"
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/email"
+	"rtims-backend/internal/middleware"
+	"rtims-backend/internal/models"
+	"rtims-backend/internal/userimport"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// maxImportSize is the largest user import file accepted.
+const maxImportSize = 1 << 20
+
+// invitationTTL is how long an invitation link stays valid. Invitations are
+// password reset tokens, redeemed with POST /auth/reset-password.
+const invitationTTL = 7 * 24 * time.Hour
+
+// UserImportHandler creates users in bulk from CSV files and invites them
+// to set a password.
+type UserImportHandler struct {
+	userService         *database.UserService
+	organizationService *database.OrganizationService
+	auditService        *database.AuditService
+	redisClient         *redis.Client
+	mailer              *email.Mailer
+	publicURL           string
+}
+
+func NewUserImportHandler(db *sql.DB, redisClient *redis.Client, mailer *email.Mailer, publicURL string) *UserImportHandler {
+	return &UserImportHandler{
+		userService:         database.NewUserService(db),
+		organizationService: database.NewOrganizationService(db),
+		auditService:        database.NewAuditService(db),
+		redisClient:         redisClient,
+		mailer:              mailer,
+		publicURL:           publicURL,
+	}
+}
+
+// ImportUsers reads users from the CSV file in multipart form field "file".
+// With dry_run=true it only validates the rows; otherwise it creates the
+// valid ones and emails each an invitation. Results are per row, as JSON or,
+// with format=csv, as a file to download.
+func (h *UserImportHandler) ImportUsers(c *gin.Context) {
+	dryRun := c.Query("dry_run") == "true"
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Import file is required"))
+		return
+	}
+	if fileHeader.Size > maxImportSize {
+		apierror.Respond(c, apierror.BadRequest("Import file must be 1MB or smaller"))
+		return
+	}
+
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Failed to read import file"))
+		return
+	}
+	defer file.Close()
+
+	rows, err := userimport.Parse(file)
+	if err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+
+	emails := make([]string, len(rows))
+	for i, row := range rows {
+		emails[i] = row.Email
+	}
+	taken, err := h.userService.TakenEmails(c.Request.Context(), emails)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to check emails", err))
+		return
+	}
+
+	organizations, err := h.warehouses(c)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get organizations", err))
+		return
+	}
+
+	results := userimport.Validate(rows, taken, organizations)
+	if !dryRun {
+		middleware.MarkAudited(c)
+		for i := range results {
+			h.importUser(c, userID, &results[i])
+		}
+	}
+
+	if c.Query("format") == "csv" {
+		c.Header("Content-Type", "text/csv")
+		c.Header("Content-Disposition", "attachment; filename=user-import-results.csv")
+		c.Status(http.StatusOK)
+		if err := userimport.WriteCSV(c.Writer, results); err != nil {
+			log.Printf("Failed to write user import results: %v", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"dry_run": dryRun,
+		"summary": userimport.Summarize(results),
+		"results": results,
+	})
+}
+
+// warehouses returns the organizations rows may name by slug: any active
+// one for host admins, and only their own for everyone else.
+func (h *UserImportHandler) warehouses(c *gin.Context) (map[string]uuid.UUID, error) {
+	own := middleware.GetOrganization(c)
+
+	var organizations []models.Organization
+	if own == database.DefaultOrganizationID {
+		all, err := h.organizationService.GetOrganizations(c.Request.Context())
+		if err != nil {
+			return nil, err
+		}
+		organizations = all
+	} else {
+		organization, err := h.organizationService.GetOrganization(c.Request.Context(), own)
+		if err != nil {
+			return nil, err
+		}
+		organizations = []models.Organization{*organization}
+	}
+
+	slugs := map[string]uuid.UUID{}
+	for _, organization := range organizations {
+		if organization.IsActive {
+			slugs[organization.Slug] = organization.ID
+		}
+	}
+	return slugs, nil
+}
+
+// importUser creates the user of a valid row and invites them; invalid rows
+// are marked failed.
+func (h *UserImportHandler) importUser(c *gin.Context, changedBy uuid.UUID, result *userimport.Result) {
+	if result.Status != userimport.StatusValid {
+		result.Status = userimport.StatusFailed
+		return
+	}
+
+	ctx := c.Request.Context()
+	if result.OrganizationID != uuid.Nil {
+		ctx = database.WithOrganization(ctx, result.OrganizationID)
+	}
+
+	// Imported users cannot sign in until they accept the invitation and
+	// choose a password
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(uuid.New().String()), bcrypt.DefaultCost)
+	if err != nil {
+		result.Fail("failed to create user")
+		return
+	}
+
+	user := &models.User{
+		ID:        uuid.New(),
+		Name:      result.Name,
+		Email:     result.Email,
+		Password:  string(hashedPassword),
+		Role:      result.Role,
+		IsActive:  true,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := h.userService.CreateUser(ctx, user); err != nil {
+		log.Printf("Failed to import user %s: %v", result.Email, err)
+		result.Fail("failed to create user")
+		return
+	}
+	result.Status = userimport.StatusCreated
+	result.UserID = &user.ID
+
+	auditLog := &models.AuditLog{
+		ID:        uuid.New(),
+		TableName: "users",
+		RecordID:  user.ID,
+		Action:    models.ActionCreate,
+		NewValues: map[string]interface{}{"name": user.Name, "email": user.Email, "role": user.Role, "source": "import"},
+		ChangedBy: changedBy,
+		ChangedAt: time.Now(),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+	}
+	if err := h.auditService.Record(ctx, auditLog); err != nil {
+		log.Printf("Failed to create audit log: %v", err)
+	}
+
+	if err := h.invite(ctx, user); err != nil {
+		log.Printf("Failed to invite imported user %s: %v", user.Email, err)
+		result.Error = "invitation not sent: " + err.Error()
+		return
+	}
+	result.Invited = true
+}
+
+// invite emails user a link to choose their password.
+func (h *UserImportHandler) invite(ctx context.Context, user *models.User) error {
+	if !h.mailer.Enabled() {
+		return fmt.Errorf("email is not configured")
+	}
+
+	token := uuid.New().String()
+	if err := h.redisClient.Set(ctx, "password_reset:"+token, user.Email, invitationTTL).Err(); err != nil {
+		return fmt.Errorf("failed to store invitation: %w", err)
+	}
+
+	body, err := email.Render("invitation.html", map[string]interface{}{
+		"UserName":  user.Name,
+		"Role":      user.Role,
+		"Link":      h.publicURL + "/reset-password?token=" + url.QueryEscape(token),
+		"ExpiresAt": time.Now().Add(invitationTTL),
+	})
+	if err != nil {
+		return err
+	}
+	if err := h.mailer.Send(user.Email, "You have been invited to RTIMS", body); err != nil {
+		return fmt.Errorf("failed to send invitation: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"rtims-backend/internal/apierror"
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// defaultCursorLimit is the page size of v2 lists without a limit.
+const defaultCursorLimit = 20
+
+// V2Handler serves API v2, which changes response shapes v1 clients rely
+// on: products carry their category as an object, and lists page by
+// cursor instead of page number. It shares its services with v1, and
+// errors use the same envelope.
+type V2Handler struct {
+	productService  *database.ProductService
+	categoryService *database.CategoryService
+	recent          *database.RecentProducts
+}
+
+func NewV2Handler(db *sql.DB, cache *database.Cache, recent *database.RecentProducts) *V2Handler {
+	return &V2Handler{
+		productService:  database.NewCachedProductService(db, cache),
+		categoryService: database.NewCategoryService(db),
+		recent:          recent,
+	}
+}
+
+// CursorPage is a v2 list response. NextCursor is null on the last page.
+type CursorPage struct {
+	Data       interface{} `json:"data"`
+	NextCursor *string     `json:"next_cursor"`
+}
+
+func newCursorPage(data interface{}, next *database.Cursor) CursorPage {
+	page := CursorPage{Data: data}
+	if next != nil {
+		encoded := next.Encode()
+		page.NextCursor = &encoded
+	}
+	return page
+}
+
+// bindCursor reads the cursor and limit of a v2 list request.
+func bindCursor(c *gin.Context) (*database.Cursor, int, error) {
+	var query models.CursorQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		return nil, 0, apierror.Invalid(err)
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultCursorLimit
+	}
+	after, err := database.DecodeCursor(query.Cursor)
+	if err != nil {
+		return nil, 0, apierror.From(err)
+	}
+	return after, query.Limit, nil
+}
+
+// withCategories gives products their category objects.
+func (h *V2Handler) withCategories(ctx context.Context, products []models.Product) ([]models.ProductV2, error) {
+	categories, err := h.categoryService.GetCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]uuid.UUID, len(categories))
+	for _, category := range categories {
+		ids[category.Name] = category.ID
+	}
+
+	result := make([]models.ProductV2, 0, len(products))
+	for _, product := range products {
+		ref := models.CategoryRef{Name: product.Category}
+		if id, ok := ids[product.Category]; ok {
+			ref.ID = &id
+		}
+		result = append(result, models.ProductV2{Product: product, Category: ref})
+	}
+	return result, nil
+}
+
+func (h *V2Handler) GetProducts(c *gin.Context) {
+	var filter models.ProductFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+	after, limit, err := bindCursor(c)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	products, next, err := h.productService.GetProductsAfter(c.Request.Context(), filter, after, limit)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get products", err))
+		return
+	}
+	withCategories, err := h.withCategories(c.Request.Context(), products)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get categories", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, newCursorPage(withCategories, next))
+}
+
+func (h *V2Handler) GetProduct(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid product ID"))
+		return
+	}
+
+	product, err := h.productService.GetProduct(c.Request.Context(), id)
+	if err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrProductNotFound, err))
+		return
+	}
+	recordView(c, h.recent, id)
+	withCategory, err := h.withCategories(c.Request.Context(), []models.Product{*product})
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get categories", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, withCategory[0])
+}
+
+func (h *V2Handler) GetStockMovements(c *gin.Context) {
+	var filter models.StockMovementFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+	after, limit, err := bindCursor(c)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	movements, next, err := h.productService.GetStockMovementsAfter(c.Request.Context(), filter, after, limit)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get stock movements", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, newCursorPage(movements, next))
+}
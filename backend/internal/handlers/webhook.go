@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"rtims-backend/internal/apierror"
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/middleware"
+	"rtims-backend/internal/models"
+	"rtims-backend/internal/webhook"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// WebhookHandler manages the webhooks of the admin's organization.
+type WebhookHandler struct {
+	webhookService *database.WebhookService
+	auditService   *database.AuditService
+	deliverer      *webhook.Deliverer
+}
+
+func NewWebhookHandler(db *sql.DB, deliverer *webhook.Deliverer) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: database.NewWebhookService(db),
+		auditService:   database.NewAuditService(db),
+		deliverer:      deliverer,
+	}
+}
+
+func (h *WebhookHandler) GetWebhooks(c *gin.Context) {
+	webhooks, err := h.webhookService.GetWebhooks(c.Request.Context())
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get webhooks", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, webhooks)
+}
+
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid webhook ID"))
+		return
+	}
+
+	webhook, err := h.webhookService.GetWebhook(c.Request.Context(), id)
+	if err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrWebhookNotFound, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// CreateWebhook creates a webhook. Its secret is only returned here and
+// when it is rotated.
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req models.CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to create webhook", err))
+		return
+	}
+
+	now := time.Now()
+	created := &models.Webhook{
+		ID:          uuid.New(),
+		URL:         req.URL,
+		Description: req.Description,
+		Secret:      secret,
+		Events:      req.Events,
+		IsActive:    true,
+		CreatedBy:   &userID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := h.webhookService.CreateWebhook(c.Request.Context(), created); err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to create webhook", err))
+		return
+	}
+
+	h.audit(c, userID, created.ID, models.ActionCreate, nil, webhookValues(created))
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// UpdateWebhook changes a webhook, returning its new secret if it was
+// rotated.
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid webhook ID"))
+		return
+	}
+
+	var req models.UpdateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	old, err := h.webhookService.GetWebhook(c.Request.Context(), id)
+	if err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrWebhookNotFound, err))
+		return
+	}
+
+	var secret string
+	if req.RotateSecret {
+		if secret, err = webhook.NewSecret(); err != nil {
+			apierror.Respond(c, apierror.Failed("Failed to update webhook", err))
+			return
+		}
+	}
+
+	if err := h.webhookService.UpdateWebhook(c.Request.Context(), id, req, secret); err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrWebhookNotFound, err))
+		return
+	}
+
+	updated, err := h.webhookService.GetWebhook(c.Request.Context(), id)
+	if err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrWebhookNotFound, err))
+		return
+	}
+
+	newValues := webhookValues(updated)
+	if req.RotateSecret {
+		newValues["secret_rotated"] = true
+	}
+	h.audit(c, userID, id, models.ActionUpdate, webhookValues(old), newValues)
+
+	updated.Secret = secret
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteWebhook deletes a webhook and its delivery log.
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid webhook ID"))
+		return
+	}
+
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	old, err := h.webhookService.GetWebhook(c.Request.Context(), id)
+	if err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrWebhookNotFound, err))
+		return
+	}
+
+	if err := h.webhookService.DeleteWebhook(c.Request.Context(), id); err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrWebhookNotFound, err))
+		return
+	}
+
+	h.audit(c, userID, id, models.ActionDelete, webhookValues(old), nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// GetWebhookDeliveries returns a webhook's delivery log, newest first.
+func (h *WebhookHandler) GetWebhookDeliveries(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid webhook ID"))
+		return
+	}
+
+	var filter models.WebhookDeliveryFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+	if filter.Limit > 200 {
+		filter.Limit = 200
+	}
+
+	// Only webhooks of the admin's organization can be looked into
+	if _, err := h.webhookService.GetWebhook(c.Request.Context(), id); err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrWebhookNotFound, err))
+		return
+	}
+
+	deliveries, err := h.webhookService.GetWebhookDeliveries(c.Request.Context(), id, filter.Status, filter.Limit)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to get webhook deliveries", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// TestWebhook sends a ping to a webhook right away, whether or not it is
+// active, and returns the delivery with the receiver's response. The ping
+// is not retried.
+func (h *WebhookHandler) TestWebhook(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid webhook ID"))
+		return
+	}
+
+	target, err := h.webhookService.GetWebhook(c.Request.Context(), id)
+	if err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrWebhookNotFound, err))
+		return
+	}
+	secret, err := h.webhookService.GetWebhookSecret(c.Request.Context(), id)
+	if err != nil {
+		apierror.Respond(c, apierror.Missing(apierror.ErrWebhookNotFound, err))
+		return
+	}
+
+	now := time.Now()
+	payload := models.WebhookPayload{
+		ID:             uuid.New(),
+		Type:           models.WebhookPing,
+		OrganizationID: target.OrganizationID,
+		CreatedAt:      now,
+		Data:           gin.H{"webhook_id": target.ID, "events": target.Events},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to test webhook", err))
+		return
+	}
+
+	delivery := &models.WebhookDelivery{
+		ID:        uuid.New(),
+		WebhookID: target.ID,
+		EventID:   payload.ID,
+		EventType: models.WebhookPing,
+		Payload:   body,
+		Status:    models.DeliveryPending,
+		CreatedAt: now,
+	}
+	if err := h.webhookService.CreateWebhookDelivery(c.Request.Context(), delivery); err != nil {
+		apierror.Respond(c, apierror.Failed("Failed to test webhook", err))
+		return
+	}
+
+	attempt := h.deliverer.Send(c.Request.Context(), target.URL, secret, delivery)
+	delivery.Status = models.DeliveryFailed
+	if attempt.Succeeded() {
+		delivery.Status = models.DeliverySucceeded
+	}
+	if err := h.webhookService.RecordWebhookAttempt(c.Request.Context(), delivery.ID, attempt, delivery.Status, nil); err != nil {
+		log.Printf("Failed to record webhook delivery %s: %v", delivery.ID, err)
+	}
+
+	attemptedAt := time.Now()
+	delivery.Attempts = 1
+	delivery.ResponseStatus = attempt.ResponseStatus
+	delivery.Error = attempt.Error
+	delivery.DurationMs = attempt.Duration.Milliseconds()
+	delivery.LastAttemptAt = &attemptedAt
+
+	c.JSON(http.StatusOK, delivery)
+}
+
+func (h *WebhookHandler) audit(c *gin.Context, userID, id uuid.UUID, action models.AuditAction, oldValues, newValues map[string]interface{}) {
+	auditLog := &models.AuditLog{
+		ID:        uuid.New(),
+		TableName: "webhooks",
+		RecordID:  id,
+		Action:    action,
+		OldValues: oldValues,
+		NewValues: newValues,
+		ChangedBy: userID,
+		ChangedAt: time.Now(),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+	}
+	middleware.MarkAudited(c)
+	if err := h.auditService.Record(c.Request.Context(), auditLog); err != nil {
+		log.Printf("Failed to create audit log: %v", err)
+	}
+}
+
+// webhookValues are the fields of a webhook recorded in the audit trail.
+// The secret is left out.
+func webhookValues(w *models.Webhook) map[string]interface{} {
+	return map[string]interface{}{
+		"url":         w.URL,
+		"description": w.Description,
+		"events":      w.Events,
+		"is_active":   w.IsActive,
+	}
+}
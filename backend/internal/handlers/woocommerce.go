@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"rtims-backend/internal/apierror"
+	"rtims-backend/internal/middleware"
+	"rtims-backend/internal/models"
+	"rtims-backend/internal/woocommerce"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// WooCommerceHandler reports the sync state of the WooCommerce stores
+// configured in settings and resolves the conflicts it finds.
+type WooCommerceHandler struct {
+	syncer *woocommerce.Syncer
+}
+
+func NewWooCommerceHandler(syncer *woocommerce.Syncer) *WooCommerceHandler {
+	return &WooCommerceHandler{syncer: syncer}
+}
+
+// wooCommerceError maps the errors of the WooCommerce integration to
+// responses, and others as apierror.Failed.
+func wooCommerceError(message string, err error) *apierror.Error {
+	var apiErr *woocommerce.APIError
+	var urlErr *url.Error
+	switch {
+	case errors.Is(err, woocommerce.ErrUnknownStore):
+		return apierror.ErrWooStoreNotFound.Wrap(err)
+	case errors.Is(err, woocommerce.ErrProductNotFound):
+		return apierror.ErrWooProductNotFound.Wrap(err)
+	case errors.Is(err, woocommerce.ErrConflictResolved):
+		return apierror.ErrWooConflictResolved.Wrap(err)
+	case errors.Is(err, woocommerce.ErrStockNotTracked):
+		return apierror.ErrWooStockNotTracked.Wrap(err)
+	case errors.Is(err, woocommerce.ErrStoreAccount):
+		return apierror.ErrWooAccount.Wrap(err)
+	case errors.As(err, &apiErr), errors.As(err, &urlErr):
+		return apierror.ErrWooUnavailable.Wrap(err)
+	}
+	return apierror.Failed(message, err)
+}
+
+// GetStatus returns the configured stores with the state of their sync,
+// and the conflicts waiting to be resolved.
+func (h *WooCommerceHandler) GetStatus(c *gin.Context) {
+	status, err := h.syncer.Status(c.Request.Context())
+	if err != nil {
+		apierror.Respond(c, wooCommerceError("Failed to get WooCommerce sync status", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// Reconcile reconciles a store now rather than at its next scheduled run.
+func (h *WooCommerceHandler) Reconcile(c *gin.Context) {
+	run, err := h.syncer.ReconcileStore(c.Request.Context(), c.Param("store"))
+	if err != nil {
+		apierror.Respond(c, wooCommerceError("Failed to reconcile WooCommerce store", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
+
+// ResolveConflict gives both sides of a conflict the current stock of the
+// side picked to keep.
+func (h *WooCommerceHandler) ResolveConflict(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest("Invalid conflict ID"))
+		return
+	}
+
+	var req models.ResolveWooCommerceConflictRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+
+	userID, _, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	conflict, err := h.syncer.ResolveConflict(c.Request.Context(), id, req.Keep, userID)
+	if err != nil {
+		mapped := wooCommerceError("Failed to resolve WooCommerce conflict", err)
+		if mapped.Code == apierror.CodeNotFound {
+			mapped = apierror.ErrWooConflictNotFound.Wrap(err)
+		}
+		apierror.Respond(c, mapped)
+		return
+	}
+
+	c.JSON(http.StatusOK, conflict)
+}
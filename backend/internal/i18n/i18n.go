@@ -0,0 +1,97 @@
+// Package i18n translates the messages the API sends to people: error
+// messages and notifications. English is the source language, so messages
+// are looked up by their English text, and locales/<language>.json maps
+// that text to a translation. Messages without one are sent in English.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLanguage is the language messages are written in.
+const DefaultLanguage = "en"
+
+//go:embed locales/*.json
+var bundleFS embed.FS
+
+// bundles maps a language code to its translations.
+var bundles = loadBundles()
+
+func loadBundles() map[string]map[string]string {
+	loaded := map[string]map[string]string{DefaultLanguage: {}}
+	files, err := bundleFS.ReadDir("locales")
+	if err != nil {
+		panic(fmt.Sprintf("i18n: %v", err))
+	}
+	for _, file := range files {
+		data, err := bundleFS.ReadFile(path.Join("locales", file.Name()))
+		if err != nil {
+			panic(fmt.Sprintf("i18n: %v", err))
+		}
+		messages := map[string]string{}
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Sprintf("i18n: invalid bundle %s: %v", file.Name(), err))
+		}
+		loaded[strings.TrimSuffix(file.Name(), ".json")] = messages
+	}
+	return loaded
+}
+
+// Lookup returns the supported language for a code such as "id" or
+// "id-ID".
+func Lookup(code string) (string, bool) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if i := strings.IndexAny(code, "-_"); i >= 0 {
+		code = code[:i]
+	}
+	_, ok := bundles[code]
+	return code, ok
+}
+
+// Languages lists the supported language codes.
+func Languages() []string {
+	languages := make([]string, 0, len(bundles))
+	for language := range bundles {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// T translates message, written in English, into language. Unsupported
+// languages and messages without a translation get message back.
+func T(language, message string) string {
+	language, _ = Lookup(language)
+	if translated, ok := bundles[language][message]; ok {
+		return translated
+	}
+	return message
+}
+
+// FromAcceptLanguage picks the supported language the client prefers most
+// from an Accept-Language header such as "id-ID,id;q=0.9,en;q=0.8",
+// falling back to DefaultLanguage.
+func FromAcceptLanguage(header string) string {
+	best, bestQuality := DefaultLanguage, 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		if language, ok := Lookup(tag); ok && quality > bestQuality {
+			best, bestQuality = language, quality
+		}
+	}
+	return best
+}
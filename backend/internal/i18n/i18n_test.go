@@ -0,0 +1,48 @@
+package i18n
+
+import "testing"
+
+func TestT(t *testing.T) {
+	if got := T("id", "Product not found"); got != "Produk tidak ditemukan" {
+		t.Errorf("expected the Indonesian translation, got %q", got)
+	}
+	if got := T("id-ID", "Product not found"); got != "Produk tidak ditemukan" {
+		t.Errorf("expected id-ID to use the id bundle, got %q", got)
+	}
+	if got := T("en", "Product not found"); got != "Product not found" {
+		t.Errorf("expected English to be unchanged, got %q", got)
+	}
+	if got := T("xx", "Product not found"); got != "Product not found" {
+		t.Errorf("expected an unknown language to fall back to English, got %q", got)
+	}
+	if got := T("id", "Unknown or inactive user: 42"); got != "Unknown or inactive user: 42" {
+		t.Errorf("expected an untranslated message to be unchanged, got %q", got)
+	}
+}
+
+func TestFromAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected string
+	}{
+		{"", "en"},
+		{"id-ID,id;q=0.9,en;q=0.8", "id"},
+		{"en-US,en;q=0.9,id;q=0.8", "en"},
+		{"fr-FR,id;q=0.5", "id"},
+		{"fr-FR,de;q=0.5", "en"},
+		{"en;q=0.2, id;q=0.7", "id"},
+		{"id;q=bad", "en"},
+	}
+	for _, tt := range tests {
+		if got := FromAcceptLanguage(tt.header); got != tt.expected {
+			t.Errorf("FromAcceptLanguage(%q): expected %q, got %q", tt.header, tt.expected, got)
+		}
+	}
+}
+
+func TestLanguages(t *testing.T) {
+	languages := Languages()
+	if len(languages) < 2 || languages[0] != "en" || languages[1] != "id" {
+		t.Errorf("unexpected languages %v", languages)
+	}
+}
@@ -0,0 +1,355 @@
+package ingest
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"time"
+
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/i18n"
+	"rtims-backend/internal/models"
+	"rtims-backend/internal/notify"
+
+	"github.com/google/uuid"
+)
+
+// fileRetention is how long the records of processed files are kept.
+const fileRetention = 180 * 24 * time.Hour
+
+// Importer picks up the files of the configured suppliers and applies them.
+type Importer struct {
+	ingestService       *database.IngestService
+	settingsService     *database.SettingsService
+	userService         *database.UserService
+	organizationService *database.OrganizationService
+	productService      *database.ProductService
+	notificationService *database.NotificationService
+	dispatcher          *notify.Dispatcher
+}
+
+func NewImporter(db *sql.DB, cache *database.Cache, dispatcher *notify.Dispatcher) *Importer {
+	return &Importer{
+		ingestService:       database.NewIngestService(db),
+		settingsService:     database.NewSettingsService(db),
+		userService:         database.NewUserService(db),
+		organizationService: database.NewOrganizationService(db),
+		productService:      database.NewCachedProductService(db, cache),
+		notificationService: database.NewNotificationService(db),
+		dispatcher:          dispatcher,
+	}
+}
+
+// Suppliers returns the configured suppliers.
+func (s *Importer) Suppliers() (map[string]Supplier, error) {
+	settings, err := s.settingsService.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	value, _ := settings[SettingSuppliers].(string)
+	return ParseSuppliers(value)
+}
+
+// Supplier returns the profile of the supplier called name.
+func (s *Importer) Supplier(name string) (Supplier, error) {
+	suppliers, err := s.Suppliers()
+	if err != nil {
+		return Supplier{}, err
+	}
+	supplier, ok := suppliers[name]
+	if !ok {
+		return Supplier{}, ErrUnknownSupplier
+	}
+	return supplier, nil
+}
+
+// Status returns the configured suppliers with their last file.
+func (s *Importer) Status(ctx context.Context) ([]models.IngestSupplier, error) {
+	suppliers, err := s.Suppliers()
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]models.IngestSupplier, 0, len(suppliers))
+	for _, supplier := range suppliers {
+		state := models.IngestSupplier{
+			Name:      supplier.Name,
+			UserEmail: supplier.UserEmail,
+			Source:    supplier.Source,
+			Location:  Location(supplier),
+		}
+		if state.LastFile, err = s.ingestService.GetLastIngestFile(ctx, supplier.Name); err != nil {
+			return nil, err
+		}
+		status = append(status, state)
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].Name < status[j].Name })
+	return status, nil
+}
+
+// Run ingests the new files of every configured supplier and purges old
+// records. It is the scheduled job; a supplier whose drop folder cannot be
+// read is logged without stopping the others.
+func (s *Importer) Run() error {
+	suppliers, err := s.Suppliers()
+	if err != nil {
+		return fmt.Errorf("failed to read ingest suppliers: %w", err)
+	}
+
+	names := make([]string, 0, len(suppliers))
+	for name := range suppliers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	failed := 0
+	for _, name := range names {
+		files, err := s.run(context.Background(), suppliers[name])
+		if err != nil {
+			log.Printf("Ingesting files of %s failed: %v", name, err)
+			failed++
+			continue
+		}
+		for _, file := range files {
+			log.Printf("Ingested %s of %s: %s, %d applied, %d unchanged, %d failed",
+				file.Filename, name, file.Status, file.Applied, file.Unchanged, file.Failed)
+		}
+	}
+
+	if _, err := s.ingestService.PurgeIngestFiles(context.Background(), time.Now().Add(-fileRetention)); err != nil {
+		log.Printf("Failed to purge ingest files: %v", err)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d suppliers could not be ingested", failed, len(names))
+	}
+	return nil
+}
+
+// RunSupplier ingests the new files of the supplier called name now.
+func (s *Importer) RunSupplier(ctx context.Context, name string) ([]models.IngestFile, error) {
+	supplier, err := s.Supplier(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.run(ctx, supplier)
+}
+
+// account returns the user a supplier acts as, and ctx scoped to the
+// user's organization, which must be active.
+func (s *Importer) account(ctx context.Context, supplier Supplier) (context.Context, *models.User, error) {
+	user, err := s.userService.GetUserByEmail(ctx, supplier.UserEmail)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, fmt.Errorf("%w: no account for %s", ErrSupplierAccount, supplier.UserEmail)
+		}
+		return nil, nil, err
+	}
+	if !user.IsActive {
+		return nil, nil, fmt.Errorf("%w: %s is deactivated", ErrSupplierAccount, supplier.UserEmail)
+	}
+
+	organizationID := user.OrganizationID
+	if organizationID == uuid.Nil {
+		organizationID = database.DefaultOrganizationID
+	}
+	organization, err := s.organizationService.GetOrganization(ctx, organizationID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !organization.IsActive {
+		return nil, nil, fmt.Errorf("%w: organization %s is deactivated", ErrSupplierAccount, organization.Name)
+	}
+	return database.WithOrganization(ctx, organizationID), user, nil
+}
+
+// run processes every file in the supplier's drop folder matching its
+// pattern, oldest name first. A file already processed with the same
+// content is archived again without being applied twice.
+func (s *Importer) run(ctx context.Context, supplier Supplier) ([]models.IngestFile, error) {
+	ctx, user, err := s.account(ctx, supplier)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := OpenSource(ctx, supplier)
+	if err != nil {
+		return nil, err
+	}
+	defer source.Close()
+
+	names, err := source.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	files := []models.IngestFile{}
+	for _, name := range names {
+		if ok, _ := path.Match(supplier.Pattern, name); !ok {
+			continue
+		}
+		data, err := source.Read(ctx, name)
+		if err != nil {
+			return files, err
+		}
+		sum := sha256.Sum256(data)
+		checksum := hex.EncodeToString(sum[:])
+
+		processed, err := s.ingestService.IsIngestFileProcessed(ctx, supplier.Name, name, checksum)
+		if err != nil {
+			return files, err
+		}
+		if processed {
+			// It was applied, but moving it out of the folder failed
+			if err := source.Archive(ctx, name, folderProcessed, archiveName(name)); err != nil {
+				return files, err
+			}
+			continue
+		}
+
+		organizationID, _ := database.OrganizationFrom(ctx)
+		file := &models.IngestFile{Supplier: supplier.Name, Filename: name, Checksum: checksum, OrganizationID: &organizationID}
+		s.apply(ctx, supplier, user.ID, data, file)
+		if err := s.ingestService.CreateIngestFile(ctx, file); err != nil {
+			return files, err
+		}
+		s.notify(ctx, file)
+		files = append(files, *file)
+
+		folder := folderProcessed
+		if file.Status == models.IngestFailed {
+			folder = folderFailed
+		}
+		if err := source.Archive(ctx, name, folder, archiveName(name)); err != nil {
+			return files, fmt.Errorf("failed to archive %s: %w", name, err)
+		}
+	}
+	return files, nil
+}
+
+// archiveName is the name a file is archived as, prefixed with the time so
+// a supplier can send the same name again.
+func archiveName(name string) string {
+	return time.Now().UTC().Format("20060102T150405Z") + "_" + name
+}
+
+// apply applies the rows of a file, recording the outcome of each on it.
+func (s *Importer) apply(ctx context.Context, supplier Supplier, userID uuid.UUID, data []byte, file *models.IngestFile) {
+	rows, err := Parse(data, supplier)
+	if err != nil {
+		file.Status, file.Error = models.IngestFailed, err.Error()
+		return
+	}
+	skus := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if row.SKU != "" {
+			skus = append(skus, row.SKU)
+		}
+	}
+	products, err := s.productService.GetProductsBySKU(ctx, skus)
+	if err != nil {
+		file.Status, file.Error = models.IngestFailed, err.Error()
+		return
+	}
+
+	notes := fmt.Sprintf("Supplier file %s/%s", supplier.Name, file.Filename)
+	for _, change := range Plan(supplier, rows, products) {
+		result := change.Result
+		if result.Status == models.IngestRowApplied {
+			if err := s.applyChange(ctx, supplier, userID, notes, change); err != nil {
+				result.Status, result.Error = models.IngestRowFailed, err.Error()
+			} else {
+				if change.Stock != 0 {
+					result.Change = &change.Stock
+				}
+				result.Price = change.Price
+			}
+		}
+
+		switch result.Status {
+		case models.IngestRowApplied:
+			file.Applied++
+		case models.IngestRowUnchanged:
+			file.Unchanged++
+		default:
+			file.Failed++
+		}
+		file.Results = append(file.Results, result)
+	}
+
+	file.Rows = len(rows)
+	switch {
+	case file.Failed == 0:
+		file.Status = models.IngestApplied
+	case file.Applied > 0 || file.Unchanged > 0:
+		file.Status = models.IngestPartial
+	default:
+		file.Status = models.IngestFailed
+	}
+}
+
+func (s *Importer) applyChange(ctx context.Context, supplier Supplier, userID uuid.UUID, notes string, change Change) error {
+	if change.Stock != 0 {
+		_, err := s.productService.UpdateProductStock(ctx, change.ProductID, change.Stock, supplier.reason(), userID, notes)
+		if errors.Is(err, database.ErrInsufficientStock) {
+			return errors.New("stock would go below zero")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if change.Price != nil {
+		if err := s.productService.UpdateProduct(ctx, change.ProductID, map[string]interface{}{"price": *change.Price}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notify tells the admins of the file's organization how it went.
+func (s *Importer) notify(ctx context.Context, file *models.IngestFile) {
+	admins, err := s.userService.GetActiveUsersByRole(ctx, models.RoleAdmin)
+	if err != nil {
+		log.Printf("Failed to get admins to notify of %s: %v", file.Filename, err)
+		return
+	}
+
+	for _, admin := range admins {
+		var message string
+		switch file.Status {
+		case models.IngestApplied:
+			message = fmt.Sprintf(i18n.T(admin.Locale, "Supplier file %s from %s applied: %d rows changed, %d unchanged"),
+				file.Filename, file.Supplier, file.Applied, file.Unchanged)
+		case models.IngestPartial:
+			message = fmt.Sprintf(i18n.T(admin.Locale, "Supplier file %s from %s partly applied: %d rows changed, %d failed"),
+				file.Filename, file.Supplier, file.Applied, file.Failed)
+		default:
+			message = fmt.Sprintf(i18n.T(admin.Locale, "Supplier file %s from %s failed and was moved to the failed folder"),
+				file.Filename, file.Supplier)
+		}
+
+		notification := &models.Notification{
+			ID:        uuid.New(),
+			UserID:    admin.ID,
+			Message:   message,
+			Type:      models.NotificationSystem,
+			IsRead:    false,
+			CreatedAt: time.Now(),
+			Metadata: &models.NotificationMetadata{
+				EntityType: "ingest_file",
+				EntityID:   &file.ID,
+			},
+		}
+		if err := s.notificationService.CreateNotification(notification); err != nil {
+			log.Printf("Failed to notify of supplier file %s: %v", file.Filename, err)
+			continue
+		}
+		s.dispatcher.Deliver(notification)
+	}
+}
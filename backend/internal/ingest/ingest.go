@@ -0,0 +1,330 @@
+// Package ingest imports the stock files suppliers drop in SFTP or S3
+// folders. Each supplier profile in settings says where its files are,
+// which of their columns hold the SKU, quantity and price, and how the
+// quantity is applied. A scheduled job picks up new files, applies them as
+// stock movements and price updates, moves them to a processed or failed
+// folder, and notifies the organization's admins of the outcome.
+package ingest
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/mail"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// SettingSuppliers holds a JSON object of the suppliers whose files are
+// ingested, by a name of lowercase letters, digits, hyphens and
+// underscores, e.g. {"acme": {"user_email": "ingest@example.com",
+// "source": "sftp", "options": {"host": "files.acme.com", …},
+// "columns": {"sku": "Item Code", "quantity": "Qty"}}}. Each supplier
+// updates the products of the organization of its user, who the
+// movements it brings in are recorded by.
+const SettingSuppliers = "ingest_suppliers"
+
+// MaxRows is the most rows a file may have.
+const MaxRows = 10000
+
+// ErrUnknownSupplier is returned for a supplier that is not configured.
+var ErrUnknownSupplier = errors.New("supplier is not configured")
+
+// ErrSupplierAccount is returned when the user a supplier acts as cannot be
+// used: there is no such account, or it or its organization is deactivated.
+var ErrSupplierAccount = errors.New("supplier account is unavailable")
+
+var supplierName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,99}$`)
+
+// QuantityMode is how the quantity column of a file is applied.
+type QuantityMode string
+
+const (
+	// QuantityChange moves stock by the quantity, recorded with the
+	// supplier's reason.
+	QuantityChange QuantityMode = "change"
+	// QuantityLevel sets stock to the quantity, recording the difference
+	// as an adjustment.
+	QuantityLevel QuantityMode = "level"
+)
+
+// Columns name the columns of a supplier's files, matched regardless of
+// case. At least one of Quantity and Price is needed.
+type Columns struct {
+	SKU      string `json:"sku"`
+	Quantity string `json:"quantity,omitempty"`
+	Price    string `json:"price,omitempty"`
+}
+
+// Supplier is the profile of one supplier. Files are the ones in the drop
+// folder matching Pattern, "*.csv" unless set, separated by Delimiter, a
+// comma unless set. Movements of QuantityChange files are recorded with
+// Reason, purchase unless set.
+type Supplier struct {
+	Name      string                `json:"-"`
+	UserEmail string                `json:"user_email"`
+	Source    string                `json:"source"`
+	Options   map[string]string     `json:"options"`
+	Pattern   string                `json:"pattern,omitempty"`
+	Delimiter string                `json:"delimiter,omitempty"`
+	Columns   Columns               `json:"columns"`
+	Quantity  QuantityMode          `json:"quantity,omitempty"`
+	Reason    models.MovementReason `json:"reason,omitempty"`
+}
+
+// ParseSuppliers parses the SettingSuppliers value, checking that every
+// profile is complete and that its source accepts its options.
+func ParseSuppliers(value string) (map[string]Supplier, error) {
+	suppliers := map[string]Supplier{}
+	if strings.TrimSpace(value) == "" {
+		return suppliers, nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&suppliers); err != nil {
+		return nil, errors.New("must be an object mapping supplier names to supplier profiles")
+	}
+
+	names := make([]string, 0, len(suppliers))
+	for name := range suppliers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		supplier := suppliers[name]
+		supplier.Name = name
+		supplier.UserEmail = strings.ToLower(supplier.UserEmail)
+		if supplier.Pattern == "" {
+			supplier.Pattern = "*.csv"
+		}
+		if supplier.Delimiter == "" {
+			supplier.Delimiter = ","
+		}
+		if supplier.Quantity == "" {
+			supplier.Quantity = QuantityChange
+		}
+		if supplier.Reason == "" {
+			supplier.Reason = models.ReasonPurchase
+		}
+		if !supplierName.MatchString(name) {
+			return nil, fmt.Errorf("%q is not a supplier name of lowercase letters, digits, hyphens and underscores", name)
+		}
+		if err := supplier.check(); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		suppliers[name] = supplier
+	}
+	return suppliers, nil
+}
+
+func (s Supplier) check() error {
+	if _, err := mail.ParseAddress(s.UserEmail); err != nil {
+		return errors.New("needs the user_email of the account it acts as")
+	}
+	if _, err := path.Match(s.Pattern, ""); err != nil {
+		return errors.New("pattern must be a valid file name pattern such as *.csv")
+	}
+	if r, size := utf8.DecodeRuneInString(s.Delimiter); size != len(s.Delimiter) || r == '"' || r == '\r' || r == '\n' {
+		return errors.New("delimiter must be a single character")
+	}
+	if s.Columns.SKU == "" {
+		return errors.New("columns needs the sku column")
+	}
+	if s.Columns.Quantity == "" && s.Columns.Price == "" {
+		return errors.New("columns needs a quantity or price column")
+	}
+	if s.Quantity != QuantityChange && s.Quantity != QuantityLevel {
+		return errors.New("quantity must be change or level")
+	}
+	switch s.Reason {
+	case models.ReasonPurchase, models.ReasonSale, models.ReasonAdjustment, models.ReasonReturn, models.ReasonDamage:
+	default:
+		return errors.New("reason must be purchase, sale, adjustment, return or damage")
+	}
+	if err := validateSource(s.Source, s.Options); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Row is a row read from a supplier file. Quantity and Price are nil when
+// the file has no such column or the row leaves it empty; Error says what
+// is wrong with the row, if anything.
+type Row struct {
+	Line     int
+	SKU      string
+	Quantity *int
+	Price    *float64
+	Error    string
+}
+
+// Parse reads the rows of a supplier file. The first line names the
+// columns; those of the supplier's profile must be among them. Errors are
+// about the file as a whole; problems with single rows are on the rows.
+func Parse(data []byte, supplier Supplier) ([]Row, error) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma, _ = utf8.DecodeRuneInString(supplier.Delimiter)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	index := map[string]int{}
+	for i, column := range header {
+		index[strings.ToLower(strings.TrimSpace(column))] = i
+	}
+	column := func(name string) (int, error) {
+		if name == "" {
+			return -1, nil
+		}
+		i, ok := index[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return -1, fmt.Errorf("file has no %q column", name)
+		}
+		return i, nil
+	}
+	skuColumn, err := column(supplier.Columns.SKU)
+	if err != nil {
+		return nil, err
+	}
+	quantityColumn, err := column(supplier.Columns.Quantity)
+	if err != nil {
+		return nil, err
+	}
+	priceColumn, err := column(supplier.Columns.Price)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []Row
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read line %d: %w", line, err)
+		}
+		if len(rows) == MaxRows {
+			return nil, fmt.Errorf("file has more than %d rows", MaxRows)
+		}
+		field := func(i int) string {
+			if i < 0 || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		row := Row{Line: line, SKU: field(skuColumn)}
+		var problems []string
+		if row.SKU == "" {
+			problems = append(problems, "sku is empty")
+		}
+		if value := field(quantityColumn); value != "" {
+			quantity, err := strconv.Atoi(value)
+			switch {
+			case err != nil:
+				problems = append(problems, "quantity must be a whole number")
+			case supplier.Quantity == QuantityLevel && quantity < 0:
+				problems = append(problems, "quantity must be at least 0")
+			default:
+				row.Quantity = &quantity
+			}
+		}
+		if value := field(priceColumn); value != "" {
+			price, err := strconv.ParseFloat(value, 64)
+			if err != nil || price < 0 || math.IsInf(price, 0) || math.IsNaN(price) {
+				problems = append(problems, "price must be a number of at least 0")
+			} else {
+				price = math.Round(price*100) / 100
+				row.Price = &price
+			}
+		}
+		row.Error = strings.Join(problems, "; ")
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// Change is what applying a row does to its product: move its stock by
+// Stock, unless 0, and set its price to Price, unless nil.
+type Change struct {
+	ProductID uuid.UUID
+	Stock     int
+	Price     *float64
+	Result    models.IngestRowResult
+}
+
+// Plan works out the change each row makes to the products, by SKU. Rows
+// are applied in order, so a SKU that appears twice is planned against
+// the stock and price its first row leaves. Rows with errors or unknown
+// SKUs are failed and rows that change nothing unchanged.
+func Plan(supplier Supplier, rows []Row, products map[string]models.Product) []Change {
+	current := make(map[string]models.Product, len(products))
+	for sku, product := range products {
+		current[sku] = product
+	}
+
+	changes := make([]Change, 0, len(rows))
+	for _, row := range rows {
+		change := Change{Result: models.IngestRowResult{Line: row.Line, SKU: row.SKU}}
+		product, ok := current[row.SKU]
+		switch {
+		case row.Error != "":
+			change.Result.Status, change.Result.Error = models.IngestRowFailed, row.Error
+		case !ok:
+			change.Result.Status, change.Result.Error = models.IngestRowFailed, "no product has this SKU"
+		default:
+			change.ProductID = product.ID
+			if row.Quantity != nil {
+				change.Stock = *row.Quantity
+				if supplier.Quantity == QuantityLevel {
+					change.Stock = *row.Quantity - product.Stock
+				}
+			}
+			if row.Price != nil && math.Abs(*row.Price-product.Price) >= 0.005 {
+				change.Price = row.Price
+			}
+			change.Result.Status = models.IngestRowUnchanged
+			if change.Stock != 0 || change.Price != nil {
+				change.Result.Status = models.IngestRowApplied
+			}
+			product.Stock += change.Stock
+			if change.Price != nil {
+				product.Price = *change.Price
+			}
+			current[row.SKU] = product
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// reason returns the reason movements of the supplier's files are
+// recorded with.
+func (s Supplier) reason() models.MovementReason {
+	if s.Quantity == QuantityLevel {
+		return models.ReasonAdjustment
+	}
+	return s.Reason
+}
@@ -0,0 +1,173 @@
+package ingest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const sftpOptions = `"options": {"host": "files.acme.com", "username": "rtims", "password": "p", "host_key": "SHA256:x"}`
+
+func TestParseSuppliers(t *testing.T) {
+	suppliers, err := ParseSuppliers(`{"acme": {"user_email": "Ingest@Example.com", "source": "sftp", ` + sftpOptions + `,
+		"columns": {"sku": "Item Code", "quantity": "Qty"}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acme := suppliers["acme"]
+	if acme.Name != "acme" || acme.UserEmail != "ingest@example.com" || acme.Pattern != "*.csv" || acme.Delimiter != "," ||
+		acme.Quantity != QuantityChange || acme.Reason != models.ReasonPurchase {
+		t.Errorf("unexpected supplier %+v", acme)
+	}
+	if location := Location(acme); location != "sftp://files.acme.com/." {
+		t.Errorf("unexpected location %s", location)
+	}
+
+	if suppliers, err := ParseSuppliers(""); err != nil || len(suppliers) != 0 {
+		t.Errorf("expected no suppliers for an empty value, got %v, %v", suppliers, err)
+	}
+
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"not an object", `["acme"]`, "must be an object"},
+		{"bad name", `{"Acme Ltd": {"user_email": "a@b.c", "source": "sftp", ` + sftpOptions + `, "columns": {"sku": "sku", "quantity": "qty"}}}`, "not a supplier name"},
+		{"no columns", `{"acme": {"user_email": "a@b.c", "source": "sftp", ` + sftpOptions + `, "columns": {"sku": "sku"}}}`, "needs a quantity or price column"},
+		{"delimiter", `{"acme": {"user_email": "a@b.c", "source": "sftp", ` + sftpOptions + `, "delimiter": ";;", "columns": {"sku": "sku", "price": "p"}}}`, "single character"},
+		{"source", `{"acme": {"user_email": "a@b.c", "source": "ftp", "columns": {"sku": "sku", "price": "p"}}}`, "source must be sftp or s3"},
+		{"s3", `{"acme": {"user_email": "a@b.c", "source": "s3", "options": {"bucket": "drop"}, "columns": {"sku": "sku", "price": "p"}}}`, "needs region, access_key_id, secret_access_key"},
+	}
+	for _, tt := range tests {
+		if _, err := ParseSuppliers(tt.value); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	supplier := Supplier{Delimiter: ";", Quantity: QuantityLevel, Columns: Columns{SKU: "item code", Quantity: "QTY", Price: "Price"}}
+	data := "\ufeffItem Code; Qty; Price\nWID-1;5;9.999\nWID-2;-1;\n;2;x\nWID-3\n"
+
+	rows, err := Parse([]byte(data), supplier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	five, price := 5, 10.0
+	want := []Row{
+		{Line: 2, SKU: "WID-1", Quantity: &five, Price: &price},
+		{Line: 3, SKU: "WID-2", Error: "quantity must be at least 0"},
+		{Line: 4, Error: "sku is empty; price must be a number of at least 0", Quantity: new(int)},
+		{Line: 5, SKU: "WID-3"},
+	}
+	*want[2].Quantity = 2
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("expected %+v, got %+v", want, rows)
+	}
+
+	if _, err := Parse([]byte("sku,qty\n"), Supplier{Delimiter: ",", Columns: Columns{SKU: "sku", Price: "price"}}); err == nil ||
+		!strings.Contains(err.Error(), `no "price" column`) {
+		t.Errorf("expected a missing column error, got %v", err)
+	}
+	if _, err := Parse(nil, supplier); err == nil || err.Error() != "file is empty" {
+		t.Errorf("expected an empty file error, got %v", err)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	widget := models.Product{ID: uuid.New(), SKU: "WID-1", Stock: 10, Price: 5}
+	products := map[string]models.Product{"WID-1": widget}
+	three, twelve, price := 3, 12, 6.0
+
+	level := Supplier{Quantity: QuantityLevel}
+	changes := Plan(level, []Row{
+		{Line: 2, SKU: "WID-1", Quantity: &twelve},
+		{Line: 3, SKU: "WID-1", Quantity: &twelve, Price: &price},
+		{Line: 4, SKU: "WID-1", Quantity: &twelve},
+		{Line: 5, SKU: "GONE"},
+		{Line: 6, SKU: "WID-1", Error: "quantity must be a whole number"},
+	}, products)
+
+	want := []struct {
+		stock  int
+		price  bool
+		status models.IngestRowStatus
+	}{
+		{2, false, models.IngestRowApplied},
+		{0, true, models.IngestRowApplied},
+		{0, false, models.IngestRowUnchanged},
+		{0, false, models.IngestRowFailed},
+		{0, false, models.IngestRowFailed},
+	}
+	for i, w := range want {
+		c := changes[i]
+		if c.Stock != w.stock || (c.Price != nil) != w.price || c.Result.Status != w.status {
+			t.Errorf("row %d: expected %+v, got %+v", i, w, c)
+		}
+	}
+	if changes[3].Result.Error != "no product has this SKU" {
+		t.Errorf("unexpected error %q", changes[3].Result.Error)
+	}
+	if products["WID-1"].Stock != 10 {
+		t.Error("expected the products to be left as they were")
+	}
+
+	changes = Plan(Supplier{Quantity: QuantityChange}, []Row{{Line: 2, SKU: "WID-1", Quantity: &three}}, products)
+	if changes[0].Stock != 3 || changes[0].ProductID != widget.ID {
+		t.Errorf("expected a change of 3, got %+v", changes[0])
+	}
+}
+
+func TestS3Source(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/supplier-drop/":
+			io.WriteString(w, `<ListBucketResult><Contents><Key>acme/a.csv</Key></Contents>`+
+				`<Contents><Key>acme/processed/old.csv</Key></Contents><IsTruncated>false</IsTruncated></ListBucketResult>`)
+		case r.Method == http.MethodGet:
+			w.Header().Set("Last-Modified", "Fri, 02 Jan 2026 03:04:05 GMT")
+			io.WriteString(w, "sku,stock\n")
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	bucket, err := s3Storage(map[string]string{"endpoint": server.URL, "region": "us-east-1", "bucket": "supplier-drop",
+		"prefix": "acme", "access_key_id": "key", "secret_access_key": "secret"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	bucket.Transport = server.Client().Transport
+	source := &s3Source{bucket: bucket, listed: map[string]bool{}, contents: map[string][]byte{}}
+
+	names, err := source.List(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(names, []string{"a.csv"}) {
+		t.Errorf("expected only the files directly under the prefix, got %v", names)
+	}
+	if _, err := source.Read(context.Background(), "processed/old.csv"); err == nil {
+		t.Error("expected files that were not listed to be refused")
+	}
+	if err := source.Archive(context.Background(), "a.csv", folderProcessed, "a-1.csv"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"GET /supplier-drop/", "GET /supplier-drop/acme/a.csv", "PUT /supplier-drop/acme/processed/a-1.csv", "DELETE /supplier-drop/acme/a.csv"}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("expected requests %v, got %v", want, requests)
+	}
+}
@@ -0,0 +1,208 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+
+	"rtims-backend/internal/sftp"
+	"rtims-backend/internal/storage"
+)
+
+// MaxFileSize is the largest file that is ingested.
+const MaxFileSize = 10 << 20
+
+// The folders, inside the drop folder, files are moved to once processed.
+const (
+	folderProcessed = "processed"
+	folderFailed    = "failed"
+)
+
+// Source is a supplier's drop folder.
+type Source interface {
+	// List returns the names of the files in the folder.
+	List(ctx context.Context) ([]string, error)
+	// Read returns the content of a file, failing for files larger than
+	// MaxFileSize.
+	Read(ctx context.Context, name string) ([]byte, error)
+	// Archive moves a file to a folder inside the drop folder, as
+	// archived, so files sent again under the same name do not clash.
+	Archive(ctx context.Context, name, folder, archived string) error
+	Close() error
+}
+
+// validateSource checks the options of a source when the suppliers
+// setting is saved.
+func validateSource(source string, options map[string]string) error {
+	switch source {
+	case "sftp":
+		if err := sftp.ConfigFromOptions(options).Validate(); err != nil {
+			return fmt.Errorf("sftp options: %w", err)
+		}
+	case "s3":
+		if _, err := s3Storage(options); err != nil {
+			return fmt.Errorf("s3 options: %w", err)
+		}
+	default:
+		return errors.New("source must be sftp or s3")
+	}
+	return nil
+}
+
+// Location describes where a supplier's files are, without credentials.
+func Location(supplier Supplier) string {
+	if supplier.Source == "s3" {
+		return "s3://" + supplier.Options["bucket"] + "/" + strings.Trim(supplier.Options["prefix"], "/")
+	}
+	return "sftp://" + supplier.Options["host"] + "/" + strings.TrimPrefix(directory(supplier.Options), "/")
+}
+
+// OpenSource connects to a supplier's drop folder.
+func OpenSource(ctx context.Context, supplier Supplier) (Source, error) {
+	if supplier.Source == "s3" {
+		bucket, err := s3Storage(supplier.Options)
+		if err != nil {
+			return nil, err
+		}
+		return &s3Source{bucket: bucket, listed: map[string]bool{}, contents: map[string][]byte{}}, nil
+	}
+
+	client, err := sftp.Dial(ctx, sftp.ConfigFromOptions(supplier.Options))
+	if err != nil {
+		return nil, err
+	}
+	return &sftpSource{client: client, dir: directory(supplier.Options)}, nil
+}
+
+func directory(options map[string]string) string {
+	if dir := options["directory"]; dir != "" {
+		return dir
+	}
+	return "."
+}
+
+// sftpSource is a directory on an SFTP server, given by the sftp options
+// and directory.
+type sftpSource struct {
+	client *sftp.Client
+	dir    string
+}
+
+func (s *sftpSource) List(ctx context.Context) ([]string, error) {
+	return s.client.ReadDir(s.dir)
+}
+
+func (s *sftpSource) Read(ctx context.Context, name string) ([]byte, error) {
+	return s.client.ReadFile(path.Join(s.dir, name), MaxFileSize)
+}
+
+func (s *sftpSource) Archive(ctx context.Context, name, folder, archived string) error {
+	target := path.Join(s.dir, folder)
+	if err := s.client.Mkdir(target); err != nil {
+		return err
+	}
+	return s.client.Rename(path.Join(s.dir, name), path.Join(target, archived))
+}
+
+func (s *sftpSource) Close() error {
+	return s.client.Close()
+}
+
+// s3Storage returns the S3 client of the s3 options: bucket, prefix,
+// region, access_key_id, secret_access_key and, for services other than
+// Amazon S3, endpoint.
+func s3Storage(options map[string]string) (*storage.S3, error) {
+	var missing []string
+	for _, option := range []string{"bucket", "region", "access_key_id", "secret_access_key"} {
+		if options[option] == "" {
+			missing = append(missing, option)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("needs %s", strings.Join(missing, ", "))
+	}
+	endpoint := options["endpoint"]
+	if endpoint == "" {
+		endpoint = "https://s3." + options["region"] + ".amazonaws.com"
+	}
+	if parsed, err := url.Parse(endpoint); err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return nil, errors.New("endpoint must be an http or https URL")
+	}
+	return &storage.S3{
+		Endpoint:        endpoint,
+		Region:          options["region"],
+		Bucket:          options["bucket"],
+		Prefix:          options["prefix"],
+		AccessKeyID:     options["access_key_id"],
+		SecretAccessKey: options["secret_access_key"],
+	}, nil
+}
+
+// s3Source is the objects directly under a prefix of a bucket. S3 cannot
+// move objects, so archiving copies the content read back under the
+// folder and deletes the original.
+type s3Source struct {
+	bucket   *storage.S3
+	listed   map[string]bool
+	contents map[string][]byte
+}
+
+func (s *s3Source) List(ctx context.Context) ([]string, error) {
+	objects, err := s.bucket.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(objects))
+	for _, object := range objects {
+		// Archived files are in folders below the prefix
+		if strings.Contains(object.Key, "/") {
+			continue
+		}
+		s.listed[object.Key] = true
+		names = append(names, object.Key)
+	}
+	return names, nil
+}
+
+func (s *s3Source) Read(ctx context.Context, name string) ([]byte, error) {
+	if !s.listed[name] {
+		return nil, fmt.Errorf("%s is not in the drop folder", name)
+	}
+	r, err := s.bucket.Open(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(io.LimitReader(r, MaxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxFileSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", name, MaxFileSize)
+	}
+	s.contents[name] = data
+	return data, nil
+}
+
+func (s *s3Source) Archive(ctx context.Context, name, folder, archived string) error {
+	data, ok := s.contents[name]
+	if !ok {
+		var err error
+		if data, err = s.Read(ctx, name); err != nil {
+			return err
+		}
+	}
+	if err := s.bucket.Put(ctx, folder+"/"+archived, bytes.NewReader(data), int64(len(data)), ""); err != nil {
+		return err
+	}
+	return s.bucket.Delete(ctx, name)
+}
+
+func (s *s3Source) Close() error {
+	return nil
+}
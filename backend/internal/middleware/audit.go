@@ -2,10 +2,10 @@ package middleware
 
 import (
 	"bytes"
-	"database/sql"
 	"encoding/json"
 	"io"
 	"log"
+	"net/http"
 	"strings"
 	"time"
 
@@ -56,16 +56,68 @@ func mapMethodToAction(method string) models.AuditAction {
 	}
 }
 
-type AuditMiddleware struct {
-	db           *sql.DB
-	auditService *database.AuditService
+// auditedKey marks a request whose handler wrote its own audit entry.
+const auditedKey = "audited"
+
+// MarkAudited tells AuditLog that the handler recorded a detailed audit
+// entry itself, so the generic request entry is skipped. Handlers that do
+// not call it are still audited by the middleware.
+func MarkAudited(c *gin.Context) {
+	c.Set(auditedKey, true)
+}
+
+// maxAuditErrorBody caps how much of an error response is kept to find its
+// error message.
+const maxAuditErrorBody = 4096
+
+// auditResponseWriter keeps the start of the response body so the error
+// message of a failed request can be recorded, or the whole body, up to
+// database.MaxSampleBody, of a sampled request.
+type auditResponseWriter struct {
+	gin.ResponseWriter
+	body   bytes.Buffer
+	sample bool
+}
+
+func (w *auditResponseWriter) Write(data []byte) (int, error) {
+	// One byte past the cap tells a sample it is too large
+	limit := maxAuditErrorBody
+	if w.sample {
+		limit = database.MaxSampleBody + 1
+	}
+	if (w.sample || w.Status() >= 400) && w.body.Len() < limit {
+		w.body.Write(data[:min(len(data), limit-w.body.Len())])
+	}
+	return w.ResponseWriter.Write(data)
 }
 
-func NewAuditMiddleware(db *sql.DB) *AuditMiddleware {
-	return &AuditMiddleware{
-		db:           db,
-		auditService: database.NewAuditService(db),
+// errorMessage returns the "error" field of a failed JSON response, falling
+// back to errors the handler attached to the context.
+func errorMessage(c *gin.Context, body []byte) string {
+	if c.Writer.Status() < 400 {
+		return ""
 	}
+
+	var response struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &response) == nil && response.Error != "" {
+		return response.Error
+	}
+	if len(c.Errors) > 0 {
+		return c.Errors.String()
+	}
+	return http.StatusText(c.Writer.Status())
+}
+
+type AuditMiddleware struct {
+	writer *database.AuditWriter
+}
+
+// NewAuditMiddleware records requests through writer. When its queue is
+// full entries are dropped, so a slow database cannot back up requests.
+func NewAuditMiddleware(writer *database.AuditWriter) *AuditMiddleware {
+	return &AuditMiddleware{writer: writer}
 }
 
 func (am *AuditMiddleware) AuditLog() gin.HandlerFunc {
@@ -76,17 +128,17 @@ func (am *AuditMiddleware) AuditLog() gin.HandlerFunc {
 			return
 		}
 
-		// Get current user info
-		userID, _, err := GetCurrentUser(c)
-		if err != nil {
-			// For unauthenticated requests, use system user
-			userID = uuid.Nil
-		}
+		// Keep the bodies of a share of mutating requests, see
+		// database.SettingAuditSamplePercent
+		method := c.Request.Method
+		sampled := (method == "POST" || method == "PUT" || method == "PATCH" || method == "DELETE") && am.writer.Sample()
 
 		// Capture request body for create/update operations
 		var requestBody map[string]interface{}
-		if c.Request.Method == "POST" || c.Request.Method == "PUT" {
-			bodyBytes, err := io.ReadAll(c.Request.Body)
+		var bodyBytes []byte
+		if method == "POST" || method == "PUT" || sampled {
+			var err error
+			bodyBytes, err = io.ReadAll(c.Request.Body)
 			if err == nil {
 				json.Unmarshal(bodyBytes, &requestBody)
 				// Restore the request body
@@ -95,26 +147,46 @@ func (am *AuditMiddleware) AuditLog() gin.HandlerFunc {
 		}
 
 		// Process the request
+		writer := &auditResponseWriter{ResponseWriter: c.Writer, sample: sampled}
+		c.Writer = writer
+		start := time.Now()
 		c.Next()
+		duration := time.Since(start)
 
-		// Log the action
-		go func() {
-			auditLog := &models.AuditLog{
-				ID:         uuid.New(),
-				TableName:  extractTableName(c.Request.URL.Path),
-				RecordID:   extractRecordID(c.Request.URL.Path),
-				Action:     mapMethodToAction(c.Request.Method),
-				NewValues:  requestBody,
-				ChangedBy:  userID,
-				ChangedAt:  time.Now(),
-				IPAddress:  c.ClientIP(),
-				UserAgent:  c.GetHeader("User-Agent"),
-			}
+		// A sampled request keeps its own entry for its bodies, even when
+		// the handler recorded a detailed one
+		if c.GetBool(auditedKey) && !sampled {
+			return
+		}
 
-			err := am.auditService.CreateAuditLog(auditLog)
-			if err != nil {
-				log.Printf("Failed to create audit log: %v", err)
-			}
-		}()
+		// Audit entries must name a user, so unauthenticated requests are skipped
+		userID, _, err := GetCurrentUser(c)
+		if err != nil {
+			return
+		}
+
+		// Build the entry now, as the context is reused once the request ends
+		auditLog := &models.AuditLog{
+			ID:         uuid.New(),
+			TableName:  extractTableName(c.Request.URL.Path),
+			RecordID:   extractRecordID(c.Request.URL.Path),
+			Action:     mapMethodToAction(c.Request.Method),
+			NewValues:  requestBody,
+			ChangedBy:  userID,
+			ChangedAt:  time.Now(),
+			IPAddress:  c.ClientIP(),
+			UserAgent:  c.GetHeader("User-Agent"),
+			StatusCode:   writer.Status(),
+			ErrorMessage: errorMessage(c, writer.body.Bytes()),
+			DurationMs:   duration.Milliseconds(),
+		}
+		if sampled {
+			auditLog.RequestBody = database.SampleBody(bodyBytes, c.ContentType())
+			auditLog.ResponseBody = database.SampleBody(writer.body.Bytes(), writer.Header().Get("Content-Type"))
+		}
+
+		if !am.writer.Enqueue(auditLog) {
+			log.Printf("Audit log queue full, dropping %s %s entry", c.Request.Method, c.Request.URL.Path)
+		}
 	}
-}
\ No newline at end of file
+}
@@ -7,6 +7,8 @@ import (
 	"strings"
 
 	"rtims-backend/config"
+	"rtims-backend/internal/apierror"
+	"rtims-backend/internal/database"
 	"rtims-backend/internal/models"
 
 	"github.com/gin-gonic/gin"
@@ -26,6 +28,9 @@ type Claims struct {
 	UserID uuid.UUID `json:"user_id"`
 	Email  string    `json:"email"`
 	Role   models.UserRole `json:"role"`
+	// OrganizationID scopes the request's queries. Tokens issued before
+	// organizations existed have none and belong to the default one.
+	OrganizationID uuid.UUID `json:"organization_id"`
 	jwt.RegisteredClaims
 }
 
@@ -34,16 +39,14 @@ func JWTAuth() gin.HandlerFunc {
  		authHeader := c.GetHeader("Authorization")
  		if authHeader == "" {
  			log.Printf("JWT Auth: Missing Authorization header for request to %s", c.Request.URL.Path)
- 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
- 			c.Abort()
+ 			apierror.Respond(c, apierror.New(http.StatusUnauthorized, apierror.CodeUnauthenticated, "Authorization header required"))
  			return
  		}
 
  		tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
  		if tokenString == authHeader {
  			log.Printf("JWT Auth: Bearer token missing for request to %s", c.Request.URL.Path)
- 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Bearer token required"})
- 			c.Abort()
+ 			apierror.Respond(c, apierror.New(http.StatusUnauthorized, apierror.CodeUnauthenticated, "Bearer token required"))
  			return
  		}
 
@@ -56,8 +59,10 @@ func JWTAuth() gin.HandlerFunc {
  			log.Printf("JWT Auth: Token parsing failed for request to %s: %v", c.Request.URL.Path, err)
 
  			// Provide specific error messages based on the type of error
+ 			code := apierror.CodeInvalidToken
  			var errorMessage string
  			if strings.Contains(err.Error(), "expired") {
+ 				code = apierror.CodeTokenExpired
  				errorMessage = "Token has expired"
  			} else if strings.Contains(err.Error(), "malformed") {
  				errorMessage = "Token is malformed"
@@ -67,11 +72,7 @@ func JWTAuth() gin.HandlerFunc {
  				errorMessage = "Invalid token"
  			}
 
- 			c.JSON(http.StatusUnauthorized, gin.H{
- 				"error":   errorMessage,
- 				"details": err.Error(),
- 			})
- 			c.Abort()
+ 			apierror.Respond(c, apierror.New(http.StatusUnauthorized, code, errorMessage))
  			return
  		}
 
@@ -80,14 +81,16 @@ func JWTAuth() gin.HandlerFunc {
  			c.Set("user_id", claims.UserID)
  			c.Set("email", claims.Email)
  			c.Set("role", claims.Role)
+ 			organizationID := claims.OrganizationID
+ 			if organizationID == uuid.Nil {
+ 				organizationID = database.DefaultOrganizationID
+ 			}
+ 			c.Set("organization_id", organizationID)
+ 			c.Request = c.Request.WithContext(database.WithOrganization(c.Request.Context(), organizationID))
  			c.Next()
  		} else {
  			log.Printf("JWT Auth: Invalid token claims for request to %s", c.Request.URL.Path)
- 			c.JSON(http.StatusUnauthorized, gin.H{
- 				"error":   "Invalid token claims",
- 				"details": "Token claims could not be validated",
- 			})
- 			c.Abort()
+ 			apierror.Respond(c, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid token claims"))
  			return
  		}
  	}
@@ -98,33 +101,20 @@ func AdminOnly() gin.HandlerFunc {
  	role, exists := c.Get("role")
  	if !exists {
  		log.Printf("AdminOnly: User role not found for request to %s", c.Request.URL.Path)
- 		c.JSON(http.StatusUnauthorized, gin.H{
- 			"error":   "User role not found",
- 			"details": "Authentication required before accessing admin resources",
- 		})
- 		c.Abort()
+ 		apierror.Respond(c, apierror.New(http.StatusUnauthorized, apierror.CodeUnauthenticated, "User role not found"))
  		return
  	}
 
  	userRole, ok := role.(models.UserRole)
  	if !ok {
  		log.Printf("AdminOnly: Invalid role type for request to %s", c.Request.URL.Path)
- 		c.JSON(http.StatusUnauthorized, gin.H{
- 			"error":   "Invalid user role",
- 			"details": "User role could not be determined",
- 		})
- 		c.Abort()
+ 		apierror.Respond(c, apierror.New(http.StatusUnauthorized, apierror.CodeUnauthenticated, "Invalid user role"))
  		return
  	}
 
  	if userRole != models.RoleAdmin {
  		log.Printf("AdminOnly: Access denied for user with role %v (not admin) accessing %s", userRole, c.Request.URL.Path)
- 		c.JSON(http.StatusForbidden, gin.H{
- 			"error":   "Admin access required",
- 			"details": fmt.Sprintf("User role '%s' does not have admin privileges", userRole),
- 			"role":    userRole,
- 		})
- 		c.Abort()
+ 		apierror.Respond(c, apierror.New(http.StatusForbidden, apierror.CodeForbidden, "Admin access required"))
  		return
  	}
 
@@ -133,6 +123,26 @@ func AdminOnly() gin.HandlerFunc {
  	}
  }
 
+// HostAdminOnly allows admins of the default organization, who manage the
+// other organizations. It runs after AdminOnly.
+func HostAdminOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if GetOrganization(c) != database.DefaultOrganizationID {
+			apierror.Respond(c, apierror.New(http.StatusForbidden, apierror.CodeForbidden, "Host admin access required"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// GetOrganization returns the organization of the authenticated user, or
+// uuid.Nil before JWTAuth has run.
+func GetOrganization(c *gin.Context) uuid.UUID {
+	id, _ := c.Get("organization_id")
+	organizationID, _ := id.(uuid.UUID)
+	return organizationID
+}
+
 func GetCurrentUser(c *gin.Context) (uuid.UUID, models.UserRole, error) {
 	userID, exists := c.Get("user_id")
 	if !exists {
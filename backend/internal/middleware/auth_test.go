@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"rtims-backend/internal/database"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestHostAdminOnly(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, tc := range []struct {
+		organization interface{}
+		status       int
+	}{
+		{database.DefaultOrganizationID, http.StatusOK},
+		{uuid.New(), http.StatusForbidden},
+		{nil, http.StatusForbidden},
+	} {
+		r := gin.New()
+		r.GET("/settings", func(c *gin.Context) {
+			if tc.organization != nil {
+				c.Set("organization_id", tc.organization)
+			}
+		}, HostAdminOnly(), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/settings", nil))
+		if w.Code != tc.status {
+			t.Errorf("organization %v: expected %d, got %d", tc.organization, tc.status, w.Code)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br", "br"},
+		{"br;q=0.5, gzip", "gzip"},
+		{"br;q=0, gzip;q=0", ""},
+		{"*", "br"},
+		{"*;q=0.2, br;q=0", "gzip"},
+		{"GZIP", "gzip"},
+	}
+	for _, tt := range tests {
+		if got := negotiateEncoding(tt.header); got != tt.expected {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", tt.header, got, tt.expected)
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	body := strings.Repeat("SKU-0001,Widget,42\n", 200)
+
+	router := gin.New()
+	router.Use(Compress(1024))
+	router.GET("/csv", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/csv", []byte(body))
+	})
+	router.GET("/small", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+	router.GET("/pdf", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/pdf", []byte(body))
+	})
+
+	tests := []struct {
+		name     string
+		path     string
+		headers  map[string]string
+		encoding string
+	}{
+		{"gzip", "/csv", map[string]string{"Accept-Encoding": "gzip"}, "gzip"},
+		{"brotli preferred", "/csv", map[string]string{"Accept-Encoding": "gzip, br"}, "br"},
+		{"not accepted", "/csv", nil, ""},
+		{"below minimum size", "/small", map[string]string{"Accept-Encoding": "gzip"}, ""},
+		{"pdf", "/pdf", map[string]string{"Accept-Encoding": "gzip"}, ""},
+		{"websocket upgrade", "/csv", map[string]string{"Accept-Encoding": "gzip", "Upgrade": "websocket"}, ""},
+		{"range request", "/csv", map[string]string{"Accept-Encoding": "gzip", "Range": "bytes=0-99"}, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		for name, value := range tt.headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if got := w.Header().Get("Content-Encoding"); got != tt.encoding {
+			t.Errorf("%s: expected Content-Encoding %q, got %q", tt.name, tt.encoding, got)
+			continue
+		}
+
+		var reader io.Reader = w.Body
+		switch tt.encoding {
+		case "gzip":
+			gz, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			reader = gz
+		case "br":
+			reader = brotli.NewReader(w.Body)
+		}
+		if tt.encoding != "" && w.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("%s: expected Vary: Accept-Encoding", tt.name)
+		}
+		decoded, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("%s: failed to decode body: %v", tt.name, err)
+		}
+		if tt.path != "/small" && string(decoded) != body {
+			t.Errorf("%s: body did not round-trip", tt.name)
+		}
+		if tt.path == "/small" && string(decoded) != `{"ok":true}` {
+			t.Errorf("%s: unexpected body %q", tt.name, decoded)
+		}
+	}
+}
@@ -1,31 +1,105 @@
 package middleware
 
 import (
+	"database/sql"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
 	"rtims-backend/config"
 
 	"github.com/gin-gonic/gin"
 )
 
-func CORS() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		cfg := config.Load()
+// SettingCORSOrigins holds a comma-separated list of origins allowed on top
+// of CORS_ALLOWED_ORIGINS, such as https://*.example.com.
+const SettingCORSOrigins = "cors_allowed_origins"
 
-		origin := c.GetHeader("Origin")
-		allowed := false
+// originsRefresh is how long the allowed origins are cached before the
+// setting is read again.
+const originsRefresh = 30 * time.Second
+
+// Origins is the allow-list of browser origins: those configured at startup
+// and those in SettingCORSOrigins, which can change while running.
+type Origins struct {
+	static []string
+	db     *sql.DB
+
+	mu      sync.Mutex
+	allowed []string
+	loaded  time.Time
+}
+
+// NewOrigins returns the allow-list of the static origins and, when db is
+// not nil, those in SettingCORSOrigins. Invalid static origins are left out;
+// config validation reports them.
+func NewOrigins(static []string, db *sql.DB) *Origins {
+	o := &Origins{db: db}
+	for _, value := range static {
+		if origin, err := config.ParseOrigin(value); err == nil {
+			o.static = append(o.static, origin)
+		}
+	}
+	return o
+}
+
+// Allowed reports whether origin is allowed.
+func (o *Origins) Allowed(origin string) bool {
+	return config.MatchOrigin(origin, o.list())
+}
+
+// list returns the static and configured origins, refreshing the setting
+// at most every originsRefresh.
+func (o *Origins) list() []string {
+	if o.db == nil {
+		return o.static
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
 
-		for _, allowedOrigin := range cfg.AllowedOrigins {
-			if origin == allowedOrigin {
-				allowed = true
-				break
+	if o.allowed != nil && time.Since(o.loaded) < originsRefresh {
+		return o.allowed
+	}
+
+	allowed := append([]string{}, o.static...)
+	var raw string
+	err := o.db.QueryRow("SELECT value FROM system_settings WHERE key = $1", SettingCORSOrigins).Scan(&raw)
+	if err == nil {
+		for _, value := range strings.Split(raw, ",") {
+			if value = strings.TrimSpace(value); value == "" {
+				continue
+			}
+			// Settings are validated when updated, so only a value stored
+			// some other way is skipped here
+			origin, err := config.ParseOrigin(value)
+			if err != nil {
+				log.Printf("Ignoring an origin in %s: %v", SettingCORSOrigins, err)
+				continue
 			}
+			allowed = append(allowed, origin)
 		}
+	}
+
+	o.allowed = allowed
+	o.loaded = time.Now()
+	return allowed
+}
+
+func CORS(cfg *config.Config, origins *Origins) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
 
-		if allowed || cfg.Environment == "development" {
+		if origin != "" && (origins.Allowed(origin) || cfg.Environment == "development") {
 			c.Header("Access-Control-Allow-Origin", origin)
 		}
+		// The response depends on the origin, so caches must keep one per origin
+		c.Writer.Header().Add("Vary", "Origin")
 
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, If-None-Match, If-Modified-Since")
+		c.Header("Access-Control-Expose-Headers", "ETag, Last-Modified")
 		c.Header("Access-Control-Allow-Credentials", "true")
 		c.Header("Access-Control-Max-Age", "86400")
 
@@ -36,4 +110,4 @@ func CORS() gin.HandlerFunc {
 
 		c.Next()
 	}
-}
\ No newline at end of file
+}
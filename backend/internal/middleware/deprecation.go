@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Deprecated marks the responses of a route that has a successor, so
+// clients can find out before it goes away: Deprecation: true, a Link to
+// the successor, with path parameters such as :id filled in from the
+// request, and, when sunset is set, the date the route will be removed.
+func Deprecated(successor string, sunset time.Time) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		link := successor
+		for _, param := range c.Params {
+			link = strings.Replace(link, ":"+param.Key, param.Value, 1)
+		}
+		c.Header("Deprecation", "true")
+		c.Header("Link", "<"+link+">; rel=\"successor-version\"")
+		if !sunset.IsZero() {
+			c.Header("Sunset", sunset.UTC().Format(http.TimeFormat))
+		}
+		c.Next()
+	}
+}
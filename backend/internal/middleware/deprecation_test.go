@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDeprecated(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	sunset := time.Date(2027, 6, 30, 0, 0, 0, 0, time.UTC)
+	r := gin.New()
+	r.GET("/api/v1/products/:id", Deprecated("/api/v2/products/:id", sunset), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	r.GET("/api/v1/stock-movements", Deprecated("/api/v2/stock-movements", time.Time{}), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/products/42", nil))
+	if got := w.Header().Get("Deprecation"); got != "true" {
+		t.Errorf("expected Deprecation: true, got %q", got)
+	}
+	if got := w.Header().Get("Link"); got != `</api/v2/products/42>; rel="successor-version"` {
+		t.Errorf("unexpected Link %q", got)
+	}
+	if got := w.Header().Get("Sunset"); got != "Wed, 30 Jun 2027 00:00:00 GMT" {
+		t.Errorf("unexpected Sunset %q", got)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/stock-movements", nil))
+	if got := w.Header().Get("Sunset"); got != "" {
+		t.Errorf("expected no Sunset without a date, got %q", got)
+	}
+}
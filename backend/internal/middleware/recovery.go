@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+
+	"rtims-backend/internal/apierror"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries the ID of a request, taken from the client or a
+// proxy in front of the API when it sends one, and echoed on the response.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds request IDs taken from clients.
+const maxRequestIDLength = 128
+
+// RequestID gives every request an ID, stored in the context as
+// "request_id", so errors can be matched to the request behind them.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if requestID == "" || len(requestID) > maxRequestIDLength {
+			requestID = uuid.New().String()
+		}
+		c.Set("request_id", requestID)
+		c.Header(RequestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+// Recovery answers 500 when a handler panics, logging the stack trace, and
+// reports the panic to Sentry along with every other 5xx response. 503s are
+// left out: they are expected while Postgres is unreachable, and the
+// breaker logs those outages already. Without sentry.Init, reporting does
+// nothing.
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hub := sentry.CurrentHub().Clone()
+
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				// Used to abort a response on purpose; net/http handles it
+				panic(recovered)
+			}
+
+			log.Printf("Panic serving %s %s: %v\n%s", c.Request.Method, c.Request.URL.Path, recovered, debug.Stack())
+			configureScope(hub, c, http.StatusInternalServerError)
+			hub.RecoverWithContext(c.Request.Context(), recovered)
+
+			if !c.Writer.Written() {
+				apierror.Respond(c, apierror.ErrInternal)
+			} else {
+				c.Abort()
+			}
+		}()
+
+		c.Next()
+
+		status := c.Writer.Status()
+		if status < http.StatusInternalServerError || status == http.StatusServiceUnavailable {
+			return
+		}
+		configureScope(hub, c, status)
+		if err := c.Errors.Last(); err != nil {
+			hub.CaptureException(err.Err)
+		} else {
+			hub.CaptureException(fmt.Errorf("%s %s returned %d", c.Request.Method, route(c), status))
+		}
+	}
+}
+
+// configureScope attaches the request, its ID, route and user to events
+// reported from hub.
+func configureScope(hub *sentry.Hub, c *gin.Context, status int) {
+	hub.ConfigureScope(func(scope *sentry.Scope) {
+		scope.SetRequest(c.Request)
+		scope.SetTag("route", route(c))
+		scope.SetTag("status", fmt.Sprint(status))
+		if requestID := c.GetString("request_id"); requestID != "" {
+			scope.SetTag("request_id", requestID)
+		}
+		if userID, _, err := GetCurrentUser(c); err == nil {
+			scope.SetUser(sentry.User{ID: userID.String()})
+		}
+	})
+}
+
+// route returns the route pattern that matched, such as
+// /api/v1/products/:id, so reports group by endpoint rather than by URL.
+func route(c *gin.Context) string {
+	if fullPath := c.FullPath(); fullPath != "" {
+		return fullPath
+	}
+	return c.Request.URL.Path
+}
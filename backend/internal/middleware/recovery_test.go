@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+)
+
+// captureTransport keeps the events it is sent instead of sending them.
+type captureTransport struct {
+	mu     sync.Mutex
+	events []*sentry.Event
+}
+
+func (t *captureTransport) Configure(sentry.ClientOptions)            {}
+func (t *captureTransport) Flush(time.Duration) bool                  { return true }
+func (t *captureTransport) FlushWithContext(ctx context.Context) bool { return true }
+func (t *captureTransport) Close()                                    {}
+
+func (t *captureTransport) SendEvent(event *sentry.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, event)
+}
+
+func TestRecovery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	transport := &captureTransport{}
+	client, err := sentry.NewClient(sentry.ClientOptions{Transport: transport})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sentry.CurrentHub().BindClient(client)
+	defer sentry.CurrentHub().BindClient(nil)
+
+	r := gin.New()
+	r.Use(RequestID(), Recovery())
+	r.GET("/products/:id", func(c *gin.Context) { panic("boom") })
+	r.GET("/unavailable", func(c *gin.Context) { c.Status(http.StatusServiceUnavailable) })
+	r.GET("/failing", func(c *gin.Context) { c.Status(http.StatusBadGateway) })
+	r.GET("/ok", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, "/products/42", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 after a panic, got %d", w.Code)
+	}
+	if w.Header().Get(RequestIDHeader) != "req-1" {
+		t.Errorf("expected the request ID to be echoed, got %q", w.Header().Get(RequestIDHeader))
+	}
+
+	for _, path := range []string{"/unavailable", "/failing", "/ok"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	if len(transport.events) != 2 {
+		t.Fatalf("expected the panic and the 502 to be reported, got %d events", len(transport.events))
+	}
+	panicEvent := transport.events[0]
+	if panicEvent.Tags["route"] != "/products/:id" || panicEvent.Tags["request_id"] != "req-1" {
+		t.Errorf("unexpected panic event tags %v", panicEvent.Tags)
+	}
+	if transport.events[1].Tags["status"] != "502" {
+		t.Errorf("unexpected 502 event tags %v", transport.events[1].Tags)
+	}
+}
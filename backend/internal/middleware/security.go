@@ -1,8 +1,13 @@
 package middleware
 
 import (
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"rtims-backend/internal/apierror"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -27,6 +32,13 @@ func RateLimit() gin.HandlerFunc {
  	lastCleanup := time.Now()
 
  	return func(c *gin.Context) {
+ 		// Fingerprinted frontend assets are fetched by the dozen on a page
+ 		// load and never change, so they do not count against the limit
+ 		if strings.HasPrefix(c.Request.URL.Path, "/_next/static/") {
+ 			c.Next()
+ 			return
+ 		}
+
  		// Get client IP
  		clientIP := c.ClientIP()
 
@@ -64,8 +76,8 @@ func RateLimit() gin.HandlerFunc {
  			}
 
  			if len(validRequests) >= limit {
- 				c.JSON(429, gin.H{"error": "Too many requests", "retry_after": 60})
- 				c.Abort()
+ 				c.Header("Retry-After", strconv.FormatInt(window, 10))
+ 				apierror.Respond(c, apierror.New(http.StatusTooManyRequests, apierror.CodeRateLimited, "Too many requests").WithDetails(gin.H{"retry_after": window}))
  				return
  			}
 
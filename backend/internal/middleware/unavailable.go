@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"math"
+	"strconv"
+
+	"rtims-backend/internal/apierror"
+	"rtims-backend/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DatabaseAvailable answers 503 with Retry-After while the Postgres circuit
+// breaker is open, so requests fail at once during an outage instead of
+// queueing for connections that cannot be made.
+func DatabaseAvailable() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if retryAfter, unavailable := database.PostgresUnavailable(); unavailable {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			apierror.Respond(c, database.ErrUnavailable)
+			return
+		}
+		c.Next()
+	}
+}
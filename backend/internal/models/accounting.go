@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AccountingFormat is the file an accounting export is written as.
+type AccountingFormat string
+
+const (
+	// AccountingQuickBooksCSV is a QuickBooks Online journal entry import.
+	AccountingQuickBooksCSV AccountingFormat = "quickbooks_csv"
+	// AccountingQuickBooksIIF is a QuickBooks Desktop IIF import.
+	AccountingQuickBooksIIF AccountingFormat = "quickbooks_iif"
+	// AccountingXeroCSV is a Xero manual journal import.
+	AccountingXeroCSV AccountingFormat = "xero_csv"
+)
+
+// PostingRevaluation is the kind of the postings of price changes; the
+// postings of movements are of their reason.
+const PostingRevaluation = "revaluation"
+
+// AccountingPosting is the value stock movements of one reason, or price
+// changes, added to (or, when negative, took from) inventory on one day.
+type AccountingPosting struct {
+	Date  time.Time `json:"date"`
+	Kind  string    `json:"kind"`
+	Value float64   `json:"value"`
+	Count int       `json:"count"`
+}
+
+// AccountingExport is a journal exported for one period of an
+// organization, which it locks. PeriodStart is nil for the organization's
+// first export, which covers everything before PeriodEnd.
+type AccountingExport struct {
+	ID             uuid.UUID        `json:"id" db:"id"`
+	OrganizationID uuid.UUID        `json:"organization_id" db:"organization_id"`
+	Format         AccountingFormat `json:"format" db:"format"`
+	PeriodStart    *time.Time       `json:"period_start" db:"period_start"`
+	PeriodEnd      time.Time        `json:"period_end" db:"period_end"`
+	Journals       int              `json:"journals" db:"journals"`
+	Debits         float64          `json:"debits" db:"debits"`
+	Filename       string           `json:"filename" db:"filename"`
+	CreatedBy      *uuid.UUID       `json:"created_by" db:"created_by"`
+	CreatedAt      time.Time        `json:"created_at" db:"created_at"`
+	Content        []byte           `json:"-" db:"content"`
+}
+
+// CreateAccountingExportRequest exports everything since the last export
+// up to and including the day Through, in UTC, which must have ended.
+type CreateAccountingExportRequest struct {
+	Format  AccountingFormat `json:"format" validate:"required,oneof=quickbooks_csv quickbooks_iif xero_csv"`
+	Through string           `json:"through" validate:"required,datetime=2006-01-02"`
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ActivityKind is what an entry of a user's activity timeline records.
+type ActivityKind string
+
+const (
+	// ActivityAudit is an audited action the user took.
+	ActivityAudit ActivityKind = "audit"
+	// ActivityLogin is a login, logout or failed attempt on the account.
+	ActivityLogin ActivityKind = "login"
+	// ActivityStockMovement is a stock change the user made.
+	ActivityStockMovement ActivityKind = "stock_movement"
+)
+
+// ActivityEntry is one entry of a user's activity timeline. Audit and login
+// entries have Audit set, stock movements Movement.
+type ActivityEntry struct {
+	Kind       ActivityKind      `json:"kind"`
+	OccurredAt time.Time         `json:"occurred_at"`
+	Audit      *AuditLog         `json:"audit,omitempty"`
+	Movement   *ActivityMovement `json:"stock_movement,omitempty"`
+}
+
+// ActivityMovement is a stock movement with the product it changed.
+type ActivityMovement struct {
+	StockMovement
+	ProductName string `json:"product_name"`
+	ProductSKU  string `json:"product_sku"`
+}
+
+// ActivitySummary counts a user's activity over the whole period, however
+// much of it the timeline holds.
+type ActivitySummary struct {
+	AuditActions      map[AuditAction]int    `json:"audit_actions"`
+	StockMovements    int                    `json:"stock_movements"`
+	MovementsByReason map[MovementReason]int `json:"movements_by_reason"`
+	UnitsIn           int                    `json:"units_in"`
+	UnitsOut          int                    `json:"units_out"`
+	Logins            int                    `json:"logins"`
+	FailedLogins      int                    `json:"failed_logins"`
+	Logouts           int                    `json:"logouts"`
+	LastLoginAt       *time.Time             `json:"last_login_at"`
+}
+
+// UserActivity is a user's activity between From and To: counts, and a
+// timeline newest first. Truncated is set when the timeline was cut at its
+// limit.
+type UserActivity struct {
+	UserID    uuid.UUID       `json:"user_id"`
+	From      time.Time       `json:"from"`
+	To        time.Time       `json:"to"`
+	Summary   ActivitySummary `json:"summary"`
+	Timeline  []ActivityEntry `json:"timeline"`
+	Truncated bool            `json:"truncated"`
+}
+
+// ActivityFilter selects the period and timeline length of a user's
+// activity.
+type ActivityFilter struct {
+	StartDate *time.Time `form:"start_date"`
+	EndDate   *time.Time `form:"end_date"`
+	Limit     int        `form:"limit"`
+}
+
+// Entity types of the activity feed.
+const (
+	FeedProduct       = "product"
+	FeedCategory      = "category"
+	FeedStockMovement = "stock_movement"
+)
+
+// FeedEntry is one entry of an organization's activity feed: a change to a
+// product or category, or a stock movement, with who made it. Message says
+// it in a sentence, such as "Ana received 50× SKU-123".
+type FeedEntry struct {
+	ID         uuid.UUID   `json:"id"`
+	EntityType string      `json:"entity_type"`
+	EntityID   uuid.UUID   `json:"entity_id"`
+	Action     AuditAction `json:"action"`
+	UserID     uuid.UUID   `json:"user_id"`
+	UserName   string      `json:"user_name"`
+	Message    string      `json:"message"`
+	OccurredAt time.Time   `json:"occurred_at"`
+
+	// Name is the product or category, NewName its name after a rename
+	// and ChangedFields what an update changed
+	Name          string   `json:"name"`
+	NewName       string   `json:"new_name,omitempty"`
+	ChangedFields []string `json:"changed_fields,omitempty"`
+	// The product of a stock movement or a product change, and the
+	// category a product was moved to
+	ProductID *uuid.UUID     `json:"product_id,omitempty"`
+	SKU       string         `json:"sku,omitempty"`
+	Category  string         `json:"category,omitempty"`
+	Change    int            `json:"change,omitempty"`
+	Reason    MovementReason `json:"reason,omitempty"`
+}
+
+// ActivityFeedFilter selects entries of the activity feed. EntityID matches
+// a product or category, and for a product also its stock movements.
+type ActivityFeedFilter struct {
+	EntityType *string    `form:"entity" validate:"omitempty,oneof=product category stock_movement"`
+	EntityID   *uuid.UUID `form:"entity_id"`
+	UserID     *uuid.UUID `form:"user_id"`
+	StartDate  *time.Time `form:"start_date"`
+	EndDate    *time.Time `form:"end_date"`
+	Page       int        `form:"page"`
+	Limit      int        `form:"limit"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AnnouncementSeverity string
+
+const (
+	SeverityInfo     AnnouncementSeverity = "info"
+	SeverityWarning  AnnouncementSeverity = "warning"
+	SeverityCritical AnnouncementSeverity = "critical"
+)
+
+type Announcement struct {
+	ID        uuid.UUID            `json:"id" db:"id"`
+	Title     string               `json:"title" db:"title" validate:"required,min=1,max=200"`
+	Message   string               `json:"message" db:"message" validate:"required"`
+	Severity  AnnouncementSeverity `json:"severity" db:"severity" validate:"required,oneof=info warning critical"`
+	ExpiresAt *time.Time           `json:"expires_at,omitempty" db:"expires_at"`
+	CreatedBy uuid.UUID            `json:"created_by" db:"created_by"`
+	CreatedAt time.Time            `json:"created_at" db:"created_at"`
+}
+
+type CreateAnnouncementRequest struct {
+	Title     string               `json:"title" validate:"required,min=1,max=200"`
+	Message   string               `json:"message" validate:"required"`
+	Severity  AnnouncementSeverity `json:"severity" validate:"omitempty,oneof=info warning critical"`
+	ExpiresAt *time.Time           `json:"expires_at,omitempty"`
+}
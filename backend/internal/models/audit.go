@@ -28,6 +28,21 @@ type AuditLog struct {
 	ChangedAt  time.Time            `json:"changed_at" db:"changed_at"`
 	IPAddress  string               `json:"ip_address" db:"ip_address"`
 	UserAgent  string               `json:"user_agent" db:"user_agent"`
+	// Response details, recorded by the audit middleware
+	StatusCode   int    `json:"status_code,omitempty" db:"status_code"`
+	ErrorMessage string `json:"error_message,omitempty" db:"error_message"`
+	DurationMs   int64  `json:"duration_ms,omitempty" db:"duration_ms"`
+	// Bodies of a sampled request and its response, with sensitive fields
+	// masked. Only the audit log detail reads them.
+	RequestBody  interface{} `json:"request_body,omitempty" db:"request_body"`
+	ResponseBody interface{} `json:"response_body,omitempty" db:"response_body"`
+}
+
+// AuditTimelineEntry is an audit entry with the name of whoever made it.
+type AuditTimelineEntry struct {
+	AuditLog
+	ChangedByName  string `json:"changed_by_name"`
+	ChangedByEmail string `json:"changed_by_email"`
 }
 
 type CreateAuditLogRequest struct {
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type BackupStatus string
+
+const (
+	BackupQueued    BackupStatus = "queued"
+	BackupRunning   BackupStatus = "running"
+	BackupCompleted BackupStatus = "completed"
+	BackupFailed    BackupStatus = "failed"
+)
+
+// Backup is one dump of the database. Location is where the dump was
+// stored, e.g. a file path or s3://bucket/key, and Checksum its SHA-256 in
+// hex.
+type Backup struct {
+	ID          uuid.UUID    `json:"id" db:"id"`
+	Status      BackupStatus `json:"status" db:"status"`
+	Storage     string       `json:"storage" db:"storage"`
+	Location    string       `json:"location,omitempty" db:"location"`
+	Size        int64        `json:"size" db:"size"`
+	Checksum    string       `json:"checksum,omitempty" db:"checksum"`
+	Error       string       `json:"error,omitempty" db:"error"`
+	RequestedBy *uuid.UUID   `json:"requested_by,omitempty" db:"requested_by"`
+	CreatedAt   time.Time    `json:"created_at" db:"created_at"`
+	StartedAt   *time.Time   `json:"started_at,omitempty" db:"started_at"`
+	CompletedAt *time.Time   `json:"completed_at,omitempty" db:"completed_at"`
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CalendarEventKind is the kind of a scheduled inventory event.
+type CalendarEventKind string
+
+const (
+	CalendarStocktake   CalendarEventKind = "stocktake"
+	CalendarMaintenance CalendarEventKind = "maintenance"
+)
+
+// CalendarEvent is a scheduled stocktake or maintenance window of an
+// organization.
+type CalendarEvent struct {
+	ID             uuid.UUID         `json:"id" db:"id"`
+	OrganizationID uuid.UUID         `json:"organization_id" db:"organization_id"`
+	Kind           CalendarEventKind `json:"kind" db:"kind"`
+	Title          string            `json:"title" db:"title"`
+	Description    string            `json:"description" db:"description"`
+	StartsAt       time.Time         `json:"starts_at" db:"starts_at"`
+	EndsAt         time.Time         `json:"ends_at" db:"ends_at"`
+	CreatedBy      *uuid.UUID        `json:"created_by" db:"created_by"`
+	CreatedAt      time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+type CreateCalendarEventRequest struct {
+	Kind        CalendarEventKind `json:"kind" validate:"required,oneof=stocktake maintenance"`
+	Title       string            `json:"title" validate:"required,min=1,max=200"`
+	Description string            `json:"description" validate:"max=2000"`
+	StartsAt    time.Time         `json:"starts_at" validate:"required"`
+	EndsAt      time.Time         `json:"ends_at" validate:"required"`
+}
+
+// UpdateCalendarEventRequest changes the fields that are set.
+type UpdateCalendarEventRequest struct {
+	Kind        *CalendarEventKind `json:"kind,omitempty" validate:"omitempty,oneof=stocktake maintenance"`
+	Title       *string            `json:"title,omitempty" validate:"omitempty,min=1,max=200"`
+	Description *string            `json:"description,omitempty" validate:"omitempty,max=2000"`
+	StartsAt    *time.Time         `json:"starts_at,omitempty"`
+	EndsAt      *time.Time         `json:"ends_at,omitempty"`
+}
+
+// CalendarEventFilter limits the events listed to those overlapping
+// StartDate to EndDate.
+type CalendarEventFilter struct {
+	StartDate *time.Time `form:"start_date"`
+	EndDate   *time.Time `form:"end_date"`
+}
+
+// CalendarFeed is the link of a user's iCalendar feed, which calendar apps
+// subscribe to without a token.
+type CalendarFeed struct {
+	URL string `json:"url"`
+}
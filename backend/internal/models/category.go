@@ -21,4 +21,18 @@ type CreateCategoryRequest struct {
 type UpdateCategoryRequest struct {
 	Name        *string `json:"name,omitempty" validate:"omitempty,min=1,max=100"`
 	Description *string `json:"description,omitempty"`
-}
\ No newline at end of file
+}
+
+// MergeCategoryRequest names the category the products of the merged
+// category move to.
+type MergeCategoryRequest struct {
+	TargetID uuid.UUID `json:"target_id" validate:"required"`
+}
+
+// CategoryMerge is the outcome of merging Source into Target: the products
+// of Source moved to Target and Source was deleted.
+type CategoryMerge struct {
+	Source        Category `json:"source"`
+	Target        Category `json:"target"`
+	ProductsMoved int      `json:"products_moved"`
+}
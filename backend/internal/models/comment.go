@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Comment is a note on a product or, when MovementID is set, on one of its
+// stock movements. Mentions are the users it mentioned with @, who were
+// notified.
+type Comment struct {
+	ID         uuid.UUID   `json:"id" db:"id"`
+	ProductID  uuid.UUID   `json:"product_id" db:"product_id"`
+	MovementID *uuid.UUID  `json:"movement_id,omitempty" db:"movement_id"`
+	AuthorID   *uuid.UUID  `json:"author_id" db:"author_id"`
+	AuthorName string      `json:"author_name" db:"author_name"`
+	Body       string      `json:"body" db:"body"`
+	Mentions   []uuid.UUID `json:"mentions" db:"mentions"`
+	CreatedAt  time.Time   `json:"created_at" db:"created_at"`
+}
+
+type CreateCommentRequest struct {
+	Body string `json:"body" validate:"required,min=1,max=5000"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// DashboardSnapshot holds one day's dashboard figures.
+type DashboardSnapshot struct {
+	Date          time.Time `json:"date" db:"snapshot_date"`
+	StockValue    float64   `json:"stock_value" db:"stock_value"`
+	TotalStock    int       `json:"total_stock" db:"total_stock"`
+	LowStockCount int       `json:"low_stock_count" db:"low_stock_count"`
+	Movements     int       `json:"movements" db:"movements"`
+	UnitsIn       int       `json:"units_in" db:"units_in"`
+	UnitsOut      int       `json:"units_out" db:"units_out"`
+	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type TrendMetric string
+
+const (
+	TrendStockValue TrendMetric = "stock_value"
+	TrendMovements  TrendMetric = "movements"
+	TrendLowStock   TrendMetric = "low_stock"
+)
+
+// TrendPoint is one day of a dashboard metric's time series.
+type TrendPoint struct {
+	Date  string  `json:"date"`
+	Value float64 `json:"value"`
+}
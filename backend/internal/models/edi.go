@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EDIDocumentStatus is how far delivering an EDI document got.
+type EDIDocumentStatus string
+
+const (
+	EDIPending   EDIDocumentStatus = "pending"
+	EDIDelivered EDIDocumentStatus = "delivered"
+	EDIFailed    EDIDocumentStatus = "failed"
+)
+
+// EDIDocument is an EDI 846 inventory advice generated for a trading
+// partner, with the outcome of its delivery.
+type EDIDocument struct {
+	ID             uuid.UUID         `json:"id" db:"id"`
+	Partner        string            `json:"partner" db:"partner"`
+	OrganizationID uuid.UUID         `json:"organization_id" db:"organization_id"`
+	ControlNumber  int               `json:"control_number" db:"control_number"`
+	Items          int               `json:"items" db:"items"`
+	Filename       string            `json:"filename" db:"filename"`
+	Transport      string            `json:"transport" db:"transport"`
+	Status         EDIDocumentStatus `json:"status" db:"status"`
+	Attempts       int               `json:"attempts" db:"attempts"`
+	Error          string            `json:"error,omitempty" db:"error"`
+	CreatedAt      time.Time         `json:"created_at" db:"created_at"`
+	DeliveredAt    *time.Time        `json:"delivered_at" db:"delivered_at"`
+	Content        []byte            `json:"-" db:"content"`
+}
+
+// EDIDocumentFilter narrows a listing of EDI documents, newest first.
+type EDIDocumentFilter struct {
+	Partner string            `form:"partner"`
+	Status  EDIDocumentStatus `form:"status" validate:"omitempty,oneof=pending delivered failed"`
+	Limit   int               `form:"limit" validate:"omitempty,min=1,max=500"`
+}
+
+// EDIPartner is a trading partner configured in settings, with its last
+// document. Its delivery options are never returned.
+type EDIPartner struct {
+	Name              string       `json:"name"`
+	Organization      string       `json:"organization"`
+	ReceiverQualifier string       `json:"receiver_qualifier"`
+	ReceiverID        string       `json:"receiver_id"`
+	Transport         string       `json:"transport"`
+	LastDocument      *EDIDocument `json:"last_document"`
+}
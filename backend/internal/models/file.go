@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ProductImage is the picture of a product. URL is a signed link to the
+// image that expires after a while.
+type ProductImage struct {
+	ProductID   uuid.UUID  `json:"product_id" db:"product_id"`
+	StorageKey  string     `json:"-" db:"storage_key"`
+	Filename    string     `json:"filename" db:"filename"`
+	ContentType string     `json:"content_type" db:"content_type"`
+	Size        int64      `json:"size" db:"size"`
+	UploadedBy  *uuid.UUID `json:"uploaded_by,omitempty" db:"uploaded_by"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+	URL         string     `json:"url,omitempty"`
+}
+
+// MovementAttachment is a file attached to a stock movement, such as a
+// delivery note or a photo of damaged goods. URL is a signed link to the
+// file that expires after a while.
+type MovementAttachment struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	MovementID  uuid.UUID  `json:"movement_id" db:"movement_id"`
+	StorageKey  string     `json:"-" db:"storage_key"`
+	Filename    string     `json:"filename" db:"filename"`
+	ContentType string     `json:"content_type" db:"content_type"`
+	Size        int64      `json:"size" db:"size"`
+	UploadedBy  *uuid.UUID `json:"uploaded_by,omitempty" db:"uploaded_by"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	URL         string     `json:"url,omitempty"`
+}
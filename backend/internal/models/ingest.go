@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IngestStatus is the outcome of a supplier file.
+type IngestStatus string
+
+const (
+	// IngestApplied files had every row applied, or already in effect.
+	IngestApplied IngestStatus = "applied"
+	// IngestPartial files had some rows fail.
+	IngestPartial IngestStatus = "partial"
+	// IngestFailed files could not be read, or every row failed.
+	IngestFailed IngestStatus = "failed"
+)
+
+// IngestRowStatus is the outcome of one row of a supplier file.
+type IngestRowStatus string
+
+const (
+	IngestRowApplied   IngestRowStatus = "applied"
+	IngestRowUnchanged IngestRowStatus = "unchanged"
+	IngestRowFailed    IngestRowStatus = "failed"
+)
+
+// IngestRowResult is what a row of a supplier file did: the stock change
+// it made and the price it set, if any.
+type IngestRowResult struct {
+	Line   int             `json:"line"`
+	SKU    string          `json:"sku"`
+	Status IngestRowStatus `json:"status"`
+	Change *int            `json:"change,omitempty"`
+	Price  *float64        `json:"price,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// IngestFile is a supplier file that was processed. OrganizationID is nil
+// when the file failed before the supplier's account was found.
+type IngestFile struct {
+	ID             uuid.UUID         `json:"id" db:"id"`
+	Supplier       string            `json:"supplier" db:"supplier"`
+	Filename       string            `json:"filename" db:"filename"`
+	Checksum       string            `json:"checksum" db:"checksum"`
+	OrganizationID *uuid.UUID        `json:"organization_id" db:"organization_id"`
+	Status         IngestStatus      `json:"status" db:"status"`
+	Rows           int               `json:"rows" db:"total_rows"`
+	Applied        int               `json:"applied" db:"applied"`
+	Unchanged      int               `json:"unchanged" db:"unchanged"`
+	Failed         int               `json:"failed" db:"failed"`
+	Error          string            `json:"error,omitempty" db:"error"`
+	ProcessedAt    time.Time         `json:"processed_at" db:"processed_at"`
+	Results        []IngestRowResult `json:"results,omitempty" db:"results"`
+}
+
+// IngestFileFilter narrows a listing of supplier files, newest first.
+type IngestFileFilter struct {
+	Supplier string       `form:"supplier"`
+	Status   IngestStatus `form:"status" validate:"omitempty,oneof=applied partial failed"`
+	Limit    int          `form:"limit" validate:"omitempty,min=1,max=500"`
+}
+
+// IngestSupplier is a supplier configured in settings, with the last file
+// it sent. Its credentials are never returned.
+type IngestSupplier struct {
+	Name      string      `json:"name"`
+	UserEmail string      `json:"user_email"`
+	Source    string      `json:"source"`
+	Location  string      `json:"location"`
+	LastFile  *IngestFile `json:"last_file"`
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MovementImportStatus is where a stock movement import is.
+type MovementImportStatus string
+
+const (
+	MovementImportQueued    MovementImportStatus = "queued"
+	MovementImportRunning   MovementImportStatus = "running"
+	MovementImportCompleted MovementImportStatus = "completed"
+	MovementImportFailed    MovementImportStatus = "failed"
+)
+
+// MovementImportRowStatus is the outcome of one row of a stock movement
+// import file.
+type MovementImportRowStatus string
+
+const (
+	MovementRowValid   MovementImportRowStatus = "valid"
+	MovementRowInvalid MovementImportRowStatus = "invalid"
+)
+
+// MovementImportRow is what a row of a stock movement import file holds, or
+// why it cannot be imported.
+type MovementImportRow struct {
+	Line       int                     `json:"line"`
+	SKU        string                  `json:"sku"`
+	ProductID  *uuid.UUID              `json:"product_id,omitempty"`
+	Change     int                     `json:"change"`
+	Reason     MovementReason          `json:"reason,omitempty"`
+	OccurredAt *time.Time              `json:"occurred_at,omitempty"`
+	Notes      string                  `json:"notes,omitempty"`
+	Status     MovementImportRowStatus `json:"status"`
+	Error      string                  `json:"error,omitempty"`
+}
+
+// MovementImport is a CSV file of historical stock movements imported in
+// the background. Processed counts the rows done so far, for progress;
+// Results holds the rows that could not be imported.
+type MovementImport struct {
+	ID          uuid.UUID            `json:"id" db:"id"`
+	Filename    string               `json:"filename" db:"filename"`
+	Status      MovementImportStatus `json:"status" db:"status"`
+	Rows        int                  `json:"rows" db:"total_rows"`
+	Processed   int                  `json:"processed" db:"processed_rows"`
+	Imported    int                  `json:"imported" db:"imported"`
+	Invalid     int                  `json:"invalid" db:"invalid"`
+	Error       string               `json:"error,omitempty" db:"error"`
+	RequestedBy *uuid.UUID           `json:"requested_by,omitempty" db:"requested_by"`
+	CreatedAt   time.Time            `json:"created_at" db:"created_at"`
+	StartedAt   *time.Time           `json:"started_at,omitempty" db:"started_at"`
+	CompletedAt *time.Time           `json:"completed_at,omitempty" db:"completed_at"`
+	Results     []MovementImportRow  `json:"results,omitempty" db:"results"`
+}
@@ -14,27 +14,129 @@ const (
 	NotificationUser     NotificationType = "user"
 )
 
+// NotificationPriority marks alerts that must be acknowledged. Critical
+// notifications left unacknowledged are escalated to admins.
+type NotificationPriority string
+
+const (
+	PriorityNormal   NotificationPriority = "normal"
+	PriorityCritical NotificationPriority = "critical"
+)
+
+// NotificationAction is the action a client should offer for a notification.
+type NotificationAction string
+
+const (
+	ActionViewProduct         NotificationAction = "view_product"
+	ActionCreatePurchaseOrder NotificationAction = "create_purchase_order"
+	ActionAcknowledge         NotificationAction = "acknowledge"
+)
+
+// NotificationMetadata links a notification to the entity it is about so
+// clients can render buttons such as "View product" without parsing Message.
+type NotificationMetadata struct {
+	EntityType string             `json:"entity_type,omitempty" db:"entity_type"`
+	EntityID   *uuid.UUID         `json:"entity_id,omitempty" db:"entity_id"`
+	Action     NotificationAction `json:"action,omitempty" db:"action"`
+	Link       string             `json:"link,omitempty" db:"link"`
+}
+
+type EmailDeliveryStatus string
+
+const (
+	EmailStatusNone    EmailDeliveryStatus = "none"
+	EmailStatusPending EmailDeliveryStatus = "pending"
+	EmailStatusSent    EmailDeliveryStatus = "sent"
+	EmailStatusFailed  EmailDeliveryStatus = "failed"
+)
+
 type Notification struct {
-	ID        uuid.UUID         `json:"id" db:"id"`
-	UserID    uuid.UUID         `json:"user_id" db:"user_id"`
-	Message   string            `json:"message" db:"message" validate:"required"`
-	Type      NotificationType  `json:"type" db:"type" validate:"required"`
-	IsRead    bool              `json:"is_read" db:"is_read"`
-	CreatedAt time.Time         `json:"created_at" db:"created_at"`
+	ID          uuid.UUID           `json:"id" db:"id"`
+	UserID      uuid.UUID           `json:"user_id" db:"user_id"`
+	Message     string              `json:"message" db:"message" validate:"required"`
+	Type        NotificationType    `json:"type" db:"type" validate:"required"`
+	IsRead      bool                `json:"is_read" db:"is_read"`
+	IsArchived  bool                `json:"is_archived" db:"is_archived"`
+	CreatedAt   time.Time           `json:"created_at" db:"created_at"`
+	EmailStatus EmailDeliveryStatus `json:"email_status" db:"email_status"`
+	EmailSentAt *time.Time          `json:"email_sent_at,omitempty" db:"email_sent_at"`
+	EmailError  string              `json:"email_error,omitempty" db:"email_error"`
+	ScheduledAt *time.Time          `json:"scheduled_at,omitempty" db:"scheduled_at"`
+	DeliveredAt *time.Time          `json:"delivered_at,omitempty" db:"delivered_at"`
+
+	Priority       NotificationPriority `json:"priority" db:"priority"`
+	AcknowledgedAt *time.Time           `json:"acknowledged_at,omitempty" db:"acknowledged_at"`
+	AcknowledgedBy *uuid.UUID           `json:"acknowledged_by,omitempty" db:"acknowledged_by"`
+	EscalatedAt    *time.Time           `json:"escalated_at,omitempty" db:"escalated_at"`
+
+	Metadata *NotificationMetadata `json:"metadata,omitempty"`
+}
+
+// IsScheduled reports whether the notification is due at a later time.
+func (n *Notification) IsScheduled() bool {
+	return n.ScheduledAt != nil && n.ScheduledAt.After(time.Now())
+}
+
+type NotificationPreference struct {
+	Type         NotificationType `json:"type" db:"type" validate:"required,oneof=low_stock system user"`
+	EmailEnabled bool             `json:"email_enabled" db:"email_enabled"`
+}
+
+type UpdateNotificationPreferencesRequest struct {
+	Preferences []NotificationPreference `json:"preferences" validate:"required,dive"`
 }
 
 type CreateNotificationRequest struct {
-	UserID  uuid.UUID        `json:"user_id" validate:"required"`
-	Message string           `json:"message" validate:"required"`
-	Type    NotificationType `json:"type" validate:"required"`
+	UserID      uuid.UUID             `json:"user_id" validate:"required"`
+	Message     string                `json:"message" validate:"required"`
+	Type        NotificationType      `json:"type" validate:"required"`
+	ScheduledAt *time.Time            `json:"scheduled_at,omitempty"`
+	Priority    NotificationPriority  `json:"priority,omitempty" validate:"omitempty,oneof=normal critical"`
+	Metadata    *NotificationMetadata `json:"metadata,omitempty"`
 }
 
 type NotificationFilter struct {
-	UserID    *uuid.UUID        `form:"user_id"`
-	Type      *NotificationType `form:"type"`
-	IsRead    *bool             `form:"is_read"`
-	Page      int               `form:"page"`
-	Limit     int               `form:"limit"`
-	SortBy    string            `form:"sort_by"`
-	SortOrder string            `form:"sort_order"`
-}
\ No newline at end of file
+	UserID     *uuid.UUID        `form:"user_id"`
+	Type       *NotificationType `form:"type"`
+	IsRead     *bool             `form:"is_read"`
+	IsArchived *bool             `form:"archived"`
+	Page       int               `form:"page"`
+	Limit      int               `form:"limit"`
+	SortBy     string            `form:"sort_by"`
+	SortOrder  string            `form:"sort_order"`
+}
+
+type BulkNotificationAction string
+
+const (
+	BulkActionMarkRead BulkNotificationAction = "mark_read"
+	BulkActionArchive  BulkNotificationAction = "archive"
+	BulkActionDelete   BulkNotificationAction = "delete"
+)
+
+// BulkNotificationRequest targets either an explicit list of IDs, every
+// notification matching Filter, or with All every notification the user has.
+// IDs take precedence when more than one is given. A filter without any
+// field selects nothing, so an empty filter cannot clear a whole inbox.
+type BulkNotificationRequest struct {
+	Action BulkNotificationAction  `json:"action" validate:"required,oneof=mark_read archive delete"`
+	IDs    []uuid.UUID             `json:"ids,omitempty"`
+	Filter *BulkNotificationFilter `json:"filter,omitempty"`
+	All    bool                    `json:"all,omitempty"`
+}
+
+// Selects reports whether the request names the notifications it applies to.
+func (r BulkNotificationRequest) Selects() bool {
+	return len(r.IDs) > 0 || !r.Filter.Empty() || r.All
+}
+
+type BulkNotificationFilter struct {
+	Type   *NotificationType `json:"type,omitempty"`
+	IsRead *bool             `json:"is_read,omitempty"`
+	Before *time.Time        `json:"before,omitempty"`
+}
+
+// Empty reports whether the filter has no field set.
+func (f *BulkNotificationFilter) Empty() bool {
+	return f == nil || (f.Type == nil && f.IsRead == nil && f.Before == nil)
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Notification template keys
+const (
+	TemplateLowStock           = "low_stock"
+	TemplateLowStockDigest     = "low_stock_digest"
+	TemplateCriticalEscalation = "critical_escalation"
+)
+
+type NotificationTemplate struct {
+	Key         string           `json:"key" db:"key"`
+	Type        NotificationType `json:"type" db:"type"`
+	Body        string           `json:"body" db:"body" validate:"required"`
+	Description string           `json:"description" db:"description"`
+	UpdatedBy   *uuid.UUID       `json:"updated_by,omitempty" db:"updated_by"`
+	UpdatedAt   time.Time        `json:"updated_at" db:"updated_at"`
+}
+
+type UpdateNotificationTemplateRequest struct {
+	Body string `json:"body" validate:"required"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Organization is a tenant: a subsidiary with its own users, products,
+// categories, stock movements, notifications and audit logs.
+type Organization struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name" validate:"required,min=1,max=200"`
+	Slug      string    `json:"slug" db:"slug" validate:"required,min=2,max=63"`
+	IsActive  bool      `json:"is_active" db:"is_active"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// CreateOrganizationRequest creates an organization together with its
+// first admin, who can then add everyone else.
+type CreateOrganizationRequest struct {
+	Name  string            `json:"name" validate:"required,min=1,max=200"`
+	Slug  string            `json:"slug" validate:"required,min=2,max=63"`
+	Admin OrganizationAdmin `json:"admin" validate:"required"`
+}
+
+// OrganizationAdmin is the first admin of a new organization.
+type OrganizationAdmin struct {
+	Name     string `json:"name" validate:"required,min=2,max=100"`
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,min=8"`
+}
+
+type UpdateOrganizationRequest struct {
+	Name     *string `json:"name,omitempty" validate:"omitempty,min=1,max=200"`
+	IsActive *bool   `json:"is_active,omitempty"`
+}
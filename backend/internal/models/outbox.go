@@ -0,0 +1,52 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxEventType says what an outbox event describes and so how the relay
+// publishes it.
+type OutboxEventType string
+
+const (
+	EventStockUpdated OutboxEventType = "stock_updated"
+	EventSearchIndex  OutboxEventType = "search_index"
+)
+
+// OutboxEvent is an event written in the same transaction as the change it
+// describes, waiting to be published.
+type OutboxEvent struct {
+	ID        uuid.UUID       `json:"id" db:"id"`
+	Type      OutboxEventType `json:"type" db:"type"`
+	Payload   json.RawMessage `json:"payload" db:"payload"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+	Attempts  int             `json:"attempts" db:"attempts"`
+}
+
+// StockUpdatedEvent is the payload of EventStockUpdated. It carries what
+// the relay needs for the live stock update and any low stock alert, as it
+// stood when the movement committed.
+type StockUpdatedEvent struct {
+	ProductID        uuid.UUID `json:"product_id"`
+	Name             string    `json:"name"`
+	SKU              string    `json:"sku"`
+	Stock            int       `json:"stock"`
+	MinimumThreshold int       `json:"minimum_threshold"`
+	LowStock         bool      `json:"low_stock"`
+	ChangedBy        uuid.UUID `json:"changed_by"`
+	OrganizationID   uuid.UUID `json:"organization_id"`
+}
+
+// SearchIndexEvent is the payload of EventSearchIndex. It names a row whose
+// search document is out of date; the indexer reads the row as it stands
+// when the event is published, so events applied late or twice do no harm.
+// An event with Before instead of an ID drops every document of the index
+// older than it, after a retention purge.
+type SearchIndexEvent struct {
+	Index  SearchIndex `json:"index"`
+	ID     uuid.UUID   `json:"id,omitempty"`
+	Before *time.Time  `json:"before,omitempty"`
+}
@@ -0,0 +1,8 @@
+package models
+
+// CursorQuery pages through an API v2 list: Limit items after Cursor, the
+// next_cursor of the previous page. The first page has no cursor.
+type CursorQuery struct {
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" validate:"omitempty,min=1,max=100"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Tables whose records are deleted after an undo window.
+const (
+	DeletionUsers      = "users"
+	DeletionCategories = "categories"
+	DeletionProducts   = "products"
+)
+
+// PendingDeletion is a deleted user, category or product that is removed
+// at ExecuteAt, unless the deletion is undone before then.
+type PendingDeletion struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	TableName   string     `json:"table_name" db:"table_name"`
+	RecordID    uuid.UUID  `json:"record_id" db:"record_id"`
+	RequestedBy *uuid.UUID `json:"requested_by,omitempty" db:"requested_by"`
+	RequestedAt time.Time  `json:"requested_at" db:"requested_at"`
+	ExecuteAt   time.Time  `json:"execute_at" db:"execute_at"`
+
+	// RestoreActive is whether a user was active before their deletion,
+	// and is reactivated if it is undone.
+	RestoreActive  bool      `json:"-" db:"restore_active"`
+	OrganizationID uuid.UUID `json:"-" db:"organization_id"`
+}
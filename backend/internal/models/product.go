@@ -17,6 +17,7 @@ type Product struct {
 	SupplierInfo     interface{} `json:"supplier_info" db:"supplier_info"`
 	CreatedAt        time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt        time.Time `json:"updated_at" db:"updated_at"`
+	OrganizationID   uuid.UUID `json:"organization_id" db:"organization_id"`
 }
 
 type CreateProductRequest struct {
@@ -51,4 +52,17 @@ type ProductFilter struct {
 	Limit        int    `form:"limit"`
 	SortBy       string `form:"sort_by"`
 	SortOrder    string `form:"sort_order"`
-}
\ No newline at end of file
+}
+// CategoryRef is a product's category in API v2. ID is nil when no category
+// has the product's category name.
+type CategoryRef struct {
+	ID   *uuid.UUID `json:"id"`
+	Name string     `json:"name"`
+}
+
+// ProductV2 is a product as API v2 answers with it: the fields of Product,
+// with the category as an object rather than its name.
+type ProductV2 struct {
+	Product
+	Category CategoryRef `json:"category"`
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ReportStatus string
+
+const (
+	ReportQueued    ReportStatus = "queued"
+	ReportRunning   ReportStatus = "running"
+	ReportCompleted ReportStatus = "completed"
+	ReportFailed    ReportStatus = "failed"
+)
+
+// Report is a generated report artifact and the request that produced it.
+type Report struct {
+	ID          uuid.UUID       `json:"id" db:"id"`
+	Type        string          `json:"type" db:"type"`
+	Format      string          `json:"format" db:"format"`
+	Status      ReportStatus    `json:"status" db:"status"`
+	Parameters  json.RawMessage `json:"parameters" db:"parameters"`
+	StorageKey  string          `json:"-" db:"storage_key"`
+	Filename    string          `json:"filename,omitempty" db:"filename"`
+	Size        int64           `json:"size" db:"size"`
+	RowCount    int             `json:"row_count" db:"row_count"`
+	Error       string          `json:"error,omitempty" db:"error"`
+	RequestedBy *uuid.UUID      `json:"requested_by,omitempty" db:"requested_by"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+	StartedAt   *time.Time      `json:"started_at,omitempty" db:"started_at"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty" db:"completed_at"`
+	DownloadURL string          `json:"download_url,omitempty"`
+}
+
+// ReportSchedule generates a report on a cron schedule and emails it to
+// Recipients. Report holds the report parameters (type, format and filters).
+type ReportSchedule struct {
+	ID             uuid.UUID       `json:"id" db:"id"`
+	Name           string          `json:"name" db:"name"`
+	Report         json.RawMessage `json:"report" db:"parameters"`
+	CronExpression string          `json:"cron_expression" db:"cron_expression"`
+	Recipients     []string        `json:"recipients" db:"recipients"`
+	IsActive       bool            `json:"is_active" db:"is_active"`
+	NextRunAt      *time.Time      `json:"next_run_at,omitempty" db:"next_run_at"`
+	LastRunAt      *time.Time      `json:"last_run_at,omitempty" db:"last_run_at"`
+	LastReportID   *uuid.UUID      `json:"last_report_id,omitempty" db:"last_report_id"`
+	CreatedBy      *uuid.UUID      `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+type CreateReportScheduleRequest struct {
+	Name           string          `json:"name" validate:"required,max=200"`
+	Report         json.RawMessage `json:"report" validate:"required"`
+	CronExpression string          `json:"cron_expression" validate:"required"`
+	Recipients     []string        `json:"recipients" validate:"required,min=1,dive,email"`
+	IsActive       *bool           `json:"is_active,omitempty"`
+}
+
+type UpdateReportScheduleRequest struct {
+	Name           *string         `json:"name,omitempty" validate:"omitempty,max=200"`
+	Report         json.RawMessage `json:"report,omitempty"`
+	CronExpression *string         `json:"cron_expression,omitempty"`
+	Recipients     []string        `json:"recipients,omitempty" validate:"omitempty,min=1,dive,email"`
+	IsActive       *bool           `json:"is_active,omitempty"`
+}
+
+// ReportOutput holds the query parameters shared by every report endpoint.
+type ReportOutput struct {
+	Format string `form:"format"`
+	Layout string `form:"layout"`
+	Locale string `form:"locale"`
+}
+
+type InventoryReportFilter struct {
+	ReportOutput
+	StartDate string `form:"start_date"`
+	EndDate   string `form:"end_date"`
+	Category  string `form:"category"`
+	CompareTo string `form:"compare_to"`
+	Limit     int    `form:"limit"`
+}
+
+type MovementReportFilter struct {
+	ReportOutput
+	StartDate string `form:"start_date"`
+	EndDate   string `form:"end_date"`
+	ProductID string `form:"product_id"`
+	Reason    string `form:"reason"`
+	GroupBy   string `form:"group_by"`
+	CompareTo string `form:"compare_to"`
+	Limit     int    `form:"limit"`
+}
+
+type UserReportFilter struct {
+	ReportOutput
+	StartDate string `form:"start_date"`
+	EndDate   string `form:"end_date"`
+	UserID    string `form:"user_id"`
+	Limit     int    `form:"limit"`
+}
+
+type FinancialReportFilter struct {
+	ReportOutput
+	StartDate string `form:"start_date"`
+	EndDate   string `form:"end_date"`
+	Category  string `form:"category"`
+}
+
+type ShareReportRequest struct {
+	UserIDs []uuid.UUID `json:"user_ids" validate:"required,min=1"`
+}
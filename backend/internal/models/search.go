@@ -0,0 +1,39 @@
+package models
+
+import "github.com/google/uuid"
+
+// SearchIndex names a table mirrored into the search cluster.
+type SearchIndex string
+
+const (
+	SearchProducts       SearchIndex = "products"
+	SearchStockMovements SearchIndex = "stock_movements"
+	SearchAuditLogs      SearchIndex = "audit_logs"
+)
+
+// SearchIndexes are the mirrored tables, in the order they are reindexed.
+var SearchIndexes = []SearchIndex{SearchProducts, SearchStockMovements, SearchAuditLogs}
+
+// SearchProduct is the search document of a product. LowStock is set when
+// stock is at or below the minimum threshold, as for low_stock_only.
+type SearchProduct struct {
+	Product
+	LowStock bool `json:"low_stock"`
+}
+
+// SearchMovement is the search document of a stock movement. It carries
+// the product's name, SKU and category, so dashboards can group movements
+// without a join.
+type SearchMovement struct {
+	StockMovement
+	OrganizationID uuid.UUID `json:"organization_id"`
+	ProductName    string    `json:"product_name"`
+	ProductSKU     string    `json:"product_sku"`
+	Category       string    `json:"category"`
+}
+
+// SearchAuditLog is the search document of an audit entry.
+type SearchAuditLog struct {
+	AuditLog
+	OrganizationID uuid.UUID `json:"organization_id"`
+}
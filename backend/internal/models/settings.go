@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SettingChange is the value of one setting before and after a revision.
+// Old is nil when the setting was unset.
+type SettingChange struct {
+	Old *string `json:"old"`
+	New string  `json:"new"`
+}
+
+// SettingsRevision is one change to the system settings. Versions count up
+// from 1 without gaps. RollbackOf is set on revisions that undid another.
+type SettingsRevision struct {
+	ID             uuid.UUID                `json:"id" db:"id"`
+	Version        int                      `json:"version" db:"version"`
+	Changes        map[string]SettingChange `json:"changes" db:"changes"`
+	ChangedBy      *uuid.UUID               `json:"changed_by,omitempty" db:"changed_by"`
+	ChangedByName  string                   `json:"changed_by_name,omitempty"`
+	ChangedByEmail string                   `json:"changed_by_email,omitempty"`
+	RollbackOf     *uuid.UUID               `json:"rollback_of,omitempty" db:"rollback_of"`
+	CreatedAt      time.Time                `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// SheetsPush is the outcome of pushing an export to its Google Sheet.
+type SheetsPush struct {
+	Export        string `json:"export"`
+	SpreadsheetID string `json:"spreadsheet_id"`
+	Sheet         string `json:"sheet"`
+	// Range is the A1 range written, headings included.
+	Range    string    `json:"range"`
+	Rows     int       `json:"rows"`
+	PushedAt time.Time `json:"pushed_at"`
+}
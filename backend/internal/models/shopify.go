@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ShopifyStore is a Shopify store configured in settings, with the state of
+// its sync. Its access token and webhook secret are never returned.
+type ShopifyStore struct {
+	Shop          string     `json:"shop"`
+	LocationID    int64      `json:"location_id"`
+	UserEmail     string     `json:"user_email"`
+	Variants      int        `json:"variants"`
+	PendingPushes int        `json:"pending_pushes"`
+	FailedPushes  int        `json:"failed_pushes"`
+	LastOrderAt   *time.Time `json:"last_order_at"`
+}
+
+// ShopifyVariant maps a Shopify variant to the product whose stock it
+// carries.
+type ShopifyVariant struct {
+	Shop            string    `json:"shop" db:"shop"`
+	VariantID       int64     `json:"variant_id" db:"variant_id"`
+	InventoryItemID int64     `json:"inventory_item_id" db:"inventory_item_id"`
+	ProductID       uuid.UUID `json:"product_id" db:"product_id"`
+	ProductName     string    `json:"product_name,omitempty"`
+	SKU             string    `json:"sku" db:"sku"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+}
+
+// MapShopifyVariantRequest maps a variant to a product by hand, for
+// variants whose SKU does not match.
+type MapShopifyVariantRequest struct {
+	ProductID uuid.UUID `json:"product_id" validate:"required"`
+}
+
+// ShopifyVariantSync is the outcome of mapping a store's variants to
+// products by SKU. Unmatched lists the SKUs no product has; variants
+// without a SKU are not counted.
+type ShopifyVariantSync struct {
+	Variants  int      `json:"variants"`
+	Mapped    int      `json:"mapped"`
+	Unmatched []string `json:"unmatched"`
+}
+
+// ShopifyStockPush is a product's stock waiting to be set as the available
+// quantity of one of its variants' inventory items.
+type ShopifyStockPush struct {
+	Shop            string    `json:"shop" db:"shop"`
+	InventoryItemID int64     `json:"inventory_item_id" db:"inventory_item_id"`
+	ProductID       uuid.UUID `json:"product_id" db:"product_id"`
+	Available       int       `json:"available" db:"available"`
+	Attempts        int       `json:"attempts" db:"attempts"`
+	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// ShopifyOrderLine is a line item of an ingested Shopify order, with the
+// sale movement it was recorded as or why it was not.
+type ShopifyOrderLine struct {
+	Shop       string     `json:"shop" db:"shop"`
+	OrderID    int64      `json:"order_id" db:"order_id"`
+	LineItemID int64      `json:"line_item_id" db:"line_item_id"`
+	OrderName  string     `json:"order_name" db:"order_name"`
+	VariantID  *int64     `json:"variant_id" db:"variant_id"`
+	ProductID  *uuid.UUID `json:"product_id" db:"product_id"`
+	Quantity   int        `json:"quantity" db:"quantity"`
+	MovementID *uuid.UUID `json:"movement_id" db:"movement_id"`
+	Error      string     `json:"error,omitempty" db:"error"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// ShopifyOrderFilter selects the ingested order lines of a store.
+type ShopifyOrderFilter struct {
+	Limit int `form:"limit"`
+}
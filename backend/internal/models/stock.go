@@ -28,7 +28,7 @@ type StockMovement struct {
 }
 
 type CreateStockMovementRequest struct {
-	ProductID uuid.UUID      `json:"product_id" validate:"required"`
+	ProductID uuid.UUID      `json:"product_id"`                 // ignored; the product is taken from the URL
 	Change    int            `json:"change" validate:"required"` // positive for in, negative for out
 	Reason    MovementReason `json:"reason" validate:"required,oneof=purchase sale adjustment return damage transfer"`
 	Notes     string         `json:"notes"`
@@ -43,4 +43,15 @@ type StockMovementFilter struct {
 	Limit     int             `form:"limit"`
 	SortBy    string          `form:"sort_by"`
 	SortOrder string          `form:"sort_order"`
-}
\ No newline at end of file
+}
+
+// StockUpdate is the outcome of a stock movement, read while the product row
+// was locked, so concurrent movements cannot change it in between.
+type StockUpdate struct {
+	Product       Product
+	PreviousStock int
+	Movement      StockMovement
+	// LowStock is set when the product is at or below its minimum threshold
+	// after the movement.
+	LowStock bool
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TelegramChat is a Telegram chat linked to a user. Title is the chat's
+// title, or the name of the person for a private chat.
+type TelegramChat struct {
+	ChatID   int64     `json:"chat_id" db:"chat_id"`
+	UserID   uuid.UUID `json:"user_id" db:"user_id"`
+	Title    string    `json:"title" db:"title"`
+	LinkedAt time.Time `json:"linked_at" db:"linked_at"`
+}
+
+// TelegramLinkCode is a code a user sends the bot to link a chat to
+// themselves.
+type TelegramLinkCode struct {
+	Code      string    `json:"code"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -23,6 +23,11 @@ type User struct {
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 	IsActive  bool      `json:"is_active" db:"is_active"`
+	OrganizationID uuid.UUID `json:"organization_id" db:"organization_id"`
+	// Locale formats the user's reports and translates their notifications;
+	// empty means the organization default for reports and English for
+	// notifications.
+	Locale    string    `json:"locale,omitempty" db:"locale"`
 }
 
 type CreateUserRequest struct {
@@ -37,6 +42,7 @@ type UpdateUserRequest struct {
 	Email    *string   `json:"email,omitempty" validate:"omitempty,email"`
 	Role     *UserRole `json:"role,omitempty" validate:"omitempty,oneof=staff admin"`
 	IsActive *bool     `json:"is_active,omitempty"`
+	Locale   *string   `json:"locale,omitempty"`
 }
 
 type LoginRequest struct {
@@ -53,6 +59,7 @@ type RegisterRequest struct {
 type AuthResponse struct {
 	User        User   `json:"user"`
 	AccessToken string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
 	TokenType   string `json:"token_type"`
 	ExpiresIn   int    `json:"expires_in"`
 }
@@ -0,0 +1,129 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebhookEvent is an event webhooks can subscribe to.
+type WebhookEvent string
+
+const (
+	WebhookProductCreated  WebhookEvent = "product.created"
+	WebhookStockLow        WebhookEvent = "stock.low"
+	WebhookMovementCreated WebhookEvent = "movement.created"
+	WebhookUserUpdated     WebhookEvent = "user.updated"
+	// WebhookPing is sent by test-firing a webhook, whatever it subscribes to.
+	WebhookPing WebhookEvent = "ping"
+)
+
+// WebhookEvents are the events webhooks can subscribe to.
+var WebhookEvents = []WebhookEvent{WebhookProductCreated, WebhookStockLow, WebhookMovementCreated, WebhookUserUpdated}
+
+// Webhook is an endpoint that is sent the events it subscribes to. Its
+// secret signs every delivery and is only shown when created or rotated.
+type Webhook struct {
+	ID             uuid.UUID      `json:"id" db:"id"`
+	OrganizationID uuid.UUID      `json:"organization_id" db:"organization_id"`
+	URL            string         `json:"url" db:"url"`
+	Description    string         `json:"description" db:"description"`
+	Secret         string         `json:"secret,omitempty" db:"secret"`
+	Events         []WebhookEvent `json:"events" db:"events"`
+	IsActive       bool           `json:"is_active" db:"is_active"`
+	CreatedBy      *uuid.UUID     `json:"created_by" db:"created_by"`
+	CreatedAt      time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+type CreateWebhookRequest struct {
+	URL         string         `json:"url" validate:"required,http_url,max=2000"`
+	Description string         `json:"description" validate:"max=200"`
+	Events      []WebhookEvent `json:"events" validate:"required,min=1,dive,oneof=product.created stock.low movement.created user.updated"`
+}
+
+// UpdateWebhookRequest changes the fields that are set. RotateSecret
+// replaces the secret, which is then returned once.
+type UpdateWebhookRequest struct {
+	URL          *string        `json:"url,omitempty" validate:"omitempty,http_url,max=2000"`
+	Description  *string        `json:"description,omitempty" validate:"omitempty,max=200"`
+	Events       []WebhookEvent `json:"events,omitempty" validate:"omitempty,min=1,dive,oneof=product.created stock.low movement.created user.updated"`
+	IsActive     *bool          `json:"is_active,omitempty"`
+	RotateSecret bool           `json:"rotate_secret,omitempty"`
+}
+
+// WebhookPayload is the body of every delivery. A retried delivery sends
+// the same payload, so receivers can use ID to ignore repeats.
+type WebhookPayload struct {
+	ID             uuid.UUID    `json:"id"`
+	Type           WebhookEvent `json:"type"`
+	OrganizationID uuid.UUID    `json:"organization_id"`
+	CreatedAt      time.Time    `json:"created_at"`
+	Data           interface{}  `json:"data"`
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	DeliveryPending   WebhookDeliveryStatus = "pending"
+	DeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	DeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+// WebhookDelivery is an event queued for a webhook, with the outcome of its
+// latest attempt.
+type WebhookDelivery struct {
+	ID             uuid.UUID             `json:"id" db:"id"`
+	WebhookID      uuid.UUID             `json:"webhook_id" db:"webhook_id"`
+	EventID        uuid.UUID             `json:"event_id" db:"event_id"`
+	EventType      WebhookEvent          `json:"event_type" db:"event_type"`
+	Payload        json.RawMessage       `json:"payload" db:"payload"`
+	Status         WebhookDeliveryStatus `json:"status" db:"status"`
+	Attempts       int                   `json:"attempts" db:"attempts"`
+	NextAttemptAt  *time.Time            `json:"next_attempt_at" db:"next_attempt_at"`
+	ResponseStatus int                   `json:"response_status,omitempty" db:"response_status"`
+	// ResponseBody is kept for the server's operators and never returned,
+	// so webhooks cannot be used to read what an address answers.
+	ResponseBody  string     `json:"-" db:"response_body"`
+	Error         string     `json:"error,omitempty" db:"error"`
+	DurationMs    int64      `json:"duration_ms,omitempty" db:"duration_ms"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	LastAttemptAt *time.Time `json:"last_attempt_at" db:"last_attempt_at"`
+}
+
+// WebhookAttempt is the outcome of sending a delivery once.
+type WebhookAttempt struct {
+	ResponseStatus int
+	ResponseBody   string
+	Error          string
+	Duration       time.Duration
+}
+
+// Succeeded reports whether the receiver accepted the delivery.
+func (a WebhookAttempt) Succeeded() bool {
+	return a.Error == "" && a.ResponseStatus >= 200 && a.ResponseStatus < 300
+}
+
+// MovementCreatedEvent is the data of movement.created and stock.low.
+type MovementCreatedEvent struct {
+	Movement         StockMovement `json:"movement"`
+	ProductName      string        `json:"product_name"`
+	ProductSKU       string        `json:"product_sku"`
+	PreviousStock    int           `json:"previous_stock"`
+	Stock            int           `json:"stock"`
+	MinimumThreshold int           `json:"minimum_threshold"`
+}
+
+// UserUpdatedEvent is the data of user.updated. Changed names the fields
+// that were set, including password, whose value is never sent.
+type UserUpdatedEvent struct {
+	User    User     `json:"user"`
+	Changed []string `json:"changed"`
+}
+
+// WebhookDeliveryFilter selects the deliveries of a webhook's log.
+type WebhookDeliveryFilter struct {
+	Status WebhookDeliveryStatus `form:"status" validate:"omitempty,oneof=pending succeeded failed"`
+	Limit  int                   `form:"limit"`
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WooCommerceMapping maps a WooCommerce product or variation to the
+// product with its SKU. SyncedStock is the stock both sides last agreed
+// on, nil until they first do.
+type WooCommerceMapping struct {
+	Store       string     `json:"store" db:"store"`
+	WooID       int64      `json:"woo_id" db:"woo_id"`
+	ParentID    int64      `json:"parent_id" db:"parent_id"`
+	ProductID   uuid.UUID  `json:"product_id" db:"product_id"`
+	SKU         string     `json:"sku" db:"sku"`
+	SyncedStock *int       `json:"synced_stock" db:"synced_stock"`
+	SyncedAt    *time.Time `json:"synced_at" db:"synced_at"`
+}
+
+// WooCommerceResolution says how a conflict was resolved: by keeping the
+// stock of one side, or by both sides coming to agree without help.
+type WooCommerceResolution string
+
+const (
+	ResolutionKeepRTIMS       WooCommerceResolution = "rtims"
+	ResolutionKeepWooCommerce WooCommerceResolution = "woocommerce"
+	ResolutionInSync          WooCommerceResolution = "in_sync"
+)
+
+// WooCommerceConflict is a product whose stock changed both in RTIMS and
+// in WooCommerce since they last agreed, or that differed when it was
+// first mapped. It is not synced until it is resolved.
+type WooCommerceConflict struct {
+	ID          uuid.UUID              `json:"id" db:"id"`
+	Store       string                 `json:"store" db:"store"`
+	WooID       int64                  `json:"woo_id" db:"woo_id"`
+	ProductID   uuid.UUID              `json:"product_id" db:"product_id"`
+	ProductName string                 `json:"product_name,omitempty"`
+	SKU         string                 `json:"sku" db:"sku"`
+	RTIMSStock  int                    `json:"rtims_stock" db:"rtims_stock"`
+	WooStock    int                    `json:"woo_stock" db:"woo_stock"`
+	SyncedStock *int                   `json:"synced_stock" db:"synced_stock"`
+	DetectedAt  time.Time              `json:"detected_at" db:"detected_at"`
+	UpdatedAt   time.Time              `json:"updated_at" db:"updated_at"`
+	Resolution  *WooCommerceResolution `json:"resolution" db:"resolution"`
+	ResolvedBy  *uuid.UUID             `json:"resolved_by" db:"resolved_by"`
+	ResolvedAt  *time.Time             `json:"resolved_at" db:"resolved_at"`
+}
+
+// ResolveWooCommerceConflictRequest picks the side whose current stock
+// both sides get.
+type ResolveWooCommerceConflictRequest struct {
+	Keep WooCommerceResolution `json:"keep" validate:"required,oneof=rtims woocommerce"`
+}
+
+// WooCommerceRun is the outcome of reconciling one store.
+type WooCommerceRun struct {
+	ID         uuid.UUID `json:"id" db:"id"`
+	Store      string    `json:"store" db:"store"`
+	StartedAt  time.Time `json:"started_at" db:"started_at"`
+	FinishedAt time.Time `json:"finished_at" db:"finished_at"`
+	// Checked counts the WooCommerce products with a SKU that matched.
+	Checked   int    `json:"checked" db:"checked"`
+	Pushed    int    `json:"pushed" db:"pushed"`
+	Pulled    int    `json:"pulled" db:"pulled"`
+	Conflicts int    `json:"conflicts" db:"conflicts"`
+	Failed    int    `json:"failed" db:"failed"`
+	Error     string `json:"error,omitempty" db:"error"`
+}
+
+// WooCommerceStore is a WooCommerce store configured in settings, with the
+// state of its sync. Its credentials are never returned.
+type WooCommerceStore struct {
+	Name          string          `json:"name"`
+	URL           string          `json:"url"`
+	UserEmail     string          `json:"user_email"`
+	Mapped        int             `json:"mapped"`
+	OpenConflicts int             `json:"open_conflicts"`
+	LastRun       *WooCommerceRun `json:"last_run"`
+}
+
+// WooCommerceStatus is the sync state of every store, with the conflicts
+// waiting to be resolved.
+type WooCommerceStatus struct {
+	Stores    []WooCommerceStore    `json:"stores"`
+	Conflicts []WooCommerceConflict `json:"conflicts"`
+}
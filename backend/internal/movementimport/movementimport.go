@@ -0,0 +1,265 @@
+// Package movementimport reads the CSV files of historical stock movements
+// admins upload when moving from another inventory system, checks each row
+// and imports the valid ones in the background, recording its progress.
+package movementimport
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// MaxRows is the most movements one file may hold.
+const MaxRows = 10000
+
+// maxNotes is the longest note a movement may have, in characters.
+const maxNotes = 1000
+
+// Columns are the columns an import file may have. Date is when the
+// movement happened; reason is adjustment when left out or empty.
+var Columns = []string{"sku", "change", "reason", "date", "notes"}
+
+var required = []string{"sku", "change", "date"}
+
+var reasons = []models.MovementReason{models.ReasonPurchase, models.ReasonSale, models.ReasonAdjustment,
+	models.ReasonReturn, models.ReasonDamage, models.ReasonTransfer}
+
+// dateLayouts are the formats the date column is read in. Dates without a
+// zone are UTC.
+var dateLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04:05", "2006-01-02"}
+
+// Row is a movement read from an import file.
+type Row struct {
+	Line   int
+	SKU    string
+	Change string
+	Reason string
+	Date   string
+	Notes  string
+}
+
+// Summary is how many rows ended up in each status.
+type Summary struct {
+	Total   int `json:"total"`
+	Valid   int `json:"valid"`
+	Invalid int `json:"invalid"`
+}
+
+// Summarize counts results by status.
+func Summarize(results []models.MovementImportRow) Summary {
+	summary := Summary{Total: len(results)}
+	for _, result := range results {
+		switch result.Status {
+		case models.MovementRowValid:
+			summary.Valid++
+		case models.MovementRowInvalid:
+			summary.Invalid++
+		}
+	}
+	return summary
+}
+
+// Parse reads the rows of an import file. The first line names the columns,
+// in any order and case; sku, change and date are required. Errors are
+// about the file as a whole; problems with single rows are found by
+// Validate.
+func Parse(r io.Reader) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	index := map[string]int{}
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		if !contains(Columns, column) {
+			return nil, fmt.Errorf("unknown column %q, expected %s", column, strings.Join(Columns, ", "))
+		}
+		if _, ok := index[column]; ok {
+			return nil, fmt.Errorf("column %q appears twice", column)
+		}
+		index[column] = i
+	}
+	for _, column := range required {
+		if _, ok := index[column]; !ok {
+			return nil, fmt.Errorf("missing column %q", column)
+		}
+	}
+
+	cell := func(record []string, column string) string {
+		i, ok := index[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	rows := []Row{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		if len(rows) == MaxRows {
+			return nil, fmt.Errorf("file has more than %d rows", MaxRows)
+		}
+		line, _ := reader.FieldPos(0)
+		rows = append(rows, Row{
+			Line:   line,
+			SKU:    cell(record, "sku"),
+			Change: cell(record, "change"),
+			Reason: strings.ToLower(cell(record, "reason")),
+			Date:   cell(record, "date"),
+			Notes:  cell(record, "notes"),
+		})
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("file has no rows")
+	}
+	return rows, nil
+}
+
+// SKUs returns the distinct SKUs rows name.
+func SKUs(rows []Row) []string {
+	seen := map[string]bool{}
+	skus := []string{}
+	for _, row := range rows {
+		if row.SKU != "" && !seen[row.SKU] {
+			seen[row.SKU] = true
+			skus = append(skus, row.SKU)
+		}
+	}
+	return skus
+}
+
+// Validate checks each row and returns its result, MovementRowValid or
+// MovementRowInvalid. products maps the SKUs of the organization's products
+// to their ID. Movements may not be dated after now, nor before lockedUntil,
+// the end of the period last exported to accounting, if any.
+func Validate(rows []Row, products map[string]uuid.UUID, lockedUntil *time.Time, now time.Time) []models.MovementImportRow {
+	results := make([]models.MovementImportRow, len(rows))
+	for i, row := range rows {
+		result := models.MovementImportRow{
+			Line:   row.Line,
+			SKU:    row.SKU,
+			Reason: models.MovementReason(row.Reason),
+			Notes:  row.Notes,
+			Status: models.MovementRowValid,
+		}
+		if result.Reason == "" {
+			result.Reason = models.ReasonAdjustment
+		}
+
+		var problems []string
+		if id, ok := products[row.SKU]; ok {
+			result.ProductID = &id
+		} else if row.SKU == "" {
+			problems = append(problems, "sku is required")
+		} else {
+			problems = append(problems, "unknown sku "+strconv.Quote(row.SKU))
+		}
+
+		change, err := strconv.Atoi(strings.TrimPrefix(row.Change, "+"))
+		switch {
+		case err != nil:
+			problems = append(problems, "change must be a whole number")
+		case change == 0:
+			problems = append(problems, "change must not be zero")
+		}
+		result.Change = change
+
+		if !containsReason(result.Reason) {
+			problems = append(problems, "reason must be purchase, sale, adjustment, return, damage or transfer")
+		}
+
+		if occurredAt, ok := parseDate(row.Date); !ok {
+			problems = append(problems, "date must be a date such as 2024-01-31 or 2024-01-31T09:30:00Z")
+		} else if occurredAt.After(now) {
+			problems = append(problems, "date is in the future")
+		} else if lockedUntil != nil && occurredAt.Before(*lockedUntil) {
+			problems = append(problems, "date is in a period exported to accounting")
+		} else {
+			result.OccurredAt = &occurredAt
+		}
+
+		if utf8.RuneCountInString(row.Notes) > maxNotes {
+			problems = append(problems, fmt.Sprintf("notes must be at most %d characters", maxNotes))
+		}
+
+		if len(problems) > 0 {
+			result.Status = models.MovementRowInvalid
+			result.Error = strings.Join(problems, "; ")
+		}
+		results[i] = result
+	}
+	return results
+}
+
+func parseDate(value string) (time.Time, bool) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// WriteCSV writes results as the downloadable result file.
+func WriteCSV(w io.Writer, results []models.MovementImportRow) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"line", "sku", "change", "reason", "date", "status", "error"})
+	for _, result := range results {
+		date := ""
+		if result.OccurredAt != nil {
+			date = result.OccurredAt.Format(time.RFC3339)
+		}
+		writer.Write([]string{
+			strconv.Itoa(result.Line),
+			result.SKU,
+			strconv.Itoa(result.Change),
+			string(result.Reason),
+			date,
+			string(result.Status),
+			result.Error,
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func containsReason(reason models.MovementReason) bool {
+	for _, r := range reasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package movementimport
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestParse(t *testing.T) {
+	rows, err := Parse(strings.NewReader("\ufeffSKU, Date,Change,Reason\n SKU-1 ,2023-04-01,+12,Purchase\n\nSKU-2,2023-04-02T10:00:00Z,-3\n"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	want := []Row{
+		{Line: 2, SKU: "SKU-1", Change: "+12", Reason: "purchase", Date: "2023-04-01"},
+		{Line: 4, SKU: "SKU-2", Change: "-3", Date: "2023-04-02T10:00:00Z"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("expected %d rows, got %v", len(want), rows)
+	}
+	for i := range want {
+		if rows[i] != want[i] {
+			t.Errorf("row %d: expected %+v, got %+v", i, want[i], rows[i])
+		}
+	}
+
+	if skus := SKUs(append(rows, Row{SKU: "SKU-1"}, Row{})); strings.Join(skus, ",") != "SKU-1,SKU-2" {
+		t.Errorf("expected SKU-1 and SKU-2, got %v", skus)
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	tooMany := "sku,change,date\n" + strings.Repeat("SKU-1,1,2023-04-01\n", MaxRows+1)
+	for name, file := range map[string]string{
+		"empty":          "",
+		"header only":    "sku,change,date\n",
+		"missing date":   "sku,change\nSKU-1,1\n",
+		"unknown column": "sku,change,date,warehouse\nSKU-1,1,2023-04-01,north\n",
+		"repeated":       "sku,change,date,sku\nSKU-1,1,2023-04-01,SKU-1\n",
+		"too many rows":  tooMany,
+	} {
+		if _, err := Parse(strings.NewReader(file)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	product := uuid.New()
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	lockedUntil := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := []Row{
+		{Line: 2, SKU: "SKU-1", Change: "+12", Reason: "purchase", Date: "2023-04-01"},
+		{Line: 3, SKU: "SKU-1", Change: "-3", Date: "2023-04-02 10:30"},
+		{Line: 4, SKU: "SKU-9", Change: "0", Reason: "theft", Date: "yesterday"},
+		{Line: 5, SKU: "SKU-1", Change: "1.5", Date: "2022-12-31"},
+		{Line: 6, Change: "4", Date: "2024-06-02"},
+	}
+	results := Validate(rows, map[string]uuid.UUID{"SKU-1": product}, &lockedUntil, now)
+
+	if results[0].Status != models.MovementRowValid || *results[0].ProductID != product || results[0].Change != 12 ||
+		results[0].Reason != models.ReasonPurchase || !results[0].OccurredAt.Equal(time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected a valid purchase of 12, got %+v", results[0])
+	}
+	if results[1].Status != models.MovementRowValid || results[1].Change != -3 || results[1].Reason != models.ReasonAdjustment {
+		t.Errorf("expected a valid adjustment of -3, got %+v", results[1])
+	}
+	for i, problem := range map[int]string{
+		2: "unknown sku \"SKU-9\"; change must not be zero; reason must be purchase, sale, adjustment, return, damage or transfer; " +
+			"date must be a date such as 2024-01-31 or 2024-01-31T09:30:00Z",
+		3: "change must be a whole number; date is in a period exported to accounting",
+		4: "sku is required; date is in the future",
+	} {
+		if results[i].Status != models.MovementRowInvalid || results[i].Error != problem {
+			t.Errorf("line %d: expected %q, got %+v", rows[i].Line, problem, results[i])
+		}
+	}
+
+	summary := Summarize(results)
+	if summary != (Summary{Total: 5, Valid: 2, Invalid: 3}) {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	occurredAt := time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	err := WriteCSV(&buf, []models.MovementImportRow{
+		{Line: 2, SKU: "SKU-1", Change: 12, Reason: models.ReasonPurchase, OccurredAt: &occurredAt, Status: models.MovementRowValid},
+		{Line: 3, SKU: "SKU-9", Reason: models.ReasonAdjustment, Status: models.MovementRowInvalid, Error: "unknown sku \"SKU-9\"; change must be a whole number"},
+	})
+	if err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+	want := "line,sku,change,reason,date,status,error\n" +
+		"2,SKU-1,12,purchase,2023-04-01T00:00:00Z,valid,\n" +
+		"3,SKU-9,0,adjustment,,invalid,\"unknown sku \"\"SKU-9\"\"; change must be a whole number\"\n"
+	if buf.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, buf.String())
+	}
+}
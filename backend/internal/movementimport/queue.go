@@ -0,0 +1,131 @@
+package movementimport
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// queueSize bounds how many imports can wait for the worker.
+const queueSize = 20
+
+// importTimeout bounds how long one import may take.
+const importTimeout = 10 * time.Minute
+
+var ErrQueueFull = errors.New("movement import queue is full, try again later")
+
+type job struct {
+	id           uuid.UUID
+	organization uuid.UUID
+	movements    []models.StockMovement
+	// invalid are the rows that are not imported
+	invalid []models.MovementImportRow
+}
+
+// Queue imports files on a background worker, one at a time, recording
+// their progress in the stock_movement_imports table.
+type Queue struct {
+	importService *database.MovementImportService
+	pending       chan job
+}
+
+// NewQueue starts the worker. Imports left unfinished by a previous run are
+// marked failed.
+func NewQueue(db *sql.DB) *Queue {
+	q := &Queue{
+		importService: database.NewMovementImportService(db),
+		pending:       make(chan job, queueSize),
+	}
+
+	if n, err := q.importService.FailInterruptedMovementImports(context.Background()); err != nil {
+		log.Printf("Failed to clean up interrupted movement imports: %v", err)
+	} else if n > 0 {
+		log.Printf("Marked %d interrupted movement imports as failed", n)
+	}
+
+	go q.worker()
+	return q
+}
+
+// Enqueue records an import of a file in the organization of ctx and
+// schedules its valid rows. results are the rows of the file as Validate
+// returned them; checksum identifies its content. It does not block when
+// the queue is full.
+func (q *Queue) Enqueue(ctx context.Context, filename, checksum string, results []models.MovementImportRow, requestedBy uuid.UUID) (*models.MovementImport, error) {
+	j := job{invalid: []models.MovementImportRow{}}
+	for _, result := range results {
+		if result.Status != models.MovementRowValid {
+			j.invalid = append(j.invalid, result)
+			continue
+		}
+		j.movements = append(j.movements, models.StockMovement{
+			ID:        uuid.New(),
+			ProductID: *result.ProductID,
+			Change:    result.Change,
+			Reason:    result.Reason,
+			CreatedBy: requestedBy,
+			CreatedAt: *result.OccurredAt,
+			Notes:     result.Notes,
+		})
+	}
+
+	movementImport := &models.MovementImport{
+		Filename:    filename,
+		Rows:        len(results),
+		Processed:   len(j.invalid),
+		Invalid:     len(j.invalid),
+		RequestedBy: &requestedBy,
+	}
+	if err := q.importService.CreateMovementImport(ctx, movementImport, checksum); err != nil {
+		return nil, err
+	}
+	j.id = movementImport.ID
+	j.organization, _ = database.OrganizationFrom(ctx)
+
+	select {
+	case q.pending <- j:
+		return movementImport, nil
+	default:
+		if err := q.importService.MarkMovementImportFailed(ctx, j.id, ErrQueueFull.Error()); err != nil {
+			log.Printf("Failed to mark movement import %s failed: %v", j.id, err)
+		}
+		return nil, ErrQueueFull
+	}
+}
+
+func (q *Queue) worker() {
+	for j := range q.pending {
+		q.run(j)
+	}
+}
+
+func (q *Queue) run(j job) {
+	ctx, cancel := context.WithTimeout(context.Background(), importTimeout)
+	defer cancel()
+	if j.organization != uuid.Nil {
+		ctx = database.WithOrganization(ctx, j.organization)
+	}
+
+	if err := q.importService.MarkMovementImportRunning(ctx, j.id); err != nil {
+		log.Printf("Failed to mark movement import %s running: %v", j.id, err)
+	}
+
+	if err := q.importService.ImportMovements(ctx, j.id, j.movements, j.invalid); err != nil {
+		log.Printf("Movement import %s failed: %v", j.id, err)
+		// The import's own deadline may be what failed it
+		failCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := q.importService.MarkMovementImportFailed(failCtx, j.id, err.Error()); err != nil {
+			log.Printf("Failed to mark movement import %s failed: %v", j.id, err)
+		}
+		return
+	}
+	log.Printf("Movement import %s imported %d movements, %d rows invalid", j.id, len(j.movements), len(j.invalid))
+}
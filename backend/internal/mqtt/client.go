@@ -0,0 +1,180 @@
+package mqtt
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+// ioTimeout bounds subscribing and disconnecting, and how long a ping may
+// go unanswered.
+const ioTimeout = 10 * time.Second
+
+// Message is a message published on a topic subscribed to.
+type Message struct {
+	Topic   string
+	Payload []byte
+	// Retained is set on the last message the broker kept for a topic,
+	// sent as it is subscribed to.
+	Retained bool
+}
+
+// Conn is a connection to an MQTT 3.1.1 broker with a clean session, with
+// github.com/eclipse/paho.mqtt.golang. It is not reconnected once lost.
+// Subscribe and Next must be called from one goroutine; Close may be
+// called from any.
+type Conn struct {
+	client paho.Client
+
+	// queued are the messages Next has yet to return. Paho hands them over
+	// as they arrive, including before Subscribe returns, and must not be
+	// held up by a slow reader.
+	mu       sync.Mutex
+	queued   []Message
+	lost     error
+	arrived  chan struct{}
+	lostOnce sync.Once
+}
+
+// Dial connects to the broker at rawURL, mqtt://host[:1883] or
+// mqtts://host[:8883], and logs in. The broker drops the connection if it
+// hears nothing for keepAlive, so the connection pings it when idle.
+func Dial(ctx context.Context, rawURL, clientID, username, password string, keepAlive time.Duration) (*Conn, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return nil, errors.New("invalid MQTT broker URL")
+	}
+
+	opts := paho.NewClientOptions().
+		SetClientID(clientID).
+		SetUsername(username).
+		SetPassword(password).
+		SetProtocolVersion(4).
+		SetCleanSession(true).
+		SetKeepAlive(keepAlive).
+		SetPingTimeout(ioTimeout).
+		SetAutoReconnect(false)
+	switch parsed.Scheme {
+	case "mqtt":
+		opts.AddBroker("tcp://" + hostPort(parsed, "1883"))
+	case "mqtts":
+		opts.AddBroker("ssl://" + hostPort(parsed, "8883"))
+		opts.SetTLSConfig(&tls.Config{ServerName: parsed.Hostname(), MinVersion: tls.VersionTLS12})
+	default:
+		return nil, fmt.Errorf("MQTT broker URL scheme must be mqtt or mqtts, not %q", parsed.Scheme)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		opts.SetConnectTimeout(time.Until(deadline))
+	}
+
+	c := &Conn{arrived: make(chan struct{}, 1)}
+	opts.SetDefaultPublishHandler(c.receive)
+	opts.SetConnectionLostHandler(func(_ paho.Client, err error) {
+		c.fail(err)
+	})
+	c.client = paho.NewClient(opts)
+
+	token := c.client.Connect()
+	select {
+	case <-token.Done():
+	case <-ctx.Done():
+		c.client.Disconnect(0)
+		return nil, fmt.Errorf("failed to connect to MQTT broker: %w", ctx.Err())
+	}
+	if err := token.Error(); err != nil {
+		return nil, fmt.Errorf("MQTT broker refused the connection: %w", err)
+	}
+	return c, nil
+}
+
+func hostPort(u *url.URL, defaultPort string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	return net.JoinHostPort(u.Hostname(), defaultPort)
+}
+
+// Subscribe subscribes to topics at QoS 1 and waits for the broker to
+// accept them.
+func (c *Conn) Subscribe(topics []string) error {
+	filters := make(map[string]byte, len(topics))
+	for _, topic := range topics {
+		filters[topic] = 1
+	}
+	token := c.client.SubscribeMultiple(filters, c.receive)
+	if !token.WaitTimeout(ioTimeout) {
+		return errors.New("failed to subscribe: MQTT broker did not answer")
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("failed to subscribe: %w", err)
+	}
+	if subscribe, ok := token.(*paho.SubscribeToken); ok {
+		for _, topic := range topics {
+			if subscribe.Result()[topic] == 0x80 {
+				return fmt.Errorf("MQTT broker refused the subscription to %s", topic)
+			}
+		}
+	}
+	return nil
+}
+
+// receive queues a message for Next. Paho acknowledges it once this
+// returns.
+func (c *Conn) receive(_ paho.Client, message paho.Message) {
+	c.mu.Lock()
+	c.queued = append(c.queued, Message{Topic: message.Topic(), Payload: message.Payload(), Retained: message.Retained()})
+	c.mu.Unlock()
+	c.signal()
+}
+
+// fail records why the connection was lost, for Next to return once the
+// messages that came before are read.
+func (c *Conn) fail(err error) {
+	c.lostOnce.Do(func() {
+		c.mu.Lock()
+		c.lost = err
+		c.mu.Unlock()
+		c.signal()
+	})
+}
+
+func (c *Conn) signal() {
+	select {
+	case c.arrived <- struct{}{}:
+	default:
+	}
+}
+
+// Next waits for the next message. It fails once the connection is lost
+// or the broker stops answering pings.
+func (c *Conn) Next() (Message, error) {
+	for {
+		c.mu.Lock()
+		if len(c.queued) > 0 {
+			message := c.queued[0]
+			c.queued = c.queued[1:]
+			c.mu.Unlock()
+			return message, nil
+		}
+		lost := c.lost
+		c.mu.Unlock()
+		if lost != nil {
+			return Message{}, lost
+		}
+		<-c.arrived
+	}
+}
+
+// Close disconnects from the broker.
+func (c *Conn) Close() error {
+	c.client.Disconnect(uint(ioTimeout / time.Millisecond))
+	c.fail(errors.New("MQTT connection closed"))
+	return nil
+}
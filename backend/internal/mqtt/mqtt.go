@@ -0,0 +1,256 @@
+// Package mqtt turns the readings of IoT scales and smart shelves into
+// stock movements. It subscribes to the topics of the devices in settings
+// on the broker at MQTT_URL, converts each weight or count reading into a
+// number of units of the device's product, and once a new number has held
+// for the device's debounce time and differs from the last one recorded by
+// at least its threshold, records the difference as an adjustment.
+package mqtt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// SettingDevices holds a JSON object of the devices whose readings are
+// applied, by a name of lowercase letters, digits, hyphens and
+// underscores, e.g. {"shelf-a1": {"topic": "shelves/a1/weight", "sku":
+// "WID-1", "user_email": "shelves@example.com", "reading": "weight",
+// "unit_weight": 0.25}}. Each device moves the stock of the product with
+// its SKU in the organization of its user, who the movements are recorded
+// by.
+const SettingDevices = "mqtt_devices"
+
+// Defaults of the device settings left out.
+const (
+	defaultThreshold = 1
+	defaultDebounce  = 5
+	maxDebounce      = 3600
+)
+
+var deviceName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,99}$`)
+
+// ReadingType is what a device measures.
+type ReadingType string
+
+const (
+	// ReadingCount is a number of units, from a counting shelf.
+	ReadingCount ReadingType = "count"
+	// ReadingWeight is the weight on a scale, in the unit of the device's
+	// UnitWeight and Tare.
+	ReadingWeight ReadingType = "weight"
+)
+
+// Device is the profile of one scale or shelf. Its readings are the
+// payloads published on Topic: a bare number, or a JSON object with the
+// number at Field, a dotted path that is "value" unless set. Weights are
+// converted to units by subtracting Tare and dividing by UnitWeight.
+// Changes smaller than Threshold units, 1 unless set, are not recorded,
+// nor readings that have held for less than DebounceSeconds, 5 unless
+// set.
+type Device struct {
+	Name            string      `json:"-"`
+	Topic           string      `json:"topic"`
+	SKU             string      `json:"sku"`
+	UserEmail       string      `json:"user_email"`
+	Reading         ReadingType `json:"reading,omitempty"`
+	Field           string      `json:"field,omitempty"`
+	UnitWeight      float64     `json:"unit_weight,omitempty"`
+	Tare            float64     `json:"tare,omitempty"`
+	Threshold       int         `json:"threshold,omitempty"`
+	DebounceSeconds int         `json:"debounce_seconds,omitempty"`
+}
+
+// ParseDevices parses the SettingDevices value, checking that every
+// profile is complete and that no two devices share a topic.
+func ParseDevices(value string) (map[string]Device, error) {
+	devices := map[string]Device{}
+	if strings.TrimSpace(value) == "" {
+		return devices, nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&devices); err != nil {
+		return nil, errors.New("must be an object mapping device names to device profiles")
+	}
+
+	names := make([]string, 0, len(devices))
+	for name := range devices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	topics := map[string]string{}
+	for _, name := range names {
+		device := devices[name]
+		device.Name = name
+		device.UserEmail = strings.ToLower(device.UserEmail)
+		if device.Reading == "" {
+			device.Reading = ReadingCount
+		}
+		if device.Threshold == 0 {
+			device.Threshold = defaultThreshold
+		}
+		if device.DebounceSeconds == 0 {
+			device.DebounceSeconds = defaultDebounce
+		}
+		if !deviceName.MatchString(name) {
+			return nil, fmt.Errorf("%q is not a device name of lowercase letters, digits, hyphens and underscores", name)
+		}
+		if err := device.check(); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if other, ok := topics[device.Topic]; ok {
+			return nil, fmt.Errorf("%s: topic %s is already the topic of %s", name, device.Topic, other)
+		}
+		topics[device.Topic] = name
+		devices[name] = device
+	}
+	return devices, nil
+}
+
+func (d Device) check() error {
+	if d.Topic == "" || len(d.Topic) > 65535 || !utf8.ValidString(d.Topic) || strings.ContainsAny(d.Topic, "+#\x00") {
+		return errors.New("topic must be a topic name without wildcards")
+	}
+	if d.SKU == "" {
+		return errors.New("needs the sku of the product it measures")
+	}
+	if _, err := mail.ParseAddress(d.UserEmail); err != nil {
+		return errors.New("needs the user_email of the account it acts as")
+	}
+	switch d.Reading {
+	case ReadingCount:
+	case ReadingWeight:
+		if !(d.UnitWeight > 0) || math.IsInf(d.UnitWeight, 0) {
+			return errors.New("unit_weight must be above 0 for weight readings")
+		}
+		if d.Tare < 0 || math.IsInf(d.Tare, 0) {
+			return errors.New("tare must be at least 0")
+		}
+	default:
+		return errors.New("reading must be count or weight")
+	}
+	if d.Threshold < 1 {
+		return errors.New("threshold must be at least 1")
+	}
+	if d.DebounceSeconds < 0 || d.DebounceSeconds > maxDebounce {
+		return fmt.Errorf("debounce_seconds must be from 0 to %d", maxDebounce)
+	}
+	return nil
+}
+
+// Units converts a reading of the device into a number of units.
+func (d Device) Units(payload []byte) (int, error) {
+	value, err := readingValue(payload, d.Field)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, errors.New("reading is not a finite number")
+	}
+	if d.Reading == ReadingWeight {
+		value = (value - d.Tare) / d.UnitWeight
+	}
+	units := math.Round(value)
+	if units < 0 || units > math.MaxInt32 {
+		return 0, fmt.Errorf("reading %g is not a plausible number of units", value)
+	}
+	return int(units), nil
+}
+
+// readingValue returns the number a payload carries: the payload itself,
+// or the value at field of a JSON object.
+func readingValue(payload []byte, field string) (float64, error) {
+	payload = bytes.TrimSpace(payload)
+	if field == "" {
+		if value, err := strconv.ParseFloat(string(payload), 64); err == nil {
+			return value, nil
+		}
+		field = "value"
+	}
+
+	var object map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&object); err != nil {
+		return 0, errors.New("payload is neither a number nor a JSON object")
+	}
+	var value interface{} = object
+	for _, key := range strings.Split(field, ".") {
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return 0, fmt.Errorf("payload has no %s", field)
+		}
+		if value, ok = fields[key]; !ok {
+			return 0, fmt.Errorf("payload has no %s", field)
+		}
+	}
+	switch value := value.(type) {
+	case json.Number:
+		return value.Float64()
+	case string:
+		// Some devices send numbers as strings
+		if number, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+			return number, nil
+		}
+	}
+	return 0, fmt.Errorf("%s is not a number", field)
+}
+
+// shelf follows the readings of one device.
+type shelf struct {
+	device Device
+
+	// recorded is the number of units last recorded, nil until the first
+	// reading settles, which is taken as the starting point.
+	recorded *int
+	// pending is the latest number of units, set since pendingSince,
+	// while it has not settled.
+	pending      *int
+	pendingSince time.Time
+	// lastError is the error of the last reading, logged once until it
+	// changes.
+	lastError string
+}
+
+// observe takes in a reading of units made at now.
+func (s *shelf) observe(units int, now time.Time) {
+	if s.pending != nil && *s.pending == units {
+		return
+	}
+	s.pending = &units
+	s.pendingSince = now
+}
+
+// settle returns the change to record, if the pending reading has held for
+// the debounce time and moved at least the threshold from the last one
+// recorded. A change is taken as recorded once returned.
+func (s *shelf) settle(now time.Time) (int, bool) {
+	if s.pending == nil || now.Sub(s.pendingSince) < time.Duration(s.device.DebounceSeconds)*time.Second {
+		return 0, false
+	}
+	units := *s.pending
+	s.pending = nil
+	if s.recorded == nil {
+		s.recorded = &units
+		return 0, false
+	}
+
+	change := units - *s.recorded
+	// Smaller changes add up until they reach the threshold
+	if change < s.device.Threshold && -change < s.device.Threshold {
+		return 0, false
+	}
+	s.recorded = &units
+	return change, true
+}
@@ -0,0 +1,270 @@
+package mqtt
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+func TestParseDevices(t *testing.T) {
+	devices, err := ParseDevices(`{"shelf-a1": {"topic": "shelves/a1", "sku": "WID-1", "user_email": "Shelves@Example.com",
+		"reading": "weight", "unit_weight": 0.25, "tare": 1.5}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shelf := devices["shelf-a1"]
+	if shelf.Name != "shelf-a1" || shelf.UserEmail != "shelves@example.com" || shelf.Threshold != 1 || shelf.DebounceSeconds != 5 {
+		t.Errorf("unexpected device %+v", shelf)
+	}
+
+	if devices, err := ParseDevices(""); err != nil || len(devices) != 0 {
+		t.Errorf("expected no devices for an empty value, got %v, %v", devices, err)
+	}
+
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"not an object", `["shelf"]`, "must be an object"},
+		{"bad name", `{"Shelf A1": {"topic": "a", "sku": "W", "user_email": "a@b.c"}}`, "not a device name"},
+		{"wildcard", `{"a1": {"topic": "shelves/+", "sku": "W", "user_email": "a@b.c"}}`, "without wildcards"},
+		{"unit weight", `{"a1": {"topic": "a", "sku": "W", "user_email": "a@b.c", "reading": "weight"}}`, "unit_weight must be above 0"},
+		{"threshold", `{"a1": {"topic": "a", "sku": "W", "user_email": "a@b.c", "threshold": -2}}`, "threshold must be at least 1"},
+		{"shared topic", `{"a1": {"topic": "a", "sku": "W", "user_email": "a@b.c"}, "a2": {"topic": "a", "sku": "X", "user_email": "a@b.c"}}`, "already the topic of a1"},
+	}
+	for _, tt := range tests {
+		if _, err := ParseDevices(tt.value); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestUnits(t *testing.T) {
+	scale := Device{Reading: ReadingWeight, UnitWeight: 0.25, Tare: 1.5}
+	counter := Device{Reading: ReadingCount, Field: "data.count"}
+
+	tests := []struct {
+		device  Device
+		payload string
+		units   int
+		err     string
+	}{
+		{scale, "4.04", 10, ""},
+		{scale, `{"value": 2.5}`, 4, ""},
+		{scale, "1.2", 0, "not a plausible number"},
+		{scale, "heavy", 0, "neither a number"},
+		{counter, `{"data": {"count": "12"}}`, 12, ""},
+		{counter, `{"data": {}}`, 0, "no data.count"},
+		{counter, `{"data": {"count": true}}`, 0, "not a number"},
+	}
+	for _, tt := range tests {
+		units, err := tt.device.Units([]byte(tt.payload))
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: expected an error containing %q, got %d, %v", tt.payload, tt.err, units, err)
+			}
+			continue
+		}
+		if err != nil || units != tt.units {
+			t.Errorf("%s: expected %d units, got %d, %v", tt.payload, tt.units, units, err)
+		}
+	}
+}
+
+func TestSettle(t *testing.T) {
+	s := &shelf{device: Device{Threshold: 2, DebounceSeconds: 5}}
+	start := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	at := func(seconds int) time.Time { return start.Add(time.Duration(seconds) * time.Second) }
+
+	// The first settled reading is the starting point
+	s.observe(20, at(0))
+	if _, ok := s.settle(at(5)); ok || s.recorded == nil || *s.recorded != 20 {
+		t.Fatalf("expected 20 units as the starting point, got %v", s.recorded)
+	}
+
+	// A reading that flickers is not recorded until it holds
+	s.observe(17, at(10))
+	s.observe(16, at(12))
+	if _, ok := s.settle(at(16)); ok {
+		t.Error("expected no change before the reading held")
+	}
+	if change, ok := s.settle(at(17)); !ok || change != -4 {
+		t.Errorf("expected a change of -4, got %d, %v", change, ok)
+	}
+
+	// Changes below the threshold add up until they reach it
+	s.observe(15, at(20))
+	if _, ok := s.settle(at(30)); ok {
+		t.Error("expected a change of one unit not to be recorded")
+	}
+	s.observe(14, at(40))
+	if change, ok := s.settle(at(50)); !ok || change != -2 || *s.recorded != 14 {
+		t.Errorf("expected a change of -2, got %d, %v", change, ok)
+	}
+}
+
+// fakeBroker listens for one connection, runs broker on it and returns
+// the URL to dial.
+func fakeBroker(t *testing.T, broker func(conn net.Conn)) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		broker(conn)
+		// Hold the connection until the client drops it
+		packets.ReadPacket(conn)
+	}()
+	return "mqtt://" + listener.Addr().String()
+}
+
+// connack reads the CONNECT packet and answers it with code.
+func connack(conn net.Conn, code func(connect *packets.ConnectPacket) byte) bool {
+	packet, err := packets.ReadPacket(conn)
+	connect, ok := packet.(*packets.ConnectPacket)
+	if err != nil || !ok {
+		return false
+	}
+	ack := packets.NewControlPacket(packets.Connack).(*packets.ConnackPacket)
+	ack.ReturnCode = code(connect)
+	return ack.Write(conn) == nil && ack.ReturnCode == packets.Accepted
+}
+
+func publish(topic, payload string, qos byte, retain bool, id uint16) *packets.PublishPacket {
+	message := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+	message.TopicName = topic
+	message.Payload = []byte(payload)
+	message.Qos = qos
+	message.Retain = retain
+	message.MessageID = id
+	return message
+}
+
+func dial(t *testing.T, url, password string) (*Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := Dial(ctx, url, "rtims", "shelves", password, time.Minute)
+	if err == nil {
+		t.Cleanup(func() { conn.Close() })
+	}
+	return conn, err
+}
+
+func TestSubscribe(t *testing.T) {
+	acks := make(chan uint16, 1)
+	url := fakeBroker(t, func(conn net.Conn) {
+		accepted := connack(conn, func(connect *packets.ConnectPacket) byte {
+			if connect.ClientIdentifier != "rtims" || connect.Username != "shelves" || string(connect.Password) != "secret" || !connect.CleanSession {
+				return packets.ErrRefusedBadUsernameOrPassword
+			}
+			return packets.Accepted
+		})
+		if !accepted {
+			return
+		}
+
+		packet, err := packets.ReadPacket(conn)
+		subscribe, ok := packet.(*packets.SubscribePacket)
+		if err != nil || !ok || len(subscribe.Topics) != 2 {
+			return
+		}
+		// A retained reading can come before the SUBACK
+		if publish("shelves/a1", "12", 1, true, 7).Write(conn) != nil {
+			return
+		}
+		suback := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+		suback.MessageID = subscribe.MessageID
+		for _, qos := range subscribe.Qoss {
+			suback.ReturnCodes = append(suback.ReturnCodes, qos)
+		}
+		if suback.Write(conn) != nil {
+			return
+		}
+
+		packet, err = packets.ReadPacket(conn)
+		puback, ok := packet.(*packets.PubackPacket)
+		if err != nil || !ok {
+			return
+		}
+		acks <- puback.MessageID
+		publish("shelves/a2", `{"value": 3}`, 0, false, 0).Write(conn)
+	})
+
+	conn, err := dial(t, url, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Subscribe([]string{"shelves/a1", "shelves/a2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	message, err := conn.Next()
+	if err != nil || message.Topic != "shelves/a1" || string(message.Payload) != "12" || !message.Retained {
+		t.Fatalf("unexpected message %+v, %v", message, err)
+	}
+	select {
+	case id := <-acks:
+		if id != 7 {
+			t.Errorf("expected packet 7 to be acknowledged, got %d", id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the retained reading to be acknowledged")
+	}
+	message, err = conn.Next()
+	if err != nil || message.Topic != "shelves/a2" || string(message.Payload) != `{"value": 3}` || message.Retained {
+		t.Errorf("unexpected message %+v, %v", message, err)
+	}
+}
+
+func TestSubscribeRefused(t *testing.T) {
+	url := fakeBroker(t, func(conn net.Conn) {
+		if !connack(conn, func(*packets.ConnectPacket) byte { return packets.Accepted }) {
+			return
+		}
+		packet, err := packets.ReadPacket(conn)
+		subscribe, ok := packet.(*packets.SubscribePacket)
+		if err != nil || !ok {
+			return
+		}
+		suback := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+		suback.MessageID = subscribe.MessageID
+		suback.ReturnCodes = []byte{0x80}
+		suback.Write(conn)
+	})
+
+	conn, err := dial(t, url, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Subscribe([]string{"shelves/#"}); err == nil || !strings.Contains(err.Error(), "refused the subscription to shelves/#") {
+		t.Errorf("expected the subscription to be refused, got %v", err)
+	}
+}
+
+func TestConnectRefused(t *testing.T) {
+	url := fakeBroker(t, func(conn net.Conn) {
+		connack(conn, func(*packets.ConnectPacket) byte { return packets.ErrRefusedBadUsernameOrPassword })
+	})
+	if _, err := dial(t, url, "wrong"); err == nil || !strings.Contains(err.Error(), "bad user name or password") {
+		t.Errorf("expected the login to be refused, got %v", err)
+	}
+}
+
+func TestDialURL(t *testing.T) {
+	for _, url := range []string{"http://broker.example.com", "broker.example.com:1883"} {
+		if _, err := Dial(context.Background(), url, "rtims", "", "", time.Minute); err == nil {
+			t.Errorf("expected %s to be rejected", url)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package mqtt
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"time"
+
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// keepAlive is how long the broker waits to hear from the subscriber
+	// before dropping it.
+	keepAlive = 60 * time.Second
+	// dialTimeout bounds connecting and logging in to the broker.
+	dialTimeout = 30 * time.Second
+	// checkInterval is how often leadership and the device settings are
+	// checked, while connected or not.
+	checkInterval = 30 * time.Second
+	// Reconnecting backs off from minRetry to maxRetry.
+	minRetry = 5 * time.Second
+	maxRetry = 5 * time.Minute
+)
+
+// Options are the broker the subscriber connects to.
+type Options struct {
+	URL      string
+	ClientID string
+	Username string
+	Password string
+}
+
+// Subscriber applies the readings of the devices in settings.
+type Subscriber struct {
+	options             Options
+	settingsService     *database.SettingsService
+	userService         *database.UserService
+	organizationService *database.OrganizationService
+	productService      *database.ProductService
+
+	// shelves follow the readings of each device, by name. They outlive
+	// connections, so a reconnect does not lose what was recorded.
+	shelves map[string]*shelf
+}
+
+func NewSubscriber(db *sql.DB, cache *database.Cache, options Options) *Subscriber {
+	return &Subscriber{
+		options:             options,
+		settingsService:     database.NewSettingsService(db),
+		userService:         database.NewUserService(db),
+		organizationService: database.NewOrganizationService(db),
+		productService:      database.NewCachedProductService(db, cache),
+		shelves:             map[string]*shelf{},
+	}
+}
+
+// Devices returns the configured devices.
+func (s *Subscriber) Devices() (map[string]Device, error) {
+	settings, err := s.settingsService.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	value, _ := settings[SettingDevices].(string)
+	return ParseDevices(value)
+}
+
+// Run keeps a subscription to the topics of the configured devices while
+// leader reports this instance as the one to hold it, so readings are not
+// recorded twice by several replicas. It blocks until ctx is done, so run
+// it in its own goroutine.
+func (s *Subscriber) Run(ctx context.Context, leader func() bool) {
+	retry := minRetry
+	for ctx.Err() == nil {
+		if !leader() {
+			sleep(ctx, checkInterval)
+			continue
+		}
+		devices, err := s.Devices()
+		if err != nil {
+			log.Printf("Failed to read MQTT devices: %v", err)
+			sleep(ctx, checkInterval)
+			continue
+		}
+		if len(devices) == 0 {
+			sleep(ctx, checkInterval)
+			continue
+		}
+
+		subscribed, err := s.session(ctx, devices, leader)
+		if subscribed {
+			retry = minRetry
+		}
+		if err == nil || ctx.Err() != nil {
+			continue
+		}
+		log.Printf("MQTT subscription failed, retrying in %s: %v", retry, err)
+		sleep(ctx, retry)
+		if retry *= 2; retry > maxRetry {
+			retry = maxRetry
+		}
+	}
+}
+
+// sleep waits for d, or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// session subscribes to the topics of devices and applies their readings
+// until the connection fails, this instance stops being the leader, the
+// device settings change or ctx is done. It reports whether it got as far
+// as subscribing.
+func (s *Subscriber) session(ctx context.Context, devices map[string]Device, leader func() bool) (bool, error) {
+	dialCtx, cancel := context.WithTimeout(ctx, dialTimeout)
+	conn, err := Dial(dialCtx, s.options.URL, s.options.ClientID, s.options.Username, s.options.Password, keepAlive)
+	cancel()
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	byTopic := map[string]*shelf{}
+	topics := make([]string, 0, len(devices))
+	for name, device := range devices {
+		// A device whose profile changed starts over
+		current, ok := s.shelves[name]
+		if !ok || current.device != device {
+			current = &shelf{device: device}
+			s.shelves[name] = current
+		}
+		byTopic[device.Topic] = current
+		topics = append(topics, device.Topic)
+	}
+	for name := range s.shelves {
+		if _, ok := devices[name]; !ok {
+			delete(s.shelves, name)
+		}
+	}
+	sort.Strings(topics)
+
+	if err := conn.Subscribe(topics); err != nil {
+		return false, err
+	}
+	log.Printf("MQTT subscribed to the topics of %d devices", len(topics))
+
+	messages := make(chan Message)
+	failed := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			message, err := conn.Next()
+			if err != nil {
+				failed <- err
+				return
+			}
+			select {
+			case messages <- message:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	settleTicker := time.NewTicker(time.Second)
+	defer settleTicker.Stop()
+	checkTicker := time.NewTicker(checkInterval)
+	defer checkTicker.Stop()
+
+	for {
+		select {
+		case message := <-messages:
+			if current, ok := byTopic[message.Topic]; ok {
+				s.observe(current, message.Payload, time.Now())
+			}
+		case now := <-settleTicker.C:
+			for _, current := range byTopic {
+				if change, ok := current.settle(now); ok {
+					s.record(current.device, change, *current.recorded)
+				}
+			}
+		case <-checkTicker.C:
+			if !leader() {
+				log.Printf("MQTT subscription handed over with scheduler leadership")
+				return true, nil
+			}
+			if current, err := s.Devices(); err == nil && !reflect.DeepEqual(current, devices) {
+				log.Printf("MQTT devices changed, subscribing again")
+				return true, nil
+			}
+		case err := <-failed:
+			return true, err
+		case <-ctx.Done():
+			return true, nil
+		}
+	}
+}
+
+// observe takes in a reading of a device, logging a bad one once until the
+// device sends another error or a good reading.
+func (s *Subscriber) observe(current *shelf, payload []byte, now time.Time) {
+	units, err := current.device.Units(payload)
+	if err != nil {
+		if err.Error() != current.lastError {
+			log.Printf("Ignoring reading of MQTT device %s: %v", current.device.Name, err)
+			current.lastError = err.Error()
+		}
+		return
+	}
+	current.lastError = ""
+	current.observe(units, now)
+}
+
+// record applies a change of units counted by a device to its product as
+// an adjustment. A change that fails is logged and not retried, as the
+// next reading is compared with the units now counted.
+func (s *Subscriber) record(device Device, change, units int) {
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+	if err := s.apply(ctx, device, change, units); err != nil {
+		log.Printf("Failed to record a change of %+d units counted by MQTT device %s: %v", change, device.Name, err)
+		return
+	}
+	log.Printf("MQTT device %s counted %d units of %s, recorded a change of %+d", device.Name, units, device.SKU, change)
+}
+
+func (s *Subscriber) apply(ctx context.Context, device Device, change, units int) error {
+	ctx, userID, err := s.account(ctx, device)
+	if err != nil {
+		return err
+	}
+	products, err := s.productService.GetProductsBySKU(ctx, []string{device.SKU})
+	if err != nil {
+		return err
+	}
+	product, ok := products[device.SKU]
+	if !ok {
+		return fmt.Errorf("no product has SKU %s", device.SKU)
+	}
+
+	notes := fmt.Sprintf("MQTT device %s counted %d units", device.Name, units)
+	_, err = s.productService.UpdateProductStock(ctx, product.ID, change, models.ReasonAdjustment, userID, notes)
+	if errors.Is(err, database.ErrInsufficientStock) {
+		return errors.New("stock would go below zero")
+	}
+	return err
+}
+
+// account returns the ID of the user a device acts as, and ctx scoped to
+// the user's organization. Both must be active.
+func (s *Subscriber) account(ctx context.Context, device Device) (context.Context, uuid.UUID, error) {
+	user, err := s.userService.GetUserByEmail(ctx, device.UserEmail)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, uuid.Nil, fmt.Errorf("no account for %s", device.UserEmail)
+		}
+		return nil, uuid.Nil, err
+	}
+	if !user.IsActive {
+		return nil, uuid.Nil, fmt.Errorf("%s is deactivated", device.UserEmail)
+	}
+
+	organizationID := user.OrganizationID
+	if organizationID == uuid.Nil {
+		organizationID = database.DefaultOrganizationID
+	}
+	organization, err := s.organizationService.GetOrganization(ctx, organizationID)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+	if !organization.IsActive {
+		return nil, uuid.Nil, fmt.Errorf("organization %s is deactivated", organization.Name)
+	}
+	return database.WithOrganization(ctx, organizationID), user.ID, nil
+}
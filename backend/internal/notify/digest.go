@@ -0,0 +1,104 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// SendLowStockDigest sends one summary notification per user in roles,
+// covering their organization's products that went low on stock in the
+// previous 24 hours. It is meant to run once a day.
+func SendLowStockDigest(db *sql.DB, dispatcher *Dispatcher, roles []string) error {
+	products, err := database.NewProductService(db).GetLowStockMovedSince(context.Background(), time.Now().Add(-24*time.Hour))
+	if err != nil {
+		return err
+	}
+	if len(products) == 0 {
+		return nil
+	}
+
+	byOrganization := map[uuid.UUID][]models.Product{}
+	for _, p := range products {
+		byOrganization[p.OrganizationID] = append(byOrganization[p.OrganizationID], p)
+	}
+
+	sent := 0
+	for organizationID, products := range byOrganization {
+		n, err := sendLowStockDigest(db, dispatcher, roles, organizationID, products)
+		sent += n
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Printf("Low stock digest covering %d products sent to %d users", len(products), sent)
+	return nil
+}
+
+// sendLowStockDigest sends the digest of one organization's products to
+// its users in roles and returns how many it sent.
+func sendLowStockDigest(db *sql.DB, dispatcher *Dispatcher, roles []string, organizationID uuid.UUID, products []models.Product) (int, error) {
+	items := make([]string, len(products))
+	for i, p := range products {
+		items[i] = fmt.Sprintf("%s (%d/%d)", p.Name, p.Stock, p.MinimumThreshold)
+	}
+
+	templateService := database.NewNotificationTemplateService(db)
+	vars := map[string]interface{}{
+		"count":    len(products),
+		"products": strings.Join(items, ", "),
+	}
+	// Rendered once per language, in each recipient's locale
+	messages := map[string]string{}
+
+	userService := database.NewUserService(db)
+	notificationService := database.NewNotificationService(db)
+	ctx := database.WithOrganization(context.Background(), organizationID)
+
+	sent := 0
+	for _, role := range roles {
+		users, err := userService.GetActiveUsersByRole(ctx, models.UserRole(role))
+		if err != nil {
+			return sent, fmt.Errorf("failed to get %s users: %w", role, err)
+		}
+
+		for _, user := range users {
+			message, ok := messages[user.Locale]
+			if !ok {
+				message = templateService.RenderIn(models.TemplateLowStockDigest, user.Locale, vars, "Daily low stock summary: {{count}} product(s) need restocking: {{products}}")
+				messages[user.Locale] = message
+			}
+			notification := &models.Notification{
+				ID:        uuid.New(),
+				UserID:    user.ID,
+				Message:   message,
+				Type:      models.NotificationLowStock,
+				IsRead:    false,
+				CreatedAt: time.Now(),
+				Metadata: &models.NotificationMetadata{
+					EntityType: "product",
+					Action:     models.ActionViewProduct,
+					Link:       "/products?low_stock=true",
+				},
+			}
+
+			if err := notificationService.CreateNotification(notification); err != nil {
+				log.Printf("Failed to create low stock digest for user %s: %v", user.ID, err)
+				continue
+			}
+			dispatcher.Deliver(notification)
+			sent++
+		}
+	}
+
+	return sent, nil
+}
@@ -0,0 +1,134 @@
+package notify
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+
+	"rtims-backend/internal/alerting"
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/email"
+	"rtims-backend/internal/models"
+	"rtims-backend/internal/search"
+	"rtims-backend/internal/telegram"
+	"rtims-backend/internal/websocket"
+)
+
+// Settings keys that configure chat webhook delivery.
+const (
+	SettingSlackWebhookURL = "slack_webhook_url"
+	SettingTeamsWebhookURL = "teams_webhook_url"
+	// SettingWebhookChannels holds a JSON object mapping notification type to
+	// Slack channel, e.g. {"low_stock": "#inventory", "system": "#ops"}.
+	// Only mapped types are forwarded to Slack or Teams.
+	SettingWebhookChannels = "webhook_channels"
+)
+
+// Dispatcher fans a saved notification out to every delivery channel:
+// the WebSocket hub, email, Slack/Teams webhooks and Telegram.
+type Dispatcher struct {
+	notificationService *database.NotificationService
+	settingsService     *database.SettingsService
+	templateService     *database.NotificationTemplateService
+	userService         *database.UserService
+	hub                 *websocket.Hub
+	mailer              *email.Mailer
+	webhooks            *WebhookClient
+
+	// LowStockDigest suppresses per-movement low stock notifications in
+	// favour of the daily digest sent by SendLowStockDigest.
+	LowStockDigest bool
+
+	// Throttle, when set, suppresses duplicate low stock notifications for
+	// the same product and user within its cooldown.
+	Throttle *Throttle
+
+	// StockFeed, when set, receives every stock update the outbox relay
+	// publishes.
+	StockFeed *StockFeed
+
+	// ShopifyPushes, when set, queues every stock update the outbox relay
+	// publishes to be pushed to the Shopify variants of the product.
+	ShopifyPushes *database.ShopifyService
+
+	// Telegram, when set, sends critical notifications to the Telegram
+	// chats linked to their recipient.
+	Telegram *telegram.Bot
+
+	// Search, when set, applies the search index events the outbox relay
+	// publishes.
+	Search *search.Indexer
+
+	// Alerts, when set, raises an alert when a stock update the outbox
+	// relay publishes leaves a product out of stock.
+	Alerts *alerting.Router
+}
+
+func NewDispatcher(db *sql.DB, hub *websocket.Hub, mailer *email.Mailer) *Dispatcher {
+	return &Dispatcher{
+		notificationService: database.NewNotificationService(db),
+		settingsService:     database.NewSettingsService(db),
+		templateService:     database.NewNotificationTemplateService(db),
+		userService:         database.NewUserService(db),
+		hub:                 hub,
+		mailer:              mailer,
+		webhooks:            NewWebhookClient(),
+	}
+}
+
+// EmailEnabled reports whether the email channel is configured.
+func (d *Dispatcher) EmailEnabled() bool {
+	return d.mailer.Enabled()
+}
+
+// Deliver pushes the notification over the WebSocket immediately and hands
+// the slower email, webhook and Telegram channels to a background goroutine.
+func (d *Dispatcher) Deliver(notification *models.Notification) {
+	websocket.BroadcastNotification(d.hub, notification)
+
+	go func() {
+		d.notificationService.DeliverEmail(notification, d.mailer)
+		d.deliverWebhooks(notification)
+		if d.Telegram != nil && notification.Priority == models.PriorityCritical {
+			d.Telegram.Alert(notification)
+		}
+	}()
+}
+
+func (d *Dispatcher) deliverWebhooks(notification *models.Notification) {
+	// Chat channels are for operational alerts, not per-user messages
+	if notification.Type != models.NotificationLowStock && notification.Type != models.NotificationSystem {
+		return
+	}
+
+	settings, err := d.settingsService.GetSettings()
+	if err != nil {
+		log.Printf("Failed to load webhook settings: %v", err)
+		return
+	}
+
+	channels := map[string]string{}
+	if raw, ok := settings[SettingWebhookChannels].(string); ok && raw != "" {
+		if err := json.Unmarshal([]byte(raw), &channels); err != nil {
+			log.Printf("Invalid %s setting: %v", SettingWebhookChannels, err)
+			return
+		}
+	}
+
+	channel, mapped := channels[string(notification.Type)]
+	if !mapped {
+		return
+	}
+
+	if url, ok := settings[SettingSlackWebhookURL].(string); ok && url != "" {
+		if err := d.webhooks.SendSlack(url, channel, notification); err != nil {
+			log.Printf("Failed to deliver notification %s to Slack: %v", notification.ID, err)
+		}
+	}
+
+	if url, ok := settings[SettingTeamsWebhookURL].(string); ok && url != "" {
+		if err := d.webhooks.SendTeams(url, notification); err != nil {
+			log.Printf("Failed to deliver notification %s to Teams: %v", notification.ID, err)
+		}
+	}
+}
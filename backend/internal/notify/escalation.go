@@ -0,0 +1,111 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/i18n"
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// RunEscalation escalates critical notifications that have not been
+// acknowledged within window by notifying every active admin of the
+// recipient's organization on all channels, until ctx is done. It blocks,
+// so run it in its own goroutine.
+func RunEscalation(ctx context.Context, db *sql.DB, dispatcher *Dispatcher, window, interval time.Duration) {
+	notificationService := database.NewNotificationService(db)
+	userService := database.NewUserService(db)
+	templateService := database.NewNotificationTemplateService(db)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		alerts, err := notificationService.ClaimUnacknowledged(time.Now().Add(-window))
+		if err != nil {
+			log.Printf("Failed to claim unacknowledged alerts: %v", err)
+			continue
+		}
+		if len(alerts) == 0 {
+			continue
+		}
+
+		adminsOf := map[uuid.UUID][]models.User{}
+		for _, alert := range alerts {
+			recipient, err := userService.GetUser(context.Background(), alert.UserID)
+			if err != nil {
+				log.Printf("Failed to get the recipient of alert %s: %v", alert.ID, err)
+				continue
+			}
+			admins, ok := adminsOf[recipient.OrganizationID]
+			if !ok {
+				ctx := database.WithOrganization(context.Background(), recipient.OrganizationID)
+				if admins, err = userService.GetActiveUsersByRole(ctx, models.RoleAdmin); err != nil {
+					log.Printf("Failed to get admins for escalation: %v", err)
+					continue
+				}
+				adminsOf[recipient.OrganizationID] = admins
+			}
+
+			for _, admin := range admins {
+				message := templateService.RenderIn(models.TemplateCriticalEscalation, admin.Locale, map[string]interface{}{
+					"window":  windowText(admin.Locale, window),
+					"message": alert.Message,
+				}, "Critical alert not acknowledged within {{window}}: {{message}}")
+				notification := &models.Notification{
+					ID:        uuid.New(),
+					UserID:    admin.ID,
+					Message:   message,
+					Type:      models.NotificationSystem,
+					IsRead:    false,
+					CreatedAt: time.Now(),
+					Metadata:  alert.Metadata,
+				}
+
+				if err := notificationService.CreateNotification(notification); err != nil {
+					log.Printf("Failed to create escalation for alert %s: %v", alert.ID, err)
+					continue
+				}
+				dispatcher.Deliver(notification)
+			}
+			log.Printf("Escalated unacknowledged alert %s to %d admins", alert.ID, len(admins))
+		}
+	}
+}
+
+// windowText spells out the escalation window in whole hours and minutes,
+// e.g. "1 hour 30 minutes", in language.
+func windowText(language string, window time.Duration) string {
+	hours, minutes := int(window/time.Hour), int(window%time.Hour/time.Minute)
+
+	var parts []string
+	switch {
+	case hours == 1:
+		parts = append(parts, i18n.T(language, "1 hour"))
+	case hours > 1:
+		parts = append(parts, fmt.Sprintf(i18n.T(language, "%d hours"), hours))
+	}
+	switch {
+	case minutes == 1:
+		parts = append(parts, i18n.T(language, "1 minute"))
+	case minutes > 1:
+		parts = append(parts, fmt.Sprintf(i18n.T(language, "%d minutes"), minutes))
+	}
+	if len(parts) == 0 {
+		return window.String()
+	}
+	return strings.Join(parts, " ")
+}
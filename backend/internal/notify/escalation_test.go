@@ -0,0 +1,27 @@
+package notify
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindowText(t *testing.T) {
+	tests := []struct {
+		language string
+		window   time.Duration
+		want     string
+	}{
+		{"en", 30 * time.Minute, "30 minutes"},
+		{"en", time.Minute, "1 minute"},
+		{"en", time.Hour, "1 hour"},
+		{"en", 90 * time.Minute, "1 hour 30 minutes"},
+		{"en", 2*time.Hour + time.Minute, "2 hours 1 minute"},
+		{"id", 2*time.Hour + 15*time.Minute, "2 jam 15 menit"},
+		{"en", 30 * time.Second, "30s"},
+	}
+	for _, tt := range tests {
+		if got := windowText(tt.language, tt.window); got != tt.want {
+			t.Errorf("windowText(%q, %v) = %q, want %q", tt.language, tt.window, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package notify
+
+import (
+	"sync"
+
+	"rtims-backend/internal/models"
+)
+
+// StockFeed fans stock updates published from the outbox out to in-process
+// subscribers, such as gRPC streams. Publishing never waits on a
+// subscriber: one whose buffer is full is dropped, and its channel closed.
+type StockFeed struct {
+	mu          sync.Mutex
+	subscribers map[chan models.StockUpdatedEvent]struct{}
+}
+
+func NewStockFeed() *StockFeed {
+	return &StockFeed{subscribers: map[chan models.StockUpdatedEvent]struct{}{}}
+}
+
+// Subscribe returns a channel of stock updates buffering up to buffer of
+// them, and a function that ends the subscription. The channel is closed
+// when the subscription ends, or when the subscriber falls behind.
+func (f *StockFeed) Subscribe(buffer int) (<-chan models.StockUpdatedEvent, func()) {
+	ch := make(chan models.StockUpdatedEvent, buffer)
+	f.mu.Lock()
+	f.subscribers[ch] = struct{}{}
+	f.mu.Unlock()
+	return ch, func() { f.drop(ch) }
+}
+
+// Publish sends event to every subscriber.
+func (f *StockFeed) Publish(event models.StockUpdatedEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for ch := range f.subscribers {
+		select {
+		case ch <- event:
+		default:
+			delete(f.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+func (f *StockFeed) drop(ch chan models.StockUpdatedEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.subscribers[ch]; ok {
+		delete(f.subscribers, ch)
+		close(ch)
+	}
+}
@@ -0,0 +1,39 @@
+package notify
+
+import (
+	"testing"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestStockFeed(t *testing.T) {
+	feed := NewStockFeed()
+	fast, unsubscribe := feed.Subscribe(2)
+	defer unsubscribe()
+	slow, _ := feed.Subscribe(1)
+
+	first := models.StockUpdatedEvent{ProductID: uuid.New(), Stock: 3}
+	feed.Publish(first)
+	feed.Publish(models.StockUpdatedEvent{ProductID: uuid.New(), Stock: 4})
+
+	if event := <-fast; event != first {
+		t.Errorf("expected the first event, got %+v", event)
+	}
+	if event := <-fast; event.Stock != 4 {
+		t.Errorf("expected the second event, got %+v", event)
+	}
+	if event := <-slow; event != first {
+		t.Errorf("expected the slow subscriber to get the first event, got %+v", event)
+	}
+	if _, ok := <-slow; ok {
+		t.Error("expected the slow subscriber to be dropped once its buffer was full")
+	}
+
+	unsubscribe()
+	if _, ok := <-fast; ok {
+		t.Error("expected unsubscribing to close the channel")
+	}
+	unsubscribe()
+}
@@ -0,0 +1,160 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"rtims-backend/internal/alerting"
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/models"
+	"rtims-backend/internal/websocket"
+
+	"github.com/google/uuid"
+)
+
+// outboxBatchSize caps how many events are claimed per poll.
+const outboxBatchSize = 100
+
+// RunOutboxRelay polls the outbox and publishes events to the WebSocket hub,
+// notification channels and search index. An event is marked published only once it has
+// been handled, so a failure or crash means it is published again later:
+// delivery is at least once. It blocks until ctx is done, finishing the
+// batch in hand, so run it in its own goroutine.
+func RunOutboxRelay(ctx context.Context, db *sql.DB, dispatcher *Dispatcher, interval time.Duration) {
+	outboxService := database.NewOutboxService(db)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for {
+			events, err := outboxService.Claim(context.Background(), outboxBatchSize)
+			if err != nil {
+				log.Printf("Failed to claim outbox events: %v", err)
+				break
+			}
+
+			for i := range events {
+				event := &events[i]
+				if err := dispatcher.publish(event); err != nil {
+					log.Printf("Failed to publish outbox event %s (attempt %d of %d): %v",
+						event.ID, event.Attempts, database.MaxOutboxAttempts, err)
+					continue
+				}
+				if err := outboxService.MarkPublished(context.Background(), event.ID); err != nil {
+					log.Printf("Failed to mark outbox event %s published: %v", event.ID, err)
+				}
+			}
+
+			if len(events) < outboxBatchSize {
+				break
+			}
+		}
+	}
+}
+
+func (d *Dispatcher) publish(event *models.OutboxEvent) error {
+	switch event.Type {
+	case models.EventStockUpdated:
+		var stock models.StockUpdatedEvent
+		if err := json.Unmarshal(event.Payload, &stock); err != nil {
+			return fmt.Errorf("invalid payload: %w", err)
+		}
+		// Queued first, so a failure to queue retries the event before
+		// it is broadcast
+		if d.ShopifyPushes != nil {
+			if err := d.ShopifyPushes.QueueShopifyStockPushes(context.Background(), stock.ProductID); err != nil {
+				return err
+			}
+		}
+		// Events queued before they carried an organization are from the
+		// default one
+		organization := stock.OrganizationID
+		if organization == uuid.Nil {
+			organization = database.DefaultOrganizationID
+		}
+		websocket.BroadcastStockUpdate(d.hub, organization, stock.ProductID, stock.Stock)
+		if d.StockFeed != nil {
+			d.StockFeed.Publish(stock)
+		}
+		if d.Alerts != nil && stock.Stock == 0 {
+			go d.Alerts.Fire(alerting.StockOut(stock))
+		}
+		if stock.LowStock {
+			return d.notifyLowStock(stock)
+		}
+		return nil
+	case models.EventSearchIndex:
+		// Events queued before search was turned off are dropped
+		if d.Search == nil {
+			return nil
+		}
+		var index models.SearchIndexEvent
+		if err := json.Unmarshal(event.Payload, &index); err != nil {
+			return fmt.Errorf("invalid payload: %w", err)
+		}
+		return d.Search.Apply(context.Background(), index)
+	}
+	return fmt.Errorf("unknown event type %q", event.Type)
+}
+
+// notifyLowStock alerts the user who moved the stock that a product is low,
+// unless it will be covered by the daily digest or the same alert was sent
+// recently.
+func (d *Dispatcher) notifyLowStock(event models.StockUpdatedEvent) error {
+	if d.LowStockDigest || !d.AllowLowStock(event.ProductID, event.ChangedBy) {
+		return nil
+	}
+
+	// An unknown locale falls back to English
+	language, _ := d.userService.GetLocale(context.Background(), event.ChangedBy)
+	message := d.templateService.RenderIn(models.TemplateLowStock, language, map[string]interface{}{
+		"product": map[string]interface{}{
+			"name":              event.Name,
+			"sku":               event.SKU,
+			"minimum_threshold": event.MinimumThreshold,
+		},
+		"stock": event.Stock,
+	}, "Product '{{product.name}}' stock is low ({{stock}} remaining)")
+
+	notification := &models.Notification{
+		ID:        uuid.New(),
+		UserID:    event.ChangedBy,
+		Message:   message,
+		Type:      models.NotificationLowStock,
+		Priority:  models.PriorityNormal,
+		IsRead:    false,
+		CreatedAt: time.Now(),
+	}
+
+	notification.Metadata = &models.NotificationMetadata{
+		EntityType: "product",
+		EntityID:   &event.ProductID,
+		Action:     models.ActionCreatePurchaseOrder,
+		Link:       "/products?id=" + event.ProductID.String(),
+	}
+
+	// Running out entirely must be acknowledged or it is escalated
+	if event.Stock == 0 {
+		notification.Priority = models.PriorityCritical
+		notification.Metadata.Action = models.ActionAcknowledge
+	}
+
+	if err := d.notificationService.CreateNotification(notification); err != nil {
+		// The event is retried, and must not find its own cooldown
+		d.releaseLowStock(event.ProductID, event.ChangedBy)
+		return fmt.Errorf("failed to create low stock notification: %w", err)
+	}
+	d.Deliver(notification)
+	return nil
+}
@@ -0,0 +1,52 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"rtims-backend/internal/models"
+	"rtims-backend/internal/websocket"
+
+	"github.com/google/uuid"
+)
+
+func TestPublish(t *testing.T) {
+	d := &Dispatcher{hub: websocket.NewHub(0)}
+
+	payload, _ := json.Marshal(models.StockUpdatedEvent{ProductID: uuid.New(), Stock: 12, MinimumThreshold: 5})
+	tests := []struct {
+		name    string
+		event   models.OutboxEvent
+		wantErr bool
+	}{
+		{"stock updated", models.OutboxEvent{Type: models.EventStockUpdated, Payload: payload}, false},
+		{"invalid payload", models.OutboxEvent{Type: models.EventStockUpdated, Payload: json.RawMessage(`"12"`)}, true},
+		{"unknown type", models.OutboxEvent{Type: "price_updated", Payload: payload}, true},
+	}
+	for _, tt := range tests {
+		if err := d.publish(&tt.event); (err != nil) != tt.wantErr {
+			t.Errorf("%s: publish() = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRunOutboxRelayStops(t *testing.T) {
+	d := &Dispatcher{hub: websocket.NewHub(0)}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		RunOutboxRelay(ctx, sql.OpenDB(downConnector{}), d, 10*time.Millisecond)
+		close(stopped)
+	}()
+
+	time.Sleep(30 * time.Millisecond)
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("expected the relay to stop once its context was cancelled")
+	}
+}
@@ -0,0 +1,21 @@
+package notify
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"rtims-backend/internal/database"
+)
+
+// PurgeRetention deletes read notifications older than maxAge.
+func PurgeRetention(db *sql.DB, maxAge time.Duration) error {
+	purged, err := database.NewNotificationService(db).PurgeReadOlderThan(time.Now().Add(-maxAge))
+	if err != nil {
+		return err
+	}
+	if purged > 0 {
+		log.Printf("Notification retention purge removed %d read notifications", purged)
+	}
+	return nil
+}
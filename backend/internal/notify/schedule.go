@@ -0,0 +1,47 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"rtims-backend/internal/database"
+)
+
+// scheduledBatchSize caps how many due notifications are claimed per tick.
+const scheduledBatchSize = 100
+
+// RunScheduledDelivery polls for scheduled notifications that have come due
+// and delivers them on every channel, until ctx is done. It blocks, so run
+// it in its own goroutine.
+func RunScheduledDelivery(ctx context.Context, db *sql.DB, dispatcher *Dispatcher, interval time.Duration) {
+	notificationService := database.NewNotificationService(db)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for {
+			due, err := notificationService.ClaimDueScheduled(scheduledBatchSize)
+			if err != nil {
+				log.Printf("Failed to claim scheduled notifications: %v", err)
+				break
+			}
+
+			for i := range due {
+				dispatcher.Deliver(&due[i])
+			}
+
+			if len(due) < scheduledBatchSize {
+				break
+			}
+		}
+	}
+}
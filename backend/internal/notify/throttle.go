@@ -0,0 +1,71 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// Throttle suppresses repeat notifications for the same key within a
+// cooldown window using Redis keys with a TTL.
+type Throttle struct {
+	redisClient *redis.Client
+	cooldown    time.Duration
+}
+
+func NewThrottle(redisClient *redis.Client, cooldown time.Duration) *Throttle {
+	return &Throttle{
+		redisClient: redisClient,
+		cooldown:    cooldown,
+	}
+}
+
+// Allow reports whether a notification for key may be sent now, and starts
+// the cooldown if so. The claim is atomic, so of several replicas relaying
+// the same alert only one sends it; if sending then fails, Release gives
+// the slot back. Redis errors fail open so alerts are never lost.
+func (t *Throttle) Allow(key string) bool {
+	ok, err := t.redisClient.SetNX(context.Background(), throttleKey(key), time.Now().Unix(), t.cooldown).Result()
+	if err != nil {
+		log.Printf("Notification throttle unavailable, sending anyway: %v", err)
+		return true
+	}
+	return ok
+}
+
+// Release ends the cooldown for key started by Allow, so a notification
+// that could not be created is not suppressed until it expires.
+func (t *Throttle) Release(key string) {
+	if err := t.redisClient.Del(context.Background(), throttleKey(key)).Err(); err != nil {
+		log.Printf("Failed to release notification throttle %s: %v", key, err)
+	}
+}
+
+func throttleKey(key string) string {
+	return "notification_cooldown:" + key
+}
+
+// AllowLowStock reports whether a low stock notification for the product may
+// be sent to the user. It always allows when no throttle is configured.
+func (d *Dispatcher) AllowLowStock(productID, userID uuid.UUID) bool {
+	if d.Throttle == nil {
+		return true
+	}
+	return d.Throttle.Allow(lowStockKey(productID, userID))
+}
+
+// releaseLowStock gives back the slot AllowLowStock claimed when the
+// notification could not be created.
+func (d *Dispatcher) releaseLowStock(productID, userID uuid.UUID) {
+	if d.Throttle != nil {
+		d.Throttle.Release(lowStockKey(productID, userID))
+	}
+}
+
+func lowStockKey(productID, userID uuid.UUID) string {
+	return fmt.Sprintf("low_stock:%s:%s", productID, userID)
+}
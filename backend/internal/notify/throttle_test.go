@@ -0,0 +1,85 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"rtims-backend/internal/models"
+	"rtims-backend/internal/websocket"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// testRedis connects to REDIS_URL, or skips the test when it is not set.
+func testRedis(t *testing.T) *redis.Client {
+	t.Helper()
+	redisURL := os.Getenv("REDIS_URL")
+	if redisURL == "" {
+		t.Skip("REDIS_URL environment variable not set, skipping Redis test")
+	}
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		t.Fatalf("Invalid REDIS_URL: %v", err)
+	}
+	client := redis.NewClient(opts)
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// downConnector fails every connection, as an unreachable database does.
+type downConnector struct{}
+
+func (downConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, errors.New("dial tcp: connection refused")
+}
+
+func (downConnector) Driver() driver.Driver { return nil }
+
+func TestThrottleFailsOpen(t *testing.T) {
+	unreachable := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: 100 * time.Millisecond})
+	throttle := NewThrottle(unreachable, time.Hour)
+	for i := 0; i < 2; i++ {
+		if !throttle.Allow("low_stock:a:b") {
+			t.Fatal("expected an unreachable Redis to allow every notification")
+		}
+	}
+	throttle.Release("low_stock:a:b")
+}
+
+func TestThrottleRelease(t *testing.T) {
+	throttle := NewThrottle(testRedis(t), time.Minute)
+	key := "test:" + uuid.NewString()
+	t.Cleanup(func() { throttle.Release(key) })
+
+	if !throttle.Allow(key) {
+		t.Fatal("expected the first notification to be allowed")
+	}
+	if throttle.Allow(key) {
+		t.Error("expected a repeat within the cooldown to be suppressed")
+	}
+	throttle.Release(key)
+	if !throttle.Allow(key) {
+		t.Error("expected a released slot to be claimable again")
+	}
+}
+
+func TestNotifyLowStockReleasesOnFailure(t *testing.T) {
+	d := NewDispatcher(sql.OpenDB(downConnector{}), websocket.NewHub(0), nil)
+	d.Throttle = NewThrottle(testRedis(t), time.Minute)
+	event := models.StockUpdatedEvent{ProductID: uuid.New(), ChangedBy: uuid.New(), Name: "Widget", Stock: 1, LowStock: true}
+	t.Cleanup(func() { d.releaseLowStock(event.ProductID, event.ChangedBy) })
+
+	if err := d.notifyLowStock(event); err == nil {
+		t.Fatal("expected creating the notification to fail")
+	}
+	// The retried event is not suppressed by its own failed attempt
+	if !d.AllowLowStock(event.ProductID, event.ChangedBy) {
+		t.Error("expected the slot to be released when the notification was not created")
+	}
+}
@@ -0,0 +1,103 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"rtims-backend/internal/models"
+)
+
+const (
+	webhookMaxAttempts = 3
+	webhookBaseBackoff = time.Second
+)
+
+// WebhookClient posts notifications to Slack and Microsoft Teams incoming webhooks.
+type WebhookClient struct {
+	httpClient *http.Client
+}
+
+func NewWebhookClient() *WebhookClient {
+	return &WebhookClient{
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// SendSlack posts a notification to a Slack incoming webhook. An empty channel
+// uses the webhook's default channel.
+func (w *WebhookClient) SendSlack(url, channel string, notification *models.Notification) error {
+	payload := map[string]interface{}{
+		"text": fmt.Sprintf("*%s*\n%s", webhookTitle(notification.Type), notification.Message),
+	}
+	if channel != "" {
+		payload["channel"] = channel
+	}
+	return w.post(url, payload)
+}
+
+// SendTeams posts a notification to a Microsoft Teams incoming webhook.
+func (w *WebhookClient) SendTeams(url string, notification *models.Notification) error {
+	themeColor := "0076D7"
+	if notification.Type == models.NotificationLowStock {
+		themeColor = "D9534F"
+	}
+
+	payload := map[string]interface{}{
+		"@type":      "MessageCard",
+		"@context":   "http://schema.org/extensions",
+		"summary":    webhookTitle(notification.Type),
+		"themeColor": themeColor,
+		"title":      webhookTitle(notification.Type),
+		"text":       notification.Message,
+	}
+	return w.post(url, payload)
+}
+
+// post sends the payload, retrying with exponential backoff on network
+// errors, rate limiting, and server errors.
+func (w *WebhookClient) post(url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < webhookMaxAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(webhookBaseBackoff << (attempt - 1))
+		}
+
+		resp, err := w.httpClient.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return nil
+		}
+
+		lastErr = fmt.Errorf("webhook returned status %d", resp.StatusCode)
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+			// Client errors will not succeed on retry
+			break
+		}
+	}
+
+	return fmt.Errorf("webhook delivery failed after retries: %w", lastErr)
+}
+
+func webhookTitle(notifType models.NotificationType) string {
+	switch notifType {
+	case models.NotificationLowStock:
+		return "Low stock alert"
+	case models.NotificationSystem:
+		return "System notification"
+	default:
+		return "Notification"
+	}
+}
@@ -0,0 +1,429 @@
+// Package openapi generates the API's OpenAPI 3 document from the routes
+// registered with gin and a description of each. Request and response
+// schemas are derived from the Go types handlers bind and return, including
+// the constraints in their validate tags, so the document follows the code.
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Operation describes a route. Body, Query and Response are values of the
+// types the handler binds and answers with; non-zero values double as the
+// examples in the document.
+type Operation struct {
+	Summary     string
+	Description string
+	Tag         string
+	// Public routes need no bearer token.
+	Public bool
+	// Admin routes are limited to administrators.
+	Admin bool
+	// Query is a struct whose form tags name the query parameters.
+	Query interface{}
+	// Body is the JSON request body.
+	Body interface{}
+	// Status is the success status, 200 when zero.
+	Status int
+	// Response is the JSON success body; nil when there is none.
+	Response interface{}
+	// ContentType replaces JSON as the success content type, e.g. for
+	// downloads, which are then documented as binary.
+	ContentType string
+}
+
+// Object is a JSON object such as the gin.H a handler answers with. Its
+// values give the types, and examples, of its properties.
+type Object map[string]interface{}
+
+// Pagination is the pagination object of list responses.
+type Pagination struct {
+	Page  int `json:"page" example:"1"`
+	Limit int `json:"limit" example:"10"`
+	Total int `json:"total" example:"42"`
+	Pages int `json:"pages" example:"5"`
+}
+
+// Page describes a paginated list response: items under key, and the
+// pagination.
+func Page(key string, items interface{}) Object {
+	return Object{key: items, "pagination": Pagination{}}
+}
+
+// Info is the document's title, version and description.
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// ErrorResponse is the envelope every error is answered with.
+type ErrorResponse struct {
+	Error     string      `json:"error" example:"Product not found"`
+	Code      string      `json:"code" example:"product_not_found"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id,omitempty" example:"5f0c2a1e-8d6b-4c1a-9a57-0e3c1d2b7f44"`
+}
+
+// Document is an OpenAPI 3 document, ready to be encoded as JSON.
+type Document map[string]interface{}
+
+// Build documents routes, describing each with the operation under
+// "METHOD /path" in operations. Routes without one are still listed, named
+// after their handler, and wildcard routes such as static files are left
+// out.
+func Build(info Info, routes gin.RoutesInfo, operations map[string]Operation) Document {
+	g := &generator{schemas: map[string]interface{}{}}
+	errorRef := g.schema(reflect.TypeOf(ErrorResponse{}))
+
+	paths := map[string]map[string]interface{}{}
+	for _, route := range routes {
+		if strings.Contains(route.Path, "*") {
+			continue
+		}
+		op, ok := operations[route.Method+" "+route.Path]
+		if !ok {
+			op = Operation{Summary: handlerName(route.Handler)}
+		}
+		path, params := pathTemplate(route.Path)
+		if paths[path] == nil {
+			paths[path] = map[string]interface{}{}
+		}
+		paths[path][strings.ToLower(route.Method)] = g.operation(op, params, errorRef)
+	}
+
+	return Document{
+		"openapi": "3.0.3",
+		"info":    info,
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": g.schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{
+					"type":         "http",
+					"scheme":       "bearer",
+					"bearerFormat": "JWT",
+					"description":  "Access token from POST /api/v1/auth/login",
+				},
+			},
+		},
+	}
+}
+
+// Handler serves doc as JSON. The document is encoded once.
+func Handler(doc Document) gin.HandlerFunc {
+	encoded, err := json.Marshal(doc)
+	return func(c *gin.Context) {
+		if err != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		c.Data(http.StatusOK, "application/json; charset=utf-8", encoded)
+	}
+}
+
+// ReadDoc returns doc as JSON, so the document can be registered with
+// swag for the Swagger UI.
+func (doc Document) ReadDoc() string {
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		return "{}"
+	}
+	return string(encoded)
+}
+
+var pathParamPattern = regexp.MustCompile(`:([A-Za-z0-9_]+)`)
+
+// pathTemplate turns a gin path such as /products/:id into /products/{id},
+// returning the names of its parameters.
+func pathTemplate(path string) (string, []string) {
+	var params []string
+	template := pathParamPattern.ReplaceAllStringFunc(path, func(match string) string {
+		params = append(params, match[1:])
+		return "{" + match[1:] + "}"
+	})
+	return template, params
+}
+
+// handlerName shortens a handler such as
+// rtims-backend/internal/handlers.(*ProductHandler).GetProducts-fm to
+// GetProducts.
+func handlerName(handler string) string {
+	handler = strings.TrimSuffix(handler, "-fm")
+	if i := strings.LastIndex(handler, "."); i >= 0 {
+		handler = handler[i+1:]
+	}
+	return handler
+}
+
+type generator struct {
+	schemas map[string]interface{}
+}
+
+func (g *generator) operation(op Operation, pathParams []string, errorRef map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{"summary": op.Summary}
+	if op.Tag != "" {
+		result["tags"] = []string{op.Tag}
+	}
+	description := op.Description
+	if op.Admin {
+		description = strings.TrimSpace("Admin only. " + description)
+	}
+	if description != "" {
+		result["description"] = description
+	}
+	if !op.Public {
+		result["security"] = []map[string][]string{{"bearerAuth": {}}}
+	}
+
+	var parameters []interface{}
+	for _, name := range pathParams {
+		parameters = append(parameters, map[string]interface{}{
+			"name": name, "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"},
+		})
+	}
+	if op.Query != nil {
+		parameters = append(parameters, g.queryParameters(reflect.TypeOf(op.Query))...)
+	}
+	if len(parameters) > 0 {
+		result["parameters"] = parameters
+	}
+
+	if op.Body != nil {
+		result["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  map[string]interface{}{"application/json": g.media(op.Body)},
+		}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]interface{}{"description": http.StatusText(status)}
+	switch {
+	case op.ContentType != "":
+		success["content"] = map[string]interface{}{
+			op.ContentType: map[string]interface{}{"schema": map[string]interface{}{"type": "string", "format": "binary"}},
+		}
+	case op.Response != nil:
+		success["content"] = map[string]interface{}{"application/json": g.media(op.Response)}
+	}
+	result["responses"] = map[string]interface{}{
+		strconv.Itoa(status): success,
+		"default": map[string]interface{}{
+			"description": "Error",
+			"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": errorRef}},
+		},
+	}
+	return result
+}
+
+// media returns the schema of v, with v as the example when it is set.
+func (g *generator) media(v interface{}) map[string]interface{} {
+	media := map[string]interface{}{"schema": g.value(v)}
+	if value := reflect.ValueOf(v); value.IsValid() && !value.IsZero() {
+		if _, isObject := v.(Object); !isObject {
+			media["example"] = v
+		}
+	}
+	return media
+}
+
+// value returns the schema of v, looking into Objects.
+func (g *generator) value(v interface{}) map[string]interface{} {
+	object, ok := v.(Object)
+	if !ok {
+		return g.schema(reflect.TypeOf(v))
+	}
+	properties := map[string]interface{}{}
+	for name, property := range object {
+		properties[name] = g.value(property)
+	}
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
+
+func (g *generator) queryParameters(t reflect.Type) []interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	var parameters []interface{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("form"), ",")[0]
+		if field.Anonymous && name == "" {
+			parameters = append(parameters, g.queryParameters(field.Type)...)
+			continue
+		}
+		if name == "" || name == "-" || !field.IsExported() {
+			continue
+		}
+		parameter := map[string]interface{}{"name": name, "in": "query", "schema": g.field(field)}
+		if strings.Contains(field.Tag.Get("validate"), "required") {
+			parameter["required"] = true
+		}
+		parameters = append(parameters, parameter)
+	}
+	return parameters
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	uuidType    = reflect.TypeOf(uuid.UUID{})
+	rawJSONType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schema returns the schema for t. Named structs are added to the
+// components and referenced.
+func (g *generator) schema(t reflect.Type) map[string]interface{} {
+	if t == nil {
+		return map[string]interface{}{}
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case uuidType:
+		return map[string]interface{}{"type": "string", "format": "uuid"}
+	case rawJSONType:
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		name := t.Name()
+		if _, ok := g.schemas[name]; !ok {
+			g.schemas[name] = map[string]interface{}{} // placeholder for recursive types
+			g.schemas[name] = g.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+func (g *generator) object(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+	g.addFields(t, properties, &required)
+	object := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		sort.Strings(required)
+		object["required"] = required
+	}
+	return object
+}
+
+func (g *generator) addFields(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			for embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				g.addFields(embedded, properties, required)
+			}
+			continue
+		}
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = g.field(field)
+		if isRequired(field.Tag.Get("validate")) {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// field returns the schema of a struct field, with the constraints of its
+// validate tag and the example of its example tag.
+func (g *generator) field(field reflect.StructField) map[string]interface{} {
+	base := g.schema(field.Type)
+	if _, isRef := base["$ref"]; isRef {
+		return base
+	}
+	schema := map[string]interface{}{}
+	for key, value := range base {
+		schema[key] = value
+	}
+
+	for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		if name == "dive" {
+			break // the rules that follow apply to elements
+		}
+		switch name {
+		case "email":
+			schema["format"] = "email"
+		case "oneof":
+			schema["enum"] = strings.Fields(param)
+		case "min", "max":
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+			switch schema["type"] {
+			case "string":
+				schema[name+"Length"] = int(n)
+			case "array":
+				schema[name+"Items"] = int(n)
+			case "integer", "number":
+				schema[map[string]string{"min": "minimum", "max": "maximum"}[name]] = n
+			}
+		}
+	}
+	if example := field.Tag.Get("example"); example != "" {
+		schema["example"] = example
+		if schema["type"] == "integer" || schema["type"] == "number" {
+			if n, err := strconv.ParseFloat(example, 64); err == nil {
+				schema["example"] = n
+			}
+		}
+	}
+	return schema
+}
+
+func isRequired(validate string) bool {
+	for _, rule := range strings.Split(validate, ",") {
+		if rule == "required" {
+			return true
+		}
+		if rule == "dive" {
+			return false
+		}
+	}
+	return false
+}
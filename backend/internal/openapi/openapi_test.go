@@ -0,0 +1,164 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type widget struct {
+	Name  string   `json:"name" validate:"required,min=2,max=50" example:"Drill"`
+	Kind  string   `json:"kind" validate:"oneof=tool part"`
+	Email string   `json:"email,omitempty" validate:"omitempty,email"`
+	Tags  []string `json:"tags" validate:"max=5,dive,min=1"`
+}
+
+type widgetFilter struct {
+	Page   int    `form:"page"`
+	Search string `form:"search" validate:"required"`
+	cursor
+}
+
+type cursor struct {
+	Cursor string `form:"cursor"`
+}
+
+func buildTestDocument(t *testing.T) map[string]interface{} {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	noop := func(c *gin.Context) {}
+	r.POST("/login", noop)
+	r.GET("/widgets/:id", noop)
+	r.POST("/widgets", noop)
+	r.GET("/widgets", noop)
+	r.GET("/static/*filepath", noop)
+
+	doc := Build(Info{Title: "Test", Version: "1"}, r.Routes(), map[string]Operation{
+		"POST /login":       {Summary: "Log in", Public: true, Body: Object{"email": ""}},
+		"GET /widgets/:id":  {Summary: "Get a widget", Response: widget{}},
+		"POST /widgets":     {Summary: "Create a widget", Admin: true, Status: http.StatusCreated, Body: widget{Name: "Drill", Kind: "tool"}},
+		"GET /widgets":      {Summary: "List widgets", Query: widgetFilter{}, Response: Page("widgets", []widget{})},
+		"GET /static/*file": {Summary: "ignored"},
+	})
+
+	// Round-trip through JSON so the test sees what clients see
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(doc.ReadDoc()), &decoded); err != nil {
+		t.Fatalf("document is not valid JSON: %v", err)
+	}
+	return decoded
+}
+
+func get(t *testing.T, v interface{}, keys ...string) interface{} {
+	t.Helper()
+	for _, key := range keys {
+		object, ok := v.(map[string]interface{})
+		if !ok {
+			t.Fatalf("expected an object above %q", key)
+		}
+		v, ok = object[key]
+		if !ok {
+			t.Fatalf("missing %q", key)
+		}
+	}
+	return v
+}
+
+func TestBuildPaths(t *testing.T) {
+	doc := buildTestDocument(t)
+	paths := get(t, doc, "paths").(map[string]interface{})
+	if _, ok := paths["/widgets/{id}"]; !ok {
+		t.Errorf("expected /widgets/:id to be templated, got paths %v", reflect.ValueOf(paths).MapKeys())
+	}
+	if _, ok := paths["/static/*filepath"]; ok {
+		t.Error("expected wildcard routes to be left out")
+	}
+	params := get(t, paths, "/widgets/{id}", "get", "parameters").([]interface{})
+	if len(params) != 1 || get(t, params[0], "name") != "id" || get(t, params[0], "in") != "path" {
+		t.Errorf("unexpected path parameters %v", params)
+	}
+
+	query := get(t, paths, "/widgets", "get", "parameters").([]interface{})
+	if len(query) != 3 || get(t, query[1], "name") != "search" || get(t, query[1], "required") != true || get(t, query[2], "name") != "cursor" {
+		t.Errorf("unexpected query parameters %v", query)
+	}
+}
+
+func TestBuildSecurity(t *testing.T) {
+	doc := buildTestDocument(t)
+	if get(t, doc, "components", "securitySchemes", "bearerAuth", "scheme") != "bearer" {
+		t.Error("expected a bearer security scheme")
+	}
+	if _, ok := get(t, doc, "paths", "/login", "post").(map[string]interface{})["security"]; ok {
+		t.Error("expected public operations to need no token")
+	}
+	if get(t, doc, "paths", "/widgets/{id}", "get", "security") == nil {
+		t.Error("expected operations to need a token")
+	}
+	if get(t, doc, "paths", "/widgets", "post", "description") != "Admin only." {
+		t.Error("expected admin operations to say so")
+	}
+}
+
+func TestBuildSchemas(t *testing.T) {
+	doc := buildTestDocument(t)
+	schema := get(t, doc, "components", "schemas", "widget")
+
+	if required := get(t, schema, "required"); !reflect.DeepEqual(required, []interface{}{"name"}) {
+		t.Errorf("expected name to be required, got %v", required)
+	}
+	name := get(t, schema, "properties", "name")
+	if get(t, name, "minLength") != 2.0 || get(t, name, "maxLength") != 50.0 || get(t, name, "example") != "Drill" {
+		t.Errorf("unexpected name schema %v", name)
+	}
+	if kinds := get(t, schema, "properties", "kind", "enum"); !reflect.DeepEqual(kinds, []interface{}{"tool", "part"}) {
+		t.Errorf("expected kind to be an enum, got %v", kinds)
+	}
+	if get(t, schema, "properties", "email", "format") != "email" {
+		t.Error("expected email to have the email format")
+	}
+	tags := get(t, schema, "properties", "tags").(map[string]interface{})
+	if tags["maxItems"] != 5.0 || tags["minItems"] != nil {
+		t.Errorf("expected only the rules before dive to apply to tags, got %v", tags)
+	}
+
+	created := get(t, doc, "paths", "/widgets", "post", "responses")
+	if _, ok := created.(map[string]interface{})["201"]; !ok {
+		t.Errorf("expected a 201 response, got %v", created)
+	}
+	if get(t, created, "default", "content", "application/json", "schema", "$ref") != "#/components/schemas/ErrorResponse" {
+		t.Error("expected errors to reference the error envelope")
+	}
+	list := get(t, doc, "paths", "/widgets", "get", "responses", "200", "content", "application/json", "schema", "properties")
+	if get(t, list, "pagination", "$ref") != "#/components/schemas/Pagination" {
+		t.Errorf("expected list responses to be paginated, got %v", list)
+	}
+}
+
+func TestBuildExamples(t *testing.T) {
+	doc := buildTestDocument(t)
+	example := get(t, doc, "paths", "/widgets", "post", "requestBody", "content", "application/json", "example")
+	if get(t, example, "name") != "Drill" || get(t, example, "kind") != "tool" {
+		t.Errorf("expected the body value as the example, got %v", example)
+	}
+	if _, ok := get(t, doc, "paths", "/widgets/{id}", "get", "responses", "200", "content", "application/json").(map[string]interface{})["example"]; ok {
+		t.Error("expected zero values to give no example")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/openapi.json", Handler(Document{"openapi": "3.0.3"}))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if w.Code != http.StatusOK || w.Body.String() != `{"openapi":"3.0.3"}` {
+		t.Errorf("unexpected response %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,127 @@
+package reports
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"rtims-backend/internal/database"
+)
+
+// Settings keys that brand PDF report headers.
+const (
+	SettingCompanyName    = "report_company_name"
+	SettingCompanyDetails = "report_company_details"
+	// SettingPrimaryColor is a hex color such as "#1f6feb" used for the
+	// header rule and table headings.
+	SettingPrimaryColor = "report_primary_color"
+	// SettingLogo holds the filename of the uploaded logo inside the
+	// branding directory. It is set by SaveLogo rather than edited directly.
+	SettingLogo = "report_logo"
+)
+
+// LogoExtensions are the image types gofpdf can embed.
+var LogoExtensions = map[string]bool{".png": true, ".jpg": true, ".jpeg": true}
+
+// defaultColor is the table heading fill used when no color is configured.
+var defaultColor = Color{240, 240, 240}
+
+type Color struct {
+	R, G, B int
+}
+
+// ParseColor parses a "#rrggbb" or "#rgb" hex color.
+func ParseColor(s string) (Color, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return Color{}, fmt.Errorf("invalid color %q", s)
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return Color{}, fmt.Errorf("invalid color %q", s)
+	}
+	return Color{int(v >> 16 & 0xff), int(v >> 8 & 0xff), int(v & 0xff)}, nil
+}
+
+// light reports whether dark text is readable on c.
+func (c Color) light() bool {
+	return c.R*299+c.G*587+c.B*114 > 128000
+}
+
+// Branding is the company identity printed at the top of PDF reports.
+type Branding struct {
+	CompanyName    string
+	CompanyDetails string
+	// LogoPath is empty when no logo has been uploaded.
+	LogoPath string
+	Color    Color
+}
+
+// Branding loads the current branding from system settings.
+func (q *Queue) Branding() (*Branding, error) {
+	settings, err := database.NewSettingsService(q.db).GetSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	setting := func(key string) string {
+		value, _ := settings[key].(string)
+		return strings.TrimSpace(value)
+	}
+
+	branding := &Branding{
+		CompanyName:    setting(SettingCompanyName),
+		CompanyDetails: setting(SettingCompanyDetails),
+		Color:          defaultColor,
+	}
+	if color, err := ParseColor(setting(SettingPrimaryColor)); err == nil {
+		branding.Color = color
+	}
+	if logo := setting(SettingLogo); logo != "" {
+		path := filepath.Join(q.brandingDir(), filepath.Base(logo))
+		if _, err := os.Stat(path); err == nil {
+			branding.LogoPath = path
+		}
+	}
+
+	return branding, nil
+}
+
+// SaveLogo stores an uploaded logo image and makes it the current logo.
+// ext must be one of LogoExtensions and the data must decode as an image,
+// since a broken logo would make every PDF report fail.
+func (q *Queue) SaveLogo(ext string, data []byte) error {
+	ext = strings.ToLower(ext)
+	if !LogoExtensions[ext] {
+		return fmt.Errorf("unsupported logo type %q", ext)
+	}
+	if _, _, err := image.DecodeConfig(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("logo is not a valid image: %w", err)
+	}
+
+	dir := q.brandingDir()
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return fmt.Errorf("failed to create branding directory: %w", err)
+	}
+
+	filename := "logo" + ext
+	if err := os.WriteFile(filepath.Join(dir, filename), data, 0o640); err != nil {
+		return fmt.Errorf("failed to save logo: %w", err)
+	}
+
+	return database.NewSettingsService(q.db).UpdateSettings(map[string]interface{}{SettingLogo: filename})
+}
+
+func (q *Queue) brandingDir() string {
+	return filepath.Join(q.dir, "branding")
+}
@@ -0,0 +1,65 @@
+package reports
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := map[string]Color{
+		"#1f6feb": {31, 111, 235},
+		"FFFFFF":  {255, 255, 255},
+		"#0a0":    {0, 170, 0},
+	}
+	for input, expected := range tests {
+		got, err := ParseColor(input)
+		if err != nil {
+			t.Errorf("ParseColor(%q) failed: %v", input, err)
+			continue
+		}
+		if got != expected {
+			t.Errorf("ParseColor(%q): expected %v, got %v", input, expected, got)
+		}
+	}
+
+	for _, input := range []string{"", "#12345", "#gggggg", "blue"} {
+		if _, err := ParseColor(input); err == nil {
+			t.Errorf("ParseColor(%q): expected error", input)
+		}
+	}
+}
+
+func TestRenderPDFLayouts(t *testing.T) {
+	report := &Report{
+		Type:        "inventory",
+		Format:      "pdf",
+		GeneratedAt: time.Now(),
+		Data: []Row{
+			{"id": "1", "name": "Bolt", "sku": "B-1", "stock": 4, "price": 0.5, "category": "Hardware", "minimum_threshold": 5},
+		},
+		Branding: &Branding{CompanyName: "Acme Ltd", CompanyDetails: "1 Main St", Color: Color{31, 111, 235}},
+	}
+	report.Summary = summarize(report.Type, report.Data)
+
+	if report.Summary[2].Value != 1 {
+		t.Errorf("expected 1 low stock item, got %v", report.Summary[2].Value)
+	}
+
+	sizes := map[string]int{}
+	for _, layout := range []string{LayoutDetailed, LayoutSummary} {
+		report.Layout = layout
+		var buf bytes.Buffer
+		if err := Render(&buf, report); err != nil {
+			t.Fatalf("Render %s layout failed: %v", layout, err)
+		}
+		if !bytes.HasPrefix(buf.Bytes(), []byte("%PDF")) {
+			t.Fatalf("%s layout did not produce a PDF", layout)
+		}
+		sizes[layout] = buf.Len()
+	}
+
+	if sizes[LayoutSummary] >= sizes[LayoutDetailed] {
+		t.Errorf("expected summary layout to be smaller than detailed (%d >= %d)", sizes[LayoutSummary], sizes[LayoutDetailed])
+	}
+}
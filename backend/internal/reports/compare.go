@@ -0,0 +1,205 @@
+package reports
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrUnknownComparison = errors.New("invalid compare_to. Supported values: previous_period, same_period_last_year")
+	ErrComparisonRange   = errors.New("compare_to requires a start_date and end_date, in order")
+	ErrInvalidDate       = errors.New("invalid date. Use YYYY-MM-DD or RFC 3339")
+)
+
+// Comparison modes for compare_to.
+const (
+	ComparePreviousPeriod     = "previous_period"
+	CompareSamePeriodLastYear = "same_period_last_year"
+)
+
+const dateLayout = "2006-01-02"
+
+// Period is the date range a report was compared against.
+type Period struct {
+	CompareTo string `json:"compare_to"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+}
+
+// validComparison reports whether compareTo is supported for reportType.
+// Only inventory and movement reports have totals worth comparing.
+func validComparison(reportType, compareTo string) bool {
+	switch compareTo {
+	case "":
+		return true
+	case ComparePreviousPeriod, CompareSamePeriodLastYear:
+		return reportType == "inventory" || reportType == "movements"
+	}
+	return false
+}
+
+// parseDate accepts the date formats used by the report filters.
+func parseDate(s string) (time.Time, bool, error) {
+	if t, err := time.Parse(dateLayout, s); err == nil {
+		return t, true, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, false, nil
+	}
+	return time.Time{}, false, ErrInvalidDate
+}
+
+// comparisonPeriod returns the range params.CompareTo refers to. Ranges
+// covering whole calendar months, e.g. 2024-03-01 to 2024-03-31, shift by
+// months so that a monthly review compares against the full prior month.
+func comparisonPeriod(params Params) (*Period, error) {
+	if params.StartDate == "" || params.EndDate == "" {
+		return nil, ErrComparisonRange
+	}
+	start, startDateOnly, err := parseDate(params.StartDate)
+	if err != nil {
+		return nil, err
+	}
+	end, endDateOnly, err := parseDate(params.EndDate)
+	if err != nil {
+		return nil, err
+	}
+	if end.Before(start) {
+		return nil, ErrComparisonRange
+	}
+	dateOnly := startDateOnly && endDateOnly
+
+	var prevStart, prevEnd time.Time
+	switch params.CompareTo {
+	case CompareSamePeriodLastYear:
+		prevStart, prevEnd = start.AddDate(-1, 0, 0), end.AddDate(-1, 0, 0)
+	case ComparePreviousPeriod:
+		if months := wholeMonths(start, end); months > 0 {
+			prevStart, prevEnd = start.AddDate(0, -months, 0), start.AddDate(0, 0, -1)
+		} else if dateOnly {
+			// Date ranges are inclusive, so the previous one ends the day before
+			prevEnd = start.AddDate(0, 0, -1)
+			prevStart = prevEnd.Add(-end.Sub(start))
+		} else {
+			prevStart, prevEnd = start.Add(-end.Sub(start)), start
+		}
+	default:
+		return nil, ErrUnknownComparison
+	}
+
+	format := time.RFC3339
+	if dateOnly {
+		format = dateLayout
+	}
+	return &Period{
+		CompareTo: params.CompareTo,
+		StartDate: prevStart.Format(format),
+		EndDate:   prevEnd.Format(format),
+	}, nil
+}
+
+// wholeMonths returns how many calendar months start..end spans when start is
+// the first and end the last day of a month, or 0 otherwise.
+func wholeMonths(start, end time.Time) int {
+	if start.Day() != 1 || end.AddDate(0, 0, 1).Day() != 1 || !isMidnight(start) || !isMidnight(end) {
+		return 0
+	}
+	return (end.Year()-start.Year())*12 + int(end.Month()-start.Month()) + 1
+}
+
+func isMidnight(t time.Time) bool {
+	return t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0
+}
+
+// compare runs the report again for the comparison period and annotates each
+// summary item with the previous value, the delta and the percentage change.
+func (r *Report) compare(db *sql.DB, params Params) error {
+	period, err := comparisonPeriod(params)
+	if err != nil {
+		return err
+	}
+
+	previous := params
+	previous.StartDate, previous.EndDate = period.StartDate, period.EndDate
+	data, err := generateData(db, previous)
+	if err != nil {
+		return err
+	}
+
+	r.Comparison = period
+	annotate(r.Summary, summarize(r.summaryType(), data))
+	return nil
+}
+
+// annotate copies the previous figures into current, matching items by
+// label, and computes their changes.
+func annotate(current, previous []SummaryItem) {
+	byLabel := map[string]interface{}{}
+	for _, item := range previous {
+		byLabel[item.Label] = item.Value
+	}
+
+	for i := range current {
+		item := &current[i]
+		prev, ok := byLabel[item.Label]
+		if !ok {
+			continue
+		}
+		item.Previous = prev
+
+		switch v := item.Value.(type) {
+		case int:
+			p := prev.(int)
+			item.Delta = v - p
+			item.PercentChange = percentChange(float64(v), float64(p))
+		case float64:
+			p := prev.(float64)
+			item.Delta = v - p
+			item.PercentChange = percentChange(v, p)
+		}
+	}
+}
+
+// percentChange returns the change from previous to current in percent, or
+// nil when previous is zero.
+func percentChange(current, previous float64) *float64 {
+	if previous == 0 {
+		return nil
+	}
+	change := (current - previous) / previous * 100
+	return &change
+}
+
+// formatComparison renders the comparison suffix for a PDF summary line,
+// e.g. " (Previous: 1,200; +150; +12.5%)".
+func (r *Report) formatComparison(item SummaryItem) string {
+	if item.Previous == nil {
+		return ""
+	}
+
+	delta := r.formatValue(item.Delta, item.Kind, true)
+	if !isNegative(item.Delta) {
+		delta = "+" + delta
+	}
+	s := fmt.Sprintf("%s: %s; %s", r.locale().T("Previous"), r.formatValue(item.Previous, item.Kind, true), delta)
+	if item.PercentChange != nil {
+		change := r.locale().FormatNumber(*item.PercentChange, 1, true) + "%"
+		if *item.PercentChange >= 0 {
+			change = "+" + change
+		}
+		s += "; " + change
+	}
+	return " (" + s + ")"
+}
+
+func isNegative(v interface{}) bool {
+	switch n := v.(type) {
+	case int:
+		return n < 0
+	case float64:
+		return n < 0
+	}
+	return false
+}
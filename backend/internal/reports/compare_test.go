@@ -0,0 +1,73 @@
+package reports
+
+import "testing"
+
+func TestComparisonPeriod(t *testing.T) {
+	tests := []struct {
+		start, end, compareTo string
+		prevStart, prevEnd    string
+	}{
+		{"2024-03-01", "2024-03-31", ComparePreviousPeriod, "2024-02-01", "2024-02-29"},
+		{"2024-01-01", "2024-03-31", ComparePreviousPeriod, "2023-10-01", "2023-12-31"},
+		{"2024-03-11", "2024-03-17", ComparePreviousPeriod, "2024-03-04", "2024-03-10"},
+		{"2024-03-01", "2024-03-31", CompareSamePeriodLastYear, "2023-03-01", "2023-03-31"},
+		{"2024-03-01T00:00:00Z", "2024-03-01T12:00:00Z", ComparePreviousPeriod, "2024-02-29T12:00:00Z", "2024-03-01T00:00:00Z"},
+	}
+	for _, tt := range tests {
+		period, err := comparisonPeriod(Params{StartDate: tt.start, EndDate: tt.end, CompareTo: tt.compareTo})
+		if err != nil {
+			t.Errorf("%s %s..%s: unexpected error %v", tt.compareTo, tt.start, tt.end, err)
+			continue
+		}
+		if period.StartDate != tt.prevStart || period.EndDate != tt.prevEnd {
+			t.Errorf("%s %s..%s: expected %s..%s, got %s..%s", tt.compareTo, tt.start, tt.end, tt.prevStart, tt.prevEnd, period.StartDate, period.EndDate)
+		}
+	}
+}
+
+func TestValidateComparison(t *testing.T) {
+	tests := []struct {
+		params   Params
+		expected error
+	}{
+		{Params{Type: "movements", Format: "json", CompareTo: ComparePreviousPeriod, StartDate: "2024-03-01", EndDate: "2024-03-31"}, nil},
+		{Params{Type: "movements", Format: "json", CompareTo: ComparePreviousPeriod}, ErrComparisonRange},
+		{Params{Type: "inventory", Format: "json", CompareTo: "last_week", StartDate: "2024-03-01", EndDate: "2024-03-31"}, ErrUnknownComparison},
+		{Params{Type: "users", Format: "json", CompareTo: ComparePreviousPeriod, StartDate: "2024-03-01", EndDate: "2024-03-31"}, ErrUnknownComparison},
+		{Params{Type: "inventory", Format: "json", CompareTo: ComparePreviousPeriod, StartDate: "March", EndDate: "2024-03-31"}, ErrInvalidDate},
+	}
+	for _, tt := range tests {
+		if err := tt.params.Validate(); err != tt.expected {
+			t.Errorf("%s compared to %s: expected %v, got %v", tt.params.Type, tt.params.CompareTo, tt.expected, err)
+		}
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	current := []SummaryItem{
+		{Label: "Total Products", Value: 12},
+		{Label: "Total Value", Value: 150.0, Kind: kindCurrency},
+		{Label: "Low Stock Items", Value: 3},
+	}
+	previous := []SummaryItem{
+		{Label: "Total Products", Value: 10},
+		{Label: "Total Value", Value: 200.0, Kind: kindCurrency},
+		{Label: "Low Stock Items", Value: 0},
+	}
+	annotate(current, previous)
+
+	if current[0].Delta != 2 || current[0].PercentChange == nil || *current[0].PercentChange != 20 {
+		t.Errorf("expected +2 (+20%%) products, got %v", current[0].Delta)
+	}
+	if current[1].Delta != -50.0 || *current[1].PercentChange != -25 {
+		t.Errorf("expected -50 (-25%%) value, got %v", current[1].Delta)
+	}
+	if current[2].Delta != 3 || current[2].PercentChange != nil {
+		t.Errorf("expected +3 low stock items with no percentage, got %v", current[2].Delta)
+	}
+
+	report := &Report{Locale: "en"}
+	if s := report.formatComparison(current[1]); s != " (Previous: 200.00; -50.00; -25.0%)" {
+		t.Errorf("unexpected comparison text %q", s)
+	}
+}
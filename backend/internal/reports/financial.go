@@ -0,0 +1,88 @@
+package reports
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// financialColumns lays out the per-category valuation. Sales and purchases
+// are valued at each product's current price, as movements do not record
+// the price at the time.
+var financialColumns = []column{
+	{Header: "Category", Key: "category", Width: 30, Align: "L"},
+	{Header: "Products", Key: "products", Width: 18, Align: "C"},
+	{Header: "Units In Stock", Key: "units_in_stock", Width: 20, Align: "C"},
+	{Header: "Stock Value", Key: "stock_value", Width: 25, Align: "R", Kind: kindCurrency},
+	{Header: "Units Sold", Key: "units_sold", Width: 18, Align: "C"},
+	{Header: "Sales Value", Key: "sales_value", Width: 25, Align: "R", Kind: kindCurrency},
+	{Header: "Units Purchased", Key: "units_purchased", Width: 22, Align: "C"},
+	{Header: "Purchase Value", Key: "purchase_value", Width: 25, Align: "R", Kind: kindCurrency},
+}
+
+// generateFinancial returns one row per category with its stock valuation
+// and the value of sales and purchases within the date range.
+func generateFinancial(db *sql.DB, params Params) ([]Row, error) {
+	conditions, args := movementConditions(Params{StartDate: params.StartDate, EndDate: params.EndDate, OrganizationID: params.OrganizationID})
+	movementFilter := ""
+	if len(conditions) > 0 {
+		movementFilter = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	query := `
+		SELECT p.category, COUNT(*), COALESCE(SUM(p.stock), 0), COALESCE(SUM(p.stock * p.price), 0),
+		       COALESCE(SUM(m.units_sold), 0), COALESCE(SUM(m.units_sold * p.price), 0),
+		       COALESCE(SUM(m.units_purchased), 0), COALESCE(SUM(m.units_purchased * p.price), 0)
+		FROM products p
+		LEFT JOIN (
+			SELECT sm.product_id,
+			       SUM(CASE WHEN sm.reason = 'sale' THEN -sm.change ELSE 0 END) AS units_sold,
+			       SUM(CASE WHEN sm.reason = 'purchase' THEN sm.change ELSE 0 END) AS units_purchased
+			FROM stock_movements sm` + movementFilter + `
+			GROUP BY sm.product_id
+		) m ON m.product_id = p.id`
+
+	productConditions := []string{}
+	if params.Category != "" {
+		args = append(args, params.Category)
+		productConditions = append(productConditions, fmt.Sprintf("p.category = $%d", len(args)))
+	}
+	if params.OrganizationID != "" {
+		args = append(args, params.OrganizationID)
+		productConditions = append(productConditions, fmt.Sprintf("p.organization_id = $%d", len(args)))
+	}
+	if len(productConditions) > 0 {
+		query += " WHERE " + strings.Join(productConditions, " AND ")
+	}
+	query += " GROUP BY p.category"
+
+	rows, err := db.Query(filterQuery(query, nil, "4 DESC", params.Limit), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []Row{}
+	for rows.Next() {
+		var category string
+		var products, unitsInStock, unitsSold, unitsPurchased int
+		var stockValue, salesValue, purchaseValue float64
+
+		if err := rows.Scan(&category, &products, &unitsInStock, &stockValue, &unitsSold, &salesValue, &unitsPurchased, &purchaseValue); err != nil {
+			return nil, err
+		}
+
+		categories = append(categories, Row{
+			"category":        category,
+			"products":        products,
+			"units_in_stock":  unitsInStock,
+			"stock_value":     stockValue,
+			"units_sold":      unitsSold,
+			"sales_value":     salesValue,
+			"units_purchased": unitsPurchased,
+			"purchase_value":  purchaseValue,
+		})
+	}
+
+	return categories, rows.Err()
+}
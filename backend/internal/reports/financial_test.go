@@ -0,0 +1,37 @@
+package reports
+
+import "testing"
+
+func TestValidateFilters(t *testing.T) {
+	tests := []struct {
+		params   Params
+		expected error
+	}{
+		{Params{Type: "financial", Format: "xlsx", StartDate: "2024-03-01"}, nil},
+		{Params{Type: "movements", Format: "json", Reason: "sale"}, nil},
+		{Params{Type: "movements", Format: "json", Reason: "theft"}, ErrUnknownReason},
+		{Params{Type: "movements", Format: "json", ProductID: "42"}, ErrInvalidProductID},
+		{Params{Type: "inventory", Format: "json", EndDate: "31/03/2024"}, ErrInvalidDate},
+		{Params{Type: "users", Format: "json", Limit: -1}, ErrInvalidLimit},
+	}
+	for _, tt := range tests {
+		if err := tt.params.Validate(); err != tt.expected {
+			t.Errorf("%+v: expected %v, got %v", tt.params, tt.expected, err)
+		}
+	}
+}
+
+func TestSummarizeFinancial(t *testing.T) {
+	data := []Row{
+		{"category": "Tools", "stock_value": 1200.0, "sales_value": 300.0, "purchase_value": 500.0},
+		{"category": "Paint", "stock_value": 800.0, "sales_value": 150.0, "purchase_value": 0.0},
+	}
+
+	summary := summarize("financial", data)
+	expected := []float64{2000, 450, 500}
+	for i, value := range expected {
+		if summary[i].Value != value || summary[i].Kind != kindCurrency {
+			t.Errorf("%s: expected %v, got %v", summary[i].Label, value, summary[i].Value)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package reports
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/models"
+	"rtims-backend/internal/storage"
+
+	"github.com/google/uuid"
+)
+
+// queueSize bounds how many jobs can wait for a worker.
+const queueSize = 100
+
+var ErrQueueFull = errors.New("report queue is full, try again later")
+
+type job struct {
+	id          uuid.UUID
+	params      Params
+	requestedBy *uuid.UUID
+}
+
+// Queue runs report jobs on a fixed pool of background workers, started
+// with Run, records
+// their progress in the reports table and keeps finished artifacts in the
+// object store. dir holds the branding logo, and the artifacts of reports
+// generated before they moved to the object store.
+type Queue struct {
+	db            *sql.DB
+	store         storage.Store
+	dir           string
+	reportService *database.ReportService
+	pending       chan job
+
+	// Limiter, when set, caps concurrent generation across instances and
+	// per user. A worker holding a job over the cap waits for a slot.
+	Limiter *Limiter
+}
+
+// NewQueue creates the report directory. Reports left unfinished by a
+// previous run are marked failed.
+func NewQueue(db *sql.DB, store storage.Store, dir string) (*Queue, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create report directory: %w", err)
+	}
+
+	q := &Queue{
+		db:            db,
+		store:         store,
+		dir:           dir,
+		reportService: database.NewReportService(db),
+		pending:       make(chan job, queueSize),
+	}
+
+	if n, err := q.reportService.FailInterrupted(); err != nil {
+		log.Printf("Failed to clean up interrupted reports: %v", err)
+	} else if n > 0 {
+		log.Printf("Marked %d interrupted reports as failed", n)
+	}
+
+	return q, nil
+}
+
+// Run generates queued reports on workers goroutines until ctx is done, and
+// returns once the reports in hand are finished. Jobs still queued are left
+// to be marked failed on the next start.
+func (q *Queue) Run(ctx context.Context, workers int) {
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx, q.run)
+		}()
+	}
+	wg.Wait()
+}
+
+// Enqueue validates params, records the report and schedules it. It does not
+// block when the queue is full.
+func (q *Queue) Enqueue(params Params, requestedBy uuid.UUID) (*models.Report, error) {
+	report, err := q.create(&params, &requestedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case q.pending <- job{id: report.ID, params: params, requestedBy: &requestedBy}:
+		return report, nil
+	default:
+		q.reportService.MarkFailed(report.ID, ErrQueueFull.Error())
+		return nil, ErrQueueFull
+	}
+}
+
+// RunNow records and generates a report in the calling goroutine, bypassing
+// the worker pool, and returns its final state. It waits for a Limiter slot
+// like any other report.
+func (q *Queue) RunNow(params Params, requestedBy *uuid.UUID) (*models.Report, error) {
+	report, err := q.create(&params, requestedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := q.Acquire(context.Background(), requestedBy)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	q.run(job{id: report.ID, params: params, requestedBy: requestedBy})
+	return q.Get(report.ID)
+}
+
+// create validates and records a report. It fills in the requester's locale
+// when params does not name one, and limits the report to the requester's
+// organization.
+func (q *Queue) create(params *Params, requestedBy *uuid.UUID) (*models.Report, error) {
+	params.OrganizationID = OrganizationFor(q.db, requestedBy)
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	if params.Locale == "" {
+		params.Locale = LocaleFor(q.db, requestedBy)
+	}
+
+	parameters, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode report parameters: %w", err)
+	}
+
+	report := &models.Report{
+		ID:          uuid.New(),
+		Type:        params.Type,
+		Format:      params.Format,
+		Status:      models.ReportQueued,
+		Parameters:  parameters,
+		RequestedBy: requestedBy,
+		CreatedAt:   time.Now(),
+	}
+
+	if err := q.reportService.CreateReport(report); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// Get returns the current state of a report.
+func (q *Queue) Get(id uuid.UUID) (*models.Report, error) {
+	return q.reportService.GetReport(id)
+}
+
+// Open reads a completed report's artifact.
+func (q *Queue) Open(ctx context.Context, report *models.Report) (io.ReadCloser, error) {
+	if !strings.HasPrefix(report.StorageKey, storage.PrefixReports) {
+		return os.Open(filepath.Join(q.dir, filepath.Base(report.StorageKey)))
+	}
+	return q.store.Open(ctx, report.StorageKey)
+}
+
+// Acquire waits for a generation slot when a Limiter is set. The returned
+// func releases it.
+func (q *Queue) Acquire(ctx context.Context, requestedBy *uuid.UUID) (func(), error) {
+	if q.Limiter == nil {
+		return func() {}, nil
+	}
+	return q.Limiter.Acquire(ctx, requestedBy)
+}
+
+// work takes jobs off the queue and hands them to run once the Limiter has
+// a slot for them, until ctx is done.
+func (q *Queue) work(ctx context.Context, run func(job)) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-q.pending:
+			release, err := q.Acquire(ctx, j.requestedBy)
+			if err != nil {
+				// Only returned once ctx is done
+				return
+			}
+			run(j)
+			release()
+		}
+	}
+}
+
+func (q *Queue) run(j job) {
+	if err := q.reportService.MarkRunning(j.id); err != nil {
+		log.Printf("Failed to mark report %s running: %v", j.id, err)
+	}
+
+	storageKey, filename, rowCount, size, err := q.generate(j.id, j.params)
+	if err != nil {
+		log.Printf("Report %s failed: %v", j.id, err)
+		if err := q.reportService.MarkFailed(j.id, err.Error()); err != nil {
+			log.Printf("Failed to mark report %s failed: %v", j.id, err)
+		}
+		return
+	}
+
+	if err := q.reportService.MarkCompleted(j.id, storageKey, filename, size, rowCount); err != nil {
+		log.Printf("Failed to mark report %s completed: %v", j.id, err)
+	}
+}
+
+func (q *Queue) generate(id uuid.UUID, params Params) (string, string, int, int64, error) {
+	report, err := Generate(q.db, params)
+	if err != nil {
+		return "", "", 0, 0, err
+	}
+	if params.Format == "pdf" {
+		if report.Branding, err = q.Branding(); err != nil {
+			log.Printf("Failed to load report branding, rendering unbranded: %v", err)
+		}
+	}
+
+	// Rendered to a temporary file first, since the store needs the size
+	file, err := os.CreateTemp("", "rtims-report-*")
+	if err != nil {
+		return "", "", 0, 0, fmt.Errorf("failed to create report file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if err := Render(file, report); err != nil {
+		return "", "", 0, 0, fmt.Errorf("failed to render report: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", "", 0, 0, fmt.Errorf("failed to stat report file: %w", err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", "", 0, 0, fmt.Errorf("failed to read report file: %w", err)
+	}
+
+	storageKey := storage.PrefixReports + id.String() + "." + params.Format
+	if err := q.store.Put(context.Background(), storageKey, file, info.Size(), ContentType(params.Format)); err != nil {
+		return "", "", 0, 0, fmt.Errorf("failed to store report: %w", err)
+	}
+
+	return storageKey, Filename(report), len(report.Data), info.Size(), nil
+}
@@ -0,0 +1,147 @@
+package reports
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"rtims-backend/internal/models"
+	"rtims-backend/internal/storage"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// TestArtifacts checks artifacts are read and deleted from the object
+// store, and those of reports generated before it from the report
+// directory.
+func TestArtifacts(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.NewLocal(t.TempDir(), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := &Queue{store: store, dir: t.TempDir()}
+
+	if err := store.Put(ctx, "reports/new.csv", strings.NewReader("new"), 3, "text/csv"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(q.dir, "old.csv"), []byte("old"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, expected := range map[string]string{"reports/new.csv": "new", "old.csv": "old"} {
+		artifact, err := q.Open(ctx, &models.Report{StorageKey: key})
+		if err != nil {
+			t.Fatalf("%s: %v", key, err)
+		}
+		data, _ := io.ReadAll(artifact)
+		artifact.Close()
+		if string(data) != expected {
+			t.Errorf("%s: expected %q, got %q", key, expected, data)
+		}
+
+		if err := q.deleteArtifact(ctx, key); err != nil {
+			t.Fatalf("%s: %v", key, err)
+		}
+		if _, err := q.Open(ctx, &models.Report{StorageKey: key}); err == nil {
+			t.Errorf("%s: expected the artifact to be deleted", key)
+		}
+		if err := q.deleteArtifact(ctx, key); err != nil {
+			t.Errorf("%s: expected deleting a missing artifact to succeed, got %v", key, err)
+		}
+	}
+}
+
+// startWork runs a worker of q until the test ends, sending the ids of the
+// jobs it runs on the returned channel.
+func startWork(t *testing.T, q *Queue) <-chan uuid.UUID {
+	ran := make(chan uuid.UUID, 4)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		q.work(ctx, func(j job) { ran <- j.id })
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-stopped
+	})
+	return ran
+}
+
+func TestWorkWaitsForLimiterSlot(t *testing.T) {
+	// Nothing listens on port 1, so the limiter runs without Redis
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: 100 * time.Millisecond})
+	defer client.Close()
+	q := &Queue{pending: make(chan job, queueSize), Limiter: NewLimiter(client, 0, 1, 0)}
+
+	alice, bob := uuid.New(), uuid.New()
+	release, ok := q.Limiter.TryAcquire(&alice)
+	if !ok {
+		t.Fatal("Expected a free slot")
+	}
+	ran := startWork(t, q)
+
+	waiting := job{id: uuid.New(), requestedBy: &alice}
+	q.pending <- waiting
+	select {
+	case id := <-ran:
+		t.Fatalf("Expected %s to wait for alice's slot", id)
+	case <-time.After(3 * limiterPoll):
+	}
+
+	release()
+	select {
+	case id := <-ran:
+		if id != waiting.id {
+			t.Errorf("Expected %s to run, got %s", waiting.id, id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the job to run once the slot was released")
+	}
+
+	other := job{id: uuid.New(), requestedBy: &bob}
+	q.pending <- other
+	select {
+	case id := <-ran:
+		if id != other.id {
+			t.Errorf("Expected %s to run, got %s", other.id, id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the worker to take the next job")
+	}
+}
+
+func TestWorkStopsOnShutdown(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: 100 * time.Millisecond})
+	defer client.Close()
+	q := &Queue{pending: make(chan job, queueSize), Limiter: NewLimiter(client, 1, 0, 0)}
+
+	release, ok := q.Limiter.TryAcquire(nil)
+	if !ok {
+		t.Fatal("Expected a free slot")
+	}
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		q.work(ctx, func(j job) { t.Errorf("Expected %s not to run after shutdown", j.id) })
+	}()
+
+	// The worker is waiting for a slot when the server shuts down
+	q.pending <- job{id: uuid.New()}
+	time.Sleep(2 * limiterPoll)
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the worker to stop")
+	}
+}
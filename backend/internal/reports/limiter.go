@@ -0,0 +1,173 @@
+package reports
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+var ErrBusy = errors.New("too many reports are being generated, try again shortly or request the report in the background")
+
+const (
+	limiterGlobalKey = "report_slots:global"
+	limiterUserKey   = "report_slots:user:"
+	// limiterLease is how long a slot survives without being renewed, so a
+	// crashed instance cannot hold slots forever.
+	limiterLease = time.Minute
+	limiterPoll  = 250 * time.Millisecond
+)
+
+// acquireScript takes a slot in the global set and, when a second key is
+// given, the user's set. Each set holds slot tokens scored by lease expiry;
+// expired tokens are dropped first. A cap of 0 means unlimited.
+var acquireScript = redis.NewScript(`
+local now, token, globalCap, userCap, expiry = tonumber(ARGV[1]), ARGV[2], tonumber(ARGV[3]), tonumber(ARGV[4]), ARGV[5]
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now)
+if globalCap > 0 and redis.call('ZCARD', KEYS[1]) >= globalCap then
+	return 0
+end
+if KEYS[2] then
+	redis.call('ZREMRANGEBYSCORE', KEYS[2], '-inf', now)
+	if userCap > 0 and redis.call('ZCARD', KEYS[2]) >= userCap then
+		return 0
+	end
+	redis.call('ZADD', KEYS[2], expiry, token)
+end
+redis.call('ZADD', KEYS[1], expiry, token)
+return 1
+`)
+
+// Limiter caps how many reports generate at once, across all API instances
+// and per user, with a counting semaphore in Redis. While Redis is down the
+// caps are enforced per instance instead.
+type Limiter struct {
+	redisClient *redis.Client
+	global      int
+	perUser     int
+	// Wait is how long an interactive request queues for a slot before
+	// giving up with ErrBusy.
+	Wait time.Duration
+
+	local localSlots
+}
+
+func NewLimiter(redisClient *redis.Client, global, perUser int, wait time.Duration) *Limiter {
+	return &Limiter{
+		redisClient: redisClient,
+		global:      global,
+		perUser:     perUser,
+		Wait:        wait,
+	}
+}
+
+// Acquire waits until a slot is free or ctx is done, in which case it
+// returns ErrBusy. The returned func releases the slot. A nil userID only
+// counts against the global cap.
+func (l *Limiter) Acquire(ctx context.Context, userID *uuid.UUID) (func(), error) {
+	for {
+		release, ok := l.TryAcquire(userID)
+		if ok {
+			return release, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ErrBusy
+		case <-time.After(limiterPoll):
+		}
+	}
+}
+
+// TryAcquire takes a slot if one is free without waiting.
+func (l *Limiter) TryAcquire(userID *uuid.UUID) (func(), bool) {
+	ctx := context.Background()
+	token := uuid.New().String()
+	keys := l.keys(userID)
+
+	now := time.Now()
+	ok, err := acquireScript.Run(ctx, l.redisClient, keys,
+		now.UnixMilli(), token, l.global, l.perUser, now.Add(limiterLease).UnixMilli()).Int()
+	if err != nil {
+		log.Printf("Report limiter unavailable, limiting this instance only: %v", err)
+		return l.local.tryAcquire(userID, l.global, l.perUser)
+	}
+	if ok == 0 {
+		return nil, false
+	}
+
+	// Renew the lease while the report generates
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(limiterLease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				expiry := float64(time.Now().Add(limiterLease).UnixMilli())
+				for _, key := range keys {
+					l.redisClient.ZAddXX(ctx, key, &redis.Z{Score: expiry, Member: token})
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		for _, key := range keys {
+			if err := l.redisClient.ZRem(ctx, key, token).Err(); err != nil {
+				log.Printf("Failed to release report slot: %v", err)
+			}
+		}
+	}, true
+}
+
+func (l *Limiter) keys(userID *uuid.UUID) []string {
+	if userID == nil {
+		return []string{limiterGlobalKey}
+	}
+	return []string{limiterGlobalKey, limiterUserKey + userID.String()}
+}
+
+// localSlots is the in-memory semaphore Limiter falls back to.
+type localSlots struct {
+	mu     sync.Mutex
+	global int
+	users  map[uuid.UUID]int
+}
+
+func (s *localSlots) tryAcquire(userID *uuid.UUID, globalCap, userCap int) (func(), bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if globalCap > 0 && s.global >= globalCap {
+		return nil, false
+	}
+	if userID != nil && userCap > 0 && s.users[*userID] >= userCap {
+		return nil, false
+	}
+
+	s.global++
+	if userID != nil {
+		if s.users == nil {
+			s.users = make(map[uuid.UUID]int)
+		}
+		s.users[*userID]++
+	}
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.global--
+		if userID != nil {
+			s.users[*userID]--
+			if s.users[*userID] <= 0 {
+				delete(s.users, *userID)
+			}
+		}
+	}, true
+}
@@ -0,0 +1,40 @@
+package reports
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+func TestLimiterFallsBackToLocalSlots(t *testing.T) {
+	// Nothing listens on port 1, so the limiter runs without Redis
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: 100 * time.Millisecond})
+	defer client.Close()
+	limiter := NewLimiter(client, 2, 1, 0)
+
+	alice, bob := uuid.New(), uuid.New()
+	releaseAlice, ok := limiter.TryAcquire(&alice)
+	if !ok {
+		t.Fatal("Expected the first report to get a slot")
+	}
+	if _, ok := limiter.TryAcquire(&alice); ok {
+		t.Error("Expected the per-user cap to hold without Redis")
+	}
+	releaseBob, ok := limiter.TryAcquire(&bob)
+	if !ok {
+		t.Fatal("Expected another user to get a slot")
+	}
+	if _, ok := limiter.TryAcquire(nil); ok {
+		t.Error("Expected the global cap to hold without Redis")
+	}
+
+	releaseAlice()
+	releaseBob()
+	if release, ok := limiter.TryAcquire(&alice); !ok {
+		t.Error("Expected released slots to be reusable")
+	} else {
+		release()
+	}
+}
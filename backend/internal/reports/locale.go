@@ -0,0 +1,410 @@
+package reports
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"rtims-backend/internal/database"
+
+	"github.com/google/uuid"
+)
+
+// Settings keys for organization-wide report localization. A user's own
+// locale takes precedence over SettingLocale.
+const (
+	SettingLocale = "report_locale"
+	// SettingCurrency is an ISO 4217 code such as "EUR". Prices are shown
+	// as plain numbers when it is empty.
+	SettingCurrency = "report_currency"
+)
+
+// DefaultLocale is used when neither the user nor the organization has
+// chosen one.
+const DefaultLocale = "en"
+
+// Locale controls how dates, numbers and labels appear in CSV and PDF
+// reports. JSON output is never localized.
+type Locale struct {
+	Code           string
+	DateTimeLayout string
+	Decimal        string
+	Thousands      string
+	// CurrencyAfter places the symbol after the amount ("1.234,50 €").
+	CurrencyAfter bool
+	// Messages translates English labels; missing entries fall back to
+	// English.
+	Messages map[string]string
+}
+
+var locales = map[string]*Locale{
+	"en": {
+		Code:           "en",
+		DateTimeLayout: "2006-01-02 15:04:05",
+		Decimal:        ".",
+		Thousands:      ",",
+	},
+	"de": {
+		Code:           "de",
+		DateTimeLayout: "02.01.2006 15:04:05",
+		Decimal:        ",",
+		Thousands:      ".",
+		CurrencyAfter:  true,
+		Messages: map[string]string{
+			"Inventory Report":  "Bestandsbericht",
+			"Movements Report":  "Lagerbewegungsbericht",
+			"Users Report":      "Benutzeraktivitätsbericht",
+			"Generated At":      "Erstellt am",
+			"Report Type":       "Berichtstyp",
+			"ID":                "ID",
+			"Name":              "Name",
+			"SKU":               "Artikelnummer",
+			"Stock":             "Bestand",
+			"Price":             "Preis",
+			"Category":          "Kategorie",
+			"Minimum Threshold": "Mindestbestand",
+			"Product ID":        "Produkt-ID",
+			"Product Name":      "Produktname",
+			"Change":            "Änderung",
+			"Reason":            "Grund",
+			"Created At":        "Erstellt am",
+			"User ID":           "Benutzer-ID",
+			"Actions":           "Aktionen",
+			"Last Action":       "Letzte Aktion",
+			"Total Products":    "Produkte gesamt",
+			"Total Value":       "Gesamtwert",
+			"Low Stock Items":   "Artikel mit niedrigem Bestand",
+			"Movements":         "Bewegungen",
+			"Units In":          "Eingänge",
+			"Units Out":         "Ausgänge",
+			"Active Users":      "Aktive Benutzer",
+			"Total Actions":     "Aktionen gesamt",
+			"Net Change":        "Nettoänderung",
+			"Day":               "Tag",
+			"Week":              "Woche",
+			"Month":             "Monat",
+			"Product":           "Produkt",
+			"User":              "Benutzer",
+			"Email":             "E-Mail",
+			"Creates":           "Angelegt",
+			"Updates":           "Geändert",
+			"Deletes":           "Gelöscht",
+			"Compared To":       "Verglichen mit",
+			"Previous":          "Vorperiode",
+			"Financial Report":  "Finanzbericht",
+			"Products":          "Produkte",
+			"Units In Stock":    "Einheiten auf Lager",
+			"Stock Value":       "Lagerwert",
+			"Units Sold":        "Verkaufte Einheiten",
+			"Sales Value":       "Umsatzwert",
+			"Units Purchased":   "Eingekaufte Einheiten",
+			"Purchase Value":    "Einkaufswert",
+		},
+	},
+	"fr": {
+		Code:           "fr",
+		DateTimeLayout: "02/01/2006 15:04:05",
+		Decimal:        ",",
+		Thousands:      " ",
+		CurrencyAfter:  true,
+		Messages: map[string]string{
+			"Inventory Report":  "Rapport d'inventaire",
+			"Movements Report":  "Rapport des mouvements de stock",
+			"Users Report":      "Rapport d'activité des utilisateurs",
+			"Generated At":      "Généré le",
+			"Report Type":       "Type de rapport",
+			"ID":                "ID",
+			"Name":              "Nom",
+			"SKU":               "Référence",
+			"Stock":             "Stock",
+			"Price":             "Prix",
+			"Category":          "Catégorie",
+			"Minimum Threshold": "Seuil minimum",
+			"Product ID":        "ID produit",
+			"Product Name":      "Nom du produit",
+			"Change":            "Variation",
+			"Reason":            "Motif",
+			"Created At":        "Créé le",
+			"User ID":           "ID utilisateur",
+			"Actions":           "Actions",
+			"Last Action":       "Dernière action",
+			"Total Products":    "Total des produits",
+			"Total Value":       "Valeur totale",
+			"Low Stock Items":   "Articles en stock faible",
+			"Movements":         "Mouvements",
+			"Units In":          "Entrées",
+			"Units Out":         "Sorties",
+			"Active Users":      "Utilisateurs actifs",
+			"Total Actions":     "Total des actions",
+			"Net Change":        "Variation nette",
+			"Day":               "Jour",
+			"Week":              "Semaine",
+			"Month":             "Mois",
+			"Product":           "Produit",
+			"User":              "Utilisateur",
+			"Email":             "E-mail",
+			"Creates":           "Créations",
+			"Updates":           "Modifications",
+			"Deletes":           "Suppressions",
+			"Compared To":       "Comparé à",
+			"Previous":          "Période précédente",
+			"Financial Report":  "Rapport financier",
+			"Products":          "Produits",
+			"Units In Stock":    "Unités en stock",
+			"Stock Value":       "Valeur du stock",
+			"Units Sold":        "Unités vendues",
+			"Sales Value":       "Valeur des ventes",
+			"Units Purchased":   "Unités achetées",
+			"Purchase Value":    "Valeur des achats",
+		},
+	},
+	"id": {
+		Code:           "id",
+		DateTimeLayout: "02/01/2006 15:04:05",
+		Decimal:        ",",
+		Thousands:      ".",
+		Messages: map[string]string{
+			"Inventory Report":  "Laporan Inventaris",
+			"Movements Report":  "Laporan Pergerakan Stok",
+			"Users Report":      "Laporan Aktivitas Pengguna",
+			"Generated At":      "Dibuat pada",
+			"Report Type":       "Jenis laporan",
+			"ID":                "ID",
+			"Name":              "Nama",
+			"SKU":               "SKU",
+			"Stock":             "Stok",
+			"Price":             "Harga",
+			"Category":          "Kategori",
+			"Minimum Threshold": "Stok minimum",
+			"Product ID":        "ID produk",
+			"Product Name":      "Nama produk",
+			"Change":            "Perubahan",
+			"Reason":            "Alasan",
+			"Created At":        "Dibuat pada",
+			"User ID":           "ID pengguna",
+			"Actions":           "Aksi",
+			"Last Action":       "Aksi terakhir",
+			"Total Products":    "Total produk",
+			"Total Value":       "Total nilai",
+			"Low Stock Items":   "Barang stok rendah",
+			"Movements":         "Pergerakan",
+			"Units In":          "Unit masuk",
+			"Units Out":         "Unit keluar",
+			"Active Users":      "Pengguna aktif",
+			"Total Actions":     "Total aksi",
+			"Net Change":        "Perubahan bersih",
+			"Day":               "Hari",
+			"Week":              "Minggu",
+			"Month":             "Bulan",
+			"Product":           "Produk",
+			"User":              "Pengguna",
+			"Email":             "Email",
+			"Creates":           "Dibuat",
+			"Updates":           "Diubah",
+			"Deletes":           "Dihapus",
+			"Compared To":       "Dibandingkan dengan",
+			"Previous":          "Periode sebelumnya",
+			"Financial Report":  "Laporan Keuangan",
+			"Products":          "Produk",
+			"Units In Stock":    "Unit dalam stok",
+			"Stock Value":       "Nilai stok",
+			"Units Sold":        "Unit terjual",
+			"Sales Value":       "Nilai penjualan",
+			"Units Purchased":   "Unit dibeli",
+			"Purchase Value":    "Nilai pembelian",
+		},
+	},
+	"nl": {
+		Code:           "nl",
+		DateTimeLayout: "02-01-2006 15:04:05",
+		Decimal:        ",",
+		Thousands:      ".",
+		Messages: map[string]string{
+			"Inventory Report":  "Voorraadrapport",
+			"Movements Report":  "Voorraadmutatierapport",
+			"Users Report":      "Gebruikersactiviteitrapport",
+			"Generated At":      "Gegenereerd op",
+			"Report Type":       "Rapporttype",
+			"ID":                "ID",
+			"Name":              "Naam",
+			"SKU":               "Artikelnummer",
+			"Stock":             "Voorraad",
+			"Price":             "Prijs",
+			"Category":          "Categorie",
+			"Minimum Threshold": "Minimumvoorraad",
+			"Product ID":        "Product-ID",
+			"Product Name":      "Productnaam",
+			"Change":            "Mutatie",
+			"Reason":            "Reden",
+			"Created At":        "Aangemaakt op",
+			"User ID":           "Gebruikers-ID",
+			"Actions":           "Acties",
+			"Last Action":       "Laatste actie",
+			"Total Products":    "Totaal producten",
+			"Total Value":       "Totale waarde",
+			"Low Stock Items":   "Artikelen met lage voorraad",
+			"Movements":         "Mutaties",
+			"Units In":          "Ontvangen",
+			"Units Out":         "Uitgegeven",
+			"Active Users":      "Actieve gebruikers",
+			"Total Actions":     "Totaal acties",
+			"Net Change":        "Nettomutatie",
+			"Day":               "Dag",
+			"Week":              "Week",
+			"Month":             "Maand",
+			"Product":           "Product",
+			"User":              "Gebruiker",
+			"Email":             "E-mail",
+			"Creates":           "Aangemaakt",
+			"Updates":           "Gewijzigd",
+			"Deletes":           "Verwijderd",
+			"Compared To":       "Vergeleken met",
+			"Previous":          "Vorige periode",
+			"Financial Report":  "Financieel rapport",
+			"Products":          "Producten",
+			"Units In Stock":    "Eenheden op voorraad",
+			"Stock Value":       "Voorraadwaarde",
+			"Units Sold":        "Verkochte eenheden",
+			"Sales Value":       "Verkoopwaarde",
+			"Units Purchased":   "Ingekochte eenheden",
+			"Purchase Value":    "Inkoopwaarde",
+		},
+	},
+}
+
+var currencySymbols = map[string]string{
+	"EUR": "€",
+	"USD": "$",
+	"GBP": "£",
+	"IDR": "Rp",
+	"CHF": "CHF",
+}
+
+// LookupLocale returns the locale for a code such as "de" or "de-DE".
+func LookupLocale(code string) (*Locale, bool) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if i := strings.IndexAny(code, "-_"); i >= 0 {
+		code = code[:i]
+	}
+	locale, ok := locales[code]
+	return locale, ok
+}
+
+// LocaleCodes lists the supported locale codes.
+func LocaleCodes() []string {
+	codes := make([]string, 0, len(locales))
+	for code := range locales {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// T translates an English label.
+func (l *Locale) T(s string) string {
+	if translated, ok := l.Messages[s]; ok {
+		return translated
+	}
+	return s
+}
+
+// FormatNumber formats v with the given number of decimals, grouping
+// thousands when grouped is set.
+func (l *Locale) FormatNumber(v float64, decimals int, grouped bool) string {
+	s := strconv.FormatFloat(math.Abs(v), 'f', decimals, 64)
+	whole, fraction := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, fraction = s[:i], s[i+1:]
+	}
+
+	if grouped && len(whole) > 3 {
+		var b strings.Builder
+		for i, digit := range whole {
+			if i > 0 && (len(whole)-i)%3 == 0 {
+				b.WriteString(l.Thousands)
+			}
+			b.WriteRune(digit)
+		}
+		whole = b.String()
+	}
+
+	if fraction != "" {
+		whole += l.Decimal + fraction
+	}
+	if v < 0 && strings.Trim(s, "0.") != "" {
+		whole = "-" + whole
+	}
+	return whole
+}
+
+// FormatCurrency formats an amount with two decimals and the symbol for the
+// ISO currency code, if any.
+func (l *Locale) FormatCurrency(v float64, currency string, grouped bool) string {
+	amount := l.FormatNumber(v, 2, grouped)
+	if currency == "" {
+		return amount
+	}
+
+	symbol, ok := currencySymbols[strings.ToUpper(currency)]
+	if !ok {
+		symbol = strings.ToUpper(currency)
+	}
+	if l.CurrencyAfter || utf8.RuneCountInString(symbol) > 1 {
+		return amount + " " + symbol
+	}
+	return symbol + amount
+}
+
+// FormatDateTime formats t in the locale's date and time layout.
+func (l *Locale) FormatDateTime(t time.Time) string {
+	return t.Format(l.DateTimeLayout)
+}
+
+// LocaleFor resolves the locale a user's reports should use: their own
+// preference, then the organization setting, then DefaultLocale.
+func LocaleFor(db *sql.DB, userID *uuid.UUID) string {
+	if userID != nil {
+		if locale, err := database.NewUserService(db).GetLocale(context.Background(), *userID); err == nil && locale != "" {
+			return locale
+		}
+	}
+
+	settings, err := database.NewSettingsService(db).GetSettings()
+	if err == nil {
+		if locale, ok := settings[SettingLocale].(string); ok {
+			if _, known := LookupLocale(locale); known {
+				return locale
+			}
+		}
+	}
+	return DefaultLocale
+}
+
+// OrganizationFor returns the organization a user's reports cover, or ""
+// for reports nobody requested.
+func OrganizationFor(db *sql.DB, userID *uuid.UUID) string {
+	if userID == nil {
+		return ""
+	}
+	user, err := database.NewUserService(db).GetUser(context.Background(), *userID)
+	if err != nil {
+		// An unknown requester sees nothing rather than everything
+		return uuid.Nil.String()
+	}
+	return user.OrganizationID.String()
+}
+
+// currencySetting returns the organization's report currency code.
+func currencySetting(db *sql.DB) string {
+	settings, err := database.NewSettingsService(db).GetSettings()
+	if err != nil {
+		return ""
+	}
+	currency, _ := settings[SettingCurrency].(string)
+	return strings.TrimSpace(currency)
+}
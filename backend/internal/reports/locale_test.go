@@ -0,0 +1,80 @@
+package reports
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLookupLocale(t *testing.T) {
+	for _, code := range []string{"de", "DE", "de-DE", "de_AT"} {
+		locale, ok := LookupLocale(code)
+		if !ok || locale.Code != "de" {
+			t.Errorf("LookupLocale(%q): expected de", code)
+		}
+	}
+	if _, ok := LookupLocale("xx"); ok {
+		t.Error("LookupLocale(\"xx\"): expected unknown locale")
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	en, _ := LookupLocale("en")
+	de, _ := LookupLocale("de")
+	fr, _ := LookupLocale("fr")
+
+	tests := []struct {
+		locale   *Locale
+		value    float64
+		decimals int
+		grouped  bool
+		expected string
+	}{
+		{en, 1234567.891, 2, true, "1,234,567.89"},
+		{de, 1234567.891, 2, true, "1.234.567,89"},
+		{de, 1234.5, 2, false, "1234,50"},
+		{fr, 1234.5, 2, true, "1 234,50"},
+		{de, -1500, 0, true, "-1.500"},
+		{en, -0.001, 2, false, "0.00"},
+		{en, 999, 0, true, "999"},
+	}
+	for _, tt := range tests {
+		if got := tt.locale.FormatNumber(tt.value, tt.decimals, tt.grouped); got != tt.expected {
+			t.Errorf("%s FormatNumber(%v): expected %q, got %q", tt.locale.Code, tt.value, tt.expected, got)
+		}
+	}
+
+	if got := de.FormatCurrency(1234.5, "EUR", true); got != "1.234,50 €" {
+		t.Errorf("de FormatCurrency: expected %q, got %q", "1.234,50 €", got)
+	}
+	if got := en.FormatCurrency(1234.5, "usd", true); got != "$1,234.50" {
+		t.Errorf("en FormatCurrency: expected %q, got %q", "$1,234.50", got)
+	}
+}
+
+func TestRenderCSVLocalized(t *testing.T) {
+	report := &Report{
+		Type:     "movements",
+		Format:   "csv",
+		Locale:   "de",
+		Currency: "EUR",
+		Data: []Row{
+			{"id": "m1", "product_id": "p1", "product_name": "Bolt", "change": -3, "reason": "sale",
+				"created_at": time.Date(2024, 3, 9, 14, 30, 0, 0, time.UTC)},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := Render(&buf, report); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if lines[0] != "ID;Produkt-ID;Produktname;Änderung;Grund;Erstellt am" {
+		t.Errorf("unexpected header: %s", lines[0])
+	}
+	if lines[1] != "m1;p1;Bolt;-3;sale;09.03.2024 14:30:00" {
+		t.Errorf("unexpected row: %s", lines[1])
+	}
+}
@@ -0,0 +1,54 @@
+package reports
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestValidateGroupBy(t *testing.T) {
+	tests := []struct {
+		params Params
+		valid  bool
+	}{
+		{Params{Type: "movements", Format: "json", GroupBy: "week"}, true},
+		{Params{Type: "movements", Format: "json", GroupBy: "user"}, true},
+		{Params{Type: "movements", Format: "json", GroupBy: "year"}, false},
+		{Params{Type: "inventory", Format: "json", GroupBy: "day"}, false},
+	}
+	for _, tt := range tests {
+		err := tt.params.Validate()
+		if tt.valid && err != nil {
+			t.Errorf("%s grouped by %s: unexpected error %v", tt.params.Type, tt.params.GroupBy, err)
+		}
+		if !tt.valid && err != ErrUnknownGroupBy {
+			t.Errorf("%s grouped by %s: expected ErrUnknownGroupBy, got %v", tt.params.Type, tt.params.GroupBy, err)
+		}
+	}
+}
+
+func TestRenderGroupedMovements(t *testing.T) {
+	report := &Report{
+		Type:    "movements",
+		Format:  "csv",
+		GroupBy: "reason",
+		Data: []Row{
+			{"key": "purchase", "label": "purchase", "movements": 2, "total_in": 30, "total_out": 0, "net_change": 30},
+			{"key": "sale", "label": "sale", "movements": 3, "total_in": 0, "total_out": 12, "net_change": -12},
+		},
+	}
+	report.Summary = summarize(report.summaryType(), report.Data)
+
+	var buf bytes.Buffer
+	if err := Render(&buf, report); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	expected := "Reason,Movements,Units In,Units Out,Net Change\npurchase,2,30,0,30\nsale,3,0,12,-12\n"
+	if buf.String() != expected {
+		t.Errorf("unexpected CSV:\n%s", buf.String())
+	}
+
+	if net := report.Summary[3]; net.Label != "Net Change" || net.Value != 18 {
+		t.Errorf("expected net change 18, got %v", net.Value)
+	}
+}
@@ -0,0 +1,91 @@
+package reports
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"sort"
+
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/models"
+)
+
+// SettingPermissions holds a JSON object mapping a role to the report types
+// it may run, e.g. {"staff": ["inventory", "movements"]}. Admins may run
+// every report regardless.
+const SettingPermissions = "report_permissions"
+
+var ErrForbiddenType = errors.New("you do not have permission to run this report type")
+
+// defaultPermissions applies while SettingPermissions is unset.
+var defaultPermissions = map[models.UserRole][]string{
+	models.RoleStaff: {"inventory", "movements"},
+}
+
+// DefaultPermissions returns defaultPermissions as a SettingPermissions
+// value.
+func DefaultPermissions() string {
+	encoded, _ := json.Marshal(defaultPermissions)
+	return string(encoded)
+}
+
+// Types lists the supported report types.
+func Types() []string {
+	types := make([]string, 0, len(columns))
+	for reportType := range columns {
+		types = append(types, reportType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// ParsePermissions decodes a SettingPermissions value and checks that it only
+// names known roles and report types.
+func ParsePermissions(raw string) (map[models.UserRole][]string, error) {
+	permissions := map[models.UserRole][]string{}
+	if err := json.Unmarshal([]byte(raw), &permissions); err != nil {
+		return nil, errors.New("report permissions must be a JSON object mapping roles to report types")
+	}
+	for role, types := range permissions {
+		if role != models.RoleStaff && role != models.RoleAdmin {
+			return nil, errors.New("unknown role in report permissions: " + string(role))
+		}
+		for _, reportType := range types {
+			if _, ok := columns[reportType]; !ok {
+				return nil, errors.New("unknown report type in report permissions: " + reportType)
+			}
+		}
+	}
+	return permissions, nil
+}
+
+// AllowedTypes returns the report types role may run.
+func AllowedTypes(db *sql.DB, role models.UserRole) []string {
+	if role == models.RoleAdmin {
+		return Types()
+	}
+
+	permissions := defaultPermissions
+	settings, err := database.NewSettingsService(db).GetSettings()
+	if err == nil {
+		if raw, ok := settings[SettingPermissions].(string); ok && raw != "" {
+			if permissions, err = ParsePermissions(raw); err != nil {
+				log.Printf("Invalid %s setting, denying report access: %v", SettingPermissions, err)
+				return []string{}
+			}
+		}
+	}
+
+	return append([]string{}, permissions[role]...)
+}
+
+// Allowed reports whether role may run reports of reportType.
+func Allowed(db *sql.DB, role models.UserRole, reportType string) bool {
+	for _, allowed := range AllowedTypes(db, role) {
+		if allowed == reportType {
+			return true
+		}
+	}
+	return false
+}
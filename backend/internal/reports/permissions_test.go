@@ -0,0 +1,23 @@
+package reports
+
+import "testing"
+
+func TestParsePermissions(t *testing.T) {
+	permissions, err := ParsePermissions(`{"staff": ["inventory", "users"]}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(permissions["staff"]) != 2 || permissions["staff"][1] != "users" {
+		t.Errorf("unexpected staff permissions: %v", permissions["staff"])
+	}
+
+	for _, raw := range []string{
+		`["inventory"]`,
+		`{"guest": ["inventory"]}`,
+		`{"staff": ["payroll"]}`,
+	} {
+		if _, err := ParsePermissions(raw); err == nil {
+			t.Errorf("ParsePermissions(%s): expected an error", raw)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package reports
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// column describes how one field of a Row is laid out in CSV and PDF output.
+type column struct {
+	Header string
+	Key    string
+	Width  float64 // PDF cell width in mm
+	Align  string  // PDF cell alignment
+	Kind   string  // kindCurrency for prices, otherwise formatted by type
+}
+
+// kindCurrency marks columns and summary items holding money amounts.
+const kindCurrency = "currency"
+
+var columns = map[string][]column{
+	"inventory": {
+		{Header: "ID", Key: "id", Width: 20, Align: "L"},
+		{Header: "Name", Key: "name", Width: 40, Align: "L"},
+		{Header: "SKU", Key: "sku", Width: 25, Align: "L"},
+		{Header: "Stock", Key: "stock", Width: 15, Align: "C"},
+		{Header: "Price", Key: "price", Width: 20, Align: "R", Kind: kindCurrency},
+		{Header: "Category", Key: "category", Width: 30, Align: "L"},
+		{Header: "Minimum Threshold", Key: "minimum_threshold", Width: 20, Align: "C"},
+	},
+	"movements": {
+		{Header: "ID", Key: "id", Width: 25, Align: "L"},
+		{Header: "Product ID", Key: "product_id", Width: 30, Align: "L"},
+		{Header: "Product Name", Key: "product_name", Width: 40, Align: "L"},
+		{Header: "Change", Key: "change", Width: 15, Align: "C"},
+		{Header: "Reason", Key: "reason", Width: 30, Align: "L"},
+		{Header: "Created At", Key: "created_at", Width: 30, Align: "L"},
+	},
+	"users": {
+		{Header: "Name", Key: "name", Width: 35, Align: "L"},
+		{Header: "Email", Key: "email", Width: 50, Align: "L"},
+		{Header: "Actions", Key: "actions", Width: 15, Align: "C"},
+		{Header: "Creates", Key: "creates", Width: 15, Align: "C"},
+		{Header: "Updates", Key: "updates", Width: 15, Align: "C"},
+		{Header: "Deletes", Key: "deletes", Width: 15, Align: "C"},
+		{Header: "Last Action", Key: "last_action", Width: 35, Align: "L"},
+	},
+	"financial": financialColumns,
+}
+
+// ContentType returns the MIME type for a report format, or "" if the
+// format is not supported.
+func ContentType(format string) string {
+	switch format {
+	case "json":
+		return "application/json"
+	case "csv":
+		return "text/csv"
+	case "pdf":
+		return "application/pdf"
+	case "xlsx":
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return ""
+}
+
+// Filename returns the download filename for a report.
+func Filename(report *Report) string {
+	return fmt.Sprintf("%s_report_%s.%s", report.Type, report.GeneratedAt.Format("2006-01-02_15-04-05"), report.Format)
+}
+
+// Render writes the report to w in its requested format.
+func Render(w io.Writer, report *Report) error {
+	switch report.Format {
+	case "json":
+		return json.NewEncoder(w).Encode(report)
+	case "csv":
+		return renderCSV(w, report)
+	case "pdf":
+		return renderPDF(w, report)
+	case "xlsx":
+		return renderXLSX(w, report)
+	}
+	return ErrUnsupportedFormat
+}
+
+// formatValue renders a value in the report's locale. PDF output groups
+// thousands and adds currency symbols; CSV output stays plain so that
+// spreadsheets can parse it.
+func (r *Report) formatValue(value interface{}, kind string, forPDF bool) string {
+	locale := r.locale()
+	switch v := value.(type) {
+	case time.Time:
+		return locale.FormatDateTime(v)
+	case float64:
+		if kind == kindCurrency && forPDF {
+			return locale.FormatCurrency(v, r.Currency, true)
+		}
+		return locale.FormatNumber(v, 2, forPDF)
+	case int:
+		return locale.FormatNumber(float64(v), 0, forPDF)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// columns returns the layout of the report's rows.
+func (r *Report) columns() []column {
+	if r.GroupBy != "" {
+		return groupedMovementColumns(r.GroupBy)
+	}
+	return columns[r.Type]
+}
+
+func (r *Report) locale() *Locale {
+	if locale, ok := LookupLocale(r.Locale); ok {
+		return locale
+	}
+	return locales[DefaultLocale]
+}
+
+// header returns the translated column heading. Currency columns name the
+// currency in CSV and XLSX output, where amounts carry no symbol.
+func (r *Report) header(col column) string {
+	header := r.locale().T(col.Header)
+	if col.Kind == kindCurrency && r.Currency != "" {
+		header += " (" + strings.ToUpper(r.Currency) + ")"
+	}
+	return header
+}
+
+func renderCSV(w io.Writer, report *Report) error {
+	cols := report.columns()
+	writer := csv.NewWriter(w)
+	// Spreadsheets in comma-decimal locales expect semicolon-separated CSV
+	if report.locale().Decimal == "," {
+		writer.Comma = ';'
+	}
+
+	header := make([]string, len(cols))
+	for i, col := range cols {
+		header[i] = report.header(col)
+	}
+	writer.Write(header)
+
+	for _, row := range report.Data {
+		record := make([]string, len(cols))
+		for i, col := range cols {
+			record[i] = report.formatValue(row[col.Key], col.Kind, false)
+		}
+		writer.Write(record)
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// Table returns the translated column headings of a report and its rows
+// as unformatted values in column order, for spreadsheets.
+func Table(report *Report) ([]string, [][]interface{}) {
+	cols := report.columns()
+
+	header := make([]string, len(cols))
+	for i, col := range cols {
+		header[i] = report.header(col)
+	}
+
+	rows := make([][]interface{}, len(report.Data))
+	for i, row := range report.Data {
+		values := make([]interface{}, len(cols))
+		for j, col := range cols {
+			values[j] = row[col.Key]
+		}
+		rows[i] = values
+	}
+	return header, rows
+}
+
+func renderXLSX(w io.Writer, report *Report) error {
+	xw, err := NewXLSXWriter(w, strings.Title(report.Type))
+	if err != nil {
+		return err
+	}
+
+	header, rows := Table(report)
+	if err := xw.WriteHeader(header); err != nil {
+		return err
+	}
+	for _, values := range rows {
+		if err := xw.WriteRow(values); err != nil {
+			return err
+		}
+	}
+
+	return xw.Close()
+}
+
+func renderPDF(w io.Writer, report *Report) error {
+	cols := report.columns()
+	branding := report.Branding
+	if branding == nil {
+		branding = &Branding{Color: defaultColor}
+	}
+
+	locale := report.locale()
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	// The core fonts are cp1252; translate UTF-8 labels and names into it
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.AddPage()
+	renderPDFHeader(pdf, branding, tr)
+
+	pdf.SetFont("Arial", "B", 16)
+
+	// Title
+	pdf.Cell(40, 10, tr(locale.T(fmt.Sprintf("%s Report", strings.Title(report.Type)))))
+	pdf.Ln(12)
+
+	pdf.SetFont("Arial", "", 10)
+
+	// Report metadata
+	pdf.Cell(40, 6, tr(fmt.Sprintf("%s: %s", locale.T("Generated At"), locale.FormatDateTime(report.GeneratedAt))))
+	pdf.Ln(6)
+	pdf.Cell(40, 6, tr(fmt.Sprintf("%s: %s", locale.T("Report Type"), report.Type)))
+	pdf.Ln(6)
+	if report.Comparison != nil {
+		pdf.Cell(40, 6, tr(fmt.Sprintf("%s: %s - %s", locale.T("Compared To"), report.Comparison.StartDate, report.Comparison.EndDate)))
+		pdf.Ln(6)
+	}
+	for _, item := range report.Summary {
+		value := report.formatValue(item.Value, item.Kind, true) + report.formatComparison(item)
+		pdf.Cell(40, 6, tr(fmt.Sprintf("%s: %s", locale.T(item.Label), value)))
+		pdf.Ln(6)
+	}
+	pdf.Ln(4)
+
+	if report.Layout == LayoutSummary {
+		return pdf.Output(w)
+	}
+
+	// Table header
+	pdf.SetFont("Arial", "B", 8)
+	pdf.SetFillColor(branding.Color.R, branding.Color.G, branding.Color.B)
+	if !branding.Color.light() {
+		pdf.SetTextColor(255, 255, 255)
+	}
+	for _, col := range cols {
+		pdf.CellFormat(col.Width, 8, tr(locale.T(col.Header)), "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(8)
+	pdf.SetTextColor(0, 0, 0)
+
+	// Table data
+	pdf.SetFont("Arial", "", 7)
+	pdf.SetFillColor(255, 255, 255)
+	for _, row := range report.Data {
+		for _, col := range cols {
+			value := report.formatValue(row[col.Key], col.Kind, true)
+			pdf.CellFormat(col.Width, 6, tr(value), "1", 0, col.Align, false, 0, "")
+		}
+		pdf.Ln(6)
+	}
+
+	return pdf.Output(w)
+}
+
+// renderPDFHeader prints the logo and company details above the report
+// title, followed by a rule in the brand color.
+func renderPDFHeader(pdf *gofpdf.Fpdf, branding *Branding, tr func(string) string) {
+	if branding.LogoPath == "" && branding.CompanyName == "" && branding.CompanyDetails == "" {
+		return
+	}
+
+	left, top, right, _ := pdf.GetMargins()
+	pageWidth, _ := pdf.GetPageSize()
+	textX := left
+	headerHeight := 0.0
+
+	if branding.LogoPath != "" {
+		info := pdf.RegisterImageOptions(branding.LogoPath, gofpdf.ImageOptions{ReadDpi: true})
+		if pdf.Ok() {
+			// Scale the logo to 15mm high, capped at 50mm wide
+			height := 15.0
+			width := info.Width() * height / info.Height()
+			if width > 50 {
+				width, height = 50, 50*info.Height()/info.Width()
+			}
+			pdf.ImageOptions(branding.LogoPath, left, top, width, height, false, gofpdf.ImageOptions{ReadDpi: true}, 0, "")
+			textX += width + 5
+			headerHeight = height
+		}
+	}
+
+	pdf.SetXY(textX, top)
+	if branding.CompanyName != "" {
+		pdf.SetFont("Arial", "B", 12)
+		pdf.CellFormat(0, 6, tr(branding.CompanyName), "", 2, "L", false, 0, "")
+	}
+	if branding.CompanyDetails != "" {
+		pdf.SetFont("Arial", "", 8)
+		pdf.SetX(textX)
+		pdf.MultiCell(pageWidth-right-textX, 4, tr(branding.CompanyDetails), "", "L", false)
+	}
+	if y := pdf.GetY() - top; y > headerHeight {
+		headerHeight = y
+	}
+
+	ruleY := top + headerHeight + 3
+	pdf.SetDrawColor(branding.Color.R, branding.Color.G, branding.Color.B)
+	pdf.SetLineWidth(0.8)
+	pdf.Line(left, ruleY, pageWidth-right, ruleY)
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.SetLineWidth(0.2)
+	pdf.SetXY(left, ruleY+5)
+}
@@ -0,0 +1,418 @@
+package reports
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUnknownType       = errors.New("invalid report type")
+	ErrUnsupportedFormat = errors.New("unsupported format. Supported formats: json, csv, pdf, xlsx")
+	ErrUnknownLayout     = errors.New("invalid layout. Supported layouts: detailed, summary")
+	ErrInvalidUserID     = errors.New("invalid user_id")
+	ErrInvalidProductID  = errors.New("invalid product_id")
+	ErrUnknownReason     = errors.New("invalid reason. Supported reasons: purchase, sale, adjustment, return, damage, transfer")
+	ErrInvalidLimit      = errors.New("limit must not be negative")
+	ErrUnknownLocale     = errors.New("unsupported locale. Supported locales: " + strings.Join(LocaleCodes(), ", "))
+)
+
+// PDF layouts. The summary layout leaves out the data table, for sharing
+// totals with external partners.
+const (
+	LayoutDetailed = "detailed"
+	LayoutSummary  = "summary"
+)
+
+// Row is a single line of report data keyed by column.
+type Row = map[string]interface{}
+
+// Params selects the report to build and how to render it. Filters that do
+// not apply to the report type are ignored.
+type Params struct {
+	Type      string `json:"type"`
+	Format    string `json:"format"`
+	StartDate string `json:"start_date,omitempty"`
+	EndDate   string `json:"end_date,omitempty"`
+	Category  string `json:"category,omitempty"`
+	ProductID string `json:"product_id,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	// UserID narrows the users report to a single user.
+	UserID string `json:"user_id,omitempty"`
+	// Limit caps the number of rows; 0 means no limit.
+	Limit int `json:"limit,omitempty"`
+	// Layout applies to PDF output only and defaults to LayoutDetailed.
+	Layout string `json:"layout,omitempty"`
+	// Locale formats CSV and PDF output, e.g. "de". Defaults to the
+	// requesting user's locale; see LocaleFor.
+	Locale string `json:"locale,omitempty"`
+	// GroupBy aggregates movement reports into one row per day, week,
+	// month, product, reason or user instead of one row per movement.
+	GroupBy string `json:"group_by,omitempty"`
+	// CompareTo adds the figures of an earlier period to the summary:
+	// ComparePreviousPeriod or CompareSamePeriodLastYear. It requires
+	// StartDate and EndDate.
+	CompareTo string `json:"compare_to,omitempty"`
+	// OrganizationID limits the report to one organization's data. It is
+	// set from the requester, never by clients; empty covers every
+	// organization.
+	OrganizationID string `json:"organization_id,omitempty"`
+}
+
+// SummaryItem is one headline figure of a report, e.g. "Total Value".
+type SummaryItem struct {
+	Label string      `json:"label"`
+	Value interface{} `json:"value"`
+	Kind  string      `json:"-"`
+	// Previous, Delta and PercentChange are set for compared reports.
+	// PercentChange is omitted when the previous value is zero.
+	Previous      interface{} `json:"previous,omitempty"`
+	Delta         interface{} `json:"delta,omitempty"`
+	PercentChange *float64    `json:"percent_change,omitempty"`
+}
+
+type Report struct {
+	Type        string        `json:"report_type"`
+	Format      string        `json:"format"`
+	Layout      string        `json:"layout"`
+	Locale      string        `json:"locale"`
+	Currency    string        `json:"currency,omitempty"`
+	GroupBy     string        `json:"group_by,omitempty"`
+	GeneratedAt time.Time     `json:"generated_at"`
+	Summary     []SummaryItem `json:"summary"`
+	Comparison  *Period       `json:"comparison,omitempty"`
+	Data        []Row         `json:"data"`
+	// Branding is printed in PDF headers when set.
+	Branding *Branding `json:"-"`
+}
+
+// Validate checks that the report type, format and layout are supported.
+func (p Params) Validate() error {
+	if _, ok := columns[p.Type]; !ok {
+		return ErrUnknownType
+	}
+	if ContentType(p.Format) == "" {
+		return ErrUnsupportedFormat
+	}
+	if p.Layout != "" && p.Layout != LayoutDetailed && p.Layout != LayoutSummary {
+		return ErrUnknownLayout
+	}
+	if _, ok := LookupLocale(p.Locale); p.Locale != "" && !ok {
+		return ErrUnknownLocale
+	}
+	if !validGroupBy(p.Type, p.GroupBy) {
+		return ErrUnknownGroupBy
+	}
+	if _, err := uuid.Parse(p.UserID); p.UserID != "" && err != nil {
+		return ErrInvalidUserID
+	}
+	if _, err := uuid.Parse(p.ProductID); p.ProductID != "" && err != nil {
+		return ErrInvalidProductID
+	}
+	if p.Reason != "" && !validReason(p.Reason) {
+		return ErrUnknownReason
+	}
+	for _, date := range []string{p.StartDate, p.EndDate} {
+		if _, _, err := parseDate(date); date != "" && err != nil {
+			return err
+		}
+	}
+	if p.Limit < 0 {
+		return ErrInvalidLimit
+	}
+	if !validComparison(p.Type, p.CompareTo) {
+		return ErrUnknownComparison
+	}
+	if p.CompareTo != "" {
+		if _, err := comparisonPeriod(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Generate runs the queries for the requested report, on the read replica
+// when one is configured.
+func Generate(db *sql.DB, params Params) (*Report, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	db = database.ForReads(db)
+
+	report := &Report{
+		Type:        params.Type,
+		Format:      params.Format,
+		Layout:      params.Layout,
+		Locale:      params.Locale,
+		Currency:    currencySetting(db),
+		GroupBy:     params.GroupBy,
+		GeneratedAt: time.Now(),
+		Data:        []Row{},
+	}
+	if report.Layout == "" {
+		report.Layout = LayoutDetailed
+	}
+	if report.Locale == "" {
+		report.Locale = DefaultLocale
+	}
+
+	var err error
+	if report.Data, err = generateData(db, params); err != nil {
+		return nil, fmt.Errorf("failed to generate %s report: %w", params.Type, err)
+	}
+	report.Summary = summarize(report.summaryType(), report.Data)
+
+	if params.CompareTo != "" {
+		if err := report.compare(db, params); err != nil {
+			return nil, fmt.Errorf("failed to generate %s comparison: %w", params.Type, err)
+		}
+	}
+
+	return report, nil
+}
+
+// generateData runs the query for the report's rows.
+func generateData(db *sql.DB, params Params) ([]Row, error) {
+	switch params.Type {
+	case "inventory":
+		return generateInventory(db, params)
+	case "movements":
+		if params.GroupBy != "" {
+			return generateMovementGroups(db, params)
+		}
+		return generateMovements(db, params)
+	case "users":
+		return generateUsers(db, params)
+	case "financial":
+		return generateFinancial(db, params)
+	}
+	return nil, ErrUnknownType
+}
+
+// summaryType picks the summary for the report's rows, which differ when
+// movements are grouped.
+func (r *Report) summaryType() string {
+	if r.GroupBy != "" {
+		return "movement_groups"
+	}
+	return r.Type
+}
+
+// summarize computes the headline figures shown above the data.
+func summarize(reportType string, data []Row) []SummaryItem {
+	switch reportType {
+	case "inventory":
+		var totalValue float64
+		var lowStock int
+		for _, row := range data {
+			stock := row["stock"].(int)
+			totalValue += row["price"].(float64) * float64(stock)
+			if stock <= row["minimum_threshold"].(int) {
+				lowStock++
+			}
+		}
+		return []SummaryItem{
+			{Label: "Total Products", Value: len(data)},
+			{Label: "Total Value", Value: totalValue, Kind: kindCurrency},
+			{Label: "Low Stock Items", Value: lowStock},
+		}
+	case "movements":
+		var stockIn, stockOut int
+		for _, row := range data {
+			if change := row["change"].(int); change > 0 {
+				stockIn += change
+			} else {
+				stockOut -= change
+			}
+		}
+		return []SummaryItem{
+			{Label: "Movements", Value: len(data)},
+			{Label: "Units In", Value: stockIn},
+			{Label: "Units Out", Value: stockOut},
+		}
+	case "movement_groups":
+		var movements, stockIn, stockOut int
+		for _, row := range data {
+			movements += row["movements"].(int)
+			stockIn += row["total_in"].(int)
+			stockOut += row["total_out"].(int)
+		}
+		return []SummaryItem{
+			{Label: "Movements", Value: movements},
+			{Label: "Units In", Value: stockIn},
+			{Label: "Units Out", Value: stockOut},
+			{Label: "Net Change", Value: stockIn - stockOut},
+		}
+	case "users":
+		var actions int
+		for _, row := range data {
+			actions += row["actions"].(int)
+		}
+		return []SummaryItem{
+			{Label: "Active Users", Value: len(data)},
+			{Label: "Total Actions", Value: actions},
+		}
+	case "financial":
+		var stockValue, salesValue, purchaseValue float64
+		for _, row := range data {
+			stockValue += row["stock_value"].(float64)
+			salesValue += row["sales_value"].(float64)
+			purchaseValue += row["purchase_value"].(float64)
+		}
+		return []SummaryItem{
+			{Label: "Stock Value", Value: stockValue, Kind: kindCurrency},
+			{Label: "Sales Value", Value: salesValue, Kind: kindCurrency},
+			{Label: "Purchase Value", Value: purchaseValue, Kind: kindCurrency},
+		}
+	}
+	return nil
+}
+
+// validReason reports whether reason is a stock movement reason.
+func validReason(reason string) bool {
+	switch models.MovementReason(reason) {
+	case models.ReasonPurchase, models.ReasonSale, models.ReasonAdjustment,
+		models.ReasonReturn, models.ReasonDamage, models.ReasonTransfer:
+		return true
+	}
+	return false
+}
+
+// filterQuery appends the WHERE clause, ORDER BY and LIMIT to query.
+func filterQuery(query string, conditions []string, orderBy string, limit int) string {
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY " + orderBy
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit)
+	}
+	return query
+}
+
+func generateInventory(db *sql.DB, params Params) ([]Row, error) {
+	query := "SELECT id, name, sku, stock, price, category, minimum_threshold FROM products"
+	args := []interface{}{}
+	conditions := []string{}
+
+	if params.StartDate != "" {
+		args = append(args, params.StartDate)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if params.EndDate != "" {
+		args = append(args, params.EndDate)
+		conditions = append(conditions, fmt.Sprintf("created_at <= $%d", len(args)))
+	}
+	if params.Category != "" {
+		args = append(args, params.Category)
+		conditions = append(conditions, fmt.Sprintf("category = $%d", len(args)))
+	}
+	if params.OrganizationID != "" {
+		args = append(args, params.OrganizationID)
+		conditions = append(conditions, fmt.Sprintf("organization_id = $%d", len(args)))
+	}
+
+	rows, err := db.Query(filterQuery(query, conditions, "name", params.Limit), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := []Row{}
+	for rows.Next() {
+		var id, name, sku, category string
+		var stock int
+		var price float64
+		var minimumThreshold int
+
+		if err := rows.Scan(&id, &name, &sku, &stock, &price, &category, &minimumThreshold); err != nil {
+			return nil, err
+		}
+
+		products = append(products, Row{
+			"id":                id,
+			"name":              name,
+			"sku":               sku,
+			"stock":             stock,
+			"price":             price,
+			"category":          category,
+			"minimum_threshold": minimumThreshold,
+		})
+	}
+
+	return products, rows.Err()
+}
+
+// movementConditions returns the WHERE conditions and arguments for the
+// movement filters in params.
+func movementConditions(params Params) ([]string, []interface{}) {
+	args := []interface{}{}
+	conditions := []string{}
+
+	if params.StartDate != "" {
+		args = append(args, params.StartDate)
+		conditions = append(conditions, fmt.Sprintf("sm.created_at >= $%d", len(args)))
+	}
+	if params.EndDate != "" {
+		args = append(args, params.EndDate)
+		conditions = append(conditions, fmt.Sprintf("sm.created_at <= $%d", len(args)))
+	}
+	if params.ProductID != "" {
+		args = append(args, params.ProductID)
+		conditions = append(conditions, fmt.Sprintf("sm.product_id = $%d", len(args)))
+	}
+	if params.Reason != "" {
+		args = append(args, params.Reason)
+		conditions = append(conditions, fmt.Sprintf("sm.reason = $%d", len(args)))
+	}
+	if params.OrganizationID != "" {
+		args = append(args, params.OrganizationID)
+		conditions = append(conditions, fmt.Sprintf("sm.organization_id = $%d", len(args)))
+	}
+
+	return conditions, args
+}
+
+func generateMovements(db *sql.DB, params Params) ([]Row, error) {
+	query := `
+		SELECT sm.id, sm.product_id, sm.change, sm.reason, sm.created_at, p.name
+		FROM stock_movements sm
+		JOIN products p ON sm.product_id = p.id
+	`
+	conditions, args := movementConditions(params)
+
+	rows, err := db.Query(filterQuery(query, conditions, "sm.created_at DESC", params.Limit), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movements := []Row{}
+	for rows.Next() {
+		var id, productID, reason, productName string
+		var change int
+		var createdAt time.Time
+
+		if err := rows.Scan(&id, &productID, &change, &reason, &createdAt, &productName); err != nil {
+			return nil, err
+		}
+
+		movements = append(movements, Row{
+			"id":           id,
+			"product_id":   productID,
+			"product_name": productName,
+			"change":       change,
+			"reason":       reason,
+			"created_at":   createdAt,
+		})
+	}
+
+	return movements, rows.Err()
+}
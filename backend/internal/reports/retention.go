@@ -0,0 +1,66 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/storage"
+)
+
+// SettingRetentionDays is how many days generated reports are kept; 0 or
+// unset keeps them forever.
+const SettingRetentionDays = "report_retention_days"
+
+// PurgeExpired deletes the finished reports older than
+// report_retention_days, with their artifacts. Artifacts it fails to
+// delete are left to the storage cleanup, as no report refers to them.
+func (q *Queue) PurgeExpired() error {
+	settings, err := database.NewSettingsService(q.db).GetSettings()
+	if err != nil {
+		return err
+	}
+	raw, _ := settings[SettingRetentionDays].(string)
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+	days, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil || days < 0 {
+		return fmt.Errorf("invalid %s setting %q", SettingRetentionDays, raw)
+	}
+	if days == 0 {
+		return nil
+	}
+
+	ctx := context.Background()
+	keys, err := q.reportService.DeleteReportsBefore(ctx, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := q.deleteArtifact(ctx, key); err != nil {
+			log.Printf("Failed to delete report artifact %s: %v", key, err)
+		}
+	}
+	if len(keys) > 0 {
+		log.Printf("Report retention deleted %d report artifacts", len(keys))
+	}
+	return nil
+}
+
+func (q *Queue) deleteArtifact(ctx context.Context, key string) error {
+	if !strings.HasPrefix(key, storage.PrefixReports) {
+		err := os.Remove(filepath.Join(q.dir, filepath.Base(key)))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return q.store.Delete(ctx, key)
+}
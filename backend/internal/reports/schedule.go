@@ -0,0 +1,155 @@
+package reports
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/email"
+	"rtims-backend/internal/models"
+)
+
+// maxAttachmentSize is the largest report sent as an attachment; bigger
+// reports are linked instead.
+const maxAttachmentSize = 10 << 20
+
+// Scheduler runs report schedules as they come due and emails the results.
+type Scheduler struct {
+	queue           *Queue
+	scheduleService *database.ReportScheduleService
+	mailer          *email.Mailer
+	publicURL       string
+}
+
+func NewScheduler(db *sql.DB, queue *Queue, mailer *email.Mailer, publicURL string) *Scheduler {
+	return &Scheduler{
+		queue:           queue,
+		scheduleService: database.NewReportScheduleService(db),
+		mailer:          mailer,
+		publicURL:       publicURL,
+	}
+}
+
+// NextRun returns when a schedule with the given cron expression should next
+// run after t, or nil if it never will.
+func NextRun(cronExpression string, t time.Time) (*time.Time, error) {
+	schedule, err := ParseCron(cronExpression)
+	if err != nil {
+		return nil, err
+	}
+	next := schedule.Next(t)
+	if next.IsZero() {
+		return nil, nil
+	}
+	return &next, nil
+}
+
+// RunDue runs the schedules that have come due. It is meant to run every
+// minute.
+func (s *Scheduler) RunDue() error {
+	due, err := s.scheduleService.GetDueSchedules(time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to get due report schedules: %w", err)
+	}
+
+	for i := range due {
+		s.runSchedule(&due[i])
+	}
+	return nil
+}
+
+func (s *Scheduler) runSchedule(schedule *models.ReportSchedule) {
+	nextRunAt, err := NextRun(schedule.CronExpression, time.Now())
+	if err != nil {
+		log.Printf("Report schedule %s has an invalid cron expression: %v", schedule.ID, err)
+		nextRunAt = nil
+	}
+
+	claimed, err := s.scheduleService.ClaimRun(schedule.ID, *schedule.NextRunAt, nextRunAt)
+	if err != nil {
+		log.Printf("Failed to claim report schedule %s: %v", schedule.ID, err)
+		return
+	}
+	if !claimed {
+		return // Another instance is running it
+	}
+
+	var params Params
+	if err := json.Unmarshal(schedule.Report, &params); err != nil {
+		log.Printf("Report schedule %s has invalid parameters: %v", schedule.ID, err)
+		return
+	}
+
+	report, err := s.queue.RunNow(params, schedule.CreatedBy)
+	if err != nil {
+		log.Printf("Report schedule %s failed to start: %v", schedule.ID, err)
+		return
+	}
+
+	if err := s.scheduleService.SetLastReport(schedule.ID, report.ID); err != nil {
+		log.Printf("Failed to record last report for schedule %s: %v", schedule.ID, err)
+	}
+
+	if report.Status != models.ReportCompleted {
+		log.Printf("Report schedule %s produced a failed report %s: %s", schedule.ID, report.ID, report.Error)
+		return
+	}
+
+	s.deliver(schedule, report)
+}
+
+func (s *Scheduler) deliver(schedule *models.ReportSchedule, report *models.Report) {
+	if !s.mailer.Enabled() {
+		log.Printf("Skipping email for report schedule %s: email is not configured", schedule.ID)
+		return
+	}
+
+	var attachments []email.Attachment
+	if report.Size <= maxAttachmentSize {
+		data, err := s.readArtifact(report)
+		if err != nil {
+			log.Printf("Failed to read report %s for email: %v", report.ID, err)
+		} else {
+			attachments = append(attachments, email.Attachment{
+				Filename:    report.Filename,
+				ContentType: ContentType(report.Format),
+				Data:        data,
+			})
+		}
+	}
+
+	body, err := email.Render("report.html", map[string]interface{}{
+		"ScheduleName": schedule.Name,
+		"ReportType":   report.Type,
+		"Format":       report.Format,
+		"RowCount":     report.RowCount,
+		"Attached":     len(attachments) > 0,
+		"DownloadURL":  fmt.Sprintf("%s/api/v1/reports/%s/download", s.publicURL, report.ID),
+		"GeneratedAt":  time.Now(),
+	})
+	if err != nil {
+		log.Printf("Failed to render report email: %v", err)
+		return
+	}
+
+	subject := fmt.Sprintf("[RTIMS] %s", schedule.Name)
+	for _, recipient := range schedule.Recipients {
+		if err := s.mailer.SendWithAttachments(recipient, subject, body, attachments...); err != nil {
+			log.Printf("Failed to email report %s to %s: %v", report.ID, recipient, err)
+		}
+	}
+}
+
+func (s *Scheduler) readArtifact(report *models.Report) ([]byte, error) {
+	artifact, err := s.queue.Open(context.Background(), report)
+	if err != nil {
+		return nil, err
+	}
+	defer artifact.Close()
+	return io.ReadAll(artifact)
+}
@@ -0,0 +1,175 @@
+package reports
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// auditConditions returns the WHERE conditions and arguments for the user
+// activity filters in params.
+func auditConditions(params Params) ([]string, []interface{}) {
+	args := []interface{}{}
+	conditions := []string{"al.changed_by IS NOT NULL"}
+
+	if params.StartDate != "" {
+		args = append(args, params.StartDate)
+		conditions = append(conditions, fmt.Sprintf("al.changed_at >= $%d", len(args)))
+	}
+	if params.EndDate != "" {
+		args = append(args, params.EndDate)
+		conditions = append(conditions, fmt.Sprintf("al.changed_at <= $%d", len(args)))
+	}
+	if params.UserID != "" {
+		args = append(args, params.UserID)
+		conditions = append(conditions, fmt.Sprintf("al.changed_by = $%d", len(args)))
+	}
+	if params.OrganizationID != "" {
+		args = append(args, params.OrganizationID)
+		conditions = append(conditions, fmt.Sprintf("al.organization_id = $%d", len(args)))
+	}
+
+	return conditions, args
+}
+
+// generateUsers returns one row per user with their name, email, action
+// counts and last action. JSON output also carries a drill-down of actions
+// per entity ("by_entity") and a per-day series ("daily").
+func generateUsers(db *sql.DB, params Params) ([]Row, error) {
+	conditions, args := auditConditions(params)
+	query := `
+		SELECT al.changed_by, COALESCE(u.name, ''), COALESCE(u.email, ''), COUNT(*) AS actions,
+		       COUNT(*) FILTER (WHERE al.action = 'create'),
+		       COUNT(*) FILTER (WHERE al.action = 'update'),
+		       COUNT(*) FILTER (WHERE al.action = 'delete'),
+		       MAX(al.changed_at)
+		FROM audit_logs al
+		LEFT JOIN users u ON al.changed_by = u.id
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		GROUP BY al.changed_by, u.name, u.email`
+
+	rows, err := db.Query(filterQuery(query, nil, "actions DESC", params.Limit), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	activities := []Row{}
+	byUser := map[uuid.UUID]Row{}
+	for rows.Next() {
+		var userID uuid.UUID
+		var name, email string
+		var actions, creates, updates, deletes int
+		var lastAction time.Time
+
+		if err := rows.Scan(&userID, &name, &email, &actions, &creates, &updates, &deletes, &lastAction); err != nil {
+			return nil, err
+		}
+
+		activity := Row{
+			"user_id":     userID,
+			"name":        name,
+			"email":       email,
+			"actions":     actions,
+			"creates":     creates,
+			"updates":     updates,
+			"deletes":     deletes,
+			"last_action": lastAction,
+			"by_entity":   map[string]map[string]int{},
+			"daily":       []Row{},
+		}
+		activities = append(activities, activity)
+		byUser[userID] = activity
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(activities) == 0 {
+		return activities, nil
+	}
+	if err := addEntityBreakdown(db, conditions, args, byUser); err != nil {
+		return nil, err
+	}
+	if err := addDailySeries(db, conditions, args, byUser); err != nil {
+		return nil, err
+	}
+
+	return activities, nil
+}
+
+// addEntityBreakdown fills each user's by_entity map with action counts per
+// table, e.g. {"products": {"update": 4}}.
+func addEntityBreakdown(db *sql.DB, conditions []string, args []interface{}, byUser map[uuid.UUID]Row) error {
+	query := `
+		SELECT al.changed_by, al.table_name, al.action, COUNT(*)
+		FROM audit_logs al
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		GROUP BY 1, 2, 3`
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID uuid.UUID
+		var entity, action string
+		var count int
+
+		if err := rows.Scan(&userID, &entity, &action, &count); err != nil {
+			return err
+		}
+
+		activity, ok := byUser[userID]
+		if !ok {
+			continue // Cut off by the limit
+		}
+		breakdown := activity["by_entity"].(map[string]map[string]int)
+		if breakdown[entity] == nil {
+			breakdown[entity] = map[string]int{}
+		}
+		breakdown[entity][action] = count
+	}
+
+	return rows.Err()
+}
+
+// addDailySeries fills each user's daily series with their action count per
+// day, oldest first.
+func addDailySeries(db *sql.DB, conditions []string, args []interface{}, byUser map[uuid.UUID]Row) error {
+	query := `
+		SELECT al.changed_by, to_char(date_trunc('day', al.changed_at), 'YYYY-MM-DD'), COUNT(*)
+		FROM audit_logs al
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		GROUP BY 1, 2
+		ORDER BY 2`
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID uuid.UUID
+		var day string
+		var count int
+
+		if err := rows.Scan(&userID, &day, &count); err != nil {
+			return err
+		}
+
+		activity, ok := byUser[userID]
+		if !ok {
+			continue
+		}
+		activity["daily"] = append(activity["daily"].([]Row), Row{"date": day, "actions": count})
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,66 @@
+package reports
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestColumnName(t *testing.T) {
+	tests := map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"}
+	for index, expected := range tests {
+		if got := columnName(index); got != expected {
+			t.Errorf("columnName(%d): expected %s, got %s", index, expected, got)
+		}
+	}
+}
+
+func TestXLSXWriter(t *testing.T) {
+	var buf bytes.Buffer
+	xw, err := NewXLSXWriter(&buf, "Inventory")
+	if err != nil {
+		t.Fatalf("NewXLSXWriter failed: %v", err)
+	}
+	if err := xw.WriteHeader([]string{"Name", "Stock", "Price"}); err != nil {
+		t.Fatalf("WriteHeader failed: %v", err)
+	}
+	if err := xw.WriteRow([]interface{}{"Bolts & <Nuts>", 12, 1.5}); err != nil {
+		t.Fatalf("WriteRow failed: %v", err)
+	}
+	if err := xw.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Output is not a valid zip archive: %v", err)
+	}
+
+	var sheet string
+	for _, f := range zr.File {
+		if f.Name != "xl/worksheets/sheet1.xml" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("Failed to open sheet: %v", err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		sheet = string(data)
+	}
+
+	expected := []string{
+		`<c r="A1" s="1" t="inlineStr"><is><t>Name</t></is></c>`,
+		`<c r="A2" t="inlineStr"><is><t>Bolts &amp; &lt;Nuts&gt;</t></is></c>`,
+		`<c r="B2"><v>12</v></c>`,
+		`<c r="C2"><v>1.5</v></c>`,
+	}
+	for _, cell := range expected {
+		if !strings.Contains(sheet, cell) {
+			t.Errorf("Expected sheet to contain %s", cell)
+		}
+	}
+}
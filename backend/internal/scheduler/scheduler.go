@@ -0,0 +1,225 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"rtims-backend/internal/database"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+)
+
+// SettingPrefix prefixes the system setting that overrides a job's default
+// schedule, e.g. schedule_backup. The value is a five-field cron expression
+// such as "0 2 * * *", a descriptor such as "@daily" or "@every 6h", or
+// "off" to stop running the job.
+const SettingPrefix = "schedule_"
+
+// ScheduleOff disables a job.
+const ScheduleOff = "off"
+
+const (
+	leaderKey = "scheduler:leader"
+	// leaderLease is how long leadership survives without being renewed,
+	// so another replica takes over soon after the leader stops.
+	leaderLease = 30 * time.Second
+)
+
+// electScript renews the lease if this instance holds it, or takes it if
+// nobody does.
+var electScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return 1
+end
+return 0
+`)
+
+// ValidateSchedule checks a schedule setting value.
+func ValidateSchedule(schedule string) error {
+	schedule = strings.TrimSpace(schedule)
+	if schedule == ScheduleOff {
+		return nil
+	}
+	if _, err := cron.ParseStandard(schedule); err != nil {
+		return fmt.Errorf("invalid schedule %q: use a cron expression such as \"0 2 * * *\", a descriptor such as \"@daily\", or \"off\": %w", schedule, err)
+	}
+	return nil
+}
+
+type job struct {
+	name string
+	// defaultSchedule works out the schedule to use when the
+	// schedule_<job> setting is unset.
+	defaultSchedule func(settings map[string]interface{}) string
+	run             func() error
+
+	// schedule is the one the job currently runs on, "" before the first
+	// reload.
+	schedule string
+	entry    cron.EntryID
+}
+
+// Scheduler runs recurring background jobs on cron schedules. Every replica
+// runs a Scheduler, but only the one holding the leader lock in Redis runs
+// jobs, so they are not run twice.
+type Scheduler struct {
+	settingsService *database.SettingsService
+	redisClient     *redis.Client
+	instance        string
+	cron            *cron.Cron
+	jobs            []*job
+
+	leader atomic.Bool
+	// contacted is set once Redis has answered an election.
+	contacted bool
+}
+
+func New(db *sql.DB, redisClient *redis.Client) *Scheduler {
+	logger := cron.PrintfLogger(log.Default())
+	return &Scheduler{
+		settingsService: database.NewSettingsService(db),
+		redisClient:     redisClient,
+		instance:        uuid.New().String(),
+		cron:            cron.New(cron.WithChain(cron.Recover(logger), cron.SkipIfStillRunning(logger))),
+	}
+}
+
+// Add registers a job that runs on defaultSchedule unless its setting says
+// otherwise. A run still in progress when the next one is due makes that
+// one be skipped. Call Add before Run.
+func (s *Scheduler) Add(name, defaultSchedule string, run func() error) {
+	s.AddFromSettings(name, func(map[string]interface{}) string { return defaultSchedule }, run)
+}
+
+// AddFromSettings registers a job whose default schedule depends on other
+// settings, such as a frequency or an on/off switch. defaultSchedule is
+// called whenever settings are reloaded; the schedule_<job> setting still
+// takes precedence. Call AddFromSettings before Run.
+func (s *Scheduler) AddFromSettings(name string, defaultSchedule func(settings map[string]interface{}) string, run func() error) {
+	s.jobs = append(s.jobs, &job{name: name, defaultSchedule: defaultSchedule, run: run})
+}
+
+// Jobs lists the names of the registered jobs.
+func (s *Scheduler) Jobs() []string {
+	names := make([]string, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		names = append(names, j.name)
+	}
+	return names
+}
+
+// Leader reports whether this instance holds the leader lock, for work
+// that, like jobs, must run on one replica only.
+func (s *Scheduler) Leader() bool {
+	return s.leader.Load()
+}
+
+// Run starts the jobs, then keeps renewing leadership and picking up
+// schedule changes from settings until ctx is done. It then waits for the
+// jobs that are running to finish. It blocks, so run it in its own
+// goroutine.
+func (s *Scheduler) Run(ctx context.Context) {
+	s.cron.Start()
+	defer func() { <-s.cron.Stop().Done() }()
+
+	ticker := time.NewTicker(leaderLease / 3)
+	defer ticker.Stop()
+
+	for {
+		s.elect()
+		s.reload()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) elect() {
+	ctx, cancel := context.WithTimeout(context.Background(), leaderLease/3)
+	defer cancel()
+
+	won, err := electScript.Run(ctx, s.redisClient, []string{leaderKey}, s.instance, leaderLease.Milliseconds()).Int()
+	if err != nil {
+		// Keep the current role while Redis is down, so followers do not
+		// all start running jobs. An instance that has never reached Redis
+		// runs them, so a single replica started without Redis still does.
+		if !s.contacted && !s.leader.Load() {
+			log.Printf("Scheduler leader election unavailable, running jobs on this instance: %v", err)
+			s.leader.Store(true)
+		}
+		return
+	}
+
+	s.contacted = true
+	if leader := won == 1; leader != s.leader.Load() {
+		s.leader.Store(leader)
+		if leader {
+			log.Printf("Scheduler leadership acquired, running jobs on this instance")
+		} else {
+			log.Printf("Scheduler leadership lost, another instance runs jobs")
+		}
+	}
+}
+
+// reload applies schedule settings that have changed.
+func (s *Scheduler) reload() {
+	settings, err := s.settingsService.GetSettings()
+	if err != nil {
+		log.Printf("Failed to load job schedules: %v", err)
+		return
+	}
+
+	for _, j := range s.jobs {
+		schedule := j.scheduleFor(settings)
+		if schedule == j.schedule {
+			continue
+		}
+		if err := ValidateSchedule(schedule); err != nil {
+			log.Printf("Ignoring %s%s setting: %v", SettingPrefix, j.name, err)
+			continue
+		}
+
+		if j.entry != 0 {
+			s.cron.Remove(j.entry)
+			j.entry = 0
+		}
+		if schedule != ScheduleOff {
+			// Validated above, so this cannot fail
+			j.entry, _ = s.cron.AddFunc(schedule, s.runner(j))
+		}
+		j.schedule = schedule
+		log.Printf("Job %s scheduled: %s", j.name, schedule)
+	}
+}
+
+// scheduleFor returns the schedule settings give the job.
+func (j *job) scheduleFor(settings map[string]interface{}) string {
+	if value, ok := settings[SettingPrefix+j.name].(string); ok && strings.TrimSpace(value) != "" {
+		return strings.TrimSpace(value)
+	}
+	return strings.TrimSpace(j.defaultSchedule(settings))
+}
+
+func (s *Scheduler) runner(j *job) func() {
+	return func() {
+		if !s.leader.Load() {
+			return
+		}
+		if err := j.run(); err != nil {
+			log.Printf("Job %s failed: %v", j.name, err)
+		}
+	}
+}
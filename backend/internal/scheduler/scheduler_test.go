@@ -0,0 +1,143 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// downConnector fails every connection, as an unreachable database does.
+type downConnector struct{}
+
+func (downConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, errors.New("dial tcp: connection refused")
+}
+
+func (downConnector) Driver() driver.Driver { return nil }
+
+func TestValidateSchedule(t *testing.T) {
+	tests := []struct {
+		schedule string
+		valid    bool
+	}{
+		{"0 2 * * *", true},
+		{"*/15 * * * *", true},
+		{"@daily", true},
+		{"@every 6h", true},
+		{"off", true},
+		{" off ", true},
+		{"", false},
+		{"0 2 * *", false},
+		{"61 * * * *", false},
+		{"daily", false},
+	}
+	for _, tt := range tests {
+		if err := ValidateSchedule(tt.schedule); (err == nil) != tt.valid {
+			t.Errorf("ValidateSchedule(%q) = %v, want valid %v", tt.schedule, err, tt.valid)
+		}
+	}
+}
+
+func TestElectWithoutRedis(t *testing.T) {
+	unreachable := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", DialTimeout: 100 * time.Millisecond, MaxRetries: -1})
+	defer unreachable.Close()
+
+	// A replica that has never reached Redis runs jobs itself
+	s := &Scheduler{redisClient: unreachable, instance: "a"}
+	s.elect()
+	if !s.leader.Load() {
+		t.Error("expected an instance that never reached Redis to lead")
+	}
+
+	// A follower keeps following while Redis is down
+	s = &Scheduler{redisClient: unreachable, instance: "b", contacted: true}
+	s.elect()
+	if s.leader.Load() {
+		t.Error("expected a follower to stay a follower while Redis is down")
+	}
+}
+
+func TestRunWaitsForRunningJobs(t *testing.T) {
+	unreachable := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", DialTimeout: 100 * time.Millisecond, MaxRetries: -1})
+	defer unreachable.Close()
+	s := New(sql.OpenDB(downConnector{}), unreachable)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	s.cron.AddFunc("@every 1s", func() {
+		select {
+		case started <- struct{}{}:
+			<-release
+		default:
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(stopped)
+	}()
+	select {
+	case <-started:
+	case <-time.After(3 * time.Second):
+		t.Fatal("expected the job to start")
+	}
+
+	cancel()
+	select {
+	case <-stopped:
+		t.Fatal("expected Run to wait for the running job")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("expected Run to return once the job finished")
+	}
+}
+
+func TestRunnerOnlyRunsOnLeader(t *testing.T) {
+	runs := 0
+	s := &Scheduler{}
+	run := s.runner(&job{name: "test", run: func() error {
+		runs++
+		return errors.New("failed")
+	}})
+
+	run()
+	if runs != 0 {
+		t.Fatalf("expected a follower not to run jobs, ran %d times", runs)
+	}
+	s.leader.Store(true)
+	run()
+	if runs != 1 {
+		t.Fatalf("expected the leader to run the job once, ran %d times", runs)
+	}
+}
+
+func TestScheduleFor(t *testing.T) {
+	s := &Scheduler{}
+	s.AddFromSettings("backup", func(settings map[string]interface{}) string {
+		if settings["auto_backup"] == "false" {
+			return ScheduleOff
+		}
+		return "0 2 * * *"
+	}, nil)
+	j := s.jobs[0]
+
+	if schedule := j.scheduleFor(map[string]interface{}{}); schedule != "0 2 * * *" {
+		t.Errorf("expected the default schedule, got %q", schedule)
+	}
+	if schedule := j.scheduleFor(map[string]interface{}{"auto_backup": "false"}); schedule != ScheduleOff {
+		t.Errorf("expected the default to follow settings, got %q", schedule)
+	}
+	if schedule := j.scheduleFor(map[string]interface{}{"auto_backup": "false", "schedule_backup": " @hourly "}); schedule != "@hourly" {
+		t.Errorf("expected schedule_backup to take precedence, got %q", schedule)
+	}
+}
@@ -0,0 +1,239 @@
+package search
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// reindexBatchSize is how many rows are read and indexed at a time.
+const reindexBatchSize = 500
+
+// maxResultWindow is how deep into results the cluster pages by default.
+const maxResultWindow = 10000
+
+// ErrReindexRunning is returned when a reindex is asked for while one is
+// running.
+var ErrReindexRunning = errors.New("a search reindex is already running")
+
+// Indexer keeps the indexes in step with Postgres and answers product
+// searches from them.
+type Indexer struct {
+	client        *Client
+	searchService *database.SearchService
+
+	// ready is set once the indexes are known to exist. Until then each
+	// event tries to create them, so none is indexed without its mapping.
+	ready      atomic.Bool
+	reindexing sync.Mutex
+}
+
+func NewIndexer(db *sql.DB, client *Client) *Indexer {
+	return &Indexer{client: client, searchService: database.NewSearchService(db)}
+}
+
+// EnsureIndexes creates the indexes that do not exist yet.
+func (ix *Indexer) EnsureIndexes(ctx context.Context) error {
+	if ix.ready.Load() {
+		return nil
+	}
+	if err := ix.client.CreateIndexes(ctx); err != nil {
+		return err
+	}
+	ix.ready.Store(true)
+	return nil
+}
+
+// Apply brings the document an EventSearchIndex names in line with its
+// row, deleting it if the row is gone. Deleting a product drops its stock
+// movements too, as Postgres does.
+func (ix *Indexer) Apply(ctx context.Context, event models.SearchIndexEvent) error {
+	if err := ix.EnsureIndexes(ctx); err != nil {
+		return err
+	}
+	if event.Before != nil {
+		timeField, ok := timeFields[event.Index]
+		if !ok {
+			return fmt.Errorf("search index %q is not purged by time", event.Index)
+		}
+		return ix.client.DeleteByQuery(ctx, event.Index, map[string]interface{}{
+			"range": map[string]interface{}{timeField: map[string]interface{}{"lt": event.Before.Format(time.RFC3339Nano)}},
+		})
+	}
+
+	documents, err := ix.searchService.GetSearchDocuments(ctx, event.Index, []uuid.UUID{event.ID})
+	if err != nil {
+		return err
+	}
+	if len(documents) > 0 {
+		return ix.client.Put(ctx, event.Index, event.ID, documents[0].Body)
+	}
+
+	if err := ix.client.Delete(ctx, event.Index, event.ID); err != nil {
+		return err
+	}
+	if event.Index == models.SearchProducts {
+		return ix.client.DeleteByQuery(ctx, models.SearchStockMovements, term("product_id", event.ID.String()))
+	}
+	return nil
+}
+
+// StartReindex starts a reindex in the background, unless one is running.
+func (ix *Indexer) StartReindex() error {
+	if !ix.reindexing.TryLock() {
+		return ErrReindexRunning
+	}
+	go func() {
+		defer ix.reindexing.Unlock()
+		start := time.Now()
+		if err := ix.reindex(context.Background()); err != nil {
+			log.Printf("Search reindex failed: %v", err)
+			return
+		}
+		log.Printf("Search reindex completed in %s", time.Since(start).Round(time.Second))
+	}()
+	return nil
+}
+
+// reindex empties each index and copies every row of its table into it,
+// for when search is first enabled or the cluster lost its data. Changes
+// published meanwhile are applied as usual, and searches miss rows not
+// copied yet.
+func (ix *Indexer) reindex(ctx context.Context) error {
+	for _, index := range models.SearchIndexes {
+		if err := ix.client.RecreateIndex(ctx, index); err != nil {
+			return err
+		}
+		after := uuid.Nil
+		for {
+			documents, err := ix.searchService.GetSearchDocumentsAfter(ctx, index, after, reindexBatchSize)
+			if err != nil {
+				return err
+			}
+			if len(documents) == 0 {
+				break
+			}
+			if err := ix.client.Bulk(ctx, index, documents); err != nil {
+				return err
+			}
+			after = documents[len(documents)-1].ID
+		}
+	}
+	return nil
+}
+
+// productSortFields are the fields products may be sorted by; searches
+// sorted by anything else come back most relevant first.
+var productSortFields = map[string]string{
+	"name": "name.keyword", "sku": "sku.keyword", "category": "category.keyword",
+	"stock": "stock", "price": "price", "created_at": "created_at", "updated_at": "updated_at",
+}
+
+// SearchProducts finds the products matching filter, limited to the
+// organization of ctx. Names and categories match by word, forgiving
+// typos, and SKUs by prefix.
+func (ix *Indexer) SearchProducts(ctx context.Context, filter models.ProductFilter) ([]models.Product, int, error) {
+	query := map[string]interface{}{
+		"query":            map[string]interface{}{"bool": productQuery(ctx, filter)},
+		"track_total_hits": true,
+	}
+	if sortField, ok := productSortFields[filter.SortBy]; ok {
+		order := "desc"
+		if filter.SortOrder == "ASC" {
+			order = "asc"
+		}
+		query["sort"] = []interface{}{map[string]interface{}{sortField: order}, "_score"}
+	}
+	if filter.Limit > 0 {
+		page := filter.Page
+		if page < 1 {
+			page = 1
+		}
+		from := (page - 1) * filter.Limit
+		// Pages past the window come back empty, with the total
+		if from >= maxResultWindow {
+			from, filter.Limit = 0, 0
+		}
+		query["from"] = from
+		query["size"] = filter.Limit
+	} else {
+		query["size"] = maxResultWindow
+	}
+
+	var result struct {
+		Hits struct {
+			Total struct {
+				Value int `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				Source models.SearchProduct `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := ix.client.Search(ctx, models.SearchProducts, query, &result); err != nil {
+		return nil, 0, err
+	}
+
+	var products []models.Product
+	for _, hit := range result.Hits.Hits {
+		products = append(products, hit.Source.Product)
+	}
+	return products, result.Hits.Total.Value, nil
+}
+
+// productQuery is the bool query of a product search: the search text
+// must match and the other fields of filter narrow it down.
+func productQuery(ctx context.Context, filter models.ProductFilter) map[string]interface{} {
+	filters := []interface{}{}
+	if organizationID, ok := database.OrganizationFrom(ctx); ok {
+		filters = append(filters, term("organization_id", organizationID.String()))
+	}
+	if filter.Category != "" {
+		filters = append(filters, term("category.keyword", filter.Category))
+	}
+	stock, price := map[string]interface{}{}, map[string]interface{}{}
+	if filter.MinStock != nil {
+		stock["gte"] = *filter.MinStock
+	}
+	if filter.MaxStock != nil {
+		stock["lte"] = *filter.MaxStock
+	}
+	if filter.MinPrice != nil {
+		price["gte"] = *filter.MinPrice
+	}
+	if filter.MaxPrice != nil {
+		price["lte"] = *filter.MaxPrice
+	}
+	for field, bounds := range map[string]map[string]interface{}{"stock": stock, "price": price} {
+		if len(bounds) > 0 {
+			filters = append(filters, map[string]interface{}{"range": map[string]interface{}{field: bounds}})
+		}
+	}
+	if filter.LowStockOnly {
+		filters = append(filters, term("low_stock", true))
+	}
+
+	should := []interface{}{
+		map[string]interface{}{"multi_match": map[string]interface{}{
+			"query": filter.Search, "fields": []string{"name^3", "category"}, "fuzziness": "AUTO",
+		}},
+		map[string]interface{}{"prefix": map[string]interface{}{
+			"sku.keyword": map[string]interface{}{"value": filter.Search, "case_insensitive": true, "boost": 5},
+		}},
+	}
+	return map[string]interface{}{"filter": filters, "should": should, "minimum_should_match": 1}
+}
+
+func term(field string, value interface{}) map[string]interface{} {
+	return map[string]interface{}{"term": map[string]interface{}{field: value}}
+}
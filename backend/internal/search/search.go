@@ -0,0 +1,287 @@
+// Package search mirrors products, stock movements and audit entries into
+// an OpenSearch or Elasticsearch cluster, configured with SEARCH_URL. The
+// outbox relay applies each change once it commits, product searches are
+// answered from the index, and the indexes can back dashboards in
+// OpenSearch Dashboards or Kibana without querying Postgres.
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// maxErrorBody is how much of an error response is kept in its error.
+const maxErrorBody = 512
+
+// APIError is a response of the cluster outside 2xx.
+type APIError struct {
+	Status int
+	Body   string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("search cluster returned status %d: %s", e.Status, e.Body)
+}
+
+// IsNotFound reports whether err is a 404 from the cluster.
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound
+}
+
+// text is a field searched by words that can also be sorted and filtered
+// on exactly as .keyword.
+var text = map[string]interface{}{
+	"type":   "text",
+	"fields": map[string]interface{}{"keyword": map[string]interface{}{"type": "keyword", "ignore_above": 256}},
+}
+
+func field(fieldType string) map[string]interface{} {
+	return map[string]interface{}{"type": fieldType}
+}
+
+// unindexed keeps a field in the document without indexing it, for values
+// whose keys vary from one document to the next.
+var unindexed = map[string]interface{}{"type": "object", "enabled": false}
+
+// mappings are the fields of each index. Fields not listed are rejected
+// rather than guessed, so a document never changes the mapping.
+var mappings = map[models.SearchIndex]map[string]interface{}{
+	models.SearchProducts: {
+		"id":                field("keyword"),
+		"organization_id":   field("keyword"),
+		"name":              text,
+		"sku":               text,
+		"category":          text,
+		"stock":             field("integer"),
+		"price":             field("double"),
+		"minimum_threshold": field("integer"),
+		"low_stock":         field("boolean"),
+		"supplier_info":     unindexed,
+		"created_at":        field("date"),
+		"updated_at":        field("date"),
+	},
+	models.SearchStockMovements: {
+		"id":              field("keyword"),
+		"organization_id": field("keyword"),
+		"product_id":      field("keyword"),
+		"product_name":    text,
+		"product_sku":     field("keyword"),
+		"category":        field("keyword"),
+		"change":          field("integer"),
+		"reason":          field("keyword"),
+		"created_by":      field("keyword"),
+		"created_at":      field("date"),
+		"notes":           field("text"),
+	},
+	models.SearchAuditLogs: {
+		"id":              field("keyword"),
+		"organization_id": field("keyword"),
+		"table_name":      field("keyword"),
+		"record_id":       field("keyword"),
+		"action":          field("keyword"),
+		"old_values":      unindexed,
+		"new_values":      unindexed,
+		"changed_by":      field("keyword"),
+		"changed_at":      field("date"),
+		"ip_address":      field("keyword"),
+		"user_agent":      field("keyword"),
+		"status_code":     field("integer"),
+		"error_message":   field("text"),
+		"duration_ms":     field("long"),
+	},
+}
+
+// timeFields are the fields an event with Before compares with.
+var timeFields = map[models.SearchIndex]string{
+	models.SearchStockMovements: "created_at",
+	models.SearchAuditLogs:      "changed_at",
+}
+
+// Client calls the REST API of an OpenSearch or Elasticsearch cluster.
+type Client struct {
+	URL      string
+	Username string
+	Password string
+	// Prefix starts the name of every index, e.g. "rtims" for
+	// rtims-products.
+	Prefix     string
+	httpClient *http.Client
+}
+
+func NewClient(baseURL, username, password, prefix string) *Client {
+	return &Client{
+		URL:        strings.TrimRight(baseURL, "/"),
+		Username:   username,
+		Password:   password,
+		Prefix:     prefix,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Name returns the name of the index of a table, e.g. rtims-stock-movements.
+func (c *Client) Name(index models.SearchIndex) string {
+	return c.Prefix + "-" + strings.ReplaceAll(string(index), "_", "-")
+}
+
+// CreateIndexes creates the indexes that do not exist yet.
+func (c *Client) CreateIndexes(ctx context.Context) error {
+	for _, index := range models.SearchIndexes {
+		err := c.do(ctx, http.MethodHead, "/"+c.Name(index), nil, nil)
+		if err == nil {
+			continue
+		}
+		if !IsNotFound(err) {
+			return fmt.Errorf("failed to check index %s: %w", c.Name(index), err)
+		}
+		if err := c.createIndex(ctx, index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecreateIndex drops an index with every document in it and creates it
+// again empty.
+func (c *Client) RecreateIndex(ctx context.Context, index models.SearchIndex) error {
+	if err := c.do(ctx, http.MethodDelete, "/"+c.Name(index), nil, nil); err != nil && !IsNotFound(err) {
+		return fmt.Errorf("failed to delete index %s: %w", c.Name(index), err)
+	}
+	return c.createIndex(ctx, index)
+}
+
+func (c *Client) createIndex(ctx context.Context, index models.SearchIndex) error {
+	body := map[string]interface{}{
+		"mappings": map[string]interface{}{"dynamic": "strict", "properties": mappings[index]},
+	}
+	if err := c.do(ctx, http.MethodPut, "/"+c.Name(index), body, nil); err != nil {
+		return fmt.Errorf("failed to create index %s: %w", c.Name(index), err)
+	}
+	return nil
+}
+
+// Put indexes a document, replacing any with the same ID.
+func (c *Client) Put(ctx context.Context, index models.SearchIndex, id uuid.UUID, document interface{}) error {
+	return c.do(ctx, http.MethodPut, "/"+c.Name(index)+"/_doc/"+id.String(), document, nil)
+}
+
+// Delete removes a document. A document that is already gone is not an
+// error.
+func (c *Client) Delete(ctx context.Context, index models.SearchIndex, id uuid.UUID) error {
+	err := c.do(ctx, http.MethodDelete, "/"+c.Name(index)+"/_doc/"+id.String(), nil, nil)
+	if IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// DeleteByQuery removes every document of an index matching query.
+func (c *Client) DeleteByQuery(ctx context.Context, index models.SearchIndex, query map[string]interface{}) error {
+	return c.do(ctx, http.MethodPost, "/"+c.Name(index)+"/_delete_by_query?conflicts=proceed",
+		map[string]interface{}{"query": query}, nil)
+}
+
+// Bulk indexes documents with one request.
+func (c *Client) Bulk(ctx context.Context, index models.SearchIndex, documents []database.SearchDocument) error {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, document := range documents {
+		action := map[string]interface{}{"index": map[string]interface{}{"_index": c.Name(index), "_id": document.ID}}
+		if err := encoder.Encode(action); err != nil {
+			return err
+		}
+		if err := encoder.Encode(document.Body); err != nil {
+			return err
+		}
+	}
+
+	var result struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			ID    string          `json:"_id"`
+			Error json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if err := c.send(ctx, http.MethodPost, "/_bulk", "application/x-ndjson", &body, &result); err != nil {
+		return err
+	}
+	// The request succeeds even when documents fail
+	if result.Errors {
+		for _, item := range result.Items {
+			for _, outcome := range item {
+				if len(outcome.Error) > 0 {
+					return fmt.Errorf("failed to index document %s: %s", outcome.ID, outcome.Error)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Search runs a query against an index and decodes the response into out.
+func (c *Client) Search(ctx context.Context, index models.SearchIndex, query map[string]interface{}, out interface{}) error {
+	return c.do(ctx, http.MethodPost, "/"+c.Name(index)+"/_search", query, out)
+}
+
+// do sends in, if it is given, as JSON and decodes a 2xx response into
+// out, if it is given.
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		encoded, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(encoded)
+	}
+	return c.send(ctx, method, path, "application/json", body, out)
+}
+
+func (c *Client) send(ctx context.Context, method, path, contentType string, body io.Reader, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.URL+path, body)
+	if err != nil {
+		return err
+	}
+	if c.Username != "" || c.Password != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		// The URL may carry credentials
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("search cluster request failed: %w", urlErr.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return &APIError{Status: resp.StatusCode, Body: string(message)}
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("invalid search cluster response: %w", err)
+		}
+	}
+	return nil
+}
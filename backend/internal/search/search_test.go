@@ -0,0 +1,171 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// fakeCluster records the requests sent to it and answers with respond.
+func fakeCluster(t *testing.T, respond func(w http.ResponseWriter, r *http.Request, body []byte)) (*Client, *[]string) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		if user, password, _ := r.BasicAuth(); user != "rtims" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		respond(w, r, body)
+	}))
+	t.Cleanup(server.Close)
+	return NewClient(server.URL+"/", "rtims", "secret", "rtims"), &requests
+}
+
+func TestName(t *testing.T) {
+	client := NewClient("http://search:9200", "", "", "staging")
+	if name := client.Name(models.SearchStockMovements); name != "staging-stock-movements" {
+		t.Errorf("unexpected index name %s", name)
+	}
+}
+
+func TestCreateIndexes(t *testing.T) {
+	var created map[string]interface{}
+	client, requests := fakeCluster(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		switch {
+		case r.Method == http.MethodHead && r.URL.Path == "/rtims-products":
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusNotFound)
+		case r.URL.Path == "/rtims-audit-logs":
+			json.Unmarshal(body, &created)
+		}
+	})
+
+	ix := &Indexer{client: client}
+	if err := ix.EnsureIndexes(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"HEAD /rtims-products", "HEAD /rtims-stock-movements", "PUT /rtims-stock-movements",
+		"HEAD /rtims-audit-logs", "PUT /rtims-audit-logs"}
+	if strings.Join(*requests, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected requests %v, got %v", expected, *requests)
+	}
+	if created["mappings"].(map[string]interface{})["dynamic"] != "strict" {
+		t.Errorf("expected a strict mapping, got %v", created)
+	}
+
+	// Known to exist from then on
+	if err := ix.EnsureIndexes(context.Background()); err != nil || len(*requests) != len(expected) {
+		t.Errorf("expected no more requests, got %v, %v", *requests, err)
+	}
+}
+
+func TestApplyPurge(t *testing.T) {
+	var query map[string]interface{}
+	client, requests := fakeCluster(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		json.Unmarshal(body, &query)
+		io.WriteString(w, `{"deleted": 3}`)
+	})
+	ix := &Indexer{client: client}
+	ix.ready.Store(true)
+
+	before := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := ix.Apply(context.Background(), models.SearchIndexEvent{Index: models.SearchAuditLogs, Before: &before}); err != nil {
+		t.Fatal(err)
+	}
+	if (*requests)[0] != "POST /rtims-audit-logs/_delete_by_query?conflicts=proceed" {
+		t.Errorf("unexpected requests %v", *requests)
+	}
+	changedAt := query["query"].(map[string]interface{})["range"].(map[string]interface{})["changed_at"]
+	if changedAt.(map[string]interface{})["lt"] != "2026-01-02T03:04:05Z" {
+		t.Errorf("unexpected query %v", query)
+	}
+
+	if err := ix.Apply(context.Background(), models.SearchIndexEvent{Index: models.SearchProducts, Before: &before}); err == nil {
+		t.Error("expected products not to be purged by time")
+	}
+}
+
+func TestBulk(t *testing.T) {
+	var lines []string
+	client, _ := fakeCluster(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		if r.Header.Get("Content-Type") != "application/x-ndjson" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		lines = strings.Split(strings.TrimSpace(string(body)), "\n")
+		io.WriteString(w, `{"errors": true, "items": [{"index": {"_id": "a", "status": 201}},
+			{"index": {"_id": "b", "status": 400, "error": {"type": "strict_dynamic_mapping_exception"}}}]}`)
+	})
+
+	id := uuid.New()
+	documents := []database.SearchDocument{
+		{ID: id, Body: models.SearchProduct{Product: models.Product{ID: id, Name: "Widget"}, LowStock: true}},
+		{ID: uuid.New(), Body: models.SearchProduct{}},
+	}
+	err := client.Bulk(context.Background(), models.SearchProducts, documents)
+	if err == nil || !strings.Contains(err.Error(), "strict_dynamic_mapping_exception") {
+		t.Errorf("expected the failed document to be reported, got %v", err)
+	}
+	if len(lines) != 4 || lines[0] != `{"index":{"_id":"`+id.String()+`","_index":"rtims-products"}}` ||
+		!strings.Contains(lines[1], `"low_stock":true`) {
+		t.Errorf("unexpected bulk body %v", lines)
+	}
+}
+
+func TestSearchProducts(t *testing.T) {
+	var query map[string]interface{}
+	client, requests := fakeCluster(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		json.Unmarshal(body, &query)
+		io.WriteString(w, `{"hits": {"total": {"value": 42}, "hits": [
+			{"_source": {"id": "6f1c1f5e-0d1e-4c1a-9a52-3c2b1d0e9f11", "name": "Widget", "sku": "WID-1", "stock": 3, "low_stock": true}}]}}`)
+	})
+	ix := &Indexer{client: client}
+
+	organizationID := uuid.New()
+	ctx := database.WithOrganization(context.Background(), organizationID)
+	minStock := 1
+	products, total, err := ix.SearchProducts(ctx, models.ProductFilter{
+		Search: "widgit", Category: "Tools", MinStock: &minStock, LowStockOnly: true,
+		Page: 3, Limit: 20, SortBy: "name", SortOrder: "ASC",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 42 || len(products) != 1 || products[0].Name != "Widget" || products[0].Stock != 3 {
+		t.Errorf("unexpected results %v, %d", products, total)
+	}
+	if (*requests)[0] != "POST /rtims-products/_search" {
+		t.Errorf("unexpected requests %v", *requests)
+	}
+
+	if query["from"] != 40.0 || query["size"] != 20.0 {
+		t.Errorf("expected the third page of 20, got %v", query)
+	}
+	if sort := query["sort"].([]interface{}); sort[0].(map[string]interface{})["name.keyword"] != "asc" {
+		t.Errorf("unexpected sort %v", sort)
+	}
+	encoded, _ := json.Marshal(query["query"])
+	for _, want := range []string{
+		`{"term":{"organization_id":"` + organizationID.String() + `"}}`,
+		`{"term":{"category.keyword":"Tools"}}`,
+		`{"range":{"stock":{"gte":1}}}`,
+		`{"term":{"low_stock":true}}`,
+		`"fuzziness":"AUTO"`,
+	} {
+		if !strings.Contains(string(encoded), want) {
+			t.Errorf("expected %s in the query %s", want, encoded)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+// Package seed fills a development or demo database with an admin account,
+// categories, products and a plausible stock movement history.
+package seed
+
+import (
+	crand "crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"rtims-backend/internal/database"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// skuPrefix marks seeded products so a second run skips them.
+const skuPrefix = "DEMO-"
+
+var ErrInvalidOptions = errors.New("products must be between 1 and 5000 and days between 1 and 730")
+
+type Options struct {
+	AdminName  string
+	AdminEmail string
+	// AdminPassword is the password of the admin and staff accounts. When
+	// empty a random one is generated.
+	AdminPassword string
+	StaffEmail    string
+	Products      int
+	// Days is how far back the movement history goes.
+	Days int
+	// RandSeed makes runs reproducible.
+	RandSeed int64
+}
+
+func DefaultOptions() Options {
+	return Options{
+		AdminName:  "Demo Admin",
+		AdminEmail: "admin@rtims.local",
+		StaffEmail: "staff@rtims.local",
+		Products:   300,
+		Days:       90,
+		RandSeed:   1,
+	}
+}
+
+// Result counts what a run created.
+type Result struct {
+	Users      int `json:"users"`
+	Categories int `json:"categories"`
+	Products   int `json:"products"`
+	Movements  int `json:"movements"`
+	// Password is the password of the accounts this run created, or ""
+	// when the accounts already existed.
+	Password string `json:"-"`
+}
+
+type category struct {
+	name, description string
+	items             []string
+	minPrice          float64
+	maxPrice          float64
+}
+
+var categories = []category{
+	{"Electronics", "Cables, chargers and small devices", []string{"USB-C Cable", "Wireless Mouse", "Keyboard", "HDMI Adapter", "Power Bank", "Headset", "Webcam", "Charger"}, 5, 120},
+	{"Office Supplies", "Paper, pens and desk items", []string{"A4 Paper Ream", "Ballpoint Pens", "Stapler", "Sticky Notes", "Binder", "Whiteboard Marker", "Envelope Pack", "Notebook"}, 1, 30},
+	{"Tools", "Hand and power tools", []string{"Claw Hammer", "Screwdriver Set", "Cordless Drill", "Tape Measure", "Utility Knife", "Spirit Level", "Wrench Set", "Pliers"}, 4, 150},
+	{"Cleaning", "Cleaning products and equipment", []string{"Floor Cleaner", "Microfiber Cloths", "Trash Bags", "Hand Soap", "Disinfectant Spray", "Mop", "Glass Cleaner", "Sponges"}, 2, 40},
+	{"Furniture", "Office and storage furniture", []string{"Office Chair", "Desk Lamp", "Filing Cabinet", "Bookshelf", "Standing Desk", "Footrest", "Monitor Stand", "Storage Bin"}, 15, 400},
+	{"Packaging", "Boxes, tape and shipping materials", []string{"Shipping Box", "Packing Tape", "Bubble Wrap", "Mailer Bag", "Label Roll", "Stretch Film", "Void Fill", "Pallet Wrap"}, 1, 35},
+	{"Safety", "Protective equipment", []string{"Safety Gloves", "Hard Hat", "Safety Glasses", "Hi-Vis Vest", "Ear Plugs", "First Aid Kit", "Dust Mask", "Steel Toe Boots"}, 2, 90},
+}
+
+var (
+	variants  = []string{"Standard", "Pro", "Compact", "Heavy Duty", "Eco", "XL", "Mini", "Premium"}
+	suppliers = []string{"Acme Supply Co.", "Northwind Traders", "Globex Wholesale", "Initech Distribution", "Umbrella Industrial"}
+)
+
+// Run seeds db. It is safe to run more than once: existing users,
+// categories and DEMO- products are left alone, and movements are only
+// generated for products this run created.
+func Run(db *sql.DB, opts Options) (*Result, error) {
+	if opts.Products < 1 || opts.Products > 5000 || opts.Days < 1 || opts.Days > 730 {
+		return nil, ErrInvalidOptions
+	}
+	password := opts.AdminPassword
+	if password == "" {
+		generated, err := randomPassword()
+		if err != nil {
+			return nil, err
+		}
+		password = generated
+	}
+	rng := rand.New(rand.NewSource(opts.RandSeed))
+	result := &Result{}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	adminID, created, err := ensureUser(tx, opts.AdminName, opts.AdminEmail, password, "admin")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create admin user: %w", err)
+	}
+	if created {
+		result.Users++
+	}
+	staffID, created, err := ensureUser(tx, "Demo Staff", opts.StaffEmail, password, "staff")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staff user: %w", err)
+	}
+	if created {
+		result.Users++
+	}
+	if result.Users > 0 {
+		result.Password = password
+	}
+
+	for _, c := range categories {
+		res, err := tx.Exec(`INSERT INTO categories (name, description, organization_id) VALUES ($1, $2, $3)
+			ON CONFLICT (organization_id, name) DO NOTHING`,
+			c.name, c.description, database.DefaultOrganizationID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create category %s: %w", c.name, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			result.Categories++
+		}
+	}
+
+	start := time.Now().AddDate(0, 0, -opts.Days)
+	users := []uuid.UUID{adminID, staffID}
+	for i := 1; i <= opts.Products; i++ {
+		c := categories[rng.Intn(len(categories))]
+		name := c.items[rng.Intn(len(c.items))] + " " + variants[rng.Intn(len(variants))]
+		price := c.minPrice + rng.Float64()*(c.maxPrice-c.minPrice)
+		threshold := 5 + rng.Intn(20)
+		movements := history(rng, threshold, start, opts.Days)
+
+		stock := 0
+		for _, m := range movements {
+			stock += m.change
+		}
+
+		supplier, _ := json.Marshal(map[string]string{
+			"name":  suppliers[rng.Intn(len(suppliers))],
+			"email": fmt.Sprintf("orders%d@supplier.example", rng.Intn(len(suppliers))+1),
+		})
+
+		var productID uuid.UUID
+		err := tx.QueryRow(`
+			INSERT INTO products (name, sku, stock, price, category, minimum_threshold, supplier_info, created_at, organization_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			ON CONFLICT (organization_id, sku) DO NOTHING
+			RETURNING id`,
+			name, fmt.Sprintf("%s%04d", skuPrefix, i), stock, float64(int(price*100))/100, c.name, threshold,
+			string(supplier), start, database.DefaultOrganizationID).Scan(&productID)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create product: %w", err)
+		}
+		result.Products++
+
+		if err := insertMovements(tx, productID, movements, users, rng); err != nil {
+			return nil, fmt.Errorf("failed to create stock movements: %w", err)
+		}
+		result.Movements += len(movements)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// randomPassword returns a password for seeded accounts, so that no
+// database ever has a known one.
+func randomPassword() (string, error) {
+	password := make([]byte, 12)
+	if _, err := crand.Read(password); err != nil {
+		return "", fmt.Errorf("failed to generate password: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(password), nil
+}
+
+// ensureUser creates the user unless the email is taken, and returns its id
+// either way.
+func ensureUser(tx *sql.Tx, name, email, password, role string) (uuid.UUID, bool, error) {
+	var id uuid.UUID
+	err := tx.QueryRow("SELECT id FROM users WHERE email = $1", email).Scan(&id)
+	if err == nil {
+		return id, false, nil
+	}
+	if err != sql.ErrNoRows {
+		return id, false, err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return id, false, err
+	}
+	err = tx.QueryRow(`INSERT INTO users (name, email, password, role, organization_id) VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		name, email, string(hashedPassword), role, database.DefaultOrganizationID).Scan(&id)
+	return id, err == nil, err
+}
+
+type movement struct {
+	change int
+	reason string
+	at     time.Time
+}
+
+// history simulates a product's stock over days: an opening purchase, daily
+// sales, a restock whenever stock falls to the threshold, and the odd
+// return, damage write-off or count adjustment. Stock never goes negative.
+func history(rng *rand.Rand, threshold int, start time.Time, days int) []movement {
+	at := func(day int) time.Time {
+		return start.AddDate(0, 0, day).Add(time.Duration(8*60+rng.Intn(10*60)) * time.Minute)
+	}
+
+	stock := threshold*3 + rng.Intn(threshold*4)
+	movements := []movement{{stock, "purchase", start.Add(time.Hour)}}
+	demand := 1 + rng.Intn(4)
+
+	for day := 1; day < days; day++ {
+		if rng.Intn(10) < 7 && stock > 0 {
+			sold := 1 + rng.Intn(demand*2)
+			if sold > stock {
+				sold = stock
+			}
+			stock -= sold
+			movements = append(movements, movement{-sold, "sale", at(day)})
+		}
+
+		switch roll := rng.Intn(100); {
+		case roll < 3:
+			returned := 1 + rng.Intn(2)
+			stock += returned
+			movements = append(movements, movement{returned, "return", at(day)})
+		case roll < 5 && stock > 0:
+			stock--
+			movements = append(movements, movement{-1, "damage", at(day)})
+		case roll < 6:
+			adjusted := rng.Intn(5) - 2
+			if adjusted != 0 && stock+adjusted >= 0 {
+				stock += adjusted
+				movements = append(movements, movement{adjusted, "adjustment", at(day)})
+			}
+		}
+
+		// Some products are left low so the low-stock views have something to show
+		if stock <= threshold && day < days-7 && rng.Intn(3) > 0 {
+			restock := threshold*3 + rng.Intn(threshold*3)
+			stock += restock
+			movements = append(movements, movement{restock, "purchase", at(day)})
+		}
+	}
+	return movements
+}
+
+var movementNotes = map[string]string{
+	"purchase":   "Supplier delivery",
+	"sale":       "Customer order",
+	"return":     "Customer return",
+	"damage":     "Damaged in storage",
+	"adjustment": "Stock count correction",
+}
+
+func insertMovements(tx *sql.Tx, productID uuid.UUID, movements []movement, users []uuid.UUID, rng *rand.Rand) error {
+	n := len(movements)
+	productIDs, createdBy := make([]string, n), make([]string, n)
+	changes := make([]int64, n)
+	reasons, notes, createdAt := make([]string, n), make([]string, n), make([]string, n)
+	for i, m := range movements {
+		productIDs[i] = productID.String()
+		changes[i] = int64(m.change)
+		reasons[i] = m.reason
+		createdBy[i] = users[rng.Intn(len(users))].String()
+		createdAt[i] = m.at.Format(time.RFC3339)
+		notes[i] = movementNotes[m.reason]
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO stock_movements (product_id, change, reason, created_by, created_at, notes)
+		SELECT * FROM unnest($1::uuid[], $2::int[], $3::text[], $4::uuid[], $5::timestamptz[], $6::text[])`,
+		pq.Array(productIDs), pq.Array(changes), pq.Array(reasons), pq.Array(createdBy), pq.Array(createdAt), pq.Array(notes))
+	return err
+}
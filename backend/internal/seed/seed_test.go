@@ -0,0 +1,54 @@
+package seed
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for seed := int64(1); seed <= 50; seed++ {
+		rng := rand.New(rand.NewSource(seed))
+		movements := history(rng, 10, start, 90)
+		if len(movements) == 0 || movements[0].reason != "purchase" {
+			t.Fatalf("seed %d: expected an opening purchase, got %+v", seed, movements)
+		}
+
+		stock := 0
+		for _, m := range movements {
+			stock += m.change
+			if stock < 0 {
+				t.Fatalf("seed %d: stock went negative at %s", seed, m.at)
+			}
+			if m.at.Before(start) || m.at.After(start.AddDate(0, 0, 90)) {
+				t.Fatalf("seed %d: movement at %s is outside the history", seed, m.at)
+			}
+			if _, ok := movementNotes[m.reason]; !ok {
+				t.Fatalf("seed %d: unexpected reason %q", seed, m.reason)
+			}
+		}
+	}
+}
+
+func TestRunRejectsInvalidOptions(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Products = 0
+	if _, err := Run(nil, opts); err != ErrInvalidOptions {
+		t.Errorf("expected ErrInvalidOptions, got %v", err)
+	}
+}
+
+func TestRandomPassword(t *testing.T) {
+	first, err := randomPassword()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, _ := randomPassword()
+	if len(first) < 16 || first == second {
+		t.Errorf("expected distinct random passwords, got %q and %q", first, second)
+	}
+	if DefaultOptions().AdminPassword != "" {
+		t.Error("expected no default password")
+	}
+}
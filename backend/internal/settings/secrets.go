@@ -0,0 +1,188 @@
+package settings
+
+import (
+	"encoding/json"
+	"strings"
+
+	"rtims-backend/internal/models"
+)
+
+// Masked stands in for secret values wherever settings leave the server:
+// API reads, audit entries and settings revisions. Sending it back in an
+// update keeps the stored secret.
+const Masked = "[REDACTED]"
+
+// secretFields are the fields of JSON settings that hold credentials, such
+// as the access tokens of Shopify stores, the consumer secrets of
+// WooCommerce stores, the private key of the Google service account and
+// the passwords and keys in EDI, supplier and alert sink options.
+var secretFields = map[string]bool{
+	"access_token":      true,
+	"webhook_secret":    true,
+	"consumer_key":      true,
+	"consumer_secret":   true,
+	"client_secret":     true,
+	"private_key":       true,
+	"private_key_id":    true,
+	"password":          true,
+	"passphrase":        true,
+	"secret_access_key": true,
+	"routing_key":       true,
+	"refresh_token":     true,
+	"api_key":           true,
+	"token":             true,
+	"secret":            true,
+}
+
+// Redacted returns the stored settings typed, as Typed does, with secret
+// settings and the secret fields of JSON settings masked. Secrets that are
+// not set stay empty.
+func Redacted(stored map[string]interface{}) map[string]interface{} {
+	typed := Typed(stored)
+	for _, setting := range schema {
+		typed[setting.Key] = setting.redact(typed[setting.Key])
+	}
+	return typed
+}
+
+// redact masks a typed value of s.
+func (s Setting) redact(value interface{}) interface{} {
+	switch {
+	case s.Secret:
+		if value == "" {
+			return value
+		}
+		return Masked
+	case s.Type == TypeJSON:
+		return redactFields(value)
+	}
+	return value
+}
+
+func redactFields(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if text, ok := item.(string); ok && text != "" && secretFields[strings.ToLower(key)] {
+				redacted[key] = Masked
+			} else {
+				redacted[key] = redactFields(item)
+			}
+		}
+		return redacted
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = redactFields(item)
+		}
+		return items
+	}
+	return value
+}
+
+// RedactChanges returns a copy of the changes of a settings revision with
+// secrets masked. Revisions keep the values themselves, to roll back to.
+func RedactChanges(changes map[string]models.SettingChange) map[string]models.SettingChange {
+	redacted := make(map[string]models.SettingChange, len(changes))
+	for key, change := range changes {
+		setting, ok := Lookup(key)
+		if !ok {
+			redacted[key] = change
+			continue
+		}
+		change.New = setting.redactStored(change.New)
+		if change.Old != nil {
+			old := setting.redactStored(*change.Old)
+			change.Old = &old
+		}
+		redacted[key] = change
+	}
+	return redacted
+}
+
+// redactStored masks a stored value of s.
+func (s Setting) redactStored(value string) string {
+	switch {
+	case s.Secret:
+		if value == "" {
+			return value
+		}
+		return Masked
+	case s.Type == TypeJSON:
+		var object map[string]interface{}
+		if json.Unmarshal([]byte(value), &object) != nil {
+			return value
+		}
+		encoded, _ := json.Marshal(redactFields(object))
+		return string(encoded)
+	}
+	return value
+}
+
+// Unmask replaces the secrets updates send as Masked, as they were read,
+// with their stored values, so settings can be saved without entering
+// every secret again. It runs before Normalize, which validates the
+// result; masked fields a JSON setting did not have before are dropped.
+func Unmask(updates map[string]interface{}, stored map[string]interface{}) map[string]interface{} {
+	unmasked := make(map[string]interface{}, len(updates))
+	for key, value := range updates {
+		unmasked[key] = value
+		setting, ok := Lookup(key)
+		if !ok {
+			continue
+		}
+
+		current, _ := stored[key].(string)
+		switch {
+		case setting.Secret:
+			if value == Masked {
+				unmasked[key] = current
+			}
+		case setting.Type == TypeJSON:
+			object := value
+			if raw, ok := value.(string); ok {
+				var decoded map[string]interface{}
+				if json.Unmarshal([]byte(raw), &decoded) != nil {
+					continue
+				}
+				object = decoded
+			}
+			var currentObject map[string]interface{}
+			json.Unmarshal([]byte(current), &currentObject)
+			unmasked[key] = unmaskFields(object, currentObject)
+		}
+	}
+	return unmasked
+}
+
+// unmaskFields puts back the values of current where value has Masked.
+func unmaskFields(value, current interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		currentMap, _ := current.(map[string]interface{})
+		unmasked := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if item == Masked {
+				if previous, ok := currentMap[key]; ok {
+					unmasked[key] = previous
+				}
+				continue
+			}
+			unmasked[key] = unmaskFields(item, currentMap[key])
+		}
+		return unmasked
+	case []interface{}:
+		currentItems, _ := current.([]interface{})
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			var previous interface{}
+			if i < len(currentItems) {
+				previous = currentItems[i]
+			}
+			items[i] = unmaskFields(item, previous)
+		}
+		return items
+	}
+	return value
+}
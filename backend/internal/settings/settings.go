@@ -0,0 +1,468 @@
+// Package settings is the schema of the admin-editable system settings.
+// They are stored as text in system_settings; the schema says which keys
+// exist, what type and default each has, and which values are valid, so
+// updates can be checked before they are stored and reads can be typed.
+package settings
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"rtims-backend/config"
+	"rtims-backend/internal/accounting"
+	"rtims-backend/internal/alerting"
+	"rtims-backend/internal/audit"
+	"rtims-backend/internal/backup"
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/edi"
+	"rtims-backend/internal/ingest"
+	"rtims-backend/internal/middleware"
+	"rtims-backend/internal/mqtt"
+	"rtims-backend/internal/notify"
+	"rtims-backend/internal/reports"
+	"rtims-backend/internal/scheduler"
+	"rtims-backend/internal/sheets"
+	"rtims-backend/internal/shopify"
+	"rtims-backend/internal/telegram"
+	"rtims-backend/internal/woocommerce"
+)
+
+// Type is the type of a setting's value.
+type Type string
+
+const (
+	TypeString  Type = "string"
+	TypeInteger Type = "integer"
+	TypeBoolean Type = "boolean"
+	// TypeEnum is a string that must be one of the setting's Options.
+	TypeEnum Type = "enum"
+	// TypeJSON is a JSON object, stored as JSON text.
+	TypeJSON Type = "json"
+	// TypeSchedule is a cron expression, a descriptor such as "@daily", or
+	// "off"; empty means the job's default schedule.
+	TypeSchedule Type = "schedule"
+)
+
+// Groups, in the order the settings page shows them.
+const (
+	GroupInventory     = "inventory"
+	GroupSystem        = "system"
+	GroupBackups       = "backups"
+	GroupNotifications = "notifications"
+	GroupAudit         = "audit"
+	GroupReports       = "reports"
+	GroupSchedules     = "schedules"
+	GroupIntegrations  = "integrations"
+)
+
+var groups = []string{GroupInventory, GroupSystem, GroupBackups, GroupNotifications, GroupAudit, GroupReports, GroupSchedules, GroupIntegrations}
+
+// Setting describes one key of system_settings.
+type Setting struct {
+	Key         string   `json:"key"`
+	Group       string   `json:"group"`
+	Type        Type     `json:"type"`
+	Default     string   `json:"default"`
+	Description string   `json:"description"`
+	Options     []string `json:"options,omitempty"`
+	// Min is the smallest value of an integer setting.
+	Min *int `json:"min,omitempty"`
+	// ReadOnly settings are set by their own endpoint, not by updating
+	// settings.
+	ReadOnly bool `json:"read_only,omitempty"`
+	// Secret settings are read as Masked; the secret fields of JSON
+	// settings always are.
+	Secret bool `json:"secret,omitempty"`
+
+	// validate checks a stored value beyond its type.
+	validate func(value string) error
+}
+
+// Jobs are the scheduled jobs that have a schedule_<job> setting.
+var Jobs = []string{
+	"reports",
+	"low_stock_digest",
+	"notification_retention",
+	"outbox_purge",
+	"audit_retention",
+	"partition_maintenance",
+	"dashboard_snapshot",
+	"backup",
+	"woocommerce_reconcile",
+	"accounting_export",
+	"edi_846",
+	"supplier_ingest",
+	"report_retention",
+	"storage_cleanup",
+	"sheets_export",
+	"pending_deletions",
+}
+
+var zero = 0
+
+var schema = []Setting{
+	{Key: "low_stock_threshold", Group: GroupInventory, Type: TypeInteger, Default: "10", Min: &zero,
+		Description: "Stock level at or below which products count as low stock"},
+
+	{Key: "maintenance_mode", Group: GroupSystem, Type: TypeBoolean, Default: "false",
+		Description: "Whether the system is in maintenance mode"},
+	{Key: database.SettingDeleteUndoMinutes, Group: GroupSystem, Type: TypeInteger, Default: "10", Min: &zero,
+		Description: "Minutes a deleted user, category or product can be restored with its undo endpoint before it is removed; 0 deletes at once"},
+	{Key: middleware.SettingCORSOrigins, Group: GroupSystem, Type: TypeString,
+		Description: "Comma-separated browser origins allowed besides CORS_ALLOWED_ORIGINS, such as https://*.example.com for every subdomain",
+		validate:    optional(func(value string) error { _, err := config.ParseOrigins(value); return err })},
+
+	{Key: backup.SettingAuto, Group: GroupBackups, Type: TypeBoolean, Default: "true",
+		Description: "Whether backups run on a schedule"},
+	{Key: backup.SettingFrequency, Group: GroupBackups, Type: TypeEnum, Default: "daily",
+		Options:     []string{"hourly", "daily", "weekly", "monthly"},
+		Description: "How often scheduled backups run"},
+	{Key: backup.SettingRetentionCount, Group: GroupBackups, Type: TypeInteger, Default: "14", Min: &zero,
+		Description: "How many completed backups are kept; 0 keeps them all"},
+	{Key: backup.SettingRetentionDays, Group: GroupBackups, Type: TypeInteger, Default: "30", Min: &zero,
+		Description: "How many days completed backups are kept; 0 keeps them forever"},
+
+	{Key: notify.SettingSlackWebhookURL, Group: GroupNotifications, Type: TypeString, Secret: true,
+		Description: "Slack incoming webhook notifications are posted to"},
+	{Key: notify.SettingTeamsWebhookURL, Group: GroupNotifications, Type: TypeString, Secret: true,
+		Description: "Microsoft Teams incoming webhook notifications are posted to"},
+	{Key: notify.SettingWebhookChannels, Group: GroupNotifications, Type: TypeJSON, Default: "{}",
+		Description: "Object mapping notification types to the channel they are posted to",
+		validate:    validateChannels},
+	{Key: telegram.SettingBotToken, Group: GroupNotifications, Type: TypeString, Secret: true,
+		Description: "Token of the Telegram bot that sends critical alerts to linked chats and answers their commands",
+		validate:    optional(telegram.ValidateToken)},
+	{Key: telegram.SettingWebhookSecret, Group: GroupNotifications, Type: TypeString, Secret: true,
+		Description: "Secret Telegram sends with the bot's updates; register the webhook again after changing it",
+		validate:    optional(telegram.ValidateSecret)},
+	{Key: alerting.SettingSinks, Group: GroupNotifications, Type: TypeJSON, Default: "{}",
+		Description: "Object mapping alert sink names to their type (alertmanager, pagerduty or opsgenie) and options",
+		validate:    func(value string) error { _, err := alerting.ParseSinks(value); return err }},
+	{Key: alerting.SettingRoutes, Group: GroupNotifications, Type: TypeJSON, Default: "{}",
+		Description: "Object mapping alert route names to their events (stock_out, sync_failed or backup_failed), skus, sinks and severity",
+		validate:    func(value string) error { _, err := alerting.ParseRoutes(value); return err }},
+
+	{Key: audit.SettingRetentionDays, Group: GroupAudit, Type: TypeInteger, Default: "0", Min: &zero,
+		Description: "How many days audit logs are kept; 0 keeps them forever",
+		validate:    func(value string) error { _, err := audit.ParseRetentionDays(value); return err }},
+	{Key: audit.SettingArchive, Group: GroupAudit, Type: TypeBoolean, Default: "false",
+		Description: "Whether audit logs are archived to object storage before they are purged"},
+	{Key: audit.SettingStreamURL, Group: GroupAudit, Type: TypeString,
+		Description: "http, https, udp or tcp URL audit logs are streamed to",
+		validate:    optional(func(value string) error { _, err := audit.ParseStreamURL(value); return err })},
+	{Key: audit.SettingStreamAuth, Group: GroupAudit, Type: TypeString, Secret: true,
+		Description: "Authorization header sent with streamed audit logs"},
+	{Key: database.SettingAuditRedactedFields, Group: GroupAudit, Type: TypeString,
+		Description: "Comma-separated fields masked in audit logs, besides passwords and tokens"},
+	{Key: database.SettingAuditSamplePercent, Group: GroupAudit, Type: TypeInteger, Default: "0", Min: &zero,
+		Description: "Percentage of create, update and delete requests whose request and response bodies are kept in their audit log; 0 keeps none",
+		validate:    func(value string) error { _, err := database.ParseSamplePercent(value); return err }},
+	{Key: database.SettingAuditSamplePIISafe, Group: GroupAudit, Type: TypeBoolean, Default: "true",
+		Description: "Whether email addresses, phone numbers, addresses and other personal data are masked in sampled bodies"},
+
+	{Key: reports.SettingLocale, Group: GroupReports, Type: TypeEnum, Default: reports.DefaultLocale, Options: reports.LocaleCodes(),
+		Description: "Language and number format of reports"},
+	{Key: reports.SettingCurrency, Group: GroupReports, Type: TypeString,
+		Description: "Currency code of amounts in reports, such as EUR"},
+	{Key: reports.SettingPermissions, Group: GroupReports, Type: TypeJSON, Default: reports.DefaultPermissions(),
+		Description: "Object mapping roles to the report types they may generate",
+		validate:    func(value string) error { _, err := reports.ParsePermissions(value); return err }},
+	{Key: reports.SettingCompanyName, Group: GroupReports, Type: TypeString,
+		Description: "Company name printed on reports"},
+	{Key: reports.SettingCompanyDetails, Group: GroupReports, Type: TypeString,
+		Description: "Address and other details printed on reports"},
+	{Key: reports.SettingPrimaryColor, Group: GroupReports, Type: TypeString,
+		Description: "Hex color of report table headings, such as #1f6feb",
+		validate:    optional(func(value string) error { _, err := reports.ParseColor(value); return err })},
+	{Key: reports.SettingLogo, Group: GroupReports, Type: TypeString, ReadOnly: true,
+		Description: "Logo printed on reports, uploaded with its own endpoint"},
+	{Key: reports.SettingRetentionDays, Group: GroupReports, Type: TypeInteger, Default: "90", Min: &zero,
+		Description: "How many days generated reports are kept; 0 keeps them all"},
+
+	{Key: shopify.SettingStores, Group: GroupIntegrations, Type: TypeJSON, Default: "{}",
+		Description: "Object mapping Shopify shop domains to their access_token, webhook_secret, location_id and user_email",
+		validate:    func(value string) error { _, err := shopify.ParseStores(value); return err }},
+	{Key: woocommerce.SettingStores, Group: GroupIntegrations, Type: TypeJSON, Default: "{}",
+		Description: "Object mapping WooCommerce store names to their url, consumer_key, consumer_secret and user_email",
+		validate:    func(value string) error { _, err := woocommerce.ParseStores(value); return err }},
+	{Key: accounting.SettingAutoExport, Group: GroupIntegrations, Type: TypeEnum, Default: "off", Options: accounting.AutoExportOptions,
+		Description: "Format the previous month's journal is exported in on the 1st of every month, or off"},
+	{Key: accounting.SettingAccounts, Group: GroupIntegrations, Type: TypeJSON, Default: accounting.DefaultAccountsSetting(),
+		Description: "Object with the inventory, received, cost_of_sales and adjustments accounts journal lines are posted to",
+		validate:    func(value string) error { _, err := accounting.ParseAccounts(value); return err }},
+	{Key: edi.SettingPartners, Group: GroupIntegrations, Type: TypeJSON, Default: "{}",
+		Description: "Object mapping EDI trading partner names to their organization, interchange qualifiers and IDs, transport and options",
+		validate:    func(value string) error { _, err := edi.ParsePartners(value); return err }},
+	{Key: ingest.SettingSuppliers, Group: GroupIntegrations, Type: TypeJSON, Default: "{}",
+		Description: "Object mapping supplier names to their user_email, source, options, file pattern, columns and quantity mode",
+		validate:    func(value string) error { _, err := ingest.ParseSuppliers(value); return err }},
+	{Key: mqtt.SettingDevices, Group: GroupIntegrations, Type: TypeJSON, Default: "{}",
+		Description: "Object mapping MQTT scale and smart shelf names to their topic, product sku, user_email, reading type, unit weight, threshold and debounce",
+		validate:    func(value string) error { _, err := mqtt.ParseDevices(value); return err }},
+	{Key: sheets.SettingServiceAccount, Group: GroupIntegrations, Type: TypeJSON, Default: "{}",
+		Description: "JSON key of the Google service account Sheets exports sign in as; share each spreadsheet with its client_email",
+		validate:    func(value string) error { _, err := sheets.ParseServiceAccount(value); return err }},
+	{Key: sheets.SettingExports, Group: GroupIntegrations, Type: TypeJSON, Default: "{}",
+		Description: "Object mapping Google Sheets export names to their spreadsheet_id, sheet, user_email, source (low_stock or report) and report",
+		validate:    func(value string) error { _, err := sheets.ParseExports(value); return err }},
+}
+
+func init() {
+	for _, name := range Jobs {
+		schema = append(schema, Setting{
+			Key:         scheduler.SettingPrefix + name,
+			Group:       GroupSchedules,
+			Type:        TypeSchedule,
+			Description: fmt.Sprintf("Schedule of the %s job; empty runs it on its default schedule", name),
+		})
+	}
+}
+
+// optional skips validate for empty values.
+func optional(validate func(string) error) func(string) error {
+	return func(value string) error {
+		if value == "" {
+			return nil
+		}
+		return validate(value)
+	}
+}
+
+func validateChannels(value string) error {
+	var channels map[string]string
+	if err := json.Unmarshal([]byte(value), &channels); err != nil {
+		return errors.New("must be an object mapping notification types to channel names")
+	}
+	return nil
+}
+
+// Group is the settings of one group of the schema.
+type Group struct {
+	Name     string    `json:"name"`
+	Settings []Setting `json:"settings"`
+}
+
+// Grouped returns the schema by group.
+func Grouped() []Group {
+	grouped := make([]Group, 0, len(groups))
+	for _, name := range groups {
+		group := Group{Name: name}
+		for _, setting := range schema {
+			if setting.Group == name {
+				group.Settings = append(group.Settings, setting)
+			}
+		}
+		grouped = append(grouped, group)
+	}
+	return grouped
+}
+
+// Lookup returns the setting for key.
+func Lookup(key string) (Setting, bool) {
+	for _, setting := range schema {
+		if setting.Key == key {
+			return setting, true
+		}
+	}
+	return Setting{}, false
+}
+
+// UnknownError is returned for updates naming keys the schema does not
+// have, or read-only ones.
+type UnknownError struct {
+	Keys []string
+}
+
+func (e *UnknownError) Error() string {
+	return "unknown settings: " + strings.Join(e.Keys, ", ")
+}
+
+// InvalidError is returned for an update with a value its setting does not
+// accept.
+type InvalidError struct {
+	Key string
+	Err error
+}
+
+func (e *InvalidError) Error() string {
+	return e.Key + ": " + e.Err.Error()
+}
+
+func (e *InvalidError) Unwrap() error {
+	return e.Err
+}
+
+// Normalize checks updates, as decoded from a JSON request, against the
+// schema and returns them as the text to store. Values may be sent typed
+// or as their text; null resets a setting to its default.
+func Normalize(updates map[string]interface{}) (map[string]string, error) {
+	var unknown []string
+	for key := range updates {
+		if setting, ok := Lookup(key); !ok || setting.ReadOnly {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, &UnknownError{Keys: unknown}
+	}
+
+	keys := make([]string, 0, len(updates))
+	for key := range updates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	normalized := make(map[string]string, len(updates))
+	for _, key := range keys {
+		setting, _ := Lookup(key)
+		value, err := setting.normalize(updates[key])
+		if err != nil {
+			return nil, &InvalidError{Key: key, Err: err}
+		}
+		normalized[key] = value
+	}
+	return normalized, nil
+}
+
+func (s Setting) normalize(value interface{}) (string, error) {
+	if value == nil {
+		return s.Default, nil
+	}
+
+	var stored string
+	switch s.Type {
+	case TypeInteger:
+		switch v := value.(type) {
+		case float64:
+			if v != math.Trunc(v) {
+				return "", errors.New("must be a whole number")
+			}
+			stored = strconv.FormatInt(int64(v), 10)
+		case string:
+			if _, err := strconv.Atoi(strings.TrimSpace(v)); err != nil {
+				return "", errors.New("must be a whole number")
+			}
+			stored = strings.TrimSpace(v)
+		default:
+			return "", errors.New("must be a whole number")
+		}
+		if s.Min != nil {
+			if n, _ := strconv.Atoi(stored); n < *s.Min {
+				return "", fmt.Errorf("must be at least %d", *s.Min)
+			}
+		}
+	case TypeBoolean:
+		switch v := value.(type) {
+		case bool:
+			stored = strconv.FormatBool(v)
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err != nil {
+				return "", errors.New("must be true or false")
+			}
+			stored = strconv.FormatBool(b)
+		default:
+			return "", errors.New("must be true or false")
+		}
+	case TypeJSON:
+		raw, isString := value.(string)
+		if !isString {
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return "", errors.New("must be a JSON object")
+			}
+			raw = string(encoded)
+		}
+		var object map[string]interface{}
+		if err := json.Unmarshal([]byte(raw), &object); err != nil || object == nil {
+			return "", errors.New("must be a JSON object")
+		}
+		// Store it compactly, whatever whitespace it was sent with
+		encoded, _ := json.Marshal(object)
+		stored = string(encoded)
+	default:
+		v, ok := value.(string)
+		if !ok {
+			return "", errors.New("must be a string")
+		}
+		stored = strings.TrimSpace(v)
+	}
+
+	switch s.Type {
+	case TypeEnum:
+		if !contains(s.Options, stored) {
+			return "", fmt.Errorf("must be one of %s", strings.Join(s.Options, ", "))
+		}
+	case TypeSchedule:
+		if stored != "" {
+			if err := scheduler.ValidateSchedule(stored); err != nil {
+				return "", err
+			}
+		}
+	}
+	if s.validate != nil {
+		if err := s.validate(stored); err != nil {
+			return "", err
+		}
+	}
+	return stored, nil
+}
+
+func contains(options []string, value string) bool {
+	for _, option := range options {
+		if option == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Typed returns the settings of the schema from stored, as read with
+// database.SettingsService.GetSettings, with their typed values. Settings
+// not stored get their default; stored keys the schema does not have are
+// left out.
+func Typed(stored map[string]interface{}) map[string]interface{} {
+	typed := make(map[string]interface{}, len(schema))
+	for _, setting := range schema {
+		value, ok := stored[setting.Key].(string)
+		if !ok {
+			value = setting.Default
+		}
+		typed[setting.Key] = setting.typed(value)
+	}
+	return typed
+}
+
+// typed converts a stored value. Values stored before they were validated
+// may not convert; those fall back to the default.
+func (s Setting) typed(value string) interface{} {
+	switch s.Type {
+	case TypeInteger:
+		if n, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+			return n
+		}
+		n, _ := strconv.Atoi(s.Default)
+		return n
+	case TypeBoolean:
+		if b, err := strconv.ParseBool(strings.TrimSpace(value)); err == nil {
+			return b
+		}
+		b, _ := strconv.ParseBool(s.Default)
+		return b
+	case TypeJSON:
+		var object map[string]interface{}
+		if err := json.Unmarshal([]byte(value), &object); err == nil && object != nil {
+			return object
+		}
+		object = map[string]interface{}{}
+		json.Unmarshal([]byte(s.Default), &object)
+		return object
+	}
+	return value
+}
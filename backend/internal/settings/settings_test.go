@@ -0,0 +1,129 @@
+package settings
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSchema(t *testing.T) {
+	seen := map[string]bool{}
+	for _, setting := range schema {
+		if seen[setting.Key] {
+			t.Errorf("%s: defined twice", setting.Key)
+		}
+		seen[setting.Key] = true
+		if setting.Description == "" {
+			t.Errorf("%s: expected a description", setting.Key)
+		}
+		if !contains(groups, setting.Group) {
+			t.Errorf("%s: unknown group %q", setting.Key, setting.Group)
+		}
+		// Defaults and options must be values the setting accepts
+		if _, err := setting.normalize(setting.Default); err != nil {
+			t.Errorf("%s: default %q is invalid: %v", setting.Key, setting.Default, err)
+		}
+		for _, option := range setting.Options {
+			if _, err := setting.normalize(option); err != nil {
+				t.Errorf("%s: option %q is invalid: %v", setting.Key, option, err)
+			}
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	normalized, err := Normalize(map[string]interface{}{
+		"low_stock_threshold":  5.0,
+		"auto_backup":          "false",
+		"maintenance_mode":     true,
+		"backup_frequency":     "weekly",
+		"audit_retention_days": "90",
+		"webhook_channels":     map[string]interface{}{"low_stock": "#ops"},
+		"report_permissions":   `{ "staff": ["inventory"] }`,
+		"report_primary_color": "",
+		"schedule_backup":      " @daily ",
+		"report_currency":      nil,
+	})
+	if err != nil {
+		t.Fatalf("Normalize: %v", err)
+	}
+	want := map[string]string{
+		"low_stock_threshold":  "5",
+		"auto_backup":          "false",
+		"maintenance_mode":     "true",
+		"backup_frequency":     "weekly",
+		"audit_retention_days": "90",
+		"webhook_channels":     `{"low_stock":"#ops"}`,
+		"report_permissions":   `{"staff":["inventory"]}`,
+		"report_primary_color": "",
+		"schedule_backup":      "@daily",
+		"report_currency":      "",
+	}
+	if !reflect.DeepEqual(normalized, want) {
+		t.Errorf("expected %v, got %v", want, normalized)
+	}
+}
+
+func TestNormalizeRejects(t *testing.T) {
+	var unknown *UnknownError
+	_, err := Normalize(map[string]interface{}{"low_stock_treshold": 5.0, "report_logo": "logo.png", "maintenance_mode": true})
+	if !errors.As(err, &unknown) || !reflect.DeepEqual(unknown.Keys, []string{"low_stock_treshold", "report_logo"}) {
+		t.Errorf("expected the misspelt and read-only keys to be rejected, got %v", err)
+	}
+
+	for key, value := range map[string]interface{}{
+		"low_stock_threshold":   2.5,
+		"backup_retention_days": -1.0,
+		"maintenance_mode":      "yes please",
+		"backup_frequency":      "fortnightly",
+		"webhook_channels":      `["#ops"]`,
+		"report_permissions":    map[string]interface{}{"owner": []string{"inventory"}},
+		"report_primary_color":  "teal",
+		"audit_stream_url":      "ftp://siem.example.com",
+		"schedule_reports":      "every minute",
+		"report_company_name":   42.0,
+		"cors_allowed_origins":  "https://app.example.com/login",
+		"audit_sample_percent":  150.0,
+		"delete_undo_minutes":   -5.0,
+	} {
+		var invalid *InvalidError
+		if _, err := Normalize(map[string]interface{}{key: value}); !errors.As(err, &invalid) || invalid.Key != key {
+			t.Errorf("%s: expected %v to be rejected, got %v", key, value, err)
+		}
+	}
+}
+
+func TestTyped(t *testing.T) {
+	typed := Typed(map[string]interface{}{
+		"low_stock_threshold":   "5",
+		"auto_backup":           "false",
+		"webhook_channels":      `{"low_stock":"#ops"}`,
+		"backup_retention_days": "soon",
+		"retired_setting":       "x",
+	})
+	if typed["low_stock_threshold"] != 5 || typed["auto_backup"] != false {
+		t.Errorf("expected typed values, got %v", typed)
+	}
+	if !reflect.DeepEqual(typed["webhook_channels"], map[string]interface{}{"low_stock": "#ops"}) {
+		t.Errorf("expected JSON settings as objects, got %v", typed["webhook_channels"])
+	}
+	if typed["backup_retention_days"] != 30 || typed["maintenance_mode"] != false || typed["schedule_backup"] != "" {
+		t.Errorf("expected defaults for invalid and unset settings, got %v", typed)
+	}
+	if _, ok := typed["retired_setting"]; ok {
+		t.Error("expected keys outside the schema to be left out")
+	}
+}
+
+func TestGrouped(t *testing.T) {
+	count := 0
+	for _, group := range Grouped() {
+		if len(group.Settings) == 0 {
+			t.Errorf("group %s has no settings", group.Name)
+		}
+		count += len(group.Settings)
+	}
+	if count != len(schema) {
+		t.Errorf("expected every setting in a group, got %d of %d", count, len(schema))
+	}
+}
@@ -0,0 +1,236 @@
+// Package sftp exchanges files with trading partners and suppliers over
+// SFTP, with github.com/pkg/sftp over SSH with a pinned host key: it lists
+// directories, reads, writes and renames files.
+package sftp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	pkgsftp "github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// timeout bounds a connection whose context has no deadline.
+const timeout = 2 * time.Minute
+
+// partSuffix names the temporary file an upload is written to.
+const partSuffix = ".part"
+
+// Config is how to reach and log in to a server. HostKey is the SHA256
+// fingerprint of the server's key, as ssh-keygen -l prints it, and
+// PrivateKey an unencrypted PEM key used instead of, or as well as,
+// Password.
+type Config struct {
+	Host       string
+	Port       string
+	Username   string
+	Password   string
+	PrivateKey string
+	HostKey    string
+}
+
+// ConfigFromOptions reads a Config from the options of a partner or
+// supplier profile: host, port (22 unless set), username, password,
+// private_key and host_key.
+func ConfigFromOptions(options map[string]string) Config {
+	port := options["port"]
+	if port == "" {
+		port = "22"
+	}
+	return Config{
+		Host:       options["host"],
+		Port:       port,
+		Username:   options["username"],
+		Password:   options["password"],
+		PrivateKey: options["private_key"],
+		HostKey:    options["host_key"],
+	}
+}
+
+// Validate checks that c is complete, naming its options as
+// ConfigFromOptions reads them.
+func (c Config) Validate() error {
+	_, err := c.clientConfig()
+	return err
+}
+
+func (c Config) clientConfig() (*ssh.ClientConfig, error) {
+	var missing []string
+	if c.Host == "" {
+		missing = append(missing, "host")
+	}
+	if c.Username == "" {
+		missing = append(missing, "username")
+	}
+	if c.HostKey == "" {
+		missing = append(missing, "host_key")
+	}
+	if c.Password == "" && c.PrivateKey == "" {
+		missing = append(missing, "password or private_key")
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("needs %s", strings.Join(missing, ", "))
+	}
+	if !strings.HasPrefix(c.HostKey, "SHA256:") {
+		return nil, errors.New("host_key must be a SHA256: fingerprint")
+	}
+
+	var auth []ssh.AuthMethod
+	if c.PrivateKey != "" {
+		signer, err := ssh.ParsePrivateKey([]byte(c.PrivateKey))
+		if err != nil {
+			return nil, errors.New("private_key must be an unencrypted PEM private key")
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if c.Password != "" {
+		auth = append(auth, ssh.Password(c.Password))
+	}
+
+	fingerprint := c.HostKey
+	return &ssh.ClientConfig{
+		User: c.Username,
+		Auth: auth,
+		HostKeyCallback: func(_ string, _ net.Addr, key ssh.PublicKey) error {
+			if ssh.FingerprintSHA256(key) != fingerprint {
+				return fmt.Errorf("host key %s does not match host_key", ssh.FingerprintSHA256(key))
+			}
+			return nil
+		},
+		Timeout: 30 * time.Second,
+	}, nil
+}
+
+// Client is a connection to an SFTP server.
+type Client struct {
+	ssh  *ssh.Client
+	sftp *pkgsftp.Client
+}
+
+// Dial connects and logs in to the server of config. The connection
+// lasts until ctx's deadline, or two minutes if it has none.
+func Dial(ctx context.Context, config Config) (*Client, error) {
+	clientConfig, err := config.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+	addr := net.JoinHostPort(config.Host, config.Port)
+
+	dialer := net.Dialer{Timeout: clientConfig.Timeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(timeout)
+	}
+	netConn.SetDeadline(deadline)
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(netConn, addr, clientConfig)
+	if err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	client := &Client{ssh: ssh.NewClient(sshConn, chans, reqs)}
+	if client.sftp, err = pkgsftp.NewClient(client.ssh); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("sftp subsystem: %w", err)
+	}
+	return client, nil
+}
+
+// Close ends the session and the connection.
+func (c *Client) Close() error {
+	if c.sftp != nil {
+		c.sftp.Close()
+	}
+	if c.ssh == nil {
+		return nil
+	}
+	return c.ssh.Close()
+}
+
+// Upload writes data to a temporary file next to path and renames it to
+// path once it is complete, so readers never see part of it.
+func (c *Client) Upload(path string, data []byte) error {
+	part := path + partSuffix
+	file, err := c.sftp.OpenFile(part, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return fmt.Errorf("sftp open %s: %w", part, err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("sftp write %s: %w", part, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("sftp close %s: %w", part, err)
+	}
+	return c.Rename(part, path)
+}
+
+// ReadDir returns the names of the regular files in dir, sorted.
+func (c *Client) ReadDir(dir string) ([]string, error) {
+	entries, err := c.sftp.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("sftp readdir %s: %w", dir, err)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		if entry.Mode().IsRegular() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ReadFile returns the content of the file at path, which may be at most
+// maxSize bytes. A missing file is os.ErrNotExist.
+func (c *Client) ReadFile(path string, maxSize int) ([]byte, error) {
+	file, err := c.sftp.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("sftp open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, int64(maxSize)+1))
+	if err != nil {
+		return nil, fmt.Errorf("sftp read %s: %w", path, err)
+	}
+	if len(data) > maxSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", path, maxSize)
+	}
+	return data, nil
+}
+
+// Rename moves the file at from to to, which must not exist.
+func (c *Client) Rename(from, to string) error {
+	if err := c.sftp.Rename(from, to); err != nil {
+		return fmt.Errorf("sftp rename %s: %w", from, err)
+	}
+	return nil
+}
+
+// Mkdir creates dir if it does not exist yet.
+func (c *Client) Mkdir(dir string) error {
+	_, err := c.sftp.Stat(dir)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("sftp stat %s: %w", dir, err)
+	}
+	if err := c.sftp.Mkdir(dir); err != nil {
+		return fmt.Errorf("sftp mkdir %s: %w", dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package sftp
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	pkgsftp "github.com/pkg/sftp"
+)
+
+// newFake returns a client connected to an in-memory server.
+func newFake(t *testing.T) *Client {
+	serverConn, clientConn := net.Pipe()
+	server := pkgsftp.NewRequestServer(serverConn, pkgsftp.InMemHandler())
+	go server.Serve()
+
+	client, err := pkgsftp.NewClientPipe(clientConn, clientConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return &Client{sftp: client}
+}
+
+// write puts a file on the server.
+func write(t *testing.T, client *Client, path string, data []byte) {
+	file, err := client.sftp.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write(data)
+	file.Close()
+}
+
+func TestUpload(t *testing.T) {
+	client := newFake(t)
+	if err := client.Mkdir("/inbound"); err != nil {
+		t.Fatal(err)
+	}
+	document := bytes.Repeat([]byte("LIN*1*SK*WID-1~\n"), 5000) // Several writes
+	if err := client.Upload("/inbound/846.edi", document); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := client.ReadDir("/inbound")
+	if err != nil || !reflect.DeepEqual(names, []string{"846.edi"}) {
+		t.Errorf("expected only 846.edi, got %v, %v", names, err)
+	}
+	data, err := client.ReadFile("/inbound/846.edi", len(document))
+	if err != nil || !bytes.Equal(data, document) {
+		t.Errorf("expected the document back, got %d bytes, %v", len(data), err)
+	}
+}
+
+func TestReadDirAndFile(t *testing.T) {
+	client := newFake(t)
+	content := strings.Repeat("sku,qty\nWID-1,5\n", 4000)
+	for _, dir := range []string{"/drop", "/drop/processed", "/other"} {
+		if err := client.Mkdir(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(t, client, "/drop/b.csv", []byte(content))
+	write(t, client, "/drop/a.csv", []byte("sku,qty\n"))
+	write(t, client, "/other/c.csv", nil)
+
+	names, err := client.ReadDir("/drop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{"a.csv", "b.csv"}) {
+		t.Errorf("expected the files of drop, got %v", names)
+	}
+
+	data, err := client.ReadFile("/drop/b.csv", len(content))
+	if err != nil || string(data) != content {
+		t.Errorf("expected the file back, got %d bytes, %v", len(data), err)
+	}
+	if _, err := client.ReadFile("/drop/b.csv", len(content)-1); err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("expected a size error, got %v", err)
+	}
+	if _, err := client.ReadFile("/drop/missing.csv", 10); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected os.ErrNotExist, got %v", err)
+	}
+}
+
+func TestMkdirAndRename(t *testing.T) {
+	client := newFake(t)
+	for _, dir := range []string{"/drop", "/drop/failed", "/drop/failed"} {
+		if err := client.Mkdir(dir); err != nil {
+			t.Fatalf("%s: %v", dir, err)
+		}
+	}
+	write(t, client, "/drop/a.csv", []byte("sku,qty\n"))
+
+	if err := client.Rename("/drop/a.csv", "/drop/failed/a-1.csv"); err != nil {
+		t.Fatal(err)
+	}
+	names, _ := client.ReadDir("/drop/failed")
+	if !reflect.DeepEqual(names, []string{"a-1.csv"}) {
+		t.Errorf("expected the file to be moved, got %v", names)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		options map[string]string
+		want    string
+	}{
+		{"empty", map[string]string{}, "needs host, username, host_key, password or private_key"},
+		{"fingerprint", map[string]string{"host": "h", "username": "u", "password": "p", "host_key": "ab:cd"}, "host_key must be"},
+		{"key", map[string]string{"host": "h", "username": "u", "private_key": "nope", "host_key": "SHA256:x"}, "private_key must be"},
+	}
+	for _, tt := range tests {
+		if err := ConfigFromOptions(tt.options).Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	config := ConfigFromOptions(map[string]string{"host": "h", "username": "u", "password": "p", "host_key": "SHA256:x"})
+	if err := config.Validate(); err != nil || config.Port != "22" {
+		t.Errorf("expected a valid config on port 22, got %+v, %v", config, err)
+	}
+}
@@ -0,0 +1,201 @@
+package sheets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	defaultTokenURI = "https://oauth2.googleapis.com/token"
+	defaultBaseURL  = "https://sheets.googleapis.com/v4/spreadsheets"
+	scope           = "https://www.googleapis.com/auth/spreadsheets"
+
+	// tokenLifetime is how long the access tokens asked for last, and
+	// tokenMargin how long before expiry one is replaced.
+	tokenLifetime = time.Hour
+	tokenMargin   = time.Minute
+)
+
+// maxResponseBody is the largest response read, and maxErrorBody how much
+// of an error response is kept in its error.
+const (
+	maxResponseBody = 1 << 20
+	maxErrorBody    = 512
+)
+
+// APIError is a response of Google outside 2xx.
+type APIError struct {
+	Status int
+	Body   string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Google Sheets returned status %d: %s", e.Status, e.Body)
+}
+
+// Client calls the Sheets API as a service account, keeping its access
+// token until shortly before it expires.
+type Client struct {
+	BaseURL    string
+	httpClient *http.Client
+
+	mu      sync.Mutex
+	account ServiceAccount
+	token   string
+	expires time.Time
+}
+
+func NewClient() *Client {
+	return &Client{BaseURL: defaultBaseURL, httpClient: &http.Client{Timeout: 60 * time.Second}}
+}
+
+// accessToken returns a token of account, signing in again when the
+// account changed or its token is about to expire.
+func (c *Client) accessToken(ctx context.Context, account ServiceAccount) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.account == account && time.Now().Add(tokenMargin).Before(c.expires) {
+		return c.token, nil
+	}
+
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(account.PrivateKey))
+	if err != nil {
+		return "", fmt.Errorf("invalid service account key: %w", err)
+	}
+	now := time.Now()
+	assertion := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   account.ClientEmail,
+		"scope": scope,
+		"aud":   account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(tokenLifetime).Unix(),
+	})
+	assertion.Header["kid"] = account.PrivateKeyID
+	signed, err := assertion.SignedString(key)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"}, "assertion": {signed}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, account.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := c.send(req, &token); err != nil {
+		return "", fmt.Errorf("failed to sign in as %s: %w", account.ClientEmail, err)
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("failed to sign in as %s: no access token returned", account.ClientEmail)
+	}
+
+	c.account = account
+	c.token = token.AccessToken
+	c.expires = now.Add(time.Duration(token.ExpiresIn) * time.Second)
+	return c.token, nil
+}
+
+// Replace makes rows the only contents of the tab titled sheet, adding
+// the tab if the spreadsheet lacks it. Values are written as they are, so
+// text that looks like a formula stays text. It returns the range written.
+func (c *Client) Replace(ctx context.Context, account ServiceAccount, spreadsheetID, sheet string, rows [][]interface{}) (string, error) {
+	token, err := c.accessToken(ctx, account)
+	if err != nil {
+		return "", err
+	}
+	base := c.BaseURL + "/" + url.PathEscape(spreadsheetID)
+
+	var spreadsheet struct {
+		Sheets []struct {
+			Properties struct {
+				Title string `json:"title"`
+			} `json:"properties"`
+		} `json:"sheets"`
+	}
+	if err := c.do(ctx, token, http.MethodGet, base+"?fields=sheets.properties.title", nil, &spreadsheet); err != nil {
+		return "", fmt.Errorf("failed to open spreadsheet %s: %w", spreadsheetID, err)
+	}
+	found := false
+	for _, s := range spreadsheet.Sheets {
+		found = found || s.Properties.Title == sheet
+	}
+	if !found {
+		addSheet := map[string]interface{}{"requests": []interface{}{
+			map[string]interface{}{"addSheet": map[string]interface{}{"properties": map[string]interface{}{"title": sheet}}},
+		}}
+		if err := c.do(ctx, token, http.MethodPost, base+":batchUpdate", addSheet, nil); err != nil {
+			return "", fmt.Errorf("failed to add sheet %s: %w", sheet, err)
+		}
+	}
+
+	// Quotes inside a sheet title are doubled in A1 notation
+	tab := "'" + strings.ReplaceAll(sheet, "'", "''") + "'"
+	if err := c.do(ctx, token, http.MethodPost, base+"/values/"+url.PathEscape(tab)+":clear", map[string]interface{}{}, nil); err != nil {
+		return "", fmt.Errorf("failed to clear sheet %s: %w", sheet, err)
+	}
+	values := map[string]interface{}{"range": tab + "!A1", "majorDimension": "ROWS", "values": rows}
+	var updated struct {
+		UpdatedRange string `json:"updatedRange"`
+	}
+	if err := c.do(ctx, token, http.MethodPut, base+"/values/"+url.PathEscape(tab+"!A1")+"?valueInputOption=RAW", values, &updated); err != nil {
+		return "", fmt.Errorf("failed to write sheet %s: %w", sheet, err)
+	}
+	return updated.UpdatedRange, nil
+}
+
+// do sends in, if it is given, as JSON with the access token and decodes
+// a 2xx response into out, if it is given.
+func (c *Client) do(ctx context.Context, token, method, rawURL string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		encoded, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.send(req, out)
+}
+
+func (c *Client) send(req *http.Request, out interface{}) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return &APIError{Status: resp.StatusCode, Body: string(message)}
+	}
+	if out != nil {
+		if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBody)).Decode(out); err != nil {
+			return errors.New("invalid response from Google")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package sheets
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/models"
+	"rtims-backend/internal/reports"
+
+	"github.com/google/uuid"
+)
+
+// MaxRows is the most rows an export pushes, well within the cells a
+// spreadsheet holds.
+const MaxRows = 50000
+
+// lowStockColumns are the headings of the low stock list, translated like
+// those of the inventory report.
+var lowStockColumns = []string{"Name", "SKU", "Category", "Stock", "Minimum Threshold", "Price"}
+
+// Exporter pushes the configured exports to their sheets.
+type Exporter struct {
+	db                  *sql.DB
+	settingsService     *database.SettingsService
+	userService         *database.UserService
+	organizationService *database.OrganizationService
+	productService      *database.ProductService
+	client              *Client
+}
+
+func NewExporter(db *sql.DB, cache *database.Cache) *Exporter {
+	return &Exporter{
+		db:                  db,
+		settingsService:     database.NewSettingsService(db),
+		userService:         database.NewUserService(db),
+		organizationService: database.NewOrganizationService(db),
+		productService:      database.NewCachedProductService(db, cache),
+		client:              NewClient(),
+	}
+}
+
+// config returns the service account and the configured exports.
+func (e *Exporter) config() (*ServiceAccount, map[string]Export, error) {
+	settings, err := e.settingsService.GetSettings()
+	if err != nil {
+		return nil, nil, err
+	}
+	value, _ := settings[SettingServiceAccount].(string)
+	account, err := ParseServiceAccount(value)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid %s setting: %w", SettingServiceAccount, err)
+	}
+	value, _ = settings[SettingExports].(string)
+	exports, err := ParseExports(value)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid %s setting: %w", SettingExports, err)
+	}
+	return account, exports, nil
+}
+
+// Run pushes every configured export. It is the scheduled job; an export
+// that fails is logged without stopping the others.
+func (e *Exporter) Run() error {
+	account, exports, err := e.config()
+	if err != nil {
+		return err
+	}
+	if len(exports) == 0 {
+		return nil
+	}
+	if account == nil {
+		return ErrNotConfigured
+	}
+
+	names := make([]string, 0, len(exports))
+	for name := range exports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	failed := 0
+	for _, name := range names {
+		push, err := e.push(context.Background(), *account, exports[name])
+		if err != nil {
+			log.Printf("Pushing Sheets export %s failed: %v", name, err)
+			failed++
+			continue
+		}
+		log.Printf("Pushed Sheets export %s: %d rows to %s", name, push.Rows, push.Range)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d Sheets exports could not be pushed", failed, len(names))
+	}
+	return nil
+}
+
+// RunExport pushes the export called name now.
+func (e *Exporter) RunExport(ctx context.Context, name string) (*models.SheetsPush, error) {
+	account, exports, err := e.config()
+	if err != nil {
+		return nil, err
+	}
+	export, ok := exports[name]
+	if !ok {
+		return nil, ErrUnknownExport
+	}
+	if account == nil {
+		return nil, ErrNotConfigured
+	}
+	return e.push(ctx, *account, export)
+}
+
+func (e *Exporter) push(ctx context.Context, account ServiceAccount, export Export) (*models.SheetsPush, error) {
+	ctx, user, err := e.account(ctx, export)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := e.rows(ctx, export, user)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) > MaxRows+1 {
+		return nil, fmt.Errorf("export has %d rows, more than the %d pushed to a sheet", len(rows)-1, MaxRows)
+	}
+
+	written, err := e.client.Replace(ctx, account, export.SpreadsheetID, export.Sheet, rows)
+	if err != nil {
+		return nil, err
+	}
+	return &models.SheetsPush{
+		Export:        export.Name,
+		SpreadsheetID: export.SpreadsheetID,
+		Sheet:         export.Sheet,
+		Range:         written,
+		Rows:          len(rows) - 1,
+		PushedAt:      time.Now(),
+	}, nil
+}
+
+// account returns the user an export acts as, and ctx scoped to the user's
+// organization, which must be active.
+func (e *Exporter) account(ctx context.Context, export Export) (context.Context, *models.User, error) {
+	user, err := e.userService.GetUserByEmail(ctx, export.UserEmail)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, fmt.Errorf("%w: no account for %s", ErrExportAccount, export.UserEmail)
+		}
+		return nil, nil, err
+	}
+	if !user.IsActive {
+		return nil, nil, fmt.Errorf("%w: %s is deactivated", ErrExportAccount, export.UserEmail)
+	}
+
+	organizationID := user.OrganizationID
+	if organizationID == uuid.Nil {
+		organizationID = database.DefaultOrganizationID
+	}
+	organization, err := e.organizationService.GetOrganization(ctx, organizationID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !organization.IsActive {
+		return nil, nil, fmt.Errorf("%w: organization %s is deactivated", ErrExportAccount, organization.Name)
+	}
+	return database.WithOrganization(ctx, organizationID), user, nil
+}
+
+// rows returns the headings and rows an export pushes, in the user's
+// locale.
+func (e *Exporter) rows(ctx context.Context, export Export, user *models.User) ([][]interface{}, error) {
+	localeCode := reports.LocaleFor(e.db, &user.ID)
+	locale, ok := reports.LookupLocale(localeCode)
+	if !ok {
+		locale, _ = reports.LookupLocale(reports.DefaultLocale)
+	}
+
+	if export.Source == SourceLowStock {
+		products, _, err := e.productService.GetProducts(ctx, models.ProductFilter{LowStockOnly: true, SortBy: "stock", SortOrder: "ASC"})
+		if err != nil {
+			return nil, err
+		}
+		header := make([]interface{}, len(lowStockColumns))
+		for i, column := range lowStockColumns {
+			header[i] = locale.T(column)
+		}
+		rows := [][]interface{}{header}
+		for _, product := range products {
+			rows = append(rows, []interface{}{product.Name, product.SKU, product.Category, product.Stock, product.MinimumThreshold, product.Price})
+		}
+		return rows, nil
+	}
+
+	allowed := false
+	for _, reportType := range reports.AllowedTypes(e.db, user.Role) {
+		allowed = allowed || reportType == export.Report.Type
+	}
+	if !allowed {
+		return nil, fmt.Errorf("%w: %s may not run %s reports", ErrExportAccount, user.Email, export.Report.Type)
+	}
+
+	params := export.Report.params()
+	params.Locale = localeCode
+	organizationID, _ := database.OrganizationFrom(ctx)
+	params.OrganizationID = organizationID.String()
+	if export.Report.Days > 0 {
+		params.StartDate = time.Now().AddDate(0, 0, -export.Report.Days).Format("2006-01-02")
+	}
+	report, err := reports.Generate(e.db, params)
+	if err != nil {
+		return nil, err
+	}
+
+	header, data := reports.Table(report)
+	rows := make([][]interface{}, 0, len(data)+1)
+	headings := make([]interface{}, len(header))
+	for i, heading := range header {
+		headings[i] = heading
+	}
+	rows = append(rows, headings)
+	for _, values := range data {
+		for i, value := range values {
+			// Sheets takes times as text when written as they are
+			if t, ok := value.(time.Time); ok {
+				values[i] = locale.FormatDateTime(t)
+			}
+		}
+		rows = append(rows, values)
+	}
+	return rows, nil
+}
@@ -0,0 +1,209 @@
+// Package sheets pushes the low stock list or a report to Google Sheets
+// for managers who work there. Each export in settings names a
+// spreadsheet, the tab to fill and what to fill it with; the sheets_export
+// job replaces the tab's contents with fresh figures on its schedule,
+// signed in as the Google service account in settings, which the
+// spreadsheets must be shared with.
+package sheets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strings"
+
+	"rtims-backend/internal/reports"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Settings keys that configure the exports.
+const (
+	// SettingServiceAccount holds the JSON key of the Google service
+	// account the exports sign in as.
+	SettingServiceAccount = "google_service_account"
+	// SettingExports holds a JSON object of the exports, by a name of
+	// lowercase letters, digits, hyphens and underscores, e.g.
+	// {"low-stock": {"spreadsheet_id": "1BxiM…", "sheet": "Low stock",
+	// "user_email": "manager@example.com", "source": "low_stock"}}. Each
+	// export covers the organization of its user, and a report only one
+	// the user may run.
+	SettingExports = "sheets_exports"
+)
+
+// Sources of the rows an export pushes.
+const (
+	// SourceLowStock is the products at or below their minimum threshold.
+	SourceLowStock = "low_stock"
+	// SourceReport is a report, chosen by the export's Report.
+	SourceReport = "report"
+)
+
+// maxSheetTitle is the longest tab title Sheets accepts.
+const maxSheetTitle = 100
+
+var (
+	// ErrNotConfigured is returned when no service account is set.
+	ErrNotConfigured = errors.New("Google service account is not configured")
+	// ErrUnknownExport is returned for an export that is not configured.
+	ErrUnknownExport = errors.New("Sheets export is not configured")
+	// ErrExportAccount is returned when the user an export acts as cannot
+	// be used: there is no such account, it or its organization is
+	// deactivated, or it may not run the export's report.
+	ErrExportAccount = errors.New("Sheets export account is unavailable")
+)
+
+var (
+	exportName    = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,99}$`)
+	spreadsheetID = regexp.MustCompile(`^[A-Za-z0-9_-]{20,100}$`)
+)
+
+// ServiceAccount is the part of a service account's JSON key used to sign
+// in.
+type ServiceAccount struct {
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// ParseServiceAccount parses the SettingServiceAccount value. It returns
+// nil for an empty value.
+func ParseServiceAccount(value string) (*ServiceAccount, error) {
+	var account ServiceAccount
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	if err := json.Unmarshal([]byte(value), &account); err != nil {
+		return nil, errors.New("must be the JSON key of a Google service account")
+	}
+	if account.ClientEmail == "" && account.PrivateKey == "" {
+		return nil, nil
+	}
+	if _, err := mail.ParseAddress(account.ClientEmail); err != nil {
+		return nil, errors.New("needs the client_email of the service account")
+	}
+	if _, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(account.PrivateKey)); err != nil {
+		return nil, errors.New("needs the private_key of the service account as an RSA key in PEM")
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = defaultTokenURI
+	}
+	if !strings.HasPrefix(account.TokenURI, "https://") {
+		return nil, errors.New("token_uri must be an https URL")
+	}
+	return &account, nil
+}
+
+// ReportOptions choose the report an export pushes. Days, unless 0,
+// limits it to the last that many days.
+type ReportOptions struct {
+	Type     string `json:"type"`
+	Category string `json:"category,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	GroupBy  string `json:"group_by,omitempty"`
+	Days     int    `json:"days,omitempty"`
+	Limit    int    `json:"limit,omitempty"`
+}
+
+// Export is the profile of one export. Rows go to the tab titled Sheet,
+// the export's name unless set, which is added if the spreadsheet lacks
+// it.
+type Export struct {
+	Name          string         `json:"-"`
+	SpreadsheetID string         `json:"spreadsheet_id"`
+	Sheet         string         `json:"sheet,omitempty"`
+	UserEmail     string         `json:"user_email"`
+	Source        string         `json:"source"`
+	Report        *ReportOptions `json:"report,omitempty"`
+}
+
+// ParseExports parses the SettingExports value, checking that every
+// profile is complete and that no two exports fill the same tab.
+func ParseExports(value string) (map[string]Export, error) {
+	exports := map[string]Export{}
+	if strings.TrimSpace(value) == "" {
+		return exports, nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&exports); err != nil {
+		return nil, errors.New("must be an object mapping export names to export profiles")
+	}
+
+	names := make([]string, 0, len(exports))
+	for name := range exports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tabs := map[string]string{}
+	for _, name := range names {
+		export := exports[name]
+		export.Name = name
+		export.UserEmail = strings.ToLower(export.UserEmail)
+		if export.Sheet == "" {
+			export.Sheet = name
+		}
+		if !exportName.MatchString(name) {
+			return nil, fmt.Errorf("%q is not an export name of lowercase letters, digits, hyphens and underscores", name)
+		}
+		if err := export.check(); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		tab := export.SpreadsheetID + "/" + export.Sheet
+		if other, ok := tabs[tab]; ok {
+			return nil, fmt.Errorf("%s: sheet %s is already filled by %s", name, export.Sheet, other)
+		}
+		tabs[tab] = name
+		exports[name] = export
+	}
+	return exports, nil
+}
+
+func (e Export) check() error {
+	if !spreadsheetID.MatchString(e.SpreadsheetID) {
+		return errors.New("needs the spreadsheet_id from the spreadsheet's URL")
+	}
+	if len([]rune(e.Sheet)) > maxSheetTitle || strings.ContainsAny(e.Sheet, "[]*?:/\\") {
+		return fmt.Errorf("sheet must be a tab title of at most %d characters without []*?:/\\", maxSheetTitle)
+	}
+	if _, err := mail.ParseAddress(e.UserEmail); err != nil {
+		return errors.New("needs the user_email of the account it acts as")
+	}
+	switch e.Source {
+	case SourceLowStock:
+		if e.Report != nil {
+			return errors.New("report only applies to the report source")
+		}
+	case SourceReport:
+		if e.Report == nil {
+			return errors.New("needs the report to push")
+		}
+		if e.Report.Days < 0 {
+			return errors.New("report days must be at least 0")
+		}
+		if err := e.Report.params().Validate(); err != nil {
+			return fmt.Errorf("report: %w", err)
+		}
+	default:
+		return errors.New("source must be low_stock or report")
+	}
+	return nil
+}
+
+// params are the report parameters of the options, without the period and
+// organization, which are set when it runs.
+func (o ReportOptions) params() reports.Params {
+	return reports.Params{
+		Type:     o.Type,
+		Format:   "json",
+		Category: o.Category,
+		Reason:   o.Reason,
+		GroupBy:  o.GroupBy,
+		Limit:    o.Limit,
+	}
+}
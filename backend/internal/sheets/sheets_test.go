@@ -0,0 +1,175 @@
+package sheets
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const testSpreadsheet = "1BxiMVs0XRA5nFMdKvBdBZjgmUUqptlbs74OgvE2upms"
+
+func testKey(t *testing.T) (*rsa.PrivateKey, string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+func TestParseServiceAccount(t *testing.T) {
+	_, privateKey := testKey(t)
+	encoded, _ := json.Marshal(map[string]string{
+		"type": "service_account", "client_email": "rtims@project.iam.gserviceaccount.com", "private_key": privateKey,
+	})
+	account, err := ParseServiceAccount(string(encoded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.TokenURI != defaultTokenURI {
+		t.Errorf("expected the default token URI, got %s", account.TokenURI)
+	}
+
+	for _, value := range []string{"", "{}"} {
+		if account, err := ParseServiceAccount(value); account != nil || err != nil {
+			t.Errorf("expected no account for %q, got %v, %v", value, account, err)
+		}
+	}
+	if _, err := ParseServiceAccount(`{"client_email": "rtims@project.iam.gserviceaccount.com", "private_key": "x"}`); err == nil ||
+		!strings.Contains(err.Error(), "private_key") {
+		t.Errorf("expected a bad key to be refused, got %v", err)
+	}
+}
+
+func TestParseExports(t *testing.T) {
+	exports, err := ParseExports(`{"low-stock": {"spreadsheet_id": "` + testSpreadsheet + `", "user_email": "Manager@Example.com", "source": "low_stock"},
+		"movements": {"spreadsheet_id": "` + testSpreadsheet + `", "sheet": "Movements", "user_email": "manager@example.com",
+			"source": "report", "report": {"type": "movements", "group_by": "day", "days": 30}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lowStock := exports["low-stock"]
+	if lowStock.Name != "low-stock" || lowStock.Sheet != "low-stock" || lowStock.UserEmail != "manager@example.com" {
+		t.Errorf("unexpected export %+v", lowStock)
+	}
+
+	export := func(fields string) string {
+		return `{"a": {"spreadsheet_id": "` + testSpreadsheet + `", "user_email": "a@b.c", ` + fields + `}}`
+	}
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"not an object", `["a"]`, "must be an object"},
+		{"spreadsheet", `{"a": {"spreadsheet_id": "x", "user_email": "a@b.c", "source": "low_stock"}}`, "needs the spreadsheet_id"},
+		{"sheet", export(`"sheet": "Q1/Q2", "source": "low_stock"`), "tab title"},
+		{"source", export(`"source": "orders"`), "source must be low_stock or report"},
+		{"no report", export(`"source": "report"`), "needs the report"},
+		{"report type", export(`"source": "report", "report": {"type": "sales"}`), "invalid report type"},
+		{"same tab", `{"a": {"spreadsheet_id": "` + testSpreadsheet + `", "sheet": "S", "user_email": "a@b.c", "source": "low_stock"},
+			"b": {"spreadsheet_id": "` + testSpreadsheet + `", "sheet": "S", "user_email": "a@b.c", "source": "low_stock"}}`, "already filled by a"},
+	}
+	for _, tt := range tests {
+		if _, err := ParseExports(tt.value); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestReplace(t *testing.T) {
+	key, privateKey := testKey(t)
+	var requests []string
+	var written map[string]interface{}
+	tokens := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			r.ParseForm()
+			assertion, err := jwt.Parse(r.PostForm.Get("assertion"), func(*jwt.Token) (interface{}, error) { return &key.PublicKey, nil })
+			if err != nil || assertion.Claims.(jwt.MapClaims)["scope"] != scope {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			tokens++
+			io.WriteString(w, `{"access_token": "ya29.test", "expires_in": 3599, "token_type": "Bearer"}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer ya29.test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		switch {
+		case r.Method == http.MethodGet:
+			io.WriteString(w, `{"sheets": [{"properties": {"title": "Sheet1"}}]}`)
+		case r.Method == http.MethodPut:
+			json.Unmarshal(body, &written)
+			io.WriteString(w, `{"updatedRange": "'Bob''s list'!A1:B2", "updatedRows": 2}`)
+		default:
+			io.WriteString(w, `{}`)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	client.BaseURL = server.URL + "/v4/spreadsheets"
+	account := ServiceAccount{ClientEmail: "rtims@project.iam.gserviceaccount.com", PrivateKey: privateKey, TokenURI: server.URL + "/token"}
+	rows := [][]interface{}{{"Name", "Stock"}, {"=HYPERLINK(\"x\")", 3}}
+
+	for i := 0; i < 2; i++ {
+		written, err := client.Replace(context.Background(), account, testSpreadsheet, "Bob's list", rows)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if written != "'Bob''s list'!A1:B2" {
+			t.Errorf("unexpected range %s", written)
+		}
+	}
+	if tokens != 1 {
+		t.Errorf("expected the access token to be reused, signed in %d times", tokens)
+	}
+
+	expected := []string{
+		"GET /v4/spreadsheets/" + testSpreadsheet + "?fields=sheets.properties.title",
+		"POST /v4/spreadsheets/" + testSpreadsheet + ":batchUpdate",
+		"POST /v4/spreadsheets/" + testSpreadsheet + "/values/%27Bob%27%27s%20list%27:clear",
+		"PUT /v4/spreadsheets/" + testSpreadsheet + "/values/%27Bob%27%27s%20list%27%21A1?valueInputOption=RAW",
+	}
+	if strings.Join(requests[:4], ",") != strings.Join(expected, ",") {
+		t.Errorf("expected requests %v, got %v", expected, requests[:4])
+	}
+	if values := written["values"].([]interface{}); len(values) != 2 || values[1].([]interface{})[1] != 3.0 {
+		t.Errorf("unexpected values %v", written)
+	}
+
+	// A spreadsheet not shared with the account
+	client.BaseURL = server.URL + "/missing"
+	account.TokenURI = server.URL + "/token?again"
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/token") {
+			io.WriteString(w, `{"access_token": "ya29.test", "expires_in": 3599}`)
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, `{"error": {"status": "PERMISSION_DENIED"}}`)
+	})
+	var apiErr *APIError
+	if _, err := client.Replace(context.Background(), account, testSpreadsheet, "Sheet1", rows); !errors.As(err, &apiErr) || apiErr.Status != http.StatusForbidden {
+		t.Errorf("expected a 403 from Google, got %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package shopify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// APIVersion is the version of the Shopify Admin REST API the client
+// calls.
+const APIVersion = "2024-07"
+
+// maxErrorBody is how much of an error response is kept in its error.
+const maxErrorBody = 512
+
+// ErrVariantNotFound is returned for a variant the store does not have.
+var ErrVariantNotFound = errors.New("Shopify variant not found")
+
+// nextLink finds the URL of the next page in a Link header.
+var nextLink = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// APIError is a response of the Admin API outside 2xx.
+type APIError struct {
+	Status int
+	Body   string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Shopify returned status %d: %s", e.Status, e.Body)
+}
+
+// Retryable reports whether the request may succeed when sent again: the
+// store was rate limited or failed.
+func (e *APIError) Retryable() bool {
+	return e.Status == http.StatusTooManyRequests || e.Status >= 500
+}
+
+// Variant is a product variant of a store.
+type Variant struct {
+	ID              int64  `json:"id"`
+	ProductID       int64  `json:"product_id"`
+	SKU             string `json:"sku"`
+	InventoryItemID int64  `json:"inventory_item_id"`
+}
+
+// Client calls the Admin REST API of stores.
+type Client struct {
+	httpClient *http.Client
+	// baseURL returns the API root of shop, which tests point at a fake.
+	baseURL func(shop string) string
+}
+
+func NewClient() *Client {
+	return &Client{
+		httpClient: &http.Client{Timeout: 15 * time.Second},
+		baseURL: func(shop string) string {
+			return "https://" + shop + "/admin/api/" + APIVersion
+		},
+	}
+}
+
+// Variants returns every variant of every product of store.
+func (c *Client) Variants(ctx context.Context, store Store) ([]Variant, error) {
+	var variants []Variant
+	url := c.baseURL(store.Shop) + "/products.json?limit=250&fields=id,variants"
+	for url != "" {
+		var page struct {
+			Products []struct {
+				Variants []Variant `json:"variants"`
+			} `json:"products"`
+		}
+		resp, err := c.do(ctx, store, http.MethodGet, url, nil, &page)
+		if err != nil {
+			return nil, err
+		}
+		for _, product := range page.Products {
+			variants = append(variants, product.Variants...)
+		}
+
+		url = ""
+		if match := nextLink.FindStringSubmatch(resp.Header.Get("Link")); match != nil {
+			url = match[1]
+		}
+	}
+	return variants, nil
+}
+
+// Variant returns a variant of store.
+func (c *Client) Variant(ctx context.Context, store Store, id int64) (*Variant, error) {
+	var body struct {
+		Variant Variant `json:"variant"`
+	}
+	_, err := c.do(ctx, store, http.MethodGet, fmt.Sprintf("%s/variants/%d.json", c.baseURL(store.Shop), id), nil, &body)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound {
+		return nil, ErrVariantNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &body.Variant, nil
+}
+
+// SetInventoryLevel sets the available quantity of an inventory item at
+// the store's location.
+func (c *Client) SetInventoryLevel(ctx context.Context, store Store, inventoryItemID int64, available int) error {
+	body := map[string]interface{}{
+		"location_id":       store.LocationID,
+		"inventory_item_id": inventoryItemID,
+		"available":         available,
+	}
+	_, err := c.do(ctx, store, http.MethodPost, c.baseURL(store.Shop)+"/inventory_levels/set.json", body, nil)
+	return err
+}
+
+// do sends a request authenticated as store and decodes a 2xx response
+// into out, if it is given.
+func (c *Client) do(ctx context.Context, store Store, method, url string, in, out interface{}) (*http.Response, error) {
+	var body io.Reader
+	if in != nil {
+		encoded, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Shopify-Access-Token", store.AccessToken)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return nil, &APIError{Status: resp.StatusCode, Body: string(message)}
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return nil, fmt.Errorf("invalid Shopify response: %w", err)
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,112 @@
+package shopify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func testClient(server *httptest.Server) *Client {
+	return &Client{httpClient: server.Client(), baseURL: func(string) string { return server.URL }}
+}
+
+var testStore = Store{Shop: "acme.myshopify.com", AccessToken: "shpat_test", LocationID: 42}
+
+func TestVariants(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Shopify-Access-Token") != "shpat_test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("page_info") == "" {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/products.json?limit=250&page_info=next>; rel="next"`, server.URL))
+			fmt.Fprint(w, `{"products": [{"variants": [{"id": 1, "sku": "A", "inventory_item_id": 11}, {"id": 2, "sku": "B", "inventory_item_id": 12}]}]}`)
+			return
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<%s/products.json?page_info=first>; rel="previous"`, server.URL))
+		fmt.Fprint(w, `{"products": [{"variants": [{"id": 3, "sku": "", "inventory_item_id": 13}]}]}`)
+	}))
+	defer server.Close()
+
+	variants, err := testClient(server).Variants(context.Background(), testStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(variants) != 3 || variants[1].SKU != "B" || variants[2].InventoryItemID != 13 {
+		t.Errorf("expected the variants of both pages, got %+v", variants)
+	}
+}
+
+func TestSetInventoryLevel(t *testing.T) {
+	var body map[string]int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/inventory_levels/set.json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		fmt.Fprint(w, `{"inventory_level": {}}`)
+	}))
+	defer server.Close()
+
+	if err := testClient(server).SetInventoryLevel(context.Background(), testStore, 11, 7); err != nil {
+		t.Fatal(err)
+	}
+	if body["location_id"] != 42 || body["inventory_item_id"] != 11 || body["available"] != 7 {
+		t.Errorf("unexpected request body %v", body)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/variants/9.json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"errors": "Exceeded 2 calls per second"}`)
+	}))
+	defer server.Close()
+	client := testClient(server)
+
+	if _, err := client.Variant(context.Background(), testStore, 9); !errors.Is(err, ErrVariantNotFound) {
+		t.Errorf("expected ErrVariantNotFound, got %v", err)
+	}
+
+	err := client.SetInventoryLevel(context.Background(), testStore, 11, 7)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusTooManyRequests || !apiErr.Retryable() {
+		t.Errorf("expected a retryable 429, got %v", err)
+	}
+}
+
+func TestRetryAt(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		err      error
+		attempts int
+		want     time.Duration
+	}{
+		{"network error", errors.New("connection refused"), 1, 30 * time.Second},
+		{"rate limited", &APIError{Status: http.StatusTooManyRequests}, 3, 2 * time.Minute},
+		{"rejected", &APIError{Status: http.StatusUnprocessableEntity}, 1, 0},
+		{"unknown store", ErrUnknownStore, 1, 0},
+		{"out of attempts", &APIError{Status: http.StatusBadGateway}, MaxAttempts, 0},
+	}
+	for _, tt := range tests {
+		next := retryAt(tt.err, tt.attempts, now)
+		switch {
+		case tt.want == 0 && next != nil:
+			t.Errorf("%s: expected no retry, got %v", tt.name, next)
+		case tt.want != 0 && (next == nil || next.Sub(now) != tt.want):
+			t.Errorf("%s: expected a retry in %v, got %v", tt.name, tt.want, next)
+		}
+	}
+}
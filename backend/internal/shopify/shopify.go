@@ -0,0 +1,126 @@
+// Package shopify syncs inventory with the Shopify stores configured in
+// settings. Products are mapped to store variants, stock levels are pushed
+// to the mapped variants as they change, and orders Shopify sends by
+// webhook are recorded as sale movements.
+package shopify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SettingStores holds a JSON object of the stores to sync, by shop domain,
+// e.g. {"acme.myshopify.com": {"access_token": "shpat_…",
+// "webhook_secret": "…", "location_id": 12345, "user_email":
+// "shopify@acme.example.com"}}. Each store syncs the products of the
+// organization of its user, who its orders are recorded by.
+const SettingStores = "shopify_stores"
+
+// Headers of the webhooks Shopify sends.
+const (
+	TopicHeader     = "X-Shopify-Topic"
+	ShopHeader      = "X-Shopify-Shop-Domain"
+	SignatureHeader = "X-Shopify-Hmac-Sha256"
+)
+
+// TopicOrderCreated is the webhook topic of a new order.
+const TopicOrderCreated = "orders/create"
+
+// ErrUnknownStore is returned for a shop that is not configured.
+var ErrUnknownStore = errors.New("Shopify store is not configured")
+
+// ErrStoreAccount is returned when the user a store acts as cannot be used:
+// there is no such account, or it or its organization is deactivated.
+var ErrStoreAccount = errors.New("Shopify store account is unavailable")
+
+var shopDomain = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*\.myshopify\.com$`)
+
+// Store is the configuration of one Shopify store.
+type Store struct {
+	Shop          string `json:"-"`
+	AccessToken   string `json:"access_token"`
+	WebhookSecret string `json:"webhook_secret"`
+	// LocationID is the Shopify location whose inventory is set.
+	LocationID int64  `json:"location_id"`
+	UserEmail  string `json:"user_email"`
+}
+
+// ParseStores parses the SettingStores value, checking that every store is
+// complete.
+func ParseStores(value string) (map[string]Store, error) {
+	stores := map[string]Store{}
+	if strings.TrimSpace(value) == "" {
+		return stores, nil
+	}
+	if err := json.Unmarshal([]byte(value), &stores); err != nil {
+		return nil, errors.New("must be an object mapping shop domains to stores")
+	}
+
+	shops := make([]string, 0, len(stores))
+	for shop := range stores {
+		shops = append(shops, shop)
+	}
+	sort.Strings(shops)
+
+	for _, shop := range shops {
+		store := stores[shop]
+		store.Shop = shop
+		var missing []string
+		if store.AccessToken == "" {
+			missing = append(missing, "access_token")
+		}
+		if store.WebhookSecret == "" {
+			missing = append(missing, "webhook_secret")
+		}
+		if store.LocationID <= 0 {
+			missing = append(missing, "location_id")
+		}
+		if !strings.Contains(store.UserEmail, "@") {
+			missing = append(missing, "user_email")
+		}
+		switch {
+		case !shopDomain.MatchString(shop):
+			return nil, fmt.Errorf("%q is not a shop domain such as example.myshopify.com", shop)
+		case len(missing) > 0:
+			return nil, fmt.Errorf("%s needs %s", shop, strings.Join(missing, ", "))
+		}
+		store.UserEmail = strings.ToLower(store.UserEmail)
+		stores[shop] = store
+	}
+	return stores, nil
+}
+
+// Verify reports whether signature, the SignatureHeader of a webhook, is
+// the base64 HMAC-SHA256 of body keyed by secret.
+func Verify(secret string, body []byte, signature string) bool {
+	expected, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// Order is the part of a Shopify order that is ingested.
+type Order struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	LineItems []LineItem `json:"line_items"`
+}
+
+// LineItem is a line of an order. VariantID is null for custom items and
+// items whose variant was deleted.
+type LineItem struct {
+	ID        int64  `json:"id"`
+	VariantID *int64 `json:"variant_id"`
+	SKU       string `json:"sku"`
+	Quantity  int    `json:"quantity"`
+}
@@ -0,0 +1,52 @@
+package shopify
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseStores(t *testing.T) {
+	stores, err := ParseStores(`{"acme.myshopify.com": {"access_token": "shpat_1", "webhook_secret": "s", "location_id": 42, "user_email": "Shop@Acme.example.com"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := stores["acme.myshopify.com"]
+	if store.Shop != "acme.myshopify.com" || store.LocationID != 42 || store.UserEmail != "shop@acme.example.com" {
+		t.Errorf("unexpected store %+v", store)
+	}
+
+	if stores, err := ParseStores(""); err != nil || len(stores) != 0 {
+		t.Errorf("expected no stores for an empty value, got %v, %v", stores, err)
+	}
+
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"not an object", `["acme.myshopify.com"]`, "must be an object"},
+		{"not a shop domain", `{"acme.com": {"access_token": "t", "webhook_secret": "s", "location_id": 1, "user_email": "a@b.c"}}`, "not a shop domain"},
+		{"incomplete", `{"acme.myshopify.com": {"access_token": "t"}}`, "needs webhook_secret, location_id, user_email"},
+	}
+	for _, tt := range tests {
+		if _, err := ParseStores(tt.value); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	// printf '{"id":1}' | openssl dgst -sha256 -hmac secret -binary | base64
+	signature := "A971iWIMgT8Zj9A9eWfikrFj7wQ16/Qwcc4OlRl2PLc="
+	body := []byte(`{"id":1}`)
+
+	if !Verify("secret", body, signature) {
+		t.Error("expected the signature to verify")
+	}
+	if Verify("other", body, signature) || Verify("secret", []byte(`{"id":2}`), signature) {
+		t.Error("expected the signature to depend on the secret and body")
+	}
+	if Verify("secret", body, "") || Verify("secret", body, "not base64!") {
+		t.Error("expected a missing or malformed signature not to verify")
+	}
+}
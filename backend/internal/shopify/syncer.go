@@ -0,0 +1,416 @@
+package shopify
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"rtims-backend/internal/alerting"
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxAttempts is how many times a stock push is sent before it fails.
+	MaxAttempts = 8
+	// baseBackoff is the wait before the first retry of a push; each retry
+	// after it waits twice as long.
+	baseBackoff = 30 * time.Second
+	batchSize   = 50
+)
+
+// Backoff returns how long to wait before retrying a push that has been
+// sent attempts times.
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	return baseBackoff << (attempts - 1)
+}
+
+// retryAt returns when a push that failed with err on its attempts-th
+// attempt is sent again, or nil if it is not: it has run out of attempts,
+// or the store rejected it in a way a retry will not change.
+func retryAt(err error, attempts int, now time.Time) *time.Time {
+	var apiErr *APIError
+	if attempts >= MaxAttempts || errors.Is(err, ErrUnknownStore) || (errors.As(err, &apiErr) && !apiErr.Retryable()) {
+		return nil
+	}
+	next := now.Add(Backoff(attempts))
+	return &next
+}
+
+// Syncer maps products to store variants, pushes queued stock levels to
+// them and ingests orders.
+type Syncer struct {
+	shopifyService      *database.ShopifyService
+	settingsService     *database.SettingsService
+	userService         *database.UserService
+	organizationService *database.OrganizationService
+	productService      *database.ProductService
+	client              *Client
+
+	// Alerts, when set, raises an alert when a stock push fails for good.
+	Alerts *alerting.Router
+}
+
+func NewSyncer(db *sql.DB, cache *database.Cache) *Syncer {
+	return &Syncer{
+		shopifyService:      database.NewShopifyService(db),
+		settingsService:     database.NewSettingsService(db),
+		userService:         database.NewUserService(db),
+		organizationService: database.NewOrganizationService(db),
+		productService:      database.NewCachedProductService(db, cache),
+		client:              NewClient(),
+	}
+}
+
+// Stores returns the configured stores.
+func (s *Syncer) Stores() (map[string]Store, error) {
+	settings, err := s.settingsService.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	value, _ := settings[SettingStores].(string)
+	return ParseStores(value)
+}
+
+// Store returns the configuration of shop.
+func (s *Syncer) Store(shop string) (Store, error) {
+	stores, err := s.Stores()
+	if err != nil {
+		return Store{}, err
+	}
+	store, ok := stores[shop]
+	if !ok {
+		return Store{}, ErrUnknownStore
+	}
+	return store, nil
+}
+
+// Status returns the configured stores with the state of their sync.
+func (s *Syncer) Status(ctx context.Context) ([]models.ShopifyStore, error) {
+	stores, err := s.Stores()
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]models.ShopifyStore, 0, len(stores))
+	for _, store := range stores {
+		state := models.ShopifyStore{Shop: store.Shop, LocationID: store.LocationID, UserEmail: store.UserEmail}
+		if err := s.shopifyService.GetShopifyStoreStatus(ctx, &state); err != nil {
+			return nil, err
+		}
+		status = append(status, state)
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].Shop < status[j].Shop })
+	return status, nil
+}
+
+// account returns the user a store acts as, and ctx scoped to the user's
+// organization, which must be active.
+func (s *Syncer) account(ctx context.Context, store Store) (context.Context, *models.User, error) {
+	user, err := s.userService.GetUserByEmail(ctx, store.UserEmail)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, fmt.Errorf("%w: no account for %s", ErrStoreAccount, store.UserEmail)
+		}
+		return nil, nil, err
+	}
+	if !user.IsActive {
+		return nil, nil, fmt.Errorf("%w: %s is deactivated", ErrStoreAccount, store.UserEmail)
+	}
+
+	organizationID := user.OrganizationID
+	if organizationID == uuid.Nil {
+		organizationID = database.DefaultOrganizationID
+	}
+	organization, err := s.organizationService.GetOrganization(ctx, organizationID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !organization.IsActive {
+		return nil, nil, fmt.Errorf("%w: organization %s is deactivated", ErrStoreAccount, organization.Name)
+	}
+	return database.WithOrganization(ctx, organizationID), user, nil
+}
+
+// Variants returns the variants of shop mapped to products.
+func (s *Syncer) Variants(ctx context.Context, shop string) ([]models.ShopifyVariant, error) {
+	store, err := s.Store(shop)
+	if err != nil {
+		return nil, err
+	}
+	ctx, _, err = s.account(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	return s.shopifyService.GetShopifyVariants(ctx, shop)
+}
+
+// SyncVariants maps every variant of shop to the product with its SKU, and
+// queues the stock of the mapped products to be pushed. Variants mapped by
+// hand keep their product unless their SKU now matches another.
+func (s *Syncer) SyncVariants(ctx context.Context, shop string) (*models.ShopifyVariantSync, error) {
+	store, err := s.Store(shop)
+	if err != nil {
+		return nil, err
+	}
+	ctx, _, err = s.account(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+
+	variants, err := s.client.Variants(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	var skus []string
+	for _, variant := range variants {
+		if variant.SKU != "" {
+			skus = append(skus, variant.SKU)
+		}
+	}
+	products, err := s.productService.GetProductsBySKU(ctx, skus)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.ShopifyVariantSync{Unmatched: []string{}}
+	var mapped []models.ShopifyVariant
+	for _, variant := range variants {
+		if variant.SKU == "" {
+			continue
+		}
+		result.Variants++
+		product, ok := products[variant.SKU]
+		if !ok {
+			result.Unmatched = append(result.Unmatched, variant.SKU)
+			continue
+		}
+		mapped = append(mapped, models.ShopifyVariant{
+			Shop:            shop,
+			VariantID:       variant.ID,
+			InventoryItemID: variant.InventoryItemID,
+			ProductID:       product.ID,
+			SKU:             variant.SKU,
+		})
+	}
+	result.Mapped = len(mapped)
+
+	if err := s.shopifyService.SaveShopifyVariants(ctx, mapped); err != nil {
+		return nil, err
+	}
+	if err := s.shopifyService.QueueShopifyStorePushes(ctx, shop); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// MapVariant maps a variant of shop to a product of the store's
+// organization, and queues the product's stock to be pushed to it.
+func (s *Syncer) MapVariant(ctx context.Context, shop string, variantID int64, req models.MapShopifyVariantRequest) (*models.ShopifyVariant, error) {
+	store, err := s.Store(shop)
+	if err != nil {
+		return nil, err
+	}
+	ctx, _, err = s.account(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+
+	product, err := s.productService.GetProduct(ctx, req.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	variant, err := s.client.Variant(ctx, store, variantID)
+	if err != nil {
+		return nil, err
+	}
+
+	mapping := models.ShopifyVariant{
+		Shop:            shop,
+		VariantID:       variant.ID,
+		InventoryItemID: variant.InventoryItemID,
+		ProductID:       product.ID,
+		ProductName:     product.Name,
+		SKU:             variant.SKU,
+		CreatedAt:       time.Now(),
+	}
+	if err := s.shopifyService.SaveShopifyVariants(ctx, []models.ShopifyVariant{mapping}); err != nil {
+		return nil, err
+	}
+	if err := s.shopifyService.QueueShopifyStockPushes(ctx, product.ID); err != nil {
+		return nil, err
+	}
+	return &mapping, nil
+}
+
+// UnmapVariant stops syncing a variant of shop.
+func (s *Syncer) UnmapVariant(ctx context.Context, shop string, variantID int64) error {
+	store, err := s.Store(shop)
+	if err != nil {
+		return err
+	}
+	ctx, _, err = s.account(ctx, store)
+	if err != nil {
+		return err
+	}
+	return s.shopifyService.DeleteShopifyVariant(ctx, shop, variantID)
+}
+
+// OrderLines returns the most recently ingested order lines of shop.
+func (s *Syncer) OrderLines(ctx context.Context, shop string, limit int) ([]models.ShopifyOrderLine, error) {
+	if _, err := s.Store(shop); err != nil {
+		return nil, err
+	}
+	return s.shopifyService.GetShopifyOrderLines(ctx, shop, limit)
+}
+
+// IngestOrder records every line item of an order of shop whose variant is
+// mapped as a sale of its product, by the store's user. Lines already
+// ingested are skipped. A line that would take stock below zero is
+// recorded with its error rather than failing the order, as the sale has
+// happened either way. Any other failure is returned once the lines that
+// could not be ingested are released, so Shopify's retry of the webhook
+// ingests them.
+func (s *Syncer) IngestOrder(ctx context.Context, shop string, order Order) ([]models.ShopifyOrderLine, error) {
+	store, err := s.Store(shop)
+	if err != nil {
+		return nil, err
+	}
+	ctx, user, err := s.account(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+
+	notes := fmt.Sprintf("Shopify order %s (%s)", order.Name, shop)
+	var ingested []models.ShopifyOrderLine
+	for _, item := range order.LineItems {
+		if item.Quantity <= 0 {
+			continue
+		}
+		line := models.ShopifyOrderLine{
+			Shop:       shop,
+			OrderID:    order.ID,
+			LineItemID: item.ID,
+			OrderName:  order.Name,
+			VariantID:  item.VariantID,
+			Quantity:   item.Quantity,
+			CreatedAt:  time.Now(),
+		}
+		if item.VariantID == nil {
+			line.Error = "line item has no variant"
+		} else if productID, err := s.shopifyService.GetShopifyVariantProduct(ctx, shop, *item.VariantID); err == nil {
+			line.ProductID = &productID
+		} else if errors.Is(err, database.ErrNotFound) {
+			line.Error = "variant is not mapped to a product"
+		} else {
+			return nil, err
+		}
+
+		claimed, err := s.shopifyService.ClaimShopifyOrderLine(ctx, &line)
+		if err != nil {
+			return nil, err
+		}
+		if !claimed {
+			continue
+		}
+		if line.ProductID != nil {
+			if err := s.recordSale(ctx, &line, user.ID, notes); err != nil {
+				if releaseErr := s.shopifyService.ReleaseShopifyOrderLine(ctx, &line); releaseErr != nil {
+					log.Printf("Failed to release Shopify order %s line %d: %v", order.Name, line.LineItemID, releaseErr)
+				}
+				return nil, err
+			}
+		}
+		ingested = append(ingested, line)
+	}
+	return ingested, nil
+}
+
+// recordSale moves the stock of a claimed line item, keeping the movement,
+// or the error if the product cannot take it, on the line.
+func (s *Syncer) recordSale(ctx context.Context, line *models.ShopifyOrderLine, userID uuid.UUID, notes string) error {
+	update, err := s.productService.UpdateProductStock(ctx, *line.ProductID, -line.Quantity, models.ReasonSale, userID, notes)
+	switch {
+	case err == nil:
+		line.MovementID = &update.Movement.ID
+	case errors.Is(err, database.ErrInsufficientStock):
+		line.Error = "not enough stock to record the sale"
+	case errors.Is(err, database.ErrNotFound):
+		line.Error = "product not found"
+	default:
+		return err
+	}
+	return s.shopifyService.FinishShopifyOrderLine(ctx, line)
+}
+
+// Run pushes due stock levels every interval until ctx is done. It blocks,
+// so run it in its own goroutine.
+func (s *Syncer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for {
+			pushes, err := s.shopifyService.ClaimShopifyStockPushes(context.Background(), batchSize)
+			if err != nil {
+				log.Printf("Failed to claim Shopify stock pushes: %v", err)
+				break
+			}
+			if len(pushes) > 0 {
+				s.pushAll(pushes)
+			}
+			if len(pushes) < batchSize {
+				break
+			}
+		}
+	}
+}
+
+// pushAll sends a batch of pushes. The stores are read once for the batch,
+// so a store removed from settings fails its pushes.
+func (s *Syncer) pushAll(pushes []models.ShopifyStockPush) {
+	stores, err := s.Stores()
+	if err != nil {
+		log.Printf("Failed to read Shopify stores: %v", err)
+		stores = map[string]Store{}
+	}
+
+	for _, push := range pushes {
+		ctx := context.Background()
+		pushErr := ErrUnknownStore
+		if store, ok := stores[push.Shop]; ok {
+			pushErr = s.client.SetInventoryLevel(ctx, store, push.InventoryItemID, push.Available)
+		}
+
+		var message string
+		var next *time.Time
+		if pushErr != nil {
+			message = pushErr.Error()
+			if next = retryAt(pushErr, push.Attempts+1, time.Now()); next == nil {
+				log.Printf("Shopify stock push of inventory item %d to %s failed after %d attempts: %v",
+					push.InventoryItemID, push.Shop, push.Attempts+1, pushErr)
+				if s.Alerts != nil {
+					go s.Alerts.Fire(alerting.SyncFailed("shopify/"+push.Shop, pushErr))
+				}
+			}
+		}
+		if err := s.shopifyService.RecordShopifyStockPush(ctx, push, message, next); err != nil {
+			log.Printf("Failed to record Shopify stock push of inventory item %d to %s: %v", push.InventoryItemID, push.Shop, err)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// Sweep deletes the objects under prefix last modified before cutoff that
+// keep does not claim, and returns how many it deleted. The cutoff spares
+// objects uploaded moments ago whose record is not written yet. Sweep
+// carries on past objects it fails to delete and returns the first error.
+func Sweep(ctx context.Context, store Store, prefix string, cutoff time.Time, keep func(key string) bool) (int, error) {
+	objects, err := store.List(ctx, prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	var firstErr error
+	for _, object := range objects {
+		if !object.ModTime.Before(cutoff) || keep(object.Key) {
+			continue
+		}
+		if err := store.Delete(ctx, object.Key); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		deleted++
+	}
+	return deleted, firstErr
+}
@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// partialSuffix marks a file Put is still writing.
+const partialSuffix = ".partial"
+
+// ErrInvalidSignature is returned by Verify for a URL that was not signed
+// by the store, or has expired.
+var ErrInvalidSignature = errors.New("invalid or expired signed URL")
+
+// Local keeps objects as files under a directory, which may be a mounted
+// volume or network share. Its signed URLs point at the API, which checks
+// the signature with Verify and serves the file.
+type Local struct {
+	Dir string
+	// URL is where the API serves the files, e.g.
+	// https://rtims.example.com/api/v1/files.
+	URL    string
+	secret []byte
+}
+
+// NewLocal creates dir if needed. secret signs the URLs.
+func NewLocal(dir, baseURL string, secret []byte) (*Local, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	return &Local{Dir: dir, URL: baseURL, secret: secret}, nil
+}
+
+func (s *Local) Name() string {
+	return "local"
+}
+
+// Put writes the object under a temporary name and renames it once
+// complete, so a reader never sees a partial one.
+func (s *Local) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	partial := path + partialSuffix
+
+	file, err := os.OpenFile(partial, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	written, err := io.Copy(file, r)
+	if err == nil && written != size {
+		err = fmt.Errorf("wrote %d of %d bytes", written, size)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(partial)
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	if err := os.Rename(partial, path); err != nil {
+		os.Remove(partial)
+		return fmt.Errorf("failed to store file: %w", err)
+	}
+	return nil
+}
+
+func (s *Local) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	return file, nil
+}
+
+// Delete removes the file, and the directories above it that it leaves
+// empty.
+func (s *Local) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	root := filepath.Clean(s.Dir)
+	for dir := filepath.Dir(path); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		// Fails, and stops, at the first directory that is not empty
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+func (s *Local) List(ctx context.Context, prefix string) ([]Object, error) {
+	// Walk only the directory the prefix is in
+	dir := s.Dir
+	if i := strings.LastIndex(prefix, "/"); i > 0 {
+		dir = filepath.Join(s.Dir, filepath.FromSlash(prefix[:i]))
+	}
+
+	objects := []Object{}
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() || strings.HasSuffix(path, partialSuffix) {
+			return nil
+		}
+		relative, err := filepath.Rel(s.Dir, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(relative)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, Object{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+	return objects, nil
+}
+
+// SignedURL points at the file under URL, with the expiry and an HMAC of
+// the key, filename and expiry in the query.
+func (s *Local) SignedURL(ctx context.Context, key, filename string, expires time.Duration) (string, error) {
+	if err := ValidateKey(key); err != nil {
+		return "", err
+	}
+	expiry := strconv.FormatInt(time.Now().Add(expires).Unix(), 10)
+	query := url.Values{"expires": {expiry}, "signature": {s.sign(key, filename, expiry)}}
+	if filename != "" {
+		query.Set("filename", filename)
+	}
+	return s.URL + "/" + (&url.URL{Path: key}).EscapedPath() + "?" + query.Encode(), nil
+}
+
+// Verify checks the query of a URL SignedURL returned for key.
+func (s *Local) Verify(key string, query url.Values, now time.Time) error {
+	expiry := query.Get("expires")
+	seconds, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || now.Unix() > seconds {
+		return ErrInvalidSignature
+	}
+	expected := s.sign(key, query.Get("filename"), expiry)
+	if !hmac.Equal([]byte(expected), []byte(query.Get("signature"))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (s *Local) sign(key, filename, expiry string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(key + "\n" + filename + "\n" + expiry))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// path returns the file of key, refusing keys that would leave Dir.
+func (s *Local) path(key string) (string, error) {
+	if err := ValidateKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(key)), nil
+}
@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3 keeps objects in a bucket of S3 or a service with its API, through
+// the MinIO client, which signs requests with AWS Signature Version 4,
+// retries failed ones and uploads large objects in parts. Requests are
+// path-style. Keys are relative to Prefix.
+type S3 struct {
+	// Driver is the name of the service: "s3", "gcs" or "minio".
+	Driver string
+	// Endpoint is the service URL, e.g. https://s3.eu-west-1.amazonaws.com.
+	Endpoint        string
+	Region          string
+	Bucket          string
+	Prefix          string
+	AccessKeyID     string
+	SecretAccessKey string
+
+	// Transport sends the requests; nil shares one transport between all
+	// buckets.
+	Transport http.RoundTripper
+}
+
+var (
+	sharedTransportOnce sync.Once
+	sharedTransport     http.RoundTripper
+)
+
+func (s *S3) Name() string {
+	if s.Driver == "" {
+		return "s3"
+	}
+	return s.Driver
+}
+
+// Put uploads the object, in parts when it is larger than the client's
+// part size.
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	client, err := s.client()
+	if err != nil {
+		return err
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if _, err := client.PutObject(ctx, s.Bucket, s.prefix()+key, r, size, minio.PutObjectOptions{ContentType: contentType}); err != nil {
+		return fmt.Errorf("failed to upload to %s: %w", s.Name(), err)
+	}
+	return nil
+}
+
+func (s *S3) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := ValidateKey(key); err != nil {
+		return nil, err
+	}
+	client, err := s.client()
+	if err != nil {
+		return nil, err
+	}
+	// Core's GetObject sends the request right away, so a missing object
+	// is reported here rather than on the first read
+	object, _, _, err := (&minio.Core{Client: client}).GetObject(ctx, s.Bucket, s.prefix()+key, minio.GetObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to download from %s: %w", s.Name(), err)
+	}
+	return object, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	client, err := s.client()
+	if err != nil {
+		return err
+	}
+	// S3 answers the same whether or not the object existed
+	if err := client.RemoveObject(ctx, s.Bucket, s.prefix()+key, minio.RemoveObjectOptions{}); err != nil &&
+		minio.ToErrorResponse(err).Code != "NoSuchKey" {
+		return fmt.Errorf("failed to delete from %s: %w", s.Name(), err)
+	}
+	return nil
+}
+
+func (s *S3) List(ctx context.Context, prefix string) ([]Object, error) {
+	client, err := s.client()
+	if err != nil {
+		return nil, err
+	}
+	root := s.prefix()
+
+	objects := []Object{}
+	for object := range client.ListObjects(ctx, s.Bucket, minio.ListObjectsOptions{Prefix: root + prefix, Recursive: true}) {
+		if object.Err != nil {
+			return nil, fmt.Errorf("failed to list objects in %s: %w", s.Name(), object.Err)
+		}
+		// Folders created in consoles are empty objects ending in a slash
+		if strings.HasSuffix(object.Key, "/") {
+			continue
+		}
+		objects = append(objects, Object{
+			Key:     strings.TrimPrefix(object.Key, root),
+			Size:    object.Size,
+			ModTime: object.LastModified,
+		})
+	}
+	return objects, nil
+}
+
+// SignedURL presigns a GET of the object, asking the service to send it as
+// an attachment named filename when it is set.
+func (s *S3) SignedURL(ctx context.Context, key, filename string, expires time.Duration) (string, error) {
+	if err := ValidateKey(key); err != nil {
+		return "", err
+	}
+	client, err := s.client()
+	if err != nil {
+		return "", err
+	}
+	params := url.Values{}
+	if filename != "" {
+		params.Set("response-content-disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	}
+	u, err := client.PresignedGetObject(ctx, s.Bucket, s.prefix()+key, expires, params)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign %s URL: %w", s.Name(), err)
+	}
+	return u.String(), nil
+}
+
+// prefix is Prefix as the start of an object key: empty, or ending in a
+// slash.
+func (s *S3) prefix() string {
+	if prefix := strings.Trim(s.Prefix, "/"); prefix != "" {
+		return prefix + "/"
+	}
+	return ""
+}
+
+// client returns a MinIO client for the endpoint. Clients hold no
+// connections of their own, so one is made for each call, which keeps S3
+// a plain value that can be copied.
+func (s *S3) client() (*minio.Client, error) {
+	endpoint, err := url.Parse(s.Endpoint)
+	if err != nil || (endpoint.Scheme != "https" && endpoint.Scheme != "http") || endpoint.Host == "" {
+		return nil, errors.New("invalid " + s.Name() + " endpoint: must be an http or https URL")
+	}
+	transport := s.Transport
+	if transport == nil {
+		sharedTransportOnce.Do(func() {
+			sharedTransport, _ = minio.DefaultTransport(true)
+		})
+		transport = sharedTransport
+	}
+	client, err := minio.New(endpoint.Host, &minio.Options{
+		Creds:        credentials.NewStaticV4(s.AccessKeyID, s.SecretAccessKey, ""),
+		Secure:       endpoint.Scheme == "https",
+		Transport:    transport,
+		Region:       s.Region,
+		BucketLookup: minio.BucketLookupPath,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s client: %w", s.Name(), err)
+	}
+	return client, nil
+}
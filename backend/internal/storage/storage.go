@@ -0,0 +1,125 @@
+// Package storage keeps the files the system holds on to, such as product
+// images, stock movement attachments, report artifacts and audit log
+// archives, in an object store: a local directory, or a bucket on Amazon
+// S3, Google Cloud Storage or MinIO, chosen with STORAGE_DRIVER. Objects
+// are addressed by keys like "products/<id>/image.png" and handed to
+// clients as short-lived signed URLs, so downloads need no credentials of
+// their own.
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+
+	"rtims-backend/config"
+)
+
+// maxKeyLength is the longest key accepted, within the 1024 bytes S3
+// allows once a prefix is added.
+const maxKeyLength = 512
+
+// Prefixes of the keys each part of the system keeps its objects under.
+const (
+	PrefixProducts      = "products/"
+	PrefixAttachments   = "attachments/"
+	PrefixReports       = "reports/"
+	PrefixBackups       = "backups/"
+	PrefixAuditArchives = "audit-archives/"
+)
+
+// ErrNotFound is returned when there is no object under a key.
+var ErrNotFound = errors.New("object not found")
+
+// Object is an object in a store.
+type Object struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Store keeps objects under keys.
+type Store interface {
+	// Name identifies the driver, e.g. "local" or "gcs".
+	Name() string
+	// Put stores the size bytes of r under key, replacing any object
+	// already there.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open reads back the object under key. It returns ErrNotFound when
+	// there is none.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the object under key. An object that is already gone
+	// is not an error.
+	Delete(ctx context.Context, key string) error
+	// List returns the objects whose keys start with prefix, at any depth,
+	// in the order of their keys.
+	List(ctx context.Context, prefix string) ([]Object, error)
+	// SignedURL returns a URL that downloads the object under key, as
+	// filename when it is set, until expires has passed.
+	SignedURL(ctx context.Context, key, filename string, expires time.Duration) (string, error)
+}
+
+// New returns the store STORAGE_DRIVER selects. Local stores sign their
+// URLs with a key derived from JWT_SECRET and serve them under
+// PUBLIC_URL/api/v1/files.
+func New(cfg *config.Config) (Store, error) {
+	switch cfg.StorageDriver {
+	case "s3", "gcs", "minio":
+		s := &S3{
+			Driver:          cfg.StorageDriver,
+			Endpoint:        cfg.StorageEndpoint,
+			Region:          cfg.StorageRegion,
+			Bucket:          cfg.StorageBucket,
+			Prefix:          cfg.StoragePrefix,
+			AccessKeyID:     cfg.StorageAccessKeyID,
+			SecretAccessKey: cfg.StorageSecretAccessKey,
+		}
+		if s.Driver == "gcs" {
+			// Cloud Storage's XML API takes Signature Version 4 with HMAC
+			// keys, and ignores the region
+			if s.Endpoint == "" {
+				s.Endpoint = "https://storage.googleapis.com"
+			}
+			if s.Region == "" {
+				s.Region = "auto"
+			}
+		}
+		// MinIO signs with a region too, by default this one
+		if s.Region == "" {
+			s.Region = "us-east-1"
+		}
+		if s.Endpoint == "" {
+			s.Endpoint = "https://s3." + s.Region + ".amazonaws.com"
+		}
+		return s, nil
+	default:
+		secret := hmac.New(sha256.New, []byte(cfg.JWTSecret))
+		secret.Write([]byte("storage signed URLs"))
+		return NewLocal(cfg.StorageDir, strings.TrimRight(cfg.PublicURL, "/")+"/api/v1/files", secret.Sum(nil))
+	}
+}
+
+// ValidateKey checks that key is a relative, slash-separated path without
+// empty, "." or ".." segments, so it means the same object in every store.
+func ValidateKey(key string) error {
+	if key == "" || len(key) > maxKeyLength {
+		return fmt.Errorf("object key must be 1 to %d bytes", maxKeyLength)
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("invalid object key %q", key)
+		}
+	}
+	for _, r := range key {
+		if r == '\\' || !unicode.IsPrint(r) {
+			return fmt.Errorf("invalid object key %q", key)
+		}
+	}
+	return nil
+}
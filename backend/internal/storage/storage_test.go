@@ -0,0 +1,231 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateKey(t *testing.T) {
+	for _, key := range []string{"products/1/image.png", "reports/a b.pdf"} {
+		if err := ValidateKey(key); err != nil {
+			t.Errorf("expected %q to be valid, got %v", key, err)
+		}
+	}
+	for _, key := range []string{"", "/etc/passwd", "products/../../secret", "products//image.png", `a\b`, "a/\n", "products/"} {
+		if err := ValidateKey(key); err == nil {
+			t.Errorf("expected %q to be refused", key)
+		}
+	}
+}
+
+func TestLocal(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s, err := NewLocal(dir, "https://rtims.example.com/api/v1/files", []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, content := range map[string]string{"products/1/image.png": "png", "products/2/image.jpg": "jpeg", "reports/a.csv": "csv"} {
+		if err := s.Put(ctx, key, strings.NewReader(content), int64(len(content)), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Put(ctx, "reports/b.csv", strings.NewReader("short"), 10, ""); err == nil {
+		t.Error("expected a short body to fail")
+	}
+
+	objects, err := s.List(ctx, "products/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 2 || objects[0].Key != "products/1/image.png" || objects[1].Key != "products/2/image.jpg" || objects[0].Size != 3 {
+		t.Errorf("unexpected objects %+v", objects)
+	}
+	if objects, err := s.List(ctx, "attachments/"); err != nil || len(objects) != 0 {
+		t.Errorf("expected no attachments, got %+v, %v", objects, err)
+	}
+
+	r, err := s.Open(ctx, "products/2/image.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "jpeg" {
+		t.Errorf("unexpected content %q", data)
+	}
+
+	if err := s.Delete(ctx, "products/2/image.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Open(ctx, "products/2/image.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "products", "2")); !os.IsNotExist(err) {
+		t.Errorf("expected the emptied directory to be removed, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "products", "1")); err != nil {
+		t.Errorf("expected the other product's directory to stay, got %v", err)
+	}
+	if err := s.Delete(ctx, "products/2/image.jpg"); err != nil {
+		t.Errorf("expected deleting a missing object to succeed, got %v", err)
+	}
+	if _, err := s.Open(ctx, "../outside"); err == nil {
+		t.Error("expected a key outside the directory to be refused")
+	}
+}
+
+func TestLocalSignedURL(t *testing.T) {
+	s := &Local{Dir: t.TempDir(), URL: "https://rtims.example.com/api/v1/files", secret: []byte("secret")}
+	signed, err := s.SignedURL(context.Background(), "attachments/1/delivery note.pdf", "delivery note.pdf", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(signed)
+	if u.Path != "/api/v1/files/attachments/1/delivery note.pdf" {
+		t.Errorf("unexpected path %s", u.Path)
+	}
+
+	key := "attachments/1/delivery note.pdf"
+	if err := s.Verify(key, u.Query(), time.Now()); err != nil {
+		t.Errorf("expected the URL to verify, got %v", err)
+	}
+	if err := s.Verify("attachments/2/delivery note.pdf", u.Query(), time.Now()); err != ErrInvalidSignature {
+		t.Errorf("expected another key to be refused, got %v", err)
+	}
+	tampered := u.Query()
+	tampered.Set("filename", "invoice.pdf")
+	if err := s.Verify(key, tampered, time.Now()); err != ErrInvalidSignature {
+		t.Errorf("expected a changed filename to be refused, got %v", err)
+	}
+	if err := s.Verify(key, u.Query(), time.Now().Add(2*time.Minute)); err != ErrInvalidSignature {
+		t.Errorf("expected an expired URL to be refused, got %v", err)
+	}
+}
+
+func TestS3(t *testing.T) {
+	var requests []string
+	objects := map[string]string{}
+	// Over plain HTTP MinIO signs the body in chunks; over TLS it is sent
+	// as is
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.EscapedPath())
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch {
+		case r.Method == http.MethodPut:
+			data, _ := io.ReadAll(r.Body)
+			objects[r.URL.Path] = string(data)
+		case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+			if r.URL.Query().Get("prefix") != "rtims/products/" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			io.WriteString(w, `<ListBucketResult><Contents><Key>rtims/products/</Key></Contents>`+
+				`<Contents><Key>rtims/products/1/image.png</Key><Size>3</Size><LastModified>2026-01-02T03:04:05.000Z</LastModified></Contents>`+
+				`<IsTruncated>false</IsTruncated></ListBucketResult>`)
+		case r.Method == http.MethodGet:
+			content, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Last-Modified", "Fri, 02 Jan 2026 03:04:05 GMT")
+			io.WriteString(w, content)
+		case r.Method == http.MethodDelete:
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	s := &S3{Driver: "minio", Endpoint: server.URL, Region: "us-east-1", Bucket: "files", Prefix: "/rtims/",
+		AccessKeyID: "key", SecretAccessKey: "secret", Transport: server.Client().Transport}
+
+	if err := s.Put(ctx, "products/1/image.png", strings.NewReader("png"), 3, "image/png"); err != nil {
+		t.Fatal(err)
+	}
+	r, err := s.Open(ctx, "products/1/image.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "png" {
+		t.Errorf("unexpected content %q", data)
+	}
+
+	listed, err := s.List(ctx, "products/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 1 || listed[0].Key != "products/1/image.png" || listed[0].Size != 3 || listed[0].ModTime.Year() != 2026 {
+		t.Errorf("unexpected objects %+v", listed)
+	}
+
+	if err := s.Delete(ctx, "products/1/image.png"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Open(ctx, "products/1/image.png"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if requests[0] != "PUT /files/rtims/products/1/image.png" {
+		t.Errorf("unexpected requests %v", requests)
+	}
+
+	signed, err := s.SignedURL(ctx, "products/1/image.png", "image.png", 15*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(signed)
+	query := u.Query()
+	if u.Path != "/files/rtims/products/1/image.png" || query.Get("X-Amz-Expires") != "900" || query.Get("X-Amz-Signature") == "" ||
+		query.Get("response-content-disposition") != `attachment; filename=image.png` {
+		t.Errorf("unexpected signed URL %s", signed)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s, err := NewLocal(dir, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"products/1/image.png", "products/2/image.png", "products/3/image.png"} {
+		if err := s.Put(ctx, key, strings.NewReader("png"), 3, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	for _, key := range []string{"products/1/image.png", "products/2/image.png"} {
+		os.Chtimes(filepath.Join(dir, filepath.FromSlash(key)), old, old)
+	}
+
+	deleted, err := Sweep(ctx, s, "products/", time.Now().Add(-24*time.Hour), func(key string) bool {
+		return key == "products/1/image.png"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 1 {
+		t.Errorf("expected 1 object to be deleted, got %d", deleted)
+	}
+	objects, _ := s.List(ctx, "products/")
+	if len(objects) != 2 || objects[0].Key != "products/1/image.png" || objects[1].Key != "products/3/image.png" {
+		t.Errorf("expected the kept and the recent image to stay, got %+v", objects)
+	}
+}
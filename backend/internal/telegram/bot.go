@@ -0,0 +1,359 @@
+package telegram
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/i18n"
+	"rtims-backend/internal/models"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// WebhookPath is where Telegram sends the bot's updates.
+const WebhookPath = "/api/v1/integrations/telegram/webhook"
+
+const (
+	// linkCodeTTL is how long a link code can be used.
+	linkCodeTTL = 10 * time.Minute
+	// linkCodeAlphabet leaves out letters and digits that read alike.
+	linkCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	linkCodeLength   = 8
+	linkCodePrefix   = "telegram_link:"
+
+	// maxLowStock is the most products /low lists.
+	maxLowStock = 20
+)
+
+// ErrLinkUnavailable is returned when a link code cannot be stored because
+// Redis is down.
+var ErrLinkUnavailable = errors.New("Telegram chat linking is temporarily unavailable")
+
+// commands are the commands Telegram suggests, with their descriptions.
+var commands = [][2]string{
+	{"stock", "Stock of a product by SKU"},
+	{"low", "Products low on stock"},
+	{"unlink", "Unlink this chat and stop its alerts"},
+	{"help", "What this bot can do"},
+}
+
+// Bot answers the commands sent to the bot configured in settings and
+// sends linked chats the critical alerts of their users.
+type Bot struct {
+	telegramService     *database.TelegramService
+	settingsService     *database.SettingsService
+	userService         *database.UserService
+	organizationService *database.OrganizationService
+	productService      *database.ProductService
+	redis               *redis.Client
+	client              *Client
+	publicURL           string
+}
+
+func NewBot(db *sql.DB, cache *database.Cache, redisClient *redis.Client, publicURL string) *Bot {
+	return &Bot{
+		telegramService:     database.NewTelegramService(db),
+		settingsService:     database.NewSettingsService(db),
+		userService:         database.NewUserService(db),
+		organizationService: database.NewOrganizationService(db),
+		productService:      database.NewCachedProductService(db, cache),
+		redis:               redisClient,
+		client:              NewClient(),
+		publicURL:           strings.TrimSuffix(publicURL, "/"),
+	}
+}
+
+// config returns the bot token and webhook secret, or ErrNotConfigured if
+// either is not set.
+func (b *Bot) config() (string, string, error) {
+	settings, err := b.settingsService.GetSettings()
+	if err != nil {
+		return "", "", err
+	}
+	token, _ := settings[SettingBotToken].(string)
+	secret, _ := settings[SettingWebhookSecret].(string)
+	if token == "" || secret == "" {
+		return "", "", ErrNotConfigured
+	}
+	return token, secret, nil
+}
+
+// Verify reports whether secret is the webhook secret of a configured bot.
+func (b *Bot) Verify(secret string) bool {
+	_, want, err := b.config()
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(secret), []byte(want)) == 1
+}
+
+// RegisterWebhook has Telegram send the bot's messages to this server and
+// suggest its commands. It returns the webhook URL.
+func (b *Bot) RegisterWebhook(ctx context.Context) (string, error) {
+	token, secret, err := b.config()
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(b.publicURL, "https://") {
+		return "", fmt.Errorf("%w: Telegram only sends updates to an https PUBLIC_URL", ErrNotConfigured)
+	}
+
+	webhookURL := b.publicURL + WebhookPath
+	if err := b.client.SetWebhook(ctx, token, webhookURL, secret); err != nil {
+		return "", err
+	}
+	if err := b.client.SetCommands(ctx, token, commands); err != nil {
+		return "", err
+	}
+	return webhookURL, nil
+}
+
+// newLinkCode returns a random code of linkCodeLength characters.
+func newLinkCode() (string, error) {
+	code := make([]byte, linkCodeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(linkCodeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		code[i] = linkCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// LinkCode generates a code that links the chat it is sent in to the
+// user. Codes live only in Redis and can be used once.
+func (b *Bot) LinkCode(ctx context.Context, userID uuid.UUID) (*models.TelegramLinkCode, error) {
+	code, err := newLinkCode()
+	if err != nil {
+		return nil, err
+	}
+	if err := b.redis.Set(ctx, linkCodePrefix+code, userID.String(), linkCodeTTL).Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrLinkUnavailable, err)
+	}
+	return &models.TelegramLinkCode{Code: code, ExpiresAt: time.Now().Add(linkCodeTTL)}, nil
+}
+
+// Chats returns the chats linked to a user.
+func (b *Bot) Chats(ctx context.Context, userID uuid.UUID) ([]models.TelegramChat, error) {
+	return b.telegramService.GetTelegramChats(ctx, userID)
+}
+
+// Unlink unlinks one of a user's chats.
+func (b *Bot) Unlink(ctx context.Context, chatID int64, userID uuid.UUID) error {
+	return b.telegramService.UnlinkTelegramChat(ctx, chatID, userID)
+}
+
+// Handle answers a message sent to the bot. It returns nil for updates
+// that need no answer.
+func (b *Bot) Handle(ctx context.Context, update Update) (*Reply, error) {
+	message := update.Message
+	if message == nil || message.Text == "" {
+		return nil, nil
+	}
+	language := ""
+	if message.From != nil {
+		language = message.From.LanguageCode
+	}
+	reply := func(text string) (*Reply, error) {
+		return &Reply{Method: "sendMessage", ChatID: message.Chat.ID, Text: text}, nil
+	}
+
+	command, argument := Command(message.Text)
+	switch command {
+	case "":
+		// Chatter in groups is not for the bot
+		if message.Chat.Type != "private" {
+			return nil, nil
+		}
+	case "/start", "/link":
+		if argument != "" {
+			return b.link(ctx, message.Chat, argument, language)
+		}
+	}
+
+	chat, err := b.telegramService.GetTelegramChat(ctx, message.Chat.ID)
+	if errors.Is(err, database.ErrNotFound) {
+		return reply(i18n.T(language, "This chat is not linked to an RTIMS account. Generate a link code in your profile and send /link followed by the code."))
+	}
+	if err != nil {
+		return nil, err
+	}
+	ctx, user, err := b.account(ctx, chat.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return reply(i18n.T(language, "The RTIMS account this chat is linked to is deactivated."))
+	}
+
+	switch command {
+	case "/stock":
+		return reply(b.stock(ctx, user.Locale, argument))
+	case "/low":
+		return reply(b.lowStock(ctx, user.Locale))
+	case "/unlink":
+		if err := b.telegramService.UnlinkTelegramChat(ctx, chat.ChatID, chat.UserID); err != nil {
+			return nil, err
+		}
+		return reply(i18n.T(user.Locale, "This chat is unlinked and will no longer receive alerts."))
+	}
+	return reply(i18n.T(user.Locale, "Send /stock followed by a SKU for its stock, /low for the products low on stock, or /unlink to unlink this chat. Critical alerts for your account are sent here."))
+}
+
+// account returns the user a chat is linked to, and ctx scoped to the
+// user's organization. It returns no user when the user or organization
+// is deactivated.
+func (b *Bot) account(ctx context.Context, userID uuid.UUID) (context.Context, *models.User, error) {
+	user, err := b.userService.GetUser(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !user.IsActive {
+		return ctx, nil, nil
+	}
+
+	organizationID := user.OrganizationID
+	if organizationID == uuid.Nil {
+		organizationID = database.DefaultOrganizationID
+	}
+	organization, err := b.organizationService.GetOrganization(ctx, organizationID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !organization.IsActive {
+		return ctx, nil, nil
+	}
+	return database.WithOrganization(ctx, organizationID), user, nil
+}
+
+// link links a chat to the user who generated code.
+func (b *Bot) link(ctx context.Context, chat Chat, code, language string) (*Reply, error) {
+	reply := func(text string) (*Reply, error) {
+		return &Reply{Method: "sendMessage", ChatID: chat.ID, Text: text}, nil
+	}
+
+	key := linkCodePrefix + strings.ToUpper(code)
+	value, err := b.redis.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return reply(i18n.T(language, "This code is invalid or has expired. Generate a new one in your profile."))
+	}
+	if err != nil {
+		return nil, err
+	}
+	b.redis.Del(ctx, key)
+
+	userID, err := uuid.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	_, user, err := b.account(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return reply(i18n.T(language, "The RTIMS account this chat is linked to is deactivated."))
+	}
+
+	if err := b.telegramService.LinkTelegramChat(ctx, &models.TelegramChat{ChatID: chat.ID, UserID: user.ID, Title: chat.Name()}); err != nil {
+		return nil, err
+	}
+	return reply(fmt.Sprintf(i18n.T(user.Locale, "This chat is now linked to %s. Send /help to see what I can do."), user.Email))
+}
+
+// stock describes the stock of the product with sku.
+func (b *Bot) stock(ctx context.Context, language, sku string) string {
+	if sku == "" {
+		return i18n.T(language, "Send a SKU after /stock, such as /stock SKU123.")
+	}
+	products, err := b.productService.GetProductsBySKU(ctx, []string{sku})
+	if err != nil {
+		log.Printf("Failed to get product %s for Telegram: %v", sku, err)
+		return i18n.T(language, "Something went wrong, please try again later.")
+	}
+	product, ok := products[sku]
+	if !ok {
+		return fmt.Sprintf(i18n.T(language, "No product has SKU %s."), sku)
+	}
+
+	text := fmt.Sprintf(i18n.T(language, "%s (%s)\nStock: %d\nMinimum: %d\nPrice: %.2f"),
+		product.Name, product.SKU, product.Stock, product.MinimumThreshold, product.Price)
+	if product.Stock <= product.MinimumThreshold {
+		text += "\n" + i18n.T(language, "Low on stock")
+	}
+	return text
+}
+
+// lowStock lists the products at or below their minimum, lowest first.
+func (b *Bot) lowStock(ctx context.Context, language string) string {
+	products, total, err := b.productService.GetProducts(ctx, models.ProductFilter{
+		LowStockOnly: true, Page: 1, Limit: maxLowStock, SortBy: "stock", SortOrder: "asc",
+	})
+	if err != nil {
+		log.Printf("Failed to get low stock products for Telegram: %v", err)
+		return i18n.T(language, "Something went wrong, please try again later.")
+	}
+	if len(products) == 0 {
+		return i18n.T(language, "No products are low on stock.")
+	}
+
+	lines := []string{fmt.Sprintf(i18n.T(language, "%d products are low on stock:"), total)}
+	for _, product := range products {
+		lines = append(lines, fmt.Sprintf("• %s %s: %d / %d", product.SKU, product.Name, product.Stock, product.MinimumThreshold))
+	}
+	if total > len(products) {
+		lines = append(lines, fmt.Sprintf(i18n.T(language, "…and %d more"), total-len(products)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Alert sends a critical notification to the chats linked to its
+// recipient. Chats that blocked the bot or removed it are unlinked.
+func (b *Bot) Alert(notification *models.Notification) {
+	token, _, err := b.config()
+	if errors.Is(err, ErrNotConfigured) {
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to load Telegram settings: %v", err)
+		return
+	}
+
+	ctx := context.Background()
+	chats, err := b.telegramService.GetTelegramChats(ctx, notification.UserID)
+	if err != nil {
+		log.Printf("Failed to get Telegram chats for notification %s: %v", notification.ID, err)
+		return
+	}
+	if len(chats) == 0 {
+		return
+	}
+	locale, _ := b.userService.GetLocale(ctx, notification.UserID)
+	text := fmt.Sprintf(i18n.T(locale, "Critical alert: %s"), notification.Message)
+
+	for _, chat := range chats {
+		err := b.client.SendMessage(ctx, token, chat.ChatID, text)
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.Status == http.StatusForbidden {
+			log.Printf("Unlinking Telegram chat %d, which no longer accepts messages: %v", chat.ChatID, err)
+			if err := b.telegramService.UnlinkTelegramChat(ctx, chat.ChatID, chat.UserID); err != nil {
+				log.Printf("Failed to unlink Telegram chat %d: %v", chat.ChatID, err)
+			}
+			continue
+		}
+		if err != nil {
+			log.Printf("Failed to deliver notification %s to Telegram chat %d: %v", notification.ID, chat.ChatID, err)
+		}
+	}
+}
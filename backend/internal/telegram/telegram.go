@@ -0,0 +1,213 @@
+// Package telegram is a Telegram bot that sends users their critical
+// alerts and answers quick stock queries. Users link a chat to themselves
+// by sending the bot a short-lived code generated in their profile; the
+// bot then answers commands in that chat as them, within their
+// organization. Updates arrive by webhook, authenticated by the secret
+// Telegram echoes in every request.
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Settings keys that configure the bot.
+const (
+	// SettingBotToken is the token BotFather gave the bot.
+	SettingBotToken = "telegram_bot_token"
+	// SettingWebhookSecret is the secret Telegram sends with every update,
+	// set when the webhook is registered.
+	SettingWebhookSecret = "telegram_webhook_secret"
+)
+
+// SecretHeader carries the webhook secret in the updates Telegram sends.
+const SecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// maxResponseBody is the largest response read, and maxErrorBody how much
+// of an error response is kept in its error.
+const (
+	maxResponseBody = 1 << 20
+	maxErrorBody    = 512
+)
+
+// ErrNotConfigured is returned when the bot token or webhook secret is
+// not set.
+var ErrNotConfigured = errors.New("Telegram bot is not configured")
+
+var (
+	botToken      = regexp.MustCompile(`^[0-9]+:[A-Za-z0-9_-]{30,}$`)
+	webhookSecret = regexp.MustCompile(`^[A-Za-z0-9_-]{16,256}$`)
+)
+
+// ValidateToken checks the format of a bot token.
+func ValidateToken(value string) error {
+	if !botToken.MatchString(value) {
+		return errors.New("must be a bot token such as 123456:ABC-DEF1234ghIkl-zyx57W2v1u123ew11")
+	}
+	return nil
+}
+
+// ValidateSecret checks a webhook secret is one Telegram accepts and long
+// enough not to be guessed.
+func ValidateSecret(value string) error {
+	if !webhookSecret.MatchString(value) {
+		return errors.New("must be 16 to 256 letters, digits, hyphens and underscores")
+	}
+	return nil
+}
+
+// Update is an update Telegram sends the bot. Only messages are handled.
+type Update struct {
+	UpdateID int64    `json:"update_id"`
+	Message  *Message `json:"message"`
+}
+
+// Message is a message sent to the bot.
+type Message struct {
+	MessageID int64  `json:"message_id"`
+	From      *User  `json:"from"`
+	Chat      Chat   `json:"chat"`
+	Text      string `json:"text"`
+}
+
+// User is the Telegram user who sent a message.
+type User struct {
+	ID           int64  `json:"id"`
+	FirstName    string `json:"first_name"`
+	LastName     string `json:"last_name"`
+	LanguageCode string `json:"language_code"`
+}
+
+// Chat is the chat a message was sent in.
+type Chat struct {
+	ID        int64  `json:"id"`
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+}
+
+// Name is the chat's title, or the name of the person in a private chat.
+func (c Chat) Name() string {
+	if c.Title != "" {
+		return c.Title
+	}
+	return strings.TrimSpace(c.FirstName + " " + c.LastName)
+}
+
+// Reply is a message the bot sends back in answer to an update, written
+// as the webhook's response so it needs no request of its own.
+type Reply struct {
+	Method string `json:"method"`
+	ChatID int64  `json:"chat_id"`
+	Text   string `json:"text"`
+}
+
+// Command splits a message such as "/stock@rtims_bot SKU123" into the
+// command, without the bot's name, and its argument. It returns "" for
+// messages that are not commands.
+func Command(text string) (string, string) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "/") {
+		return "", ""
+	}
+	command, argument, _ := strings.Cut(text, " ")
+	command, _, _ = strings.Cut(command, "@")
+	return strings.ToLower(command), strings.TrimSpace(argument)
+}
+
+// APIError is a response of the Bot API that is not ok.
+type APIError struct {
+	Status      int
+	Description string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Telegram returned status %d: %s", e.Status, e.Description)
+}
+
+// Client calls the Bot API.
+type Client struct {
+	httpClient *http.Client
+	// baseURL is the API root, which tests point at a fake.
+	baseURL string
+}
+
+func NewClient() *Client {
+	return &Client{
+		httpClient: &http.Client{Timeout: 15 * time.Second},
+		baseURL:    "https://api.telegram.org",
+	}
+}
+
+// SendMessage sends text to a chat.
+func (c *Client) SendMessage(ctx context.Context, token string, chatID int64, text string) error {
+	return c.call(ctx, token, "sendMessage", map[string]interface{}{"chat_id": chatID, "text": text})
+}
+
+// SetWebhook has Telegram send the bot's messages to webhookURL with secret.
+func (c *Client) SetWebhook(ctx context.Context, token, webhookURL, secret string) error {
+	return c.call(ctx, token, "setWebhook", map[string]interface{}{
+		"url":             webhookURL,
+		"secret_token":    secret,
+		"allowed_updates": []string{"message"},
+	})
+}
+
+// SetCommands sets the commands Telegram suggests in the bot's chats, by
+// command and description.
+func (c *Client) SetCommands(ctx context.Context, token string, commands [][2]string) error {
+	list := make([]map[string]string, 0, len(commands))
+	for _, command := range commands {
+		list = append(list, map[string]string{"command": command[0], "description": command[1]})
+	}
+	return c.call(ctx, token, "setMyCommands", map[string]interface{}{"commands": list})
+}
+
+func (c *Client) call(ctx context.Context, token, method string, params interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/bot"+token+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		// The URL holds the token, so it is left out of the error
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to call Telegram %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	if err := json.Unmarshal(data, &result); err != nil || !result.OK {
+		if result.Description == "" {
+			result.Description = string(data)
+		}
+		if len(result.Description) > maxErrorBody {
+			result.Description = result.Description[:maxErrorBody]
+		}
+		return &APIError{Status: resp.StatusCode, Description: result.Description}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testToken = "123456:ABC-DEF1234ghIkl-zyx57W2v1u123ew11"
+
+func TestCommand(t *testing.T) {
+	tests := []struct {
+		text, command, argument string
+	}{
+		{"/stock SKU123", "/stock", "SKU123"},
+		{"  /Stock@rtims_bot   SKU123 ", "/stock", "SKU123"},
+		{"/low", "/low", ""},
+		{"/start AB23CD45", "/start", "AB23CD45"},
+		{"how much SKU123?", "", ""},
+	}
+	for _, tt := range tests {
+		command, argument := Command(tt.text)
+		if command != tt.command || argument != tt.argument {
+			t.Errorf("%q: expected %q %q, got %q %q", tt.text, tt.command, tt.argument, command, argument)
+		}
+	}
+}
+
+func TestNewLinkCode(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		code, err := newLinkCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(code) != linkCodeLength || strings.Trim(code, linkCodeAlphabet) != "" {
+			t.Fatalf("unexpected code %q", code)
+		}
+		seen[code] = true
+	}
+	if len(seen) < 100 {
+		t.Errorf("expected 100 different codes, got %d", len(seen))
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := ValidateToken(testToken); err != nil {
+		t.Errorf("expected a valid token, got %v", err)
+	}
+	if err := ValidateToken("ABC-DEF"); err == nil {
+		t.Error("expected an error for a malformed token")
+	}
+	if err := ValidateSecret("short"); err == nil {
+		t.Error("expected an error for a short secret")
+	}
+	if err := ValidateSecret(strings.Repeat("s3cret_", 4)); err != nil {
+		t.Errorf("expected a valid secret, got %v", err)
+	}
+}
+
+func TestSendMessage(t *testing.T) {
+	var got map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bot" + testToken + "/sendMessage":
+			json.NewDecoder(r.Body).Decode(&got)
+			// A sent message comes back in full
+			fmt.Fprintf(w, `{"ok": true, "result": {"message_id": 1, "text": %q}}`, strings.Repeat("x", 1000))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"ok": false, "error_code": 403, "description": "Forbidden: bot was blocked by the user"}`)
+		}
+	}))
+	defer server.Close()
+	client := &Client{httpClient: server.Client(), baseURL: server.URL}
+
+	if err := client.SendMessage(context.Background(), testToken, -1001, "Critical alert"); err != nil {
+		t.Fatal(err)
+	}
+	if got["chat_id"] != float64(-1001) || got["text"] != "Critical alert" {
+		t.Errorf("unexpected request %v", got)
+	}
+
+	err := client.SendMessage(context.Background(), "other:token", 42, "hi")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusForbidden || !strings.Contains(apiErr.Description, "blocked") {
+		t.Errorf("expected a 403 APIError, got %v", err)
+	}
+}
+
+func TestCallHidesToken(t *testing.T) {
+	client := &Client{httpClient: http.DefaultClient, baseURL: "http://127.0.0.1:0"}
+	err := client.SendMessage(context.Background(), testToken, 1, "hi")
+	if err == nil || strings.Contains(err.Error(), testToken) {
+		t.Errorf("expected an error without the token, got %v", err)
+	}
+}
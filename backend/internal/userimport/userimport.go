@@ -0,0 +1,261 @@
+// Package userimport reads the CSV files admins upload to create many users
+// at once, such as seasonal warehouse staff, and writes back the outcome of
+// each row.
+package userimport
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"rtims-backend/internal/models"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+// MaxRows is the most users one file may create.
+const MaxRows = 1000
+
+// Columns are the columns an import file may have. Warehouse is the slug of the organization the user joins; when it
+// is left out or empty, users join the organization of the admin importing
+// them.
+var Columns = []string{"name", "email", "role", "warehouse"}
+
+var required = []string{"name", "email"}
+
+// Row is a user read from an import file.
+type Row struct {
+	Line      int
+	Name      string
+	Email     string
+	Role      string
+	Warehouse string
+}
+
+// Status is the outcome of a row.
+type Status string
+
+const (
+	// StatusValid and StatusInvalid are the outcomes of a preview, which
+	// creates nothing.
+	StatusValid   Status = "valid"
+	StatusInvalid Status = "invalid"
+	StatusCreated Status = "created"
+	StatusFailed  Status = "failed"
+)
+
+// Result is the outcome of a row.
+type Result struct {
+	Line           int             `json:"line"`
+	Name           string          `json:"name"`
+	Email          string          `json:"email"`
+	Role           models.UserRole `json:"role"`
+	Warehouse      string          `json:"warehouse,omitempty"`
+	Status         Status          `json:"status"`
+	Error          string          `json:"error,omitempty"`
+	UserID         *uuid.UUID      `json:"user_id,omitempty"`
+	Invited        bool            `json:"invited"`
+	OrganizationID uuid.UUID       `json:"-"`
+}
+
+// Fail marks the result as failed with the reason.
+func (r *Result) Fail(reason string) {
+	r.Status = StatusFailed
+	r.Error = reason
+}
+
+// Summary is how many rows ended up in each status.
+type Summary struct {
+	Total   int `json:"total"`
+	Valid   int `json:"valid"`
+	Invalid int `json:"invalid"`
+	Created int `json:"created"`
+	Failed  int `json:"failed"`
+	Invited int `json:"invited"`
+}
+
+// Summarize counts results by status.
+func Summarize(results []Result) Summary {
+	summary := Summary{Total: len(results)}
+	for _, result := range results {
+		switch result.Status {
+		case StatusValid:
+			summary.Valid++
+		case StatusInvalid:
+			summary.Invalid++
+		case StatusCreated:
+			summary.Created++
+		case StatusFailed:
+			summary.Failed++
+		}
+		if result.Invited {
+			summary.Invited++
+		}
+	}
+	return summary
+}
+
+// Parse reads the rows of an import file. The first line names the columns,
+// in any order and case; name and email are required. Errors are about the
+// file as a whole; problems with single rows are found by Validate.
+func Parse(r io.Reader) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	index := map[string]int{}
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		if !contains(Columns, column) {
+			return nil, fmt.Errorf("unknown column %q, expected %s", column, strings.Join(Columns, ", "))
+		}
+		if _, ok := index[column]; ok {
+			return nil, fmt.Errorf("column %q appears twice", column)
+		}
+		index[column] = i
+	}
+	for _, column := range required {
+		if _, ok := index[column]; !ok {
+			return nil, fmt.Errorf("missing column %q", column)
+		}
+	}
+
+	cell := func(record []string, column string) string {
+		i, ok := index[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	rows := []Row{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		if len(rows) == MaxRows {
+			return nil, fmt.Errorf("file has more than %d rows", MaxRows)
+		}
+		line, _ := reader.FieldPos(0)
+		rows = append(rows, Row{
+			Line:      line,
+			Name:      cell(record, "name"),
+			Email:     cell(record, "email"),
+			Role:      strings.ToLower(cell(record, "role")),
+			Warehouse: strings.ToLower(cell(record, "warehouse")),
+		})
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("file has no rows")
+	}
+	return rows, nil
+}
+
+var validate = validator.New()
+
+// Validate checks each row and returns its result, StatusValid or
+// StatusInvalid. taken holds the lowercased emails that already belong to
+// users; organizations maps the warehouse slugs rows may name to their
+// organization. Rows without a role are staff.
+func Validate(rows []Row, taken map[string]bool, organizations map[string]uuid.UUID) []Result {
+	seen := map[string]int{}
+	results := make([]Result, len(rows))
+	for i, row := range rows {
+		result := Result{
+			Line:      row.Line,
+			Name:      row.Name,
+			Email:     row.Email,
+			Role:      models.UserRole(row.Role),
+			Warehouse: row.Warehouse,
+			Status:    StatusValid,
+		}
+		if result.Role == "" {
+			result.Role = models.RoleStaff
+		}
+
+		email := strings.ToLower(row.Email)
+		var problems []string
+		if length := utf8.RuneCountInString(row.Name); length < 2 || length > 100 {
+			problems = append(problems, "name must be 2 to 100 characters")
+		}
+		switch {
+		case validate.Var(row.Email, "required,email,max=255") != nil:
+			problems = append(problems, "email is invalid")
+		case taken[email]:
+			problems = append(problems, "email is already in use")
+		case seen[email] != 0:
+			problems = append(problems, "email repeats line "+strconv.Itoa(seen[email]))
+		}
+		if result.Role != models.RoleStaff && result.Role != models.RoleAdmin {
+			problems = append(problems, "role must be staff or admin")
+		}
+		if row.Warehouse != "" {
+			if id, ok := organizations[row.Warehouse]; ok {
+				result.OrganizationID = id
+			} else {
+				problems = append(problems, "unknown warehouse "+strconv.Quote(row.Warehouse))
+			}
+		}
+
+		if seen[email] == 0 {
+			seen[email] = row.Line
+		}
+		if len(problems) > 0 {
+			result.Status = StatusInvalid
+			result.Error = strings.Join(problems, "; ")
+		}
+		results[i] = result
+	}
+	return results
+}
+
+// WriteCSV writes results as the downloadable result file.
+func WriteCSV(w io.Writer, results []Result) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"line", "name", "email", "role", "warehouse", "status", "invited", "user_id", "error"})
+	for _, result := range results {
+		userID := ""
+		if result.UserID != nil {
+			userID = result.UserID.String()
+		}
+		writer.Write([]string{
+			strconv.Itoa(result.Line),
+			result.Name,
+			result.Email,
+			string(result.Role),
+			result.Warehouse,
+			string(result.Status),
+			strconv.FormatBool(result.Invited),
+			userID,
+			result.Error,
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package userimport
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestParse(t *testing.T) {
+	rows, err := Parse(strings.NewReader("\ufeffEmail, Name,Warehouse\n ana@example.com ,Ana,North-DC\n\nbudi@example.com,Budi\n"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	want := []Row{
+		{Line: 2, Name: "Ana", Email: "ana@example.com", Warehouse: "north-dc"},
+		{Line: 4, Name: "Budi", Email: "budi@example.com"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("expected %d rows, got %v", len(want), rows)
+	}
+	for i := range want {
+		if rows[i] != want[i] {
+			t.Errorf("row %d: expected %+v, got %+v", i, want[i], rows[i])
+		}
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	tooMany := "name,email\n" + strings.Repeat("Ana,ana@example.com\n", MaxRows+1)
+	for name, file := range map[string]string{
+		"empty":          "",
+		"header only":    "name,email,role\n",
+		"missing email":  "name,role\nAna,staff\n",
+		"unknown column": "name,email,department\nAna,ana@example.com,ops\n",
+		"repeated":       "name,email,email\nAna,ana@example.com,ana@example.com\n",
+		"too many rows":  tooMany,
+	} {
+		if _, err := Parse(strings.NewReader(file)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	north := uuid.New()
+	rows := []Row{
+		{Line: 2, Name: "Ana", Email: "ana@example.com", Warehouse: "north"},
+		{Line: 3, Name: "Budi", Email: "budi@example.com", Role: "admin"},
+		{Line: 4, Name: "A", Email: "not-an-email", Role: "owner", Warehouse: "south"},
+		{Line: 5, Name: "Citra", Email: "Ana@Example.com"},
+		{Line: 6, Name: "Dewi", Email: "dewi@example.com"},
+	}
+	results := Validate(rows, map[string]bool{"dewi@example.com": true}, map[string]uuid.UUID{"north": north})
+
+	if results[0].Status != StatusValid || results[0].Role != models.RoleStaff || results[0].OrganizationID != north {
+		t.Errorf("expected a valid staff row in the north warehouse, got %+v", results[0])
+	}
+	if results[1].Status != StatusValid || results[1].Role != models.RoleAdmin || results[1].OrganizationID != uuid.Nil {
+		t.Errorf("expected a valid admin row in the importer's organization, got %+v", results[1])
+	}
+	for i, problem := range map[int]string{
+		2: "name must be 2 to 100 characters; email is invalid; role must be staff or admin; unknown warehouse \"south\"",
+		3: "email repeats line 2",
+		4: "email is already in use",
+	} {
+		if results[i].Status != StatusInvalid || results[i].Error != problem {
+			t.Errorf("line %d: expected %q, got %+v", rows[i].Line, problem, results[i])
+		}
+	}
+
+	summary := Summarize(results)
+	if summary != (Summary{Total: 5, Valid: 2, Invalid: 3}) {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	id := uuid.New()
+	var buf bytes.Buffer
+	err := WriteCSV(&buf, []Result{
+		{Line: 2, Name: "Ana", Email: "ana@example.com", Role: models.RoleStaff, Status: StatusCreated, UserID: &id, Invited: true},
+		{Line: 3, Name: "Budi, Jr.", Email: "budi", Role: models.RoleStaff, Status: StatusFailed, Error: "email is invalid"},
+	})
+	if err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+	want := "line,name,email,role,warehouse,status,invited,user_id,error\n" +
+		fmt.Sprintf("2,Ana,ana@example.com,staff,,created,true,%s,\n", id) +
+		"3,\"Budi, Jr.\",budi,staff,,failed,false,,email is invalid\n"
+	if buf.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, buf.String())
+	}
+}
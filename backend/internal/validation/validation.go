@@ -0,0 +1,64 @@
+// Package validation checks requests against the validate tags of the
+// models they bind to, e.g. `validate:"required,min=1,max=200"`.
+package validation
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+var validate = newValidate()
+
+func newValidate() *validator.Validate {
+	v := validator.New()
+	v.SetTagName("validate")
+	// Name fields in errors as clients send them, by their json (or, for
+	// query strings, form) tag
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, tag := range []string{"json", "form"} {
+			name := strings.Split(field.Tag.Get(tag), ",")[0]
+			if name != "" && name != "-" {
+				return name
+			}
+		}
+		return field.Name
+	})
+	return v
+}
+
+// Struct validates v, a struct or a pointer to one. Failures are returned
+// as validator.ValidationErrors.
+func Struct(v interface{}) error {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+	return validate.Struct(value.Interface())
+}
+
+// structValidator is the binding.StructValidator behind Install.
+type structValidator struct{}
+
+func (structValidator) ValidateStruct(obj interface{}) error {
+	return Struct(obj)
+}
+
+func (structValidator) Engine() interface{} {
+	return validate
+}
+
+// Install makes gin's ShouldBind methods validate what they bind, so
+// handlers get a validator.ValidationErrors from them for invalid requests.
+// Call it once at startup.
+func Install() {
+	binding.Validator = structValidator{}
+}
@@ -0,0 +1,44 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+
+	"rtims-backend/internal/models"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func TestInstall(t *testing.T) {
+	Install()
+
+	var req models.CreateProductRequest
+	err := binding.JSON.BindBody([]byte(`{"name": "", "sku": "SKU-1", "price": -5, "category": "Tools"}`), &req)
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		t.Fatalf("expected validation errors, got %v", err)
+	}
+	fields := map[string]string{}
+	for _, fieldErr := range validationErrs {
+		fields[fieldErr.Field()] = fieldErr.Tag()
+	}
+	if len(fields) != 2 || fields["name"] != "required" || fields["price"] != "min" {
+		t.Errorf("unexpected failures %v", fields)
+	}
+
+	err = binding.JSON.BindBody([]byte(`{"name": "Hammer", "sku": "SKU-1", "price": 5, "category": "Tools"}`), &req)
+	if err != nil {
+		t.Errorf("unexpected error for a valid product: %v", err)
+	}
+}
+
+func TestStructIgnoresNonStructs(t *testing.T) {
+	settings := map[string]interface{}{"low_stock_threshold": -1}
+	if err := Struct(&settings); err != nil {
+		t.Errorf("unexpected error for a map: %v", err)
+	}
+	if err := Struct((*models.CreateProductRequest)(nil)); err != nil {
+		t.Errorf("unexpected error for a nil pointer: %v", err)
+	}
+}
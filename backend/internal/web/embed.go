@@ -0,0 +1,23 @@
+//go:build embedfrontend
+
+package web
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// dist holds the frontend's static export, copied here by the build (see
+// the Dockerfile at the repository root).
+//
+//go:embed all:dist
+var dist embed.FS
+
+// Files returns the embedded frontend.
+func Files() fs.FS {
+	files, err := fs.Sub(dist, "dist")
+	if err != nil {
+		panic(err)
+	}
+	return files
+}
@@ -0,0 +1,10 @@
+//go:build !embedfrontend
+
+package web
+
+import "io/fs"
+
+// Files returns nil: this binary was built without the frontend.
+func Files() fs.FS {
+	return nil
+}
@@ -0,0 +1,113 @@
+// Package web serves the frontend's static export, so a small deployment
+// can run the API and the frontend from one binary. The export is built
+// into the binary with -tags embedfrontend (see Files); without the tag
+// the frontend is deployed separately.
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"rtims-backend/internal/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiPaths are never the frontend's: unknown routes under them answer
+// with the API's 404 rather than the app.
+var apiPaths = []string{"/api/", "/ws", "/swagger/", "/openapi.json", "/health", "/readyz"}
+
+// Handler serves files, the frontend's static export, for routes the API
+// does not handle. A path is looked up as a file, then as an exported
+// page (path.html or path/index.html); anything else without a file
+// extension is a client-side route and gets index.html.
+//
+// Fingerprinted assets under /_next/static/ are cached for a year, pages
+// are revalidated on every load so a deploy takes effect at once, and
+// other files are cached for an hour.
+func Handler(files fs.FS) gin.HandlerFunc {
+	etags := fileETags(files)
+	return func(c *gin.Context) {
+		urlPath := c.Request.URL.Path
+		if (c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead) || isAPIPath(urlPath) {
+			apierror.Respond(c, apierror.ErrNotFound)
+			return
+		}
+
+		name, ok := resolve(files, urlPath)
+		if !ok {
+			if path.Ext(urlPath) != "" {
+				c.Status(http.StatusNotFound)
+				return
+			}
+			name = "index.html"
+		}
+		data, err := fs.ReadFile(files, name)
+		if err != nil {
+			c.Status(http.StatusNotFound)
+			return
+		}
+
+		switch {
+		case strings.HasPrefix(name, "_next/static/"):
+			c.Header("Cache-Control", "public, max-age=31536000, immutable")
+		case path.Ext(name) == ".html":
+			c.Header("Cache-Control", "no-cache")
+		default:
+			c.Header("Cache-Control", "public, max-age=3600")
+		}
+		c.Header("ETag", etags[name])
+		http.ServeContent(c.Writer, c.Request, name, time.Time{}, bytes.NewReader(data))
+	}
+}
+
+func isAPIPath(urlPath string) bool {
+	for _, prefix := range apiPaths {
+		if strings.HasPrefix(urlPath, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve returns the file in files that serves urlPath.
+func resolve(files fs.FS, urlPath string) (string, bool) {
+	name := strings.TrimPrefix(path.Clean("/"+urlPath), "/")
+	candidates := []string{name + ".html", path.Join(name, "index.html")}
+	if name == "" {
+		candidates = []string{"index.html"}
+	} else {
+		candidates = append([]string{name}, candidates...)
+	}
+	for _, candidate := range candidates {
+		if info, err := fs.Stat(files, candidate); err == nil && !info.IsDir() {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// fileETags hashes every file once, so pages can be revalidated without
+// being sent again.
+func fileETags(files fs.FS) map[string]string {
+	etags := map[string]string{}
+	fs.WalkDir(files, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(files, name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		etags[name] = `"` + hex.EncodeToString(sum[:8]) + `"`
+		return nil
+	})
+	return etags
+}
@@ -0,0 +1,79 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	files := fstest.MapFS{
+		"index.html":                    {Data: []byte("<html>home</html>")},
+		"products.html":                 {Data: []byte("<html>products</html>")},
+		"settings/index.html":           {Data: []byte("<html>settings</html>")},
+		"favicon.ico":                   {Data: []byte("icon")},
+		"_next/static/chunks/app-1a.js": {Data: []byte("js")},
+	}
+	r := gin.New()
+	r.NoRoute(Handler(files))
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		status       int
+		body         string
+		cacheControl string
+	}{
+		{"root", http.MethodGet, "/", http.StatusOK, "<html>home</html>", "no-cache"},
+		{"exported page", http.MethodGet, "/products", http.StatusOK, "<html>products</html>", "no-cache"},
+		{"exported directory page", http.MethodGet, "/settings", http.StatusOK, "<html>settings</html>", "no-cache"},
+		{"client-side route", http.MethodGet, "/products/42/edit", http.StatusOK, "<html>home</html>", "no-cache"},
+		{"static asset", http.MethodGet, "/_next/static/chunks/app-1a.js", http.StatusOK, "js", "public, max-age=31536000, immutable"},
+		{"other file", http.MethodGet, "/favicon.ico", http.StatusOK, "icon", "public, max-age=3600"},
+		{"missing asset", http.MethodGet, "/_next/static/chunks/gone.js", http.StatusNotFound, "", ""},
+		{"unknown api route", http.MethodGet, "/api/v1/nothing", http.StatusNotFound, "", ""},
+		{"post", http.MethodPost, "/products", http.StatusNotFound, "", ""},
+		{"traversal", http.MethodGet, "/../../etc/passwd", http.StatusOK, "<html>home</html>", "no-cache"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.status {
+				t.Fatalf("expected %d, got %d", tt.status, w.Code)
+			}
+			if tt.body != "" && w.Body.String() != tt.body {
+				t.Errorf("expected %q, got %q", tt.body, w.Body.String())
+			}
+			if got := w.Header().Get("Cache-Control"); got != tt.cacheControl {
+				t.Errorf("expected Cache-Control %q, got %q", tt.cacheControl, got)
+			}
+		})
+	}
+}
+
+func TestHandlerRevalidates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.NoRoute(Handler(fstest.MapFS{"index.html": {Data: []byte("<html>home</html>")}}))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected an ETag")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for a current ETag, got %d", w.Code)
+	}
+}
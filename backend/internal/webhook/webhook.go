@@ -0,0 +1,206 @@
+// Package webhook delivers the events admins subscribe webhooks to. Every
+// request is signed with the webhook's secret, and deliveries the receiver
+// does not accept are retried with exponential backoff.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"rtims-backend/internal/buildinfo"
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/models"
+)
+
+// Headers sent with every delivery. SignatureHeader is "sha256=" followed by
+// the hex HMAC-SHA256, keyed by the webhook's secret, of the timestamp
+// header, a dot and the body.
+const (
+	EventHeader     = "X-RTIMS-Event"
+	DeliveryHeader  = "X-RTIMS-Delivery"
+	TimestampHeader = "X-RTIMS-Timestamp"
+	SignatureHeader = "X-RTIMS-Signature"
+)
+
+const (
+	// MaxAttempts is how many times a delivery is sent before it fails.
+	MaxAttempts = 8
+	// baseBackoff is the wait before the first retry; each retry after it
+	// waits twice as long, so the last comes about an hour after the event.
+	baseBackoff = 30 * time.Second
+	batchSize   = 50
+	// maxResponseBody is how much of a receiver's response is kept in the
+	// delivery log.
+	maxResponseBody = 1024
+	// retention is how long finished deliveries stay in the log.
+	retention = 30 * 24 * time.Hour
+)
+
+// NewSecret returns a random secret for signing a webhook's deliveries.
+func NewSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+// Sign returns the SignatureHeader value for body sent at timestamp.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff returns how long to wait before retrying a delivery that has
+// been sent attempts times.
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	return baseBackoff << (attempts - 1)
+}
+
+// retryable reports whether an attempt that failed may succeed when sent
+// again. Client errors other than rate limiting will not.
+func retryable(attempt models.WebhookAttempt) bool {
+	return attempt.ResponseStatus == 0 || attempt.ResponseStatus == http.StatusTooManyRequests || attempt.ResponseStatus >= 500
+}
+
+// Outcome returns the status of a delivery after its attempts-th attempt,
+// and when it is retried, if it is.
+func Outcome(attempt models.WebhookAttempt, attempts int, now time.Time) (models.WebhookDeliveryStatus, *time.Time) {
+	if attempt.Succeeded() {
+		return models.DeliverySucceeded, nil
+	}
+	if attempts >= MaxAttempts || !retryable(attempt) {
+		return models.DeliveryFailed, nil
+	}
+	next := now.Add(Backoff(attempts))
+	return models.DeliveryPending, &next
+}
+
+// Deliverer sends queued deliveries.
+type Deliverer struct {
+	webhookService *database.WebhookService
+	httpClient     *http.Client
+	lastPurge      time.Time
+}
+
+func NewDeliverer(db *sql.DB) *Deliverer {
+	return &Deliverer{
+		webhookService: database.NewWebhookService(db),
+		httpClient:     newHTTPClient(10 * time.Second),
+	}
+}
+
+// Run sends due deliveries every interval, and purges old ones from the log
+// once a day, until ctx is done. It blocks, so run it in its own goroutine.
+func (d *Deliverer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for {
+			deliveries, err := d.webhookService.ClaimWebhookDeliveries(context.Background(), batchSize)
+			if err != nil {
+				log.Printf("Failed to claim webhook deliveries: %v", err)
+				break
+			}
+			d.deliverAll(deliveries)
+			if len(deliveries) < batchSize {
+				break
+			}
+		}
+
+		if time.Since(d.lastPurge) > 24*time.Hour {
+			d.lastPurge = time.Now()
+			if _, err := d.webhookService.PurgeWebhookDeliveries(context.Background(), time.Now().Add(-retention)); err != nil {
+				log.Printf("Failed to purge webhook deliveries: %v", err)
+			}
+		}
+	}
+}
+
+// deliverAll sends a batch concurrently, so one slow receiver does not hold
+// up the others.
+func (d *Deliverer) deliverAll(deliveries []database.PendingDelivery) {
+	var wg sync.WaitGroup
+	for i := range deliveries {
+		wg.Add(1)
+		go func(delivery *database.PendingDelivery) {
+			defer wg.Done()
+			attempt := d.Send(context.Background(), delivery.URL, delivery.Secret, &delivery.WebhookDelivery)
+			status, next := Outcome(attempt, delivery.Attempts+1, time.Now())
+			if status == models.DeliveryFailed {
+				log.Printf("Webhook delivery %s to %s failed after %d attempts: %s",
+					delivery.ID, delivery.URL, delivery.Attempts+1, describe(attempt))
+			}
+			if err := d.webhookService.RecordWebhookAttempt(context.Background(), delivery.ID, attempt, status, next); err != nil {
+				log.Printf("Failed to record webhook delivery %s: %v", delivery.ID, err)
+			}
+		}(&deliveries[i])
+	}
+	wg.Wait()
+}
+
+// Send posts a delivery's payload to url once, signed with secret.
+func (d *Deliverer) Send(ctx context.Context, url, secret string, delivery *models.WebhookDelivery) models.WebhookAttempt {
+	start := time.Now()
+	timestamp := strconv.FormatInt(start.Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return models.WebhookAttempt{Error: err.Error()}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "RTIMS-Webhooks/"+buildinfo.Version)
+	req.Header.Set(EventHeader, string(delivery.EventType))
+	req.Header.Set(DeliveryHeader, delivery.ID.String())
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(secret, timestamp, delivery.Payload))
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return models.WebhookAttempt{Error: err.Error(), Duration: time.Since(start)}
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	attempt := models.WebhookAttempt{
+		ResponseStatus: resp.StatusCode,
+		ResponseBody:   string(body),
+		Duration:       time.Since(start),
+	}
+	if !attempt.Succeeded() {
+		attempt.Error = fmt.Sprintf("webhook returned status %d", resp.StatusCode)
+	}
+	return attempt
+}
+
+func describe(attempt models.WebhookAttempt) string {
+	if attempt.Error != "" {
+		return attempt.Error
+	}
+	return fmt.Sprintf("status %d", attempt.ResponseStatus)
+}
@@ -0,0 +1,147 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestSend(t *testing.T) {
+	delivery := &models.WebhookDelivery{
+		ID:        uuid.New(),
+		EventType: models.WebhookProductCreated,
+		Payload:   []byte(`{"type":"product.created"}`),
+	}
+
+	var header http.Header
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+		io.WriteString(w, strings.Repeat("x", 2*maxResponseBody))
+	}))
+	defer server.Close()
+
+	d := &Deliverer{httpClient: server.Client()}
+	attempt := d.Send(context.Background(), server.URL, "whsec_test", delivery)
+
+	if !attempt.Succeeded() || attempt.ResponseStatus != http.StatusAccepted {
+		t.Fatalf("expected the delivery to succeed, got %+v", attempt)
+	}
+	if len(attempt.ResponseBody) != maxResponseBody {
+		t.Errorf("expected the response body cut to %d bytes, got %d", maxResponseBody, len(attempt.ResponseBody))
+	}
+	if string(body) != string(delivery.Payload) {
+		t.Errorf("expected the payload as the body, got %s", body)
+	}
+	if header.Get(EventHeader) != "product.created" || header.Get(DeliveryHeader) != delivery.ID.String() {
+		t.Errorf("expected event and delivery headers, got %v", header)
+	}
+	if want := Sign("whsec_test", header.Get(TimestampHeader), delivery.Payload); header.Get(SignatureHeader) != want {
+		t.Errorf("expected signature %s, got %s", want, header.Get(SignatureHeader))
+	}
+}
+
+func TestSendFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	d := &Deliverer{httpClient: server.Client()}
+	attempt := d.Send(context.Background(), server.URL, "secret", &models.WebhookDelivery{Payload: []byte(`{}`)})
+	if attempt.Succeeded() || attempt.ResponseStatus != http.StatusServiceUnavailable || attempt.Error == "" {
+		t.Errorf("expected a failed attempt with status 503, got %+v", attempt)
+	}
+}
+
+func TestSign(t *testing.T) {
+	// printf '1700000000.{"a":1}' | openssl dgst -sha256 -hmac secret
+	want := "sha256=49f24e537407743fa4a0242bb63b94b9a47ee99cbbe071ccd8a22550ae411686"
+	got := Sign("secret", "1700000000", []byte(`{"a":1}`))
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if Sign("other", "1700000000", []byte(`{"a":1}`)) == got || Sign("secret", "1700000001", []byte(`{"a":1}`)) == got {
+		t.Error("expected the signature to depend on the secret and timestamp")
+	}
+}
+
+func TestOutcome(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		attempt  models.WebhookAttempt
+		attempts int
+		status   models.WebhookDeliveryStatus
+		retryIn  time.Duration
+	}{
+		{"success", models.WebhookAttempt{ResponseStatus: 204}, 1, models.DeliverySucceeded, 0},
+		{"network error", models.WebhookAttempt{Error: "connection refused"}, 1, models.DeliveryPending, 30 * time.Second},
+		{"server error backs off", models.WebhookAttempt{ResponseStatus: 502, Error: "status 502"}, 3, models.DeliveryPending, 2 * time.Minute},
+		{"rate limited", models.WebhookAttempt{ResponseStatus: 429, Error: "status 429"}, 2, models.DeliveryPending, time.Minute},
+		{"client error", models.WebhookAttempt{ResponseStatus: 410, Error: "status 410"}, 1, models.DeliveryFailed, 0},
+		{"exhausted", models.WebhookAttempt{ResponseStatus: 500, Error: "status 500"}, MaxAttempts, models.DeliveryFailed, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, next := Outcome(tt.attempt, tt.attempts, now)
+			if status != tt.status {
+				t.Errorf("expected %s, got %s", tt.status, status)
+			}
+			if tt.retryIn == 0 && next != nil {
+				t.Errorf("expected no retry, got %v", next)
+			}
+			if tt.retryIn != 0 && (next == nil || next.Sub(now) != tt.retryIn) {
+				t.Errorf("expected a retry in %v, got %v", tt.retryIn, next)
+			}
+		})
+	}
+}
+
+func TestSendRefusesInternalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("expected no request to reach a loopback address")
+	}))
+	defer server.Close()
+
+	d := NewDeliverer(nil)
+	attempt := d.Send(context.Background(), server.URL, "secret", &models.WebhookDelivery{Payload: []byte(`{}`)})
+	if attempt.Succeeded() || !strings.Contains(attempt.Error, ErrForbiddenAddress.Error()) {
+		t.Errorf("expected the delivery to be refused, got %+v", attempt)
+	}
+}
+
+func TestPublicAddress(t *testing.T) {
+	for address, want := range map[string]bool{
+		"93.184.216.34":    true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"::1":              false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"fd00:ec2::254":    false,
+		"fe80::1":          false,
+		"100.100.100.200":  false,
+		"0.0.0.0":          false,
+		"::ffff:127.0.0.1": false,
+		"224.0.0.1":        false,
+	} {
+		if got := refuseInternal("tcp", net.JoinHostPort(address, "443"), nil) == nil; got != want {
+			t.Errorf("%s: expected allowed %v, got %v", address, want, got)
+		}
+	}
+}
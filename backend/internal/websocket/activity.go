@@ -0,0 +1,156 @@
+package websocket
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"rtims-backend/internal/activity"
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// activityLag is how far back each poll of the activity feed looks, as
+	// audit entries are written in the background and stock movements
+	// become visible when their transaction commits, after they occurred.
+	activityLag = 30 * time.Second
+
+	// activityBatchSize caps how many entries are read per query.
+	activityBatchSize = 100
+)
+
+// activityCursor is how far the activity feed of an organization has been
+// pushed: when it was last polled, and the entries pushed since activityLag
+// before that, which the next poll sees again.
+type activityCursor struct {
+	polled time.Time
+	pushed map[uuid.UUID]time.Time
+}
+
+// RunActivityFeed polls the activity feed of each organization with a
+// client connected every interval and pushes the new entries to its
+// clients. It blocks until ctx is done, so run it in its own goroutine.
+func (h *Hub) RunActivityFeed(ctx context.Context, interval time.Duration, getEntries func(ctx context.Context, since time.Time, limit int) ([]models.FeedEntry, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	cursors := make(map[uuid.UUID]*activityCursor)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		connected := make(map[uuid.UUID]bool)
+		for _, organization := range h.organizations() {
+			connected[organization] = true
+
+			cursor, ok := cursors[organization]
+			if !ok {
+				cursor = &activityCursor{pushed: make(map[uuid.UUID]time.Time)}
+				cursors[organization] = cursor
+			}
+			h.pollActivity(organization, cursor, !ok, getEntries)
+		}
+
+		// Organizations start over from their next client
+		for organization := range cursors {
+			if !connected[organization] {
+				delete(cursors, organization)
+			}
+		}
+	}
+}
+
+// pollActivity pushes the entries of the organization's activity feed that
+// cursor has not seen. The first poll of an organization only marks what
+// is there as seen, since its clients read it when they connected.
+func (h *Hub) pollActivity(organization uuid.UUID, cursor *activityCursor, first bool, getEntries func(ctx context.Context, since time.Time, limit int) ([]models.FeedEntry, error)) {
+	now := time.Now()
+	since := now
+	if !first {
+		since = cursor.polled
+	}
+
+	ctx := database.WithOrganization(context.Background(), organization)
+	from := since.Add(-activityLag)
+	for {
+		entries, err := getEntries(ctx, from, activityBatchSize)
+		if err != nil {
+			log.Printf("Failed to get the activity feed: %v", err)
+			return
+		}
+
+		for _, entry := range entries {
+			if _, ok := cursor.pushed[entry.ID]; ok {
+				continue
+			}
+			cursor.pushed[entry.ID] = entry.OccurredAt
+			if !first {
+				h.sendActivity(organization, entry)
+			}
+		}
+
+		if len(entries) < activityBatchSize {
+			break
+		}
+		from = entries[len(entries)-1].OccurredAt
+	}
+	cursor.polled = now
+
+	// Entries older than the next poll looks back are not seen again
+	for id, occurredAt := range cursor.pushed {
+		if occurredAt.Before(now.Add(-activityLag)) {
+			delete(cursor.pushed, id)
+		}
+	}
+}
+
+// sendActivity sends an entry of the organization's activity feed to its
+// clients, with the message in each client's language.
+func (h *Hub) sendActivity(organization uuid.UUID, entry models.FeedEntry) {
+	encoded := make(map[string][]byte)
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for client := range h.Clients {
+		if client.Organization != organization {
+			continue
+		}
+
+		jsonData, ok := encoded[client.Language]
+		if !ok {
+			entry.Message = activity.Describe(entry, client.Language)
+			var err error
+			if jsonData, err = encodeEvent(EventActivity, ActivityEvent{Entry: entry}); err != nil {
+				return
+			}
+			encoded[client.Language] = jsonData
+		}
+
+		select {
+		case client.Send <- jsonData:
+		default:
+		}
+	}
+}
+
+// organizations returns the organizations with a client connected.
+func (h *Hub) organizations() []uuid.UUID {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	seen := make(map[uuid.UUID]bool)
+	var organizations []uuid.UUID
+	for client := range h.Clients {
+		if !seen[client.Organization] {
+			seen[client.Organization] = true
+			organizations = append(organizations, client.Organization)
+		}
+	}
+	return organizations
+}
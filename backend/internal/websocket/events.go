@@ -0,0 +1,166 @@
+package websocket
+
+import (
+	"encoding/json"
+	"time"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// EventSchemaVersion is bumped whenever an event payload changes in a way
+// that is not backwards compatible.
+const EventSchemaVersion = 1
+
+// EventType identifies the payload carried in an Envelope.
+type EventType string
+
+const (
+	EventLowStock       EventType = "stock_update"
+	EventNotifications  EventType = "notifications"
+	EventSystemStatus   EventType = "system_status"
+	EventStockChanged   EventType = "stock_change"
+	EventNotification   EventType = "notification"
+	EventAnnouncement   EventType = "announcement"
+	EventAnnouncements  EventType = "announcements"
+	EventDashboardStats EventType = "dashboard_stats"
+	EventServerShutdown EventType = "server_shutdown"
+	EventActivity       EventType = "activity"
+	EventBatch          EventType = "batch"
+)
+
+// Envelope is the wire format of every server-to-client event:
+//
+//	{"version": 1, "type": "stock_change", "timestamp": "...", "data": {...}}
+//
+// Clients should switch on Type and ignore events whose Version they do not
+// understand. When several events are batched into one frame they arrive as
+// a single batch envelope whose data is the array of their envelopes, so
+// every frame is an object.
+type Envelope struct {
+	Version   int         `json:"version"`
+	Type      EventType   `json:"type"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// LowStockProduct is a single entry in a LowStockEvent.
+type LowStockProduct struct {
+	ID               string `json:"id"`
+	Name             string `json:"name"`
+	SKU              string `json:"sku"`
+	Stock            int    `json:"stock"`
+	MinimumThreshold int    `json:"minimum_threshold"`
+}
+
+// LowStockEvent is sent on connect with every product at or below its threshold.
+type LowStockEvent struct {
+	Products []LowStockProduct `json:"products"`
+	Message  string            `json:"message"`
+}
+
+// NotificationSummary is a single entry in a NotificationsEvent.
+type NotificationSummary struct {
+	ID        string    `json:"id"`
+	Message   string    `json:"message"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NotificationsEvent is sent on connect with the user's unread notifications.
+type NotificationsEvent struct {
+	Notifications []NotificationSummary `json:"notifications"`
+}
+
+// SystemStatusEvent carries headline inventory counts.
+type SystemStatusEvent struct {
+	TotalProducts int       `json:"total_products"`
+	LowStockCount int       `json:"low_stock_count"`
+	TotalUsers    int       `json:"total_users"`
+	ServerTime    time.Time `json:"server_time"`
+}
+
+// StockChangedEvent is broadcast whenever a product's stock level changes.
+type StockChangedEvent struct {
+	ProductID uuid.UUID `json:"product_id"`
+	NewStock  int       `json:"new_stock"`
+}
+
+// NotificationEvent is broadcast when a notification is created for a user.
+type NotificationEvent struct {
+	ID               uuid.UUID                    `json:"id"`
+	UserID           uuid.UUID                    `json:"user_id"`
+	Message          string                       `json:"message"`
+	NotificationType string                       `json:"notification_type"`
+	Priority         models.NotificationPriority  `json:"priority"`
+	Metadata         *models.NotificationMetadata `json:"metadata,omitempty"`
+}
+
+// AnnouncementEvent is broadcast when an admin publishes an announcement.
+type AnnouncementEvent struct {
+	Announcement models.Announcement `json:"announcement"`
+}
+
+// AnnouncementsEvent is sent on connect with all active announcements.
+type AnnouncementsEvent struct {
+	Announcements []models.Announcement `json:"announcements"`
+}
+
+// DashboardStatsEvent is pushed periodically to admin clients so the
+// dashboard does not need to poll /dashboard/stats.
+type DashboardStatsEvent struct {
+	Stats map[string]interface{} `json:"stats"`
+}
+
+// ActivityEvent is sent to the clients of an organization for each new
+// entry of its activity feed, with the message in the client's language.
+type ActivityEvent struct {
+	Entry models.FeedEntry `json:"entry"`
+}
+
+// ServerShutdownEvent is the last event a client receives before the server
+// closes the connection for a restart.
+type ServerShutdownEvent struct {
+	ReconnectAfterMs int `json:"reconnect_after_ms"`
+}
+
+// encodeEvent wraps data in a versioned envelope and marshals it.
+func encodeEvent(eventType EventType, data interface{}) ([]byte, error) {
+	return json.Marshal(Envelope{
+		Version:   EventSchemaVersion,
+		Type:      eventType,
+		Timestamp: time.Now(),
+		Data:      data,
+	})
+}
+
+// sendEvent queues an event for a single client, giving up after a second
+// or once the hub has dropped the client.
+func (c *Client) sendEvent(eventType EventType, data interface{}) {
+	jsonData, err := encodeEvent(eventType, data)
+	if err != nil {
+		return
+	}
+
+	select {
+	case c.Send <- jsonData:
+	case <-c.done:
+	case <-time.After(time.Second):
+	}
+}
+
+// broadcastEvent queues an event for the clients to is addressed to, all
+// of them when to is empty, without blocking the caller.
+func (h *Hub) broadcastEvent(to Message, eventType EventType, data interface{}) {
+	jsonData, err := encodeEvent(eventType, data)
+	if err != nil {
+		return
+	}
+	to.Data = jsonData
+
+	select {
+	case h.Broadcast <- to:
+	default:
+	}
+}
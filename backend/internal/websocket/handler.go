@@ -1,13 +1,18 @@
 package websocket
 
 import (
+	"compress/flate"
 	"database/sql"
-	"encoding/json"
 	"log"
 	"net/http"
 	"time"
 
+	"rtims-backend/config"
+	"rtims-backend/internal/apierror"
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/i18n"
 	"rtims-backend/internal/middleware"
+	"rtims-backend/internal/models"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
@@ -16,22 +21,55 @@ import (
 )
 
 var upgrader = websocket.Upgrader{
+	// Negotiate permessage-deflate; dashboards on metered links receive a
+	// steady stream of small, highly repetitive JSON events.
+	EnableCompression: true,
 	CheckOrigin: func(r *http.Request) bool {
-		// Allow connections from any origin in development
-		// In production, implement proper origin checking
+		// Allow connections from any origin in development.
+		// ConfigureOriginCheck replaces this in production.
 		return true
 	},
 }
 
+// ConfigureOriginCheck restricts WebSocket upgrades to known origins when
+// running in production. WS_ALLOWED_ORIGINS, when set, takes precedence over
+// origins, the general CORS allow-list. Requests without an Origin header
+// come from non-browser clients and are still accepted.
+func ConfigureOriginCheck(cfg *config.Config, origins *middleware.Origins) {
+	if cfg.Environment != "production" {
+		return
+	}
+
+	if len(cfg.WSAllowedOrigins) > 0 {
+		origins = middleware.NewOrigins(cfg.WSAllowedOrigins, nil)
+	}
+
+	upgrader.CheckOrigin = func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" || origins.Allowed(origin) {
+			return true
+		}
+
+		log.Printf("Rejected WebSocket upgrade from origin %q", origin)
+		return false
+	}
+}
+
 func ServeWebSocket(hub *Hub, c *gin.Context, db *sql.DB, redisClient *redis.Client) {
+	if hub.IsShuttingDown() {
+		apierror.Respond(c, apierror.New(http.StatusServiceUnavailable, apierror.CodeUnavailable, "Server is shutting down"))
+		return
+	}
+
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Println("Failed to upgrade connection:", err)
 		return
 	}
+	conn.SetCompressionLevel(flate.BestSpeed)
 
 	// Get current user info
-	userID, _, err := middleware.GetCurrentUser(c)
+	userID, role, err := middleware.GetCurrentUser(c)
 	if err != nil {
 		log.Println("Failed to get user info:", err)
 		conn.Close()
@@ -39,10 +77,15 @@ func ServeWebSocket(hub *Hub, c *gin.Context, db *sql.DB, redisClient *redis.Cli
 	}
 
 	client := &Client{
-		ID:   userID.String(),
-		Conn: conn,
-		Send: make(chan []byte, 256),
-		Hub:  hub,
+		ID:           userID.String(),
+		Role:         role,
+		Organization: middleware.GetOrganization(c),
+		Language:     i18n.FromAcceptLanguage(c.GetHeader("Accept-Language")),
+		Conn:         conn,
+		Send:         make(chan []byte, 256),
+		Hub:          hub,
+		done:         make(chan struct{}),
+		flushed:      make(chan struct{}),
 	}
 
 	client.Hub.Register <- client
@@ -61,6 +104,9 @@ func ServeWebSocket(hub *Hub, c *gin.Context, db *sql.DB, redisClient *redis.Cli
 
 		// Send system status
 		sendSystemStatus(client, db)
+
+		// Send announcements that are still active
+		sendAnnouncements(client, db)
 	}()
 }
 
@@ -69,45 +115,28 @@ func sendStockUpdates(client *Client, db *sql.DB) {
 	rows, err := db.Query(`
 		SELECT id, name, sku, stock, minimum_threshold
 		FROM products
-		WHERE stock <= minimum_threshold AND minimum_threshold > 0
-	`)
+		WHERE stock <= minimum_threshold AND minimum_threshold > 0 AND organization_id = $1
+	`, client.Organization)
 	if err != nil {
 		log.Println("Failed to query low stock products:", err)
 		return
 	}
 	defer rows.Close()
 
-	var lowStockProducts []map[string]interface{}
+	var lowStockProducts []LowStockProduct
 	for rows.Next() {
-		var id, name, sku string
-		var stock, threshold int
-		if err := rows.Scan(&id, &name, &sku, &stock, &threshold); err != nil {
+		var product LowStockProduct
+		if err := rows.Scan(&product.ID, &product.Name, &product.SKU, &product.Stock, &product.MinimumThreshold); err != nil {
 			continue
 		}
-
-		lowStockProducts = append(lowStockProducts, map[string]interface{}{
-			"id":                id,
-			"name":              name,
-			"sku":               sku,
-			"stock":             stock,
-			"minimum_threshold": threshold,
-			"type":              "low_stock_alert",
-		})
+		lowStockProducts = append(lowStockProducts, product)
 	}
 
 	if len(lowStockProducts) > 0 {
-		message := map[string]interface{}{
-			"type":    "stock_update",
-			"data":    lowStockProducts,
-			"message": "Low stock alerts",
-		}
-
-		if jsonData, err := json.Marshal(message); err == nil {
-			select {
-			case client.Send <- jsonData:
-			case <-time.After(time.Second):
-			}
-		}
+		client.sendEvent(EventLowStock, LowStockEvent{
+			Products: lowStockProducts,
+			Message:  "Low stock alerts",
+		})
 	}
 }
 
@@ -126,95 +155,64 @@ func sendNotifications(client *Client, db *sql.DB, userID uuid.UUID) {
 	}
 	defer rows.Close()
 
-	var notifications []map[string]interface{}
+	var notifications []NotificationSummary
 	for rows.Next() {
-		var id, message, notifType string
-		var createdAt time.Time
-		if err := rows.Scan(&id, &message, &notifType, &createdAt); err != nil {
+		var notification NotificationSummary
+		if err := rows.Scan(&notification.ID, &notification.Message, &notification.Type, &notification.CreatedAt); err != nil {
 			continue
 		}
-
-		notifications = append(notifications, map[string]interface{}{
-			"id":         id,
-			"message":    message,
-			"type":       notifType,
-			"created_at": createdAt,
-		})
+		notifications = append(notifications, notification)
 	}
 
 	if len(notifications) > 0 {
-		message := map[string]interface{}{
-			"type": "notifications",
-			"data": notifications,
-		}
-
-		if jsonData, err := json.Marshal(message); err == nil {
-			select {
-			case client.Send <- jsonData:
-			case <-time.After(time.Second):
-			}
-		}
+		client.sendEvent(EventNotifications, NotificationsEvent{Notifications: notifications})
 	}
 }
 
 func sendSystemStatus(client *Client, db *sql.DB) {
 	// Get system statistics
-	var totalProducts, lowStockCount, totalUsers int
-	db.QueryRow("SELECT COUNT(*) FROM products").Scan(&totalProducts)
-	db.QueryRow("SELECT COUNT(*) FROM products WHERE stock <= minimum_threshold").Scan(&lowStockCount)
-	db.QueryRow("SELECT COUNT(*) FROM users WHERE is_active = true").Scan(&totalUsers)
-
-	systemStatus := map[string]interface{}{
-		"total_products":   totalProducts,
-		"low_stock_count":  lowStockCount,
-		"total_users":      totalUsers,
-		"server_time":      time.Now(),
-	}
-
-	message := map[string]interface{}{
-		"type": "system_status",
-		"data": systemStatus,
-	}
+	var status SystemStatusEvent
+	db.QueryRow("SELECT COUNT(*) FROM products WHERE organization_id = $1", client.Organization).Scan(&status.TotalProducts)
+	db.QueryRow("SELECT COUNT(*) FROM products WHERE stock <= minimum_threshold AND organization_id = $1", client.Organization).Scan(&status.LowStockCount)
+	db.QueryRow("SELECT COUNT(*) FROM users WHERE is_active = true AND organization_id = $1", client.Organization).Scan(&status.TotalUsers)
+	status.ServerTime = time.Now()
 
-	if jsonData, err := json.Marshal(message); err == nil {
-		select {
-		case client.Send <- jsonData:
-		case <-time.After(time.Second):
-		}
-	}
+	client.sendEvent(EventSystemStatus, status)
 }
 
-// BroadcastStockUpdate sends stock updates to all connected clients
-func BroadcastStockUpdate(hub *Hub, productID uuid.UUID, newStock int) {
-	message := map[string]interface{}{
-		"type":      "stock_change",
-		"product_id": productID,
-		"new_stock": newStock,
-		"timestamp": time.Now(),
+func sendAnnouncements(client *Client, db *sql.DB) {
+	announcements, err := database.NewAnnouncementService(db).GetActiveAnnouncements()
+	if err != nil {
+		log.Println("Failed to query announcements:", err)
+		return
 	}
 
-	if jsonData, err := json.Marshal(message); err == nil {
-		select {
-		case hub.Broadcast <- jsonData:
-		default:
-		}
+	if len(announcements) > 0 {
+		client.sendEvent(EventAnnouncements, AnnouncementsEvent{Announcements: announcements})
 	}
 }
 
-// BroadcastNotification sends notifications to specific users or all users
-func BroadcastNotification(hub *Hub, userID uuid.UUID, message string, notifType string) {
-	notification := map[string]interface{}{
-		"type":      "notification",
-		"user_id":   userID,
-		"message":   message,
-		"notif_type": notifType,
-		"timestamp": time.Now(),
-	}
+// BroadcastStockUpdate sends a stock update to the clients of the product's organization
+func BroadcastStockUpdate(hub *Hub, organization, productID uuid.UUID, newStock int) {
+	hub.broadcastEvent(Message{Organization: organization}, EventStockChanged, StockChangedEvent{
+		ProductID: productID,
+		NewStock:  newStock,
+	})
+}
 
-	if jsonData, err := json.Marshal(notification); err == nil {
-		select {
-		case hub.Broadcast <- jsonData:
-		default:
-		}
-	}
-}
\ No newline at end of file
+// BroadcastNotification sends a notification to the connections of the user it is for
+func BroadcastNotification(hub *Hub, notification *models.Notification) {
+	hub.broadcastEvent(Message{UserID: notification.UserID.String()}, EventNotification, NotificationEvent{
+		ID:               notification.ID,
+		UserID:           notification.UserID,
+		Message:          notification.Message,
+		NotificationType: string(notification.Type),
+		Priority:         notification.Priority,
+		Metadata:         notification.Metadata,
+	})
+}
+
+// BroadcastAnnouncement pushes a system-wide announcement to all connected clients
+func BroadcastAnnouncement(hub *Hub, announcement models.Announcement) {
+	hub.broadcastEvent(Message{}, EventAnnouncement, AnnouncementEvent{Announcement: announcement})
+}
@@ -1,31 +1,109 @@
 package websocket
 
 import (
+	"context"
+	"encoding/json"
 	"log"
+	"sync"
+	"time"
 
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
+const (
+	// batchInterval is how long WritePump coalesces queued events before
+	// flushing them to the client as a single frame.
+	batchInterval = 250 * time.Millisecond
+
+	// maxBatchSize caps the number of events coalesced into one frame.
+	maxBatchSize = 64
+
+	// reconnectAfter is the delay clients are asked to wait before
+	// reconnecting after a server shutdown.
+	reconnectAfter = 5 * time.Second
+)
+
 type Client struct {
-	ID   string
-	Conn *websocket.Conn
+	ID           string
+	Role         models.UserRole
+	Organization uuid.UUID
+	// Language is the language of the messages of activity events
+	Language     string
+	Conn         *websocket.Conn
+	// Send queues events for WritePump. It is never closed, as the
+	// goroutines sending a client its initial data may still write to it
+	// after the hub dropped the client; they stop once done is closed.
 	Send chan []byte
 	Hub  *Hub
+
+	// closeMessage, when set before done is closed, is written as the close
+	// frame instead of an empty one.
+	closeMessage []byte
+	// done is closed when the hub drops the client, for WritePump to flush
+	// what is queued and close the connection.
+	done chan struct{}
+	// flushed is closed once WritePump has flushed and exited.
+	flushed chan struct{}
+}
+
+// Message is an event queued on Hub.Broadcast, with the clients it is for.
+type Message struct {
+	Data []byte
+	// Organization, unless nil, limits the message to the clients of that
+	// organization.
+	Organization uuid.UUID
+	// UserID, unless empty, limits the message to the connections of that
+	// user.
+	UserID string
+}
+
+// addressedTo reports whether the message is for client.
+func (m Message) addressedTo(client *Client) bool {
+	if m.Organization != uuid.Nil && client.Organization != m.Organization {
+		return false
+	}
+	return m.UserID == "" || client.ID == m.UserID
 }
 
 type Hub struct {
 	Clients    map[*Client]bool
-	Broadcast  chan []byte
+	Broadcast  chan Message
 	Register   chan *Client
 	Unregister chan *Client
+
+	// MaxConnsPerUser limits concurrent connections per user ID. When a user
+	// exceeds it their oldest connection is evicted. Zero disables the limit.
+	MaxConnsPerUser int
+
+	// userClients tracks each user's connections in registration order.
+	userClients map[string][]*Client
+	mu          sync.RWMutex
+
+	shutdown chan chan []*Client
+	closing  bool
+}
+
+// HubStats is a point-in-time snapshot of hub connection counts.
+type HubStats struct {
+	TotalConnections   int `json:"total_connections"`
+	ConnectedUsers     int `json:"connected_users"`
+	MaxUserConnections int `json:"max_user_connections"`
+	MaxConnsPerUser    int `json:"max_conns_per_user"`
 }
 
-func NewHub() *Hub {
+func NewHub(maxConnsPerUser int) *Hub {
 	return &Hub{
-		Clients:    make(map[*Client]bool),
-		Broadcast:  make(chan []byte),
-		Register:   make(chan *Client),
-		Unregister: make(chan *Client),
+		Clients:         make(map[*Client]bool),
+		Broadcast:       make(chan Message),
+		Register:        make(chan *Client),
+		Unregister:      make(chan *Client),
+		MaxConnsPerUser: maxConnsPerUser,
+		userClients:     make(map[string][]*Client),
+		shutdown:        make(chan chan []*Client),
 	}
 }
 
@@ -33,55 +111,292 @@ func (h *Hub) Run() {
 	for {
 		select {
 		case client := <-h.Register:
+			h.mu.Lock()
+			if h.closing {
+				// Turn away late connections while draining
+				client.closeMessage = shutdownCloseMessage()
+				close(client.done)
+				h.mu.Unlock()
+				continue
+			}
+
 			h.Clients[client] = true
+			h.userClients[client.ID] = append(h.userClients[client.ID], client)
+
+			// Evict the oldest connections once the user is over the limit
+			if h.MaxConnsPerUser > 0 {
+				for len(h.userClients[client.ID]) > h.MaxConnsPerUser {
+					oldest := h.userClients[client.ID][0]
+					h.removeClient(oldest)
+					log.Printf("Client %s exceeded %d connections, evicted oldest connection", client.ID, h.MaxConnsPerUser)
+				}
+			}
 			log.Printf("Client %s connected. Total clients: %d", client.ID, len(h.Clients))
+			h.mu.Unlock()
 
 		case client := <-h.Unregister:
+			h.mu.Lock()
 			if _, ok := h.Clients[client]; ok {
-				delete(h.Clients, client)
-				close(client.Send)
+				h.removeClient(client)
 				log.Printf("Client %s disconnected. Total clients: %d", client.ID, len(h.Clients))
 			}
+			h.mu.Unlock()
 
 		case message := <-h.Broadcast:
+			h.mu.Lock()
+			for client := range h.Clients {
+				if !message.addressedTo(client) {
+					continue
+				}
+				select {
+				case client.Send <- message.Data:
+				default:
+					h.removeClient(client)
+				}
+			}
+			h.mu.Unlock()
+
+		case reply := <-h.shutdown:
+			h.mu.Lock()
+			h.closing = true
+
+			notice, _ := encodeEvent(EventServerShutdown, ServerShutdownEvent{
+				ReconnectAfterMs: int(reconnectAfter / time.Millisecond),
+			})
+
+			draining := make([]*Client, 0, len(h.Clients))
 			for client := range h.Clients {
 				select {
-				case client.Send <- message:
+				case client.Send <- notice:
 				default:
-					close(client.Send)
-					delete(h.Clients, client)
 				}
+				client.closeMessage = shutdownCloseMessage()
+				h.removeClient(client)
+				draining = append(draining, client)
 			}
+			log.Printf("WebSocket hub shutting down, draining %d clients", len(draining))
+			h.mu.Unlock()
+
+			reply <- draining
 		}
 	}
 }
 
+// Shutdown stops the hub from accepting new clients, tells every connected
+// client that the server is going away (with a reconnect hint), and waits
+// until their pending events have been flushed or ctx expires.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	reply := make(chan []*Client, 1)
+	select {
+	case h.shutdown <- reply:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	for _, client := range <-reply {
+		if client.flushed == nil {
+			continue
+		}
+		select {
+		case <-client.flushed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	log.Println("WebSocket hub drained")
+	return nil
+}
+
+// IsShuttingDown reports whether Shutdown has been called.
+func (h *Hub) IsShuttingDown() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.closing
+}
+
+func shutdownCloseMessage() []byte {
+	return websocket.FormatCloseMessage(websocket.CloseServiceRestart, "server_shutdown")
+}
+
+// removeClient drops a client from the hub and closes its done channel.
+// Callers must hold h.mu.
+func (h *Hub) removeClient(client *Client) {
+	delete(h.Clients, client)
+	close(client.done)
+
+	conns := h.userClients[client.ID]
+	for i, c := range conns {
+		if c == client {
+			conns = append(conns[:i], conns[i+1:]...)
+			break
+		}
+	}
+	if len(conns) == 0 {
+		delete(h.userClients, client.ID)
+	} else {
+		h.userClients[client.ID] = conns
+	}
+}
+
+// Stats reports current connection counts. It is safe to call from any goroutine.
+func (h *Hub) Stats() HubStats {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	stats := HubStats{
+		TotalConnections: len(h.Clients),
+		ConnectedUsers:   len(h.userClients),
+		MaxConnsPerUser:  h.MaxConnsPerUser,
+	}
+	for _, conns := range h.userClients {
+		if len(conns) > stats.MaxUserConnections {
+			stats.MaxUserConnections = len(conns)
+		}
+	}
+
+	return stats
+}
+
+// RunDashboardStats recomputes dashboard statistics every interval and pushes
+// them to connected admin clients. The stats are computed once per tick for
+// each organization with an admin connected, and shared by its admins. It
+// stops when ctx is done.
+func (h *Hub) RunDashboardStats(ctx context.Context, interval time.Duration, getStats func(ctx context.Context) (map[string]interface{}, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, organization := range h.adminOrganizations() {
+			h.sendDashboardStats(organization, getStats)
+		}
+	}
+}
+
+func (h *Hub) sendDashboardStats(organization uuid.UUID, getStats func(ctx context.Context) (map[string]interface{}, error)) {
+	stats, err := getStats(database.WithOrganization(context.Background(), organization))
+	if err != nil {
+		log.Printf("Failed to compute dashboard stats: %v", err)
+		return
+	}
+
+	jsonData, err := encodeEvent(EventDashboardStats, DashboardStatsEvent{Stats: stats})
+	if err != nil {
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for client := range h.Clients {
+		if client.Role != models.RoleAdmin || client.Organization != organization {
+			continue
+		}
+		select {
+		case client.Send <- jsonData:
+		default:
+		}
+	}
+}
+
+// adminOrganizations returns the organizations with an admin connected.
+func (h *Hub) adminOrganizations() []uuid.UUID {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	seen := make(map[uuid.UUID]bool)
+	var organizations []uuid.UUID
+	for client := range h.Clients {
+		if client.Role == models.RoleAdmin && !seen[client.Organization] {
+			seen[client.Organization] = true
+			organizations = append(organizations, client.Organization)
+		}
+	}
+	return organizations
+}
+
 func (c *Client) WritePump() {
+	ticker := time.NewTicker(batchInterval)
 	defer func() {
+		ticker.Stop()
 		c.Conn.Close()
+		if c.flushed != nil {
+			close(c.flushed)
+		}
 	}()
 
+	var pending [][]byte
 	for {
 		select {
-		case message, ok := <-c.Send:
-			if !ok {
-				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
-				return
+		case <-c.done:
+			// Flush what was queued before the hub dropped the client
+			for drained := false; !drained; {
+				select {
+				case message := <-c.Send:
+					pending = append(pending, message)
+				default:
+					drained = true
+				}
 			}
+			c.flush(pending)
+			closeMessage := c.closeMessage
+			if closeMessage == nil {
+				closeMessage = []byte{}
+			}
+			c.Conn.WriteMessage(websocket.CloseMessage, closeMessage)
+			return
 
-			w, err := c.Conn.NextWriter(websocket.TextMessage)
-			if err != nil {
-				return
+		case message := <-c.Send:
+			pending = append(pending, message)
+			if len(pending) >= maxBatchSize {
+				if err := c.flush(pending); err != nil {
+					return
+				}
+				pending = pending[:0]
 			}
-			w.Write(message)
 
-			if err := w.Close(); err != nil {
+		case <-ticker.C:
+			if len(pending) == 0 {
+				continue
+			}
+			if err := c.flush(pending); err != nil {
 				return
 			}
+			pending = pending[:0]
 		}
 	}
 }
 
+// flush writes the pending events as a single frame.
+func (c *Client) flush(pending [][]byte) error {
+	if len(pending) == 0 {
+		return nil
+	}
+	frame, err := batchFrame(pending)
+	if err != nil {
+		return err
+	}
+	return c.Conn.WriteMessage(websocket.TextMessage, frame)
+}
+
+// batchFrame returns a lone event as-is and wraps several in a batch
+// envelope.
+func batchFrame(pending [][]byte) ([]byte, error) {
+	if len(pending) == 1 {
+		return pending[0], nil
+	}
+	events := make([]json.RawMessage, len(pending))
+	for i, message := range pending {
+		events[i] = message
+	}
+	return encodeEvent(EventBatch, events)
+}
+
 func (c *Client) ReadPump() {
 	defer func() {
 		c.Hub.Unregister <- c
@@ -0,0 +1,235 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func newTestClient(id string, organization uuid.UUID) *Client {
+	return &Client{
+		ID:           id,
+		Role:         models.RoleStaff,
+		Organization: organization,
+		Send:         make(chan []byte, 4),
+		done:         make(chan struct{}),
+	}
+}
+
+// startHub runs a hub whose broadcasts are queued, so events sent with
+// broadcastEvent are not dropped while Run is busy, and registers clients.
+func startHub(clients ...*Client) *Hub {
+	hub := NewHub(0)
+	hub.Broadcast = make(chan Message, 8)
+	go hub.Run()
+	for _, client := range clients {
+		client.Hub = hub
+		hub.Register <- client
+	}
+	settle(hub)
+	return hub
+}
+
+// settle waits until Run has handled what was sent to it before: Run only
+// takes another message once done with the last one, and unregistering a
+// client it does not know changes nothing.
+func settle(hub *Hub) {
+	hub.Unregister <- &Client{}
+}
+
+// received returns the type of every event queued for client.
+func received(client *Client) []EventType {
+	var types []EventType
+	for {
+		select {
+		case message, ok := <-client.Send:
+			if !ok {
+				return types
+			}
+			var envelope Envelope
+			json.Unmarshal(message, &envelope)
+			types = append(types, envelope.Type)
+		default:
+			return types
+		}
+	}
+}
+
+// waitFor waits until client has been sent an event.
+func waitFor(t *testing.T, client *Client) {
+	t.Helper()
+	deadline := time.After(time.Second)
+	for len(client.Send) == 0 {
+		select {
+		case <-deadline:
+			t.Fatalf("client %s received nothing", client.ID)
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func TestBroadcastIsolatesOrganizations(t *testing.T) {
+	organization, other := uuid.New(), uuid.New()
+	alice := newTestClient(uuid.New().String(), organization)
+	bob := newTestClient(uuid.New().String(), organization)
+	carol := newTestClient(uuid.New().String(), other)
+	hub := startHub(alice, bob, carol)
+
+	BroadcastStockUpdate(hub, organization, uuid.New(), 3)
+	waitFor(t, alice)
+	waitFor(t, bob)
+	// Stats waits for the broadcast to reach every client
+	hub.Stats()
+	if types := received(carol); len(types) != 0 {
+		t.Errorf("expected the other organization to receive nothing, got %v", types)
+	}
+	received(alice)
+	received(bob)
+
+	BroadcastNotification(hub, &models.Notification{ID: uuid.New(), UserID: uuid.MustParse(bob.ID), Message: "Low stock"})
+	waitFor(t, bob)
+	hub.Stats()
+	if types := received(bob); len(types) != 1 || types[0] != EventNotification {
+		t.Errorf("expected bob to receive the notification, got %v", types)
+	}
+	if types := received(alice); len(types) != 0 {
+		t.Errorf("expected alice to receive nothing, got %v", types)
+	}
+	if types := received(carol); len(types) != 0 {
+		t.Errorf("expected carol to receive nothing, got %v", types)
+	}
+
+	BroadcastAnnouncement(hub, models.Announcement{ID: uuid.New(), Title: "Maintenance"})
+	for _, client := range []*Client{alice, bob, carol} {
+		waitFor(t, client)
+	}
+}
+
+func TestSendEventAfterUnregister(t *testing.T) {
+	client := newTestClient(uuid.New().String(), uuid.New())
+	hub := startHub(client)
+
+	// The initial data goroutine is still sending when the client goes
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		for i := 0; i < 10; i++ {
+			client.sendEvent(EventSystemStatus, SystemStatusEvent{})
+		}
+	}()
+	hub.Unregister <- client
+
+	select {
+	case <-sent:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("expected sendEvent to give up once the client was dropped")
+	}
+	settle(hub)
+	select {
+	case <-client.done:
+	default:
+		t.Error("expected the client to be marked done")
+	}
+	if stats := hub.Stats(); stats.TotalConnections != 0 {
+		t.Errorf("expected no connections, got %d", stats.TotalConnections)
+	}
+}
+
+func TestMaxConnsPerUserEvictsOldest(t *testing.T) {
+	hub := NewHub(2)
+	go hub.Run()
+
+	user := uuid.New().String()
+	organization := uuid.New()
+	oldest := newTestClient(user, organization)
+	middle := newTestClient(user, organization)
+	newest := newTestClient(user, organization)
+	other := newTestClient(uuid.New().String(), organization)
+	for _, client := range []*Client{oldest, middle, newest, other} {
+		client.Hub = hub
+		hub.Register <- client
+	}
+	settle(hub)
+
+	stats := hub.Stats()
+	want := HubStats{TotalConnections: 3, ConnectedUsers: 2, MaxUserConnections: 2, MaxConnsPerUser: 2}
+	if stats != want {
+		t.Errorf("expected %+v, got %+v", want, stats)
+	}
+	select {
+	case <-oldest.done:
+	default:
+		t.Error("expected the oldest connection to be evicted")
+	}
+	for _, client := range []*Client{middle, newest, other} {
+		select {
+		case <-client.done:
+			t.Errorf("expected client %s to stay connected", client.ID)
+		default:
+		}
+	}
+
+	hub.Unregister <- middle
+	hub.Unregister <- other
+	settle(hub)
+	stats = hub.Stats()
+	want = HubStats{TotalConnections: 1, ConnectedUsers: 1, MaxUserConnections: 1, MaxConnsPerUser: 2}
+	if stats != want {
+		t.Errorf("expected %+v after disconnecting, got %+v", want, stats)
+	}
+}
+
+func TestBroadcastEvictsSlowClients(t *testing.T) {
+	organization := uuid.New()
+	slow := newTestClient(uuid.New().String(), organization)
+	fast := newTestClient(uuid.New().String(), organization)
+	// The slow client's WritePump has stopped reading, filling its buffer
+	for len(slow.Send) < cap(slow.Send) {
+		slow.Send <- []byte("{}")
+	}
+	hub := startHub(slow, fast)
+
+	BroadcastStockUpdate(hub, organization, uuid.New(), 1)
+	waitFor(t, fast)
+
+	select {
+	case <-slow.done:
+	case <-time.After(time.Second):
+		t.Fatal("expected the slow client to be evicted")
+	}
+	stats := hub.Stats()
+	want := HubStats{TotalConnections: 1, ConnectedUsers: 1, MaxUserConnections: 1}
+	if stats != want {
+		t.Errorf("expected %+v, got %+v", want, stats)
+	}
+}
+
+func TestBatchFrame(t *testing.T) {
+	stock, _ := encodeEvent(EventStockChanged, StockChangedEvent{})
+	notification, _ := encodeEvent(EventNotification, nil)
+
+	frame, err := batchFrame([][]byte{stock})
+	if err != nil || string(frame) != string(stock) {
+		t.Errorf("expected a lone event sent as-is, got %s, %v", frame, err)
+	}
+
+	frame, err = batchFrame([][]byte{stock, notification})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var batch struct {
+		Type EventType  `json:"type"`
+		Data []Envelope `json:"data"`
+	}
+	if err := json.Unmarshal(frame, &batch); err != nil {
+		t.Fatalf("expected an envelope, got %s: %v", frame, err)
+	}
+	if batch.Type != EventBatch || len(batch.Data) != 2 ||
+		batch.Data[0].Type != EventStockChanged || batch.Data[1].Type != EventNotification {
+		t.Errorf("expected a batch of both events, got %s", frame)
+	}
+}
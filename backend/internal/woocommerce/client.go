@@ -0,0 +1,188 @@
+package woocommerce
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// perPage is the largest page the REST API returns.
+	perPage = 100
+	// maxErrorBody is how much of an error response is kept in its error.
+	maxErrorBody = 512
+)
+
+// ErrProductNotFound is returned for a product or variation the store does
+// not have.
+var ErrProductNotFound = errors.New("WooCommerce product not found")
+
+// APIError is a response of the REST API outside 2xx.
+type APIError struct {
+	Status int
+	Body   string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("WooCommerce returned status %d: %s", e.Status, e.Body)
+}
+
+// Item is a product or variation of a store. ParentID is the variable
+// product of a variation, 0 for products.
+type Item struct {
+	ID       int64  `json:"id"`
+	ParentID int64  `json:"-"`
+	Type     string `json:"type"`
+	SKU      string `json:"sku"`
+	// ManageStock is true, false or, for variations that share the stock
+	// of their product, "parent".
+	ManageStock   interface{} `json:"manage_stock"`
+	StockQuantity *int        `json:"stock_quantity"`
+}
+
+// Tracked reports whether the item keeps its own stock quantity.
+func (i Item) Tracked() bool {
+	return i.ManageStock == true && i.StockQuantity != nil
+}
+
+func (i Item) path() string {
+	if i.ParentID != 0 {
+		return fmt.Sprintf("/products/%d/variations/%d", i.ParentID, i.ID)
+	}
+	return fmt.Sprintf("/products/%d", i.ID)
+}
+
+// Client calls the REST API of stores.
+type Client struct {
+	httpClient *http.Client
+	// baseURL returns the API root of store, which tests point at a fake.
+	baseURL func(store Store) string
+}
+
+func NewClient() *Client {
+	return &Client{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		baseURL: func(store Store) string {
+			return store.URL + "/wp-json/wc/v3"
+		},
+	}
+}
+
+// Items returns every product of store and the variations of its variable
+// products.
+func (c *Client) Items(ctx context.Context, store Store) ([]Item, error) {
+	products, err := c.list(ctx, store, "/products", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]Item, 0, len(products))
+	for _, product := range products {
+		items = append(items, product)
+		if product.Type != "variable" {
+			continue
+		}
+		variations, err := c.list(ctx, store, fmt.Sprintf("/products/%d/variations", product.ID), product.ID)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, variations...)
+	}
+	return items, nil
+}
+
+// list reads every page of a collection, marking its items as variations
+// of parentID if it is not 0.
+func (c *Client) list(ctx context.Context, store Store, path string, parentID int64) ([]Item, error) {
+	var items []Item
+	for page := 1; ; page++ {
+		var batch []Item
+		url := fmt.Sprintf("%s%s?per_page=%d&page=%d", c.baseURL(store), path, perPage, page)
+		resp, err := c.do(ctx, store, http.MethodGet, url, nil, &batch)
+		if err != nil {
+			return nil, err
+		}
+		for i := range batch {
+			batch[i].ParentID = parentID
+		}
+		items = append(items, batch...)
+
+		pages, _ := strconv.Atoi(resp.Header.Get("X-WP-TotalPages"))
+		if len(batch) < perPage || page >= pages {
+			return items, nil
+		}
+	}
+}
+
+// Item returns the current state of a product or variation.
+func (c *Client) Item(ctx context.Context, store Store, id, parentID int64) (*Item, error) {
+	item := Item{ID: id, ParentID: parentID}
+	_, err := c.do(ctx, store, http.MethodGet, c.baseURL(store)+item.path(), nil, &item)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound {
+		return nil, ErrProductNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	item.ParentID = parentID
+	return &item, nil
+}
+
+// SetStock sets the stock quantity of a product or variation.
+func (c *Client) SetStock(ctx context.Context, store Store, id, parentID int64, quantity int) error {
+	item := Item{ID: id, ParentID: parentID}
+	body := map[string]interface{}{"manage_stock": true, "stock_quantity": quantity}
+	_, err := c.do(ctx, store, http.MethodPut, c.baseURL(store)+item.path(), body, nil)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound {
+		return ErrProductNotFound
+	}
+	return err
+}
+
+// do sends a request authenticated with store's API key and decodes a 2xx
+// response into out, if it is given.
+func (c *Client) do(ctx context.Context, store Store, method, url string, in, out interface{}) (*http.Response, error) {
+	var body io.Reader
+	if in != nil {
+		encoded, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(store.ConsumerKey, store.ConsumerSecret)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return nil, &APIError{Status: resp.StatusCode, Body: string(message)}
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return nil, fmt.Errorf("invalid WooCommerce response: %w", err)
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,115 @@
+package woocommerce
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func testClient(server *httptest.Server) *Client {
+	return &Client{httpClient: server.Client(), baseURL: func(Store) string { return server.URL }}
+}
+
+var testStore = Store{Name: "main", URL: "https://shop.example.com", ConsumerKey: "ck_test", ConsumerSecret: "cs_test"}
+
+func TestItems(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key, secret, ok := r.BasicAuth(); !ok || key != "ck_test" || secret != "cs_test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("X-WP-TotalPages", "1")
+		switch r.URL.Path {
+		case "/products":
+			fmt.Fprint(w, `[{"id": 1, "type": "simple", "sku": "A", "manage_stock": true, "stock_quantity": 5},
+				{"id": 2, "type": "variable", "sku": "", "manage_stock": false, "stock_quantity": null}]`)
+		case "/products/2/variations":
+			fmt.Fprint(w, `[{"id": 3, "sku": "B", "manage_stock": true, "stock_quantity": 2},
+				{"id": 4, "sku": "C", "manage_stock": "parent", "stock_quantity": 9}]`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	items, err := testClient(server).Items(context.Background(), testStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 4 || items[2].ID != 3 || items[2].ParentID != 2 || *items[2].StockQuantity != 2 {
+		t.Fatalf("expected the products and the variations of the variable one, got %+v", items)
+	}
+	for i, tracked := range []bool{true, false, true, false} {
+		if items[i].Tracked() != tracked {
+			t.Errorf("expected item %d tracked to be %v", items[i].ID, tracked)
+		}
+	}
+}
+
+func TestItemsPages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-WP-TotalPages", "2")
+		page := r.URL.Query().Get("page")
+		items := make([]map[string]interface{}, 0, perPage)
+		for i := 0; i < perPage && (page == "1" || i < 3); i++ {
+			items = append(items, map[string]interface{}{"id": len(items) + 1, "type": "simple"})
+		}
+		json.NewEncoder(w).Encode(items)
+	}))
+	defer server.Close()
+
+	items, err := testClient(server).Items(context.Background(), testStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != perPage+3 {
+		t.Errorf("expected the items of both pages, got %d", len(items))
+	}
+}
+
+func TestSetStock(t *testing.T) {
+	var path string
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&body)
+		fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+
+	if err := testClient(server).SetStock(context.Background(), testStore, 3, 2, 7); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/products/2/variations/3" || body["stock_quantity"] != float64(7) || body["manage_stock"] != true {
+		t.Errorf("unexpected request to %s with %v", path, body)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/products/9" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"code": "woocommerce_rest_cannot_view"}`)
+	}))
+	defer server.Close()
+	client := testClient(server)
+
+	if _, err := client.Item(context.Background(), testStore, 9, 0); !errors.Is(err, ErrProductNotFound) {
+		t.Errorf("expected ErrProductNotFound, got %v", err)
+	}
+	var apiErr *APIError
+	if _, err := client.Items(context.Background(), testStore); !errors.As(err, &apiErr) || apiErr.Status != http.StatusUnauthorized {
+		t.Errorf("expected a 401 APIError, got %v", err)
+	}
+}
@@ -0,0 +1,396 @@
+package woocommerce
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// runRetention is how long reconciliation runs are kept.
+const runRetention = 30 * 24 * time.Hour
+
+// ErrConflictResolved is returned when resolving a conflict that is
+// already resolved.
+var ErrConflictResolved = errors.New("WooCommerce conflict is already resolved")
+
+// ErrStockNotTracked is returned when resolving a conflict in favour of a
+// WooCommerce product that no longer tracks its stock.
+var ErrStockNotTracked = errors.New("WooCommerce product does not track its stock")
+
+// Syncer reconciles the stock of the configured stores with RTIMS and
+// resolves the conflicts it finds.
+type Syncer struct {
+	wooCommerceService  *database.WooCommerceService
+	settingsService     *database.SettingsService
+	userService         *database.UserService
+	organizationService *database.OrganizationService
+	productService      *database.ProductService
+	client              *Client
+}
+
+func NewSyncer(db *sql.DB, cache *database.Cache) *Syncer {
+	return &Syncer{
+		wooCommerceService:  database.NewWooCommerceService(db),
+		settingsService:     database.NewSettingsService(db),
+		userService:         database.NewUserService(db),
+		organizationService: database.NewOrganizationService(db),
+		productService:      database.NewCachedProductService(db, cache),
+		client:              NewClient(),
+	}
+}
+
+// Stores returns the configured stores.
+func (s *Syncer) Stores() (map[string]Store, error) {
+	settings, err := s.settingsService.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	value, _ := settings[SettingStores].(string)
+	return ParseStores(value)
+}
+
+// Store returns the configuration of the store called name.
+func (s *Syncer) Store(name string) (Store, error) {
+	stores, err := s.Stores()
+	if err != nil {
+		return Store{}, err
+	}
+	store, ok := stores[name]
+	if !ok {
+		return Store{}, ErrUnknownStore
+	}
+	return store, nil
+}
+
+// Status returns the configured stores with the state of their sync, and
+// the conflicts waiting to be resolved.
+func (s *Syncer) Status(ctx context.Context) (*models.WooCommerceStatus, error) {
+	stores, err := s.Stores()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &models.WooCommerceStatus{Stores: make([]models.WooCommerceStore, 0, len(stores))}
+	for _, store := range stores {
+		state := models.WooCommerceStore{Name: store.Name, URL: store.URL, UserEmail: store.UserEmail}
+		if err := s.wooCommerceService.GetWooCommerceStoreStatus(ctx, &state); err != nil {
+			return nil, err
+		}
+		status.Stores = append(status.Stores, state)
+	}
+	sort.Slice(status.Stores, func(i, j int) bool { return status.Stores[i].Name < status.Stores[j].Name })
+
+	if status.Conflicts, err = s.wooCommerceService.GetOpenWooCommerceConflicts(ctx, ""); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// account returns the user a store acts as, and ctx scoped to the user's
+// organization, which must be active.
+func (s *Syncer) account(ctx context.Context, store Store) (context.Context, *models.User, error) {
+	user, err := s.userService.GetUserByEmail(ctx, store.UserEmail)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, fmt.Errorf("%w: no account for %s", ErrStoreAccount, store.UserEmail)
+		}
+		return nil, nil, err
+	}
+	if !user.IsActive {
+		return nil, nil, fmt.Errorf("%w: %s is deactivated", ErrStoreAccount, store.UserEmail)
+	}
+
+	organizationID := user.OrganizationID
+	if organizationID == uuid.Nil {
+		organizationID = database.DefaultOrganizationID
+	}
+	organization, err := s.organizationService.GetOrganization(ctx, organizationID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !organization.IsActive {
+		return nil, nil, fmt.Errorf("%w: organization %s is deactivated", ErrStoreAccount, organization.Name)
+	}
+	return database.WithOrganization(ctx, organizationID), user, nil
+}
+
+// Reconcile reconciles every configured store and purges old runs. It is
+// the scheduled job; a store that fails is logged and recorded as a failed
+// run without stopping the others.
+func (s *Syncer) Reconcile() error {
+	stores, err := s.Stores()
+	if err != nil {
+		return fmt.Errorf("failed to read WooCommerce stores: %w", err)
+	}
+
+	names := make([]string, 0, len(stores))
+	for name := range stores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	failed := 0
+	for _, name := range names {
+		run, err := s.reconcile(context.Background(), stores[name])
+		if err != nil {
+			log.Printf("WooCommerce reconciliation of %s failed: %v", name, err)
+			failed++
+			continue
+		}
+		if run.Pushed > 0 || run.Pulled > 0 || run.Conflicts > 0 || run.Failed > 0 {
+			log.Printf("WooCommerce reconciliation of %s: %d checked, %d pushed, %d pulled, %d conflicts, %d failed",
+				name, run.Checked, run.Pushed, run.Pulled, run.Conflicts, run.Failed)
+		}
+	}
+
+	if _, err := s.wooCommerceService.PurgeWooCommerceRuns(context.Background(), time.Now().Add(-runRetention)); err != nil {
+		log.Printf("Failed to purge WooCommerce runs: %v", err)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d WooCommerce stores failed to reconcile", failed, len(names))
+	}
+	return nil
+}
+
+// ReconcileStore reconciles the store called name now.
+func (s *Syncer) ReconcileStore(ctx context.Context, name string) (*models.WooCommerceRun, error) {
+	store, err := s.Store(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.reconcile(ctx, store)
+}
+
+// reconcile maps every product of store that tracks its stock to the
+// product with its SKU, and syncs the stock of each that changed on one
+// side only. The run is recorded whether it succeeds or not.
+func (s *Syncer) reconcile(ctx context.Context, store Store) (*models.WooCommerceRun, error) {
+	run := &models.WooCommerceRun{ID: uuid.New(), Store: store.Name, StartedAt: time.Now()}
+	err := s.reconcileItems(ctx, store, run)
+	run.FinishedAt = time.Now()
+	if err != nil {
+		run.Error = err.Error()
+	}
+	if recordErr := s.wooCommerceService.CreateWooCommerceRun(context.Background(), run); recordErr != nil {
+		log.Printf("Failed to record WooCommerce run of %s: %v", store.Name, recordErr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+func (s *Syncer) reconcileItems(ctx context.Context, store Store, run *models.WooCommerceRun) error {
+	ctx, user, err := s.account(ctx, store)
+	if err != nil {
+		return err
+	}
+
+	items, err := s.client.Items(ctx, store)
+	if err != nil {
+		return err
+	}
+	var skus []string
+	for _, item := range items {
+		if item.SKU != "" {
+			skus = append(skus, item.SKU)
+		}
+	}
+	products, err := s.productService.GetProductsBySKU(ctx, skus)
+	if err != nil {
+		return err
+	}
+	mappings, err := s.wooCommerceService.GetWooCommerceMappings(ctx, store.Name)
+	if err != nil {
+		return err
+	}
+	open, err := s.wooCommerceService.GetOpenWooCommerceConflicts(ctx, store.Name)
+	if err != nil {
+		return err
+	}
+	conflicts := make(map[int64]models.WooCommerceConflict, len(open))
+	for _, conflict := range open {
+		conflicts[conflict.WooID] = conflict
+	}
+
+	matched := []int64{}
+	for _, item := range items {
+		product, ok := products[item.SKU]
+		if item.SKU == "" || !ok || !item.Tracked() {
+			continue
+		}
+		run.Checked++
+		matched = append(matched, item.ID)
+
+		// A product whose SKU now matches another product starts over
+		mapping, ok := mappings[item.ID]
+		if !ok || mapping.ProductID != product.ID {
+			mapping = models.WooCommerceMapping{Store: store.Name, WooID: item.ID, ProductID: product.ID}
+		}
+		mapping.ParentID = item.ParentID
+		mapping.SKU = item.SKU
+
+		var conflict *models.WooCommerceConflict
+		if c, ok := conflicts[item.ID]; ok {
+			conflict = &c
+		}
+		if err := s.reconcileItem(ctx, store, user.ID, item, product, &mapping, conflict, run); err != nil {
+			log.Printf("Failed to reconcile WooCommerce product %d of %s: %v", item.ID, store.Name, err)
+			run.Failed++
+		}
+	}
+
+	return s.wooCommerceService.DeleteStaleWooCommerceMappings(ctx, store.Name, matched)
+}
+
+// reconcileItem syncs the stock of one mapped product. While the product
+// has an open conflict it is left alone, until someone resolves it or both
+// sides come to agree.
+func (s *Syncer) reconcileItem(ctx context.Context, store Store, userID uuid.UUID, item Item, product models.Product,
+	mapping *models.WooCommerceMapping, conflict *models.WooCommerceConflict, run *models.WooCommerceRun) error {
+	rtims, woo := product.Stock, *item.StockQuantity
+
+	action := Decide(rtims, woo, mapping.SyncedStock)
+	if conflict != nil && action != ActionInSync {
+		action = ActionConflict
+	}
+
+	synced := rtims
+	switch action {
+	case ActionPush:
+		if err := s.client.SetStock(ctx, store, item.ID, item.ParentID, rtims); err != nil {
+			return err
+		}
+		run.Pushed++
+	case ActionPull:
+		change := woo - rtims
+		reason := models.ReasonAdjustment
+		if change < 0 {
+			reason = models.ReasonSale
+		}
+		notes := fmt.Sprintf("WooCommerce sync (%s)", store.Name)
+		_, err := s.productService.UpdateProductStock(ctx, product.ID, change, reason, userID, notes)
+		if errors.Is(err, database.ErrInsufficientStock) {
+			// WooCommerce allows backorders to take stock below zero,
+			// which RTIMS cannot follow
+			action = ActionConflict
+			break
+		}
+		if err != nil {
+			return err
+		}
+		synced = woo
+		run.Pulled++
+	}
+
+	if action == ActionConflict {
+		if conflict == nil {
+			conflict = &models.WooCommerceConflict{ID: uuid.New(), Store: store.Name, WooID: item.ID}
+		}
+		conflict.ProductID = product.ID
+		conflict.SKU = item.SKU
+		conflict.RTIMSStock = rtims
+		conflict.WooStock = woo
+		conflict.SyncedStock = mapping.SyncedStock
+		run.Conflicts++
+		// The mapping is saved without a new synced stock, so the product
+		// counts as mapped while the conflict is open
+		if err := s.wooCommerceService.SaveWooCommerceMapping(ctx, mapping); err != nil {
+			return err
+		}
+		return s.wooCommerceService.SaveWooCommerceConflict(ctx, conflict)
+	}
+
+	if conflict != nil {
+		if err := s.wooCommerceService.ResolveWooCommerceConflict(ctx, conflict.ID, models.ResolutionInSync, nil); err != nil {
+			return err
+		}
+	}
+	if mapping.SyncedStock != nil && *mapping.SyncedStock == synced && mapping.SyncedAt != nil {
+		return nil
+	}
+	now := time.Now()
+	mapping.SyncedStock = &synced
+	mapping.SyncedAt = &now
+	return s.wooCommerceService.SaveWooCommerceMapping(ctx, mapping)
+}
+
+// ResolveConflict resolves an open conflict by giving both sides the
+// current stock of the side to keep. Stock taken from WooCommerce is
+// recorded as an adjustment by userID.
+func (s *Syncer) ResolveConflict(ctx context.Context, id uuid.UUID, keep models.WooCommerceResolution, userID uuid.UUID) (*models.WooCommerceConflict, error) {
+	conflict, err := s.wooCommerceService.GetWooCommerceConflict(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if conflict.ResolvedAt != nil {
+		return nil, ErrConflictResolved
+	}
+	store, err := s.Store(conflict.Store)
+	if err != nil {
+		return nil, err
+	}
+	ctx, _, err = s.account(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+
+	mappings, err := s.wooCommerceService.GetWooCommerceMappings(ctx, store.Name)
+	if err != nil {
+		return nil, err
+	}
+	mapping, ok := mappings[conflict.WooID]
+	if !ok {
+		return nil, fmt.Errorf("WooCommerce mapping %w", database.ErrNotFound)
+	}
+	product, err := s.productService.GetProduct(ctx, conflict.ProductID)
+	if err != nil {
+		return nil, err
+	}
+
+	var synced int
+	switch keep {
+	case models.ResolutionKeepRTIMS:
+		if err := s.client.SetStock(ctx, store, mapping.WooID, mapping.ParentID, product.Stock); err != nil {
+			return nil, err
+		}
+		synced = product.Stock
+	case models.ResolutionKeepWooCommerce:
+		item, err := s.client.Item(ctx, store, mapping.WooID, mapping.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		if !item.Tracked() {
+			return nil, ErrStockNotTracked
+		}
+		synced = *item.StockQuantity
+		if change := synced - product.Stock; change != 0 {
+			notes := fmt.Sprintf("WooCommerce conflict resolved (%s)", store.Name)
+			if _, err := s.productService.UpdateProductStock(ctx, product.ID, change, models.ReasonAdjustment, userID, notes); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown WooCommerce resolution %q", keep)
+	}
+
+	now := time.Now()
+	mapping.SyncedStock = &synced
+	mapping.SyncedAt = &now
+	if err := s.wooCommerceService.SaveWooCommerceMapping(ctx, &mapping); err != nil {
+		return nil, err
+	}
+	if err := s.wooCommerceService.ResolveWooCommerceConflict(ctx, id, keep, &userID); err != nil {
+		return nil, err
+	}
+	return s.wooCommerceService.GetWooCommerceConflict(ctx, id)
+}
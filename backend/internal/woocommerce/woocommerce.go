@@ -0,0 +1,120 @@
+// Package woocommerce keeps stock in sync with the WooCommerce stores
+// configured in settings. Store products are mapped to RTIMS products by
+// SKU, and a scheduled reconciliation copies stock changes made on either
+// side to the other, holding back products changed on both as conflicts
+// for an admin to resolve.
+package woocommerce
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SettingStores holds a JSON object of the stores to sync, by a name of
+// lowercase letters, digits, hyphens and underscores, e.g. {"main":
+// {"url": "https://shop.example.com", "consumer_key": "ck_…",
+// "consumer_secret": "cs_…", "user_email": "woo@example.com"}}. Each store
+// syncs the products of the organization of its user, who the movements
+// it brings in are recorded by.
+const SettingStores = "woocommerce_stores"
+
+// ErrUnknownStore is returned for a store that is not configured.
+var ErrUnknownStore = errors.New("WooCommerce store is not configured")
+
+// ErrStoreAccount is returned when the user a store acts as cannot be used:
+// there is no such account, or it or its organization is deactivated.
+var ErrStoreAccount = errors.New("WooCommerce store account is unavailable")
+
+var storeName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,99}$`)
+
+// Store is the configuration of one WooCommerce store. The consumer key
+// and secret are those of a REST API key with read/write permissions.
+type Store struct {
+	Name           string `json:"-"`
+	URL            string `json:"url"`
+	ConsumerKey    string `json:"consumer_key"`
+	ConsumerSecret string `json:"consumer_secret"`
+	UserEmail      string `json:"user_email"`
+}
+
+// ParseStores parses the SettingStores value, checking that every store is
+// complete.
+func ParseStores(value string) (map[string]Store, error) {
+	stores := map[string]Store{}
+	if strings.TrimSpace(value) == "" {
+		return stores, nil
+	}
+	if err := json.Unmarshal([]byte(value), &stores); err != nil {
+		return nil, errors.New("must be an object mapping store names to stores")
+	}
+
+	names := make([]string, 0, len(stores))
+	for name := range stores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		store := stores[name]
+		store.Name = name
+		var missing []string
+		if store.ConsumerKey == "" {
+			missing = append(missing, "consumer_key")
+		}
+		if store.ConsumerSecret == "" {
+			missing = append(missing, "consumer_secret")
+		}
+		if !strings.Contains(store.UserEmail, "@") {
+			missing = append(missing, "user_email")
+		}
+		parsed, err := url.Parse(store.URL)
+		switch {
+		case !storeName.MatchString(name):
+			return nil, fmt.Errorf("%q is not a store name of lowercase letters, digits, hyphens and underscores", name)
+		case err != nil || parsed.Scheme != "https" || parsed.Host == "":
+			return nil, fmt.Errorf("%s needs an https url", name)
+		case len(missing) > 0:
+			return nil, fmt.Errorf("%s needs %s", name, strings.Join(missing, ", "))
+		}
+		store.URL = strings.TrimRight(store.URL, "/")
+		store.UserEmail = strings.ToLower(store.UserEmail)
+		stores[name] = store
+	}
+	return stores, nil
+}
+
+// Action is what reconciliation does with a mapped product.
+type Action string
+
+const (
+	// ActionInSync leaves a product both sides agree on.
+	ActionInSync Action = "in_sync"
+	// ActionPush copies the RTIMS stock to WooCommerce.
+	ActionPush Action = "push"
+	// ActionPull moves the RTIMS stock to the WooCommerce stock.
+	ActionPull Action = "pull"
+	// ActionConflict holds a product back for an admin to resolve.
+	ActionConflict Action = "conflict"
+)
+
+// Decide works out which side changed a product's stock by comparing both
+// with the stock they last agreed on, synced, nil if they never have. Only
+// a change on one side can be copied to the other.
+func Decide(rtims, woo int, synced *int) Action {
+	switch {
+	case rtims == woo:
+		return ActionInSync
+	case synced == nil:
+		return ActionConflict
+	case woo == *synced:
+		return ActionPush
+	case rtims == *synced:
+		return ActionPull
+	}
+	return ActionConflict
+}
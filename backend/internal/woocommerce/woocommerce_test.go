@@ -0,0 +1,59 @@
+package woocommerce
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseStores(t *testing.T) {
+	stores, err := ParseStores(`{"main": {"url": "https://shop.example.com/", "consumer_key": "ck_1", "consumer_secret": "cs_1", "user_email": "Woo@Example.com"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := stores["main"]
+	if store.Name != "main" || store.URL != "https://shop.example.com" || store.UserEmail != "woo@example.com" {
+		t.Errorf("unexpected store %+v", store)
+	}
+
+	if stores, err := ParseStores(""); err != nil || len(stores) != 0 {
+		t.Errorf("expected no stores for an empty value, got %v, %v", stores, err)
+	}
+
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"not an object", `["main"]`, "must be an object"},
+		{"bad name", `{"Main Store": {"url": "https://shop.example.com", "consumer_key": "k", "consumer_secret": "s", "user_email": "a@b.c"}}`, "not a store name"},
+		{"plain http", `{"main": {"url": "http://shop.example.com", "consumer_key": "k", "consumer_secret": "s", "user_email": "a@b.c"}}`, "needs an https url"},
+		{"incomplete", `{"main": {"url": "https://shop.example.com", "consumer_key": "k"}}`, "needs consumer_secret, user_email"},
+	}
+	for _, tt := range tests {
+		if _, err := ParseStores(tt.value); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestDecide(t *testing.T) {
+	synced := 10
+	tests := []struct {
+		name       string
+		rtims, woo int
+		synced     *int
+		want       Action
+	}{
+		{"agree", 7, 7, &synced, ActionInSync},
+		{"agree when first mapped", 7, 7, nil, ActionInSync},
+		{"differ when first mapped", 7, 8, nil, ActionConflict},
+		{"changed in RTIMS", 7, 10, &synced, ActionPush},
+		{"changed in WooCommerce", 10, 4, &synced, ActionPull},
+		{"changed on both sides", 7, 4, &synced, ActionConflict},
+	}
+	for _, tt := range tests {
+		if got := Decide(tt.rtims, tt.woo, tt.synced); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
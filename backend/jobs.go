@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"rtims-backend/config"
+	"rtims-backend/internal/accounting"
+	"rtims-backend/internal/alerting"
+	"rtims-backend/internal/audit"
+	"rtims-backend/internal/backup"
+	"rtims-backend/internal/dashboard"
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/deletion"
+	"rtims-backend/internal/edi"
+	"rtims-backend/internal/files"
+	"rtims-backend/internal/ingest"
+	"rtims-backend/internal/notify"
+	"rtims-backend/internal/reports"
+	"rtims-backend/internal/scheduler"
+	"rtims-backend/internal/sheets"
+	"rtims-backend/internal/storage"
+	"rtims-backend/internal/woocommerce"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// newJobScheduler registers the recurring background jobs with their
+// default schedules. Admins can change a schedule, or turn a job off, with
+// the schedule_<job> system setting. A sync job that fails raises a
+// sync_failed alert.
+func newJobScheduler(cfg *config.Config, db *sql.DB, redisClient *redis.Client, dispatcher *notify.Dispatcher,
+	reportScheduler *reports.Scheduler, backups *backup.Runner, woo *woocommerce.Syncer,
+	exporter *accounting.Exporter, ediSender *edi.Sender, importer *ingest.Importer, reportQueue *reports.Queue,
+	library *files.Library, sheetsExporter *sheets.Exporter, alerts *alerting.Router, deletions *deletion.Runner,
+	store storage.Store) *scheduler.Scheduler {
+	jobs := scheduler.New(db, redisClient)
+
+	// Run scheduled reports and email them to their recipients
+	jobs.Add("reports", "* * * * *", reportScheduler.RunDue)
+
+	// Send the daily low stock digest in place of per-movement alerts
+	if dispatcher.LowStockDigest {
+		jobs.Add("low_stock_digest", fmt.Sprintf("0 %d * * *", cfg.NotificationDigestHour), func() error {
+			return notify.SendLowStockDigest(db, dispatcher, cfg.NotificationDigestRoles)
+		})
+	}
+
+	// Purge old read notifications
+	if cfg.NotificationRetention > 0 {
+		jobs.Add("notification_retention", "0 * * * *", func() error {
+			return notify.PurgeRetention(db, cfg.NotificationRetention)
+		})
+	}
+
+	// Purge outbox events a week after they were published
+	jobs.Add("outbox_purge", "15 3 * * *", func() error {
+		purged, err := database.NewOutboxService(db).PurgePublished(context.Background(), time.Now().AddDate(0, 0, -7))
+		if err != nil {
+			return err
+		}
+		if purged > 0 {
+			log.Printf("Outbox purge removed %d published events", purged)
+		}
+		return nil
+	})
+
+	// Purge (and optionally archive) audit logs past their retention period
+	jobs.Add("audit_retention", "30 * * * *", func() error {
+		return audit.PurgeRetention(db, store)
+	})
+
+	// Keep monthly audit log and stock movement partitions created ahead
+	// of time
+	jobs.Add("partition_maintenance", "0 0 * * *", func() error {
+		return database.EnsurePartitions(context.Background(), db, time.Now(), database.PartitionsAhead)
+	})
+
+	// Record dashboard snapshots for trend charts; the last run of the day
+	// leaves the day's closing figures
+	jobs.Add("dashboard_snapshot", "59 * * * *", func() error {
+		return dashboard.RecordSnapshot(db)
+	})
+
+	// Back up the database as often as backup_frequency says, unless
+	// auto_backup is off; the job waits for the dump to be stored, so a
+	// failed backup is logged as a failed job
+	jobs.AddFromSettings("backup", backup.Schedule, func() error {
+		_, err := backups.RunNow(nil)
+		return err
+	})
+
+	// Reconcile stock with the WooCommerce stores, pushing and pulling
+	// changes made on one side and recording conflicts for the others
+	jobs.Add("woocommerce_reconcile", "*/15 * * * *", alerts.Watch("woocommerce_reconcile", woo.Reconcile))
+
+	// Export the previous month's journal on the 1st, unless
+	// accounting_auto_export is off
+	jobs.AddFromSettings("accounting_export", accounting.Schedule, alerts.Watch("accounting_export", exporter.ExportMonthly))
+
+	// Send the trading partners their EDI 846 inventory advice
+	jobs.Add("edi_846", "0 6 * * *", alerts.Watch("edi_846", ediSender.SendAll))
+
+	// Pick up the stock files suppliers dropped since the last run
+	jobs.Add("supplier_ingest", "*/5 * * * *", alerts.Watch("supplier_ingest", importer.Run))
+
+	// Delete reports older than report_retention_days with their artifacts
+	jobs.Add("report_retention", "40 3 * * *", reportQueue.PurgeExpired)
+
+	// Delete the objects in storage no image, attachment or report refers
+	// to any more
+	jobs.Add("storage_cleanup", "10 4 * * *", library.Cleanup)
+
+	// Refresh the low stock lists and reports pushed to Google Sheets
+	jobs.Add("sheets_export", "*/15 * * * *", alerts.Watch("sheets_export", sheetsExporter.Run))
+
+	// Delete the users, categories and products whose undo window passed
+	jobs.Add("pending_deletions", "* * * * *", deletions.RunDue)
+
+	return jobs
+}
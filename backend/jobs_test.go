@@ -0,0 +1,21 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"rtims-backend/config"
+	"rtims-backend/internal/notify"
+	"rtims-backend/internal/scheduler"
+	"rtims-backend/internal/settings"
+)
+
+func TestJobSchedulesAreSettings(t *testing.T) {
+	cfg := &config.Config{NotificationRetention: time.Hour}
+	jobs := newJobScheduler(cfg, nil, nil, &notify.Dispatcher{LowStockDigest: true}, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	for _, name := range jobs.Jobs() {
+		if _, ok := settings.Lookup(scheduler.SettingPrefix + name); !ok {
+			t.Errorf("job %s has no %s%s setting in the settings schema", name, scheduler.SettingPrefix, name)
+		}
+	}
+}
@@ -1,18 +1,54 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"rtims-backend/config"
+	"rtims-backend/internal/accounting"
+	"rtims-backend/internal/alerting"
+	"rtims-backend/internal/apierror"
+	"rtims-backend/internal/audit"
+	"rtims-backend/internal/backup"
+	"rtims-backend/internal/calendar"
 	"rtims-backend/internal/database"
+	"rtims-backend/internal/deletion"
+	"rtims-backend/internal/edi"
+	"rtims-backend/internal/email"
+	"rtims-backend/internal/files"
+	"rtims-backend/internal/grpcapi"
 	"rtims-backend/internal/handlers"
+	"rtims-backend/internal/ingest"
 	"rtims-backend/internal/middleware"
+	"rtims-backend/internal/movementimport"
+	"rtims-backend/internal/mqtt"
+	"rtims-backend/internal/notify"
+	"rtims-backend/internal/openapi"
+	"rtims-backend/internal/reports"
+	"rtims-backend/internal/search"
+	"rtims-backend/internal/sheets"
+	"rtims-backend/internal/shopify"
+	"rtims-backend/internal/storage"
+	"rtims-backend/internal/telegram"
+	"rtims-backend/internal/validation"
+	"rtims-backend/internal/web"
+	"rtims-backend/internal/webhook"
 	"rtims-backend/internal/websocket"
+	"rtims-backend/internal/woocommerce"
 
+	"github.com/getsentry/sentry-go"
 	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	swaggerFiles "github.com/swaggo/files"
+	"github.com/swaggo/swag"
 	"github.com/joho/godotenv"
 )
 
@@ -22,14 +58,27 @@ func main() {
 		log.Println("Warning: .env file not found")
 	}
 
-	// Initialize configuration
-	cfg := config.Load()
+	// Initialize configuration, refusing to start with any invalid setting
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Loaded %s configuration:\n%s", cfg.Environment, cfg.Redacted())
+
+	// `rtims-backend migrate ...` manages the schema and `rtims-backend seed`
+	// loads demo data; both exit when done
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			os.Exit(runMigrateCommand(cfg, os.Args[2:]))
+		case "seed":
+			os.Exit(runSeedCommand(cfg, os.Args[2:]))
+		}
+	}
 
 	// Initialize JWT secret with logging
 		log.Printf("Initializing JWT secret...")
-		if cfg.JWTSecret == "" {
-			log.Fatal("JWT_SECRET is not set in environment variables")
-		}
+		// config.Load rejects short secrets in production only
 		if len(cfg.JWTSecret) < 32 {
 			log.Printf("Warning: JWT_SECRET is shorter than recommended (32 characters). Current length: %d", len(cfg.JWTSecret))
 		}
@@ -39,14 +88,33 @@ func main() {
 	// Database and Redis are already initialized above
 
 	// Initialize WebSocket hub
-	wsHub := websocket.NewHub()
+	wsHub := websocket.NewHub(cfg.WSMaxConnsPerUser)
 	go wsHub.Run()
 
 	// Initialize database with enhanced validation
 		log.Println("Initializing database connection...")
-		db := database.InitDB(cfg.DatabaseURL)
+		database.ConfigureQueryTimeout(cfg.DBQueryTimeout)
+		database.ConfigureResilience(database.Resilience{
+			Failures:     uint32(cfg.BreakerFailures),
+			OpenFor:      cfg.BreakerOpenFor,
+			Retries:      cfg.Retries,
+			RetryBackoff: cfg.RetryBackoff,
+		})
+		db := database.InitDB(cfg.DatabaseURL, dbPool(cfg))
 		defer db.Close()
 
+		if cfg.MigrateOnStart {
+			migrateOnStart(db)
+		}
+
+		// Send report generation, audit log listing and dashboard aggregates
+		// to the read replica, if there is one
+		if cfg.DatabaseReplicaURL != "" {
+			replica := database.InitReadReplica(cfg.DatabaseReplicaURL, dbPool(cfg))
+			defer replica.Close()
+			database.UseReadReplica(replica)
+		}
+
 		// Validate database connection and required tables
 		if err := database.ValidateDatabaseConnection(db); err != nil {
 			log.Fatal("Database validation failed:", err)
@@ -55,38 +123,261 @@ func main() {
 
 		// Initialize Redis client with enhanced validation
 		log.Println("Initializing Redis connection...")
-		redisClient := database.InitRedis(cfg.RedisURL)
+		redisClient := database.InitRedis(cfg.RedisURL, database.RedisPool{
+			Size:         cfg.RedisPoolSize,
+			DialTimeout:  cfg.RedisDialTimeout,
+			ReadTimeout:  cfg.RedisReadTimeout,
+			WriteTimeout: cfg.RedisWriteTimeout,
+		})
 		defer redisClient.Close()
 
-		// Validate Redis connection
+		// Validate Redis connection. Without Redis the API still serves
+		// requests, degraded, and recovers once it is reachable
 		if err := database.ValidateRedisConnection(redisClient); err != nil {
-			log.Fatal("Redis validation failed:", err)
+			log.Printf("Warning: Redis validation failed, starting in degraded mode: %v", err)
+		} else {
+			log.Println("Redis connection validated successfully")
 		}
-		log.Println("Redis connection validated successfully")
+
+	// Read-through Redis cache for products and dashboard stats
+	cache := database.NewCache(redisClient, cfg.CacheTTL)
+
+	// Browser origins allowed by CORS_ALLOWED_ORIGINS and the
+	// cors_allowed_origins setting, which is re-read as it changes
+	origins := middleware.NewOrigins(cfg.AllowedOrigins, db)
+	websocket.ConfigureOriginCheck(cfg, origins)
+
+	// Recently viewed products of each user
+	recentProducts := database.NewRecentProducts(redisClient)
+
+	// Background workers run until shutdown cancels their context, and are
+	// waited for before Redis and the database are closed
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	startWorker := func(run func(ctx context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(workerCtx)
+		}()
+	}
+
+	// Push live dashboard stats to admin WebSocket clients
+	if cfg.WSDashboardStatsInterval > 0 {
+		dashboardService := database.NewCachedDashboardService(db, cache)
+		startWorker(func(ctx context.Context) {
+			wsHub.RunDashboardStats(ctx, cfg.WSDashboardStatsInterval, dashboardService.GetStats)
+		})
+	}
+
+	// Push new activity feed entries to the WebSocket clients of their organization
+	if cfg.WSActivityInterval > 0 {
+		startWorker(func(ctx context.Context) {
+			wsHub.RunActivityFeed(ctx, cfg.WSActivityInterval, database.NewAuditService(db).GetActivityFeedSince)
+		})
+	}
+
+	// Forward audit entries to the SIEM configured in settings
+	auditStreamer := audit.NewStreamer(db)
+	startWorker(func(ctx context.Context) { auditStreamer.Run(ctx, 5*time.Second) })
+
+	// Send queued events to the webhooks subscribed to them, retrying
+	// failed deliveries with backoff
+	webhooks := webhook.NewDeliverer(db)
+	startWorker(func(ctx context.Context) { webhooks.Run(ctx, 5*time.Second) })
+
+	// Forward critical alerts, such as stock outs of flagged products and
+	// failed syncs and backups, to the Alertmanager, PagerDuty and Opsgenie
+	// sinks the routes in settings choose
+	alertRouter := alerting.NewRouter(db)
+
+	// Push stock levels to the Shopify stores configured in settings
+	shopifySyncer := shopify.NewSyncer(db, cache)
+	shopifySyncer.Alerts = alertRouter
+	startWorker(func(ctx context.Context) { shopifySyncer.Run(ctx, 5*time.Second) })
+
+	// Reconcile stock with the WooCommerce stores configured in settings
+	wooCommerceSyncer := woocommerce.NewSyncer(db, cache)
+
+	// Export journals for QuickBooks and Xero, locking exported periods
+	accountingExporter := accounting.NewExporter(db)
+
+	// Send EDI 846 inventory advice to the trading partners in settings
+	ediSender := edi.NewSender(db)
+
+	// Initialize notification delivery (WebSocket, email, chat webhooks)
+	dispatcher := notify.NewDispatcher(db, wsHub, email.NewMailer(cfg))
+
+	// Suppress repeat low stock alerts for a product hovering around its threshold
+	if cfg.NotificationCooldown > 0 {
+		dispatcher.Throttle = notify.NewThrottle(redisClient, cfg.NotificationCooldown)
+	}
+
+	// Stock updates are also streamed to gRPC clients
+	stockFeed := notify.NewStockFeed()
+	dispatcher.StockFeed = stockFeed
+
+	// and queued for the Shopify variants they are mapped to
+	dispatcher.ShopifyPushes = database.NewShopifyService(db)
+
+	// Stock outs raise alerts
+	dispatcher.Alerts = alertRouter
+
+	// Mirror products, stock movements and audit entries into OpenSearch
+	// when SEARCH_URL is set, and answer product searches from it
+	var searchIndexer *search.Indexer
+	if cfg.SearchURL != "" {
+		searchIndexer = search.NewIndexer(db, search.NewClient(cfg.SearchURL, cfg.SearchUsername, cfg.SearchPassword,
+			cfg.SearchIndexPrefix))
+		if err := searchIndexer.EnsureIndexes(context.Background()); err != nil {
+			log.Printf("Warning: search indexes could not be created, retrying as changes come in: %v", err)
+		}
+		database.UseSearch(searchIndexer)
+		dispatcher.Search = searchIndexer
+	}
+
+	// Publish stock updates, low stock alerts and search index changes from
+	// the outbox once the changes behind them commit
+	startWorker(func(ctx context.Context) { notify.RunOutboxRelay(ctx, db, dispatcher, time.Second) })
+
+	// Send critical alerts to linked Telegram chats, whose commands the
+	// bot answers by webhook
+	telegramBot := telegram.NewBot(db, cache, redisClient, cfg.PublicURL)
+	dispatcher.Telegram = telegramBot
+
+	// Deliver scheduled notifications as they come due
+	startWorker(func(ctx context.Context) { notify.RunScheduledDelivery(ctx, db, dispatcher, 30*time.Second) })
+
+	// Escalate critical alerts that nobody acknowledges in time
+	if cfg.NotificationEscalation > 0 {
+		startWorker(func(ctx context.Context) {
+			notify.RunEscalation(ctx, db, dispatcher, cfg.NotificationEscalation, time.Minute)
+		})
+	}
+
+	// Replace per-movement low stock alerts with a daily digest when scheduled
+	if cfg.NotificationDigestHour >= 0 && cfg.NotificationDigestHour < 24 {
+		dispatcher.LowStockDigest = true
+	}
+
+	// Ingest the stock files suppliers drop in SFTP or S3 folders
+	supplierImporter := ingest.NewImporter(db, cache, dispatcher)
+
+	// Push the low stock list and reports to the Google Sheets in settings
+	sheetsExporter := sheets.NewExporter(db, cache)
+
+	// Keep product images, movement attachments and report artifacts, and
+	// backups when BACKUP_STORAGE is shared, on local disk, S3, GCS or MinIO
+	objectStore, err := storage.New(cfg)
+	if err != nil {
+		log.Fatal("Failed to set up object storage:", err)
+	}
+	fileLibrary := files.NewLibrary(db, objectStore, cfg.StorageURLExpiry)
+
+	// Start background report workers
+	reportQueue, err := reports.NewQueue(db, objectStore, cfg.ReportDir)
+	if err != nil {
+		log.Fatal("Failed to set up the report queue:", err)
+	}
+
+	// Cap concurrent report generation so a burst cannot exhaust the DB pool
+	if cfg.ReportMaxConcurrent > 0 || cfg.ReportMaxConcurrentPerUser > 0 {
+		reportQueue.Limiter = reports.NewLimiter(redisClient, cfg.ReportMaxConcurrent, cfg.ReportMaxConcurrentPerUser,
+			cfg.ReportQueueTimeout)
+	}
+	startWorker(func(ctx context.Context) { reportQueue.Run(ctx, cfg.ReportWorkers) })
+
+	// Carry out deletions of users, categories and products once they can
+	// no longer be undone
+	pendingDeletions := deletion.NewRunner(db, cache, redisClient)
+
+	// Import historical stock movements from CSV files in the background
+	movementImportQueue := movementimport.NewQueue(db)
+
+	// Dump the database with pg_dump to local disk, S3 or the object store
+	backupStorage, err := backup.NewStorage(cfg, objectStore)
+	if err != nil {
+		log.Fatal("Failed to set up backup storage:", err)
+	}
+	backups := backup.NewRunner(db, cfg.DatabaseURL, cfg.BackupPgDump, backupStorage)
+	backups.Alerts = alertRouter
+
+	// Run scheduled reports, digests, retention purges, snapshots, backups
+	// and storage cleanup on cron schedules, on one replica at a time
+	reportScheduler := reports.NewScheduler(db, reportQueue, email.NewMailer(cfg), cfg.PublicURL)
+	jobScheduler := newJobScheduler(cfg, db, redisClient, dispatcher, reportScheduler, backups, wooCommerceSyncer,
+		accountingExporter, ediSender, supplierImporter, reportQueue, fileLibrary, sheetsExporter, alertRouter, pendingDeletions, objectStore)
+	startWorker(jobScheduler.Run)
+
+	// Record the readings of the scales and smart shelves in settings as
+	// stock adjustments, subscribed to on the instance that runs jobs
+	if cfg.MQTTURL != "" {
+		mqttSubscriber := mqtt.NewSubscriber(db, cache, mqtt.Options{
+			URL:      cfg.MQTTURL,
+			ClientID: cfg.MQTTClientID,
+			Username: cfg.MQTTUsername,
+			Password: cfg.MQTTPassword,
+		})
+		startWorker(func(ctx context.Context) { mqttSubscriber.Run(ctx, jobScheduler.Leader) })
+	}
+
+	// Report panics and 5xx responses to Sentry when configured
+	if cfg.SentryDSN != "" {
+		if err := sentry.Init(sentry.ClientOptions{Dsn: cfg.SentryDSN, Environment: cfg.Environment}); err != nil {
+			log.Printf("Warning: Sentry initialization failed, errors will only be logged: %v", err)
+		} else {
+			defer sentry.Flush(5 * time.Second)
+		}
+	}
 
 	// Set Gin mode
 	if cfg.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	// Validate requests against their models' validate tags
+	validation.Install()
+
 	// Initialize Gin router
 	r := gin.New()
 
 	// Add middleware
+	r.Use(middleware.RequestID())
 	r.Use(gin.Logger())
-	r.Use(gin.Recovery())
-	r.Use(middleware.CORS())
+	r.Use(middleware.Recovery())
+	r.Use(middleware.CORS(cfg, origins))
 	r.Use(middleware.SecurityHeaders())
 	r.Use(middleware.RateLimit())
+	if cfg.CompressionMinBytes >= 0 {
+		r.Use(middleware.Compress(cfg.CompressionMinBytes))
+	}
 
-	// Initialize audit middleware with database
-	auditMiddleware := middleware.NewAuditMiddleware(db)
-
-	// Health check endpoint
+	// Audit entries from the middleware and handlers are batched and
+	// written in the background
+	auditWriter := database.NewAuditWriter(db, cfg.AuditWorkers, cfg.AuditQueueSize)
+	database.UseAuditWriter(auditWriter)
+	auditMiddleware := middleware.NewAuditMiddleware(auditWriter)
+
+	// Health check endpoints: /healthz (and the older /health) for liveness,
+	// /readyz for readiness with dependency checks
+	// Redis is optional: without it the API runs degraded
+	healthHandler := handlers.NewHealthHandler(map[string]handlers.ReadinessCheck{
+		"database": db.PingContext,
+		"migrations": func(ctx context.Context) error {
+			return database.CheckMigrations(ctx, db)
+		},
+	}, map[string]handlers.ReadinessCheck{
+		"redis": func(ctx context.Context) error {
+			return redisClient.Ping(ctx).Err()
+		},
+	})
 	r.GET("/health", handlers.HealthCheck)
+	r.GET("/healthz", handlers.HealthCheck)
+	r.GET("/readyz", healthHandler.Readiness)
 
 	// API v1 routes
 	v1 := r.Group("/api/v1")
+	v1.Use(middleware.DatabaseAvailable())
 	{
 		// Initialize auth handlers
 		handlers.InitAuthHandlers([]byte(cfg.JWTSecret), db, redisClient)
@@ -97,10 +388,27 @@ func main() {
 			auth.POST("/register", handlers.Register)
 			auth.POST("/login", handlers.Login)
 			auth.POST("/refresh", handlers.RefreshToken)
+			auth.POST("/logout", handlers.Logout)
 			auth.POST("/forgot-password", handlers.ForgotPassword)
 			auth.POST("/reset-password", handlers.ResetPassword)
 		}
 
+		// Shopify order webhooks, authenticated by their signature
+		shopifyHandler := handlers.NewShopifyHandler(shopifySyncer)
+		v1.POST("/integrations/shopify/webhooks", shopifyHandler.ReceiveWebhook)
+
+		// Telegram bot updates, authenticated by the webhook secret
+		telegramHandler := handlers.NewTelegramHandler(telegramBot)
+		v1.POST("/integrations/telegram/webhook", telegramHandler.ReceiveWebhook)
+
+		// Files of the local object store, authenticated by their signed URL
+		fileHandler := handlers.NewFileHandler(db, cache, fileLibrary, objectStore)
+		v1.GET("/files/*key", fileHandler.ServeFile)
+
+		// Calendar feeds, authenticated by the signed token in their link
+		calendarHandler := handlers.NewCalendarHandler(db, calendar.NewFeed(db, cfg.JWTSecret), cfg.PublicURL)
+		v1.GET("/calendar/:token/feed.ics", calendarHandler.ServeFeed)
+
 		// Protected routes
 			protected := v1.Group("/")
 			protected.Use(middleware.JWTAuth())
@@ -110,19 +418,19 @@ func main() {
 				protected.GET("/test-auth", func(c *gin.Context) {
 					userID, exists := c.Get("user_id")
 					if !exists {
-						c.JSON(http.StatusInternalServerError, gin.H{"error": "User ID not found in context"})
+						apierror.Respond(c, apierror.New(http.StatusInternalServerError, apierror.CodeInternal, "User ID not found in context"))
 						return
 					}
 
 					email, exists := c.Get("email")
 					if !exists {
-						c.JSON(http.StatusInternalServerError, gin.H{"error": "Email not found in context"})
+						apierror.Respond(c, apierror.New(http.StatusInternalServerError, apierror.CodeInternal, "Email not found in context"))
 						return
 					}
 
 					role, exists := c.Get("role")
 					if !exists {
-						c.JSON(http.StatusInternalServerError, gin.H{"error": "Role not found in context"})
+						apierror.Respond(c, apierror.New(http.StatusInternalServerError, apierror.CodeInternal, "Role not found in context"))
 						return
 					}
 
@@ -136,41 +444,121 @@ func main() {
 					})
 				})
 
+				// Demo data, for host admins where DEV_SEED_ENABLED opts in
+				if cfg.DevSeedEnabled {
+					protected.POST("/dev/seed", middleware.AdminOnly(), middleware.HostAdminOnly(), handlers.NewSeedHandler(db).Seed)
+				}
+
 				// User routes
 				protected.GET("/profile", handlers.GetProfile)
 				protected.PUT("/profile", handlers.UpdateProfile)
+				protected.POST("/profile/telegram/link-code", telegramHandler.CreateLinkCode)
+				protected.GET("/profile/telegram/chats", telegramHandler.GetChats)
+				protected.DELETE("/profile/telegram/chats/:chat_id", telegramHandler.UnlinkChat)
+				protected.GET("/profile/calendar-feed", calendarHandler.GetFeed)
+				protected.POST("/profile/calendar-feed/regenerate", calendarHandler.RegenerateFeed)
+
+				// Scheduled stocktakes and maintenance windows
+				protected.GET("/calendar/events", calendarHandler.GetEvents)
 
 			// Initialize product handler
-			productHandler := handlers.NewProductHandler(db, redisClient, wsHub)
+			productHandler := handlers.NewProductHandler(db, cache, recentProducts)
 
 			// Initialize notification handler
-			notificationHandler := handlers.NewNotificationHandler(db, wsHub)
+			notificationHandler := handlers.NewNotificationHandler(db, dispatcher)
 
 			// Initialize admin handler
-			adminHandler := handlers.NewAdminHandler(db)
+			adminHandler := handlers.NewAdminHandler(db, cache, redisClient, wsHub, reportQueue, backups)
+
+			// Initialize announcement handler
+			announcementHandler := handlers.NewAnnouncementHandler(db, wsHub)
+
+			// Initialize report schedule handler
+			reportScheduleHandler := handlers.NewReportScheduleHandler(db)
+
+			// Initialize user import handler
+			userImportHandler := handlers.NewUserImportHandler(db, redisClient, email.NewMailer(cfg), cfg.PublicURL)
+
+			// Initialize pending deletion handler
+			pendingDeletionHandler := handlers.NewPendingDeletionHandler(db)
+
+			// Initialize stock movement import handler
+			movementImportHandler := handlers.NewMovementImportHandler(db, movementImportQueue)
+
+			// Initialize webhook handler
+			webhookHandler := handlers.NewWebhookHandler(db, webhooks)
+
+			// Initialize WooCommerce handler
+			wooCommerceHandler := handlers.NewWooCommerceHandler(wooCommerceSyncer)
+
+			// Initialize accounting handler
+			accountingHandler := handlers.NewAccountingHandler(db, accountingExporter)
+
+			// Initialize EDI handler
+			ediHandler := handlers.NewEDIHandler(db, ediSender)
+
+			// Initialize supplier ingest handler
+			ingestHandler := handlers.NewIngestHandler(db, supplierImporter)
+
+			// Initialize Google Sheets export handler
+			sheetsHandler := handlers.NewSheetsHandler(sheetsExporter)
+			alertHandler := handlers.NewAlertHandler(alertRouter)
+
+			// Initialize search handler
+			searchHandler := handlers.NewSearchHandler(searchIndexer)
+
+			// Initialize comment handler
+			commentHandler := handlers.NewCommentHandler(db, cache, dispatcher)
+
+			// Initialize activity feed handler
+			activityFeedHandler := handlers.NewActivityFeedHandler(db)
 
 			// Dashboard routes
 			protected.GET("/dashboard/stats", adminHandler.GetDashboardStats)
 			protected.GET("/dashboard/alerts", adminHandler.GetDashboardAlerts)
+			protected.GET("/dashboard/trends", adminHandler.GetDashboardTrends)
+
+			// Recently viewed and favorite products
+			protected.GET("/profile/recent-products", productHandler.GetRecentProducts)
+			protected.GET("/profile/favorites", productHandler.GetFavoriteProducts)
 
 			// Product routes
 			products := protected.Group("/products")
 			{
-				products.GET("/", productHandler.GetProducts)
-				products.GET("/:id", productHandler.GetProduct)
+				products.GET("/", middleware.Deprecated("/api/v2/products", cfg.APIV1Sunset), productHandler.GetProducts)
+				products.GET("/:id", middleware.Deprecated("/api/v2/products/:id", cfg.APIV1Sunset), productHandler.GetProduct)
 				products.POST("/", productHandler.CreateProduct)
 				products.PUT("/:id", productHandler.UpdateProduct)
 				products.DELETE("/:id", productHandler.DeleteProduct)
+				products.POST("/:id/undo", pendingDeletionHandler.UndoProductDeletion)
 				products.POST("/:id/stock", productHandler.UpdateStock)
+				products.POST("/:id/favorite", productHandler.AddFavorite)
+				products.DELETE("/:id/favorite", productHandler.RemoveFavorite)
+				products.GET("/:id/image", fileHandler.GetProductImage)
+				products.PUT("/:id/image", fileHandler.UploadProductImage)
+				products.DELETE("/:id/image", fileHandler.DeleteProductImage)
+				products.GET("/:id/comments", commentHandler.GetProductComments)
+				products.POST("/:id/comments", commentHandler.CreateProductComment)
 			}
 
 			// Stock movement routes
 			movements := protected.Group("/stock-movements")
 			{
-				movements.GET("/", productHandler.GetStockMovements)
+				movements.GET("/", middleware.Deprecated("/api/v2/stock-movements", cfg.APIV1Sunset), productHandler.GetStockMovements)
 				movements.GET("/:id", productHandler.GetStockMovement)
+				movements.POST("/import", middleware.AdminOnly(), movementImportHandler.ImportMovements)
+				movements.GET("/imports", middleware.AdminOnly(), movementImportHandler.GetMovementImports)
+				movements.GET("/imports/:id", middleware.AdminOnly(), movementImportHandler.GetMovementImport)
+				movements.GET("/:id/attachments", fileHandler.GetAttachments)
+				movements.POST("/:id/attachments", fileHandler.UploadAttachment)
+				movements.DELETE("/:id/attachments/:attachment_id", fileHandler.DeleteAttachment)
+				movements.GET("/:id/comments", commentHandler.GetMovementComments)
+				movements.POST("/:id/comments", commentHandler.CreateMovementComment)
 			}
 
+			// Comments are deleted by their author or an admin
+			protected.DELETE("/comments/:id", commentHandler.DeleteComment)
+
 			// Category routes
 			categories := protected.Group("/categories")
 			{
@@ -178,6 +566,26 @@ func main() {
 				categories.POST("/", adminHandler.CreateCategory)
 				categories.PUT("/:id", adminHandler.UpdateCategory)
 				categories.DELETE("/:id", adminHandler.DeleteCategory)
+				categories.POST("/:id/undo", pendingDeletionHandler.UndoCategoryDeletion)
+			}
+
+			// Report routes, limited per report type by the report_permissions
+			// setting; generated reports are visible to their requester and
+			// the users they are shared with
+			reportRoutes := protected.Group("/reports")
+			{
+				reportRoutes.GET("/", adminHandler.GetMyReports)
+				reportRoutes.GET("/types", adminHandler.GetReportTypes)
+				reportRoutes.POST("/", adminHandler.EnqueueReport)
+				reportRoutes.GET("/jobs/:id", adminHandler.GetReportJob)
+				reportRoutes.GET("/:id/download", adminHandler.DownloadReport)
+				reportRoutes.GET("/:id/shares", adminHandler.GetReportShares)
+				reportRoutes.POST("/:id/shares", adminHandler.ShareReport)
+				reportRoutes.DELETE("/:id/shares/:user_id", adminHandler.UnshareReport)
+				reportRoutes.GET("/inventory", adminHandler.GenerateInventoryReport)
+				reportRoutes.GET("/movements", adminHandler.GenerateMovementReport)
+				reportRoutes.GET("/users", adminHandler.GenerateUserReport)
+				reportRoutes.GET("/financial", adminHandler.GenerateFinancialReport)
 			}
 
 			// Admin routes
@@ -187,37 +595,149 @@ func main() {
 				// User management
 				admin.GET("/users", adminHandler.GetUsers)
 				admin.POST("/users", adminHandler.CreateUser)
+				admin.POST("/users/import", userImportHandler.ImportUsers)
 				admin.PUT("/users/:id", adminHandler.UpdateUser)
 				admin.DELETE("/users/:id", adminHandler.DeleteUser)
+				admin.POST("/users/:id/undo", pendingDeletionHandler.UndoUserDeletion)
+				admin.GET("/users/:id/export", adminHandler.ExportUser)
+				admin.GET("/users/:id/activity", adminHandler.GetUserActivity)
 
 				// Category management
 				admin.GET("/categories", adminHandler.GetCategories)
 				admin.POST("/categories", adminHandler.CreateCategory)
 				admin.PUT("/categories/:id", adminHandler.UpdateCategory)
 				admin.DELETE("/categories/:id", adminHandler.DeleteCategory)
+				admin.POST("/categories/:id/undo", pendingDeletionHandler.UndoCategoryDeletion)
+				admin.POST("/categories/:id/merge", adminHandler.MergeCategory)
+
+				// Deletions that can still be undone
+				admin.GET("/pending-deletions", pendingDeletionHandler.GetPendingDeletions)
 
 				// Reports
 				admin.GET("/reports/stats", adminHandler.GetReportStats)
 				admin.GET("/reports/types", adminHandler.GetReportTypes)
 				admin.GET("/reports/recent", adminHandler.GetRecentReports)
-				admin.GET("/reports/inventory", adminHandler.GenerateReport)
-				admin.GET("/reports/movements", adminHandler.GenerateReport)
-				admin.GET("/reports/users", adminHandler.GenerateReport)
-				admin.GET("/reports/financial", adminHandler.GenerateReport)
-				admin.GET("/reports/:type", adminHandler.GenerateReport)
-
-				// System settings
+				admin.POST("/reports", adminHandler.EnqueueReport)
+				admin.POST("/reports/branding/logo", adminHandler.UploadReportLogo)
+				admin.GET("/reports/schedules", reportScheduleHandler.GetSchedules)
+				admin.POST("/reports/schedules", reportScheduleHandler.CreateSchedule)
+				admin.PUT("/reports/schedules/:id", reportScheduleHandler.UpdateSchedule)
+				admin.DELETE("/reports/schedules/:id", reportScheduleHandler.DeleteSchedule)
+				admin.GET("/reports/jobs/:id", adminHandler.GetReportJob)
+				admin.GET("/reports/:id/download", adminHandler.DownloadReport)
+				admin.GET("/reports/inventory", adminHandler.GenerateInventoryReport)
+				admin.GET("/reports/movements", adminHandler.GenerateMovementReport)
+				admin.GET("/reports/users", adminHandler.GenerateUserReport)
+				admin.GET("/reports/financial", adminHandler.GenerateFinancialReport)
+
+				// Calendar events
+				admin.POST("/calendar/events", calendarHandler.CreateEvent)
+				admin.PUT("/calendar/events/:id", calendarHandler.UpdateEvent)
+				admin.DELETE("/calendar/events/:id", calendarHandler.DeleteEvent)
+
+				// System settings. Settings, announcements and templates
+				// apply to every organization, so only host admins change them
+				hostOnly := middleware.HostAdminOnly()
 				admin.GET("/settings", adminHandler.GetSettings)
-				admin.PUT("/settings", adminHandler.UpdateSettings)
-				admin.GET("/settings/status", adminHandler.GetSystemStatus)
-				admin.POST("/settings/backup", adminHandler.TriggerBackup)
+				admin.GET("/settings/schema", adminHandler.GetSettingsSchema)
+				admin.PUT("/settings", hostOnly, adminHandler.UpdateSettings)
+				admin.GET("/settings/revisions", hostOnly, adminHandler.GetSettingsRevisions)
+				admin.POST("/settings/revisions/:id/rollback", hostOnly, adminHandler.RollbackSettingsRevision)
+				admin.GET("/settings/status", hostOnly, adminHandler.GetSystemStatus)
+				admin.POST("/settings/backup", hostOnly, adminHandler.TriggerBackup)
+				admin.GET("/settings/backups", hostOnly, adminHandler.GetBackups)
+				admin.GET("/settings/backups/:id/download", hostOnly, adminHandler.DownloadBackup)
+				admin.DELETE("/settings/backups/:id", hostOnly, adminHandler.DeleteBackup)
+
+				// Announcements
+				admin.POST("/announcements", hostOnly, announcementHandler.CreateAnnouncement)
+
+				// Notification templates
+				admin.GET("/notification-templates", notificationHandler.GetTemplates)
+				admin.PUT("/notification-templates/:key", hostOnly, notificationHandler.UpdateTemplate)
+
+				// Webhooks
+				admin.GET("/webhooks", webhookHandler.GetWebhooks)
+				admin.POST("/webhooks", webhookHandler.CreateWebhook)
+				admin.GET("/webhooks/:id", webhookHandler.GetWebhook)
+				admin.PUT("/webhooks/:id", webhookHandler.UpdateWebhook)
+				admin.DELETE("/webhooks/:id", webhookHandler.DeleteWebhook)
+				admin.GET("/webhooks/:id/deliveries", webhookHandler.GetWebhookDeliveries)
+				admin.POST("/webhooks/:id/test", webhookHandler.TestWebhook)
+
+				// Shopify stores are configured in settings, so only host
+				// admins map and sync them
+				admin.GET("/shopify/stores", hostOnly, shopifyHandler.GetStores)
+				admin.GET("/shopify/stores/:shop/variants", hostOnly, shopifyHandler.GetVariants)
+				admin.POST("/shopify/stores/:shop/variants/sync", hostOnly, shopifyHandler.SyncVariants)
+				admin.PUT("/shopify/stores/:shop/variants/:variant_id", hostOnly, shopifyHandler.MapVariant)
+				admin.DELETE("/shopify/stores/:shop/variants/:variant_id", hostOnly, shopifyHandler.UnmapVariant)
+				admin.GET("/shopify/stores/:shop/orders", hostOnly, shopifyHandler.GetOrders)
+
+				// WooCommerce stores are configured in settings too
+				admin.GET("/woocommerce/status", hostOnly, wooCommerceHandler.GetStatus)
+				admin.POST("/woocommerce/stores/:store/reconcile", hostOnly, wooCommerceHandler.Reconcile)
+				admin.POST("/woocommerce/conflicts/:id/resolve", hostOnly, wooCommerceHandler.ResolveConflict)
+
+				// Accounting exports
+				admin.GET("/accounting/exports", accountingHandler.GetExports)
+				admin.POST("/accounting/exports", accountingHandler.CreateExport)
+				admin.GET("/accounting/exports/:id/download", accountingHandler.DownloadExport)
+
+				// EDI 846 inventory advice
+				admin.GET("/edi/partners", hostOnly, ediHandler.GetPartners)
+				admin.POST("/edi/partners/:partner/send", hostOnly, ediHandler.Send)
+				admin.GET("/edi/documents", hostOnly, ediHandler.GetDocuments)
+				admin.GET("/edi/documents/:id/download", hostOnly, ediHandler.DownloadDocument)
+				admin.POST("/edi/documents/:id/resend", hostOnly, ediHandler.ResendDocument)
+
+				// Supplier stock file ingestion
+				admin.GET("/ingest/suppliers", hostOnly, ingestHandler.GetSuppliers)
+				admin.POST("/ingest/suppliers/:supplier/run", hostOnly, ingestHandler.Run)
+				admin.GET("/ingest/files", hostOnly, ingestHandler.GetFiles)
+				admin.GET("/ingest/files/:id", hostOnly, ingestHandler.GetFile)
+
+				// Google Sheets exports
+				admin.POST("/sheets/exports/:export/run", hostOnly, sheetsHandler.Run)
+
+				// Alert sinks
+				admin.POST("/alerts/sinks/:sink/test", hostOnly, alertHandler.TestSink)
+
+				// Telegram bot
+				admin.POST("/telegram/webhook", hostOnly, telegramHandler.RegisterWebhook)
+
+				// Search index
+				admin.POST("/search/reindex", hostOnly, searchHandler.Reindex)
+			}
+
+			// Organization management, for admins of the default
+			// organization
+			organizationHandler := handlers.NewOrganizationHandler(db)
+			organizations := protected.Group("/organizations")
+			organizations.Use(middleware.AdminOnly(), middleware.HostAdminOnly())
+			{
+				organizations.GET("/", organizationHandler.GetOrganizations)
+				organizations.POST("/", organizationHandler.CreateOrganization)
+				organizations.GET("/:id", organizationHandler.GetOrganization)
+				organizations.PUT("/:id", organizationHandler.UpdateOrganization)
 			}
 
+			// Announcement routes
+			protected.GET("/announcements/active", announcementHandler.GetActiveAnnouncements)
+
 			// Notification routes
 			notifications := protected.Group("/notifications")
 			{
 				notifications.GET("/", notificationHandler.GetNotifications)
+				notifications.GET("/preferences", notificationHandler.GetPreferences)
+				notifications.PUT("/preferences", notificationHandler.UpdatePreferences)
 				notifications.PUT("/:id/read", notificationHandler.MarkNotificationRead)
+				notifications.PUT("/:id/acknowledge", notificationHandler.AcknowledgeNotification)
+				notifications.PUT("/:id/archive", notificationHandler.ArchiveNotification)
+				notifications.PUT("/:id/unarchive", notificationHandler.UnarchiveNotification)
+				notifications.DELETE("/:id", notificationHandler.DeleteNotification)
+				notifications.PUT("/read-all", notificationHandler.MarkAllNotificationsRead)
+				notifications.POST("/bulk", notificationHandler.BulkUpdateNotifications)
 				notifications.POST("/", notificationHandler.CreateNotification)
 			}
 
@@ -225,8 +745,13 @@ func main() {
 			auditLogs := protected.Group("/audit-logs")
 			{
 				auditLogs.GET("/", notificationHandler.GetAuditLogs)
+				auditLogs.GET("/record/:table/:record_id", notificationHandler.GetRecordTimeline)
 				auditLogs.GET("/:id", notificationHandler.GetAuditLog)
+				auditLogs.POST("/:id/restore", middleware.AdminOnly(), notificationHandler.RestoreAuditLog)
 			}
+
+			// Activity feed of the organization, live over the WebSocket
+			protected.GET("/activity-feed", activityFeedHandler.GetActivityFeed)
 		}
 
 		// WebSocket endpoint
@@ -235,14 +760,159 @@ func main() {
 		})
 	}
 
-	// Swagger documentation
+	// API v2 routes: response shapes v1 clients cannot take, on the same
+	// services. v1 routes with a successor here say so in their headers
+	v2 := r.Group("/api/v2")
+	v2.Use(middleware.DatabaseAvailable())
+	v2.Use(middleware.JWTAuth())
+	{
+		v2Handler := handlers.NewV2Handler(db, cache, recentProducts)
+		v2.GET("/products", v2Handler.GetProducts)
+		v2.GET("/products/:id", v2Handler.GetProduct)
+		v2.GET("/stock-movements", v2Handler.GetStockMovements)
+	}
+
+	// GraphQL, for clients that select the fields and relations they need
+	graphQL := r.Group("/graphql")
+	graphQL.Use(middleware.DatabaseAvailable())
+	graphQL.Use(middleware.JWTAuth())
+	{
+		graphQLHandler := handlers.NewGraphQLHandler(db, cache)
+		graphQL.GET("", graphQLHandler.Query)
+		graphQL.POST("", graphQLHandler.Query)
+	}
+
+	// The frontend, when it is built into the binary
+	if files := web.Files(); files != nil {
+		r.NoRoute(web.Handler(files))
+	}
+
+	// API documentation. The document describes every route, its own
+	// included, so it is built once they are all registered.
+	var serveOpenAPI gin.HandlerFunc
+	r.GET("/openapi.json", middleware.JWTAuth(), middleware.AdminOnly(), func(c *gin.Context) {
+		serveOpenAPI(c)
+	})
 	if cfg.Environment != "production" {
 		r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 	}
+	apiDoc := openapi.Build(apiInfo, r.Routes(), apiOperations())
+	serveOpenAPI = openapi.Handler(apiDoc)
+	swag.Register(swag.Name, apiDoc)
 
 	// Start server
-	log.Printf("Server starting on port %s in %s mode", cfg.Port, cfg.Environment)
-	if err := r.Run(":" + cfg.Port); err != nil {
-		log.Fatal("Failed to start server:", err)
+	srv := &http.Server{
+		Addr:    ":" + cfg.Port,
+		Handler: r,
+	}
+	redirectSrv, err := configureTLS(cfg, srv)
+	if err != nil {
+		log.Fatal("Invalid TLS configuration:", err)
+	}
+	go func() {
+		var err error
+		if srv.TLSConfig != nil {
+			log.Printf("Server starting on port %s (HTTPS) in %s mode", cfg.Port, cfg.Environment)
+			// Certificate paths are empty with autocert, which supplies
+			// certificates through TLSConfig
+			err = srv.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			log.Printf("Server starting on port %s in %s mode", cfg.Port, cfg.Environment)
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatal("Failed to start server:", err)
+		}
+	}()
+	if redirectSrv != nil {
+		go func() {
+			log.Printf("Redirecting HTTP on port %s to HTTPS", cfg.HTTPRedirectPort)
+			if err := redirectSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatal("Failed to start HTTP redirect server:", err)
+			}
+		}()
+	}
+
+	// gRPC for internal integrations, over mutual TLS
+	var grpcServer *grpc.Server
+	if cfg.GRPCPort != "" {
+		tlsConfig, err := grpcapi.TLSConfig(cfg.GRPCCertFile, cfg.GRPCKeyFile, cfg.GRPCClientCAFile)
+		if err != nil {
+			log.Fatal("Invalid gRPC TLS configuration:", err)
+		}
+		listener, err := net.Listen("tcp", ":"+cfg.GRPCPort)
+		if err != nil {
+			log.Fatal("Failed to listen for gRPC:", err)
+		}
+		grpcServer = grpcapi.NewServer(db, cache, stockFeed).GRPCServer(tlsConfig)
+		go func() {
+			log.Printf("gRPC server starting on port %s", cfg.GRPCPort)
+			if err := grpcServer.Serve(listener); err != nil {
+				log.Fatal("Failed to start gRPC server:", err)
+			}
+		}()
+	}
+
+	// Shut down gracefully on SIGINT/SIGTERM
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.Println("Shutting down server...")
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	// WebSocket connections are hijacked, so srv.Shutdown does not wait for
+	// them; tell clients to reconnect elsewhere and flush their events first
+	if err := wsHub.Shutdown(ctx); err != nil {
+		log.Printf("WebSocket hub did not drain cleanly: %v", err)
+	}
+
+	// Stop accepting connections and wait for in-flight requests
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Server did not shut down cleanly: %v", err)
+	}
+	if redirectSrv != nil {
+		redirectSrv.Shutdown(ctx)
+	}
+	if grpcServer != nil {
+		// Streams run until their clients leave, so they are cut off
+		// rather than waited for
+		grpcServer.Stop()
+	}
+
+	// Stop the background workers, which finish the batch or job in hand,
+	// so none is cut off by the closes of Redis and the database
+	stopWorkers()
+	stopped := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		log.Printf("Background workers did not stop in time: %v", ctx.Err())
+	}
+
+	// Write audit entries still queued by the last requests
+	if err := auditWriter.Close(ctx); err != nil {
+		log.Printf("Audit log queue did not drain: %v", err)
+	}
+
+	// The deferred closes of Redis and the database run as main returns
+	log.Println("Server stopped")
+}
+
+// dbPool is the Postgres pool configuration for the primary and the read
+// replica.
+func dbPool(cfg *config.Config) database.Pool {
+	return database.Pool{
+		MaxOpen:          cfg.DBMaxOpenConns,
+		MaxIdle:          cfg.DBMaxIdleConns,
+		MaxLifetime:      cfg.DBConnMaxLifetime,
+		MaxIdleTime:      cfg.DBConnMaxIdleTime,
+		StatementTimeout: cfg.DBStatementTimeout,
+		LockTimeout:      cfg.DBLockTimeout,
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"rtims-backend/config"
+	"rtims-backend/internal/database"
+	"rtims-backend/migrations"
+)
+
+const migrateUsage = `usage: rtims-backend migrate <command>
+
+commands:
+  up           apply every pending migration
+  down [n]     revert the newest n migrations (default 1)
+  status       show the current version and pending migrations
+  force <n>    record version n as applied without running SQL`
+
+// runMigrateCommand handles `rtims-backend migrate ...` and returns the
+// process exit code.
+func runMigrateCommand(cfg *config.Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	// Migrations may legitimately run longer than any API query
+	pool := dbPool(cfg)
+	pool.StatementTimeout = 0
+	db := database.InitDB(cfg.DatabaseURL, pool)
+	defer db.Close()
+
+	migrator, err := database.NewMigrator(db, migrations.FS)
+	if err != nil {
+		log.Printf("Failed to load migrations: %v", err)
+		return 1
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			log.Printf("Migration failed after applying %d: %v", applied, err)
+			return 1
+		}
+		log.Printf("Applied %d migration(s)", applied)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				fmt.Fprintln(os.Stderr, "down takes a positive number of migrations to revert")
+				return 2
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			log.Printf("Revert failed after reverting %d: %v", reverted, err)
+			return 1
+		}
+		log.Printf("Reverted %d migration(s)", reverted)
+
+	case "status":
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			log.Printf("Failed to read migration status: %v", err)
+			return 1
+		}
+		fmt.Printf("version: %d (latest %d)\n", status.Version, status.Latest)
+		if status.Dirty {
+			fmt.Println("dirty: the last migration failed part way")
+		}
+		for _, migration := range status.Pending {
+			fmt.Printf("pending: %03d_%s\n", migration.Version, migration.Name)
+		}
+
+	case "force":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return 2
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || version < 0 {
+			fmt.Fprintln(os.Stderr, "force takes a migration version")
+			return 2
+		}
+		if err := migrator.Force(ctx, version); err != nil {
+			log.Printf("Failed to force version: %v", err)
+			return 1
+		}
+		log.Printf("Schema version set to %d", version)
+
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	return 0
+}
+
+// migrateOnStart applies pending migrations before the API starts serving.
+func migrateOnStart(db *sql.DB) {
+	migrator, err := database.NewMigrator(db, migrations.FS)
+	if err != nil {
+		log.Fatal("Failed to load migrations:", err)
+	}
+	applied, err := migrator.Up(context.Background())
+	if err != nil {
+		log.Fatal("Database migration failed:", err)
+	}
+	log.Printf("Database schema up to date (%d migration(s) applied)", applied)
+}
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS notification_preferences;
+
+ALTER TABLE notifications
+    DROP COLUMN IF EXISTS email_status,
+    DROP COLUMN IF EXISTS email_sent_at,
+    DROP COLUMN IF EXISTS email_error;
@@ -0,0 +1,15 @@
+-- Email delivery channel for notifications
+
+ALTER TABLE notifications
+    ADD COLUMN email_status VARCHAR(20) NOT NULL DEFAULT 'none' CHECK (email_status IN ('none', 'pending', 'sent', 'failed')),
+    ADD COLUMN email_sent_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN email_error TEXT;
+
+-- Per-user, per-type delivery preferences. Absence of a row means in-app only.
+CREATE TABLE notification_preferences (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('low_stock', 'system', 'user')),
+    email_enabled BOOLEAN NOT NULL DEFAULT false,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (user_id, type)
+);
@@ -0,0 +1,6 @@
+-- Archive flag for notifications
+
+ALTER TABLE notifications
+    ADD COLUMN is_archived BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX idx_notifications_user_archived ON notifications(user_id, is_archived);
@@ -0,0 +1,15 @@
+-- Admin-editable notification message templates
+-- Placeholders use {{name}} or {{object.field}} and are filled in at send time
+
+CREATE TABLE notification_templates (
+    key VARCHAR(100) PRIMARY KEY,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('low_stock', 'system', 'user')),
+    body TEXT NOT NULL,
+    description TEXT,
+    updated_by UUID REFERENCES users(id),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+INSERT INTO notification_templates (key, type, body, description) VALUES
+('low_stock', 'low_stock', 'Product ''{{product.name}}'' stock is low ({{stock}} remaining)',
+ 'Sent when a stock movement leaves a product at or below its minimum threshold. Variables: product.name, product.sku, product.minimum_threshold, stock');
@@ -0,0 +1,5 @@
+-- Template for the daily low-stock digest
+
+INSERT INTO notification_templates (key, type, body, description) VALUES
+('low_stock_digest', 'low_stock', 'Daily low stock summary: {{count}} product(s) need restocking: {{products}}',
+ 'Daily summary of products that went low on stock in the last 24 hours. Variables: count, products');
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_notifications_pending_delivery;
+
+ALTER TABLE notifications
+    DROP COLUMN IF EXISTS scheduled_at,
+    DROP COLUMN IF EXISTS delivered_at;
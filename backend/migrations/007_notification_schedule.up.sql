@@ -0,0 +1,12 @@
+-- Scheduled notifications
+-- scheduled_at is NULL for immediate notifications; delivered_at is set once
+-- the notification has been pushed to its channels.
+
+ALTER TABLE notifications
+    ADD COLUMN scheduled_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN delivered_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE notifications SET delivered_at = created_at;
+
+CREATE INDEX idx_notifications_pending_delivery ON notifications(scheduled_at)
+    WHERE delivered_at IS NULL;
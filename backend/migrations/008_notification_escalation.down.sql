@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_notifications_unacknowledged;
+
+ALTER TABLE notifications
+    DROP COLUMN IF EXISTS priority,
+    DROP COLUMN IF EXISTS acknowledged_at,
+    DROP COLUMN IF EXISTS acknowledged_by,
+    DROP COLUMN IF EXISTS escalated_at;
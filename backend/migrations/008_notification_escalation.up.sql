@@ -0,0 +1,10 @@
+-- Critical alert acknowledgement and escalation
+
+ALTER TABLE notifications
+    ADD COLUMN priority VARCHAR(20) NOT NULL DEFAULT 'normal' CHECK (priority IN ('normal', 'critical')),
+    ADD COLUMN acknowledged_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN acknowledged_by UUID REFERENCES users(id),
+    ADD COLUMN escalated_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_notifications_unacknowledged ON notifications(delivered_at)
+    WHERE priority = 'critical' AND acknowledged_at IS NULL AND escalated_at IS NULL;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_notifications_entity;
+
+ALTER TABLE notifications
+    DROP COLUMN IF EXISTS entity_type,
+    DROP COLUMN IF EXISTS entity_id,
+    DROP COLUMN IF EXISTS action,
+    DROP COLUMN IF EXISTS link;
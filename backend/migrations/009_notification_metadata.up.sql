@@ -0,0 +1,9 @@
+-- Structured metadata so clients can render actions on a notification
+
+ALTER TABLE notifications
+    ADD COLUMN entity_type VARCHAR(50),
+    ADD COLUMN entity_id UUID,
+    ADD COLUMN action VARCHAR(50),
+    ADD COLUMN link TEXT;
+
+CREATE INDEX idx_notifications_entity ON notifications(entity_type, entity_id);
@@ -0,0 +1,22 @@
+-- Generated report artifacts
+-- storage_key is the artifact's path relative to REPORT_DIR
+
+CREATE TABLE reports (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    type VARCHAR(50) NOT NULL,
+    format VARCHAR(10) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'completed', 'failed')),
+    parameters JSONB NOT NULL DEFAULT '{}',
+    storage_key TEXT,
+    filename VARCHAR(255),
+    size BIGINT NOT NULL DEFAULT 0,
+    row_count INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    requested_by UUID REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    started_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_reports_created_at ON reports(created_at DESC);
+CREATE INDEX idx_reports_requested_by ON reports(requested_by);
@@ -0,0 +1,18 @@
+-- Recurring reports emailed to a list of recipients
+
+CREATE TABLE report_schedules (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(200) NOT NULL,
+    parameters JSONB NOT NULL,
+    cron_expression VARCHAR(100) NOT NULL,
+    recipients JSONB NOT NULL DEFAULT '[]',
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    next_run_at TIMESTAMP WITH TIME ZONE,
+    last_run_at TIMESTAMP WITH TIME ZONE,
+    last_report_id UUID REFERENCES reports(id) ON DELETE SET NULL,
+    created_by UUID REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_report_schedules_next_run ON report_schedules(next_run_at) WHERE is_active = true;
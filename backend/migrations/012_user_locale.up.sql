@@ -0,0 +1,4 @@
+-- Per-user report locale (e.g. 'de'); NULL uses the organization default
+-- from the report_locale setting
+
+ALTER TABLE users ADD COLUMN locale VARCHAR(10);
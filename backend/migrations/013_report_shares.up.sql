@@ -0,0 +1,12 @@
+-- Generated reports shared with other users, who see them in their
+-- reports list alongside the ones they requested
+
+CREATE TABLE report_shares (
+    report_id UUID NOT NULL REFERENCES reports(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    shared_by UUID REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (report_id, user_id)
+);
+
+CREATE INDEX idx_report_shares_user ON report_shares(user_id);
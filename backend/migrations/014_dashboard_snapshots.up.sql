@@ -0,0 +1,13 @@
+-- Daily dashboard figures for trend charts. The current day's row is
+-- refreshed throughout the day, so each row ends up holding end-of-day values.
+
+CREATE TABLE dashboard_snapshots (
+    snapshot_date DATE PRIMARY KEY,
+    stock_value DECIMAL(14,2) NOT NULL DEFAULT 0,
+    total_stock INTEGER NOT NULL DEFAULT 0,
+    low_stock_count INTEGER NOT NULL DEFAULT 0,
+    movements INTEGER NOT NULL DEFAULT 0,
+    units_in INTEGER NOT NULL DEFAULT 0,
+    units_out INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_audit_logs_status_code;
+
+ALTER TABLE audit_logs
+    DROP COLUMN IF EXISTS status_code,
+    DROP COLUMN IF EXISTS error_message,
+    DROP COLUMN IF EXISTS duration_ms;
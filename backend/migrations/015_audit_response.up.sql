@@ -0,0 +1,9 @@
+-- Outcome of the audited request, so failed attempts can be told apart from
+-- changes that went through. NULL for entries written by handlers.
+
+ALTER TABLE audit_logs
+    ADD COLUMN status_code INTEGER,
+    ADD COLUMN error_message TEXT,
+    ADD COLUMN duration_ms INTEGER;
+
+CREATE INDEX idx_audit_logs_status_code ON audit_logs(status_code);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_audit_logs_unstreamed;
+
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS streamed_at;
@@ -0,0 +1,9 @@
+-- Tracks which audit entries have been forwarded to the external SIEM.
+-- Existing entries count as already sent so enabling streaming does not
+-- replay the whole history.
+
+ALTER TABLE audit_logs ADD COLUMN streamed_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE audit_logs SET streamed_at = changed_at;
+
+CREATE INDEX idx_audit_logs_unstreamed ON audit_logs(changed_at) WHERE streamed_at IS NULL;
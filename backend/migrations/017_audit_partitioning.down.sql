@@ -0,0 +1,34 @@
+-- Move audit entries back into a single unpartitioned table
+
+CREATE TABLE audit_logs_unpartitioned (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    table_name VARCHAR(100) NOT NULL,
+    record_id UUID NOT NULL,
+    action VARCHAR(20) NOT NULL CHECK (action IN ('create', 'update', 'delete', 'login', 'logout', 'view')),
+    old_values JSONB,
+    new_values JSONB,
+    changed_by UUID NOT NULL REFERENCES users(id),
+    changed_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    ip_address INET,
+    user_agent TEXT,
+    status_code INTEGER,
+    error_message TEXT,
+    duration_ms INTEGER,
+    streamed_at TIMESTAMP WITH TIME ZONE
+);
+
+INSERT INTO audit_logs_unpartitioned SELECT id, table_name, record_id, action, old_values, new_values, changed_by,
+    changed_at, ip_address, user_agent, status_code, error_message, duration_ms, streamed_at FROM audit_logs;
+
+-- Dropping the parent drops every partition with it
+DROP TABLE audit_logs;
+
+ALTER TABLE audit_logs_unpartitioned RENAME TO audit_logs;
+
+CREATE INDEX idx_audit_logs_table_name ON audit_logs(table_name);
+CREATE INDEX idx_audit_logs_record_id ON audit_logs(record_id);
+CREATE INDEX idx_audit_logs_changed_by ON audit_logs(changed_by);
+CREATE INDEX idx_audit_logs_changed_at ON audit_logs(changed_at);
+CREATE INDEX idx_audit_logs_action ON audit_logs(action);
+CREATE INDEX idx_audit_logs_status_code ON audit_logs(status_code);
+CREATE INDEX idx_audit_logs_unstreamed ON audit_logs(changed_at) WHERE streamed_at IS NULL;
@@ -0,0 +1,65 @@
+-- Partition audit_logs by month of changed_at so date-filtered queries only
+-- scan the months they cover, and old months can be dropped cheaply. The
+-- audit partition maintenance job creates upcoming months; rows outside
+-- every monthly partition land in audit_logs_default.
+
+ALTER TABLE audit_logs RENAME TO audit_logs_unpartitioned;
+
+DROP INDEX IF EXISTS idx_audit_logs_table_name;
+DROP INDEX IF EXISTS idx_audit_logs_record_id;
+DROP INDEX IF EXISTS idx_audit_logs_changed_by;
+DROP INDEX IF EXISTS idx_audit_logs_changed_at;
+DROP INDEX IF EXISTS idx_audit_logs_action;
+DROP INDEX IF EXISTS idx_audit_logs_status_code;
+DROP INDEX IF EXISTS idx_audit_logs_unstreamed;
+
+CREATE TABLE audit_logs (
+    id UUID NOT NULL DEFAULT uuid_generate_v4(),
+    table_name VARCHAR(100) NOT NULL,
+    record_id UUID NOT NULL,
+    action VARCHAR(20) NOT NULL CHECK (action IN ('create', 'update', 'delete', 'login', 'logout', 'view')),
+    old_values JSONB,
+    new_values JSONB,
+    changed_by UUID NOT NULL REFERENCES users(id),
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    ip_address INET,
+    user_agent TEXT,
+    status_code INTEGER,
+    error_message TEXT,
+    duration_ms INTEGER,
+    streamed_at TIMESTAMP WITH TIME ZONE,
+    -- The partition key has to be part of the primary key
+    PRIMARY KEY (id, changed_at)
+) PARTITION BY RANGE (changed_at);
+
+CREATE TABLE audit_logs_default PARTITION OF audit_logs DEFAULT;
+
+-- One partition per month from the oldest entry through the next three months
+DO $$
+DECLARE
+    month DATE := date_trunc('month', COALESCE((SELECT MIN(changed_at) FROM audit_logs_unpartitioned), NOW()));
+BEGIN
+    WHILE month <= date_trunc('month', NOW()) + INTERVAL '3 months' LOOP
+        EXECUTE format(
+            'CREATE TABLE audit_logs_y%sm%s PARTITION OF audit_logs FOR VALUES FROM (%L) TO (%L)',
+            to_char(month, 'YYYY'), to_char(month, 'MM'), month, month + INTERVAL '1 month'
+        );
+        month := month + INTERVAL '1 month';
+    END LOOP;
+END $$;
+
+INSERT INTO audit_logs (id, table_name, record_id, action, old_values, new_values, changed_by,
+                        changed_at, ip_address, user_agent, status_code, error_message, duration_ms, streamed_at)
+SELECT id, table_name, record_id, action, old_values, new_values, changed_by,
+       COALESCE(changed_at, NOW()), ip_address, user_agent, status_code, error_message, duration_ms, streamed_at
+FROM audit_logs_unpartitioned;
+
+DROP TABLE audit_logs_unpartitioned;
+
+CREATE INDEX idx_audit_logs_table_name ON audit_logs(table_name);
+CREATE INDEX idx_audit_logs_record_id ON audit_logs(record_id);
+CREATE INDEX idx_audit_logs_changed_by ON audit_logs(changed_by);
+CREATE INDEX idx_audit_logs_changed_at ON audit_logs(changed_at);
+CREATE INDEX idx_audit_logs_action ON audit_logs(action);
+CREATE INDEX idx_audit_logs_status_code ON audit_logs(status_code);
+CREATE INDEX idx_audit_logs_unstreamed ON audit_logs(changed_at) WHERE streamed_at IS NULL;
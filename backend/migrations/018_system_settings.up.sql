@@ -0,0 +1,18 @@
+-- Admin-editable settings, stored as text. The table used to be created by
+-- the API on first use, so it may already exist.
+
+CREATE TABLE IF NOT EXISTS system_settings (
+    key VARCHAR(255) PRIMARY KEY,
+    value TEXT,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO system_settings (key, value) VALUES
+('low_stock_threshold', '10'),
+('auto_backup', 'true'),
+('backup_frequency', 'daily'),
+('maintenance_mode', 'false'),
+('slack_webhook_url', ''),
+('teams_webhook_url', ''),
+('webhook_channels', '{}')
+ON CONFLICT (key) DO NOTHING;
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_notifications_user_updated;
+DROP INDEX IF EXISTS idx_products_updated_at;
+
+DROP TRIGGER IF EXISTS update_notifications_updated_at ON notifications;
+DROP TRIGGER IF EXISTS update_categories_updated_at ON categories;
+
+ALTER TABLE notifications DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE categories DROP COLUMN IF EXISTS updated_at;
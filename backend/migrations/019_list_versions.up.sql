@@ -0,0 +1,14 @@
+-- updated_at on categories and notifications so list endpoints can derive
+-- ETags from max(updated_at) and the row count
+
+ALTER TABLE categories ADD COLUMN updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW();
+UPDATE categories SET updated_at = created_at;
+
+ALTER TABLE notifications ADD COLUMN updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW();
+UPDATE notifications SET updated_at = COALESCE(delivered_at, created_at);
+
+CREATE TRIGGER update_categories_updated_at BEFORE UPDATE ON categories FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_notifications_updated_at BEFORE UPDATE ON notifications FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE INDEX idx_products_updated_at ON products(updated_at);
+CREATE INDEX idx_notifications_user_updated ON notifications(user_id, updated_at);
@@ -0,0 +1,24 @@
+-- Move stock movements back into a single unpartitioned table
+
+CREATE TABLE stock_movements_unpartitioned (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    change INTEGER NOT NULL, -- positive for in, negative for out
+    reason VARCHAR(20) NOT NULL CHECK (reason IN ('purchase', 'sale', 'adjustment', 'return', 'damage', 'transfer')),
+    created_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    notes TEXT
+);
+
+INSERT INTO stock_movements_unpartitioned SELECT id, product_id, change, reason, created_by, created_at, notes
+FROM stock_movements;
+
+-- Dropping the parent drops every partition with it
+DROP TABLE stock_movements;
+
+ALTER TABLE stock_movements_unpartitioned RENAME TO stock_movements;
+
+CREATE INDEX idx_stock_movements_product_id ON stock_movements(product_id);
+CREATE INDEX idx_stock_movements_created_by ON stock_movements(created_by);
+CREATE INDEX idx_stock_movements_created_at ON stock_movements(created_at);
+CREATE INDEX idx_stock_movements_reason ON stock_movements(reason);
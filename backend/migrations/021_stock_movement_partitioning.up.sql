@@ -0,0 +1,50 @@
+-- Partition stock_movements by month of created_at so date-filtered
+-- listings and reports only scan the months they cover. The partition
+-- maintenance job creates upcoming months; rows outside every monthly
+-- partition land in stock_movements_default.
+
+ALTER TABLE stock_movements RENAME TO stock_movements_unpartitioned;
+
+DROP INDEX IF EXISTS idx_stock_movements_product_id;
+DROP INDEX IF EXISTS idx_stock_movements_created_by;
+DROP INDEX IF EXISTS idx_stock_movements_created_at;
+DROP INDEX IF EXISTS idx_stock_movements_reason;
+
+CREATE TABLE stock_movements (
+    id UUID NOT NULL DEFAULT uuid_generate_v4(),
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    change INTEGER NOT NULL, -- positive for in, negative for out
+    reason VARCHAR(20) NOT NULL CHECK (reason IN ('purchase', 'sale', 'adjustment', 'return', 'damage', 'transfer')),
+    created_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    notes TEXT,
+    -- The partition key has to be part of the primary key
+    PRIMARY KEY (id, created_at)
+) PARTITION BY RANGE (created_at);
+
+CREATE TABLE stock_movements_default PARTITION OF stock_movements DEFAULT;
+
+-- One partition per month from the oldest movement through the next three months
+DO $$
+DECLARE
+    month DATE := date_trunc('month', COALESCE((SELECT MIN(created_at) FROM stock_movements_unpartitioned), NOW()));
+BEGIN
+    WHILE month <= date_trunc('month', NOW()) + INTERVAL '3 months' LOOP
+        EXECUTE format(
+            'CREATE TABLE stock_movements_y%sm%s PARTITION OF stock_movements FOR VALUES FROM (%L) TO (%L)',
+            to_char(month, 'YYYY'), to_char(month, 'MM'), month, month + INTERVAL '1 month'
+        );
+        month := month + INTERVAL '1 month';
+    END LOOP;
+END $$;
+
+INSERT INTO stock_movements (id, product_id, change, reason, created_by, created_at, notes)
+SELECT id, product_id, change, reason, created_by, COALESCE(created_at, NOW()), notes
+FROM stock_movements_unpartitioned;
+
+DROP TABLE stock_movements_unpartitioned;
+
+CREATE INDEX idx_stock_movements_product_id ON stock_movements(product_id, created_at);
+CREATE INDEX idx_stock_movements_created_by ON stock_movements(created_by);
+CREATE INDEX idx_stock_movements_created_at ON stock_movements(created_at);
+CREATE INDEX idx_stock_movements_reason ON stock_movements(reason);
@@ -0,0 +1,26 @@
+DROP TRIGGER IF EXISTS set_audit_logs_organization ON audit_logs;
+DROP TRIGGER IF EXISTS set_notifications_organization ON notifications;
+DROP TRIGGER IF EXISTS set_stock_movements_organization ON stock_movements;
+DROP FUNCTION IF EXISTS set_organization_from_changed_by();
+DROP FUNCTION IF EXISTS set_organization_from_user();
+DROP FUNCTION IF EXISTS set_organization_from_product();
+
+DROP INDEX IF EXISTS idx_audit_logs_organization_id;
+DROP INDEX IF EXISTS idx_notifications_organization_id;
+DROP INDEX IF EXISTS idx_stock_movements_organization_id;
+DROP INDEX IF EXISTS idx_users_organization_id;
+
+-- Fails if several organizations share a SKU or category name
+ALTER TABLE categories DROP CONSTRAINT IF EXISTS categories_organization_name_key;
+ALTER TABLE categories ADD CONSTRAINT categories_name_key UNIQUE (name);
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_organization_sku_key;
+ALTER TABLE products ADD CONSTRAINT products_sku_key UNIQUE (sku);
+
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE notifications DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE stock_movements DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE categories DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE products DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE users DROP COLUMN IF EXISTS organization_id;
+
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,81 @@
+-- Organizations let one instance host several subsidiaries. Users,
+-- products, categories, stock movements, notifications and audit logs
+-- belong to one organization; existing rows go to the default organization.
+-- Movements, notifications and audit logs take the organization of their
+-- product or user, set by trigger, so they can never disagree with it;
+-- entries without one, like system audit entries, go to the default.
+
+CREATE TABLE organizations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(200) NOT NULL,
+    slug VARCHAR(63) UNIQUE NOT NULL,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TRIGGER update_organizations_updated_at BEFORE UPDATE ON organizations FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+INSERT INTO organizations (id, name, slug) VALUES
+('00000000-0000-0000-0000-000000000001', 'Default', 'default');
+
+-- The default only fills existing rows; new rows must name their organization
+ALTER TABLE users ADD COLUMN organization_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES organizations(id);
+ALTER TABLE products ADD COLUMN organization_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES organizations(id);
+ALTER TABLE categories ADD COLUMN organization_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES organizations(id);
+ALTER TABLE stock_movements ADD COLUMN organization_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES organizations(id);
+ALTER TABLE notifications ADD COLUMN organization_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES organizations(id);
+ALTER TABLE audit_logs ADD COLUMN organization_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES organizations(id);
+
+ALTER TABLE users ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE products ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE categories ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE stock_movements ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE notifications ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE audit_logs ALTER COLUMN organization_id DROP DEFAULT;
+
+-- SKUs and category names only have to be unique within an organization;
+-- emails stay unique across the instance, since they identify who logs in
+ALTER TABLE products DROP CONSTRAINT products_sku_key;
+ALTER TABLE products ADD CONSTRAINT products_organization_sku_key UNIQUE (organization_id, sku);
+ALTER TABLE categories DROP CONSTRAINT categories_name_key;
+ALTER TABLE categories ADD CONSTRAINT categories_organization_name_key UNIQUE (organization_id, name);
+
+CREATE INDEX idx_users_organization_id ON users(organization_id);
+CREATE INDEX idx_stock_movements_organization_id ON stock_movements(organization_id, created_at);
+CREATE INDEX idx_notifications_organization_id ON notifications(organization_id);
+CREATE INDEX idx_audit_logs_organization_id ON audit_logs(organization_id, changed_at);
+
+CREATE OR REPLACE FUNCTION set_organization_from_product()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.organization_id := COALESCE(
+        (SELECT organization_id FROM products WHERE id = NEW.product_id),
+        '00000000-0000-0000-0000-000000000001');
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+CREATE OR REPLACE FUNCTION set_organization_from_user()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.organization_id := COALESCE(
+        (SELECT organization_id FROM users WHERE id = NEW.user_id),
+        '00000000-0000-0000-0000-000000000001');
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+CREATE OR REPLACE FUNCTION set_organization_from_changed_by()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.organization_id := COALESCE(
+        (SELECT organization_id FROM users WHERE id = NEW.changed_by),
+        '00000000-0000-0000-0000-000000000001');
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER set_stock_movements_organization BEFORE INSERT ON stock_movements FOR EACH ROW EXECUTE FUNCTION set_organization_from_product();
+CREATE TRIGGER set_notifications_organization BEFORE INSERT ON notifications FOR EACH ROW EXECUTE FUNCTION set_organization_from_user();
+CREATE TRIGGER set_audit_logs_organization BEFORE INSERT ON audit_logs FOR EACH ROW EXECUTE FUNCTION set_organization_from_changed_by();
@@ -0,0 +1,21 @@
+-- Database backups: one row per pg_dump run, with where the dump was
+-- stored and its size and SHA-256 checksum once it completes
+
+CREATE TABLE backups (
+    id UUID PRIMARY KEY,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('queued', 'running', 'completed', 'failed')),
+    storage VARCHAR(20) NOT NULL,
+    location TEXT,
+    size BIGINT NOT NULL DEFAULT 0,
+    checksum VARCHAR(64),
+    error TEXT,
+    requested_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_backups_created_at ON backups(created_at DESC);
+
+-- Only one backup runs at a time, across every instance
+CREATE UNIQUE INDEX backups_one_active ON backups ((true)) WHERE status IN ('queued', 'running');
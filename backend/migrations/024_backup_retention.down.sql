@@ -0,0 +1 @@
+DELETE FROM system_settings WHERE key IN ('backup_retention_count', 'backup_retention_days');
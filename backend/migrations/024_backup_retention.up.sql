@@ -0,0 +1,7 @@
+-- Backup retention defaults: keep the 14 newest completed backups, none
+-- older than 30 days. 0 turns either limit off.
+
+INSERT INTO system_settings (key, value) VALUES
+('backup_retention_count', '14'),
+('backup_retention_days', '30')
+ON CONFLICT (key) DO NOTHING;
@@ -0,0 +1,12 @@
+-- Settings history: every change to system_settings is recorded as a
+-- numbered revision holding the old and new value of each key it changed,
+-- so a bad change can be found and rolled back
+
+CREATE TABLE settings_revisions (
+    id UUID PRIMARY KEY,
+    version INTEGER NOT NULL UNIQUE,
+    changes JSONB NOT NULL,
+    changed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    rollback_of UUID REFERENCES settings_revisions(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
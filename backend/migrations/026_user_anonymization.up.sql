@@ -0,0 +1,5 @@
+-- Deleting a user anonymizes them: the row stays, so the stock movements,
+-- reports and audit entries that point at it keep their references, but
+-- the user's name, email and other personal data are scrubbed
+
+ALTER TABLE users ADD COLUMN anonymized_at TIMESTAMP WITH TIME ZONE;
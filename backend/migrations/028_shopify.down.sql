@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS shopify_order_lines;
+DROP TABLE IF EXISTS shopify_stock_pushes;
+DROP TABLE IF EXISTS shopify_variants;
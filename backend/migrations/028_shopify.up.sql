@@ -0,0 +1,48 @@
+-- Shopify inventory sync: products are mapped to the variants of the
+-- Shopify stores configured in settings. Stock updates from the outbox are
+-- queued as pushes of a product's stock to its variants, one row per
+-- inventory item so only the latest level is sent, and retried with backoff.
+-- The line items of Shopify orders are recorded as they are ingested as
+-- sale movements, so repeated webhooks do not move stock twice.
+
+CREATE TABLE shopify_variants (
+    shop VARCHAR(255) NOT NULL,
+    variant_id BIGINT NOT NULL,
+    inventory_item_id BIGINT NOT NULL,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    sku VARCHAR(100) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (shop, variant_id)
+);
+
+CREATE INDEX idx_shopify_variants_product_id ON shopify_variants(product_id);
+
+CREATE TABLE shopify_stock_pushes (
+    shop VARCHAR(255) NOT NULL,
+    inventory_item_id BIGINT NOT NULL,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    available INTEGER NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE,
+    error TEXT,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (shop, inventory_item_id)
+);
+
+CREATE INDEX idx_shopify_stock_pushes_due ON shopify_stock_pushes(next_attempt_at) WHERE next_attempt_at IS NOT NULL;
+
+CREATE TABLE shopify_order_lines (
+    shop VARCHAR(255) NOT NULL,
+    order_id BIGINT NOT NULL,
+    line_item_id BIGINT NOT NULL,
+    order_name VARCHAR(100) NOT NULL DEFAULT '',
+    variant_id BIGINT,
+    product_id UUID REFERENCES products(id) ON DELETE SET NULL,
+    quantity INTEGER NOT NULL,
+    movement_id UUID,
+    error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (shop, order_id, line_item_id)
+);
+
+CREATE INDEX idx_shopify_order_lines_created_at ON shopify_order_lines(shop, created_at);
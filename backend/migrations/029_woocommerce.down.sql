@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS woocommerce_runs;
+DROP TABLE IF EXISTS woocommerce_conflicts;
+DROP TABLE IF EXISTS woocommerce_products;
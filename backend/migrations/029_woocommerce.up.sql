@@ -0,0 +1,54 @@
+-- WooCommerce sync: products of the stores configured in settings are
+-- mapped to RTIMS products by SKU, keeping the stock both sides last
+-- agreed on. Reconciliation compares each side with it to tell which one
+-- changed: a change on one side is copied to the other, and changes on
+-- both are kept as conflicts until an admin resolves them.
+
+CREATE TABLE woocommerce_products (
+    store VARCHAR(100) NOT NULL,
+    woo_id BIGINT NOT NULL,
+    parent_id BIGINT NOT NULL DEFAULT 0,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    sku VARCHAR(100) NOT NULL,
+    synced_stock INTEGER,
+    synced_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (store, woo_id)
+);
+
+CREATE INDEX idx_woocommerce_products_product_id ON woocommerce_products(product_id);
+
+CREATE TABLE woocommerce_conflicts (
+    id UUID PRIMARY KEY,
+    store VARCHAR(100) NOT NULL,
+    woo_id BIGINT NOT NULL,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    sku VARCHAR(100) NOT NULL,
+    rtims_stock INTEGER NOT NULL,
+    woo_stock INTEGER NOT NULL,
+    synced_stock INTEGER,
+    detected_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    resolution VARCHAR(20) CHECK (resolution IN ('rtims', 'woocommerce', 'in_sync')),
+    resolved_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    resolved_at TIMESTAMP WITH TIME ZONE
+);
+
+-- One open conflict per WooCommerce product
+CREATE UNIQUE INDEX woocommerce_conflicts_open ON woocommerce_conflicts(store, woo_id) WHERE resolved_at IS NULL;
+CREATE INDEX idx_woocommerce_conflicts_detected_at ON woocommerce_conflicts(detected_at);
+
+CREATE TABLE woocommerce_runs (
+    id UUID PRIMARY KEY,
+    store VARCHAR(100) NOT NULL,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    finished_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    checked INTEGER NOT NULL DEFAULT 0,
+    pushed INTEGER NOT NULL DEFAULT 0,
+    pulled INTEGER NOT NULL DEFAULT 0,
+    conflicts INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    error TEXT
+);
+
+CREATE INDEX idx_woocommerce_runs_store ON woocommerce_runs(store, started_at);
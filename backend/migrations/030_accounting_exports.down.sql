@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS stock_movements_period_lock ON stock_movements;
+DROP TRIGGER IF EXISTS product_price_changes_period_lock ON product_price_changes;
+DROP FUNCTION IF EXISTS reject_locked_period_change();
+DROP TABLE IF EXISTS accounting_exports;
+DROP TRIGGER IF EXISTS record_products_price_change ON products;
+DROP FUNCTION IF EXISTS record_product_price_change();
+DROP TABLE IF EXISTS product_price_changes;
@@ -0,0 +1,85 @@
+-- Accounting exports: purchase, sale and other stock movements, and the
+-- revaluations of price changes, are exported as journal entries for
+-- QuickBooks or Xero. Each export covers the period since the one before
+-- it, and locks it: exported movements and price changes can no longer be
+-- changed or deleted, nor new ones dated into it.
+
+-- Price changes, with the stock they revalued, so movements are valued at
+-- the price of their time rather than today's
+CREATE TABLE product_price_changes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    organization_id UUID NOT NULL REFERENCES organizations(id),
+    old_price DECIMAL(10,2) NOT NULL,
+    new_price DECIMAL(10,2) NOT NULL,
+    stock INTEGER NOT NULL,
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_product_price_changes_product ON product_price_changes(product_id, changed_at);
+CREATE INDEX idx_product_price_changes_organization ON product_price_changes(organization_id, changed_at);
+
+CREATE OR REPLACE FUNCTION record_product_price_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.price IS DISTINCT FROM OLD.price THEN
+        INSERT INTO product_price_changes (product_id, organization_id, old_price, new_price, stock)
+        VALUES (NEW.id, NEW.organization_id, OLD.price, NEW.price, OLD.stock);
+    END IF;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER record_products_price_change AFTER UPDATE OF price ON products FOR EACH ROW EXECUTE FUNCTION record_product_price_change();
+
+CREATE TABLE accounting_exports (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    organization_id UUID NOT NULL REFERENCES organizations(id),
+    format VARCHAR(20) NOT NULL CHECK (format IN ('quickbooks_csv', 'quickbooks_iif', 'xero_csv')),
+    -- NULL for an organization's first export, which covers everything
+    -- before period_end
+    period_start TIMESTAMP WITH TIME ZONE,
+    period_end TIMESTAMP WITH TIME ZONE NOT NULL,
+    journals INTEGER NOT NULL DEFAULT 0,
+    debits DECIMAL(14,2) NOT NULL DEFAULT 0,
+    filename VARCHAR(255) NOT NULL,
+    content BYTEA NOT NULL,
+    created_by UUID REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_accounting_exports_period ON accounting_exports(organization_id, period_end DESC);
+
+-- reject_locked_period_change rejects changes to rows of an exported
+-- period. TG_ARGV[0] names the column that dates the row.
+CREATE OR REPLACE FUNCTION reject_locked_period_change()
+RETURNS TRIGGER AS $$
+DECLARE
+    changed JSONB;
+    locked_until TIMESTAMP WITH TIME ZONE;
+BEGIN
+    FOREACH changed IN ARRAY ARRAY[
+        CASE WHEN TG_OP <> 'INSERT' THEN to_jsonb(OLD) END,
+        CASE WHEN TG_OP <> 'DELETE' THEN to_jsonb(NEW) END
+    ] LOOP
+        CONTINUE WHEN changed IS NULL;
+        SELECT MAX(period_end) INTO locked_until FROM accounting_exports
+        WHERE organization_id = (changed->>'organization_id')::uuid;
+        IF locked_until IS NOT NULL AND (changed->>TG_ARGV[0])::timestamptz < locked_until THEN
+            RAISE EXCEPTION '% of % is in a period exported to accounting', TG_TABLE_NAME, changed->>TG_ARGV[0]
+                USING ERRCODE = 'check_violation', CONSTRAINT = 'accounting_period_locked';
+        END IF;
+    END LOOP;
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    END IF;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+-- Named to fire after set_stock_movements_organization, which fills in
+-- organization_id on insert
+CREATE TRIGGER stock_movements_period_lock BEFORE INSERT OR UPDATE OR DELETE ON stock_movements
+    FOR EACH ROW EXECUTE FUNCTION reject_locked_period_change('created_at');
+CREATE TRIGGER product_price_changes_period_lock BEFORE INSERT OR UPDATE OR DELETE ON product_price_changes
+    FOR EACH ROW EXECUTE FUNCTION reject_locked_period_change('changed_at');
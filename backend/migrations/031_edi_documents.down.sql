@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS edi_documents;
+DROP TABLE IF EXISTS edi_control_numbers;
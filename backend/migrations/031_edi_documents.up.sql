@@ -0,0 +1,28 @@
+-- EDI 846 inventory advice: documents generated for the trading partners
+-- configured in settings, kept with the outcome of their delivery so
+-- failed ones can be resent. Interchange control numbers count up per
+-- partner, as partners reject repeated ones.
+
+CREATE TABLE edi_control_numbers (
+    partner VARCHAR(100) PRIMARY KEY,
+    last_number INTEGER NOT NULL
+);
+
+CREATE TABLE edi_documents (
+    id UUID PRIMARY KEY,
+    partner VARCHAR(100) NOT NULL,
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    control_number INTEGER NOT NULL,
+    items INTEGER NOT NULL,
+    filename VARCHAR(255) NOT NULL,
+    content BYTEA NOT NULL,
+    transport VARCHAR(50) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_edi_documents_partner ON edi_documents(partner, created_at);
+CREATE INDEX idx_edi_documents_organization_id ON edi_documents(organization_id);
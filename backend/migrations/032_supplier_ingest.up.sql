@@ -0,0 +1,24 @@
+-- Supplier file ingestion: stock files suppliers drop in an SFTP or S3
+-- folder, as configured in settings, are applied as stock movements and
+-- price updates. Each file is recorded with the outcome of every row; a
+-- file with the same name and content is never applied twice, even if it
+-- could not be moved out of the drop folder.
+
+CREATE TABLE ingest_files (
+    id UUID PRIMARY KEY,
+    supplier VARCHAR(100) NOT NULL,
+    filename VARCHAR(255) NOT NULL,
+    checksum CHAR(64) NOT NULL,
+    organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('applied', 'partial', 'failed')),
+    total_rows INTEGER NOT NULL DEFAULT 0,
+    applied INTEGER NOT NULL DEFAULT 0,
+    unchanged INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    results JSONB NOT NULL DEFAULT '[]',
+    processed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX ingest_files_content ON ingest_files(supplier, filename, checksum);
+CREATE INDEX idx_ingest_files_processed_at ON ingest_files(supplier, processed_at);
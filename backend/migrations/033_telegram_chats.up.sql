@@ -0,0 +1,12 @@
+-- Telegram chats linked to users with a code from their profile. The bot
+-- answers commands in a linked chat as its user and sends it the user's
+-- critical alerts.
+
+CREATE TABLE telegram_chats (
+    chat_id BIGINT PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    title VARCHAR(255) NOT NULL DEFAULT '',
+    linked_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_telegram_chats_user_id ON telegram_chats(user_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS movement_attachments;
+DROP TABLE IF EXISTS product_images;
@@ -0,0 +1,29 @@
+-- Product images and stock movement attachments. The files are kept in the
+-- object store under storage_key; the storage_cleanup job deletes objects
+-- no row refers to any more.
+
+CREATE TABLE product_images (
+    product_id UUID PRIMARY KEY REFERENCES products(id) ON DELETE CASCADE,
+    storage_key TEXT NOT NULL UNIQUE,
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    uploaded_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- stock_movements is partitioned with a composite key, so movement_id
+-- cannot reference it; the storage_cleanup job deletes the attachments of
+-- movements that are gone
+CREATE TABLE movement_attachments (
+    id UUID PRIMARY KEY,
+    movement_id UUID NOT NULL,
+    storage_key TEXT NOT NULL UNIQUE,
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    uploaded_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_movement_attachments_movement ON movement_attachments(movement_id, created_at);
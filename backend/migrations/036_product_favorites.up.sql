@@ -0,0 +1,11 @@
+-- Products users marked as favorites, to find the SKUs they handle daily.
+-- Recently viewed products are kept in Redis instead.
+
+CREATE TABLE product_favorites (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, product_id)
+);
+
+CREATE INDEX idx_product_favorites_product ON product_favorites(product_id);
@@ -0,0 +1,19 @@
+-- Comments on products and stock movements, so shop-floor context stays
+-- with the stock it is about. A comment on a movement also carries the
+-- movement's product and goes with it; stock_movements is partitioned with
+-- a composite key, so movement_id cannot reference it.
+
+CREATE TABLE comments (
+    id UUID PRIMARY KEY,
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    movement_id UUID,
+    author_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    body TEXT NOT NULL,
+    mentions UUID[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_comments_product ON comments(product_id, created_at) WHERE movement_id IS NULL;
+CREATE INDEX idx_comments_movement ON comments(movement_id, created_at) WHERE movement_id IS NOT NULL;
+CREATE INDEX idx_comments_author ON comments(author_id);
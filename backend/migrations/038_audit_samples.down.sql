@@ -0,0 +1,3 @@
+ALTER TABLE audit_logs
+    DROP COLUMN IF EXISTS request_body,
+    DROP COLUMN IF EXISTS response_body;
@@ -0,0 +1,7 @@
+-- Request and response bodies of the sampled share of mutating requests,
+-- with sensitive fields masked, to debug client integrations. NULL for
+-- requests that were not sampled and entries written by handlers.
+
+ALTER TABLE audit_logs
+    ADD COLUMN request_body JSONB,
+    ADD COLUMN response_body JSONB;
@@ -0,0 +1,28 @@
+-- Stock movement imports: CSV files of historical stock movements, such as
+-- those of the inventory system used before, loaded in the background. The
+-- valid rows of a file are imported in one transaction, and the import
+-- records how many rows it has processed as it goes and, once done, the
+-- rows it could not import. Imported movements are history: they do not
+-- change the stock of their products. An organization cannot import the
+-- same file twice, unless the earlier import failed.
+
+CREATE TABLE stock_movement_imports (
+    id UUID PRIMARY KEY,
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    filename VARCHAR(255) NOT NULL,
+    checksum CHAR(64) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'completed', 'failed')),
+    total_rows INTEGER NOT NULL DEFAULT 0,
+    processed_rows INTEGER NOT NULL DEFAULT 0,
+    imported INTEGER NOT NULL DEFAULT 0,
+    invalid INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    results JSONB NOT NULL DEFAULT '[]',
+    requested_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE UNIQUE INDEX stock_movement_imports_content ON stock_movement_imports(organization_id, checksum) WHERE status <> 'failed';
+CREATE INDEX idx_stock_movement_imports_created_at ON stock_movement_imports(organization_id, created_at DESC);
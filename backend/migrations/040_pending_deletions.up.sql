@@ -0,0 +1,19 @@
+-- Deletions of users, categories and products an admin can still undo.
+-- DELETE schedules the removal for execute_at, delete_undo_minutes later,
+-- and the pending_deletions job carries it out once that has passed;
+-- undoing it drops the row. Users cannot log in while their deletion is
+-- pending; restore_active is whether to reactivate them on undo.
+
+CREATE TABLE pending_deletions (
+    id UUID PRIMARY KEY,
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    table_name VARCHAR(20) NOT NULL CHECK (table_name IN ('users', 'categories', 'products')),
+    record_id UUID NOT NULL,
+    restore_active BOOLEAN,
+    requested_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    requested_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    execute_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    CONSTRAINT pending_deletions_record_key UNIQUE (table_name, record_id)
+);
+
+CREATE INDEX idx_pending_deletions_execute_at ON pending_deletions(execute_at);
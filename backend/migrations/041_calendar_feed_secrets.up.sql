@@ -0,0 +1,4 @@
+-- The secret each user's calendar feed token is derived from, created when
+-- the user first asks for the link. Regenerating it revokes the old link.
+
+ALTER TABLE users ADD COLUMN calendar_feed_secret VARCHAR(64);
@@ -0,0 +1,4 @@
+DELETE FROM dashboard_snapshots WHERE organization_id <> '00000000-0000-0000-0000-000000000001';
+ALTER TABLE dashboard_snapshots DROP CONSTRAINT dashboard_snapshots_pkey;
+ALTER TABLE dashboard_snapshots ADD PRIMARY KEY (snapshot_date);
+ALTER TABLE dashboard_snapshots DROP COLUMN organization_id;
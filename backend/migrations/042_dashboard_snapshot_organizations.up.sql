@@ -0,0 +1,9 @@
+-- Dashboard snapshots belong to an organization, one row per organization
+-- a day. Existing rows summed the whole instance and go to the default
+-- organization, which they describe while it is the only one.
+
+ALTER TABLE dashboard_snapshots ADD COLUMN organization_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES organizations(id) ON DELETE CASCADE;
+ALTER TABLE dashboard_snapshots ALTER COLUMN organization_id DROP DEFAULT;
+
+ALTER TABLE dashboard_snapshots DROP CONSTRAINT dashboard_snapshots_pkey;
+ALTER TABLE dashboard_snapshots ADD PRIMARY KEY (snapshot_date, organization_id);
@@ -0,0 +1 @@
+DELETE FROM notification_templates WHERE key = 'critical_escalation';
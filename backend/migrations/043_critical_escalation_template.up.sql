@@ -0,0 +1,5 @@
+-- Template for escalating a critical alert nobody acknowledged
+
+INSERT INTO notification_templates (key, type, body, description) VALUES
+('critical_escalation', 'system', 'Critical alert not acknowledged within {{window}}: {{message}}',
+ 'Sent to admins when a critical alert is not acknowledged in time. Variables: window, message');
@@ -0,0 +1,10 @@
+// Package migrations embeds the SQL schema migrations so the API binary can
+// apply them itself. Files follow golang-migrate naming
+// (NNN_name.up.sql / NNN_name.down.sql), so the migrate CLI works on this
+// directory too.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
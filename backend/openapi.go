@@ -0,0 +1,571 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"rtims-backend/internal/graphql"
+	"rtims-backend/internal/handlers"
+	"rtims-backend/internal/models"
+	"rtims-backend/internal/openapi"
+	"rtims-backend/internal/reports"
+	"rtims-backend/internal/settings"
+	"rtims-backend/internal/userimport"
+
+	"github.com/google/uuid"
+)
+
+// apiInfo heads the OpenAPI document served at /openapi.json.
+var apiInfo = openapi.Info{
+	Title:       "RTIMS API",
+	Version:     "1.0",
+	Description: "Real-Time Inventory Management System API. Errors are answered with {\"error\", \"code\", \"details\", \"request_id\"}; clients should act on code.",
+}
+
+// message is the body of responses that only confirm an action.
+var message = openapi.Object{"message": "Done"}
+
+// hostOnly describes routes limited to admins of the default organization,
+// which change what every organization sees.
+const hostOnly = "Limited to admins of the default organization."
+
+// apiOperations describes the routes registered in main, keyed by
+// "METHOD /path" as gin reports them. Routes missing here are still
+// documented, with their handler's name as the summary.
+func apiOperations() map[string]openapi.Operation {
+	exampleID := uuid.MustParse("3f6c2b1e-9d1a-4c5e-8b7a-2e4f6a8c0d12")
+	operations := map[string]openapi.Operation{
+		// Health
+		"GET /health":  {Summary: "Liveness probe (deprecated, use /healthz)", Tag: "Health", Public: true, Response: handlers.HealthResponse{}},
+		"GET /healthz": {Summary: "Liveness probe", Tag: "Health", Public: true, Response: handlers.HealthResponse{}},
+		"GET /readyz": {Summary: "Readiness probe", Tag: "Health", Public: true, Response: handlers.ReadinessResponse{},
+			Description: "Answers 503 while a required dependency is down; degraded is set while an optional one is."},
+
+		// Authentication
+		"POST /api/v1/auth/register": {Summary: "Register a staff account", Tag: "Auth", Public: true, Status: http.StatusCreated,
+			Body:     models.RegisterRequest{Name: "Dewi Lestari", Email: "dewi@example.com", Password: "correct-horse-battery"},
+			Response: models.AuthResponse{}},
+		"POST /api/v1/auth/login": {Summary: "Log in", Tag: "Auth", Public: true,
+			Body:     models.LoginRequest{Email: "admin@rtims.local", Password: "admin12345"},
+			Response: models.AuthResponse{}},
+		"POST /api/v1/auth/refresh": {Summary: "Exchange a refresh token for a new access token", Tag: "Auth", Public: true,
+			Body: models.RefreshTokenRequest{}, Response: models.AuthResponse{}},
+		"POST /api/v1/auth/logout": {Summary: "Revoke a refresh token", Tag: "Auth", Public: true,
+			Body: models.RefreshTokenRequest{}, Response: message},
+		"POST /api/v1/auth/forgot-password": {Summary: "Email a password reset link", Tag: "Auth", Public: true,
+			Body: openapi.Object{"email": "dewi@example.com"}, Response: message},
+		"POST /api/v1/auth/reset-password": {Summary: "Set a new password with a reset token", Tag: "Auth", Public: true,
+			Body: openapi.Object{"token": "", "password": ""}, Response: message},
+		"POST /api/v1/dev/seed": {Summary: "Load demo data (host admins, where DEV_SEED_ENABLED is set)", Tag: "Development", Admin: true,
+			Description: "admin_password is the random password of the demo accounts, answered only when this call creates them.",
+			Response:    openapi.Object{"message": "", "created": map[string]int{}, "admin_email": "", "admin_password": ""}},
+		"GET /api/v1/test-auth": {Summary: "Check an access token", Tag: "Auth",
+			Response: openapi.Object{"message": "", "user_id": uuid.UUID{}, "email": "", "role": models.UserRole("")}},
+
+		// Profile
+		"GET /api/v1/profile": {Summary: "Get the current user", Tag: "Profile", Response: models.User{}},
+		"PUT /api/v1/profile": {Summary: "Update the current user", Tag: "Profile",
+			Body: models.UpdateUserRequest{}, Response: models.User{}},
+		"POST /api/v1/profile/telegram/link-code": {Summary: "Create a Telegram link code", Tag: "Profile", Status: http.StatusCreated,
+			Description: "A code to send the Telegram bot as /link CODE, which links the chat it is sent in to the current user. Codes expire after 10 minutes and work once.",
+			Response:    models.TelegramLinkCode{}},
+		"GET /api/v1/profile/telegram/chats": {Summary: "List linked Telegram chats", Tag: "Profile",
+			Description: "The Telegram chats linked to the current user, which receive their critical alerts.",
+			Response:    []models.TelegramChat{}},
+		"DELETE /api/v1/profile/telegram/chats/:chat_id": {Summary: "Unlink a Telegram chat", Tag: "Profile", Response: message},
+		"GET /api/v1/profile/recent-products": {Summary: "List recently viewed products", Tag: "Profile",
+			Description: "The last 20 products the current user opened, newest first. Views are kept in Redis for 30 days; the list is empty while Redis is down.",
+			Response:    []models.Product{}},
+		"GET /api/v1/profile/favorites": {Summary: "List favorite products", Tag: "Profile",
+			Description: "The products the current user marked as favorites, most recently marked first.",
+			Response:    []models.Product{}},
+
+		// Dashboard
+		"GET /api/v1/dashboard/stats":  {Summary: "Get dashboard statistics", Tag: "Dashboard", Response: map[string]interface{}{}},
+		"GET /api/v1/dashboard/alerts": {Summary: "Get dashboard alerts", Tag: "Dashboard", Response: []map[string]interface{}{}},
+		"GET /api/v1/dashboard/trends": {Summary: "Get a daily series of a dashboard metric", Tag: "Dashboard",
+			Description: "metric is one of the trend metrics, period a number of days such as 30d.",
+			Query: struct {
+				Metric string `form:"metric"`
+				Period string `form:"period"`
+			}{},
+			Response: openapi.Object{"metric": models.TrendMetric(""), "period": "30d", "points": []models.TrendPoint{}}},
+
+		// Products
+		"GET /api/v1/products/": {Summary: "List products", Tag: "Products",
+			Description: "When SEARCH_URL is set, a search is answered from the search index: names and categories match by word, allowing for typos, SKUs by prefix, and results come most relevant first unless sort_by is set.",
+			Query:       models.ProductFilter{}, Response: openapi.Page("products", []models.Product{})},
+		"GET /api/v1/products/:id": {Summary: "Get a product", Tag: "Products", Response: models.Product{}},
+		"POST /api/v1/products/": {Summary: "Create a product", Tag: "Products", Status: http.StatusCreated,
+			Body: models.CreateProductRequest{Name: "Cordless Drill 18V", SKU: "TL-1001", Stock: 25, Price: 89.9,
+				Category: "Tools", MinimumThreshold: 5},
+			Response: models.Product{}},
+		"PUT /api/v1/products/:id": {Summary: "Update a product", Tag: "Products",
+			Body: models.UpdateProductRequest{}, Response: models.Product{}},
+		"DELETE /api/v1/products/:id": {Summary: "Delete a product", Tag: "Products", Response: message,
+			Description: "Once delete_undo_minutes (default 10) have passed; until then the answer is 202 with the pending deletion, which POST /api/v1/products/:id/undo cancels. With delete_undo_minutes 0 it is immediate."},
+		"POST /api/v1/products/:id/undo": {Summary: "Undo a product's deletion", Tag: "Products", Response: message,
+			Description: "Answers 404 no_pending_deletion once the deletion has been carried out."},
+		"POST /api/v1/products/:id/stock": {Summary: "Record a stock movement", Tag: "Products",
+			Description: "change is positive for stock in and negative for stock out. Answers 409 insufficient_stock when stock would go below zero.",
+			Body:        models.CreateStockMovementRequest{Change: -3, Reason: models.ReasonSale, Notes: "Order #1042"},
+			Response:    openapi.Object{"message": "", "stock_movement": models.StockMovement{}}},
+		"GET /api/v1/products/:id/image": {Summary: "Get a product's image", Tag: "Products", Response: models.ProductImage{},
+			Description: "url is a signed URL that shows the image until STORAGE_URL_EXPIRY_MINUTES have passed."},
+		"PUT /api/v1/products/:id/image": {Summary: "Upload a product's image", Tag: "Products", Response: models.ProductImage{},
+			Description: "multipart/form-data with a PNG, JPEG, GIF or WebP file in the image field, at most 5MB. Replaces the image the product had."},
+		"DELETE /api/v1/products/:id/image": {Summary: "Delete a product's image", Tag: "Products", Response: message},
+		"POST /api/v1/products/:id/favorite": {Summary: "Add a product to the current user's favorites", Tag: "Products",
+			Description: "Adding a favorite again changes nothing.", Response: message},
+		"DELETE /api/v1/products/:id/favorite": {Summary: "Remove a product from the current user's favorites", Tag: "Products", Response: message},
+		"GET /api/v1/products/:id/comments": {Summary: "List the comments on a product", Tag: "Comments",
+			Description: "Oldest first. Comments on the product's stock movements are listed with their movement.",
+			Response:    []models.Comment{}},
+		"POST /api/v1/products/:id/comments": {Summary: "Comment on a product", Tag: "Comments", Status: http.StatusCreated,
+			Description: "Users mentioned as @ followed by their email address, or the part before its @, are notified.",
+			Body:        models.CreateCommentRequest{Body: "@budi the shelf count is 3 short, please recount"},
+			Response:    models.Comment{}},
+
+		// Stock movements
+		"GET /api/v1/stock-movements/": {Summary: "List stock movements", Tag: "Stock Movements",
+			Query: models.StockMovementFilter{}, Response: openapi.Page("movements", []models.StockMovement{})},
+		"GET /api/v1/stock-movements/:id": {Summary: "Get a stock movement", Tag: "Stock Movements", Response: models.StockMovement{}},
+		"GET /api/v1/stock-movements/:id/attachments": {Summary: "List a stock movement's attachments", Tag: "Stock Movements",
+			Description: "Each url is a signed URL that downloads the file until STORAGE_URL_EXPIRY_MINUTES have passed.",
+			Response:    []models.MovementAttachment{}},
+		"POST /api/v1/stock-movements/:id/attachments": {Summary: "Attach a file to a stock movement", Tag: "Stock Movements",
+			Status: http.StatusCreated, Response: models.MovementAttachment{},
+			Description: "multipart/form-data with a PDF, CSV, text, XLSX, DOCX or image file in the file field, at most 20MB."},
+		"DELETE /api/v1/stock-movements/:id/attachments/:attachment_id": {Summary: "Delete a stock movement's attachment",
+			Tag: "Stock Movements", Response: message},
+		"POST /api/v1/stock-movements/import": {Summary: "Import historical stock movements from CSV", Tag: "Stock Movements", Admin: true,
+			Description: "multipart/form-data with a CSV file of at most 5MB and 10000 rows in the file field, such as one exported from the system RTIMS replaces. The header names the columns: sku, change (signed, e.g. -3), date (e.g. 2024-01-31 or 2024-01-31T09:30:00Z, UTC without a zone), and optionally reason (default adjustment) and notes. " +
+				"Rows are invalid when their SKU is unknown, or their date is in the future or in a period exported to accounting. With dry_run=true rows are only validated, and results are per row, as JSON or, with format=csv, as a CSV download. " +
+				"Otherwise the valid rows are imported in the background, all or none, and the queued import is returned with status 202; follow its progress with GET /api/v1/stock-movements/imports/:id. Imported movements keep their date and do not change current stock. The same file cannot be imported twice unless its earlier import failed.",
+			Query: struct {
+				DryRun bool   `form:"dry_run"`
+				Format string `form:"format"`
+			}{},
+			Status:   http.StatusAccepted,
+			Response: models.MovementImport{}},
+		"GET /api/v1/stock-movements/imports": {Summary: "List stock movement imports", Tag: "Stock Movements", Admin: true,
+			Description: "The latest 20, newest first, without their results.",
+			Response:    openapi.Object{"imports": []models.MovementImport{}}},
+		"GET /api/v1/stock-movements/imports/:id": {Summary: "Get a stock movement import", Tag: "Stock Movements", Admin: true,
+			Description: "Its progress, processed of rows, and once completed the rows it could not import.",
+			Response:    models.MovementImport{}},
+		"GET /api/v1/stock-movements/:id/comments": {Summary: "List the comments on a stock movement", Tag: "Comments",
+			Description: "Oldest first.", Response: []models.Comment{}},
+		"POST /api/v1/stock-movements/:id/comments": {Summary: "Comment on a stock movement", Tag: "Comments", Status: http.StatusCreated,
+			Description: "Users mentioned as @ followed by their email address, or the part before its @, are notified.",
+			Body:        models.CreateCommentRequest{Body: "Damaged in transit, photos attached"},
+			Response:    models.Comment{}},
+		"DELETE /api/v1/comments/:id": {Summary: "Delete a comment", Tag: "Comments",
+			Description: "Users delete their own comments; admins delete any comment of their organization.", Response: message},
+
+		// Categories
+		"GET /api/v1/categories/": {Summary: "List categories", Tag: "Categories", Response: []models.Category{}},
+		"POST /api/v1/categories/": {Summary: "Create a category", Tag: "Categories", Status: http.StatusCreated,
+			Body: models.CreateCategoryRequest{Name: "Tools"}, Response: models.Category{}},
+		"PUT /api/v1/categories/:id": {Summary: "Update a category", Tag: "Categories",
+			Body: models.UpdateCategoryRequest{}, Response: models.Category{}},
+		"DELETE /api/v1/categories/:id": {Summary: "Delete a category", Tag: "Categories", Response: message,
+			Description: "Answers 409 category_in_use while products belong to it; merge it into another category instead. " +
+				"The category is deleted once delete_undo_minutes (default 10) have passed; until then the answer is 202 with the pending deletion, which POST /api/v1/categories/:id/undo cancels."},
+		"POST /api/v1/categories/:id/undo": {Summary: "Undo a category's deletion", Tag: "Categories", Response: message,
+			Description: "Answers 404 no_pending_deletion once the deletion has been carried out."},
+		"POST /api/v1/admin/categories/:id/merge": {Summary: "Merge a category into another", Tag: "Categories",
+			Description: "Moves every product of the category to target_id and deletes the category, in one transaction. " +
+				"Each product moved gets an audit entry, as does the deleted category.",
+			Body: models.MergeCategoryRequest{}, Response: models.CategoryMerge{}},
+
+		// Reports shared with their requester
+		"GET /api/v1/reports/": {Summary: "List my reports and those shared with me", Tag: "Reports", Response: []models.Report{}},
+		"GET /api/v1/reports/:id/shares": {Summary: "List who a report is shared with", Tag: "Reports",
+			Response: openapi.Object{"user_ids": []uuid.UUID{}}},
+		"POST /api/v1/reports/:id/shares": {Summary: "Share a report", Tag: "Reports",
+			Body: models.ShareReportRequest{UserIDs: []uuid.UUID{exampleID}}, Response: message},
+		"DELETE /api/v1/reports/:id/shares/:user_id": {Summary: "Stop sharing a report", Tag: "Reports", Response: message},
+
+		// Users
+		"GET /api/v1/admin/users": {Summary: "List users", Tag: "Users",
+			Query: models.UserFilter{}, Response: openapi.Page("users", []models.User{})},
+		"POST /api/v1/admin/users": {Summary: "Create a user", Tag: "Users", Status: http.StatusCreated,
+			Body:     models.CreateUserRequest{Name: "Budi Santoso", Email: "budi@example.com", Password: "correct-horse-battery", Role: models.RoleStaff},
+			Response: models.User{}},
+		"POST /api/v1/admin/users/import": {Summary: "Import users from CSV", Tag: "Users",
+			Description: "multipart/form-data with a CSV file of at most 1MB and 1000 rows in the file field. The header names the columns: name, email, role (staff or admin, default staff) and warehouse, the slug of the organization to join; only host admins may name an organization other than their own. " +
+				"With dry_run=true rows are only validated. Otherwise valid rows are created and emailed an invitation to choose a password, valid for 7 days, and invalid rows are reported as failed. Results are per row, as JSON or, with format=csv, as a CSV download.",
+			Query: struct {
+				DryRun bool   `form:"dry_run"`
+				Format string `form:"format"`
+			}{},
+			Response: openapi.Object{"dry_run": false, "summary": userimport.Summary{}, "results": []userimport.Result{}}},
+		"PUT /api/v1/admin/users/:id": {Summary: "Update a user", Tag: "Users", Body: models.UpdateUserRequest{}, Response: models.User{}},
+		"DELETE /api/v1/admin/users/:id": {Summary: "Delete a user", Tag: "Users",
+			Description: "Anonymizes the user: their name, email and other personal data are scrubbed, and they can no longer log in, but their stock movements and audit entries keep referencing them. " +
+				"The user is deactivated at once and anonymized once delete_undo_minutes (default 10) have passed; until then the answer is 202 with the pending deletion, which POST /api/v1/admin/users/:id/undo cancels.",
+			Response: message},
+		"POST /api/v1/admin/users/:id/undo": {Summary: "Undo a user's deletion", Tag: "Users", Response: message,
+			Description: "Reactivates the user if they were active. Answers 404 no_pending_deletion once the deletion has been carried out."},
+		"GET /api/v1/admin/pending-deletions": {Summary: "List pending deletions", Tag: "Users",
+			Description: "The users, categories and products whose deletion can still be undone, the soonest first.",
+			Response:    openapi.Object{"deletions": []models.PendingDeletion{}}},
+		"GET /api/v1/admin/users/:id/export": {Summary: "Export a user's personal data", Tag: "Users",
+			Description: "A zip of one JSON file per section: profile, notifications and preferences, stock movements, reports, report schedules, announcements, and audit entries made by or about the user. Exports are audited."},
+		"GET /api/v1/admin/users/:id/activity": {Summary: "Get a user's activity", Tag: "Users",
+			Description: "The user's audited actions, logins, logouts and failed login attempts, and stock movements between start_date and end_date, by default the last 90 days. " +
+				"summary counts the whole period; timeline holds the newest limit entries (default 100, at most 500), and truncated says whether there were more. Token refreshes are left out.",
+			Query: models.ActivityFilter{}, Response: models.UserActivity{}},
+
+		// Report administration
+		"GET /api/v1/admin/reports/stats":  {Summary: "Get report statistics", Tag: "Reports", Response: map[string]interface{}{}},
+		"GET /api/v1/admin/reports/recent": {Summary: "List recent reports", Tag: "Reports", Response: []models.Report{}},
+		"POST /api/v1/admin/reports/branding/logo": {Summary: "Upload the logo printed on PDF reports", Tag: "Reports", Response: message,
+			Description: "multipart/form-data with a PNG or JPEG file in the logo field, at most 2MB."},
+		"GET /api/v1/admin/reports/schedules": {Summary: "List report schedules", Tag: "Report Schedules", Response: []models.ReportSchedule{}},
+		"POST /api/v1/admin/reports/schedules": {Summary: "Create a report schedule", Tag: "Report Schedules", Status: http.StatusCreated,
+			Body: models.CreateReportScheduleRequest{Name: "Weekly inventory", Report: json.RawMessage(`{"type":"inventory","format":"pdf"}`),
+				CronExpression: "0 7 * * 1", Recipients: []string{"ops@example.com"}},
+			Response: models.ReportSchedule{}},
+		"PUT /api/v1/admin/reports/schedules/:id": {Summary: "Update a report schedule", Tag: "Report Schedules",
+			Body: models.UpdateReportScheduleRequest{}, Response: models.ReportSchedule{}},
+		"DELETE /api/v1/admin/reports/schedules/:id": {Summary: "Delete a report schedule", Tag: "Report Schedules", Response: message},
+
+		// Settings
+		"GET /api/v1/admin/settings": {Summary: "Get system settings", Tag: "Settings",
+			Description: "Every setting of the schema with its typed value, or its default when unset. Secrets read as [REDACTED].", Response: map[string]interface{}{}},
+		"GET /api/v1/admin/settings/schema": {Summary: "Describe the system settings", Tag: "Settings",
+			Description: "Settings by group, with their types, defaults and allowed values.", Response: []settings.Group{}},
+		"PUT /api/v1/admin/settings": {Summary: "Update system settings", Tag: "Settings",
+			Description: hostOnly + " Only the keys sent are changed; unknown keys are rejected, and null resets a setting to its default. Secrets sent back as [REDACTED] keep their value.",
+			Body:        map[string]interface{}{"low_stock_threshold": 10, "auto_backup": true}, Response: map[string]interface{}{}},
+		"GET /api/v1/admin/settings/revisions": {Summary: "List recent settings changes", Tag: "Settings",
+			Description: hostOnly + " Each revision has the old and new value of every key it changed, with secrets masked.",
+			Response:    openapi.Object{"revisions": []models.SettingsRevision{}}},
+		"POST /api/v1/admin/settings/revisions/:id/rollback": {Summary: "Undo a settings change", Tag: "Settings",
+			Description: hostOnly + " Restores the values the revision replaced, as a new revision; keys it set for the first time go back to their default.",
+			Response:    openapi.Object{"revision": models.SettingsRevision{}, "settings": map[string]interface{}{}}},
+		"GET /api/v1/admin/settings/status": {Summary: "Get system status", Tag: "Settings",
+			Description: hostOnly + " Database and connection pool, read replica lag, Redis INFO stats, storage, backups, WebSocket connections, and this instance's version, uptime and Go runtime.",
+			Response:    map[string]interface{}{}},
+		"POST /api/v1/admin/settings/backup": {Summary: "Start a database backup", Tag: "Settings", Status: http.StatusAccepted,
+			Description: hostOnly + " Poll the backups list for its status; 409 while another backup is running.", Response: models.Backup{}},
+		"GET /api/v1/admin/settings/backups": {Summary: "List recent backups", Tag: "Settings", Description: hostOnly,
+			Response: openapi.Object{"backups": []models.Backup{}}},
+		"GET /api/v1/admin/settings/backups/:id/download": {Summary: "Download a backup's pg_dump archive", Tag: "Settings",
+			Description: hostOnly + " Downloads are audited."},
+		"DELETE /api/v1/admin/settings/backups/:id": {Summary: "Delete a backup and its dump", Tag: "Settings", Description: hostOnly,
+			Response: message},
+
+		// Announcements
+		"POST /api/v1/admin/announcements": {Summary: "Broadcast an announcement", Tag: "Announcements", Status: http.StatusCreated, Description: hostOnly,
+			Body:     models.CreateAnnouncementRequest{Title: "Stocktake on Friday", Message: "The warehouse closes at 15:00.", Severity: models.SeverityInfo},
+			Response: models.Announcement{}},
+		"GET /api/v1/announcements/active": {Summary: "List active announcements", Tag: "Announcements", Response: []models.Announcement{}},
+
+		// Notification templates
+		"GET /api/v1/admin/notification-templates": {Summary: "List notification templates", Tag: "Notifications",
+			Response: []models.NotificationTemplate{}},
+		"PUT /api/v1/admin/notification-templates/:key": {Summary: "Update a notification template", Tag: "Notifications", Description: hostOnly,
+			Body:     models.UpdateNotificationTemplateRequest{Body: "Product '{{product.name}}' stock is low ({{stock}} remaining)"},
+			Response: models.NotificationTemplate{}},
+
+		// Webhooks
+		"GET /api/v1/admin/webhooks": {Summary: "List webhooks", Tag: "Webhooks", Response: []models.Webhook{}},
+		"POST /api/v1/admin/webhooks": {Summary: "Create a webhook", Tag: "Webhooks", Status: http.StatusCreated,
+			Description: "Subscribes url to events: product.created, stock.low, movement.created and user.updated. The response holds the secret that signs deliveries, which is not shown again. " +
+				"Every delivery is a JSON POST with the headers X-RTIMS-Event, X-RTIMS-Delivery, X-RTIMS-Timestamp and X-RTIMS-Signature, sha256= followed by the hex HMAC-SHA256 of the timestamp, a dot and the body. " +
+				"Deliveries answered with a network error, 429 or 5xx are retried with exponential backoff from 30 seconds, 8 attempts in all; other responses outside 2xx fail them.",
+			Body:     models.CreateWebhookRequest{URL: "https://erp.example.com/hooks/rtims", Description: "ERP sync", Events: []models.WebhookEvent{models.WebhookProductCreated, models.WebhookStockLow}},
+			Response: models.Webhook{}},
+		"GET /api/v1/admin/webhooks/:id": {Summary: "Get a webhook", Tag: "Webhooks", Response: models.Webhook{}},
+		"PUT /api/v1/admin/webhooks/:id": {Summary: "Update a webhook", Tag: "Webhooks",
+			Description: "Changes the fields that are set. With rotate_secret the secret is replaced and returned once. Deliveries of an inactive webhook wait until it is activated again.",
+			Body:        models.UpdateWebhookRequest{}, Response: models.Webhook{}},
+		"DELETE /api/v1/admin/webhooks/:id": {Summary: "Delete a webhook and its delivery log", Tag: "Webhooks", Response: message},
+		"GET /api/v1/admin/webhooks/:id/deliveries": {Summary: "Get a webhook's delivery log", Tag: "Webhooks",
+			Description: "The newest limit deliveries (default 50, at most 200), optionally only those with status pending, succeeded or failed. Finished deliveries are kept for 30 days.",
+			Query:       models.WebhookDeliveryFilter{}, Response: []models.WebhookDelivery{}},
+		"POST /api/v1/admin/webhooks/:id/test": {Summary: "Test-fire a webhook", Tag: "Webhooks",
+			Description: "Sends a ping event right away, even to an inactive webhook, and returns the delivery with the response status. The ping is not retried.",
+			Response:    models.WebhookDelivery{}},
+
+		// Telegram
+		"POST /api/v1/integrations/telegram/webhook": {Summary: "Receive a Telegram update", Tag: "Telegram", Public: true,
+			Description: "Called by Telegram, not clients. X-Telegram-Bot-Api-Secret-Token must be the telegram_webhook_secret setting. " +
+				"Commands from linked chats are answered as the linked user, with the reply as the response."},
+		"POST /api/v1/admin/telegram/webhook": {Summary: "Register the Telegram webhook", Tag: "Telegram",
+			Description: hostOnly + " Points the bot in the telegram_bot_token setting at this server's PUBLIC_URL, which must be https, with the telegram_webhook_secret setting, and sets the commands Telegram suggests.",
+			Response:    openapi.Object{"url": ""}},
+
+		// Search
+		"POST /api/v1/admin/search/reindex": {Summary: "Rebuild the search index", Tag: "Search", Status: http.StatusAccepted,
+			Description: hostOnly + " Empties the search indexes and copies every product, stock movement and audit entry into them in the background. " +
+				"Answers 409 search_disabled when SEARCH_URL is not set and search_reindex_running while a reindex runs.",
+			Response: message},
+
+		// Shopify
+		"POST /api/v1/integrations/shopify/webhooks": {Summary: "Receive a Shopify webhook", Tag: "Shopify", Public: true,
+			Description: "Called by Shopify, not clients. X-Shopify-Hmac-Sha256 must be the base64 HMAC-SHA256 of the body keyed by the webhook_secret of the store named by X-Shopify-Shop-Domain. " +
+				"orders/create records every line item whose variant is mapped as a sale of its product; line items already recorded are skipped, so Shopify's retries are safe. Other topics are ignored."},
+		"GET /api/v1/admin/shopify/stores": {Summary: "List Shopify stores", Tag: "Shopify",
+			Description: hostOnly + " The stores configured in the shopify_stores setting, with how many variants are mapped, how many stock pushes are waiting or have failed, and when an order was last received.",
+			Response:    []models.ShopifyStore{}},
+		"GET /api/v1/admin/shopify/stores/:shop/variants": {Summary: "List a Shopify store's mapped variants", Tag: "Shopify", Description: hostOnly,
+			Response: []models.ShopifyVariant{}},
+		"POST /api/v1/admin/shopify/stores/:shop/variants/sync": {Summary: "Map a Shopify store's variants by SKU", Tag: "Shopify",
+			Description: hostOnly + " Maps every variant of the store to the product of the store user's organization with its SKU, and pushes the stock of every mapped product. Variants mapped by hand keep their product unless their SKU matches another.",
+			Response:    models.ShopifyVariantSync{Unmatched: []string{"TSHIRT-XL"}}},
+		"PUT /api/v1/admin/shopify/stores/:shop/variants/:variant_id": {Summary: "Map a Shopify variant to a product", Tag: "Shopify",
+			Description: hostOnly + " For variants whose SKU does not match. The product's stock is pushed to the variant.",
+			Body:        models.MapShopifyVariantRequest{ProductID: exampleID}, Response: models.ShopifyVariant{}},
+		"DELETE /api/v1/admin/shopify/stores/:shop/variants/:variant_id": {Summary: "Stop syncing a Shopify variant", Tag: "Shopify", Description: hostOnly,
+			Response: message},
+		"GET /api/v1/admin/shopify/stores/:shop/orders": {Summary: "Get a Shopify store's received order lines", Tag: "Shopify",
+			Description: hostOnly + " The newest limit line items (default 50, at most 200), with the sale movement each was recorded as, or why it was not.",
+			Query:       models.ShopifyOrderFilter{}, Response: []models.ShopifyOrderLine{}},
+
+		// WooCommerce
+		"GET /api/v1/admin/woocommerce/status": {Summary: "Get WooCommerce sync status", Tag: "WooCommerce",
+			Description: hostOnly + " The stores configured in the woocommerce_stores setting, with how many products are mapped, how many conflicts are open and their last reconciliation, and every open conflict. " +
+				"A conflict is a product whose stock changed both in RTIMS and in WooCommerce since they last agreed, or that differed when first mapped; it is not synced until it is resolved.",
+			Response: models.WooCommerceStatus{}},
+		"POST /api/v1/admin/woocommerce/stores/:store/reconcile": {Summary: "Reconcile a WooCommerce store now", Tag: "WooCommerce",
+			Description: hostOnly + " Runs the store's reconciliation without waiting for the woocommerce_reconcile job, and returns how many products it pushed, pulled and found in conflict.",
+			Response:    models.WooCommerceRun{}},
+		"POST /api/v1/admin/woocommerce/conflicts/:id/resolve": {Summary: "Resolve a WooCommerce conflict", Tag: "WooCommerce",
+			Description: hostOnly + " Gives both sides the current stock of the side to keep. Keeping WooCommerce records the difference as an adjustment by you.",
+			Body:        models.ResolveWooCommerceConflictRequest{Keep: models.ResolutionKeepRTIMS}, Response: models.WooCommerceConflict{}},
+
+		// Accounting
+		"GET /api/v1/admin/accounting/exports": {Summary: "List accounting exports", Tag: "Accounting",
+			Description: "The journals exported for your organization, newest first. Each covers the period from the end of the one before it, or from the start for the first.",
+			Response:    []models.AccountingExport{}},
+		"POST /api/v1/admin/accounting/exports": {Summary: "Export the accounting journal", Tag: "Accounting", Status: http.StatusCreated,
+			Description: "Exports the stock movements and price changes since the last export through the day through (UTC), which must have ended, as one journal entry per day and kind, valued at the prices of the time. " +
+				"The period is then locked: its movements can no longer be changed or deleted, so neither can products that have them. Answers 409 accounting_period_exported if the day has already been exported. Exports are audited.",
+			Body:     models.CreateAccountingExportRequest{Format: models.AccountingXeroCSV, Through: "2026-09-30"},
+			Response: models.AccountingExport{}},
+		"GET /api/v1/admin/accounting/exports/:id/download": {Summary: "Download an accounting export", Tag: "Accounting",
+			ContentType: "text/csv", Description: "The CSV or, for quickbooks_iif, IIF file to import."},
+
+		// EDI
+		"GET /api/v1/admin/edi/partners": {Summary: "List EDI trading partners", Tag: "EDI",
+			Description: hostOnly + " The partners configured in the edi_partners setting, with the last inventory advice generated for each. Delivery options are not returned.",
+			Response:    []models.EDIPartner{}},
+		"POST /api/v1/admin/edi/partners/:partner/send": {Summary: "Send a partner its inventory advice now", Tag: "EDI", Status: http.StatusCreated,
+			Description: hostOnly + " Generates an EDI 846 of the partner's current stock without waiting for the edi_846 job and delivers it by the partner's transport. " +
+				"A document that could not be delivered is still saved and returned with status failed and the error, to be resent.",
+			Response: models.EDIDocument{}},
+		"GET /api/v1/admin/edi/documents": {Summary: "List EDI documents", Tag: "EDI",
+			Description: hostOnly + " The documents generated in the last 90 days, newest first, 100 unless limit says otherwise.",
+			Query:       models.EDIDocumentFilter{}, Response: []models.EDIDocument{}},
+		"GET /api/v1/admin/edi/documents/:id/download": {Summary: "Download an EDI document", Tag: "EDI",
+			ContentType: "application/EDI-X12", Description: hostOnly + " The X12 interchange as it was sent."},
+		"POST /api/v1/admin/edi/documents/:id/resend": {Summary: "Resend an EDI document", Tag: "EDI",
+			Description: hostOnly + " Delivers the document again by its partner's current transport, with its original control number.",
+			Response:    models.EDIDocument{}},
+
+		// Supplier ingest
+		"GET /api/v1/admin/ingest/suppliers": {Summary: "List ingest suppliers", Tag: "Ingest",
+			Description: hostOnly + " The suppliers configured in the ingest_suppliers setting, with where their files are picked up and the last file processed. Credentials are not returned.",
+			Response:    []models.IngestSupplier{}},
+		"POST /api/v1/admin/ingest/suppliers/:supplier/run": {Summary: "Ingest a supplier's files now", Tag: "Ingest",
+			Description: hostOnly + " Picks up the supplier's new files without waiting for the supplier_ingest job and returns those processed. " +
+				"Files already processed with the same name and content are archived without being applied again.",
+			Response: []models.IngestFile{}},
+		"GET /api/v1/admin/ingest/files": {Summary: "List supplier files", Tag: "Ingest",
+			Description: hostOnly + " The files processed in the last 180 days, newest first, 100 unless limit says otherwise, without their row results.",
+			Query:       models.IngestFileFilter{}, Response: []models.IngestFile{}},
+		"GET /api/v1/admin/ingest/files/:id": {Summary: "Get a supplier file", Tag: "Ingest",
+			Description: hostOnly + " The file with the outcome of each of its rows.",
+			Response:    models.IngestFile{}},
+
+		// Google Sheets exports
+		"POST /api/v1/admin/sheets/exports/:export/run": {Summary: "Push a Sheets export now", Tag: "Sheets",
+			Description: hostOnly + " Replaces the contents of the export's sheet with fresh figures without waiting for the sheets_export job. " +
+				"Answers 409 sheets_not_configured when the google_service_account setting is empty and 502 sheets_unavailable when Google rejects the push, " +
+				"for example because the spreadsheet is not shared with the service account.",
+			Response: models.SheetsPush{}},
+
+		// Alerting
+		"POST /api/v1/admin/alerts/sinks/:sink/test": {Summary: "Send a test alert to a sink", Tag: "Alerting",
+			Description: hostOnly + " Raises a warning in the alert sink, whatever the alert_routes setting says, to check that it is set up. " +
+				"Answers 502 alert_sink_unavailable when the sink cannot be reached or rejects the alert.",
+			Response: message},
+
+		// Calendar
+		"GET /api/v1/calendar/events": {Summary: "List calendar events", Tag: "Calendar",
+			Description: "The scheduled stocktakes and maintenance windows overlapping start_date to end_date, by default the next 90 days, earliest first.",
+			Query:       models.CalendarEventFilter{}, Response: []models.CalendarEvent{}},
+		"POST /api/v1/admin/calendar/events": {Summary: "Schedule a stocktake or maintenance window", Tag: "Calendar", Status: http.StatusCreated,
+			Body: models.CreateCalendarEventRequest{Kind: models.CalendarStocktake, Title: "Quarterly stocktake", StartsAt: time.Date(2025, 3, 29, 8, 0, 0, 0, time.UTC),
+				EndsAt: time.Date(2025, 3, 29, 17, 0, 0, 0, time.UTC)},
+			Response: models.CalendarEvent{}},
+		"PUT /api/v1/admin/calendar/events/:id": {Summary: "Update a calendar event", Tag: "Calendar",
+			Description: "Changes the fields that are set.",
+			Body:        models.UpdateCalendarEventRequest{}, Response: models.CalendarEvent{}},
+		"DELETE /api/v1/admin/calendar/events/:id": {Summary: "Delete a calendar event", Tag: "Calendar", Response: message},
+		"GET /api/v1/profile/calendar-feed": {Summary: "Get the link of the current user's calendar feed", Tag: "Calendar",
+			Description: "An iCalendar link to subscribe to in Google Calendar, Outlook or Apple Calendar. It holds a token derived from a secret of the current user and " +
+				"stops working when it is regenerated, the user or their organization is deactivated or JWT_SECRET changes.",
+			Response: models.CalendarFeed{}},
+		"POST /api/v1/profile/calendar-feed/regenerate": {Summary: "Regenerate the link of the current user's calendar feed", Tag: "Calendar",
+			Description: "Replaces the secret the link is derived from, so the link given out before stops working, and returns the new link.",
+			Response:    models.CalendarFeed{}},
+		"GET /api/v1/calendar/:token/feed.ics": {Summary: "Get a calendar feed", Tag: "Calendar", Public: true, ContentType: "text/calendar",
+			Description: "The stocktakes and maintenance windows of the user's organization from 30 days ago to 90 days ahead and, for admins, the upcoming runs of its report schedules. " +
+				"Answers 403 calendar_feed_invalid for a token that is not valid."},
+
+		// Organizations
+		"GET /api/v1/organizations/": {Summary: "List organizations", Tag: "Organizations", Admin: true, Description: hostOnly,
+			Response: []models.Organization{}},
+		"POST /api/v1/organizations/": {Summary: "Create an organization and its first admin", Tag: "Organizations", Admin: true, Description: hostOnly,
+			Status: http.StatusCreated,
+			Body: models.CreateOrganizationRequest{Name: "RTIMS Surabaya", Slug: "surabaya",
+				Admin: models.OrganizationAdmin{Name: "Budi Santoso", Email: "budi@surabaya.example.com", Password: "correct-horse-battery"}},
+			Response: models.Organization{}},
+		"GET /api/v1/organizations/:id": {Summary: "Get an organization", Tag: "Organizations", Admin: true, Description: hostOnly,
+			Response: models.Organization{}},
+		"PUT /api/v1/organizations/:id": {Summary: "Rename or (de)activate an organization", Tag: "Organizations", Admin: true,
+			Description: hostOnly + " Users of a deactivated organization cannot log in.",
+			Body:        models.UpdateOrganizationRequest{}, Response: models.Organization{}},
+
+		// Notifications
+		"GET /api/v1/notifications/": {Summary: "List my notifications", Tag: "Notifications",
+			Query: models.NotificationFilter{}, Response: openapi.Page("notifications", []models.Notification{})},
+		"GET /api/v1/notifications/preferences": {Summary: "Get my notification preferences", Tag: "Notifications",
+			Response: openapi.Object{"preferences": []models.NotificationPreference{}, "email_enabled": false}},
+		"PUT /api/v1/notifications/preferences": {Summary: "Update my notification preferences", Tag: "Notifications",
+			Body: models.UpdateNotificationPreferencesRequest{Preferences: []models.NotificationPreference{
+				{Type: models.NotificationLowStock, EmailEnabled: true},
+			}},
+			Response: openapi.Object{"preferences": []models.NotificationPreference{}, "email_enabled": false}},
+		"PUT /api/v1/notifications/:id/read": {Summary: "Mark a notification read", Tag: "Notifications",
+			Response: openapi.Object{"message": "", "id": uuid.UUID{}, "user_id": uuid.UUID{}}},
+		"PUT /api/v1/notifications/:id/acknowledge": {Summary: "Acknowledge a critical alert", Tag: "Notifications",
+			Response: openapi.Object{"message": "", "id": uuid.UUID{}}},
+		"PUT /api/v1/notifications/:id/archive": {Summary: "Archive a notification", Tag: "Notifications",
+			Response: openapi.Object{"id": uuid.UUID{}, "is_archived": true}},
+		"PUT /api/v1/notifications/:id/unarchive": {Summary: "Unarchive a notification", Tag: "Notifications",
+			Response: openapi.Object{"id": uuid.UUID{}, "is_archived": false}},
+		"DELETE /api/v1/notifications/:id": {Summary: "Delete a notification", Tag: "Notifications", Response: message},
+		"PUT /api/v1/notifications/read-all": {Summary: "Mark all my notifications read", Tag: "Notifications",
+			Response: openapi.Object{"message": "", "updated": 0}},
+		"POST /api/v1/notifications/bulk": {Summary: "Update many notifications at once", Tag: "Notifications",
+			Description: "Applies the action to the notifications in ids, to those matching a filter with at least one field, or with all: true to every delivered notification of the caller.",
+			Body:        models.BulkNotificationRequest{Action: models.BulkActionMarkRead, IDs: []uuid.UUID{exampleID}},
+			Response:    openapi.Object{"message": "", "action": models.BulkNotificationAction(""), "affected": 0}},
+		"POST /api/v1/notifications/": {Summary: "Send a notification to a user", Tag: "Notifications", Status: http.StatusCreated,
+			Body:     models.CreateNotificationRequest{UserID: exampleID, Message: "Please recount aisle 4", Type: models.NotificationUser},
+			Response: models.Notification{}},
+
+		// Audit logs
+		"GET /api/v1/audit-logs/": {Summary: "List audit log entries", Tag: "Audit Logs",
+			Query: models.AuditLogFilter{}, Response: openapi.Page("audit_logs", []models.AuditLog{})},
+		"GET /api/v1/audit-logs/record/:table/:record_id": {Summary: "Get the history of a record", Tag: "Audit Logs",
+			Response: openapi.Object{"table_name": "", "record_id": uuid.UUID{}, "timeline": []models.AuditTimelineEntry{}}},
+		"GET /api/v1/audit-logs/:id": {Summary: "Get an audit log entry", Tag: "Audit Logs", Response: models.AuditLog{},
+			Description: "Sampled requests, see the audit_sample_percent setting, include their request and response bodies with sensitive fields masked."},
+		"POST /api/v1/audit-logs/:id/restore": {Summary: "Restore a record to its state before an entry", Tag: "Audit Logs", Admin: true,
+			Description: "Refused with 400, naming the fields, when a value it would restore was redacted as [REDACTED] when the entry was recorded.",
+			Response:    openapi.Object{"message": "", "table_name": "", "record_id": uuid.UUID{}, "values": map[string]interface{}{}}},
+		"GET /api/v1/activity-feed": {Summary: "List the organization's activity", Tag: "Audit Logs",
+			Description: "Changes to products and categories and stock movements, newest first, each with a message such as \"Ana received 50× SKU-123\" in the language of Accept-Language. entity is product, category or stock_movement; entity_id of a product also matches its stock movements. New entries are pushed over the WebSocket as activity events.",
+			Query:       models.ActivityFeedFilter{}, Response: openapi.Page("entries", []models.FeedEntry{})},
+
+		// API v2
+		"GET /api/v2/products": {Summary: "List products by cursor", Tag: "API v2",
+			Description: "Newest first. Pass next_cursor as cursor for the next page; it is null on the last one.",
+			Query: struct {
+				Search       string   `form:"search"`
+				Category     string   `form:"category"`
+				MinStock     *int     `form:"min_stock"`
+				MaxStock     *int     `form:"max_stock"`
+				MinPrice     *float64 `form:"min_price"`
+				MaxPrice     *float64 `form:"max_price"`
+				LowStockOnly bool     `form:"low_stock_only"`
+				models.CursorQuery
+			}{},
+			Response: openapi.Object{"data": []models.ProductV2{}, "next_cursor": ""}},
+		"GET /api/v2/products/:id": {Summary: "Get a product with its category", Tag: "API v2", Response: models.ProductV2{}},
+		"GET /api/v2/stock-movements": {Summary: "List stock movements by cursor", Tag: "API v2",
+			Description: "Newest first. Pass next_cursor as cursor for the next page; it is null on the last one.",
+			Query: struct {
+				ProductID *uuid.UUID             `form:"product_id"`
+				Reason    *models.MovementReason `form:"reason"`
+				StartDate *time.Time             `form:"start_date"`
+				EndDate   *time.Time             `form:"end_date"`
+				models.CursorQuery
+			}{},
+			Response: openapi.Object{"data": []models.StockMovement{}, "next_cursor": ""}},
+
+		// GraphQL
+		"GET /graphql": {Summary: "Run a GraphQL query from the query string", Tag: "GraphQL",
+			Description: "Takes query, operationName and variables (as JSON) as query parameters. See POST /graphql.",
+			Query: struct {
+				Query         string `form:"query"`
+				OperationName string `form:"operationName"`
+				Variables     string `form:"variables"`
+			}{},
+			Response: graphql.Response{}},
+		"POST /graphql": {Summary: "Run a GraphQL query", Tag: "GraphQL",
+			Description: "Queries products, product, movements, movement, categories and notifications. Products have their category and movements, movements their product, and categories their products. Fields that fail are null with an entry in errors; queries that do not parse or validate answer 422.",
+			Body:        graphql.Request{Query: "{ products(limit: 10) { name stock category { name } } }"},
+			Response:    graphql.Response{}},
+
+		"GET /ws": {Summary: "Open the WebSocket for live updates", Tag: "WebSocket", Public: true,
+			Description: "Upgrades to a WebSocket. Pass the access token as the token query parameter."},
+		"GET /openapi.json": {Summary: "Get this document", Tag: "Documentation", Admin: true, Response: map[string]interface{}{}},
+	}
+
+	// Report routes are served both to every user, limited by the
+	// report_permissions setting, and under /admin
+	for _, prefix := range []string{"/api/v1/reports", "/api/v1/admin/reports"} {
+		root := prefix + "/"
+		if strings.Contains(prefix, "/admin/") {
+			root = prefix
+		}
+		operations["GET "+prefix+"/types"] = openapi.Operation{Summary: "List report types", Tag: "Reports", Response: []map[string]interface{}{}}
+		operations["POST "+root] = openapi.Operation{Summary: "Generate a report in the background", Tag: "Reports",
+			Status: http.StatusAccepted, Body: reports.Params{Type: "inventory", Format: "xlsx", Category: "Tools"}, Response: models.Report{},
+			Description: "Poll the job until its status is completed, then download it. Answers 503 report_queue_full when the queue is full."}
+		operations["GET "+prefix+"/jobs/:id"] = openapi.Operation{Summary: "Get a background report", Tag: "Reports", Response: models.Report{}}
+		operations["GET "+prefix+"/:id/download"] = openapi.Operation{Summary: "Download a completed report", Tag: "Reports",
+			ContentType: "application/octet-stream", Description: "Answers 409 report_not_ready until the report is completed."}
+		for _, report := range []struct {
+			name  string
+			query interface{}
+		}{
+			{"inventory", models.InventoryReportFilter{}},
+			{"movements", models.MovementReportFilter{}},
+			{"users", models.UserReportFilter{}},
+			{"financial", models.FinancialReportFilter{}},
+		} {
+			operations["GET "+prefix+"/"+report.name] = openapi.Operation{Summary: "Generate the " + report.name + " report", Tag: "Reports",
+				Query: report.query, Response: reports.Report{},
+				Description: "JSON by default; format=csv, pdf or xlsx answers with the file instead."}
+		}
+	}
+
+	// Admins also manage categories under /admin
+	for _, route := range []string{"GET /categories", "POST /categories", "PUT /categories/:id", "DELETE /categories/:id"} {
+		method, path, _ := strings.Cut(route, " ")
+		user := method + " /api/v1" + path
+		if !strings.HasSuffix(path, ":id") {
+			user += "/"
+		}
+		operations[method+" /api/v1/admin"+path] = operations[user]
+	}
+
+	for key, op := range operations {
+		if strings.Contains(key, " /api/v1/admin/") {
+			op.Admin = true
+			operations[key] = op
+		}
+	}
+	return operations
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"rtims-backend/internal/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAPIOperations(t *testing.T) {
+	operations := apiOperations()
+	var routes gin.RoutesInfo
+	for key, op := range operations {
+		method, path, _ := strings.Cut(key, " ")
+		routes = append(routes, gin.RouteInfo{Method: method, Path: path})
+		if op.Summary == "" || op.Tag == "" {
+			t.Errorf("%s: expected a summary and tag", key)
+		}
+		if strings.HasPrefix(path, "/api/v1/admin/") && !op.Admin {
+			t.Errorf("%s: expected to be admin only", key)
+		}
+	}
+
+	doc := openapi.Build(apiInfo, routes, operations)
+	paths := doc["paths"].(map[string]map[string]interface{})
+	login := paths["/api/v1/auth/login"]["post"].(map[string]interface{})
+	if _, ok := login["security"]; ok {
+		t.Error("expected login to need no token")
+	}
+	if _, ok := paths["/openapi.json"]["get"].(map[string]interface{})["security"]; !ok {
+		t.Error("expected the document to need a token")
+	}
+	if doc.ReadDoc() == "{}" {
+		t.Error("expected the document to encode")
+	}
+}
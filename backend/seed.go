@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"rtims-backend/config"
+	"rtims-backend/internal/database"
+	"rtims-backend/internal/seed"
+)
+
+// runSeedCommand handles `rtims-backend seed [flags]` and returns the
+// process exit code. It refuses to touch a production database.
+func runSeedCommand(cfg *config.Config, args []string) int {
+	if cfg.Environment == "production" {
+		fmt.Fprintln(os.Stderr, "seed is for development and demo databases; ENVIRONMENT is production")
+		return 2
+	}
+
+	opts := seed.DefaultOptions()
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	flags.StringVar(&opts.AdminEmail, "admin-email", opts.AdminEmail, "email of the admin account")
+	flags.StringVar(&opts.AdminPassword, "admin-password", opts.AdminPassword, "password for the admin and staff accounts (random if empty)")
+	flags.IntVar(&opts.Products, "products", opts.Products, "number of products to create")
+	flags.IntVar(&opts.Days, "days", opts.Days, "days of stock movement history")
+	flags.Int64Var(&opts.RandSeed, "rand-seed", opts.RandSeed, "random seed, for reproducible data")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	db := database.InitDB(cfg.DatabaseURL, dbPool(cfg))
+	defer db.Close()
+
+	result, err := seed.Run(db, opts)
+	if err != nil {
+		log.Printf("Seeding failed: %v", err)
+		return 1
+	}
+	log.Printf("Seeded %d user(s), %d categories, %d products and %d stock movements",
+		result.Users, result.Categories, result.Products, result.Movements)
+	if result.Password != "" {
+		log.Printf("Log in as %s with password %s", opts.AdminEmail, result.Password)
+	}
+	return 0
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+
+	"rtims-backend/config"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// configureTLS sets srv up to serve HTTPS when the config asks for it,
+// either from TLS_CERT_FILE/TLS_KEY_FILE or with certificates Let's Encrypt
+// issues for TLS_AUTOCERT_DOMAINS. It returns the plain HTTP server that
+// redirects to HTTPS (and answers ACME challenges), or nil if none is
+// configured. With neither option set srv is left serving plain HTTP.
+func configureTLS(cfg *config.Config, srv *http.Server) (*http.Server, error) {
+	manual := cfg.TLSCertFile != "" || cfg.TLSKeyFile != ""
+	automatic := len(cfg.TLSAutocertDomains) > 0
+	switch {
+	case manual && automatic:
+		return nil, errors.New("set either TLS_CERT_FILE/TLS_KEY_FILE or TLS_AUTOCERT_DOMAINS, not both")
+	case manual && (cfg.TLSCertFile == "" || cfg.TLSKeyFile == ""):
+		return nil, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	case !manual && !automatic:
+		return nil, nil
+	}
+
+	redirect := redirectToHTTPS(cfg.Port)
+	if manual {
+		srv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	} else {
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.TLSAutocertDomains...),
+			Cache:      autocert.DirCache(cfg.TLSAutocertCacheDir),
+			Email:      cfg.TLSAutocertEmail,
+		}
+		srv.TLSConfig = manager.TLSConfig()
+		srv.TLSConfig.MinVersion = tls.VersionTLS12
+		// HTTP-01 challenges arrive on the redirect port; everything else
+		// is redirected
+		redirect = manager.HTTPHandler(redirect)
+	}
+
+	if cfg.HTTPRedirectPort == "" {
+		return nil, nil
+	}
+	return &http.Server{
+		Addr:    ":" + cfg.HTTPRedirectPort,
+		Handler: redirect,
+	}, nil
+}
+
+// redirectToHTTPS permanently redirects requests to the same host and path
+// on the HTTPS port.
+func redirectToHTTPS(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"rtims-backend/config"
+)
+
+func TestConfigureTLS(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.Config
+		tls      bool
+		redirect bool
+		wantErr  bool
+	}{
+		{"plain http", config.Config{Port: "8080", HTTPRedirectPort: "80"}, false, false, false},
+		{"certificate files", config.Config{Port: "443", TLSCertFile: "cert.pem", TLSKeyFile: "key.pem"}, true, false, false},
+		{"certificate files with redirect", config.Config{Port: "443", TLSCertFile: "cert.pem", TLSKeyFile: "key.pem", HTTPRedirectPort: "80"}, true, true, false},
+		{"autocert", config.Config{Port: "443", TLSAutocertDomains: []string{"inventory.example.com"}, TLSAutocertCacheDir: t.TempDir(), HTTPRedirectPort: "80"}, true, true, false},
+		{"key without certificate", config.Config{Port: "443", TLSKeyFile: "key.pem"}, false, false, true},
+		{"both sources", config.Config{Port: "443", TLSCertFile: "cert.pem", TLSKeyFile: "key.pem", TLSAutocertDomains: []string{"inventory.example.com"}}, false, false, true},
+	}
+	for _, tt := range tests {
+		srv := &http.Server{}
+		redirect, err := configureTLS(&tt.cfg, srv)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if (srv.TLSConfig != nil) != tt.tls {
+			t.Errorf("%s: expected TLS %v", tt.name, tt.tls)
+		}
+		if (redirect != nil) != tt.redirect {
+			t.Errorf("%s: expected redirect server %v", tt.name, tt.redirect)
+		}
+	}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		port     string
+		host     string
+		expected string
+	}{
+		{"443", "inventory.example.com", "https://inventory.example.com/api/v1/products?page=2"},
+		{"443", "inventory.example.com:80", "https://inventory.example.com/api/v1/products?page=2"},
+		{"8443", "inventory.example.com:8080", "https://inventory.example.com:8443/api/v1/products?page=2"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/products?page=2", nil)
+		req.Host = tt.host
+		w := httptest.NewRecorder()
+		redirectToHTTPS(tt.port).ServeHTTP(w, req)
+
+		if w.Code != http.StatusPermanentRedirect {
+			t.Errorf("expected status %d, got %d", http.StatusPermanentRedirect, w.Code)
+		}
+		if got := w.Header().Get("Location"); got != tt.expected {
+			t.Errorf("expected redirect to %s, got %s", tt.expected, got)
+		}
+	}
+}
@@ -1,7 +1,9 @@
 import type { NextConfig } from "next";
 
+// NEXT_OUTPUT=export builds a static export into out/, which the backend
+// can embed and serve itself (see backend/internal/web).
 const nextConfig: NextConfig = {
-  output: 'standalone',
+  output: process.env.NEXT_OUTPUT === 'export' ? 'export' : 'standalone',
   serverExternalPackages: ['@tailwindcss/postcss'],
 };
 
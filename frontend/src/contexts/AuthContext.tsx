@@ -44,11 +44,14 @@ export function AuthProvider({ children }: { children: React.ReactNode }) {
   const login = async (credentials: LoginRequest) => {
     try {
       const response = await api.post<AuthResponse>('/auth/login', credentials)
-      const { user, access_token, token_type } = response.data
+      const { user, access_token, refresh_token, token_type } = response.data
 
       // Store tokens
       localStorage.setItem('access_token', access_token)
       localStorage.setItem('token_type', token_type)
+      if (refresh_token) {
+        localStorage.setItem('refresh_token', refresh_token)
+      }
 
       setUser(user)
     } catch (error) {
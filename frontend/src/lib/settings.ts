@@ -18,11 +18,43 @@ export interface Settings {
 
 import { api } from './api'
 
+// The API stores settings flat, by snake_case key, and rejects keys it
+// does not know. Notification and appearance preferences only live in the
+// browser.
+interface StoredSettings {
+  low_stock_threshold: number
+  maintenance_mode: boolean
+  auto_backup: boolean
+  backup_frequency: Settings['system']['backupFrequency']
+}
+
+function fromStored(stored: StoredSettings, previous?: Settings): Settings {
+  return {
+    lowStockThreshold: stored.low_stock_threshold,
+    notifications: previous?.notifications ?? { email: true, lowStock: true, systemAlerts: true },
+    system: {
+      maintenanceMode: stored.maintenance_mode,
+      autoBackup: stored.auto_backup,
+      backupFrequency: stored.backup_frequency
+    },
+    appearance: previous?.appearance ?? { theme: 'system', compactMode: false }
+  }
+}
+
+function toStored(settings: Settings): StoredSettings {
+  return {
+    low_stock_threshold: settings.lowStockThreshold,
+    maintenance_mode: settings.system.maintenanceMode,
+    auto_backup: settings.system.autoBackup,
+    backup_frequency: settings.system.backupFrequency
+  }
+}
+
 export const settingsApi = {
   async getSettings(): Promise<Settings> {
     try {
       const response = await api.get('/admin/settings')
-      return response.data
+      return fromStored(response.data)
     } catch (error) {
       console.error('Failed to fetch settings:', error)
       throw error
@@ -31,8 +63,8 @@ export const settingsApi = {
 
   async updateSettings(settings: Settings): Promise<Settings> {
     try {
-      const response = await api.put('/admin/settings', settings)
-      return response.data
+      const response = await api.put('/admin/settings', toStored(settings))
+      return fromStored(response.data, settings)
     } catch (error) {
       console.error('Failed to update settings:', error)
       throw error
@@ -54,7 +86,7 @@ export const settingsApi = {
     }
   },
 
-  async triggerBackup(): Promise<{ success: boolean; message: string }> {
+  async triggerBackup(): Promise<{ id: string; status: 'queued' | 'running' | 'completed' | 'failed' }> {
     try {
       const response = await api.post('/admin/settings/backup')
       return response.data
@@ -39,6 +39,7 @@ export interface RegisterRequest {
 export interface AuthResponse {
   user: User
   access_token: string
+  refresh_token?: string
   token_type: string
   expires_in: number
 }
@@ -147,6 +148,17 @@ export interface UpdateCategoryRequest {
 // Notification types
 export type NotificationType = 'low_stock' | 'system' | 'user'
 
+export type NotificationPriority = 'normal' | 'critical'
+
+export type NotificationAction = 'view_product' | 'create_purchase_order' | 'acknowledge'
+
+export interface NotificationMetadata {
+  entity_type?: string
+  entity_id?: string
+  action?: NotificationAction
+  link?: string
+}
+
 export interface Notification {
   id: string
   user_id: string
@@ -154,12 +166,22 @@ export interface Notification {
   type: NotificationType
   is_read: boolean
   created_at: string
+  scheduled_at?: string
+  delivered_at?: string
+  priority: NotificationPriority
+  acknowledged_at?: string
+  acknowledged_by?: string
+  escalated_at?: string
+  metadata?: NotificationMetadata
 }
 
 export interface CreateNotificationRequest {
   user_id: string
   message: string
   type: NotificationType
+  scheduled_at?: string
+  priority?: NotificationPriority
+  metadata?: NotificationMetadata
 }
 
 export interface NotificationFilter {
@@ -253,9 +275,38 @@ export interface SystemStatus {
 }
 
 // WebSocket message types
-export interface WebSocketMessage {
-  type: 'stock_change' | 'notification' | 'system_status' | 'stock_update'
-  data?: Record<string, unknown>
-  message?: string
-  timestamp?: string
-}
\ No newline at end of file
+// Every server event is wrapped in a versioned envelope. Batched frames
+// arrive as a 'batch' envelope whose data is an array of envelopes.
+export type WebSocketEventType =
+  | 'stock_update'
+  | 'notifications'
+  | 'system_status'
+  | 'stock_change'
+  | 'notification'
+  | 'announcement'
+  | 'announcements'
+  | 'dashboard_stats'
+  | 'server_shutdown'
+  | 'activity'
+  | 'batch'
+
+export interface WebSocketMessage<T = Record<string, unknown>> {
+  version: number
+  type: WebSocketEventType
+  timestamp: string
+  data: T
+}
+
+export interface StockChangedEvent {
+  product_id: string
+  new_stock: number
+}
+
+export interface NotificationEvent {
+  id: string
+  user_id: string
+  message: string
+  notification_type: NotificationType
+  priority: NotificationPriority
+  metadata?: NotificationMetadata
+}